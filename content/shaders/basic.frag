@@ -2,23 +2,151 @@
 //#version 300 es
 //precision mediump float;
 
+#define MAX_LIGHTS 32
+#define LIGHT_TILES_X 16
+#define LIGHT_TILES_Y 9
+#define LIGHT_TILE_COUNT (LIGHT_TILES_X * LIGHT_TILES_Y)
+#define MAX_SHADOW_MAPS 8
+
+struct Light {
+	vec4 position;	// xyz = world position, w = type
+	vec4 direction;	// xyz = direction, w = range
+	vec4 color;		// rgb = color, a = intensity
+	vec4 cone;		// x = cos(inner angle), y = cos(outer angle)
+	vec4 shadow;	// x = first shadow map (-1 for none), y = map count, z = depth bias, w = normal bias
+	vec4 shadowFilter;	// x = PCF radius, y = texel size
+};
+
+#ifdef VULKAN
+	layout(set = 0, binding = 0) readonly uniform UniformBufferObject {
+#else
+	uniform struct GlobalData {
+#endif
+	mat4 view;
+	mat4 projection;
+	mat4 uiView;
+	mat4 uiProjection;
+	vec3 cameraPosition;
+	vec3 uiCameraPosition;
+	float time;
+	vec2 screenSize;
+	int lightCount;
+	vec4 ambientLight;
+	Light lights[MAX_LIGHTS];
+	uvec4 lightTiles[LIGHT_TILE_COUNT / 4];
+	vec4 shadowCascadeSplits;
+	mat4 shadowMatrices[MAX_SHADOW_MAPS];
+} globalData;
+
 #ifdef VULKAN
 	layout(location = 0) in vec4 fragColor;
 	layout(location = 1) in vec2 fragTexCoords;
+	layout(location = 2) in vec3 fragPosition;
+	layout(location = 3) in vec3 fragNormal;
 
 	layout(binding = 1) uniform sampler2D texSampler;
+	layout(binding = 4) uniform sampler2DArray shadowMap;
 #else
 	in vec4 fragColor;
 	in vec2 fragTexCoords;
+	in vec3 fragPosition;
+	in vec3 fragNormal;
 
 	uniform sampler2D texSampler;
+	uniform highp sampler2DArray shadowMap;
 #endif
 
 layout(location = 0) out vec4 outColor;
 layout(location = 1) out float reveal;
 
+// shadow is how much of the light reaches the fragment, from 0 in full
+// shadow to 1. Directional lights pick their cascade by the view depth of
+// the fragment, the shadow map is compared over the PCF radius
+float shadow(Light light, vec3 normal) {
+	int index = int(light.shadow.x);
+	int count = int(light.shadow.y);
+	if (int(light.position.w) == 0) {
+		float viewDepth = -(globalData.view * vec4(fragPosition, 1.0)).z;
+		int cascade = 0;
+		while (cascade < count && viewDepth > globalData.shadowCascadeSplits[cascade])
+			cascade++;
+		if (cascade == count)
+			return 1.0;
+		index += cascade;
+	}
+	vec3 p = fragPosition + normal * light.shadow.w;
+	highp vec4 clip = globalData.shadowMatrices[index] * vec4(p, 1.0);
+	if (clip.w <= 0.0)
+		return 1.0;
+	highp float z = clip.z / clip.w - light.shadow.z;
+	if (z > 1.0)
+		return 1.0;
+	float size = 1.0 / light.shadowFilter.y;
+	ivec2 bounds = min(ivec2(int(size + 0.5)), textureSize(shadowMap, 0).xy);
+	ivec2 texel = ivec2(floor((clip.xy / clip.w * 0.5 + 0.5) * size));
+	int radius = int(light.shadowFilter.x);
+	float lit = 0.0;
+	float total = 0.0;
+	for (int y = -radius; y <= radius; y++) {
+		for (int x = -radius; x <= radius; x++) {
+			ivec2 at = texel + ivec2(x, y);
+			highp float depth = 1.0;
+			if (all(greaterThanEqual(at, ivec2(0))) && all(lessThan(at, bounds))) {
+				depth = texelFetch(shadowMap, ivec3(at, index), 0).r;
+#ifndef VULKAN
+				// GL keeps the depth of -1 to 1 as 0 to 1
+				depth = depth * 2.0 - 1.0;
+#endif
+			}
+			if (z <= depth)
+				lit += 1.0;
+			total += 1.0;
+		}
+	}
+	return lit / total;
+}
+
+vec3 lightContribution(Light light, vec3 normal) {
+	int type = int(light.position.w);
+	vec3 toLight = -light.direction.xyz;
+	float attenuation = 1.0;
+	if (type != 0) {
+		toLight = light.position.xyz - fragPosition;
+		float dist = length(toLight);
+		toLight /= max(dist, 0.0001);
+		float falloff = clamp(1.0 - pow(dist / light.direction.w, 2.0), 0.0, 1.0);
+		attenuation = falloff * falloff;
+		if (type == 2) {
+			float theta = dot(-toLight, light.direction.xyz);
+			attenuation *= smoothstep(light.cone.y, light.cone.x, theta);
+		}
+	}
+	float diffuse = max(dot(normal, toLight), 0.0);
+	if (light.shadow.x >= 0.0)
+		attenuation *= shadow(light, normal);
+	return light.color.rgb * light.color.a * diffuse * attenuation;
+}
+
+vec3 lighting() {
+	if (globalData.lightCount == 0)
+		return vec3(1.0);
+	vec3 normal = normalize(fragNormal);
+	ivec2 tile = ivec2(gl_FragCoord.xy / globalData.screenSize * vec2(LIGHT_TILES_X, LIGHT_TILES_Y));
+	tile = clamp(tile, ivec2(0), ivec2(LIGHT_TILES_X - 1, LIGHT_TILES_Y - 1));
+	int tileIdx = tile.y * LIGHT_TILES_X + tile.x;
+	uint mask = globalData.lightTiles[tileIdx / 4][tileIdx % 4];
+	vec3 light = globalData.ambientLight.rgb;
+	while (mask != 0u) {
+		int i = findLSB(mask);
+		mask &= mask - 1u;
+		light += lightContribution(globalData.lights[i], normal);
+	}
+	return light;
+}
+
 void main() {
-    vec4 unWeightedColor = texture(texSampler, fragTexCoords) * fragColor;
+	vec4 unWeightedColor = texture(texSampler, fragTexCoords) * fragColor;
+	unWeightedColor.rgb *= lighting();
 #ifdef OIT
 	float distWeight = clamp(0.03 / (1e-5 + pow(gl_FragCoord.z / 200.0, 4.0)), 1e-2, 3e3);
 	float alphaWeight = min(1.0, max(max(unWeightedColor.r, unWeightedColor.g),
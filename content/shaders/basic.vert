@@ -11,6 +11,20 @@ layout (location = 5) in ivec4 JointIds;
 layout (location = 6) in vec4 JointWeights;
 layout (location = 7) in vec3 MorphTarget;
 
+#define MAX_LIGHTS 32
+#define LIGHT_TILES_X 16
+#define LIGHT_TILES_Y 9
+#define LIGHT_TILE_COUNT (LIGHT_TILES_X * LIGHT_TILES_Y)
+
+struct Light {
+	vec4 position;	// xyz = world position, w = type
+	vec4 direction;	// xyz = direction, w = range
+	vec4 color;		// rgb = color, a = intensity
+	vec4 cone;		// x = cos(inner angle), y = cos(outer angle)
+	vec4 shadow;	// x = first shadow map (-1 for none), y = map count, z = depth bias, w = normal bias
+	vec4 shadowFilter;	// x = PCF radius, y = texel size
+};
+
 #ifdef VULKAN
 	layout(set = 0, binding = 0) readonly uniform UniformBufferObject {
 #else
@@ -23,6 +37,11 @@ layout (location = 7) in vec3 MorphTarget;
 	vec3 cameraPosition;
 	vec3 uiCameraPosition;
 	float time;
+	vec2 screenSize;
+	int lightCount;
+	vec4 ambientLight;
+	Light lights[MAX_LIGHTS];
+	uvec4 lightTiles[LIGHT_TILE_COUNT / 4];
 } globalData;
 
 #ifdef VULKAN
@@ -31,12 +50,16 @@ layout (location = 7) in vec3 MorphTarget;
 
 	layout(location = 0) out vec4 fragColor;
 	layout(location = 1) out vec2 fragTexCoords;
+	layout(location = 2) out vec3 fragPosition;
+	layout(location = 3) out vec3 fragNormal;
 #else
 	#define INSTANCE_VEC4_COUNT 5
 	uniform sampler2D instanceSampler;
 
 	out vec4 fragColor;
 	out vec2 fragTexCoords;
+	out vec3 fragPosition;
+	out vec3 fragNormal;
 
 	mat4 pullModel(int xOffset) {
 		mat4 model;
@@ -56,5 +79,8 @@ void main() {
 #endif
 	fragColor = Color * color;
 	fragTexCoords = UV0;
-	gl_Position = globalData.projection * globalData.view * model * vec4(Position, 1.0);
+	vec4 worldPosition = model * vec4(Position, 1.0);
+	fragPosition = worldPosition.xyz;
+	fragNormal = normalize(transpose(inverse(mat3(model))) * Normal);
+	gl_Position = globalData.projection * globalData.view * worldPosition;
 }
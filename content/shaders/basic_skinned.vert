@@ -0,0 +1,121 @@
+#version 460
+//#version 300 es
+//precision mediump float;
+
+layout (location = 0) in vec3 Position;
+layout (location = 1) in vec3 Normal;
+layout (location = 2) in vec4 Tangent;
+layout (location = 3) in vec2 UV0;
+layout (location = 4) in vec4 Color;
+layout (location = 5) in ivec4 JointIds;
+layout (location = 6) in vec4 JointWeights;
+layout (location = 7) in vec3 MorphTarget;
+
+#define MAX_LIGHTS 32
+#define LIGHT_TILES_X 16
+#define LIGHT_TILES_Y 9
+#define LIGHT_TILE_COUNT (LIGHT_TILES_X * LIGHT_TILES_Y)
+
+struct Light {
+	vec4 position;	// xyz = world position, w = type
+	vec4 direction;	// xyz = direction, w = range
+	vec4 color;		// rgb = color, a = intensity
+	vec4 cone;		// x = cos(inner angle), y = cos(outer angle)
+	vec4 shadow;	// x = first shadow map (-1 for none), y = map count, z = depth bias, w = normal bias
+	vec4 shadowFilter;	// x = PCF radius, y = texel size
+};
+
+#ifdef VULKAN
+	layout(set = 0, binding = 0) readonly uniform UniformBufferObject {
+#else
+	uniform struct GlobalData {
+#endif
+	mat4 view;
+	mat4 projection;
+	mat4 uiView;
+	mat4 uiProjection;
+	vec3 cameraPosition;
+	vec3 uiCameraPosition;
+	float time;
+	vec2 screenSize;
+	int lightCount;
+	vec4 ambientLight;
+	Light lights[MAX_LIGHTS];
+	uvec4 lightTiles[LIGHT_TILE_COUNT / 4];
+} globalData;
+
+#ifdef VULKAN
+	layout(set = 0, binding = 2) readonly buffer JointPalette {
+		mat4 joints[];
+	} jointPalette;
+
+	layout(location = 8) in mat4 model;
+	layout(location = 12) in vec4 color;
+	layout(location = 13) in float jointOffset;
+
+	layout(location = 0) out vec4 fragColor;
+	layout(location = 1) out vec2 fragTexCoords;
+	layout(location = 2) out vec3 fragPosition;
+	layout(location = 3) out vec3 fragNormal;
+#else
+	#define INSTANCE_VEC4_COUNT 6
+	#define DATA_TEXTURE_WIDTH 1024
+	uniform sampler2D instanceSampler;
+	// The same joints as the Vulkan buffer, DATA_TEXTURE_WIDTH vec4s a row
+	uniform sampler2D jointSampler;
+
+	out vec4 fragColor;
+	out vec2 fragTexCoords;
+	out vec3 fragPosition;
+	out vec3 fragNormal;
+
+	mat4 pullModel(int xOffset) {
+		mat4 model;
+		model[0] = texelFetch(instanceSampler, ivec2(xOffset,0), 0);
+		model[1] = texelFetch(instanceSampler, ivec2(xOffset+1,0), 0);
+		model[2] = texelFetch(instanceSampler, ivec2(xOffset+2,0), 0);
+		model[3] = texelFetch(instanceSampler, ivec2(xOffset+3,0), 0);
+		return model;
+	}
+
+	mat4 pullJoint(int index) {
+		mat4 joint;
+		for (int i = 0; i < 4; i++) {
+			int texel = index * 4 + i;
+			joint[i] = texelFetch(jointSampler, ivec2(texel % DATA_TEXTURE_WIDTH, texel / DATA_TEXTURE_WIDTH), 0);
+		}
+		return joint;
+	}
+#endif
+
+void main() {
+#ifndef VULKAN
+	int xOffset = gl_InstanceID*INSTANCE_VEC4_COUNT;
+	mat4 model = pullModel(xOffset);
+	vec4 color = texelFetch(instanceSampler, ivec2(xOffset+4,0), 0);
+	float jointOffset = texelFetch(instanceSampler, ivec2(xOffset+5,0), 0).x;
+#endif
+	mat4 skin = mat4(1.0);
+	float totalWeight = dot(JointWeights, vec4(1.0));
+	if (totalWeight > 0.0) {
+		int offset = int(jointOffset);
+#ifdef VULKAN
+		skin = JointWeights.x * jointPalette.joints[offset + JointIds.x]
+			+ JointWeights.y * jointPalette.joints[offset + JointIds.y]
+			+ JointWeights.z * jointPalette.joints[offset + JointIds.z]
+			+ JointWeights.w * jointPalette.joints[offset + JointIds.w];
+#else
+		skin = JointWeights.x * pullJoint(offset + JointIds.x)
+			+ JointWeights.y * pullJoint(offset + JointIds.y)
+			+ JointWeights.z * pullJoint(offset + JointIds.z)
+			+ JointWeights.w * pullJoint(offset + JointIds.w);
+#endif
+		skin /= totalWeight;
+	}
+	fragColor = Color * color;
+	fragTexCoords = UV0;
+	vec4 worldPosition = model * skin * vec4(Position, 1.0);
+	fragPosition = worldPosition.xyz;
+	fragNormal = normalize(transpose(inverse(mat3(model * skin))) * Normal);
+	gl_Position = globalData.projection * globalData.view * worldPosition;
+}
@@ -0,0 +1,14 @@
+#version 450
+
+// The composite of multisampled render targets, it runs for every sample so
+// the edges of transparent drawings are resolved like the opaque ones
+layout (location = 0) out vec4 outColor;
+
+layout(input_attachment_index = 0, binding = 0) uniform subpassInputMS texColor;
+layout(input_attachment_index = 1, binding = 1) uniform subpassInputMS texWeights;
+
+void main() {
+	vec4 accum = subpassLoad(texColor, gl_SampleID);
+	float reveal = subpassLoad(texWeights, gl_SampleID).r;
+	outColor = vec4(accum.rgb / max(accum.a, 1e-5), reveal);
+}
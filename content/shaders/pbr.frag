@@ -0,0 +1,283 @@
+#version 460
+//#version 300 es
+//precision mediump float;
+
+#define MAX_LIGHTS 32
+#define LIGHT_TILES_X 16
+#define LIGHT_TILES_Y 9
+#define LIGHT_TILE_COUNT (LIGHT_TILES_X * LIGHT_TILES_Y)
+#define MAX_SHADOW_MAPS 8
+
+struct Light {
+	vec4 position;	// xyz = world position, w = type
+	vec4 direction;	// xyz = direction, w = range
+	vec4 color;		// rgb = color, a = intensity
+	vec4 cone;		// x = cos(inner angle), y = cos(outer angle)
+	vec4 shadow;	// x = first shadow map (-1 for none), y = map count, z = depth bias, w = normal bias
+	vec4 shadowFilter;	// x = PCF radius, y = texel size
+};
+
+#ifdef VULKAN
+	layout(set = 0, binding = 0) readonly uniform UniformBufferObject {
+#else
+	uniform struct GlobalData {
+#endif
+	mat4 view;
+	mat4 projection;
+	mat4 uiView;
+	mat4 uiProjection;
+	vec3 cameraPosition;
+	vec3 uiCameraPosition;
+	float time;
+	vec2 screenSize;
+	int lightCount;
+	vec4 ambientLight;
+	Light lights[MAX_LIGHTS];
+	uvec4 lightTiles[LIGHT_TILE_COUNT / 4];
+	vec4 shadowCascadeSplits;
+	mat4 shadowMatrices[MAX_SHADOW_MAPS];
+} globalData;
+
+#define PI 3.14159265359
+#define ALPHA_MASK 1
+#define ALPHA_BLEND 2
+#define TEX_BASE_COLOR 0
+#define TEX_METALLIC_ROUGHNESS 1
+#define TEX_NORMAL 2
+#define TEX_OCCLUSION 3
+#define TEX_EMISSIVE 4
+#define TEX_ENVIRONMENT 5
+#define TEX_COUNT 6
+
+#ifdef VULKAN
+	layout(location = 0) in vec4 fragColor;
+	layout(location = 1) in vec2 fragTexCoords;
+	layout(location = 2) in vec3 fragPosition;
+	layout(location = 3) in vec3 fragNormal;
+	layout(location = 4) in vec4 fragTangent;
+	layout(location = 5) in vec4 fragEmissive;
+	layout(location = 6) in vec4 fragPBRFactors;	// metallic, roughness, normal scale, occlusion strength
+	layout(location = 7) in vec4 fragSurface;		// environment intensity, alpha mode, alpha cutoff
+
+	layout(binding = 1) uniform sampler2D textures[TEX_COUNT];
+	layout(binding = 4) uniform sampler2DArray shadowMap;
+#else
+	in vec4 fragColor;
+	in vec2 fragTexCoords;
+	in vec3 fragPosition;
+	in vec3 fragNormal;
+	in vec4 fragTangent;
+	in vec4 fragEmissive;
+	in vec4 fragPBRFactors;
+	in vec4 fragSurface;
+
+	uniform sampler2D textures[TEX_COUNT];
+	uniform highp sampler2DArray shadowMap;
+#endif
+
+layout(location = 0) out vec4 outColor;
+layout(location = 1) out float reveal;
+
+// The environment is a cube map laid out as a strip of 6 faces, the lookup
+// stays half a texel inside of the face so filtering does not bleed into
+// the next face
+vec3 environment(vec3 dir) {
+	vec3 a = abs(dir);
+	float face, sc, tc, ma;
+	if (a.x >= a.y && a.x >= a.z) {
+		face = dir.x > 0.0 ? 0.0 : 1.0;
+		sc = dir.x > 0.0 ? -dir.z : dir.z;
+		tc = -dir.y;
+		ma = a.x;
+	} else if (a.y >= a.z) {
+		face = dir.y > 0.0 ? 2.0 : 3.0;
+		sc = dir.x;
+		tc = dir.y > 0.0 ? dir.z : -dir.z;
+		ma = a.y;
+	} else {
+		face = dir.z > 0.0 ? 4.0 : 5.0;
+		sc = dir.z > 0.0 ? dir.x : -dir.x;
+		tc = -dir.y;
+		ma = a.z;
+	}
+	float inset = 0.5 / float(textureSize(textures[TEX_ENVIRONMENT], 0).y);
+	vec2 uv = clamp((vec2(sc, tc) / ma + 1.0) * 0.5, inset, 1.0 - inset);
+	return texture(textures[TEX_ENVIRONMENT], vec2((face + uv.x) / 6.0, uv.y)).rgb;
+}
+
+// irradiance is the light coming from the hemisphere around the normal,
+// taken from the centers of the faces as an ambient cube
+vec3 irradiance(vec3 n) {
+	vec3 sq = n * n;
+	return sq.x * environment(vec3(n.x >= 0.0 ? 1.0 : -1.0, 0.0, 0.0))
+		+ sq.y * environment(vec3(0.0, n.y >= 0.0 ? 1.0 : -1.0, 0.0))
+		+ sq.z * environment(vec3(0.0, 0.0, n.z >= 0.0 ? 1.0 : -1.0));
+}
+
+// envBRDFApprox is the analytic fit of the split sum BRDF lookup
+vec3 envBRDFApprox(vec3 f0, float roughness, float nDotV) {
+	const vec4 c0 = vec4(-1.0, -0.0275, -0.572, 0.022);
+	const vec4 c1 = vec4(1.0, 0.0425, 1.04, -0.04);
+	vec4 r = roughness * c0 + c1;
+	float a004 = min(r.x * r.x, exp2(-9.28 * nDotV)) * r.x + r.y;
+	vec2 ab = vec2(-1.04, 1.04) * a004 + r.zw;
+	return f0 * ab.x + ab.y;
+}
+
+float distributionGGX(float nDotH, float roughness) {
+	float a = roughness * roughness;
+	float a2 = a * a;
+	float d = nDotH * nDotH * (a2 - 1.0) + 1.0;
+	return a2 / max(PI * d * d, 0.0001);
+}
+
+float geometrySmith(float nDotV, float nDotL, float roughness) {
+	float k = (roughness + 1.0) * (roughness + 1.0) / 8.0;
+	return (nDotV / (nDotV * (1.0 - k) + k)) * (nDotL / (nDotL * (1.0 - k) + k));
+}
+
+vec3 fresnelSchlick(float cosTheta, vec3 f0) {
+	return f0 + (1.0 - f0) * pow(clamp(1.0 - cosTheta, 0.0, 1.0), 5.0);
+}
+
+vec3 surfaceNormal() {
+	vec3 n = normalize(fragNormal);
+	if (dot(fragTangent.xyz, fragTangent.xyz) < 0.0001)
+		return n;
+	vec3 t = normalize(fragTangent.xyz - n * dot(n, fragTangent.xyz));
+	vec3 b = cross(n, t) * fragTangent.w;
+	vec3 m = texture(textures[TEX_NORMAL], fragTexCoords).xyz * 2.0 - 1.0;
+	m.xy *= fragPBRFactors.z;
+	return normalize(t * m.x + b * m.y + n * m.z);
+}
+
+// shadow is how much of the light reaches the fragment, from 0 in full
+// shadow to 1. Directional lights pick their cascade by the view depth of
+// the fragment, the shadow map is compared over the PCF radius
+float shadow(Light light, vec3 normal) {
+	int index = int(light.shadow.x);
+	int count = int(light.shadow.y);
+	if (int(light.position.w) == 0) {
+		float viewDepth = -(globalData.view * vec4(fragPosition, 1.0)).z;
+		int cascade = 0;
+		while (cascade < count && viewDepth > globalData.shadowCascadeSplits[cascade])
+			cascade++;
+		if (cascade == count)
+			return 1.0;
+		index += cascade;
+	}
+	vec3 p = fragPosition + normal * light.shadow.w;
+	highp vec4 clip = globalData.shadowMatrices[index] * vec4(p, 1.0);
+	if (clip.w <= 0.0)
+		return 1.0;
+	highp float z = clip.z / clip.w - light.shadow.z;
+	if (z > 1.0)
+		return 1.0;
+	float size = 1.0 / light.shadowFilter.y;
+	ivec2 bounds = min(ivec2(int(size + 0.5)), textureSize(shadowMap, 0).xy);
+	ivec2 texel = ivec2(floor((clip.xy / clip.w * 0.5 + 0.5) * size));
+	int radius = int(light.shadowFilter.x);
+	float lit = 0.0;
+	float total = 0.0;
+	for (int y = -radius; y <= radius; y++) {
+		for (int x = -radius; x <= radius; x++) {
+			ivec2 at = texel + ivec2(x, y);
+			highp float depth = 1.0;
+			if (all(greaterThanEqual(at, ivec2(0))) && all(lessThan(at, bounds))) {
+				depth = texelFetch(shadowMap, ivec3(at, index), 0).r;
+#ifndef VULKAN
+				// GL keeps the depth of -1 to 1 as 0 to 1
+				depth = depth * 2.0 - 1.0;
+#endif
+			}
+			if (z <= depth)
+				lit += 1.0;
+			total += 1.0;
+		}
+	}
+	return lit / total;
+}
+
+vec3 directLighting(vec3 n, vec3 v, vec3 albedo, vec3 f0, float metallic, float roughness) {
+	ivec2 tile = ivec2(gl_FragCoord.xy / globalData.screenSize * vec2(LIGHT_TILES_X, LIGHT_TILES_Y));
+	tile = clamp(tile, ivec2(0), ivec2(LIGHT_TILES_X - 1, LIGHT_TILES_Y - 1));
+	int tileIdx = tile.y * LIGHT_TILES_X + tile.x;
+	uint mask = globalData.lightTiles[tileIdx / 4][tileIdx % 4];
+	float nDotV = max(dot(n, v), 0.0001);
+	vec3 result = vec3(0.0);
+	while (mask != 0u) {
+		int i = findLSB(mask);
+		mask &= mask - 1u;
+		Light light = globalData.lights[i];
+		int type = int(light.position.w);
+		vec3 toLight = -light.direction.xyz;
+		float attenuation = 1.0;
+		if (type != 0) {
+			toLight = light.position.xyz - fragPosition;
+			float dist = length(toLight);
+			toLight /= max(dist, 0.0001);
+			float falloff = clamp(1.0 - pow(dist / light.direction.w, 2.0), 0.0, 1.0);
+			attenuation = falloff * falloff;
+			if (type == 2) {
+				float theta = dot(-toLight, light.direction.xyz);
+				attenuation *= smoothstep(light.cone.y, light.cone.x, theta);
+			}
+		}
+		float nDotL = max(dot(n, toLight), 0.0);
+		if (nDotL <= 0.0 || attenuation <= 0.0)
+			continue;
+		if (light.shadow.x >= 0.0)
+			attenuation *= shadow(light, normalize(fragNormal));
+		vec3 h = normalize(v + toLight);
+		vec3 f = fresnelSchlick(max(dot(h, v), 0.0), f0);
+		float d = distributionGGX(max(dot(n, h), 0.0), roughness);
+		float g = geometrySmith(nDotV, nDotL, roughness);
+		vec3 specular = d * g * f / (4.0 * nDotV * nDotL + 0.0001);
+		vec3 kd = (1.0 - f) * (1.0 - metallic);
+		vec3 radiance = light.color.rgb * light.color.a * attenuation;
+		result += (kd * albedo / PI + specular) * radiance * nDotL;
+	}
+	return result;
+}
+
+void main() {
+	vec4 base = texture(textures[TEX_BASE_COLOR], fragTexCoords) * fragColor;
+	if (int(fragSurface.y) == ALPHA_MASK) {
+		if (base.a < fragSurface.z)
+			discard;
+		base.a = 1.0;
+	} else if (int(fragSurface.y) != ALPHA_BLEND) {
+		base.a = 1.0;
+	}
+	vec4 mr = texture(textures[TEX_METALLIC_ROUGHNESS], fragTexCoords);
+	float metallic = clamp(mr.b * fragPBRFactors.x, 0.0, 1.0);
+	float roughness = clamp(mr.g * fragPBRFactors.y, 0.04, 1.0);
+	float ao = mix(1.0, texture(textures[TEX_OCCLUSION], fragTexCoords).r, fragPBRFactors.w);
+	vec3 n = surfaceNormal();
+	vec3 v = normalize(globalData.cameraPosition - fragPosition);
+	vec3 albedo = base.rgb;
+	vec3 f0 = mix(vec3(0.04), albedo, metallic);
+	float nDotV = max(dot(n, v), 0.0001);
+	vec3 color = directLighting(n, v, albedo, f0, metallic, roughness);
+	vec3 diffuseIBL = irradiance(n) * albedo * (1.0 - metallic);
+	vec3 r = reflect(-v, n);
+	vec3 prefiltered = mix(environment(r), irradiance(r), roughness);
+	vec3 specularIBL = prefiltered * envBRDFApprox(f0, roughness, nDotV);
+	color += (diffuseIBL + specularIBL) * fragSurface.x * ao;
+	color += globalData.ambientLight.rgb * albedo * ao;
+	color += texture(textures[TEX_EMISSIVE], fragTexCoords).rgb * fragEmissive.rgb * fragEmissive.w;
+	vec4 unWeightedColor = vec4(color, base.a);
+#ifdef OIT
+	float distWeight = clamp(0.03 / (1e-5 + pow(gl_FragCoord.z / 200.0, 4.0)), 1e-2, 3e3);
+	float alphaWeight = min(1.0, max(max(unWeightedColor.r, unWeightedColor.g),
+	max(unWeightedColor.b, unWeightedColor.a)) * 40.0 + 0.01);
+	alphaWeight *= alphaWeight;
+	float weight = alphaWeight * distWeight;
+	outColor = vec4(unWeightedColor.rgb * unWeightedColor.a, unWeightedColor.a) * weight;
+	reveal = unWeightedColor.a;
+#else
+	if (unWeightedColor.a < (1.0 - 0.0001))
+		discard;
+	outColor = unWeightedColor;
+#endif
+}
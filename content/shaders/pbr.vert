@@ -0,0 +1,106 @@
+#version 460
+//#version 300 es
+//precision mediump float;
+
+layout (location = 0) in vec3 Position;
+layout (location = 1) in vec3 Normal;
+layout (location = 2) in vec4 Tangent;
+layout (location = 3) in vec2 UV0;
+layout (location = 4) in vec4 Color;
+layout (location = 5) in ivec4 JointIds;
+layout (location = 6) in vec4 JointWeights;
+layout (location = 7) in vec3 MorphTarget;
+
+#define MAX_LIGHTS 32
+#define LIGHT_TILES_X 16
+#define LIGHT_TILES_Y 9
+#define LIGHT_TILE_COUNT (LIGHT_TILES_X * LIGHT_TILES_Y)
+
+struct Light {
+	vec4 position;	// xyz = world position, w = type
+	vec4 direction;	// xyz = direction, w = range
+	vec4 color;		// rgb = color, a = intensity
+	vec4 cone;		// x = cos(inner angle), y = cos(outer angle)
+	vec4 shadow;	// x = first shadow map (-1 for none), y = map count, z = depth bias, w = normal bias
+	vec4 shadowFilter;	// x = PCF radius, y = texel size
+};
+
+#ifdef VULKAN
+	layout(set = 0, binding = 0) readonly uniform UniformBufferObject {
+#else
+	uniform struct GlobalData {
+#endif
+	mat4 view;
+	mat4 projection;
+	mat4 uiView;
+	mat4 uiProjection;
+	vec3 cameraPosition;
+	vec3 uiCameraPosition;
+	float time;
+	vec2 screenSize;
+	int lightCount;
+	vec4 ambientLight;
+	Light lights[MAX_LIGHTS];
+	uvec4 lightTiles[LIGHT_TILE_COUNT / 4];
+} globalData;
+
+#ifdef VULKAN
+	layout(location = 8) in mat4 model;
+	layout(location = 12) in vec4 baseColor;
+	layout(location = 13) in vec4 emissive;
+	layout(location = 14) in vec4 pbrFactors;
+	layout(location = 15) in vec4 surface;
+
+	layout(location = 0) out vec4 fragColor;
+	layout(location = 1) out vec2 fragTexCoords;
+	layout(location = 2) out vec3 fragPosition;
+	layout(location = 3) out vec3 fragNormal;
+	layout(location = 4) out vec4 fragTangent;
+	layout(location = 5) out vec4 fragEmissive;
+	layout(location = 6) out vec4 fragPBRFactors;
+	layout(location = 7) out vec4 fragSurface;
+#else
+	#define INSTANCE_VEC4_COUNT 8
+	uniform sampler2D instanceSampler;
+
+	out vec4 fragColor;
+	out vec2 fragTexCoords;
+	out vec3 fragPosition;
+	out vec3 fragNormal;
+	out vec4 fragTangent;
+	out vec4 fragEmissive;
+	out vec4 fragPBRFactors;
+	out vec4 fragSurface;
+
+	mat4 pullModel(int xOffset) {
+		mat4 model;
+		model[0] = texelFetch(instanceSampler, ivec2(xOffset,0), 0);
+		model[1] = texelFetch(instanceSampler, ivec2(xOffset+1,0), 0);
+		model[2] = texelFetch(instanceSampler, ivec2(xOffset+2,0), 0);
+		model[3] = texelFetch(instanceSampler, ivec2(xOffset+3,0), 0);
+		return model;
+	}
+#endif
+
+void main() {
+#ifndef VULKAN
+	int xOffset = gl_InstanceID*INSTANCE_VEC4_COUNT;
+	mat4 model = pullModel(xOffset);
+	vec4 baseColor = texelFetch(instanceSampler, ivec2(xOffset+4,0), 0);
+	vec4 emissive = texelFetch(instanceSampler, ivec2(xOffset+5,0), 0);
+	vec4 pbrFactors = texelFetch(instanceSampler, ivec2(xOffset+6,0), 0);
+	vec4 surface = texelFetch(instanceSampler, ivec2(xOffset+7,0), 0);
+#endif
+	fragColor = Color * baseColor;
+	fragTexCoords = UV0;
+	vec4 worldPosition = model * vec4(Position, 1.0);
+	fragPosition = worldPosition.xyz;
+	fragNormal = normalize(transpose(inverse(mat3(model))) * Normal);
+	// Meshes without tangents leave them zero, the fragment shader then
+	// skips the normal map
+	fragTangent = vec4(mat3(model) * Tangent.xyz, Tangent.w);
+	fragEmissive = emissive;
+	fragPBRFactors = pbrFactors;
+	fragSurface = surface;
+	gl_Position = globalData.projection * globalData.view * worldPosition;
+}
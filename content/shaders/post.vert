@@ -0,0 +1,29 @@
+#version 460
+
+// The post process passes draw a quad that covers all of clip space, the
+// vertex and instance layout is the one of every other shader so the pass
+// can be drawn like any other drawing
+
+layout(location = 0) in vec3 Position;
+
+layout(location = 8) in mat4 model;
+layout(location = 12) in vec4 params0;
+layout(location = 13) in vec4 params1;
+layout(location = 14) in vec4 params2;
+layout(location = 15) in vec4 params3;
+
+layout(location = 0) out vec2 fragTexCoords;
+layout(location = 1) flat out vec4 fragParams0;
+layout(location = 2) flat out vec4 fragParams1;
+layout(location = 3) flat out vec4 fragParams2;
+layout(location = 4) flat out vec4 fragParams3;
+
+void main() {
+	fragParams0 = params0;
+	fragParams1 = params1;
+	fragParams2 = params2;
+	fragParams3 = params3;
+	// The top of clip space is the first row of the target being read
+	fragTexCoords = Position.xy * 0.5 + 0.5;
+	gl_Position = vec4(Position.xy, 0.0, 1.0);
+}
@@ -0,0 +1,29 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = threshold, y = soft knee
+
+void main() {
+	vec3 color = source(fragTexCoords).rgb;
+	float brightness = max(color.r, max(color.g, color.b));
+	float knee = max(fragParams0.x * fragParams0.y, 0.0001);
+	float soft = clamp(brightness - fragParams0.x + knee, 0.0, 2.0 * knee);
+	soft = soft * soft / (4.0 * knee);
+	float contribution = max(soft, brightness - fragParams0.x) / max(brightness, 0.0001);
+	outColor = vec4(color * contribution, 1.0);
+}
@@ -0,0 +1,32 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: xy = direction, z = spread of the samples in pixels
+
+const float weights[5] = float[](0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216);
+
+void main() {
+	vec2 texel = 1.0 / vec2(textureSize(textures[0], 0));
+	vec2 offset = fragParams0.xy * texel * fragParams0.z;
+	vec3 sum = source(fragTexCoords).rgb * weights[0];
+	for (int i = 1; i < 5; i++) {
+		sum += source(fragTexCoords + offset * float(i)).rgb * weights[i];
+		sum += source(fragTexCoords - offset * float(i)).rgb * weights[i];
+	}
+	outColor = vec4(sum, 1.0);
+}
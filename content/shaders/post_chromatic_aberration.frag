@@ -0,0 +1,28 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = distance between red and blue at the corners as a
+// fraction of the image
+
+void main() {
+	vec2 offset = (fragTexCoords - 0.5) * fragParams0.x;
+	float r = source(fragTexCoords + offset).r;
+	float g = source(fragTexCoords).g;
+	float b = source(fragTexCoords - offset).b;
+	outColor = vec4(r, g, b, 1.0);
+}
@@ -0,0 +1,34 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect and textures[2] is the LUT
+layout(binding = 1) uniform sampler2D textures[3];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = intensity, y = size of the LUT
+
+vec3 lookUp(vec3 color, float slice, float size) {
+	vec2 cell = (color.rg * (size - 1.0) + 0.5) / vec2(size * size, size);
+	return texture(textures[2], cell + vec2(slice / size, 0.0)).rgb;
+}
+
+void main() {
+	vec4 color = source(fragTexCoords);
+	vec3 c = clamp(color.rgb, vec3(0.0), vec3(1.0));
+	float size = fragParams0.y;
+	float blue = c.b * (size - 1.0);
+	float slice = floor(blue);
+	vec3 graded = mix(lookUp(c, slice, size), lookUp(c, min(slice + 1.0, size - 1.0), size), blue - slice);
+	outColor = vec4(mix(color.rgb, graded, fragParams0.x), 1.0);
+}
@@ -0,0 +1,45 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = longest search span in pixels, y = reduce multiplier,
+// z = smallest reduction
+
+const vec3 lumaWeights = vec3(0.299, 0.587, 0.114);
+
+void main() {
+	vec2 uv = fragTexCoords;
+	vec2 texel = 1.0 / vec2(textureSize(textures[0], 0));
+	float lumaNW = dot(source(uv + vec2(-1.0, -1.0) * texel).rgb, lumaWeights);
+	float lumaNE = dot(source(uv + vec2(1.0, -1.0) * texel).rgb, lumaWeights);
+	float lumaSW = dot(source(uv + vec2(-1.0, 1.0) * texel).rgb, lumaWeights);
+	float lumaSE = dot(source(uv + vec2(1.0, 1.0) * texel).rgb, lumaWeights);
+	float lumaM = dot(source(uv).rgb, lumaWeights);
+	float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+	float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+	vec2 dir = vec2(-((lumaNW + lumaNE) - (lumaSW + lumaSE)), (lumaNW + lumaSW) - (lumaNE + lumaSE));
+	float reduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * fragParams0.y, fragParams0.z);
+	float scale = 1.0 / (min(abs(dir.x), abs(dir.y)) + reduce);
+	dir = clamp(dir * scale, vec2(-fragParams0.x), vec2(fragParams0.x)) * texel;
+	vec3 rgbA = 0.5 * (source(uv + dir * (1.0 / 3.0 - 0.5)).rgb + source(uv + dir * (2.0 / 3.0 - 0.5)).rgb);
+	vec3 rgbB = rgbA * 0.5 + 0.25 * (source(uv - dir * 0.5).rgb + source(uv + dir * 0.5).rgb);
+	float lumaB = dot(rgbB, lumaWeights);
+	if (lumaB < lumaMin || lumaB > lumaMax)
+		outColor = vec4(rgbA, 1.0);
+	else
+		outColor = vec4(rgbB, 1.0);
+}
@@ -0,0 +1,26 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = exposure, y = gamma
+
+void main() {
+	vec4 color = source(fragTexCoords);
+	vec3 mapped = vec3(1.0) - exp(-color.rgb * fragParams0.x);
+	mapped = pow(mapped, vec3(1.0 / fragParams0.y));
+	outColor = vec4(mapped, 1.0);
+}
@@ -0,0 +1,27 @@
+#version 460
+
+layout(location = 0) in vec2 fragTexCoords;
+layout(location = 1) flat in vec4 fragParams0;
+layout(location = 2) flat in vec4 fragParams1;
+layout(location = 3) flat in vec4 fragParams2;
+layout(location = 4) flat in vec4 fragParams3;
+
+// textures[0] is the output of the previous pass, textures[1] is the image
+// as it was before the first pass of the effect
+layout(binding = 1) uniform sampler2D textures[2];
+
+layout(location = 0) out vec4 outColor;
+
+vec4 source(vec2 uv) {
+	return texture(textures[0], clamp(uv, vec2(0.0), vec2(1.0)));
+}
+
+// fragParams0: x = intensity, y = radius the fade ends at, z = softness,
+// fragParams1 = color, the distance to a corner is 1
+
+void main() {
+	vec4 color = source(fragTexCoords);
+	float dist = length(fragTexCoords - 0.5) * 1.41421356;
+	float amount = smoothstep(fragParams0.y - fragParams0.z, fragParams0.y, dist) * fragParams0.x;
+	outColor = vec4(mix(color.rgb, fragParams1.rgb, amount), 1.0);
+}
@@ -0,0 +1,119 @@
+/*****************************************************************************/
+/* gltf_importer.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package asset_importer
+
+import (
+	"encoding/json"
+	"kaiju/assets"
+	"kaiju/assets/asset_info"
+	"kaiju/editor/cache/project_cache"
+	"kaiju/filesystem"
+	"kaiju/rendering"
+	"kaiju/rendering/loaders"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/KaijuEngine/uuid"
+)
+
+type GLTFImporter struct{}
+
+func (m GLTFImporter) Handles(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".gltf" || ext == ".glb"
+}
+
+func (m GLTFImporter) Import(path string) error {
+	adi, err := createADI(path, cleanupOBJ)
+	if err != nil {
+		return err
+	}
+	adi.Type = ImportTypeGLTF
+	key, err := filepath.Rel("content", path)
+	if err != nil {
+		return err
+	}
+	db := assets.NewDatabase()
+	res, err := loaders.GLTF(nil, filepath.ToSlash(key), &db)
+	if err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	for _, o := range res.Meshes {
+		info := adi.SpawnChild(uuid.New().String())
+		info.Type = ImportTypeMesh
+		info.ParentID = adi.ID
+		if err := project_cache.CacheMesh(info, o); err != nil {
+			return err
+		}
+		adi.Children = append(adi.Children, info)
+		if o.Material != nil && !written[o.MaterialName] {
+			written[o.MaterialName] = true
+			if err := writeGLTFMaterial(path, o.MaterialName, *o.Material); err != nil {
+				return err
+			}
+		}
+	}
+	return asset_info.Write(adi)
+}
+
+// writeGLTFMaterial writes the material next to the glTF file as
+// <file>_<material>.material. A material file that already exists is left
+// alone so changes made to it are kept when the file is imported again
+func writeGLTFMaterial(path, name string, data rendering.MaterialData) error {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	matPath := filepath.Join(filepath.Dir(path), base+"_"+name+rendering.MaterialFileExtension)
+	if filesystem.FileExists(matPath) {
+		return nil
+	}
+	str, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := filesystem.WriteTextFile(matPath, string(str)); err != nil {
+		return err
+	}
+	return MaterialImporter{}.Import(matPath)
+}
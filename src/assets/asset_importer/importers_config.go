@@ -44,9 +44,12 @@ import (
 type ImportType = string
 
 const (
-	ImportTypeObj  ImportType = "obj"
-	ImportTypeMesh ImportType = "mesh"
-	ImportTypePNG  ImportType = "png"
+	ImportTypeObj      ImportType = "obj"
+	ImportTypeMesh     ImportType = "mesh"
+	ImportTypePNG      ImportType = "png"
+	ImportTypeNavGrid  ImportType = "navgrid"
+	ImportTypeMaterial ImportType = "material"
+	ImportTypeGLTF     ImportType = "gltf"
 )
 
 var (
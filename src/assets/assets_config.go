@@ -39,7 +39,17 @@ package assets
 
 // Textures
 const (
-	TextureSquare = "textures/square.png"
+	TextureSquare      = "textures/square.png"
+	TextureWhite       = "textures/white.png"
+	TextureFlatNormal  = "textures/flat_normal.png"
+	TextureEnvironment = "textures/environment.png"
+)
+
+// Materials
+const (
+	MaterialBasic   = "materials/basic.material"
+	MaterialSkinned = "materials/basic_skinned.material"
+	MaterialMorph   = "materials/basic_morph.material"
 )
 
 // Shader definitions
@@ -49,6 +59,23 @@ const (
 	ShaderDefinitionText3D       = "shaders/definitions/text3d.json"
 	ShaderDefinitionText         = "shaders/definitions/text.json"
 	ShaderDefinitionOITComposite = "shaders/definitions/oit_composite.json"
-	ShaderDefinitionUI           = "shaders/definitions/ui.json"
-	ShaderDefinitionSprite       = "shaders/definitions/sprite.json"
+	// ShaderDefinitionOITCompositeMS composes multisampled render targets
+	ShaderDefinitionOITCompositeMS = "shaders/definitions/oit_composite_ms.json"
+	ShaderDefinitionUI             = "shaders/definitions/ui.json"
+	ShaderDefinitionSprite         = "shaders/definitions/sprite.json"
+	ShaderDefinitionPBR            = "shaders/definitions/pbr.json"
+	ShaderDefinitionSkinned        = "shaders/definitions/basic_skinned.json"
+	ShaderDefinitionMorph          = "shaders/definitions/basic_morph.json"
+)
+
+// Post process shader definitions
+const (
+	ShaderDefinitionPostTonemap             = "shaders/definitions/post_tonemap.json"
+	ShaderDefinitionPostBloomBright         = "shaders/definitions/post_bloom_bright.json"
+	ShaderDefinitionPostBlur                = "shaders/definitions/post_blur.json"
+	ShaderDefinitionPostBloomCombine        = "shaders/definitions/post_bloom_combine.json"
+	ShaderDefinitionPostFXAA                = "shaders/definitions/post_fxaa.json"
+	ShaderDefinitionPostColorGrading        = "shaders/definitions/post_color_grading.json"
+	ShaderDefinitionPostVignette            = "shaders/definitions/post_vignette.json"
+	ShaderDefinitionPostChromaticAberration = "shaders/definitions/post_chromatic_aberration.json"
 )
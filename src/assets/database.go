@@ -59,6 +59,11 @@ func (a *Database) Read(key string) ([]byte, error) {
 	return filesystem.ReadFile(key)
 }
 
+// Path is where the file for the key is on disk
+func (a *Database) Path(key string) string {
+	return filepath.Join("content", key)
+}
+
 func (a *Database) Exists(key string) bool {
 	key = filepath.Join("content", key)
 	return filesystem.FileExists(key)
@@ -68,6 +68,7 @@ type Camera interface {
 	Height() float32
 	View() matrix.Mat4
 	Projection() matrix.Mat4
+	Frustum() collision.Frustum
 	Center() matrix.Vec3
 	Yaw() float32
 	Pitch() float32
@@ -120,6 +120,7 @@ func (c *StandardCamera) internalUpdateProjection() {
 	}
 	c.iProjection = c.projection
 	c.iProjection.Inverse()
+	c.updateFrustum()
 }
 
 func (c *StandardCamera) internalUpdateView() {
@@ -323,14 +324,15 @@ func (c *StandardCamera) ForwardPlaneHit(screenPos matrix.Vec2, planePos matrix.
 	return c.TryPlaneHit(screenPos, planePos, fwd)
 }
 
-func (c *StandardCamera) Position() matrix.Vec3   { return c.position }
-func (c *StandardCamera) Width() float32          { return c.width }
-func (c *StandardCamera) Height() float32         { return c.height }
-func (c *StandardCamera) View() matrix.Mat4       { return c.view }
-func (c *StandardCamera) Projection() matrix.Mat4 { return c.projection }
-func (c *StandardCamera) Center() matrix.Vec3     { return c.lookAt }
-func (c *StandardCamera) Yaw() float32            { return c.yaw }
-func (c *StandardCamera) Pitch() float32          { return c.pitch }
-func (c *StandardCamera) NearPlane() float32      { return c.nearPlane }
-func (c *StandardCamera) FarPlane() float32       { return c.farPlane }
-func (c *StandardCamera) Zoom() float32           { return c.zoom }
+func (c *StandardCamera) Position() matrix.Vec3      { return c.position }
+func (c *StandardCamera) Width() float32             { return c.width }
+func (c *StandardCamera) Height() float32            { return c.height }
+func (c *StandardCamera) View() matrix.Mat4          { return c.view }
+func (c *StandardCamera) Projection() matrix.Mat4    { return c.projection }
+func (c *StandardCamera) Frustum() collision.Frustum { return c.frustum }
+func (c *StandardCamera) Center() matrix.Vec3        { return c.lookAt }
+func (c *StandardCamera) Yaw() float32               { return c.yaw }
+func (c *StandardCamera) Pitch() float32             { return c.pitch }
+func (c *StandardCamera) NearPlane() float32         { return c.nearPlane }
+func (c *StandardCamera) FarPlane() float32          { return c.farPlane }
+func (c *StandardCamera) Zoom() float32              { return c.zoom }
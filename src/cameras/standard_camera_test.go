@@ -0,0 +1,72 @@
+/*****************************************************************************/
+/* standard_camera_test.go                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package cameras
+
+import (
+	"kaiju/collision"
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestStandardCameraFrustum(t *testing.T) {
+	c := NewStandardCamera(800, 600, matrix.Vec3{0, 0, 5})
+	c.SetPositionAndLookAt(matrix.Vec3{0, 0, 5}, matrix.Vec3Zero())
+	f := c.Frustum()
+	unit := matrix.Vec3{0.5, 0.5, 0.5}
+	box := func(center matrix.Vec3) collision.AABB {
+		return collision.AABB{Min: center.Subtract(unit), Max: center.Add(unit)}
+	}
+	if !f.ContainsPoint(matrix.Vec3Zero()) {
+		t.Error("the point being looked at should be in the frustum")
+	}
+	if !f.IntersectsAABB(box(matrix.Vec3Zero())) {
+		t.Error("the box being looked at should be in the frustum")
+	}
+	if !f.IntersectsAABB(box(matrix.Vec3{2, 1, 0})) {
+		t.Error("a box off center but in view should be in the frustum")
+	}
+	if f.IntersectsAABB(box(matrix.Vec3{0, 0, 10})) {
+		t.Error("a box behind the camera should be culled")
+	}
+	if f.IntersectsAABB(box(matrix.Vec3{100, 0, 0})) {
+		t.Error("a box far to the side should be culled")
+	}
+	if f.IntersectsAABB(box(matrix.Vec3{0, 0, -1000})) {
+		t.Error("a box past the far plane should be culled")
+	}
+}
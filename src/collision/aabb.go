@@ -0,0 +1,149 @@
+/*****************************************************************************/
+/* aabb.go                                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import "kaiju/matrix"
+
+type AABB struct {
+	Min matrix.Vec3
+	Max matrix.Vec3
+}
+
+func AABBFromPoints(points ...matrix.Vec3) AABB {
+	if len(points) == 0 {
+		return AABB{}
+	}
+	b := AABB{Min: points[0], Max: points[0]}
+	for _, p := range points[1:] {
+		b.ExpandToPoint(p)
+	}
+	return b
+}
+
+func AABBUnion(a, b AABB) AABB {
+	return AABB{
+		Min: matrix.Vec3Min(a.Min, b.Min),
+		Max: matrix.Vec3Max(a.Max, b.Max),
+	}
+}
+
+func (b AABB) Center() matrix.Vec3 {
+	return b.Min.Add(b.Max).Scale(0.5)
+}
+
+func (b AABB) Extent() matrix.Vec3 {
+	return b.Max.Subtract(b.Min).Scale(0.5)
+}
+
+func (b AABB) Size() matrix.Vec3 {
+	return b.Max.Subtract(b.Min)
+}
+
+func (b *AABB) ExpandToPoint(point matrix.Vec3) {
+	b.Min = matrix.Vec3Min(b.Min, point)
+	b.Max = matrix.Vec3Max(b.Max, point)
+}
+
+func (b AABB) Grow(amount float32) AABB {
+	g := matrix.Vec3{amount, amount, amount}
+	return AABB{Min: b.Min.Subtract(g), Max: b.Max.Add(g)}
+}
+
+func (b AABB) Overlaps(other AABB) bool {
+	return b.Min.X() <= other.Max.X() && b.Max.X() >= other.Min.X() &&
+		b.Min.Y() <= other.Max.Y() && b.Max.Y() >= other.Min.Y() &&
+		b.Min.Z() <= other.Max.Z() && b.Max.Z() >= other.Min.Z()
+}
+
+func (b AABB) Contains(point matrix.Vec3) bool {
+	return point.X() >= b.Min.X() && point.X() <= b.Max.X() &&
+		point.Y() >= b.Min.Y() && point.Y() <= b.Max.Y() &&
+		point.Z() >= b.Min.Z() && point.Z() <= b.Max.Z()
+}
+
+func (b AABB) ClosestPoint(point matrix.Vec3) matrix.Vec3 {
+	return matrix.Vec3Min(matrix.Vec3Max(point, b.Min), b.Max)
+}
+
+// Transform returns the bounds that fully enclose this box after it has been
+// transformed by the supplied matrix
+func (b AABB) Transform(m matrix.Mat4) AABB {
+	c := b.Center()
+	e := b.Extent()
+	corners := [8]matrix.Vec3{
+		{c.X() - e.X(), c.Y() - e.Y(), c.Z() - e.Z()},
+		{c.X() + e.X(), c.Y() - e.Y(), c.Z() - e.Z()},
+		{c.X() - e.X(), c.Y() + e.Y(), c.Z() - e.Z()},
+		{c.X() + e.X(), c.Y() + e.Y(), c.Z() - e.Z()},
+		{c.X() - e.X(), c.Y() - e.Y(), c.Z() + e.Z()},
+		{c.X() + e.X(), c.Y() - e.Y(), c.Z() + e.Z()},
+		{c.X() - e.X(), c.Y() + e.Y(), c.Z() + e.Z()},
+		{c.X() + e.X(), c.Y() + e.Y(), c.Z() + e.Z()},
+	}
+	for i := range corners {
+		corners[i] = m.TransformPoint(corners[i])
+	}
+	return AABBFromPoints(corners[:]...)
+}
+
+// RayHit uses the slab method to find the distance along the ray where it
+// enters the box, the ray direction does not need to be normalized
+func (b AABB) RayHit(ray Ray) (float32, bool) {
+	tMin := float32(0)
+	tMax := float32(matrix.FloatMax)
+	for i := 0; i < 3; i++ {
+		if matrix.Abs(ray.Direction[i]) < matrix.FloatSmallestNonzero {
+			if ray.Origin[i] < b.Min[i] || ray.Origin[i] > b.Max[i] {
+				return 0, false
+			}
+			continue
+		}
+		inv := 1.0 / ray.Direction[i]
+		t1 := (b.Min[i] - ray.Origin[i]) * inv
+		t2 := (b.Max[i] - ray.Origin[i]) * inv
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = max(tMin, t1)
+		tMax = min(tMax, t2)
+		if tMin > tMax {
+			return 0, false
+		}
+	}
+	return tMin, true
+}
@@ -0,0 +1,195 @@
+/*****************************************************************************/
+/* bvh.go                                                                    */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import (
+	"kaiju/matrix"
+	"slices"
+)
+
+const bvhLeafSize = 4
+
+type bvhNode struct {
+	bounds AABB
+	// left and right are node indices for branches, leaves have a left of
+	// -1 and use start/count to reference a range of the triangle order
+	left, right  int32
+	start, count int32
+}
+
+// BVH is a bounding volume hierarchy over a set of triangles that is built
+// once and then queried, it is meant for static mesh data in local space
+type BVH struct {
+	Triangles []Triangle
+	order     []int32
+	nodes     []bvhNode
+}
+
+type BVHHit struct {
+	Point       matrix.Vec3
+	Barycentric matrix.Vec3
+	Distance    float32
+	Triangle    int
+}
+
+func NewBVH(triangles []Triangle) *BVH {
+	b := &BVH{
+		Triangles: triangles,
+		order:     make([]int32, len(triangles)),
+		nodes:     make([]bvhNode, 0, max(1, len(triangles)/bvhLeafSize*2)),
+	}
+	for i := range b.order {
+		b.order[i] = int32(i)
+	}
+	if len(triangles) > 0 {
+		b.build(0, int32(len(triangles)))
+	}
+	return b
+}
+
+// Bounds returns the bounds of all of the triangles in the hierarchy
+func (b *BVH) Bounds() AABB {
+	if len(b.nodes) == 0 {
+		return AABB{}
+	}
+	return b.nodes[0].bounds
+}
+
+func (b *BVH) build(start, count int32) int32 {
+	idx := int32(len(b.nodes))
+	b.nodes = append(b.nodes, bvhNode{left: -1, start: start, count: count})
+	tris := b.order[start : start+count]
+	bounds := b.Triangles[tris[0]].Bounds()
+	centers := AABBFromPoints(b.Triangles[tris[0]].Centroid())
+	for _, t := range tris[1:] {
+		bounds = AABBUnion(bounds, b.Triangles[t].Bounds())
+		centers.ExpandToPoint(b.Triangles[t].Centroid())
+	}
+	b.nodes[idx].bounds = bounds
+	if count <= bvhLeafSize {
+		return idx
+	}
+	size := centers.Size()
+	axis := 0
+	if size.Y() > size[axis] {
+		axis = 1
+	}
+	if size.Z() > size[axis] {
+		axis = 2
+	}
+	if size[axis] <= 0 {
+		// Every centroid is in the same spot, splitting won't help
+		return idx
+	}
+	slices.SortFunc(tris, func(a, c int32) int {
+		ca := b.Triangles[a].Centroid()[axis]
+		cc := b.Triangles[c].Centroid()[axis]
+		if ca < cc {
+			return -1
+		} else if ca > cc {
+			return 1
+		}
+		return 0
+	})
+	half := count / 2
+	left := b.build(start, half)
+	right := b.build(start+half, count-half)
+	b.nodes[idx].left = left
+	b.nodes[idx].right = right
+	return idx
+}
+
+// RayHit finds the closest triangle along the ray, distance is measured in
+// lengths of the ray direction
+func (b *BVH) RayHit(ray Ray, maxLen float32) (BVHHit, bool) {
+	hit := BVHHit{Distance: maxLen, Triangle: -1}
+	if len(b.nodes) == 0 {
+		return hit, false
+	}
+	stack := make([]int32, 0, 64)
+	stack = append(stack, 0)
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if d, ok := node.bounds.RayHit(ray); !ok || d > hit.Distance {
+			continue
+		}
+		if node.left >= 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		for _, t := range b.order[node.start : node.start+node.count] {
+			if d, bary, ok := b.Triangles[t].RayHit(ray, hit.Distance); ok {
+				hit.Distance = d
+				hit.Barycentric = bary
+				hit.Triangle = int(t)
+			}
+		}
+	}
+	if hit.Triangle < 0 {
+		return hit, false
+	}
+	hit.Point = ray.Point(hit.Distance)
+	return hit, true
+}
+
+// Overlapping calls fn with the index of every triangle whose bounds
+// overlap the supplied bounds
+func (b *BVH) Overlapping(bounds AABB, fn func(triangle int)) {
+	if len(b.nodes) == 0 {
+		return
+	}
+	stack := make([]int32, 0, 64)
+	stack = append(stack, 0)
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !node.bounds.Overlaps(bounds) {
+			continue
+		}
+		if node.left >= 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		for _, t := range b.order[node.start : node.start+node.count] {
+			if b.Triangles[t].Bounds().Overlaps(bounds) {
+				fn(int(t))
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*****************************************************************************/
+/* bvh_test.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import (
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestBVHRayHit(t *testing.T) {
+	// A row of quads at increasing depth, each made of two triangles
+	tris := make([]Triangle, 0)
+	for i := 0; i < 16; i++ {
+		z := float32(-i)
+		x := float32(i * 3)
+		tris = append(tris,
+			NewTriangle(matrix.Vec3{x - 1, -1, z}, matrix.Vec3{x + 1, -1, z}, matrix.Vec3{x + 1, 1, z}),
+			NewTriangle(matrix.Vec3{x - 1, -1, z}, matrix.Vec3{x + 1, 1, z}, matrix.Vec3{x - 1, 1, z}))
+	}
+	bvh := NewBVH(tris)
+	ray := Ray{Origin: matrix.Vec3{15.5, -0.5, 10}, Direction: matrix.Vec3{0, 0, -1}}
+	hit, ok := bvh.RayHit(ray, 100)
+	if !ok {
+		t.Fatal("expected the ray to hit the quad at x = 15")
+	}
+	if hit.Triangle != 10 {
+		t.Errorf("expected triangle 10, got %d", hit.Triangle)
+	}
+	if !matrix.ApproxTo(hit.Distance, 15, 0.001) {
+		t.Errorf("expected a distance of 15, got %f", hit.Distance)
+	}
+	p := tris[hit.Triangle].P
+	point := p[0].Scale(hit.Barycentric[0]).Add(p[1].Scale(hit.Barycentric[1])).Add(p[2].Scale(hit.Barycentric[2]))
+	if !matrix.Vec3ApproxTo(point, hit.Point, 0.001) {
+		t.Errorf("barycentric point %v does not match the hit point %v", point, hit.Point)
+	}
+	if _, ok = bvh.RayHit(ray, 10); ok {
+		t.Error("the hit is further than the max length")
+	}
+	ray.Origin = matrix.Vec3{1.5, 0, 10}
+	if _, ok = bvh.RayHit(ray, 100); ok {
+		t.Error("the ray passes between the quads")
+	}
+}
+
+func TestBVHOverlapping(t *testing.T) {
+	tris := make([]Triangle, 0)
+	for i := 0; i < 32; i++ {
+		x := float32(i * 2)
+		tris = append(tris, NewTriangle(matrix.Vec3{x, 0, 0},
+			matrix.Vec3{x + 1, 0, 0}, matrix.Vec3{x, 1, 0}))
+	}
+	bvh := NewBVH(tris)
+	found := make(map[int]bool)
+	bvh.Overlapping(AABBFromPoints(matrix.Vec3{9.5, 0.5, -1}, matrix.Vec3{14.5, 0.5, 1}), func(tri int) {
+		found[tri] = true
+	})
+	if len(found) != 3 || !found[5] || !found[6] || !found[7] {
+		t.Errorf("expected triangles 5, 6 and 7, got %v", found)
+	}
+}
@@ -0,0 +1,172 @@
+/*****************************************************************************/
+/* capsule.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import "kaiju/matrix"
+
+const (
+	sweepMaxIterations = 32
+	sweepTolerance     = 0.0001
+)
+
+// Capsule is a swept sphere along the segment between A and B, a capsule
+// with matching A and B points is a sphere
+type Capsule struct {
+	A      matrix.Vec3
+	B      matrix.Vec3
+	Radius float32
+}
+
+// SweepHit describes the first contact of a shape moving along a motion
+// vector. Time is the fraction [0, 1] of the motion where contact happens
+// and Normal points away from the contact point. SurfaceNormal is the face
+// normal of what was hit, which differs from Normal when hitting an edge.
+// Depth is only set when the shape was already penetrating at the start.
+type SweepHit struct {
+	Point         matrix.Vec3
+	Normal        matrix.Vec3
+	SurfaceNormal matrix.Vec3
+	Time          float32
+	Depth         float32
+}
+
+func faceNormalTowards(tri Triangle, normal matrix.Vec3) matrix.Vec3 {
+	if matrix.Vec3Dot(tri.Normal, normal) < 0 {
+		return tri.Normal.Negative()
+	}
+	return tri.Normal
+}
+
+func (c Capsule) Segment() Segment {
+	return Segment{c.A, c.B}
+}
+
+func (c Capsule) Bounds() AABB {
+	return AABBFromPoints(c.A, c.B).Grow(c.Radius)
+}
+
+func (c Capsule) Translate(offset matrix.Vec3) Capsule {
+	return Capsule{c.A.Add(offset), c.B.Add(offset), c.Radius}
+}
+
+// SweptBounds returns the bounds that enclose the capsule for the entire
+// length of the motion
+func (c Capsule) SweptBounds(motion matrix.Vec3) AABB {
+	return AABBUnion(c.Bounds(), c.Translate(motion).Bounds())
+}
+
+// TrianglePenetration returns the depth and direction the capsule needs
+// to be pushed along to no longer overlap the triangle
+func (c Capsule) TrianglePenetration(tri Triangle) (float32, matrix.Vec3, bool) {
+	onSeg, onTri := c.Segment().ClosestTrianglePoints(tri)
+	delta := onSeg.Subtract(onTri)
+	dist := delta.Length()
+	if dist >= c.Radius {
+		return 0, matrix.Vec3{}, false
+	}
+	normal := tri.Normal
+	if dist > matrix.FloatSmallestNonzero {
+		normal = delta.Shrink(dist)
+	} else if matrix.Vec3Dot(normal, c.Segment().ClosestPoint(tri.Centroid()).Subtract(tri.Centroid())) < 0 {
+		normal = normal.Negative()
+	}
+	return c.Radius - dist, normal, true
+}
+
+// SweepTriangle moves the capsule along the motion vector and reports the
+// first time it touches the triangle. This uses conservative advancement,
+// since the distance between two convex shapes under translation is a
+// convex function of time, stepping along its tangent never overshoots.
+func (c Capsule) SweepTriangle(tri Triangle, motion matrix.Vec3) (SweepHit, bool) {
+	if depth, normal, ok := c.TrianglePenetration(tri); ok {
+		// Already overlapping, only block motion that digs further in
+		if matrix.Vec3Dot(motion, normal) >= 0 {
+			return SweepHit{}, false
+		}
+		_, onTri := c.Segment().ClosestTrianglePoints(tri)
+		return SweepHit{
+			Point:         onTri,
+			Normal:        normal,
+			SurfaceNormal: faceNormalTowards(tri, normal),
+			Depth:         depth,
+		}, true
+	}
+	t := float32(0)
+	hit := SweepHit{}
+	for i := 0; i < sweepMaxIterations; i++ {
+		seg := Segment{c.A.Add(motion.Scale(t)), c.B.Add(motion.Scale(t))}
+		onSeg, onTri := seg.ClosestTrianglePoints(tri)
+		delta := onSeg.Subtract(onTri)
+		length := delta.Length()
+		dist := length - c.Radius
+		normal := tri.Normal
+		if length > matrix.FloatSmallestNonzero {
+			normal = delta.Shrink(length)
+		}
+		hit = SweepHit{
+			Point:         onTri,
+			Normal:        normal,
+			SurfaceNormal: faceNormalTowards(tri, normal),
+			Time:          t,
+		}
+		if dist <= sweepTolerance {
+			return hit, true
+		}
+		closing := -matrix.Vec3Dot(motion, normal)
+		if closing <= matrix.FloatSmallestNonzero {
+			return SweepHit{}, false
+		}
+		next := t + dist/closing
+		if next > 1 {
+			return SweepHit{}, false
+		}
+		t = next
+	}
+	// Advancement is still closing in after all of the iterations, every
+	// step so far was safe so report contact at the last one rather than
+	// letting the capsule tunnel through
+	hit.Time = t
+	return hit, true
+}
+
+func (c Capsule) Overlaps(other Capsule) bool {
+	a, b := c.Segment().ClosestPoints(other.Segment())
+	r := c.Radius + other.Radius
+	d := b.Subtract(a)
+	return matrix.Vec3Dot(d, d) <= r*r
+}
@@ -0,0 +1,81 @@
+/*****************************************************************************/
+/* capsule_test.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import (
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestCapsuleSweepTriangle(t *testing.T) {
+	floor := NewTriangle(matrix.Vec3{-10, 0, -10},
+		matrix.Vec3{-10, 0, 10}, matrix.Vec3{10, 0, 0})
+	c := Capsule{A: matrix.Vec3{0, 1.5, 0}, B: matrix.Vec3{0, 2.5, 0}, Radius: 0.5}
+	hit, ok := c.SweepTriangle(floor, matrix.Vec3{0, -2, 0})
+	if !ok {
+		t.Fatal("expected the capsule to hit the floor")
+	}
+	if !matrix.ApproxTo(hit.Time, 0.5, 0.001) {
+		t.Errorf("expected a hit at half of the motion, got %f", hit.Time)
+	}
+	if !matrix.Vec3ApproxTo(hit.Normal, matrix.Vec3Up(), 0.001) {
+		t.Errorf("expected an up facing normal, got %v", hit.Normal)
+	}
+	if _, ok = c.SweepTriangle(floor, matrix.Vec3{0, 2, 0}); ok {
+		t.Error("moving away from the floor should not hit")
+	}
+	if _, ok = c.SweepTriangle(floor, matrix.Vec3{0, -0.5, 0}); ok {
+		t.Error("motion that stops short of the floor should not hit")
+	}
+}
+
+func TestCapsuleTrianglePenetration(t *testing.T) {
+	floor := NewTriangle(matrix.Vec3{-10, 0, -10},
+		matrix.Vec3{-10, 0, 10}, matrix.Vec3{10, 0, 0})
+	c := Capsule{A: matrix.Vec3{0, 0.25, 0}, B: matrix.Vec3{0, 1, 0}, Radius: 0.5}
+	depth, normal, ok := c.TrianglePenetration(floor)
+	if !ok {
+		t.Fatal("expected the capsule to overlap the floor")
+	}
+	if !matrix.ApproxTo(depth, 0.25, 0.001) {
+		t.Errorf("expected a depth of 0.25, got %f", depth)
+	}
+	if !matrix.Vec3ApproxTo(normal, matrix.Vec3Up(), 0.001) {
+		t.Errorf("expected an up facing normal, got %v", normal)
+	}
+}
@@ -37,6 +37,40 @@
 
 package collision
 
+import "kaiju/matrix"
+
 type Frustum struct {
 	Planes [6]Plane
 }
+
+// ContainsPoint reports if the point is on the inner side of every plane,
+// the planes are not required to be normalized
+func (f Frustum) ContainsPoint(point matrix.Vec3) bool {
+	for i := range f.Planes {
+		p := &f.Planes[i]
+		if matrix.Vec3Dot(p.Normal, point)+p.Dot < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB is a conservative test that only rejects the box when it
+// is fully outside of one of the planes, boxes near the corners of the
+// frustum may be reported as intersecting when they are not
+func (f Frustum) IntersectsAABB(box AABB) bool {
+	for i := range f.Planes {
+		p := &f.Planes[i]
+		// The corner of the box furthest along the plane normal
+		v := box.Min
+		for j := 0; j < 3; j++ {
+			if p.Normal[j] >= 0 {
+				v[j] = box.Max[j]
+			}
+		}
+		if matrix.Vec3Dot(p.Normal, v)+p.Dot < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+/*****************************************************************************/
+/* obb.go                                                                    */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import "kaiju/matrix"
+
+const obbCapsuleIterations = 32
+
+// OBB is an oriented bounding box, the axes are expected to be normalized
+// and orthogonal to each other with Extent being the half size along each
+type OBB struct {
+	Center matrix.Vec3
+	Extent matrix.Vec3
+	Axes   [3]matrix.Vec3
+}
+
+// OBBFromAABB creates the box that results from transforming the local
+// space bounds by the matrix, scale is moved from the axes into the extent
+func OBBFromAABB(b AABB, m matrix.Mat4) OBB {
+	origin := m.TransformPoint(matrix.Vec3Zero())
+	o := OBB{Center: m.TransformPoint(b.Center())}
+	e := b.Extent()
+	for i := 0; i < 3; i++ {
+		unit := matrix.Vec3Zero()
+		unit[i] = 1
+		axis := m.TransformPoint(unit).Subtract(origin)
+		length := axis.Length()
+		if length > 0 {
+			o.Axes[i] = axis.Shrink(length)
+		} else {
+			o.Axes[i] = unit
+		}
+		o.Extent[i] = e[i] * length
+	}
+	return o
+}
+
+func (o OBB) Bounds() AABB {
+	var r matrix.Vec3
+	for i := 0; i < 3; i++ {
+		r.AddAssign(o.Axes[i].Abs().Scale(o.Extent[i]))
+	}
+	return AABB{Min: o.Center.Subtract(r), Max: o.Center.Add(r)}
+}
+
+func (o OBB) ClosestPoint(point matrix.Vec3) matrix.Vec3 {
+	d := point.Subtract(o.Center)
+	out := o.Center
+	for i := 0; i < 3; i++ {
+		dist := matrix.Clamp(matrix.Vec3Dot(d, o.Axes[i]), -o.Extent[i], o.Extent[i])
+		out.AddAssign(o.Axes[i].Scale(dist))
+	}
+	return out
+}
+
+func (o OBB) Contains(point matrix.Vec3) bool {
+	d := point.Subtract(o.Center)
+	for i := 0; i < 3; i++ {
+		if matrix.Abs(matrix.Vec3Dot(d, o.Axes[i])) > o.Extent[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (o OBB) distanceSq(point matrix.Vec3) float32 {
+	d := point.Subtract(o.ClosestPoint(point))
+	return matrix.Vec3Dot(d, d)
+}
+
+func (o OBB) OverlapsSphere(s Sphere) bool {
+	return o.distanceSq(s.Center) <= s.Radius*s.Radius
+}
+
+// OverlapsCapsule finds the point on the capsule segment closest to the box,
+// the distance to a convex shape is convex along the segment so a ternary
+// search is enough to find it
+func (o OBB) OverlapsCapsule(c Capsule) bool {
+	r2 := c.Radius * c.Radius
+	seg := c.Segment()
+	at := func(t float32) float32 {
+		return o.distanceSq(seg.A.Add(seg.B.Subtract(seg.A).Scale(t)))
+	}
+	lo, hi := float32(0), float32(1)
+	for i := 0; i < obbCapsuleIterations; i++ {
+		m1 := lo + (hi-lo)/3
+		m2 := hi - (hi-lo)/3
+		if at(m1) < at(m2) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	return at((lo+hi)*0.5) <= r2 || at(0) <= r2 || at(1) <= r2
+}
+
+// Overlaps uses the separating axis test with the 15 potential axes of the
+// two boxes
+func (o OBB) Overlaps(other OBB) bool {
+	const parallel = 0.000001
+	var r, absR [3][3]float32
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = matrix.Vec3Dot(o.Axes[i], other.Axes[j])
+			absR[i][j] = matrix.Abs(r[i][j]) + parallel
+		}
+	}
+	d := other.Center.Subtract(o.Center)
+	t := matrix.Vec3{
+		matrix.Vec3Dot(d, o.Axes[0]),
+		matrix.Vec3Dot(d, o.Axes[1]),
+		matrix.Vec3Dot(d, o.Axes[2]),
+	}
+	a, b := o.Extent, other.Extent
+	for i := 0; i < 3; i++ {
+		rb := b[0]*absR[i][0] + b[1]*absR[i][1] + b[2]*absR[i][2]
+		if matrix.Abs(t[i]) > a[i]+rb {
+			return false
+		}
+	}
+	for j := 0; j < 3; j++ {
+		ra := a[0]*absR[0][j] + a[1]*absR[1][j] + a[2]*absR[2][j]
+		if matrix.Abs(t[0]*r[0][j]+t[1]*r[1][j]+t[2]*r[2][j]) > ra+b[j] {
+			return false
+		}
+	}
+	for i := 0; i < 3; i++ {
+		i1, i2 := (i+1)%3, (i+2)%3
+		for j := 0; j < 3; j++ {
+			j1, j2 := (j+1)%3, (j+2)%3
+			ra := a[i1]*absR[i2][j] + a[i2]*absR[i1][j]
+			rb := b[j1]*absR[i][j2] + b[j2]*absR[i][j1]
+			if matrix.Abs(t[i2]*r[i1][j]-t[i1]*r[i2][j]) > ra+rb {
+				return false
+			}
+		}
+	}
+	return true
+}
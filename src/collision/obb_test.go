@@ -0,0 +1,81 @@
+/*****************************************************************************/
+/* obb_test.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import (
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestOBBOverlaps(t *testing.T) {
+	m := matrix.Mat4Identity()
+	m.Scale(matrix.Vec3{2, 2, 2})
+	m.Translate(matrix.Vec3{1, 1, 1})
+	a := OBBFromAABB(AABB{Min: matrix.Vec3{-1, -1, -1}, Max: matrix.Vec3{0, 0, 0}}, m)
+	if !matrix.Vec3ApproxTo(a.Center, matrix.Vec3Zero(), 0.001) ||
+		!matrix.Vec3ApproxTo(a.Extent, matrix.Vec3One(), 0.001) {
+		t.Fatalf("expected a unit box at the origin, got %v %v", a.Center, a.Extent)
+	}
+	diag := matrix.Vec3{1, 0, 1}.Normal()
+	rotated := OBB{
+		Extent: matrix.Vec3One(),
+		Axes:   [3]matrix.Vec3{diag, matrix.Vec3Up(), {-diag.Z(), 0, diag.X()}},
+	}
+	rotated.Center = matrix.Vec3{2.3, 0, 0}
+	if !a.Overlaps(rotated) {
+		t.Error("the rotated corner should reach into the box")
+	}
+	rotated.Center = matrix.Vec3{2.5, 0, 0}
+	if a.Overlaps(rotated) {
+		t.Error("the rotated corner should stop short of the box")
+	}
+	if !a.OverlapsSphere(Sphere{Center: matrix.Vec3{1.5, 0, 0}, Radius: 0.6}) {
+		t.Error("expected the sphere to overlap the box")
+	}
+	if a.OverlapsSphere(Sphere{Center: matrix.Vec3{1.5, 1.5, 0}, Radius: 0.6}) {
+		t.Error("the sphere near the edge should not overlap the box")
+	}
+	c := Capsule{A: matrix.Vec3{-5, 1.4, 0}, B: matrix.Vec3{5, 1.4, 0}, Radius: 0.5}
+	if !a.OverlapsCapsule(c) {
+		t.Error("expected the capsule to overlap the box")
+	}
+	c = c.Translate(matrix.Vec3{0, 0.2, 0})
+	if a.OverlapsCapsule(c) {
+		t.Error("the raised capsule should not overlap the box")
+	}
+}
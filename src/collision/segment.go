@@ -77,3 +77,80 @@ func (l Segment) TriangleHit(a, b, c matrix.Vec3) bool {
 	}
 	return true
 }
+
+func (l Segment) ClosestPoint(point matrix.Vec3) matrix.Vec3 {
+	ab := l.B.Subtract(l.A)
+	lenSq := matrix.Vec3Dot(ab, ab)
+	if lenSq < matrix.FloatSmallestNonzero {
+		return l.A
+	}
+	t := matrix.Clamp(matrix.Vec3Dot(point.Subtract(l.A), ab)/lenSq, 0, 1)
+	return l.A.Add(ab.Scale(t))
+}
+
+// ClosestPoints returns the closest point on this segment and the closest
+// point on the other segment, see Real-Time Collision Detection 5.1.9
+func (l Segment) ClosestPoints(other Segment) (matrix.Vec3, matrix.Vec3) {
+	const epsilon = 1e-9
+	d1 := l.B.Subtract(l.A)
+	d2 := other.B.Subtract(other.A)
+	r := l.A.Subtract(other.A)
+	a := matrix.Vec3Dot(d1, d1)
+	e := matrix.Vec3Dot(d2, d2)
+	f := matrix.Vec3Dot(d2, r)
+	var s, t float32
+	if a <= epsilon && e <= epsilon {
+		return l.A, other.A
+	}
+	if a <= epsilon {
+		t = matrix.Clamp(f/e, 0, 1)
+	} else {
+		c := matrix.Vec3Dot(d1, r)
+		if e <= epsilon {
+			s = matrix.Clamp(-c/a, 0, 1)
+		} else {
+			b := matrix.Vec3Dot(d1, d2)
+			denom := a*e - b*b
+			if denom != 0 {
+				s = matrix.Clamp((b*f-c*e)/denom, 0, 1)
+			}
+			t = (b*s + f) / e
+			if t < 0 {
+				t = 0
+				s = matrix.Clamp(-c/a, 0, 1)
+			} else if t > 1 {
+				t = 1
+				s = matrix.Clamp((b-c)/a, 0, 1)
+			}
+		}
+	}
+	return l.A.Add(d1.Scale(s)), other.A.Add(d2.Scale(t))
+}
+
+// ClosestTrianglePoints returns the closest point on the segment and the
+// closest point on the triangle, if the segment pierces the triangle both
+// points will be the point of intersection
+func (l Segment) ClosestTrianglePoints(tri Triangle) (matrix.Vec3, matrix.Vec3) {
+	dir := l.B.Subtract(l.A)
+	if length := dir.Length(); length > matrix.FloatSmallestNonzero {
+		ray := Ray{Origin: l.A, Direction: dir.Shrink(length)}
+		if dist, _, ok := tri.RayHit(ray, length); ok {
+			p := ray.Point(dist)
+			return p, p
+		}
+	}
+	bestSeg := l.A
+	bestTri := tri.ClosestPoint(l.A)
+	bestDist := bestSeg.SquareDistance(bestTri)
+	test := func(s, t matrix.Vec3) {
+		if d := s.SquareDistance(t); d < bestDist {
+			bestSeg, bestTri, bestDist = s, t, d
+		}
+	}
+	test(l.B, tri.ClosestPoint(l.B))
+	for i := 0; i < 3; i++ {
+		edge := Segment{tri.P[i], tri.P[(i+1)%3]}
+		test(l.ClosestPoints(edge))
+	}
+	return bestSeg, bestTri
+}
@@ -0,0 +1,64 @@
+/*****************************************************************************/
+/* sphere.go                                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import "kaiju/matrix"
+
+type Sphere struct {
+	Center matrix.Vec3
+	Radius float32
+}
+
+func (s Sphere) Bounds() AABB {
+	r := matrix.Vec3{s.Radius, s.Radius, s.Radius}
+	return AABB{Min: s.Center.Subtract(r), Max: s.Center.Add(r)}
+}
+
+func (s Sphere) Contains(point matrix.Vec3) bool {
+	return matrix.Vec3Dot(point.Subtract(s.Center), point.Subtract(s.Center)) <= s.Radius*s.Radius
+}
+
+func (s Sphere) Overlaps(other Sphere) bool {
+	r := s.Radius + other.Radius
+	d := other.Center.Subtract(s.Center)
+	return matrix.Vec3Dot(d, d) <= r*r
+}
+
+func (s Sphere) OverlapsCapsule(capsule Capsule) bool {
+	return capsule.Overlaps(Capsule{A: s.Center, B: s.Center, Radius: s.Radius})
+}
@@ -0,0 +1,167 @@
+/*****************************************************************************/
+/* triangle.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package collision
+
+import "kaiju/matrix"
+
+type Triangle struct {
+	P      [3]matrix.Vec3
+	Normal matrix.Vec3
+}
+
+// NewTriangle creates a triangle with a counter-clockwise winding, the normal
+// of a degenerate (zero area) triangle will be zero
+func NewTriangle(a, b, c matrix.Vec3) Triangle {
+	t := Triangle{P: [3]matrix.Vec3{a, b, c}}
+	cross := matrix.Vec3Cross(b.Subtract(a), c.Subtract(a))
+	if length := cross.Length(); length > matrix.FloatSmallestNonzero {
+		t.Normal = cross.Shrink(length)
+	}
+	return t
+}
+
+func (t Triangle) IsDegenerate() bool {
+	return t.Normal.Equals(matrix.Vec3Zero())
+}
+
+func (t Triangle) Bounds() AABB {
+	return AABBFromPoints(t.P[0], t.P[1], t.P[2])
+}
+
+func (t Triangle) Centroid() matrix.Vec3 {
+	return t.P[0].Add(t.P[1]).Add(t.P[2]).Scale(1.0 / 3.0)
+}
+
+func (t Triangle) Transform(m matrix.Mat4) Triangle {
+	return NewTriangle(m.TransformPoint(t.P[0]),
+		m.TransformPoint(t.P[1]), m.TransformPoint(t.P[2]))
+}
+
+// ClosestPoint returns the point on (or within) the triangle that is closest
+// to the given point, see Real-Time Collision Detection 5.1.5
+func (t Triangle) ClosestPoint(point matrix.Vec3) matrix.Vec3 {
+	a, b, c := t.P[0], t.P[1], t.P[2]
+	ab := b.Subtract(a)
+	ac := c.Subtract(a)
+	ap := point.Subtract(a)
+	d1 := matrix.Vec3Dot(ab, ap)
+	d2 := matrix.Vec3Dot(ac, ap)
+	if d1 <= 0 && d2 <= 0 {
+		return a
+	}
+	bp := point.Subtract(b)
+	d3 := matrix.Vec3Dot(ab, bp)
+	d4 := matrix.Vec3Dot(ac, bp)
+	if d3 >= 0 && d4 <= d3 {
+		return b
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		v := d1 / (d1 - d3)
+		return a.Add(ab.Scale(v))
+	}
+	cp := point.Subtract(c)
+	d5 := matrix.Vec3Dot(ab, cp)
+	d6 := matrix.Vec3Dot(ac, cp)
+	if d6 >= 0 && d5 <= d6 {
+		return c
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		w := d2 / (d2 - d6)
+		return a.Add(ac.Scale(w))
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && (d4-d3) >= 0 && (d5-d6) >= 0 {
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return b.Add(c.Subtract(b).Scale(w))
+	}
+	denom := 1.0 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	return a.Add(ab.Scale(v)).Add(ac.Scale(w))
+}
+
+// Barycentric returns the (u, v, w) weights of the point for the vertices
+// (a, b, c) of the triangle, the point is assumed to be on the triangle plane
+func (t Triangle) Barycentric(point matrix.Vec3) matrix.Vec3 {
+	v0 := t.P[1].Subtract(t.P[0])
+	v1 := t.P[2].Subtract(t.P[0])
+	v2 := point.Subtract(t.P[0])
+	d00 := matrix.Vec3Dot(v0, v0)
+	d01 := matrix.Vec3Dot(v0, v1)
+	d11 := matrix.Vec3Dot(v1, v1)
+	d20 := matrix.Vec3Dot(v2, v0)
+	d21 := matrix.Vec3Dot(v2, v1)
+	denom := d00*d11 - d01*d01
+	if matrix.Abs(denom) < matrix.FloatSmallestNonzero {
+		return matrix.Vec3{1, 0, 0}
+	}
+	v := (d11*d20 - d01*d21) / denom
+	w := (d00*d21 - d01*d20) / denom
+	return matrix.Vec3{1.0 - v - w, v, w}
+}
+
+// RayHit is a two sided Möller–Trumbore intersection test which returns the
+// distance along the ray along with the barycentric coordinates of the hit
+func (t Triangle) RayHit(ray Ray, maxLen float32) (float32, matrix.Vec3, bool) {
+	const epsilon = 1e-7
+	e1 := t.P[1].Subtract(t.P[0])
+	e2 := t.P[2].Subtract(t.P[0])
+	p := matrix.Vec3Cross(ray.Direction, e2)
+	det := matrix.Vec3Dot(e1, p)
+	if matrix.Abs(det) < epsilon {
+		return 0, matrix.Vec3{}, false
+	}
+	invDet := 1.0 / det
+	s := ray.Origin.Subtract(t.P[0])
+	u := matrix.Vec3Dot(s, p) * invDet
+	if u < 0 || u > 1 {
+		return 0, matrix.Vec3{}, false
+	}
+	q := matrix.Vec3Cross(s, e1)
+	v := matrix.Vec3Dot(ray.Direction, q) * invDet
+	if v < 0 || u+v > 1 {
+		return 0, matrix.Vec3{}, false
+	}
+	dist := matrix.Vec3Dot(e2, q) * invDet
+	if dist < 0 || dist > maxLen {
+		return 0, matrix.Vec3{}, false
+	}
+	return dist, matrix.Vec3{1.0 - u - v, u, v}, true
+}
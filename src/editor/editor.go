@@ -46,9 +46,11 @@ import (
 	"kaiju/editor/cache/project_cache"
 	"kaiju/editor/controls"
 	"kaiju/editor/project"
+	"kaiju/editor/selection"
 	"kaiju/editor/ui/menu"
 	"kaiju/editor/ui/project_window"
 	"kaiju/engine"
+	"kaiju/hid"
 	"kaiju/klib"
 	"kaiju/matrix"
 	"kaiju/rendering"
@@ -57,12 +59,18 @@ import (
 	"unsafe"
 )
 
+const maxClickDragDistance = 4
+
 type Editor struct {
 	Host           *engine.Host
 	menu           *menu.Menu
 	project        string
 	cam            controls.EditorCamera
 	AssetImporters asset_importer.ImportRegistry
+	selection      *selection.Selection
+	picker         *selection.Picker
+	highlight      *selection.Highlighter
+	clickStart     matrix.Vec2
 }
 
 func New(host *engine.Host) *Editor {
@@ -71,9 +79,15 @@ func New(host *engine.Host) *Editor {
 	ed := &Editor{
 		Host:           host,
 		AssetImporters: asset_importer.NewImportRegistry(),
+		selection:      selection.New(),
+		picker:         selection.NewPicker(),
 	}
+	ed.highlight = selection.NewHighlighter(host, ed.selection, ed.picker)
 	ed.AssetImporters.Register(asset_importer.OBJImporter{})
 	ed.AssetImporters.Register(asset_importer.PNGImporter{})
+	ed.AssetImporters.Register(asset_importer.NavGridImporter{})
+	ed.AssetImporters.Register(asset_importer.MaterialImporter{})
+	ed.AssetImporters.Register(asset_importer.GLTFImporter{})
 	host.Updater.AddUpdate(ed.update)
 	return ed
 }
@@ -88,6 +102,9 @@ func (t testBasicShaderData) Size() int {
 	return size
 }
 
+func (e *Editor) Selection() *selection.Selection { return e.selection }
+func (e *Editor) Picker() *selection.Picker       { return e.picker }
+
 func (e *Editor) setProject(project string) error {
 	project = strings.TrimSpace(project)
 	if project == "" {
@@ -150,18 +167,53 @@ func (e *Editor) SetupUI() {
 		m := klib.MustReturn(project_cache.LoadCachedMesh(adi.Children[0]))
 		sd := testBasicShaderData{rendering.NewShaderDataBase(), matrix.ColorWhite()}
 		tex, _ := e.Host.TextureCache().Texture(assets.TextureSquare, rendering.TextureFilterLinear)
-		mesh := rendering.NewMesh(m.Name, m.Verts, m.Indexes)
+		mesh := rendering.NewMesh(adi.Children[0].ID, m.Verts, m.Indexes)
 		e.Host.MeshCache().AddMesh(mesh)
+		entity := e.Host.NewEntity()
+		entity.SetName(m.Name)
 		e.Host.Drawings.AddDrawing(rendering.Drawing{
 			Renderer:   e.Host.Window.Renderer,
 			Shader:     e.Host.ShaderCache().ShaderFromDefinition(assets.ShaderDefinitionBasic),
 			Mesh:       mesh,
 			Textures:   []*rendering.Texture{tex},
 			ShaderData: &sd,
+			Transform:  &entity.Transform,
 		})
+		e.picker.AddTarget(entity, mesh.Key(), m)
 	}
 }
 
 func (ed *Editor) update(delta float64) {
 	ed.cam.Update(ed.Host, delta)
+	ed.updateSelection()
+}
+
+// updateSelection picks the entity under the cursor when it is clicked, a
+// click that drags or is used to move the camera does not change selection
+func (ed *Editor) updateSelection() {
+	cursor := &ed.Host.Window.Cursor
+	kb := &ed.Host.Window.Keyboard
+	if cursor.Pressed() {
+		ed.clickStart = cursor.ScreenPosition()
+	}
+	if !cursor.Released() || kb.KeyHeld(hid.KeyboardKeyLeftAlt) || kb.KeyHeld(hid.KeyboardKeySpace) {
+		return
+	}
+	pos := cursor.ScreenPosition()
+	if pos.Distance(ed.clickStart) > maxClickDragDistance {
+		return
+	}
+	additive := kb.KeyHeld(hid.KeyboardKeyLeftShift) || kb.KeyHeld(hid.KeyboardKeyRightShift)
+	hit, ok := ed.picker.PickScreen(ed.Host.Camera, pos)
+	if !ok {
+		if !additive {
+			ed.selection.Clear()
+		}
+		return
+	}
+	if additive {
+		ed.selection.Toggle(hit.Entity)
+	} else {
+		ed.selection.Set(hit.Entity)
+	}
 }
@@ -0,0 +1,153 @@
+/*****************************************************************************/
+/* highlight.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package selection
+
+import (
+	"kaiju/assets"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/systems/events"
+	"unsafe"
+)
+
+// highlightMeshKey is the white wire cube shared by all of the boxes, the
+// color is applied through the shader data so that changing it doesn't
+// need a new mesh
+const highlightMeshKey = "selection_highlight"
+
+type highlightShaderData struct {
+	rendering.ShaderDataBase
+	Color matrix.Color
+}
+
+func (t highlightShaderData) Size() int {
+	const size = int(unsafe.Sizeof(highlightShaderData{}) - rendering.ShaderBaseDataStart)
+	return size
+}
+
+type highlightBox struct {
+	entity *engine.Entity
+	data   *highlightShaderData
+}
+
+// Highlighter draws a wire box around the bounds of every selected entity
+// and keeps the boxes following the entities as they move
+type Highlighter struct {
+	host      *engine.Host
+	selection *Selection
+	picker    *Picker
+	boxes     map[*engine.Entity]highlightBox
+	Color     matrix.Color
+	changedId events.Id
+	updateId  int
+}
+
+func NewHighlighter(host *engine.Host, selection *Selection, picker *Picker) *Highlighter {
+	h := &Highlighter{
+		host:      host,
+		selection: selection,
+		picker:    picker,
+		boxes:     make(map[*engine.Entity]highlightBox),
+		Color:     matrix.Color{1, 0.6, 0, 1},
+	}
+	h.changedId = selection.Changed.Add(h.sync)
+	h.updateId = host.LateUpdater.AddUpdate(h.update)
+	return h
+}
+
+func (h *Highlighter) Destroy() {
+	h.selection.Changed.Remove(h.changedId)
+	h.host.LateUpdater.RemoveUpdate(h.updateId)
+	for target := range h.boxes {
+		h.removeBox(target)
+	}
+}
+
+func (h *Highlighter) sync() {
+	for target := range h.boxes {
+		if !h.selection.Contains(target) {
+			h.removeBox(target)
+		}
+	}
+	for _, target := range h.selection.Entities() {
+		if _, ok := h.boxes[target]; !ok {
+			h.addBox(target)
+		}
+	}
+	h.update(0)
+}
+
+func (h *Highlighter) addBox(target *engine.Entity) {
+	h.host.CreatingEditorEntities()
+	entity := h.host.NewEntity()
+	h.host.DoneCreatingEditorEntities()
+	data := &highlightShaderData{
+		ShaderDataBase: rendering.NewShaderDataBase(),
+		Color:          h.Color,
+	}
+	h.host.Drawings.AddDrawing(rendering.Drawing{
+		Renderer:   h.host.Window.Renderer,
+		Shader:     h.host.ShaderCache().ShaderFromDefinition(assets.ShaderDefinitionGrid),
+		Mesh:       rendering.NewMeshWireCube(h.host.MeshCache(), highlightMeshKey, matrix.ColorWhite()),
+		ShaderData: data,
+		Transform:  &entity.Transform,
+	})
+	h.boxes[target] = highlightBox{entity, data}
+}
+
+func (h *Highlighter) removeBox(target *engine.Entity) {
+	box := h.boxes[target]
+	box.data.Destroy()
+	box.entity.Destroy()
+	delete(h.boxes, target)
+}
+
+func (h *Highlighter) update(float64) {
+	for target, box := range h.boxes {
+		bounds, ok := h.picker.Bounds(target)
+		if !ok || !target.IsActive() || target.IsDestroyed() {
+			box.data.Deactivate()
+			continue
+		}
+		box.data.Activate()
+		box.data.Color = h.Color
+		box.entity.Transform.SetPosition(bounds.Center())
+		box.entity.Transform.SetScale(bounds.Size())
+	}
+}
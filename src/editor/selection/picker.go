@@ -0,0 +1,165 @@
+/*****************************************************************************/
+/* picker.go                                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package selection
+
+import (
+	"kaiju/cameras"
+	"kaiju/collision"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering/loaders"
+	"slices"
+)
+
+const maxPickDistance = 10000
+
+type pickTarget struct {
+	entity *engine.Entity
+	bvh    *collision.BVH
+}
+
+// Hit is the closest entity found under the cursor, Triangle is the index
+// of the triangle within the mesh and Barycentric the weights of its points
+type Hit struct {
+	Entity      *engine.Entity
+	Point       matrix.Vec3
+	Barycentric matrix.Vec3
+	Distance    float32
+	Triangle    int
+}
+
+// Picker tests rays against the triangles of the meshes in the scene, each
+// mesh has a BVH built once and shared by all of the entities using it. The
+// BVHs are keyed the same way as meshes in the rendering.MeshCache since
+// mesh names are only unique within the file they were loaded from.
+type Picker struct {
+	meshes  map[string]*collision.BVH
+	targets []pickTarget
+}
+
+func NewPicker() *Picker {
+	return &Picker{meshes: make(map[string]*collision.BVH)}
+}
+
+// BVHFromMesh builds the hierarchy for the triangles of a loaded mesh
+func BVHFromMesh(mesh loaders.ResultMesh) *collision.BVH {
+	tris := make([]collision.Triangle, 0, len(mesh.Indexes)/3)
+	for i := 0; i+2 < len(mesh.Indexes); i += 3 {
+		tris = append(tris, collision.NewTriangle(
+			mesh.Verts[mesh.Indexes[i]].Position,
+			mesh.Verts[mesh.Indexes[i+1]].Position,
+			mesh.Verts[mesh.Indexes[i+2]].Position))
+	}
+	return collision.NewBVH(tris)
+}
+
+// AddTarget makes the entity pickable using the triangles of the mesh, the
+// key is the mesh cache key of the mesh. The target is removed when the
+// entity is destroyed.
+func (p *Picker) AddTarget(entity *engine.Entity, key string, mesh loaders.ResultMesh) {
+	bvh, ok := p.meshes[key]
+	if !ok {
+		bvh = BVHFromMesh(mesh)
+		p.meshes[key] = bvh
+	}
+	p.targets = append(p.targets, pickTarget{entity, bvh})
+	entity.OnDestroy.Add(func() { p.RemoveTarget(entity) })
+}
+
+func (p *Picker) RemoveTarget(entity *engine.Entity) {
+	p.targets = slices.DeleteFunc(p.targets, func(t pickTarget) bool {
+		return t.entity == entity
+	})
+}
+
+// Bounds returns the world space bounds of the mesh for a pickable entity
+func (p *Picker) Bounds(entity *engine.Entity) (collision.AABB, bool) {
+	for i := range p.targets {
+		if p.targets[i].entity == entity {
+			m := entity.Transform.WorldMatrix()
+			return p.targets[i].bvh.Bounds().Transform(m), true
+		}
+	}
+	return collision.AABB{}, false
+}
+
+// PickScreen casts a ray from the camera through the screen position, which
+// is expected to have its origin at the top left of the window
+func (p *Picker) PickScreen(camera cameras.Camera, screenPos matrix.Vec2) (Hit, bool) {
+	return p.Pick(camera.Raycast(screenPos))
+}
+
+// Pick returns the closest active entity whose mesh triangles the ray hits,
+// the ray is moved into the local space of each entity rather than moving
+// the triangles into world space
+func (p *Picker) Pick(ray collision.Ray) (Hit, bool) {
+	best := Hit{Distance: maxPickDistance, Triangle: -1}
+	for i := range p.targets {
+		t := &p.targets[i]
+		if !t.entity.IsActive() || t.entity.IsDestroyed() {
+			continue
+		}
+		world := t.entity.Transform.WorldMatrix()
+		if d, ok := t.bvh.Bounds().Transform(world).RayHit(ray); !ok || d > best.Distance {
+			continue
+		}
+		inv := world
+		inv.Inverse()
+		origin := inv.TransformPoint(ray.Origin)
+		local := collision.Ray{
+			Origin:    origin,
+			Direction: inv.TransformPoint(ray.Point(1)).Subtract(origin),
+		}
+		hit, ok := t.bvh.RayHit(local, matrix.FloatMax)
+		if !ok {
+			continue
+		}
+		point := world.TransformPoint(hit.Point)
+		dist := point.Distance(ray.Origin)
+		if dist < best.Distance {
+			best = Hit{
+				Entity:      t.entity,
+				Point:       point,
+				Barycentric: hit.Barycentric,
+				Distance:    dist,
+				Triangle:    hit.Triangle,
+			}
+		}
+	}
+	return best, best.Entity != nil
+}
@@ -0,0 +1,159 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package selection
+
+import (
+	"kaiju/collision"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/rendering/loaders"
+	"testing"
+)
+
+// testCube is a unit cube centered on the origin with outward facing
+// triangles, every cube uses the same name like meshes loaded from
+// different files often do
+func testCube() loaders.ResultMesh {
+	verts := make([]rendering.Vertex, 8)
+	for i := range verts {
+		verts[i].Position = matrix.Vec3{
+			float32(i&1) - 0.5, float32((i>>1)&1) - 0.5, float32((i>>2)&1) - 0.5}
+	}
+	return loaders.ResultMesh{
+		Name:  "Cube",
+		Verts: verts,
+		Indexes: []uint32{
+			0, 2, 1, 1, 2, 3, // -Z
+			4, 5, 6, 5, 7, 6, // +Z
+			0, 1, 4, 1, 5, 4, // -Y
+			2, 6, 3, 3, 6, 7, // +Y
+			0, 4, 2, 2, 4, 6, // -X
+			1, 3, 5, 3, 7, 5, // +X
+		},
+	}
+}
+
+func testTarget(position matrix.Vec3) *engine.Entity {
+	e := engine.NewEntity()
+	e.Transform.SetPosition(position)
+	return e
+}
+
+func destroyEntity(e *engine.Entity) {
+	e.Destroy()
+	for !e.TickCleanup() {
+	}
+}
+
+func TestPickerSharesBVHByKey(t *testing.T) {
+	p := NewPicker()
+	cube := testCube()
+	p.AddTarget(testTarget(matrix.Vec3Zero()), "cube_a", cube)
+	p.AddTarget(testTarget(matrix.Vec3Zero()), "cube_a", cube)
+	small := testCube()
+	for i := range small.Verts {
+		small.Verts[i].Position.ScaleAssign(0.5)
+	}
+	p.AddTarget(testTarget(matrix.Vec3Zero()), "cube_b", small)
+	if len(p.meshes) != 2 {
+		t.Fatalf("expected one BVH per mesh key, got %d", len(p.meshes))
+	}
+	if p.targets[0].bvh != p.targets[1].bvh || p.targets[0].bvh == p.targets[2].bvh {
+		t.Error("targets should only share a BVH when they share a mesh key")
+	}
+	if size := p.targets[2].bvh.Bounds().Size(); !matrix.Vec3ApproxTo(size, matrix.Vec3One().Scale(0.5), 0.001) {
+		t.Errorf("a mesh with the same name but a different key should get its own BVH, got a size of %v", size)
+	}
+}
+
+func TestPickerPick(t *testing.T) {
+	p := NewPicker()
+	near := testTarget(matrix.Vec3{0, 0, 2})
+	far := testTarget(matrix.Vec3{0, 0, -2})
+	far.Transform.SetScale(matrix.Vec3One().Scale(4))
+	p.AddTarget(near, "cube", testCube())
+	p.AddTarget(far, "cube", testCube())
+	ray := collision.Ray{Origin: matrix.Vec3{0, 0, 10}, Direction: matrix.Vec3{0, 0, -1}}
+	hit, ok := p.Pick(ray)
+	if !ok || hit.Entity != near {
+		t.Fatalf("expected to pick the near cube, got %v", hit.Entity)
+	}
+	if !matrix.ApproxTo(hit.Distance, 7.5, 0.001) {
+		t.Errorf("expected a distance of 7.5, got %f", hit.Distance)
+	}
+	// The far cube is scaled up so a ray beside the near cube still hits it
+	ray.Origin = matrix.Vec3{1.5, 0, 10}
+	if hit, ok = p.Pick(ray); !ok || hit.Entity != far {
+		t.Fatalf("expected to pick the scaled far cube, got %v", hit.Entity)
+	}
+	if !matrix.Vec3ApproxTo(hit.Point, matrix.Vec3{1.5, 0, 0}, 0.001) {
+		t.Errorf("expected the hit point on the front of the far cube, got %v", hit.Point)
+	}
+	ray.Origin = matrix.Vec3{0, 0, 10}
+	near.Deactivate()
+	if hit, ok = p.Pick(ray); !ok || hit.Entity != far {
+		t.Errorf("inactive entities should not be picked, got %v", hit.Entity)
+	}
+	p.RemoveTarget(far)
+	if _, ok = p.Pick(ray); ok {
+		t.Error("removed targets should not be picked")
+	}
+}
+
+func TestPickerBounds(t *testing.T) {
+	p := NewPicker()
+	e := testTarget(matrix.Vec3{1, 2, 3})
+	e.Transform.SetScale(matrix.Vec3{2, 2, 2})
+	if _, ok := p.Bounds(e); ok {
+		t.Fatal("an entity that isn't a target has no bounds")
+	}
+	p.AddTarget(e, "cube", testCube())
+	bounds, ok := p.Bounds(e)
+	if !ok {
+		t.Fatal("expected bounds for the target")
+	}
+	if !matrix.Vec3ApproxTo(bounds.Center(), matrix.Vec3{1, 2, 3}, 0.001) ||
+		!matrix.Vec3ApproxTo(bounds.Size(), matrix.Vec3{2, 2, 2}, 0.001) {
+		t.Errorf("unexpected world bounds %v", bounds)
+	}
+	destroyEntity(e)
+	if _, ok = p.Bounds(e); ok {
+		t.Error("the target should be removed when the entity is destroyed")
+	}
+}
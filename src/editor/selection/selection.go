@@ -0,0 +1,142 @@
+/*****************************************************************************/
+/* selection.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package selection
+
+import (
+	"kaiju/engine"
+	"kaiju/systems/events"
+	"slices"
+)
+
+// Selection is the set of entities the editor is currently working on, the
+// first entity selected is considered the primary selection. Entities are
+// dropped from the selection when they are destroyed.
+type Selection struct {
+	entities   []*engine.Entity
+	destroyIds map[*engine.Entity]events.Id
+	Changed    events.Event
+}
+
+func New() *Selection {
+	return &Selection{
+		destroyIds: make(map[*engine.Entity]events.Id),
+		Changed:    events.New(),
+	}
+}
+
+func (s *Selection) Entities() []*engine.Entity { return s.entities }
+func (s *Selection) IsEmpty() bool              { return len(s.entities) == 0 }
+
+func (s *Selection) Primary() (*engine.Entity, bool) {
+	if len(s.entities) == 0 {
+		return nil, false
+	}
+	return s.entities[0], true
+}
+
+func (s *Selection) Contains(entity *engine.Entity) bool {
+	return slices.Contains(s.entities, entity)
+}
+
+// Set replaces the current selection with the given entity
+func (s *Selection) Set(entity *engine.Entity) {
+	if len(s.entities) == 1 && s.entities[0] == entity {
+		return
+	}
+	s.untrackAll()
+	s.entities = append(s.entities[:0], entity)
+	s.track(entity)
+	s.Changed.Execute()
+}
+
+func (s *Selection) Add(entity *engine.Entity) {
+	if s.Contains(entity) {
+		return
+	}
+	s.entities = append(s.entities, entity)
+	s.track(entity)
+	s.Changed.Execute()
+}
+
+func (s *Selection) Remove(entity *engine.Entity) {
+	idx := slices.Index(s.entities, entity)
+	if idx < 0 {
+		return
+	}
+	s.entities = slices.Delete(s.entities, idx, idx+1)
+	s.untrack(entity)
+	s.Changed.Execute()
+}
+
+func (s *Selection) Toggle(entity *engine.Entity) {
+	if s.Contains(entity) {
+		s.Remove(entity)
+	} else {
+		s.Add(entity)
+	}
+}
+
+func (s *Selection) Clear() {
+	if len(s.entities) == 0 {
+		return
+	}
+	s.untrackAll()
+	s.entities = s.entities[:0]
+	s.Changed.Execute()
+}
+
+func (s *Selection) track(entity *engine.Entity) {
+	s.destroyIds[entity] = entity.OnDestroy.Add(func() {
+		// The handler can't be removed while the event is executing
+		delete(s.destroyIds, entity)
+		s.Remove(entity)
+	})
+}
+
+func (s *Selection) untrack(entity *engine.Entity) {
+	if id, ok := s.destroyIds[entity]; ok {
+		entity.OnDestroy.Remove(id)
+		delete(s.destroyIds, entity)
+	}
+}
+
+func (s *Selection) untrackAll() {
+	for _, e := range s.entities {
+		s.untrack(e)
+	}
+}
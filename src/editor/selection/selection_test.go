@@ -0,0 +1,111 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package selection
+
+import (
+	"kaiju/engine"
+	"testing"
+)
+
+func TestSelection(t *testing.T) {
+	s := New()
+	changes := 0
+	s.Changed.Add(func() { changes++ })
+	a, b, c := engine.NewEntity(), engine.NewEntity(), engine.NewEntity()
+	if _, ok := s.Primary(); ok || !s.IsEmpty() {
+		t.Fatal("a new selection should be empty")
+	}
+	s.Set(a)
+	s.Set(a)
+	if changes != 1 {
+		t.Errorf("setting the same entity twice should only change once, got %d", changes)
+	}
+	s.Add(b)
+	s.Add(b)
+	s.Add(c)
+	if len(s.Entities()) != 3 || changes != 3 {
+		t.Errorf("expected 3 entities after 3 changes, got %d after %d", len(s.Entities()), changes)
+	}
+	if p, _ := s.Primary(); p != a {
+		t.Error("the first selected entity should be the primary")
+	}
+	s.Toggle(b)
+	if s.Contains(b) || !s.Contains(c) {
+		t.Error("toggling a selected entity should remove it")
+	}
+	s.Toggle(b)
+	if !s.Contains(b) {
+		t.Error("toggling an unselected entity should add it")
+	}
+	s.Remove(a)
+	if p, _ := s.Primary(); p != c {
+		t.Error("removing the primary should promote the next entity")
+	}
+	s.Set(a)
+	if len(s.Entities()) != 1 || !s.Contains(a) {
+		t.Error("set should replace the whole selection")
+	}
+	changes = 0
+	s.Clear()
+	s.Clear()
+	if !s.IsEmpty() || changes != 1 {
+		t.Errorf("clear should empty the selection once, got %d changes", changes)
+	}
+}
+
+func TestSelectionDestroyedEntity(t *testing.T) {
+	s := New()
+	a, b := engine.NewEntity(), engine.NewEntity()
+	s.Add(a)
+	s.Add(b)
+	destroyEntity(a)
+	if s.Contains(a) || !s.Contains(b) {
+		t.Error("destroyed entities should be dropped from the selection")
+	}
+	// Deselected entities should no longer be tracked
+	s.Remove(b)
+	if len(s.destroyIds) != 0 {
+		t.Errorf("expected no destroy handlers to be tracked, got %d", len(s.destroyIds))
+	}
+	changes := 0
+	s.Changed.Add(func() { changes++ })
+	destroyEntity(b)
+	if changes != 0 {
+		t.Error("destroying an unselected entity should not change the selection")
+	}
+}
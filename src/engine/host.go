@@ -59,16 +59,26 @@ type Host struct {
 	textureCache   rendering.TextureCache
 	meshCache      rendering.MeshCache
 	fontCache      rendering.FontCache
+	materialCache  rendering.MaterialCache
 	Drawings       rendering.Drawings
+	Lights         rendering.Lights
+	Skins          rendering.Skins
+	Morphs         rendering.Morphs
+	PostProcessing rendering.PostProcessing
 	frameTime      float64
 	Closing        bool
 	Updater        Updater
 	LateUpdater    Updater
 	assetDatabase  assets.Database
 	OnClose        events.Event
+	// OnFrameDrawn is executed after the frame is drawn and before it is
+	// presented, the frame can be read back from FrameTarget then
+	OnFrameDrawn   events.Event
 	CloseSignal    chan struct{}
 	frameRateLimit *time.Ticker
 	inEditorEntity bool
+	frameTarget    rendering.RenderTarget
+	fixedTimestep  float64
 }
 
 func NewHost(name string) *Host {
@@ -84,12 +94,17 @@ func NewHost(name string) *Host {
 		LateUpdater:    NewUpdater(),
 		assetDatabase:  assets.NewDatabase(),
 		Drawings:       rendering.NewDrawings(),
+		Lights:         rendering.NewLights(),
+		Skins:          rendering.NewSkins(),
+		Morphs:         rendering.NewMorphs(),
 		OnClose:        events.New(),
+		OnFrameDrawn:   events.New(),
 		CloseSignal:    make(chan struct{}),
 		Camera:         cameras.NewStandardCamera(w, h, matrix.Vec3{0, 0, 1}),
 		UICamera:       cameras.NewStandardCameraOrthographic(w, h, matrix.Vec3{0, 0, 1}),
 	}
 	host.UICamera.SetPosition(matrix.Vec3{0, 0, 250})
+	host.PostProcessing = rendering.NewPostProcessing(host)
 	return host
 }
 
@@ -104,6 +119,17 @@ func (host *Host) Initialize(width, height int) error {
 	if err != nil {
 		return err
 	}
+	host.setupWindow(win, width, height)
+	return nil
+}
+
+// InitializeHeadless sets the host up on a window that is never shown and
+// draws with the given renderer, see windowing.NewHeadless
+func (host *Host) InitializeHeadless(width, height int, renderer rendering.Renderer) {
+	host.setupWindow(windowing.NewHeadless(width, height, renderer), width, height)
+}
+
+func (host *Host) setupWindow(win *windowing.Window, width, height int) {
 	host.Window = win
 	host.Camera.ViewportChanged(float32(width), float32(height))
 	host.UICamera.ViewportChanged(float32(width), float32(height))
@@ -111,8 +137,8 @@ func (host *Host) Initialize(width, height int) error {
 	host.textureCache = rendering.NewTextureCache(host.Window.Renderer, &host.assetDatabase)
 	host.meshCache = rendering.NewMeshCache(host.Window.Renderer, &host.assetDatabase)
 	host.fontCache = rendering.NewFontCache(host.Window.Renderer, &host.assetDatabase)
+	host.materialCache = rendering.NewMaterialCache(host, &host.assetDatabase)
 	host.Window.OnResize.Add(host.resized)
-	return nil
 }
 
 func (host *Host) Name() string { return host.name }
@@ -131,11 +157,12 @@ func (host *Host) DoneCreatingEditorEntities() {
 	host.inEditorEntity = false
 }
 
-func (host *Host) ShaderCache() *rendering.ShaderCache   { return &host.shaderCache }
-func (host *Host) TextureCache() *rendering.TextureCache { return &host.textureCache }
-func (host *Host) MeshCache() *rendering.MeshCache       { return &host.meshCache }
-func (host *Host) FontCache() *rendering.FontCache       { return &host.fontCache }
-func (host *Host) AssetDatabase() *assets.Database       { return &host.assetDatabase }
+func (host *Host) ShaderCache() *rendering.ShaderCache     { return &host.shaderCache }
+func (host *Host) TextureCache() *rendering.TextureCache   { return &host.textureCache }
+func (host *Host) MeshCache() *rendering.MeshCache         { return &host.meshCache }
+func (host *Host) FontCache() *rendering.FontCache         { return &host.fontCache }
+func (host *Host) MaterialCache() *rendering.MaterialCache { return &host.materialCache }
+func (host *Host) AssetDatabase() *assets.Database         { return &host.assetDatabase }
 
 func (host *Host) AddEntity(entity *Entity) {
 	host.addEntity(entity)
@@ -154,6 +181,9 @@ func (host *Host) NewEntity() *Entity {
 }
 
 func (host *Host) Update(deltaTime float64) {
+	if host.fixedTimestep > 0 {
+		deltaTime = host.fixedTimestep
+	}
 	host.Window.Poll()
 	host.Updater.Update(deltaTime)
 	host.LateUpdater.Update(deltaTime)
@@ -172,8 +202,16 @@ func (host *Host) Render() {
 	host.shaderCache.CreatePending()
 	host.textureCache.CreatePending()
 	host.meshCache.CreatePending()
-	host.Window.Renderer.ReadyFrame(host.Camera, host.UICamera, float32(host.Runtime()))
-	host.Drawings.Render(host.Window.Renderer)
+	host.Window.Renderer.ReadyFrame(rendering.FrameData{
+		Camera:   host.Camera,
+		UICamera: host.UICamera,
+		Lights:   &host.Lights,
+		Skins:    &host.Skins,
+		Morphs:   &host.Morphs,
+		Runtime:  float32(host.Runtime()),
+	})
+	host.frameTarget = host.Drawings.Render(host.Window.Renderer, host.Camera, host.PostProcessing.Find(host.Camera))
+	host.OnFrameDrawn.Execute()
 	host.Window.SwapBuffers()
 	// TODO:  Thread this or make the dirty on demand, and have a flag for the dirty frame
 	for _, e := range host.entities {
@@ -186,15 +224,27 @@ func (host *Host) Runtime() float64 {
 	return host.frameTime
 }
 
+// FrameTarget is the render target that was presented for the last frame
+func (host *Host) FrameTarget() rendering.RenderTarget { return host.frameTarget }
+
+// SetFixedTimestep makes Update step by the given seconds every frame no
+// matter how long the frame took, so recordings play back smoothly even
+// when frames are slow to draw. 0 goes back to the real frame time
+func (host *Host) SetFixedTimestep(seconds float64) { host.fixedTimestep = max(seconds, 0) }
+
+func (host *Host) FixedTimestep() float64 { return host.fixedTimestep }
+
 func (host *Host) Teardown() {
 	host.OnClose.Execute()
 	host.Updater.Destroy()
 	host.LateUpdater.Destroy()
 	host.Drawings.Destroy(host.Window.Renderer)
+	host.PostProcessing.Destroy(host.Window.Renderer)
 	host.textureCache.Destroy()
 	host.meshCache.Destroy()
 	host.shaderCache.Destroy()
 	host.fontCache.Destroy()
+	host.materialCache.Destroy()
 	host.assetDatabase.Destroy()
 	host.Window.Destroy()
 	host.CloseSignal <- struct{}{}
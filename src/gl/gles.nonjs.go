@@ -179,6 +179,12 @@ void cglVertexAttribPointer(GLuint index, GLint size, GLenum type, GLboolean nor
 	glVertexAttribPointer(index, size, type, normalized, stride, pointer);
 }
 
+// The offset into the bound buffer is made a pointer here rather than in Go,
+// where vet reports the uintptr to unsafe.Pointer conversion
+void cglVertexAttribIPointer(GLuint index, GLint size, GLenum type, GLsizei stride, GLintptr offset) {
+	glVertexAttribIPointer(index, size, type, stride, (const void *)offset);
+}
+
 void cglEnableVertexAttribArray(GLuint index) {
 	glEnableVertexAttribArray(index);
 }
@@ -207,6 +213,18 @@ void cglUniform3fv(GLint location, GLsizei count, const GLfloat *value) {
 	glUniform3fv(location, count, value);
 }
 
+void cglUniform2fv(GLint location, GLsizei count, const GLfloat *value) {
+	glUniform2fv(location, count, value);
+}
+
+void cglUniform4fv(GLint location, GLsizei count, const GLfloat *value) {
+	glUniform4fv(location, count, value);
+}
+
+void cglUniform4uiv(GLint location, GLsizei count, const GLuint *value) {
+	glUniform4uiv(location, count, value);
+}
+
 void cglUniform1f(GLint location, GLfloat value) {
 	glUniform1f(location, value);
 }
@@ -254,6 +272,46 @@ void cglClearBufferfv(GLenum buffer, GLint drawBuffer, const GLfloat *value) {
 void cglViewport(GLint x, GLint y, GLsizei width, GLsizei height) {
 	glViewport(x, y, width, height);
 }
+
+void cglTexImage3D(GLenum target, GLint level, GLint internalFormat, GLsizei width, GLsizei height, GLsizei depth, GLint border, GLenum format, GLenum type, const void *pixels) {
+	glTexImage3D(target, level, internalFormat, width, height, depth, border, format, type, pixels);
+}
+
+void cglFramebufferTextureLayer(GLenum target, GLenum attachment, GLuint texture, GLint level, GLint layer) {
+	glFramebufferTextureLayer(target, attachment, texture, level, layer);
+}
+
+void cglReadPixels(GLint x, GLint y, GLsizei width, GLsizei height, GLenum format, GLenum type, void *pixels) {
+	glReadPixels(x, y, width, height, format, type, pixels);
+}
+
+void cglPixelStorei(GLenum pname, GLint param) {
+	glPixelStorei(pname, param);
+}
+
+void cglGenRenderbuffers(GLsizei n, GLuint *renderbuffers) {
+	glGenRenderbuffers(n, renderbuffers);
+}
+
+void cglDeleteRenderbuffers(GLsizei n, GLuint *renderbuffers) {
+	glDeleteRenderbuffers(n, renderbuffers);
+}
+
+void cglBindRenderbuffer(GLenum target, GLuint renderbuffer) {
+	glBindRenderbuffer(target, renderbuffer);
+}
+
+void cglRenderbufferStorageMultisample(GLenum target, GLsizei samples, GLenum internalformat, GLsizei width, GLsizei height) {
+	glRenderbufferStorageMultisample(target, samples, internalformat, width, height);
+}
+
+void cglFramebufferRenderbuffer(GLenum target, GLenum attachment, GLenum renderbuffertarget, GLuint renderbuffer) {
+	glFramebufferRenderbuffer(target, attachment, renderbuffertarget, renderbuffer);
+}
+
+void cglBlitFramebuffer(GLint srcX0, GLint srcY0, GLint srcX1, GLint srcY1, GLint dstX0, GLint dstY0, GLint dstX1, GLint dstY1, GLbitfield mask, GLenum filter) {
+	glBlitFramebuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1, mask, filter);
+}
 */
 import "C"
 import (
@@ -363,6 +421,11 @@ const (
 	OneMinusSrcColor        = 0x0301
 	ColorBufferBit          = 0x00004000
 	DepthBufferBit          = 0x00000100
+	Texture2DArray          = 0x8C1A
+	ReadFrameBuffer         = 0x8CA8
+	DrawFrameBuffer         = 0x8CA9
+	RenderBuffer            = 0x8D41
+	PackAlignment           = 0x0D05
 )
 
 func ClearColor(r, g, b, a float32) {
@@ -526,6 +589,12 @@ func VertexAttribPointer(index uint32, size int32, typ Handle, normalized bool,
 	C.cglVertexAttribPointer(C.GLuint(index), C.GLint(size), C.GLenum(typ), C.GLboolean(nml), C.GLsizei(stride), unsafe.Pointer(uintptr(offset)))
 }
 
+// VertexAttribIPointer is for integer attributes, they are not converted to
+// floats like they are by VertexAttribPointer
+func VertexAttribIPointer(index uint32, size int32, typ Handle, stride int32, offset int32) {
+	C.cglVertexAttribIPointer(C.GLuint(index), C.GLint(size), C.GLenum(typ), C.GLsizei(stride), C.GLintptr(offset))
+}
+
 func EnableVertexAttribArray(index uint32) {
 	C.cglEnableVertexAttribArray(C.GLuint(index))
 }
@@ -581,6 +650,19 @@ func Uniform3fv(location Result, values *matrix.Vec3) {
 	C.cglUniform3fv(C.GLint(location), C.GLsizei(1), (*C.GLfloat)(unsafe.Pointer(&values[0])))
 }
 
+func Uniform2fv(location Result, values *matrix.Vec2) {
+	C.cglUniform2fv(C.GLint(location), C.GLsizei(1), (*C.GLfloat)(unsafe.Pointer(&values[0])))
+}
+
+func Uniform4fv(location Result, values *matrix.Vec4) {
+	C.cglUniform4fv(C.GLint(location), C.GLsizei(1), (*C.GLfloat)(unsafe.Pointer(&values[0])))
+}
+
+// Uniform4uiv sets the uvec4 array that starts at the location
+func Uniform4uiv(location Result, values [][4]uint32) {
+	C.cglUniform4uiv(C.GLint(location), C.GLsizei(len(values)), (*C.GLuint)(unsafe.Pointer(&values[0][0])))
+}
+
 func Uniform1f(location Result, value float32) {
 	C.cglUniform1f(C.GLint(location), C.GLfloat(value))
 }
@@ -629,3 +711,43 @@ func DrawBuffers(buffers []Handle) {
 func ClearBufferfv(buffer Handle, drawBuffer int32, value matrix.Vec4) {
 	C.cglClearBufferfv(C.GLenum(buffer), C.GLint(drawBuffer), (*C.GLfloat)(unsafe.Pointer(&value[0])))
 }
+
+func TexImage3D(target Handle, level int32, internalFormat Handle, width, height, depth int32, border int32, format Handle, typ Handle, pixels unsafe.Pointer) {
+	C.cglTexImage3D(C.GLenum(target), C.GLint(level), C.GLint(internalFormat), C.GLsizei(width), C.GLsizei(height), C.GLsizei(depth), C.GLint(border), C.GLenum(format), C.GLenum(typ), pixels)
+}
+
+func FrameBufferTextureLayer(target Handle, attachment Handle, texture Handle, level int32, layer int32) {
+	C.cglFramebufferTextureLayer(C.GLenum(target), C.GLenum(attachment), texture.AsGL(), C.GLint(level), C.GLint(layer))
+}
+
+func ReadPixels(x, y, width, height int32, format Handle, typ Handle, pixels unsafe.Pointer) {
+	C.cglReadPixels(C.GLint(x), C.GLint(y), C.GLsizei(width), C.GLsizei(height), C.GLenum(format), C.GLenum(typ), pixels)
+}
+
+func PixelStorei(pname Handle, param int32) {
+	C.cglPixelStorei(C.GLenum(pname), C.GLint(param))
+}
+
+func GenRenderBuffers(n int32, renderbuffers *Handle) {
+	C.cglGenRenderbuffers(C.GLsizei(n), (*C.GLuint)(unsafe.Pointer(renderbuffers)))
+}
+
+func DeleteRenderBuffers(n int32, renderbuffers *Handle) {
+	C.cglDeleteRenderbuffers(C.GLsizei(n), (*C.GLuint)(unsafe.Pointer(renderbuffers)))
+}
+
+func BindRenderBuffer(target Handle, renderbuffer Handle) {
+	C.cglBindRenderbuffer(C.GLenum(target), renderbuffer.AsGL())
+}
+
+func RenderBufferStorageMultisample(target Handle, samples int32, internalFormat Handle, width, height int32) {
+	C.cglRenderbufferStorageMultisample(C.GLenum(target), C.GLsizei(samples), C.GLenum(internalFormat), C.GLsizei(width), C.GLsizei(height))
+}
+
+func FrameBufferRenderBuffer(target Handle, attachment Handle, renderbufferTarget Handle, renderbuffer Handle) {
+	C.cglFramebufferRenderbuffer(C.GLenum(target), C.GLenum(attachment), C.GLenum(renderbufferTarget), renderbuffer.AsGL())
+}
+
+func BlitFrameBuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1 int32, mask Handle, filter Handle) {
+	C.cglBlitFramebuffer(C.GLint(srcX0), C.GLint(srcY0), C.GLint(srcX1), C.GLint(srcY1), C.GLint(dstX0), C.GLint(dstY0), C.GLint(dstX1), C.GLint(dstY1), C.GLbitfield(mask), C.GLenum(filter))
+}
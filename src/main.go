@@ -47,7 +47,9 @@ import (
 	"kaiju/profiler"
 	"kaiju/systems/console"
 	tests "kaiju/tests/rendering_tests"
+	"kaiju/tools/capture"
 	"kaiju/tools/html_preview"
+	"kaiju/tools/shader_reload"
 	"runtime"
 )
 
@@ -59,9 +61,15 @@ func addConsole(host *engine.Host) {
 	console.For(host).AddCommand("EntityCount", func(*engine.Host, string) string {
 		return fmt.Sprintf("Entity count: %d", len(host.Entities()))
 	})
+	console.For(host).AddCommand("DrawStats", func(*engine.Host, string) string {
+		stats := host.Drawings.Stats()
+		return fmt.Sprintf("Drawn: %d, Culled: %d", stats.Drawn, stats.Culled)
+	})
 	html_preview.SetupConsole(host)
 	hierarchy.SetupConsole(host)
 	profiler.SetupConsole(host)
+	capture.SetupConsole(host)
+	shader_reload.SetupConsole(host)
 	tests.SetupConsole(host)
 }
 
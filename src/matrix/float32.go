@@ -106,6 +106,10 @@ func Pow(x Float, y Float) Float {
 	return Float(math.Pow(float64(x), float64(y)))
 }
 
+func Exp(x Float) Float {
+	return Float(math.Exp(float64(x)))
+}
+
 func IsNaN(x Float) bool {
 	return math.IsNaN(float64(x))
 }
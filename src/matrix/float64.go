@@ -106,6 +106,10 @@ func Pow(x Float, y Float) Float {
 	return math.Pow(x, y)
 }
 
+func Exp(x Float) Float {
+	return math.Exp(x)
+}
+
 func IsNan(x Float) bool {
 	return math.IsNaN(x)
 }
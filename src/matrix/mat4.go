@@ -341,6 +341,15 @@ func (m *Mat4) Scale(scale Vec3) {
 	m[x2y2] *= scale.Z()
 }
 
+// Mat4FromTRS is the matrix that scales, then rotates, then translates
+func Mat4FromTRS(translation Vec3, rotation Quaternion, scale Vec3) Mat4 {
+	m := Mat4Identity()
+	m.Scale(scale)
+	m = m.Multiply(rotation.ToMat4())
+	m.Translate(translation)
+	return m
+}
+
 func (m *Mat4) LookAt(eye Vec3, center Vec3, up Vec3) {
 	f := eye.Subtract(center)
 	f.Normalize()
@@ -38,6 +38,7 @@
 package rendering
 
 import (
+	"kaiju/collision"
 	"kaiju/klib"
 	"kaiju/matrix"
 	"unsafe"
@@ -51,6 +52,7 @@ type DrawInstance interface {
 	IsActive() bool
 	Size() int
 	SetModel(model matrix.Mat4)
+	Model() matrix.Mat4
 	UpdateModel()
 	DataPointer() unsafe.Pointer
 	setTransform(transform *matrix.Transform)
@@ -95,6 +97,8 @@ func (s *ShaderDataBase) SetModel(model matrix.Mat4) {
 	}
 }
 
+func (s *ShaderDataBase) Model() matrix.Mat4 { return s.model }
+
 func (s *ShaderDataBase) UpdateModel() {
 	if s.transform != nil && s.transform.IsDirty() {
 		s.model = s.initModel.Multiply(s.transform.WorldMatrix())
@@ -113,9 +117,14 @@ type DrawInstanceGroup struct {
 	instanceData []byte
 	instanceSize int
 	visibleCount int
+	culledCount  int
+	frustum      *collision.Frustum
 	padding      int
 	useBlending  bool
 	destroyed    bool
+	// Set from the drawing flags of the same names
+	noShadowCasting   bool
+	noShadowReceiving bool
 }
 
 func NewDrawInstanceGroup(mesh *Mesh, dataSize int) DrawInstanceGroup {
@@ -172,6 +181,18 @@ func (d *DrawInstanceGroup) texSize() (int32, int32) {
 }
 
 func (d *DrawInstanceGroup) VisibleCount() int { return d.visibleCount }
+func (d *DrawInstanceGroup) CulledCount() int  { return d.culledCount }
+
+func (d *DrawInstanceGroup) isCulled(instance DrawInstance) bool {
+	if d.frustum == nil {
+		return false
+	}
+	bounds, ok := d.Mesh.Bounds()
+	if !ok {
+		return false
+	}
+	return !d.frustum.IntersectsAABB(bounds.Transform(instance.Model()))
+}
 
 func (d *DrawInstanceGroup) VisibleSize() int {
 	return d.visibleCount * (d.instanceSize + d.padding)
@@ -182,6 +203,7 @@ func (d *DrawInstanceGroup) UpdateData(renderer Renderer) {
 	offset := uintptr(0)
 	count := len(d.Instances)
 	d.visibleCount = 0
+	d.culledCount = 0
 	for i := 0; i < count; i++ {
 		instance := d.Instances[i]
 		instance.UpdateModel()
@@ -189,13 +211,28 @@ func (d *DrawInstanceGroup) UpdateData(renderer Renderer) {
 			d.Instances[i] = d.Instances[count-1]
 			i--
 			count--
-		} else if instance.IsActive() {
+		} else if !instance.IsActive() {
+			continue
+		} else if d.isCulled(instance) {
+			d.culledCount++
+		} else {
 			to := unsafe.Pointer(uintptr(base) + offset)
 			klib.Memcpy(to, instance.DataPointer(), d.instanceSize)
 			offset += uintptr(d.instanceSize + d.padding)
 			d.visibleCount++
 		}
 	}
+	// The culled instances are kept after the visible ones, they are out
+	// of view but can still cast shadows into it
+	if d.culledCount > 0 {
+		for _, instance := range d.Instances[:count] {
+			if instance.IsActive() && d.isCulled(instance) {
+				to := unsafe.Pointer(uintptr(base) + offset)
+				klib.Memcpy(to, instance.DataPointer(), d.instanceSize)
+				offset += uintptr(d.instanceSize + d.padding)
+			}
+		}
+	}
 	if count < len(d.Instances) {
 		newMemLen := count * (d.instanceSize + d.padding)
 		d.Instances = d.Instances[:count]
@@ -38,6 +38,8 @@
 package rendering
 
 import (
+	"kaiju/cameras"
+	"kaiju/collision"
 	"kaiju/matrix"
 	"slices"
 	"sync"
@@ -51,13 +53,26 @@ type Drawing struct {
 	ShaderData  DrawInstance
 	Transform   *matrix.Transform
 	UseBlending bool
+	// NoShadowCasting keeps the drawing out of shadow maps and
+	// NoShadowReceiving keeps shadows from being drawn on it
+	NoShadowCasting   bool
+	NoShadowReceiving bool
 }
 
 func (d *Drawing) IsValid() bool { return d.Shader != nil }
 
+// DrawStats counts the instances of the last rendered frame that were sent
+// to the renderer and those that were skipped for being out of view
+type DrawStats struct {
+	Drawn  int
+	Culled int
+}
+
 type Drawings struct {
 	draws     []ShaderDraw
 	backDraws []Drawing
+	stats     DrawStats
+	frustum   collision.Frustum
 	mutex     sync.RWMutex
 }
 
@@ -94,7 +109,8 @@ func (d *Drawings) matchGroup(sd *ShaderDraw, dg *Drawing) int {
 	idx := -1
 	for i := 0; i < len(sd.instanceGroups) && idx < 0; i++ {
 		g := &sd.instanceGroups[i]
-		if g.Mesh == dg.Mesh && texturesMatch(g.Textures, dg.Textures) && dg.UseBlending == g.useBlending {
+		if g.Mesh == dg.Mesh && texturesMatch(g.Textures, dg.Textures) && dg.UseBlending == g.useBlending &&
+			dg.NoShadowCasting == g.noShadowCasting && dg.NoShadowReceiving == g.noShadowReceiving {
 			idx = i
 		}
 	}
@@ -121,6 +137,8 @@ func (d *Drawings) PreparePending() {
 			group.AddInstance(drawing.ShaderData, drawing.Renderer, drawing.Shader)
 			group.Textures = drawing.Textures
 			group.useBlending = drawing.UseBlending
+			group.noShadowCasting = drawing.NoShadowCasting
+			group.noShadowReceiving = drawing.NoShadowReceiving
 			if idx >= 0 {
 				draw.instanceGroups[idx] = group
 			} else {
@@ -143,16 +161,68 @@ func (d *Drawings) AddDrawings(drawings []Drawing) {
 	d.backDraws = append(d.backDraws, drawings...)
 }
 
-func (d *Drawings) Render(renderer Renderer) {
+func (d *Drawings) Stats() DrawStats { return d.stats }
+
+// Shaders returns the shaders of the drawings that have been prepared
+func (d *Drawings) Shaders() []*Shader {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	shaders := make([]*Shader, len(d.draws))
+	for i := range d.draws {
+		shaders[i] = d.draws[i].shader
+	}
+	return shaders
+}
+
+// setCulling points the instance groups of shaders that support culling at
+// the frustum, a nil frustum disables culling
+func (d *Drawings) setCulling(frustum *collision.Frustum) {
+	for i := range d.draws {
+		cull := frustum
+		if !d.draws[i].shader.FrustumCulling {
+			cull = nil
+		}
+		for j := range d.draws[i].instanceGroups {
+			d.draws[i].instanceGroups[j].frustum = cull
+		}
+	}
+}
+
+func (d *Drawings) updateStats() {
+	d.stats = DrawStats{}
+	for i := range d.draws {
+		for j := range d.draws[i].instanceGroups {
+			g := &d.draws[i].instanceGroups[j]
+			d.stats.Drawn += g.VisibleCount()
+			d.stats.Culled += g.CulledCount()
+		}
+	}
+}
+
+// Render draws all of the instances, instances of shaders with frustum
+// culling enabled are skipped when their mesh bounds are out of the view
+// of the camera. The effects of the post process stack, which may be nil,
+// are drawn over the frame before it is shown
+// Render draws into the default target, applies the post process stack and
+// presents the result. The target that was presented is returned, it is
+// the default target or the output of the stack
+func (d *Drawings) Render(renderer Renderer, camera cameras.Camera, post *PostProcessStack) RenderTarget {
+	d.frustum = camera.Frustum()
+	d.setCulling(&d.frustum)
 	renderer.Draw(d.draws)
+	target := post.applyOrWarn(renderer, renderer.DefaultTarget())
 	renderer.BlitTargets(RenderTargetDraw{
-		Target: renderer.DefaultTarget(),
+		Target: target,
 		Rect:   matrix.Vec4{0, 0, 1, 1},
 	})
+	d.updateStats()
+	return target
 }
 
 func (d *Drawings) RenderToTarget(renderer Renderer, target RenderTarget) {
+	d.setCulling(nil)
 	renderer.DrawToTarget(d.draws, target)
+	d.updateStats()
 }
 
 func (d *Drawings) Destroy(renderer Renderer) {
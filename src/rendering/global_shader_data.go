@@ -39,12 +39,26 @@ package rendering
 
 import "kaiju/matrix"
 
+// GlobalShaderData follows the std140 layout of the global uniform buffer
+// of the shaders, shaders only need to declare the fields up to the last
+// one they use
 type GlobalShaderData struct {
 	View             matrix.Mat4
 	Projection       matrix.Mat4
 	UIView           matrix.Mat4
 	UIProjection     matrix.Mat4
 	CameraPosition   matrix.Vec3
+	_                float32
 	UICameraPosition matrix.Vec3
 	Time             float32
+	ScreenSize       matrix.Vec2
+	LightCount       int32
+	_                int32
+	AmbientLight     matrix.Vec4
+	Lights           [MaxLights]LightShaderData
+	LightTiles       [LightTileCount / 4][4]uint32
+	// ShadowCascadeSplits is the view depth where each cascade of the main
+	// directional light ends
+	ShadowCascadeSplits matrix.Vec4
+	ShadowMatrices      [MaxShadowMaps]matrix.Mat4
 }
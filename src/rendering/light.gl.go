@@ -0,0 +1,72 @@
+//go:build OPENGL
+
+/*****************************************************************************/
+/* light.gl.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"fmt"
+	"kaiju/gl"
+)
+
+// setLightUniforms uploads the lights, the light tiles and the shadow
+// matrices of the global shader data, GLES 3.0 sets the members of the
+// global struct one by one
+func (r *GLRenderer) setLightUniforms(program gl.Handle) {
+	data := &r.globalShaderData
+	gl.Uniform2fv(gl.GetUniformLocation(program, "globalData.screenSize"), &data.ScreenSize)
+	gl.Uniform1i(gl.GetUniformLocation(program, "globalData.lightCount"), data.LightCount)
+	gl.Uniform4fv(gl.GetUniformLocation(program, "globalData.ambientLight"), &data.AmbientLight)
+	for i := range data.LightCount {
+		light := &data.Lights[i]
+		name := fmt.Sprintf("globalData.lights[%d].", i)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"position"), &light.Position)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"direction"), &light.Direction)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"color"), &light.Color)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"cone"), &light.Cone)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"shadow"), &light.Shadow)
+		gl.Uniform4fv(gl.GetUniformLocation(program, name+"shadowFilter"), &light.ShadowFilter)
+	}
+	gl.Uniform4uiv(gl.GetUniformLocation(program, "globalData.lightTiles"), data.LightTiles[:])
+	gl.Uniform4fv(gl.GetUniformLocation(program, "globalData.shadowCascadeSplits"),
+		&data.ShadowCascadeSplits)
+	for i := range data.ShadowMatrices {
+		loc := gl.GetUniformLocation(program, fmt.Sprintf("globalData.shadowMatrices[%d]", i))
+		gl.UniformMatrix4fv(loc, false, &data.ShadowMatrices[i])
+	}
+}
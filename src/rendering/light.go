@@ -0,0 +1,306 @@
+/*****************************************************************************/
+/* light.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"slices"
+	"sync"
+)
+
+type LightType = int32
+
+const (
+	LightTypeDirectional LightType = iota
+	LightTypePoint
+	LightTypeSpot
+)
+
+const (
+	// MaxLights is the most lights that can light a frame, each tile keeps
+	// the lights touching it as bits of a uint32
+	MaxLights = 32
+	// The screen is split into a fixed grid of tiles no matter its size,
+	// shaders only look at the lights in the tile of the fragment
+	LightTilesX    = 16
+	LightTilesY    = 9
+	LightTileCount = LightTilesX * LightTilesY
+)
+
+// Light is a light in the scene, when Transform is set the position and
+// direction of the light come from it. The light shines down the -Z axis
+// of the transform (matrix.Vec3Forward), toward what it was pointed at
+// with Entity.LookAt
+type Light struct {
+	Type      LightType
+	Color     matrix.Color
+	Intensity float32
+	// Range is the distance at which point and spot lights fade out
+	Range float32
+	// InnerAngle and OuterAngle are the half angles, in degrees, of the cone
+	// of a spot light. The light is full inside of the inner angle and fades
+	// out to nothing at the outer angle
+	InnerAngle float32
+	OuterAngle float32
+	Position   matrix.Vec3
+	Direction  matrix.Vec3
+	Transform  *matrix.Transform
+	Enabled    bool
+	// CastShadows draws shadow maps for a directional or spot light, point
+	// lights do not cast shadows
+	CastShadows bool
+	Shadows     ShadowSettings
+}
+
+// LightShaderData is a light as it is laid out in the global shader data
+type LightShaderData struct {
+	// xyz is the world position, w is the LightType
+	Position matrix.Vec4
+	// xyz is the direction the light shines, w is the range
+	Direction matrix.Vec4
+	// rgb is the color, a is the intensity
+	Color matrix.Vec4
+	// x is the cosine of the inner angle, y of the outer angle
+	Cone matrix.Vec4
+	// x is the index of the first shadow matrix (-1 without shadows), y is
+	// how many shadow maps the light has, z is the depth bias and w is the
+	// normal bias
+	Shadow matrix.Vec4
+	// x is the PCF radius in texels, y is the size of a texel
+	ShadowFilter matrix.Vec4
+}
+
+// Lights are the lights of a host, like Drawings they are gathered every
+// frame and given to the renderer through the global shader data
+type Lights struct {
+	lights  []*Light
+	passes  []ShadowPass
+	Ambient matrix.Color
+	mutex   sync.RWMutex
+}
+
+func NewLights() Lights {
+	return Lights{
+		lights:  make([]*Light, 0),
+		Ambient: matrix.Color{0.1, 0.1, 0.1, 1},
+	}
+}
+
+func NewDirectionalLight(direction matrix.Vec3, color matrix.Color, intensity float32) Light {
+	return Light{
+		Type:      LightTypeDirectional,
+		Color:     color,
+		Intensity: intensity,
+		Direction: direction.Normal(),
+		Enabled:   true,
+		Shadows:   DefaultShadowSettings(),
+	}
+}
+
+func NewPointLight(position matrix.Vec3, color matrix.Color, intensity, lightRange float32) Light {
+	return Light{
+		Type:      LightTypePoint,
+		Color:     color,
+		Intensity: intensity,
+		Range:     lightRange,
+		Position:  position,
+		Enabled:   true,
+		Shadows:   DefaultShadowSettings(),
+	}
+}
+
+func NewSpotLight(position, direction matrix.Vec3, color matrix.Color, intensity, lightRange, innerAngle, outerAngle float32) Light {
+	return Light{
+		Type:       LightTypeSpot,
+		Color:      color,
+		Intensity:  intensity,
+		Range:      lightRange,
+		InnerAngle: innerAngle,
+		OuterAngle: outerAngle,
+		Position:   position,
+		Direction:  direction.Normal(),
+		Enabled:    true,
+		Shadows:    DefaultShadowSettings(),
+	}
+}
+
+// WorldPosition is the position of the light, taken from its transform if
+// it has one
+func (l *Light) WorldPosition() matrix.Vec3 {
+	if l.Transform != nil {
+		return l.Transform.WorldPosition()
+	}
+	return l.Position
+}
+
+// WorldDirection is the direction the light shines, taken from its
+// transform if it has one
+func (l *Light) WorldDirection() matrix.Vec3 {
+	if l.Transform != nil {
+		return l.Transform.WorldMatrix().Forward().Negative()
+	}
+	return l.Direction
+}
+
+func (l *Light) shaderData() LightShaderData {
+	pos := l.WorldPosition()
+	dir := l.WorldDirection()
+	return LightShaderData{
+		Position:  matrix.Vec4{pos.X(), pos.Y(), pos.Z(), float32(l.Type)},
+		Direction: matrix.Vec4{dir.X(), dir.Y(), dir.Z(), l.Range},
+		Color:     matrix.Vec4{l.Color.R(), l.Color.G(), l.Color.B(), l.Intensity},
+		Cone: matrix.Vec4{
+			matrix.Cos(matrix.Deg2Rad(l.InnerAngle)),
+			matrix.Cos(matrix.Deg2Rad(l.OuterAngle)), 0, 0,
+		},
+		Shadow: matrix.Vec4{-1, 0, 0, 0},
+	}
+}
+
+func (l *Lights) Add(light *Light) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lights = append(l.lights, light)
+}
+
+func (l *Lights) Remove(light *Light) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lights = slices.DeleteFunc(l.lights, func(o *Light) bool { return o == light })
+}
+
+func (l *Lights) Count() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return len(l.lights)
+}
+
+// ShadowPasses are the shadow maps to draw for the frame, they are created
+// along with the global shader data of the frame
+func (l *Lights) ShadowPasses() []ShadowPass {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return append([]ShadowPass{}, l.passes...)
+}
+
+// lightTileRange finds the tiles covered by the sphere of a point or spot
+// light, everything is covered when the sphere is partly behind the camera
+func lightTileRange(viewProjection matrix.Mat4, pos matrix.Vec3, radius float32) (int, int, int, int) {
+	minX, minY := float32(1), float32(1)
+	maxX, maxY := float32(-1), float32(-1)
+	for i := 0; i < 8; i++ {
+		corner := matrix.Vec4{pos.X() - radius, pos.Y() - radius, pos.Z() - radius, 1}
+		if i&1 != 0 {
+			corner[matrix.Vx] += radius * 2
+		}
+		if i&2 != 0 {
+			corner[matrix.Vy] += radius * 2
+		}
+		if i&4 != 0 {
+			corner[matrix.Vz] += radius * 2
+		}
+		clip := viewProjection.MultiplyVec4(corner)
+		if clip.W() <= 0 {
+			return 0, 0, LightTilesX - 1, LightTilesY - 1
+		}
+		x, y := clip.X()/clip.W(), clip.Y()/clip.W()
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	if maxX < -1 || minX > 1 || maxY < -1 || minY > 1 {
+		return 0, 0, -1, -1
+	}
+	tile := func(ndc float32, count int) int {
+		return min(max(int((ndc*0.5+0.5)*float32(count)), 0), count-1)
+	}
+	return tile(minX, LightTilesX), tile(minY, LightTilesY),
+		tile(maxX, LightTilesX), tile(maxY, LightTilesY)
+}
+
+// fillShaderData writes the enabled lights into the global shader data,
+// marks the screen tiles each of them can reach and creates the shadow
+// passes of the frame
+func (l *Lights) fillShaderData(data *GlobalShaderData, camera cameras.Camera) {
+	data.AmbientLight = matrix.Vec4(l.Ambient)
+	data.LightCount = 0
+	data.LightTiles = [LightTileCount / 4][4]uint32{}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	viewProjection := data.View.Multiply(data.Projection)
+	active := make([]*Light, 0, len(l.lights))
+	for _, light := range l.lights {
+		if !light.Enabled || data.LightCount >= MaxLights {
+			continue
+		}
+		idx := data.LightCount
+		data.Lights[idx] = light.shaderData()
+		data.LightCount++
+		active = append(active, light)
+		x0, y0, x1, y1 := 0, 0, LightTilesX-1, LightTilesY-1
+		if light.Type != LightTypeDirectional {
+			x0, y0, x1, y1 = lightTileRange(viewProjection, light.WorldPosition(), light.Range)
+		}
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				t := y*LightTilesX + x
+				data.LightTiles[t/4][t%4] |= 1 << uint(idx)
+			}
+		}
+	}
+	l.passes = shadowPasses(active, data, camera, l.passes)
+}
+
+// NewGlobalShaderData creates the global shader data for the frame, lights
+// can be nil to draw without any
+func NewGlobalShaderData(camera, uiCamera cameras.Camera, lights *Lights, runtime float32) GlobalShaderData {
+	data := GlobalShaderData{
+		View:             camera.View(),
+		UIView:           uiCamera.View(),
+		Projection:       camera.Projection(),
+		UIProjection:     uiCamera.Projection(),
+		CameraPosition:   camera.Position(),
+		UICameraPosition: uiCamera.Position(),
+		Time:             runtime,
+		ScreenSize:       matrix.Vec2{camera.Width(), camera.Height()},
+	}
+	if lights != nil {
+		lights.fillShaderData(&data, camera)
+	}
+	return data
+}
@@ -0,0 +1,112 @@
+/*****************************************************************************/
+/* light_test.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"testing"
+)
+
+func softwareTestLitRender(r *SoftwareRenderer, d *Drawings, caches *softwareTestCaches, lights *Lights) {
+	camera := cameras.NewStandardCamera(64, 64, matrix.Vec3{0, 0, 2})
+	uiCamera := cameras.NewStandardCameraOrthographic(64, 64, matrix.Vec3{0, 0, 250})
+	caches.meshes.CreatePending()
+	d.PreparePending()
+	r.ReadyFrame(FrameData{Camera: camera, UICamera: uiCamera, Lights: lights})
+	d.Render(r, camera, nil)
+}
+
+func TestDirectionalLight(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	d := NewDrawings()
+	softwareTestQuad(r, &d, shader, NewMeshQuad(&caches.meshes), matrix.Vec3{}, matrix.ColorWhite())
+	lights := NewLights()
+	lights.Ambient = matrix.Color{0.2, 0.2, 0.2, 1}
+	sun := NewDirectionalLight(matrix.Vec3{0, 0, -1}, matrix.Color{1, 0, 0, 1}, 0.5)
+	lights.Add(&sun)
+	softwareTestLitRender(r, &d, caches, &lights)
+	softwareTestColor(t, r, 32, 32, matrix.Color{0.7, 0.2, 0.2, 1})
+	// Facing away from the surface only leaves the ambient light
+	sun.Direction = matrix.Vec3{0, 0, 1}
+	softwareTestLitRender(r, &d, caches, &lights)
+	softwareTestColor(t, r, 32, 32, matrix.Color{0.2, 0.2, 0.2, 1})
+	// Without any enabled lights the basic shader is unlit
+	sun.Enabled = false
+	softwareTestLitRender(r, &d, caches, &lights)
+	softwareTestColor(t, r, 32, 32, matrix.ColorWhite())
+}
+
+func TestPointLightFalloff(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	d := NewDrawings()
+	softwareTestQuad(r, &d, shader, NewMeshQuad(&caches.meshes), matrix.Vec3{}, matrix.ColorWhite())
+	lights := NewLights()
+	lights.Ambient = matrix.Color{}
+	bulb := NewPointLight(matrix.Vec3{0, 0, 0.25}, matrix.ColorWhite(), 1, 0.5)
+	lights.Add(&bulb)
+	softwareTestLitRender(r, &d, caches, &lights)
+	// Half way to its range the light is (1-0.5²)² as strong
+	softwareTestColor(t, r, 32, 32, matrix.Color{0.5625, 0.5625, 0.5625, 1})
+	// The corner of the quad is out of the range of the light
+	softwareTestColor(t, r, 20, 20, matrix.Color{0, 0, 0, 1})
+}
+
+func TestLightTiles(t *testing.T) {
+	camera := cameras.NewStandardCamera(160, 90, matrix.Vec3{0, 0, 5})
+	uiCamera := cameras.NewStandardCameraOrthographic(160, 90, matrix.Vec3{0, 0, 250})
+	lights := NewLights()
+	left := NewPointLight(matrix.Vec3{-4.5, 0, 0}, matrix.ColorWhite(), 1, 0.5)
+	sun := NewDirectionalLight(matrix.Vec3{0, -1, 0}, matrix.ColorWhite(), 1)
+	lights.Add(&left)
+	lights.Add(&sun)
+	data := NewGlobalShaderData(camera, uiCamera, &lights, 0)
+	if data.LightCount != 2 {
+		t.Fatalf("expected 2 lights, got %d", data.LightCount)
+	}
+	tileMask := func(x, y int) uint32 {
+		tile := y*LightTilesX + x
+		return data.LightTiles[tile/4][tile%4]
+	}
+	if tileMask(0, LightTilesY/2) != 0b11 {
+		t.Fatalf("expected both lights on the left, got %b", tileMask(0, LightTilesY/2))
+	}
+	if tileMask(LightTilesX-1, LightTilesY/2) != 0b10 {
+		t.Fatalf("expected only the directional light on the right, got %b", tileMask(LightTilesX-1, LightTilesY/2))
+	}
+}
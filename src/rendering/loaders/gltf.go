@@ -40,12 +40,15 @@ package loaders
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"kaiju/assets"
 	"kaiju/klib"
 	"kaiju/matrix"
 	"kaiju/rendering"
 	"kaiju/rendering/loaders/gltf"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"unsafe"
 )
 
@@ -142,20 +145,20 @@ func GLTF(renderer rendering.Renderer, path string, assetDB *assets.Database) (R
 		if g, err := readFileGLB(path, assetDB); err != nil {
 			return Result{}, err
 		} else {
-			return gltfParse(&g)
+			return gltfParse(&g, filepath.Dir(path))
 		}
 	} else if filepath.Ext(path) == ".gltf" {
 		if g, err := readFileGLTF(path, assetDB); err != nil {
 			return Result{}, err
 		} else {
-			return gltfParse(&g)
+			return gltfParse(&g, filepath.Dir(path))
 		}
 	} else {
 		return Result{}, errors.New("invalid file extension")
 	}
 }
 
-func gltfParse(doc *fullGLTF) (Result, error) {
+func gltfParse(doc *fullGLTF, root string) (Result, error) {
 	res := NewResult()
 	for i := range doc.glTF.Meshes {
 		mesh := &doc.glTF.Meshes[i]
@@ -163,9 +166,42 @@ func gltfParse(doc *fullGLTF) (Result, error) {
 			return res, err
 		} else if indices, err := gltfReadMeshIndices(mesh, doc); err != nil {
 			return res, err
+		} else if targets, err := gltfReadMeshMorphTargets(mesh, doc, verts); err != nil {
+			return res, err
 		} else {
-			textures := gltfReadMeshTextures(mesh, &doc.glTF)
-			res.Add(mesh.Name, verts, indices, klib.MapValues(textures))
+			material, materialName := gltfReadMeshMaterial(mesh, &doc.glTF, root)
+			textures := make([]string, 0, len(material.Textures))
+			for _, t := range material.Textures {
+				textures = append(textures, t.Texture)
+			}
+			res.Add(mesh.Name, verts, indices, textures)
+			res.Meshes[len(res.Meshes)-1].Material = &material
+			res.Meshes[len(res.Meshes)-1].MaterialName = materialName
+			res.Meshes[len(res.Meshes)-1].MorphTargets = targets
+			weights := make([]matrix.Float, len(targets))
+			for j := 0; j < len(weights) && j < len(mesh.Weights); j++ {
+				weights[j] = matrix.Float(mesh.Weights[j])
+			}
+			res.Meshes[len(res.Meshes)-1].MorphWeights = weights
+		}
+	}
+	if err := gltfReadSkins(doc, &res); err != nil {
+		return res, err
+	}
+	if err := gltfReadAnimations(doc, &res); err != nil {
+		return res, err
+	}
+	for i := range doc.glTF.Nodes {
+		node := &doc.glTF.Nodes[i]
+		if node.Mesh < 0 || int(node.Mesh) >= len(res.Meshes) {
+			continue
+		}
+		m := &res.Meshes[node.Mesh]
+		if m.Node < 0 {
+			m.Node = i
+		}
+		if node.Skin != nil {
+			m.Skin = int(*node.Skin)
 		}
 	}
 	return res, nil
@@ -180,95 +216,107 @@ func gltfViewBytes(doc *fullGLTF, view *gltf.BufferView) []byte {
 	return doc.bins[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength]
 }
 
-func gltfReadMeshMorphTargets(mesh *gltf.Mesh, doc *fullGLTF, verts []rendering.Vertex) klib.ErrorList {
-	errs := klib.NewErrorList()
-	for _, target := range mesh.Primitives[0].Targets {
-		if target.POSITION == nil {
-			continue
+// gltfReadMeshMorphTargets reads the position and normal deltas of every
+// target, the position delta of the first target is also kept in
+// Vertex.MorphTarget
+func gltfReadMeshMorphTargets(mesh *gltf.Mesh, doc *fullGLTF, verts []rendering.Vertex) ([]ResultMorphTarget, error) {
+	readDeltas := func(idx *int32) ([]matrix.Vec3, error) {
+		if idx == nil {
+			return nil, nil
 		}
-		acc := doc.glTF.Accessors[*target.POSITION]
-		if len(doc.glTF.BufferViews) <= int(acc.BufferView) {
-			errs.AddAny(errors.New("invalid buffer view index"))
+		floats, components, err := gltfReadAccessor(doc, *idx)
+		if err != nil {
+			return nil, err
+		} else if components != 3 || len(floats) != len(verts)*3 {
+			return nil, errors.New("morph targets do not match vert count")
 		}
-		view := doc.glTF.BufferViews[acc.BufferView]
-		if acc.Count <= 0 {
-			errs.AddAny(errors.New("invalid accessor count"))
-			continue
+		deltas := make([]matrix.Vec3, len(verts))
+		for i := range deltas {
+			deltas[i] = matrix.Vec3{floats[i*3+0], floats[i*3+1], floats[i*3+2]}
 		}
-		targets := gltfViewBytes(doc, &view)
-		const v3Size = int(unsafe.Sizeof([3]float32{}))
-		if int(acc.Count) != len(verts) || len(targets)/v3Size != len(verts) {
-			errs.AddAny(errors.New("morph targets do not match vert count"))
-			continue
+		return deltas, nil
+	}
+	targets := make([]ResultMorphTarget, 0, len(mesh.Primitives[0].Targets))
+	for i, target := range mesh.Primitives[0].Targets {
+		t := ResultMorphTarget{}
+		if i < len(mesh.Extras.TargetNames) {
+			t.Name = mesh.Extras.TargetNames[i]
 		}
-		floats := klib.ConvertByteSliceType[float32](targets)
-		for i := 0; i < len(verts); i++ {
-			verts[i].MorphTarget = matrix.Vec3{
-				floats[i*3+0],
-				floats[i*3+1],
-				floats[i*3+2],
-			}
+		var err error
+		if t.Positions, err = readDeltas(target.POSITION); err != nil {
+			return targets, err
+		}
+		if t.Normals, err = readDeltas(target.NORMAL); err != nil {
+			return targets, err
+		}
+		if t.Positions == nil {
+			t.Positions = make([]matrix.Vec3, len(verts))
 		}
+		targets = append(targets, t)
 	}
-	return errs
+	if len(targets) > 0 {
+		for i := range verts {
+			verts[i].MorphTarget = targets[0].Positions[i]
+		}
+	}
+	return targets, nil
 }
 
 func gltfReadMeshVerts(mesh *gltf.Mesh, doc *fullGLTF) ([]rendering.Vertex, error) {
-	var pos, nml, tan, tex0, tex1, jnt0, wei0 *gltf.BufferView
-	var posAcc, nmlAcc, tanAcc, tex0Acc, tex1Acc, jnt0Acc, wei0Acc *gltf.Accessor
+	var pos, nml, tan, tex0, tex1 *gltf.BufferView
+	var posAcc, nmlAcc, tanAcc, tex0Acc, tex1Acc *gltf.Accessor
 	g := &doc.glTF
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.POSITION); ok {
-		pos = &g.BufferViews[idx]
 		posAcc = &g.Accessors[idx]
+		pos = &g.BufferViews[posAcc.BufferView]
 	}
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.NORMAL); ok {
-		nml = &g.BufferViews[idx]
 		nmlAcc = &g.Accessors[idx]
+		nml = &g.BufferViews[nmlAcc.BufferView]
 	}
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.TANGENT); ok {
-		tan = &g.BufferViews[idx]
 		tanAcc = &g.Accessors[idx]
+		tan = &g.BufferViews[tanAcc.BufferView]
 	}
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.TEXCOORD_0); ok {
-		tex0 = &g.BufferViews[idx]
 		tex0Acc = &g.Accessors[idx]
+		tex0 = &g.BufferViews[tex0Acc.BufferView]
 	}
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.TEXCOORD_1); ok {
-		tex1 = &g.BufferViews[idx]
 		tex1Acc = &g.Accessors[idx]
+		tex1 = &g.BufferViews[tex1Acc.BufferView]
 	}
+	var jointIds, weights []matrix.Float
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.JOINTS_0); ok {
-		jnt0 = &g.BufferViews[idx]
-		jnt0Acc = &g.Accessors[idx]
+		var err error
+		if jointIds, _, err = gltfReadAccessor(doc, int32(idx)); err != nil {
+			return []rendering.Vertex{}, err
+		}
 	}
 	if idx, ok := gltfAttr(mesh.Primitives, gltf.WEIGHTS_0); ok {
-		wei0 = &g.BufferViews[idx]
-		wei0Acc = &g.Accessors[idx]
+		var err error
+		if weights, _, err = gltfReadAccessor(doc, int32(idx)); err != nil {
+			return []rendering.Vertex{}, err
+		}
 	}
 
 	// TODO:  Probably need to support multiple buffers, but they are NULL?
-	verts := gltfViewBytes(doc, pos)
-	vertNormals := gltfViewBytes(doc, nml)
+	verts := gltfViewBytes(doc, pos)[posAcc.ByteOffset:]
+	vertNormals := gltfViewBytes(doc, nml)[nmlAcc.ByteOffset:]
 	var texCoords0 []byte
 	var tangent []byte
 	if tex0 != nil {
-		texCoords0 = gltfViewBytes(doc, tex0)
+		texCoords0 = gltfViewBytes(doc, tex0)[tex0Acc.ByteOffset:]
 	} else {
 		texCoords0 = nil
 	}
 	if tan != nil {
-		tangent = gltfViewBytes(doc, tan)
+		tangent = gltfViewBytes(doc, tan)[tanAcc.ByteOffset:]
 	} else {
 		tangent = nil
 	}
 	//const uint8_t* vertColors = col0 != NULL
 	//	? (uint8_t*)gltfData.bin + col0.data.buffer_view.offset : NULL;
-	jointIds := make([]byte, 0)
-	weights := make([]byte, 0)
-	if jnt0 != nil {
-		jointIds = gltfViewBytes(doc, jnt0)
-		weights = gltfViewBytes(doc, wei0)
-	}
 
 	//size_t vertNormalsSize = nml.data.buffer_view.size;
 	//size_t texCoords0Size = tex0.data.buffer_view.size;
@@ -282,8 +330,8 @@ func gltfReadMeshVerts(mesh *gltf.Mesh, doc *fullGLTF) ([]rendering.Vertex, erro
 	if !(posAcc.ComponentType == gltf.FLOAT && posAcc.Type == gltf.VEC3) {
 		return []rendering.Vertex{}, errors.New("posAcc.ComponentType != gltf.ComponentFloat || posAcc.Type != gltf.AccessorVec3")
 	}
-	if !(wei0 == nil || wei0Acc.ComponentType == gltf.FLOAT && wei0Acc.Type == gltf.VEC4) {
-		return []rendering.Vertex{}, errors.New("wei0 == NULL || wei0Acc.ComponentType == gltf.ComponentFloat && wei0Acc.Type == gltf.AccessorVec4")
+	if !(len(jointIds) == 0 || len(jointIds) == int(vertCount)*4 && len(weights) == len(jointIds)) {
+		return []rendering.Vertex{}, errors.New("JOINTS_0 and WEIGHTS_0 must have a vec4 for every vertex")
 	}
 	if !(nmlAcc.ComponentType == gltf.FLOAT && nmlAcc.Type == gltf.VEC3) {
 		return []rendering.Vertex{}, errors.New("nmlAcc.ComponentType != gltf.ComponentFloat || nmlAcc.Type != gltf.AccessorVec3")
@@ -309,29 +357,11 @@ func gltfReadMeshVerts(mesh *gltf.Mesh, doc *fullGLTF) ([]rendering.Vertex, erro
 		// NAN is being exported for colors, so skipping this line
 		//vertData[j].color = (vertColors != NULL ? ((color*)vertColors)[j] : color_white());
 		vertData[i].Color.MultiplyAssign(vertColor)
-		joint := [4]int{0, 0, 0, 0}
-		const jointSize = int(unsafe.Sizeof(joint))
 		if len(jointIds) > 0 {
-			switch jnt0Acc.ComponentType {
-			case gltf.UNSIGNED_BYTE:
-				ptr := jointIds[i*4:]
-				joint[0] = int(ptr[0])
-				joint[1] = int(ptr[1])
-				joint[2] = int(ptr[2])
-				joint[3] = int(ptr[3])
-			case gltf.UNSIGNED_SHORT:
-				ptr := jointIds[i*4*2:]
-				joint[0] = int(ptr[0])
-				joint[1] = int(ptr[1])
-				joint[2] = int(ptr[2])
-				joint[3] = int(ptr[3])
-			default:
-				klib.Memcpy(unsafe.Pointer(&joint[0]), unsafe.Pointer(&jointIds[i]), jointSize)
+			for j := range 4 {
+				vertData[i].JointIds[j] = int32(jointIds[i*4+int32(j)])
+				vertData[i].JointWeights[j] = weights[i*4+int32(j)]
 			}
-		}
-		klib.Memcpy(unsafe.Pointer(&vertData[i].JointIds), unsafe.Pointer(&joint[0]), jointSize)
-		if len(weights) > 0 {
-			klib.Memcpy(unsafe.Pointer(&vertData[i].JointWeights), unsafe.Pointer(&weights[i*v4size]), int(v4size))
 		} else {
 			vertData[i].JointWeights = matrix.Vec4Zero()
 		}
@@ -353,16 +383,15 @@ func gltfReadMeshVerts(mesh *gltf.Mesh, doc *fullGLTF) ([]rendering.Vertex, erro
 			vertData[i].UV0[matrix.Vy] -= 1.0
 		}
 	}
-	errs := gltfReadMeshMorphTargets(mesh, doc, vertData)
-	return vertData, errs.First()
+	return vertData, nil
 }
 
 func gltfReadMeshIndices(mesh *gltf.Mesh, doc *fullGLTF) ([]uint32, error) {
 	idx := mesh.Primitives[0].Indices
-	view := doc.glTF.BufferViews[idx]
 	acc := doc.glTF.Accessors[idx]
-	indices := doc.bins[view.Buffer][view.ByteOffset:]
-	indicesSize := view.ByteLength
+	view := doc.glTF.BufferViews[acc.BufferView]
+	indices := doc.bins[view.Buffer][view.ByteOffset+acc.ByteOffset:]
+	indicesSize := acc.Count * int32(gltfComponentSize(acc.ComponentType))
 	if !(indicesSize > 0) {
 		return []uint32{}, errors.New("indicesCount > 0")
 	}
@@ -400,26 +429,85 @@ func gltfReadMeshIndices(mesh *gltf.Mesh, doc *fullGLTF) ([]uint32, error) {
 	return convertedIndices, nil
 }
 
-func gltfReadMeshTextures(mesh *gltf.Mesh, doc *gltf.GLTF) map[string]string {
-	textures := make(map[string]string)
-	if len(doc.Materials) == 0 || mesh.Primitives[0].Material == nil {
-		return textures
+// gltfTextureKey is the asset key and filter of the texture, images that
+// are embedded in the file are not supported and are left out
+func gltfTextureKey(doc *gltf.GLTF, root string, id *gltf.TextureId) (rendering.MaterialTextureData, bool) {
+	if id == nil || id.Index < 0 || int(id.Index) >= len(doc.Textures) {
+		return rendering.MaterialTextureData{}, false
 	}
-	mat := doc.Materials[*mesh.Primitives[0].Material]
-	if mat.PBRMetallicRoughness.BaseColorTexture != nil {
-		textures["baseColor"] = doc.Images[mat.PBRMetallicRoughness.BaseColorTexture.Index].URI
+	tex := doc.Textures[id.Index]
+	if tex.Source < 0 || int(tex.Source) >= len(doc.Images) {
+		return rendering.MaterialTextureData{}, false
 	}
-	if mat.PBRMetallicRoughness.MetallicRoughnessTexture != nil {
-		textures["metallicRoughness"] = doc.Images[mat.PBRMetallicRoughness.MetallicRoughnessTexture.Index].URI
+	uri := doc.Images[tex.Source].URI
+	if uri == "" || strings.HasPrefix(uri, "data:") {
+		return rendering.MaterialTextureData{}, false
 	}
-	if mat.NormalTexture != nil {
-		textures["normal"] = doc.Images[mat.NormalTexture.Index].URI
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
 	}
-	if mat.OcclusionTexture != nil {
-		textures["occlusion"] = doc.Images[mat.OcclusionTexture.Index].URI
+	data := rendering.MaterialTextureData{
+		Texture: filepath.ToSlash(filepath.Join(root, uri)),
+		Filter:  "Linear",
 	}
-	if mat.EmissiveTexture != nil {
-		textures["emissive"] = doc.Images[mat.EmissiveTexture.Index].URI
+	if tex.Sampler >= 0 && int(tex.Sampler) < len(doc.Samplers) &&
+		doc.Samplers[tex.Sampler].MagFilter == gltf.NEAREST {
+		data.Filter = "Nearest"
 	}
-	return textures
+	return data, true
+}
+
+// gltfReadMeshMaterial converts the material of the mesh into a PBR
+// material, root is the folder of the glTF file that texture URIs are
+// relative to. Meshes without a material get the default glTF material
+func gltfReadMeshMaterial(mesh *gltf.Mesh, doc *gltf.GLTF, root string) (rendering.MaterialData, string) {
+	data := rendering.NewPBRMaterialData()
+	mat := gltf.DefaultMaterial()
+	name := "default"
+	if idx := mesh.Primitives[0].Material; idx != nil && int(*idx) < len(doc.Materials) {
+		mat = doc.Materials[*idx]
+		name = mat.Name
+		if name == "" {
+			name = fmt.Sprintf("material_%d", *idx)
+		}
+	}
+	pbr := &mat.PBRMetallicRoughness
+	slots := []struct {
+		slot int
+		id   *gltf.TextureId
+	}{
+		{rendering.PBRTextureBaseColor, pbr.BaseColorTexture},
+		{rendering.PBRTextureMetallicRoughness, pbr.MetallicRoughnessTexture},
+		{rendering.PBRTextureNormal, mat.NormalTexture},
+		{rendering.PBRTextureOcclusion, mat.OcclusionTexture},
+		{rendering.PBRTextureEmissive, mat.EmissiveTexture},
+	}
+	for _, s := range slots {
+		if tex, ok := gltfTextureKey(doc, root, s.id); ok {
+			data.Textures[s.slot] = tex
+		}
+	}
+	normalScale, occlusionStrength := float32(1), float32(1)
+	if mat.NormalTexture != nil {
+		normalScale = mat.NormalTexture.Scale
+	}
+	if mat.OcclusionTexture != nil {
+		occlusionStrength = mat.OcclusionTexture.Strength
+	}
+	alphaMode := rendering.PBRAlphaOpaque
+	switch mat.AlphaMode {
+	case gltf.MASK:
+		alphaMode = rendering.PBRAlphaMask
+	case gltf.BLEND:
+		alphaMode = rendering.PBRAlphaBlend
+		data.UseBlending = true
+	}
+	c, e := pbr.BaseColorFactor, mat.EmissiveFactor
+	data.Parameters[rendering.PBRParamBaseColor] = []matrix.Float{c[0], c[1], c[2], c[3]}
+	data.Parameters[rendering.PBRParamEmissive] = []matrix.Float{e[0], e[1], e[2], 1}
+	data.Parameters[rendering.PBRParamFactors] = []matrix.Float{
+		pbr.MetallicFactor, pbr.RoughnessFactor, normalScale, occlusionStrength}
+	data.Parameters[rendering.PBRParamSurface] = []matrix.Float{
+		1, matrix.Float(alphaMode), mat.AlphaCutoff, 0}
+	return data, name
 }
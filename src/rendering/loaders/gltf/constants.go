@@ -73,3 +73,35 @@ const (
 	MAT3   AccessorType = "MAT3"
 	MAT4   AccessorType = "MAT4"
 )
+
+type AlphaMode = string
+
+const (
+	OPAQUE AlphaMode = "OPAQUE"
+	MASK   AlphaMode = "MASK"
+	BLEND  AlphaMode = "BLEND"
+)
+
+type SamplerFilter = int32
+
+const (
+	NEAREST SamplerFilter = 9728
+	LINEAR  SamplerFilter = 9729
+)
+
+type AnimationPath = string
+
+const (
+	PATH_TRANSLATION AnimationPath = "translation"
+	PATH_ROTATION    AnimationPath = "rotation"
+	PATH_SCALE       AnimationPath = "scale"
+	PATH_WEIGHTS     AnimationPath = "weights"
+)
+
+type Interpolation = string
+
+const (
+	INTERPOLATION_LINEAR      Interpolation = "LINEAR"
+	INTERPOLATION_STEP        Interpolation = "STEP"
+	INTERPOLATION_CUBICSPLINE Interpolation = "CUBICSPLINE"
+)
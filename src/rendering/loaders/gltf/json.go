@@ -54,15 +54,76 @@ type Scene struct {
 }
 
 type Node struct {
+	Name     string  `json:"name"`
+	Mesh     int32   `json:"mesh"`
+	Skin     *int32  `json:"skin"`
+	Children []int32 `json:"children"`
+	// Matrix is only set when the node gives its transform as a matrix,
+	// otherwise Translation, Rotation (x, y, z, w) and Scale are used
+	Matrix      []float32  `json:"matrix"`
+	Translation [3]float32 `json:"translation"`
+	Rotation    [4]float32 `json:"rotation"`
+	Scale       [3]float32 `json:"scale"`
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	type plain Node
+	v := plain{Mesh: -1, Rotation: [4]float32{0, 0, 0, 1}, Scale: [3]float32{1, 1, 1}}
+	err := json.Unmarshal(data, &v)
+	*n = Node(v)
+	return err
+}
+
+type Skin struct {
 	Name string `json:"name"`
-	Mesh int32  `json:"mesh"`
+	// InverseBindMatrices is the accessor of the matrices, without it they
+	// are all identity
+	InverseBindMatrices *int32  `json:"inverseBindMatrices"`
+	Skeleton            *int32  `json:"skeleton"`
+	Joints              []int32 `json:"joints"`
+}
+
+type AnimationTarget struct {
+	Node *int32        `json:"node"`
+	Path AnimationPath `json:"path"`
+}
+
+type AnimationChannel struct {
+	Sampler int32           `json:"sampler"`
+	Target  AnimationTarget `json:"target"`
+}
+
+type AnimationSampler struct {
+	Input         int32         `json:"input"`
+	Output        int32         `json:"output"`
+	Interpolation Interpolation `json:"interpolation"`
+}
+
+func (s *AnimationSampler) UnmarshalJSON(data []byte) error {
+	type plain AnimationSampler
+	v := plain{Interpolation: INTERPOLATION_LINEAR}
+	err := json.Unmarshal(data, &v)
+	*s = AnimationSampler(v)
+	return err
+}
+
+type Animation struct {
+	Name     string             `json:"name"`
+	Channels []AnimationChannel `json:"channels"`
+	Samplers []AnimationSampler `json:"samplers"`
 }
 
 type TextureId struct {
-	Index int32 `json:"index"`
+	Index    int32 `json:"index"`
+	TexCoord int32 `json:"texCoord"`
+	// Scale is only used by normal textures and Strength by occlusion
+	// textures, both default to 1
+	Scale    float32 `json:"scale"`
+	Strength float32 `json:"strength"`
 }
 
 type PBRMetallicRoughness struct {
+	BaseColorFactor          [4]float32 `json:"baseColorFactor"`
 	BaseColorTexture         *TextureId `json:"baseColorTexture"`
 	MetallicRoughnessTexture *TextureId `json:"metallicRoughnessTexture"`
 	MetallicFactor           float32    `json:"metallicFactor"`
@@ -72,12 +133,59 @@ type PBRMetallicRoughness struct {
 type Materials struct {
 	Name                 string               `json:"name"`
 	DoubleSided          bool                 `json:"doubleSided"`
+	AlphaMode            AlphaMode            `json:"alphaMode"`
+	AlphaCutoff          float32              `json:"alphaCutoff"`
+	EmissiveFactor       [3]float32           `json:"emissiveFactor"`
 	NormalTexture        *TextureId           `json:"normalTexture"`
 	OcclusionTexture     *TextureId           `json:"occlusionTexture"`
 	EmissiveTexture      *TextureId           `json:"emissiveTexture"`
 	PBRMetallicRoughness PBRMetallicRoughness `json:"pbrMetallicRoughness"`
 }
 
+// The UnmarshalJSON functions fill in the default values the glTF spec
+// gives to properties that are left out of the file
+
+func (t *TextureId) UnmarshalJSON(data []byte) error {
+	type plain TextureId
+	v := plain{Scale: 1, Strength: 1}
+	err := json.Unmarshal(data, &v)
+	*t = TextureId(v)
+	return err
+}
+
+func defaultPBRMetallicRoughness() PBRMetallicRoughness {
+	return PBRMetallicRoughness{
+		BaseColorFactor: [4]float32{1, 1, 1, 1},
+		MetallicFactor:  1,
+		RoughnessFactor: 1,
+	}
+}
+
+func (p *PBRMetallicRoughness) UnmarshalJSON(data []byte) error {
+	type plain PBRMetallicRoughness
+	v := plain(defaultPBRMetallicRoughness())
+	err := json.Unmarshal(data, &v)
+	*p = PBRMetallicRoughness(v)
+	return err
+}
+
+// DefaultMaterial is the material glTF uses for meshes without one
+func DefaultMaterial() Materials {
+	return Materials{
+		AlphaMode:            OPAQUE,
+		AlphaCutoff:          0.5,
+		PBRMetallicRoughness: defaultPBRMetallicRoughness(),
+	}
+}
+
+func (m *Materials) UnmarshalJSON(data []byte) error {
+	type plain Materials
+	v := plain(DefaultMaterial())
+	err := json.Unmarshal(data, &v)
+	*m = Materials(v)
+	return err
+}
+
 type Target struct {
 	POSITION   *int32 `json:"POSITION"`
 	NORMAL     *int32 `json:"NORMAL"`
@@ -98,16 +206,34 @@ type Primitive struct {
 	Extras     interface{}       `json:"extras"`
 }
 
+type MeshExtras struct {
+	// TargetNames are the names of the morph targets, most exporters write
+	// them here as glTF has no place for them
+	TargetNames []string `json:"targetNames"`
+}
+
 type Mesh struct {
 	Name       string      `json:"name"`
 	Primitives []Primitive `json:"primitives"`
+	// Weights are the default weights of the morph targets
+	Weights []float32  `json:"weights"`
+	Extras  MeshExtras `json:"extras"`
 }
 
 type Texture struct {
+	// Sampler is -1 when the texture does not name a sampler
 	Sampler int32 `json:"sampler"`
 	Source  int32 `json:"source"`
 }
 
+func (t *Texture) UnmarshalJSON(data []byte) error {
+	type plain Texture
+	v := plain{Sampler: -1, Source: -1}
+	err := json.Unmarshal(data, &v)
+	*t = Texture(v)
+	return err
+}
+
 type Image struct {
 	Name     string `json:"name"`
 	URI      string `json:"uri"`
@@ -116,7 +242,9 @@ type Image struct {
 
 type Accessor struct {
 	BufferView    int32         `json:"bufferView"`
+	ByteOffset    int32         `json:"byteOffset"`
 	ComponentType ComponentType `json:"componentType"`
+	Normalized    bool          `json:"normalized"`
 	Count         int32         `json:"count"`
 	Max           matrix.Vec3   `json:"max"`
 	Min           matrix.Vec3   `json:"min"`
@@ -127,6 +255,8 @@ type BufferView struct {
 	Buffer     int32 `json:"buffer"`
 	ByteLength int32 `json:"byteLength"`
 	ByteOffset int32 `json:"byteOffset"`
+	// ByteStride is 0 when the elements are tightly packed
+	ByteStride int32 `json:"byteStride"`
 	Target     int32 `json:"target"`
 }
 
@@ -155,6 +285,8 @@ type GLTF struct {
 	BufferViews []BufferView `json:"bufferViews"`
 	Samplers    []Sampler    `json:"samplers"`
 	Buffers     []Buffer     `json:"buffers"`
+	Skins       []Skin       `json:"skins"`
+	Animations  []Animation  `json:"animations"`
 }
 
 func LoadGLTF(jsonStr string) (GLTF, error) {
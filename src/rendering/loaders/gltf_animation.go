@@ -0,0 +1,296 @@
+/*****************************************************************************/
+/* gltf_animation.go                                                         */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package loaders
+
+import (
+	"encoding/binary"
+	"errors"
+	"kaiju/matrix"
+	"kaiju/rendering/loaders/gltf"
+	"math"
+)
+
+func gltfAccessorComponents(t gltf.AccessorType) int {
+	switch t {
+	case gltf.SCALAR:
+		return 1
+	case gltf.VEC2:
+		return 2
+	case gltf.VEC3:
+		return 3
+	case gltf.VEC4, gltf.MAT2:
+		return 4
+	case gltf.MAT3:
+		return 9
+	case gltf.MAT4:
+		return 16
+	default:
+		return 0
+	}
+}
+
+func gltfComponentSize(t gltf.ComponentType) int {
+	switch t {
+	case gltf.BYTE, gltf.UNSIGNED_BYTE:
+		return 1
+	case gltf.SHORT, gltf.UNSIGNED_SHORT:
+		return 2
+	case gltf.UNSIGNED_INT, gltf.FLOAT:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// gltfReadAccessor reads every element of the accessor as floats along with
+// how many floats make up an element. Normalized integers are mapped to 0..1
+// (or -1..1 when signed) and other integers keep their value
+func gltfReadAccessor(doc *fullGLTF, idx int32) ([]matrix.Float, int, error) {
+	if idx < 0 || int(idx) >= len(doc.glTF.Accessors) {
+		return nil, 0, errors.New("invalid accessor index")
+	}
+	acc := &doc.glTF.Accessors[idx]
+	components := gltfAccessorComponents(acc.Type)
+	size := gltfComponentSize(acc.ComponentType)
+	if components == 0 || size == 0 {
+		return nil, 0, errors.New("invalid accessor type")
+	}
+	out := make([]matrix.Float, int(acc.Count)*components)
+	if acc.BufferView < 0 || int(acc.BufferView) >= len(doc.glTF.BufferViews) {
+		// Accessors without a buffer view are all zeros
+		return out, components, nil
+	}
+	view := &doc.glTF.BufferViews[acc.BufferView]
+	data := gltfViewBytes(doc, view)
+	stride := int(view.ByteStride)
+	if stride == 0 {
+		stride = components * size
+	}
+	if acc.Count > 0 && int(acc.ByteOffset)+(int(acc.Count)-1)*stride+components*size > len(data) {
+		return nil, 0, errors.New("accessor is outside of its buffer view")
+	}
+	le := binary.LittleEndian
+	for i := range int(acc.Count) {
+		elm := data[int(acc.ByteOffset)+i*stride:]
+		for c := range components {
+			var v matrix.Float
+			b := elm[c*size:]
+			switch acc.ComponentType {
+			case gltf.BYTE:
+				v = matrix.Float(int8(b[0]))
+				if acc.Normalized {
+					v = max(v/127, -1)
+				}
+			case gltf.UNSIGNED_BYTE:
+				v = matrix.Float(b[0])
+				if acc.Normalized {
+					v /= 255
+				}
+			case gltf.SHORT:
+				v = matrix.Float(int16(le.Uint16(b)))
+				if acc.Normalized {
+					v = max(v/32767, -1)
+				}
+			case gltf.UNSIGNED_SHORT:
+				v = matrix.Float(le.Uint16(b))
+				if acc.Normalized {
+					v /= 65535
+				}
+			case gltf.UNSIGNED_INT:
+				v = matrix.Float(le.Uint32(b))
+			case gltf.FLOAT:
+				v = matrix.Float(math.Float32frombits(le.Uint32(b)))
+			}
+			out[i*components+c] = v
+		}
+	}
+	return out, components, nil
+}
+
+// gltfNodeTRS is the local transform of the node, matrices are split into
+// their translation, rotation and scale
+func gltfNodeTRS(node *gltf.Node) (matrix.Vec3, matrix.Quaternion, matrix.Vec3) {
+	if len(node.Matrix) == 16 {
+		m := matrix.Mat4{}
+		for i := range m {
+			m[i] = matrix.Float(node.Matrix[i])
+		}
+		scale := matrix.Vec3{
+			m.ColumnVector(0).AsVec3().Length(),
+			m.ColumnVector(1).AsVec3().Length(),
+			m.ColumnVector(2).AsVec3().Length(),
+		}
+		r := matrix.Mat4Identity()
+		for col := range 3 {
+			for row := range 3 {
+				r[col*4+row] = m[col*4+row] / scale[col]
+			}
+		}
+		return m.Position(), matrix.QuaternionFromMat4(r), scale
+	}
+	t, r, s := node.Translation, node.Rotation, node.Scale
+	return matrix.Vec3{matrix.Float(t[0]), matrix.Float(t[1]), matrix.Float(t[2])},
+		matrix.NewQuaternion(matrix.Float(r[3]), matrix.Float(r[0]), matrix.Float(r[1]), matrix.Float(r[2])),
+		matrix.Vec3{matrix.Float(s[0]), matrix.Float(s[1]), matrix.Float(s[2])}
+}
+
+func gltfNodeParents(g *gltf.GLTF) []int32 {
+	parents := make([]int32, len(g.Nodes))
+	for i := range parents {
+		parents[i] = -1
+	}
+	for i := range g.Nodes {
+		for _, c := range g.Nodes[i].Children {
+			if c >= 0 && int(c) < len(parents) {
+				parents[c] = int32(i)
+			}
+		}
+	}
+	return parents
+}
+
+func gltfReadSkins(doc *fullGLTF, res *Result) error {
+	g := &doc.glTF
+	parents := gltfNodeParents(g)
+	for i := range g.Skins {
+		skin := &g.Skins[i]
+		var inverseBinds []matrix.Float
+		if skin.InverseBindMatrices != nil {
+			ibm, components, err := gltfReadAccessor(doc, *skin.InverseBindMatrices)
+			if err != nil {
+				return err
+			} else if components != 16 || len(ibm) < len(skin.Joints)*16 {
+				return errors.New("invalid inverse bind matrices")
+			}
+			inverseBinds = ibm
+		}
+		index := make(map[int32]int, len(skin.Joints))
+		for j, node := range skin.Joints {
+			if node < 0 || int(node) >= len(g.Nodes) {
+				return errors.New("invalid skin joint node")
+			}
+			index[node] = j
+		}
+		skeleton := ResultSkeleton{
+			Name:   skin.Name,
+			Joints: make([]ResultJoint, len(skin.Joints)),
+			Root:   matrix.Mat4Identity(),
+		}
+		rootFound := false
+		for j, node := range skin.Joints {
+			joint := &skeleton.Joints[j]
+			joint.Name = g.Nodes[node].Name
+			joint.Node = int(node)
+			joint.Parent = -1
+			joint.Position, joint.Rotation, joint.Scale = gltfNodeTRS(&g.Nodes[node])
+			joint.InverseBind = matrix.Mat4Identity()
+			if inverseBinds != nil {
+				for k := range joint.InverseBind {
+					joint.InverseBind[k] = inverseBinds[j*16+k]
+				}
+			}
+			p := parents[node]
+			if parent, ok := index[p]; ok {
+				joint.Parent = parent
+				continue
+			}
+			if !rootFound {
+				rootFound = true
+				for ; p >= 0; p = parents[p] {
+					t, r, s := gltfNodeTRS(&g.Nodes[p])
+					skeleton.Root = skeleton.Root.Multiply(matrix.Mat4FromTRS(t, r, s))
+				}
+			}
+		}
+		res.Skeletons = append(res.Skeletons, skeleton)
+	}
+	return nil
+}
+
+func gltfReadAnimations(doc *fullGLTF, res *Result) error {
+	g := &doc.glTF
+	for i := range g.Animations {
+		anim := &g.Animations[i]
+		out := ResultAnimation{
+			Name:     anim.Name,
+			Channels: make([]ResultAnimationChannel, 0, len(anim.Channels)),
+		}
+		for _, c := range anim.Channels {
+			if c.Target.Node == nil {
+				continue
+			}
+			if c.Sampler < 0 || int(c.Sampler) >= len(anim.Samplers) {
+				return errors.New("invalid animation sampler index")
+			}
+			sampler := &anim.Samplers[c.Sampler]
+			times, _, err := gltfReadAccessor(doc, sampler.Input)
+			if err != nil {
+				return err
+			}
+			values, components, err := gltfReadAccessor(doc, sampler.Output)
+			if err != nil {
+				return err
+			}
+			if len(times) == 0 {
+				continue
+			}
+			keyValues := len(values) / len(times)
+			if sampler.Interpolation == gltf.INTERPOLATION_CUBICSPLINE {
+				keyValues /= 3
+			}
+			if c.Target.Path == gltf.PATH_WEIGHTS {
+				// Weights are stored as scalars, one per morph target
+				components = keyValues
+			}
+			if components == 0 || keyValues != components {
+				return errors.New("animation output does not match its input")
+			}
+			out.Channels = append(out.Channels, ResultAnimationChannel{
+				Node:          int(*c.Target.Node),
+				Path:          c.Target.Path,
+				Interpolation: sampler.Interpolation,
+				Times:         times,
+				Values:        values,
+				Components:    components,
+			})
+			out.Duration = max(out.Duration, times[len(times)-1])
+		}
+		res.Animations = append(res.Animations, out)
+	}
+	return nil
+}
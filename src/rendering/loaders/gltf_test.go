@@ -0,0 +1,191 @@
+/*****************************************************************************/
+/* gltf_test.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package loaders
+
+import (
+	"kaiju/assets"
+	"kaiju/rendering"
+	"kaiju/rendering/loaders/gltf"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const gltfTestMaterials = `{
+	"materials": [{
+		"name": "Brushed Metal",
+		"alphaMode": "MASK",
+		"emissiveFactor": [0.5, 0.25, 0],
+		"normalTexture": {"index": 1, "scale": 0.5},
+		"pbrMetallicRoughness": {
+			"baseColorFactor": [1, 0.5, 0.25, 1],
+			"baseColorTexture": {"index": 0},
+			"roughnessFactor": 0.3
+		}
+	}],
+	"meshes": [
+		{"primitives": [{"attributes": {}, "material": 0}]},
+		{"primitives": [{"attributes": {}}]}
+	],
+	"textures": [{"source": 1, "sampler": 0}, {"source": 0}],
+	"images": [{"uri": "normal%20map.png"}, {"uri": "textures/base.png"}],
+	"samplers": [{"magFilter": 9728}]
+}`
+
+func TestGLTFMaterial(t *testing.T) {
+	doc, err := gltf.LoadGLTF(gltfTestMaterials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, name := gltfReadMeshMaterial(&doc.Meshes[0], &doc, "meshes")
+	if name != "Brushed Metal" || data.Shader != assets.ShaderDefinitionPBR {
+		t.Fatalf("unexpected material %q using %s", name, data.Shader)
+	}
+	base := data.Textures[rendering.PBRTextureBaseColor]
+	if base.Texture != "meshes/textures/base.png" || base.Filter != "Nearest" {
+		t.Fatalf("expected the base color from the texture source, got %v", base)
+	}
+	if normal := data.Textures[rendering.PBRTextureNormal]; normal.Texture != "meshes/normal map.png" {
+		t.Fatalf("expected the unescaped normal map, got %s", normal.Texture)
+	}
+	if occlusion := data.Textures[rendering.PBRTextureOcclusion]; occlusion.Texture != assets.TextureWhite {
+		t.Fatalf("expected the missing occlusion map to default to white, got %s", occlusion.Texture)
+	}
+	expect := map[string][]float32{
+		rendering.PBRParamBaseColor: {1, 0.5, 0.25, 1},
+		rendering.PBRParamEmissive:  {0.5, 0.25, 0, 1},
+		rendering.PBRParamFactors:   {1, 0.3, 0.5, 1},
+		rendering.PBRParamSurface:   {1, rendering.PBRAlphaMask, 0.5, 0},
+	}
+	for k, v := range expect {
+		if !slices.Equal(data.Parameters[k], v) {
+			t.Errorf("expected %s to be %v, got %v", k, v, data.Parameters[k])
+		}
+	}
+	// Meshes without a material use the glTF default material
+	data, name = gltfReadMeshMaterial(&doc.Meshes[1], &doc, "meshes")
+	if name != "default" || !slices.Equal(data.Parameters[rendering.PBRParamFactors], []float32{1, 1, 1, 1}) {
+		t.Fatalf("expected the default material, got %q %v", name, data.Parameters)
+	}
+}
+
+// gltfTestContent parses a glTF file from the content folder
+func gltfTestContent(t *testing.T, name string) Result {
+	t.Helper()
+	folder := filepath.Join("..", "..", "..", "content", "meshes")
+	jsonStr, err := os.ReadFile(filepath.Join(folder, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := fullGLTF{}
+	if doc.glTF, err = gltf.LoadGLTF(string(jsonStr)); err != nil {
+		t.Fatal(err)
+	}
+	bin, err := os.ReadFile(filepath.Join(folder, doc.glTF.Buffers[0].URI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.bins = [][]byte{bin}
+	res, err := gltfParse(&doc, "meshes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestGLTFSkin(t *testing.T) {
+	res := gltfTestContent(t, "skinned_column.gltf")
+	if len(res.Skeletons) != 1 || res.Meshes[0].Skin != 0 {
+		t.Fatalf("expected the mesh to use the only skeleton, got %d skeletons", len(res.Skeletons))
+	}
+	s := res.Skeletons[0]
+	if len(s.Joints) != 2 || s.Joints[0].Parent != -1 || s.Joints[1].Parent != 0 {
+		t.Fatalf("expected a root joint with one child, got %+v", s.Joints)
+	}
+	if y := s.Root.Position().Y(); y != -1 {
+		t.Errorf("expected the armature above the root joint to be in Root, got y %f", y)
+	}
+	if y := s.Joints[0].InverseBind.Position().Y(); y != 1 {
+		t.Errorf("expected the inverse bind matrix of the root to undo the armature, got y %f", y)
+	}
+	// The joint ids are bytes, the top vertex only follows the second joint
+	top := res.Meshes[0].Verts[len(res.Meshes[0].Verts)-1]
+	if top.JointIds[1] != 1 || top.JointWeights[1] != 1 {
+		t.Errorf("expected the top vertex to follow joint 1, got %v %v", top.JointIds, top.JointWeights)
+	}
+	if len(res.Animations) != 2 {
+		t.Fatalf("expected 2 animations, got %d", len(res.Animations))
+	}
+	sway := res.Animations[1]
+	if sway.Duration != 2 || len(sway.Channels) != 2 {
+		t.Fatalf("expected the sway to last 2 seconds over 2 channels, got %+v", sway)
+	}
+	// Cubic spline keys hold an in tangent, value and out tangent
+	rotation := sway.Channels[0]
+	if rotation.Interpolation != gltf.INTERPOLATION_CUBICSPLINE || rotation.Components != 4 ||
+		len(rotation.Values) != len(rotation.Times)*3*4 {
+		t.Errorf("unexpected cubic spline channel %+v", rotation)
+	}
+}
+
+func TestGLTFMorphTargets(t *testing.T) {
+	res := gltfTestContent(t, "morph_sheet.gltf")
+	m := res.Meshes[0]
+	if m.Node != 0 || len(m.MorphTargets) != 2 || len(m.MorphWeights) != 2 {
+		t.Fatalf("expected 2 morph targets on node 0, got %d on node %d", len(m.MorphTargets), m.Node)
+	}
+	if m.MorphTargets[0].Name != "tall" || m.MorphTargets[1].Name != "wide" {
+		t.Errorf("expected the target names from the extras, got %q and %q",
+			m.MorphTargets[0].Name, m.MorphTargets[1].Name)
+	}
+	if m.MorphTargets[0].Normals != nil || len(m.MorphTargets[1].Normals) != len(m.Verts) {
+		t.Errorf("expected only the second target to have normals")
+	}
+	targets := m.NewMorphTargets()
+	if targets.TargetCount != 2 || len(targets.Deltas) != 2*2*len(m.Verts) {
+		t.Fatalf("expected a position and normal delta per vertex per target, got %d", len(targets.Deltas))
+	}
+	last := len(m.Verts) - 1
+	if d := targets.Deltas[(len(m.Verts)+last)*2]; d.X() != m.MorphTargets[1].Positions[last].X() {
+		t.Errorf("expected the deltas of the second target after the first, got %v", d)
+	}
+	if ch := res.Animations[0].Channels[0]; ch.Path != gltf.PATH_WEIGHTS || ch.Components != 2 {
+		t.Errorf("expected a weight channel for both targets, got %+v", ch)
+	}
+}
@@ -37,23 +37,95 @@
 
 package loaders
 
-import "kaiju/rendering"
+import (
+	"kaiju/klib"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/rendering/loaders/gltf"
+)
 
 type ResultMesh struct {
 	Name    string
 	Verts   []rendering.Vertex
 	Indexes []uint32
+	// Material is the PBR material of the mesh, it is nil for formats that
+	// do not have materials. Meshes that share a material in the file share
+	// the same name
+	Material     *rendering.MaterialData
+	MaterialName string
+	// Skin is the index of the skeleton in Result.Skeletons that moves the
+	// mesh, -1 when the mesh is not skinned
+	Skin int
+	// Node is the first node of the file that draws the mesh, weight
+	// channels of animations target it. It is -1 when no node draws the mesh
+	Node         int
+	MorphTargets []ResultMorphTarget
+	// MorphWeights are the default weights of the morph targets
+	MorphWeights []matrix.Float
+}
+
+// ResultMorphTarget is how far each vertex of the mesh moves when the
+// target is fully blended in, Normals is nil when the target has none
+type ResultMorphTarget struct {
+	Name      string
+	Positions []matrix.Vec3
+	Normals   []matrix.Vec3
+}
+
+// ResultJoint is a joint of a skeleton in its rest pose. The joint ids of
+// the vertices are indexes into the joints of the skeleton
+type ResultJoint struct {
+	Name string
+	// Parent is the index of the parent joint, -1 for the root joints
+	Parent int
+	// Node is the node of the file the joint was made from, animation
+	// channels target nodes
+	Node        int
+	Position    matrix.Vec3
+	Rotation    matrix.Quaternion
+	Scale       matrix.Vec3
+	InverseBind matrix.Mat4
+}
+
+type ResultSkeleton struct {
+	Name   string
+	Joints []ResultJoint
+	// Root is the transform of the nodes above the root joints that are
+	// not joints themselves
+	Root matrix.Mat4
+}
+
+// ResultAnimationChannel is the keyframes of one property of a node. Values
+// has Components floats for each key, rotations are x, y, z, w like glTF.
+// Cubic spline keys are the in tangent, the value and the out tangent
+type ResultAnimationChannel struct {
+	Node          int
+	Path          gltf.AnimationPath
+	Interpolation gltf.Interpolation
+	Times         []matrix.Float
+	Values        []matrix.Float
+	Components    int
+}
+
+type ResultAnimation struct {
+	Name     string
+	Duration matrix.Float
+	Channels []ResultAnimationChannel
 }
 
 type Result struct {
-	Meshes   []ResultMesh
-	Textures []string
+	Meshes     []ResultMesh
+	Textures   []string
+	Skeletons  []ResultSkeleton
+	Animations []ResultAnimation
 }
 
 func NewResult() Result {
 	return Result{
-		Meshes:   make([]ResultMesh, 0),
-		Textures: make([]string, 0),
+		Meshes:     make([]ResultMesh, 0),
+		Textures:   make([]string, 0),
+		Skeletons:  make([]ResultSkeleton, 0),
+		Animations: make([]ResultAnimation, 0),
 	}
 }
 
@@ -64,5 +136,27 @@ func (r *Result) Add(name string, verts []rendering.Vertex, indexes []uint32, te
 		Name:    name,
 		Verts:   verts,
 		Indexes: indexes,
+		Skin:    -1,
+		Node:    -1,
 	})
+	for _, t := range textures {
+		if !klib.Contains(r.Textures, t) {
+			r.Textures = append(r.Textures, t)
+		}
+	}
+}
+
+// NewMorphTargets creates the renderer morph targets of the mesh, nil when
+// the mesh has no morph targets
+func (m *ResultMesh) NewMorphTargets() *rendering.MorphTargets {
+	if len(m.MorphTargets) == 0 {
+		return nil
+	}
+	positions := make([][]matrix.Vec3, len(m.MorphTargets))
+	normals := make([][]matrix.Vec3, len(m.MorphTargets))
+	for i := range m.MorphTargets {
+		positions[i] = m.MorphTargets[i].Positions
+		normals[i] = m.MorphTargets[i].Normals
+	}
+	return rendering.NewMorphTargets(len(m.Verts), positions, normals)
 }
@@ -0,0 +1,279 @@
+/*****************************************************************************/
+/* material.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"encoding/json"
+	"fmt"
+	"kaiju/matrix"
+	"strings"
+	"unsafe"
+)
+
+// MaterialFileExtension is the extension of material assets
+const MaterialFileExtension = ".material"
+
+// MaterialTextureData is a texture slot of a material file, the slots are
+// bound to the shader in the order they are listed
+type MaterialTextureData struct {
+	Texture string
+	// Filter is either "Linear" (the default) or "Nearest"
+	Filter string
+}
+
+// MaterialData is the contents of a material file. Parameters are the
+// values of the shader definition fields by name, every value is a list of
+// floats, one for a float, 4 for a vec4 and 16 for a mat4. Fields that are
+// not listed are zero, except for the model matrix which is always set by
+// the drawing
+type MaterialData struct {
+	Shader      string
+	Textures    []MaterialTextureData
+	Parameters  map[string][]matrix.Float
+	UseBlending bool
+}
+
+type materialField struct {
+	fieldType string
+	offset    int
+	size      int
+}
+
+// Material binds a shader definition to its textures and the default
+// values of its instance fields. A material is shared by everything drawn
+// with it, use NewInstance to draw with it
+type Material struct {
+	Key         string
+	Shader      *Shader
+	Definition  ShaderDef
+	Textures    []*Texture
+	UseBlending bool
+	fields      map[string]materialField
+	defaults    []byte
+}
+
+// MaterialInstance is the per drawing data of a material, any of the
+// parameters of the material can be overridden for a single instance
+type MaterialInstance struct {
+	ShaderDataBase
+	Material *Material
+	// Textures start as the textures of the material, they can be changed
+	// before the drawing for the instance is created
+	Textures []*Texture
+	data     []byte
+}
+
+func MaterialDataFromJson(jsonStr string) (MaterialData, error) {
+	var data MaterialData
+	err := json.Unmarshal([]byte(jsonStr), &data)
+	return data, err
+}
+
+func materialTextureFilter(filter string) (TextureFilter, error) {
+	switch strings.ToLower(filter) {
+	case "", "linear":
+		return TextureFilterLinear, nil
+	case "nearest":
+		return TextureFilterNearest, nil
+	default:
+		return TextureFilterLinear, fmt.Errorf("unknown texture filter %q", filter)
+	}
+}
+
+// NewMaterial creates the material from its data, the shader and textures
+// are loaded through the given caches
+func NewMaterial(key string, data MaterialData, shaders *ShaderCache, textures *TextureCache) (*Material, error) {
+	if data.Shader == "" {
+		return nil, fmt.Errorf("material %s has no shader", key)
+	}
+	def, err := shaders.ShaderDefinition(data.Shader)
+	if err != nil {
+		return nil, err
+	}
+	m := &Material{
+		Key:         key,
+		Shader:      shaders.ShaderFromDefinition(data.Shader),
+		Definition:  def,
+		Textures:    make([]*Texture, 0, len(data.Textures)),
+		UseBlending: data.UseBlending,
+		fields:      make(map[string]materialField),
+	}
+	offset := 0
+	for _, f := range m.Definition.Fields {
+		t, ok := defTypes[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("material %s has an unknown field type %s", key, f.Type)
+		}
+		size := int(t.size) * t.repeat
+		m.fields[f.Name] = materialField{f.Type, offset, size}
+		offset += size
+	}
+	m.defaults = make([]byte, offset)
+	identity := matrix.Mat4Identity()
+	if f, ok := m.fields["model"]; ok && f.fieldType == "mat4" {
+		copyMaterialValue(m.defaults, f, identity[:])
+	}
+	for name, value := range data.Parameters {
+		f, ok := m.fields[name]
+		if !ok {
+			return nil, fmt.Errorf("material %s sets %s which is not a field of %s", key, name, data.Shader)
+		}
+		if len(value)*floatSize != f.size {
+			return nil, fmt.Errorf("material %s expects %d values for %s but has %d",
+				key, f.size/floatSize, name, len(value))
+		}
+		copyMaterialValue(m.defaults, f, value)
+	}
+	for _, t := range data.Textures {
+		filter, err := materialTextureFilter(t.Filter)
+		if err != nil {
+			return nil, err
+		}
+		tex, err := textures.Texture(t.Texture, filter)
+		if err != nil {
+			return nil, err
+		}
+		m.Textures = append(m.Textures, tex)
+	}
+	return m, nil
+}
+
+func copyMaterialValue(data []byte, f materialField, value []matrix.Float) {
+	copy(data[f.offset:f.offset+f.size], unsafe.Slice((*byte)(unsafe.Pointer(&value[0])), f.size))
+}
+
+// HasParameter reports if the shader has a field with the name and type
+func (m *Material) HasParameter(name, fieldType string) bool {
+	f, ok := m.fields[name]
+	return ok && f.fieldType == fieldType
+}
+
+// NewInstance creates instance data for the material that starts with the
+// default parameter values of the material
+func (m *Material) NewInstance() *MaterialInstance {
+	mi := &MaterialInstance{
+		ShaderDataBase: NewShaderDataBase(),
+		Material:       m,
+		Textures:       append([]*Texture{}, m.Textures...),
+		data:           append([]byte{}, m.defaults...),
+	}
+	return mi
+}
+
+// Drawing creates the drawing of the mesh using this instance, it can then
+// be added to Drawings
+func (mi *MaterialInstance) Drawing(renderer Renderer, mesh *Mesh, transform *matrix.Transform) Drawing {
+	return Drawing{
+		Renderer:    renderer,
+		Shader:      mi.Material.Shader,
+		Mesh:        mesh,
+		Textures:    mi.Textures,
+		ShaderData:  mi,
+		Transform:   transform,
+		UseBlending: mi.Material.UseBlending,
+	}
+}
+
+func (mi *MaterialInstance) Size() int { return len(mi.data) }
+
+func (mi *MaterialInstance) DataPointer() unsafe.Pointer {
+	if len(mi.data) == 0 {
+		return nil
+	}
+	if f, ok := mi.Material.fields["model"]; ok && f.fieldType == "mat4" {
+		model := mi.Model()
+		copyMaterialValue(mi.data, f, model[:])
+	}
+	return unsafe.Pointer(&mi.data[0])
+}
+
+func (mi *MaterialInstance) set(name, fieldType string, value []matrix.Float) bool {
+	f, ok := mi.Material.fields[name]
+	if !ok || f.fieldType != fieldType {
+		return false
+	}
+	copyMaterialValue(mi.data, f, value)
+	return true
+}
+
+// The Set functions override the value of a parameter for this instance,
+// they return false if the shader has no field with the name and type
+
+func (mi *MaterialInstance) SetFloat(name string, value matrix.Float) bool {
+	return mi.set(name, "float", []matrix.Float{value})
+}
+
+func (mi *MaterialInstance) SetVec2(name string, value matrix.Vec2) bool {
+	return mi.set(name, "vec2", value[:])
+}
+
+func (mi *MaterialInstance) SetVec3(name string, value matrix.Vec3) bool {
+	return mi.set(name, "vec3", value[:])
+}
+
+func (mi *MaterialInstance) SetVec4(name string, value matrix.Vec4) bool {
+	return mi.set(name, "vec4", value[:])
+}
+
+func (mi *MaterialInstance) SetColor(name string, value matrix.Color) bool {
+	return mi.set(name, "vec4", value[:])
+}
+
+func (mi *MaterialInstance) SetMat4(name string, value matrix.Mat4) bool {
+	return mi.set(name, "mat4", value[:])
+}
+
+// ResetParameter sets the parameter back to the value of the material
+func (mi *MaterialInstance) ResetParameter(name string) bool {
+	f, ok := mi.Material.fields[name]
+	if ok {
+		copy(mi.data[f.offset:f.offset+f.size], mi.Material.defaults[f.offset:f.offset+f.size])
+	}
+	return ok
+}
+
+// Parameter returns the current values of the parameter for this instance
+func (mi *MaterialInstance) Parameter(name string) ([]matrix.Float, bool) {
+	f, ok := mi.Material.fields[name]
+	if !ok {
+		return nil, false
+	}
+	out := make([]matrix.Float, f.size/floatSize)
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&out[0])), f.size), mi.data[f.offset:f.offset+f.size])
+	return out, true
+}
@@ -0,0 +1,99 @@
+/*****************************************************************************/
+/* material_cache.go                                                         */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"sync"
+)
+
+type MaterialCache struct {
+	caches        RenderCaches
+	assetDatabase *assets.Database
+	materials     map[string]*Material
+	mutex         sync.Mutex
+}
+
+func NewMaterialCache(caches RenderCaches, assetDatabase *assets.Database) MaterialCache {
+	return MaterialCache{
+		caches:        caches,
+		assetDatabase: assetDatabase,
+		materials:     make(map[string]*Material),
+		mutex:         sync.Mutex{},
+	}
+}
+
+// Material loads the material with the given asset key, or returns it if it
+// was already loaded. The shader and textures of the material are loaded
+// through the shader and texture caches
+func (m *MaterialCache) Material(materialKey string) (*Material, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if material, ok := m.materials[materialKey]; ok {
+		return material, nil
+	}
+	str, err := m.assetDatabase.ReadText(materialKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := MaterialDataFromJson(str)
+	if err != nil {
+		return nil, err
+	}
+	material, err := NewMaterial(materialKey, data,
+		m.caches.ShaderCache(), m.caches.TextureCache())
+	if err != nil {
+		return nil, err
+	}
+	m.materials[materialKey] = material
+	return material, nil
+}
+
+// AddMaterial adds a material that was created in code so that it can be
+// found by its key
+func (m *MaterialCache) AddMaterial(material *Material) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.materials[material.Key] = material
+}
+
+func (m *MaterialCache) Destroy() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.materials = make(map[string]*Material)
+}
@@ -0,0 +1,94 @@
+/*****************************************************************************/
+/* material_test.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestMaterialInstanceOverride(t *testing.T) {
+	r, caches, _ := softwareTestSetup(t)
+	material, err := NewMaterial("test.material", MaterialData{
+		Shader:     assets.ShaderDefinitionBasic,
+		Parameters: map[string][]matrix.Float{"color": {1, 0, 0, 1}},
+	}, &caches.shaders, &caches.textures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := material.NewInstance()
+	blue := material.NewInstance()
+	if !blue.SetColor("color", matrix.ColorBlue()) {
+		t.Fatal("expected the basic shader to have a color parameter")
+	}
+	if blue.SetFloat("color", 1) {
+		t.Fatal("expected setting a vec4 parameter as a float to fail")
+	}
+	if red.Size() != int(material.Definition.Stride()) {
+		t.Fatalf("expected the instance size to be %d, got %d", material.Definition.Stride(), red.Size())
+	}
+	mesh := NewMeshQuad(&caches.meshes)
+	d := NewDrawings()
+	redModel := matrix.Mat4Identity()
+	redModel.Translate(matrix.Vec3{-0.5, 0, 0})
+	red.SetModel(redModel)
+	blueModel := matrix.Mat4Identity()
+	blueModel.Translate(matrix.Vec3{0.5, 0, 0})
+	blue.SetModel(blueModel)
+	d.AddDrawing(red.Drawing(r, mesh, nil))
+	d.AddDrawing(blue.Drawing(r, mesh, nil))
+	softwareTestRender(r, &d, caches)
+	softwareTestColor(t, r, 20, 32, matrix.ColorRed())
+	softwareTestColor(t, r, 44, 32, matrix.ColorBlue())
+	blue.ResetParameter("color")
+	if c, _ := blue.Parameter("color"); c[0] != 1 || c[2] != 0 {
+		t.Fatalf("expected the color to reset to red, got %v", c)
+	}
+}
+
+func TestMaterialUnknownParameter(t *testing.T) {
+	_, caches, _ := softwareTestSetup(t)
+	_, err := NewMaterial("test.material", MaterialData{
+		Shader:     assets.ShaderDefinitionBasic,
+		Parameters: map[string][]matrix.Float{"missing": {1}},
+	}, &caches.shaders, &caches.textures)
+	if err == nil {
+		t.Fatal("expected an error for a parameter the shader doesn't have")
+	}
+}
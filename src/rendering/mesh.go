@@ -37,7 +37,10 @@
 
 package rendering
 
-import "kaiju/matrix"
+import (
+	"kaiju/collision"
+	"kaiju/matrix"
+)
 
 type MeshDrawMode = int
 type MeshCullMode = int
@@ -60,14 +63,24 @@ type Mesh struct {
 	key            string
 	pendingVerts   []Vertex
 	pendingIndexes []uint32
+	bounds         collision.AABB
+	hasBounds      bool
 }
 
 func NewMesh(key string, verts []Vertex, indexes []uint32) *Mesh {
-	return &Mesh{
+	m := &Mesh{
 		key:            key,
 		pendingVerts:   verts,
 		pendingIndexes: indexes,
 	}
+	if len(verts) > 0 {
+		m.bounds = collision.AABB{Min: verts[0].Position, Max: verts[0].Position}
+		for i := 1; i < len(verts); i++ {
+			m.bounds.ExpandToPoint(verts[i].Position)
+		}
+		m.hasBounds = true
+	}
+	return m
 }
 
 func (m *Mesh) SetKey(key string) {
@@ -85,6 +98,10 @@ func (m *Mesh) DelayedCreate(renderer Renderer) {
 func (m Mesh) Key() string   { return m.key }
 func (m Mesh) IsReady() bool { return m.MeshId.IsValid() }
 
+// Bounds returns the local space bounds of the vertices the mesh was
+// created with, the second value is false for meshes without vertices
+func (m Mesh) Bounds() (collision.AABB, bool) { return m.bounds, m.hasBounds }
+
 func NewMeshQuad(cache *MeshCache) *Mesh {
 	const key = "quad"
 	if mesh, ok := cache.FindMesh(key); ok {
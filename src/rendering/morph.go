@@ -0,0 +1,192 @@
+/*****************************************************************************/
+/* morph.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"errors"
+	"kaiju/matrix"
+	"slices"
+	"sync"
+)
+
+const (
+	// MaxMorphTargets is the most targets a drawing can blend, the weights
+	// are given to the shader as two vec4
+	MaxMorphTargets = 8
+	// MaxMorphDeltas is the most deltas of all the morph targets that can be
+	// added at once, every vertex of a target takes 2 (position and normal)
+	MaxMorphDeltas = 1 << 17
+	// morphDeltasBinding is the binding of the morph delta storage buffer in
+	// shader definitions that blend morph targets
+	morphDeltasBinding = 3
+)
+
+// Parameters of shader definitions that blend morph targets
+const (
+	// MorphParamInfo is where the deltas of the mesh start, its vertex
+	// count and its target count
+	MorphParamInfo = "morph"
+	// MorphParamWeights0 is the weight of the first 4 targets
+	MorphParamWeights0 = "morphWeights0"
+	// MorphParamWeights1 is the weight of the last 4 targets
+	MorphParamWeights1 = "morphWeights1"
+)
+
+// MorphTargets are the targets of a mesh as the difference from the mesh to
+// each of the targets. For every target the deltas hold the position delta
+// and then the normal delta of each vertex
+type MorphTargets struct {
+	Deltas      []matrix.Vec4
+	VertexCount int
+	TargetCount int
+	offset      int
+}
+
+// Morphs are the morph targets of a host, they are put into one buffer of
+// deltas that is given to the renderer
+type Morphs struct {
+	targets []*MorphTargets
+	version uint64
+	mutex   sync.Mutex
+}
+
+// NewMorphTargets creates the targets of a mesh with the vertex count from
+// the position and normal deltas of each target, the normals can be nil.
+// Targets past MaxMorphTargets are left out
+func NewMorphTargets(vertexCount int, positions, normals [][]matrix.Vec3) *MorphTargets {
+	count := min(len(positions), MaxMorphTargets)
+	m := &MorphTargets{
+		Deltas:      make([]matrix.Vec4, count*vertexCount*2),
+		VertexCount: vertexCount,
+		TargetCount: count,
+	}
+	for t := range count {
+		for v := 0; v < vertexCount && v < len(positions[t]); v++ {
+			p := positions[t][v]
+			m.Deltas[(t*vertexCount+v)*2] = matrix.Vec4{p.X(), p.Y(), p.Z(), 0}
+		}
+		if t >= len(normals) {
+			continue
+		}
+		for v := 0; v < vertexCount && v < len(normals[t]); v++ {
+			n := normals[t][v]
+			m.Deltas[(t*vertexCount+v)*2+1] = matrix.Vec4{n.X(), n.Y(), n.Z(), 0}
+		}
+	}
+	return m
+}
+
+// Offset is where the deltas of the targets start in the delta buffer, it
+// does not change while the targets are added
+func (m *MorphTargets) Offset() int { return m.offset }
+
+func NewMorphs() Morphs {
+	return Morphs{targets: make([]*MorphTargets, 0)}
+}
+
+// Add finds room for the deltas of the targets, it fails when the delta
+// buffer is full. Targets can be shared by every drawing of the mesh, the
+// deltas are uploaded when targets are added or removed so they should not
+// be changed while added
+func (m *Morphs) Add(targets *MorphTargets) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if slices.Contains(m.targets, targets) {
+		return nil
+	}
+	offset := 0
+	at := len(m.targets)
+	for i, other := range m.targets {
+		if other.offset-offset >= len(targets.Deltas) {
+			at = i
+			break
+		}
+		offset = other.offset + len(other.Deltas)
+	}
+	if at == len(m.targets) && offset+len(targets.Deltas) > MaxMorphDeltas {
+		return errors.New("the morph delta buffer is full")
+	}
+	targets.offset = offset
+	m.targets = slices.Insert(m.targets, at, targets)
+	m.version++
+	return nil
+}
+
+func (m *Morphs) Remove(targets *MorphTargets) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if i := slices.Index(m.targets, targets); i >= 0 {
+		m.targets = slices.Delete(m.targets, i, i+1)
+		m.version++
+	}
+}
+
+// fillDeltas copies the deltas of every target into the buffer when they
+// changed since the version, it returns how many deltas of the buffer are
+// used and the version of the deltas now in the buffer
+func (m *Morphs) fillDeltas(deltas []matrix.Vec4, version uint64) (int, uint64) {
+	if m == nil {
+		return 0, version
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	used := 0
+	for _, t := range m.targets {
+		if version != m.version {
+			copy(deltas[t.offset:], t.Deltas)
+		}
+		used = t.offset + len(t.Deltas)
+	}
+	return used, m.version
+}
+
+// SetMorphTargets points the instance at the deltas of the targets, the
+// targets need to be added to the Morphs of the host first
+func (mi *MaterialInstance) SetMorphTargets(targets *MorphTargets) bool {
+	return mi.SetVec4(MorphParamInfo, matrix.Vec4{matrix.Float(targets.offset),
+		matrix.Float(targets.VertexCount), matrix.Float(targets.TargetCount), 0})
+}
+
+// SetMorphWeights sets how much of each target is blended into the mesh for
+// this instance, weights past MaxMorphTargets are left out
+func (mi *MaterialInstance) SetMorphWeights(weights []matrix.Float) bool {
+	var w [MaxMorphTargets]matrix.Float
+	copy(w[:], weights)
+	return mi.SetVec4(MorphParamWeights0, matrix.Vec4(w[:4])) &&
+		mi.SetVec4(MorphParamWeights1, matrix.Vec4(w[4:]))
+}
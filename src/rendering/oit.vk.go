@@ -43,6 +43,7 @@ import (
 	"kaiju/assets"
 	"kaiju/klib"
 	"log"
+	"strings"
 	"unsafe"
 
 	vk "github.com/KaijuEngine/go-vulkan"
@@ -57,6 +58,20 @@ type oitFrameBuffers struct {
 	depth                  TextureId
 	weightedColor          TextureId
 	weightedReveal         TextureId
+	// resolve is the single sampled color of multisampled buffers, it is
+	// what is sampled, blitted and read back instead of the color
+	resolve TextureId
+	// colorFormat and samples are the format of the color image and the
+	// sample count of every image, the zero values are the format of the
+	// screen with a single sample
+	colorFormat vk.Format
+	samples     vk.SampleCountFlagBits
+	// width and height are the size the images are created at, the default
+	// target is the size of the swap chain
+	width  uint32
+	height uint32
+	// sampleDepth lets the depth image be sampled by other drawings
+	sampleDepth bool
 }
 
 func (o *oitFrameBuffers) reset(vr *Vulkan) {
@@ -64,6 +79,7 @@ func (o *oitFrameBuffers) reset(vr *Vulkan) {
 	vr.textureIdFree(&o.depth)
 	vr.textureIdFree(&o.weightedColor)
 	vr.textureIdFree(&o.weightedReveal)
+	vr.textureIdFree(&o.resolve)
 	vk.DestroyFramebuffer(vr.device, o.opaqueFrameBuffer, nil)
 	vr.dbg.remove(uintptr(unsafe.Pointer(o.opaqueFrameBuffer)))
 	vk.DestroyFramebuffer(vr.device, o.transparentFrameBuffer, nil)
@@ -72,6 +88,34 @@ func (o *oitFrameBuffers) reset(vr *Vulkan) {
 	o.depth = TextureId{}
 	o.weightedColor = TextureId{}
 	o.weightedReveal = TextureId{}
+	o.resolve = TextureId{}
+}
+
+func (o *oitFrameBuffers) format() vk.Format {
+	if o.colorFormat == vk.FormatUndefined {
+		return vk.FormatB8g8r8a8Unorm
+	}
+	return o.colorFormat
+}
+
+func (o *oitFrameBuffers) sampleCount() vk.SampleCountFlagBits {
+	if o.samples == 0 {
+		return vk.SampleCount1Bit
+	}
+	return o.samples
+}
+
+func (o *oitFrameBuffers) multisampled() bool {
+	return o.sampleCount() != vk.SampleCount1Bit
+}
+
+// output is the color image holding what was drawn once the frame buffers
+// are done being drawn to
+func (o *oitFrameBuffers) output() *TextureId {
+	if o.multisampled() {
+		return &o.resolve
+	}
+	return &o.color
 }
 
 func (o *oitFrameBuffers) createImages(vr *Vulkan) bool {
@@ -89,9 +133,24 @@ type oitPass struct {
 	compositeQuad         *Mesh
 	opaqueRenderPass      vk.RenderPass
 	transparentRenderPass vk.RenderPass
+	// colorFormat and samples are those of the frame buffers the pass was
+	// made for, passes are shared by the targets that match them
+	colorFormat vk.Format
+	samples     vk.SampleCountFlagBits
+}
+
+// renderPassFor is the render pass the pipeline of the shader is created
+// for and if the pipeline draws into the weighted transparent images
+func (o *oitPass) renderPassFor(shader *Shader) (vk.RenderPass, bool) {
+	if strings.HasSuffix(shader.FragPath, oitSuffix) || shader.IsComposite() {
+		return o.transparentRenderPass, !shader.IsComposite()
+	}
+	return o.opaqueRenderPass, false
 }
 
 func (o *oitPass) createOitResources(vr *Vulkan, defaultOitBuffers *oitFrameBuffers) bool {
+	o.colorFormat = defaultOitBuffers.format()
+	o.samples = defaultOitBuffers.sampleCount()
 	return o.createOitRenderPassOpaque(vr, defaultOitBuffers) &&
 		o.createOitRenderPassTransparent(vr, defaultOitBuffers)
 }
@@ -104,22 +163,37 @@ func (o *oitPass) reset(vr *Vulkan) {
 }
 
 func (o *oitFrameBuffers) createOitSolidImages(vr *Vulkan) bool {
-	w := uint32(vr.swapChainExtent.Width)
-	h := uint32(vr.swapChainExtent.Height)
-	samples := vk.SampleCount1Bit
-	//VkSampleCountFlagBits samples = vr.msaaSamples;
+	w, h := o.width, o.height
+	samples := o.sampleCount()
 	// Create the solid color image
+	outputUsage := vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit |
+		vk.ImageUsageTransferSrcBit | vk.ImageUsageSampledBit)
+	colorUsage := outputUsage
+	if o.multisampled() {
+		colorUsage = vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit)
+	}
 	imagesCreated := vr.CreateImage(w, h, 1, samples,
-		vk.FormatB8g8r8a8Unorm, vk.ImageTilingOptimal,
-		vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit|vk.ImageUsageTransferSrcBit|vk.ImageUsageSampledBit),
+		o.format(), vk.ImageTilingOptimal, colorUsage,
 		vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), &o.color, 1)
 	imagesCreated = imagesCreated && vr.createImageView(&o.color,
 		vk.ImageAspectFlags(vk.ImageAspectColorBit))
+	// The samples of the color are resolved into a single sampled image
+	// at the end of the transparent pass
+	if o.multisampled() {
+		imagesCreated = imagesCreated && vr.CreateImage(w, h, 1, vk.SampleCount1Bit,
+			o.format(), vk.ImageTilingOptimal, outputUsage,
+			vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), &o.resolve, 1)
+		imagesCreated = imagesCreated && vr.createImageView(&o.resolve,
+			vk.ImageAspectFlags(vk.ImageAspectColorBit))
+	}
 	// Create the depth image
 	depthFormat := vr.findDepthFormat()
+	depthUsage := vk.ImageUsageFlags(vk.ImageUsageDepthStencilAttachmentBit)
+	if o.sampleDepth {
+		depthUsage |= vk.ImageUsageFlags(vk.ImageUsageSampledBit | vk.ImageUsageTransferSrcBit)
+	}
 	imagesCreated = imagesCreated && vr.CreateImage(w, h, 1,
-		samples, depthFormat, vk.ImageTilingOptimal,
-		vk.ImageUsageFlags(vk.ImageUsageDepthStencilAttachmentBit),
+		samples, depthFormat, vk.ImageTilingOptimal, depthUsage,
 		vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), &o.depth, 1)
 	imagesCreated = imagesCreated && vr.createImageView(&o.depth,
 		vk.ImageAspectFlags(vk.ImageAspectDepthBit))
@@ -127,6 +201,11 @@ func (o *oitFrameBuffers) createOitSolidImages(vr *Vulkan) bool {
 		vr.transitionImageLayout(&o.color,
 			vk.ImageLayoutColorAttachmentOptimal, vk.ImageAspectFlags(vk.ImageAspectColorBit),
 			vk.AccessFlags(vk.AccessColorAttachmentWriteBit), vk.CommandBuffer(vk.NullHandle))
+		if o.multisampled() {
+			vr.transitionImageLayout(&o.resolve,
+				vk.ImageLayoutColorAttachmentOptimal, vk.ImageAspectFlags(vk.ImageAspectColorBit),
+				vk.AccessFlags(vk.AccessColorAttachmentWriteBit), vk.CommandBuffer(vk.NullHandle))
+		}
 		vr.transitionImageLayout(&o.depth,
 			vk.ImageLayoutDepthStencilAttachmentOptimal, vk.ImageAspectFlags(vk.ImageAspectDepthBit),
 			vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit), vk.CommandBuffer(vk.NullHandle))
@@ -135,10 +214,8 @@ func (o *oitFrameBuffers) createOitSolidImages(vr *Vulkan) bool {
 }
 
 func (o *oitFrameBuffers) createOitTransparentImages(vr *Vulkan) bool {
-	w := uint32(vr.swapChainExtent.Width)
-	h := uint32(vr.swapChainExtent.Height)
-	samples := vk.SampleCount1Bit
-	//VkSampleCountFlagBits samples = vr.msaaSamples;
+	w, h := o.width, o.height
+	samples := o.sampleCount()
 	// Create the transparent weighted color image
 	imagesCreated := vr.CreateImage(w, h, 1, samples,
 		vk.FormatR16g16b16a16Sfloat, vk.ImageTilingOptimal,
@@ -258,6 +335,12 @@ func (o *oitPass) createOitRenderPassTransparent(vr *Vulkan, defaultOitBuffers *
 
 	allAttachments := []vk.AttachmentDescription{weightedColorAttachment,
 		weightedRevealAttachment, colorAttachment, depthAttachment}
+	if defaultOitBuffers.multisampled() {
+		resolveAttachment := colorAttachment
+		resolveAttachment.Samples = vk.SampleCount1Bit
+		resolveAttachment.LoadOp = vk.AttachmentLoadOpDontCare
+		allAttachments = append(allAttachments, resolveAttachment)
+	}
 
 	var subpasses [2]vk.SubpassDescription
 
@@ -293,6 +376,12 @@ func (o *oitPass) createOitRenderPassTransparent(vr *Vulkan, defaultOitBuffers *
 	subpasses[1].PColorAttachments = []vk.AttachmentReference{subpass1ColorAttachment}
 	subpasses[1].InputAttachmentCount = uint32(len(subpass1InputAttachments))
 	subpasses[1].PInputAttachments = subpass1InputAttachments[:]
+	if defaultOitBuffers.multisampled() {
+		resolveRef := vk.AttachmentReference{}
+		resolveRef.Attachment = 4 // resolve
+		resolveRef.Layout = vk.ImageLayoutColorAttachmentOptimal
+		subpasses[1].PResolveAttachments = []vk.AttachmentReference{resolveRef}
+	}
 
 	// Dependencies
 	var subpassDependencies [3]vk.SubpassDependency
@@ -347,6 +436,9 @@ func (o *oitFrameBuffers) createOitFrameBufferOpaque(vr *Vulkan, pass *oitPass)
 func (o *oitFrameBuffers) createOitFrameBufferTransparent(vr *Vulkan, pass *oitPass) bool {
 	attachments := []vk.ImageView{o.weightedColor.View,
 		o.weightedReveal.View, o.color.View, o.depth.View}
+	if o.multisampled() {
+		attachments = append(attachments, o.resolve.View)
+	}
 	return vr.CreateFrameBuffer(pass.transparentRenderPass, attachments,
 		uint32(o.weightedColor.Width), uint32(o.weightedColor.Height),
 		&o.transparentFrameBuffer)
@@ -359,6 +451,8 @@ func (o *oitPass) createCompositeResources(vr *Vulkan, windowWidth, windowHeight
 	meshCache.CreatePending()
 	vr.oitPass.compositeShader = shaderCache.ShaderFromDefinition(
 		assets.ShaderDefinitionOITComposite)
+	vr.compositeShaderMS = shaderCache.ShaderFromDefinition(
+		assets.ShaderDefinitionOITCompositeMS)
 	shaderCache.CreatePending()
 	if err != nil {
 		log.Fatalf("%s", err)
@@ -368,11 +462,21 @@ func (o *oitPass) createCompositeResources(vr *Vulkan, windowWidth, windowHeight
 	return true
 }
 
-func (o *oitFrameBuffers) createSetsAndSamplers(vr *Vulkan) bool {
-	o.descriptorSets, o.descriptorPool = klib.MustReturn2(vr.createDescriptorSet(vr.oitPass.compositeShader.RenderId.descriptorSetLayout, 0))
+func (o *oitFrameBuffers) createSetsAndSamplers(vr *Vulkan, pass *oitPass) bool {
+	o.descriptorSets, o.descriptorPool = klib.MustReturn2(vr.createDescriptorSet(pass.compositeShader.RenderId.descriptorSetLayout, 0))
+	return o.createSamplers(vr)
+}
+
+// createSamplers makes the samplers the composite pass reads the weighted
+// images with, they are freed with the images when the buffers are reset
+func (o *oitFrameBuffers) createSamplers(vr *Vulkan) bool {
 	vr.createTextureSampler(&o.weightedColor.Sampler,
 		o.weightedColor.MipLevels, vk.FilterLinear)
 	vr.createTextureSampler(&o.weightedReveal.Sampler,
 		o.weightedReveal.MipLevels, vk.FilterLinear)
 	return true
 }
+
+func (o *oitFrameBuffers) extent() vk.Extent2D {
+	return vk.Extent2D{Width: o.width, Height: o.height}
+}
@@ -0,0 +1,105 @@
+/*****************************************************************************/
+/* pbr.go                                                                    */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"kaiju/matrix"
+)
+
+// Texture slots of the PBR shader, the textures of a PBR material are bound
+// in this order
+const (
+	PBRTextureBaseColor = iota
+	// PBRTextureMetallicRoughness has roughness in green and metallic in
+	// blue like glTF
+	PBRTextureMetallicRoughness
+	PBRTextureNormal
+	// PBRTextureOcclusion has the ambient occlusion in red
+	PBRTextureOcclusion
+	PBRTextureEmissive
+	// PBRTextureEnvironment is a cube map laid out as a horizontal strip
+	// of 6 square faces in the order +X, -X, +Y, -Y, +Z, -Z
+	PBRTextureEnvironment
+	PBRTextureCount
+)
+
+// Alpha modes of the PBR shader, they are the alpha modes of glTF
+const (
+	PBRAlphaOpaque = iota
+	// PBRAlphaMask discards fragments with an alpha below the cutoff
+	PBRAlphaMask
+	// PBRAlphaBlend needs the material to use blending
+	PBRAlphaBlend
+)
+
+// Parameters of the PBR shader definition
+const (
+	// PBRParamBaseColor is multiplied with the base color texture
+	PBRParamBaseColor = "baseColor"
+	// PBRParamEmissive is multiplied with the emissive texture, w scales it
+	PBRParamEmissive = "emissive"
+	// PBRParamFactors is the metallic, roughness, normal scale and
+	// occlusion strength
+	PBRParamFactors = "pbrFactors"
+	// PBRParamSurface is the environment intensity, alpha mode and alpha
+	// cutoff
+	PBRParamSurface = "surface"
+)
+
+// NewPBRMaterialData is a white, fully rough dielectric PBR material lit by
+// the default environment. Every texture slot is filled so the factors
+// alone decide the look until textures are set
+func NewPBRMaterialData() MaterialData {
+	textures := make([]MaterialTextureData, PBRTextureCount)
+	for i := range textures {
+		textures[i] = MaterialTextureData{Texture: assets.TextureWhite, Filter: "Linear"}
+	}
+	textures[PBRTextureNormal].Texture = assets.TextureFlatNormal
+	textures[PBRTextureEnvironment].Texture = assets.TextureEnvironment
+	return MaterialData{
+		Shader:   assets.ShaderDefinitionPBR,
+		Textures: textures,
+		Parameters: map[string][]matrix.Float{
+			PBRParamBaseColor: {1, 1, 1, 1},
+			PBRParamEmissive:  {0, 0, 0, 1},
+			PBRParamFactors:   {0, 1, 1, 1},
+			PBRParamSurface:   {1, PBRAlphaOpaque, 0.5, 0},
+		},
+	}
+}
@@ -0,0 +1,429 @@
+/*****************************************************************************/
+/* post_process.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"errors"
+	"kaiju/assets"
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"log"
+	"slices"
+	"unsafe"
+)
+
+// PostParamCount is how many vec4 parameters a post process pass gives to
+// its shader, the shader definition names them params0 to params3
+const PostParamCount = 4
+
+// postParamNames are the names of the pass parameters in the fields of
+// post process shader definitions
+var postParamNames = [PostParamCount]string{"params0", "params1", "params2", "params3"}
+
+// Texture slots of post process shaders, the textures given to a pass are
+// bound after these
+const (
+	// PostTextureSource is the output of the previous pass
+	PostTextureSource = iota
+	// PostTextureInput is the image as it was before the first pass of the
+	// effect, effects like bloom combine it with their last pass
+	PostTextureInput
+	// PostTextureExtra is the first of the textures of the pass
+	PostTextureExtra
+)
+
+// Names of the effects that ship with the engine
+const (
+	PostEffectTonemap             = "tonemap"
+	PostEffectBloom               = "bloom"
+	PostEffectFXAA                = "fxaa"
+	PostEffectColorGrading        = "color grading"
+	PostEffectVignette            = "vignette"
+	PostEffectChromaticAberration = "chromatic aberration"
+)
+
+// postTargetCount is how many targets a stack rotates through, one holds
+// the input of the effect while the other two ping-pong between passes
+const postTargetCount = 3
+
+type postShaderData struct {
+	ShaderDataBase
+	Params [PostParamCount]matrix.Vec4
+}
+
+func (p postShaderData) Size() int {
+	return int(unsafe.Sizeof(postShaderData{}) - ShaderBaseDataStart)
+}
+
+// PostPass draws a quad over the whole target with the shader of a shader
+// definition. The definition needs the model field followed by the vec4
+// fields params0 to params3, and a combined image sampler for the source,
+// the effect input and each of the textures of the pass
+type PostPass struct {
+	// Definition is the asset key of the shader definition of the pass
+	Definition string
+	// Params are read every time the pass is drawn, so they can be changed
+	// while the pass is in use
+	Params [PostParamCount]matrix.Vec4
+	// Textures are bound after the source and the effect input
+	Textures []*Texture
+	drawings Drawings
+	data     *postShaderData
+	textures []*Texture
+}
+
+// PostEffect is a named list of passes that is enabled or disabled as one
+type PostEffect struct {
+	Name    string
+	Enabled bool
+	Passes  []*PostPass
+}
+
+// NewPostPass creates a pass for the shader definition, the params fill the
+// pass parameters in order
+func NewPostPass(definition string, params ...matrix.Vec4) *PostPass {
+	p := &PostPass{Definition: definition}
+	copy(p.Params[:], params)
+	return p
+}
+
+// NewPostEffect creates an enabled effect from the passes, custom effects
+// are made this way from the user's own shader definitions
+func NewPostEffect(name string, passes ...*PostPass) *PostEffect {
+	return &PostEffect{Name: name, Enabled: true, Passes: passes}
+}
+
+// NewTonemapEffect maps HDR colors into the displayable range with an
+// exposure curve, params0 of the pass is the exposure and the gamma. A
+// gamma of 1 keeps the colors in the space they were drawn in
+func NewTonemapEffect(exposure, gamma matrix.Float) *PostEffect {
+	return NewPostEffect(PostEffectTonemap, NewPostPass(
+		assets.ShaderDefinitionPostTonemap, matrix.Vec4{exposure, gamma, 0, 0}))
+}
+
+// NewBloomEffect spreads the light of the colors brighter than the
+// threshold into their surroundings. The first pass keeps the bright
+// colors (params0 is the threshold and the soft knee), the next two blur
+// them horizontally and vertically (params0 is the direction and the
+// spread in pixels) and the last adds them back onto the input (params0.x
+// is the intensity)
+func NewBloomEffect(threshold, intensity, spread matrix.Float) *PostEffect {
+	return NewPostEffect(PostEffectBloom,
+		NewPostPass(assets.ShaderDefinitionPostBloomBright, matrix.Vec4{threshold, 0.5, 0, 0}),
+		NewPostPass(assets.ShaderDefinitionPostBlur, matrix.Vec4{1, 0, spread, 0}),
+		NewPostPass(assets.ShaderDefinitionPostBlur, matrix.Vec4{0, 1, spread, 0}),
+		NewPostPass(assets.ShaderDefinitionPostBloomCombine, matrix.Vec4{intensity, 0, 0, 0}))
+}
+
+// NewFXAAEffect smooths the jagged edges of the image, params0 of the pass
+// is the longest search span in pixels, the reduce multiplier and the
+// smallest reduction
+func NewFXAAEffect() *PostEffect {
+	return NewPostEffect(PostEffectFXAA, NewPostPass(
+		assets.ShaderDefinitionPostFXAA, matrix.Vec4{8, 1.0 / 8.0, 1.0 / 128.0, 0}))
+}
+
+// NewColorGradingEffect looks the colors up in the LUT, a strip of size
+// squares of size by size pixels where red runs across a square, green
+// runs down it and blue picks the square. params0 of the pass is the
+// intensity and the size of the LUT
+func NewColorGradingEffect(lut *Texture, size int, intensity matrix.Float) *PostEffect {
+	pass := NewPostPass(assets.ShaderDefinitionPostColorGrading,
+		matrix.Vec4{intensity, matrix.Float(size), 0, 0})
+	pass.Textures = []*Texture{lut}
+	return NewPostEffect(PostEffectColorGrading, pass)
+}
+
+// NewVignetteEffect fades the edges of the image into the color. params0
+// of the pass is the intensity, the radius where the fade ends and the
+// softness of the fade, the distance to a corner is 1. params1 is the color
+func NewVignetteEffect(intensity, radius, softness matrix.Float, color matrix.Color) *PostEffect {
+	return NewPostEffect(PostEffectVignette, NewPostPass(
+		assets.ShaderDefinitionPostVignette,
+		matrix.Vec4{intensity, radius, softness, 0}, matrix.Vec4(color)))
+}
+
+// NewChromaticAberrationEffect splits the red and blue of the image
+// towards the edges like a cheap lens, params0.x of the pass is how far
+// apart they are at the corners as a fraction of the image
+func NewChromaticAberrationEffect(strength matrix.Float) *PostEffect {
+	return NewPostEffect(PostEffectChromaticAberration, NewPostPass(
+		assets.ShaderDefinitionPostChromaticAberration, matrix.Vec4{strength, 0, 0, 0}))
+}
+
+// PostProcessStack is the ordered list of effects drawn over the image of
+// a camera. The targets the passes draw into are created and reused by the
+// stack
+type PostProcessStack struct {
+	caches  RenderCaches
+	effects []*PostEffect
+	targets [postTargetCount]RenderTarget
+	warned  bool
+}
+
+func NewPostProcessStack(caches RenderCaches) *PostProcessStack {
+	return &PostProcessStack{caches: caches}
+}
+
+// Effects are the effects of the stack in the order they are applied
+func (s *PostProcessStack) Effects() []*PostEffect { return slices.Clone(s.effects) }
+
+// Effect finds the first effect with the name
+func (s *PostProcessStack) Effect(name string) (*PostEffect, bool) {
+	idx := s.indexOf(name)
+	if idx < 0 {
+		return nil, false
+	}
+	return s.effects[idx], true
+}
+
+// Add appends the effect to the end of the stack
+func (s *PostProcessStack) Add(effect *PostEffect) {
+	s.effects = append(s.effects, effect)
+}
+
+// Insert places the effect at the index, it is clamped to the stack
+func (s *PostProcessStack) Insert(index int, effect *PostEffect) {
+	index = max(0, min(index, len(s.effects)))
+	s.effects = slices.Insert(s.effects, index, effect)
+}
+
+// Move places the effect with the name at the index, returns false if there
+// is no such effect
+func (s *PostProcessStack) Move(name string, index int) bool {
+	idx := s.indexOf(name)
+	if idx < 0 {
+		return false
+	}
+	effect := s.effects[idx]
+	s.effects = slices.Delete(s.effects, idx, idx+1)
+	s.Insert(index, effect)
+	return true
+}
+
+// Remove takes the effect with the name out of the stack and releases what
+// its passes had created, returns false if there is no such effect
+func (s *PostProcessStack) Remove(renderer Renderer, name string) bool {
+	idx := s.indexOf(name)
+	if idx < 0 {
+		return false
+	}
+	for _, p := range s.effects[idx].Passes {
+		p.destroy(renderer)
+	}
+	s.effects = slices.Delete(s.effects, idx, idx+1)
+	return true
+}
+
+// Destroy releases the passes of all the effects and the targets of the
+// stack, the effects are kept and can be applied again
+func (s *PostProcessStack) Destroy(renderer Renderer) {
+	for _, e := range s.effects {
+		for _, p := range e.Passes {
+			p.destroy(renderer)
+		}
+	}
+	for i := range s.targets {
+		if s.targets[i] != nil {
+			DestroyRenderTarget(renderer, s.targets[i])
+			s.targets[i] = nil
+		}
+	}
+}
+
+func (s *PostProcessStack) indexOf(name string) int {
+	return slices.IndexFunc(s.effects, func(e *PostEffect) bool { return e.Name == name })
+}
+
+// Apply draws the enabled effects in order over the source and returns the
+// target holding the result. The source is returned as is when no effect
+// is enabled, or when the renderer can't draw post processing
+func (s *PostProcessStack) Apply(renderer Renderer, source RenderTarget) (RenderTarget, error) {
+	if s == nil {
+		return source, nil
+	}
+	current := source
+	for _, e := range s.effects {
+		if !e.Enabled || len(e.Passes) == 0 {
+			continue
+		}
+		input := current
+		for _, p := range e.Passes {
+			dst, err := s.target(renderer, input, current)
+			if err == nil {
+				err = p.draw(renderer, s.caches, current, input, dst)
+			}
+			if err != nil {
+				return source, err
+			}
+			current = dst
+		}
+	}
+	return current, nil
+}
+
+// applyOrWarn is Apply for drawing a frame, failing to post process only
+// logs once and the frame is shown without the effects
+func (s *PostProcessStack) applyOrWarn(renderer Renderer, source RenderTarget) RenderTarget {
+	target, err := s.Apply(renderer, source)
+	if err != nil && !s.warned {
+		log.Printf("post processing is skipped: %v", err)
+		s.warned = true
+	}
+	return target
+}
+
+// target finds a target of the stack that is neither being read by the pass
+// nor holding the input of the effect
+func (s *PostProcessStack) target(renderer Renderer, input, current RenderTarget) (RenderTarget, error) {
+	for i := range s.targets {
+		if s.targets[i] == input || s.targets[i] == current {
+			continue
+		}
+		if s.targets[i] == nil {
+			// HDR targets keep the colors above 1 for effects like bloom
+			// and tonemapping, not every renderer can draw into them
+			t, err := NewRenderTargetWithOptions(renderer, RenderTargetOptions{
+				Format: RenderTargetFormatRGBA16F,
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.targets[i] = t
+		}
+		return s.targets[i], nil
+	}
+	return nil, errors.New("no post process target is free")
+}
+
+func (p *PostPass) draw(renderer Renderer, caches RenderCaches, source, input, dst RenderTarget) error {
+	sourceTexture, err := RenderTargetTexture(renderer, source)
+	if err != nil {
+		return err
+	}
+	inputTexture, err := RenderTargetTexture(renderer, input)
+	if err != nil {
+		return err
+	}
+	if p.data == nil || len(p.textures) != PostTextureExtra+len(p.Textures) {
+		p.destroy(renderer)
+		p.create(renderer, caches)
+	}
+	// The instance group shares the texture list of the drawing, so
+	// changing the list in place rebinds the textures of the pass
+	p.textures[PostTextureSource] = sourceTexture
+	p.textures[PostTextureInput] = inputTexture
+	copy(p.textures[PostTextureExtra:], p.Textures)
+	p.data.Params = p.Params
+	p.drawings.RenderToTarget(renderer, dst)
+	return nil
+}
+
+func (p *PostPass) create(renderer Renderer, caches RenderCaches) {
+	p.data = &postShaderData{ShaderDataBase: NewShaderDataBase()}
+	p.textures = make([]*Texture, PostTextureExtra+len(p.Textures))
+	p.drawings = NewDrawings()
+	p.drawings.AddDrawing(Drawing{
+		Renderer:          renderer,
+		Shader:            caches.ShaderCache().ShaderFromDefinition(p.Definition),
+		Mesh:              NewMeshUnitQuad(caches.MeshCache()),
+		Textures:          p.textures,
+		ShaderData:        p.data,
+		NoShadowCasting:   true,
+		NoShadowReceiving: true,
+	})
+	// The pass is created in the middle of drawing a frame, after the
+	// caches have created what was pending for it
+	caches.ShaderCache().CreatePending()
+	caches.MeshCache().CreatePending()
+	caches.TextureCache().CreatePending()
+	p.drawings.PreparePending()
+}
+
+func (p *PostPass) destroy(renderer Renderer) {
+	if p.data == nil {
+		return
+	}
+	p.drawings.Destroy(renderer)
+	p.data = nil
+	p.textures = nil
+}
+
+// PostProcessing holds the post process stack of each camera
+type PostProcessing struct {
+	caches RenderCaches
+	stacks map[cameras.Camera]*PostProcessStack
+}
+
+func NewPostProcessing(caches RenderCaches) PostProcessing {
+	return PostProcessing{
+		caches: caches,
+		stacks: make(map[cameras.Camera]*PostProcessStack),
+	}
+}
+
+// Stack is the stack of the camera, it is created empty the first time it
+// is asked for
+func (p *PostProcessing) Stack(camera cameras.Camera) *PostProcessStack {
+	if s, ok := p.stacks[camera]; ok {
+		return s
+	}
+	s := NewPostProcessStack(p.caches)
+	p.stacks[camera] = s
+	return s
+}
+
+// Find is the stack of the camera if it has one, a nil stack applies no
+// effects
+func (p *PostProcessing) Find(camera cameras.Camera) *PostProcessStack {
+	return p.stacks[camera]
+}
+
+// Remove destroys the stack of the camera
+func (p *PostProcessing) Remove(renderer Renderer, camera cameras.Camera) {
+	if s, ok := p.stacks[camera]; ok {
+		s.Destroy(renderer)
+		delete(p.stacks, camera)
+	}
+}
+
+func (p *PostProcessing) Destroy(renderer Renderer) {
+	for camera := range p.stacks {
+		p.Remove(renderer, camera)
+	}
+}
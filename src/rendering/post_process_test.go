@@ -0,0 +1,115 @@
+/*****************************************************************************/
+/* post_process_test.go                                                      */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"testing"
+)
+
+func postTestNames(s *PostProcessStack) []string {
+	names := []string{}
+	for _, e := range s.Effects() {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestPostProcessStackOrder(t *testing.T) {
+	r := NewSoftwareRenderer(8, 8)
+	s := NewPostProcessStack(nil)
+	s.Add(NewTonemapEffect(1, 1))
+	s.Add(NewFXAAEffect())
+	s.Insert(0, NewBloomEffect(1, 1, 1))
+	s.Insert(99, NewVignetteEffect(1, 0.5, 0.2, matrix.ColorBlack()))
+	if !s.Move(PostEffectVignette, 1) {
+		t.Fatal("expected the vignette to move")
+	}
+	if !s.Remove(r, PostEffectFXAA) || s.Remove(r, PostEffectFXAA) {
+		t.Fatal("expected FXAA to be removed once")
+	}
+	expected := []string{PostEffectBloom, PostEffectVignette, PostEffectTonemap}
+	got := postTestNames(s)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	}
+	if e, ok := s.Effect(PostEffectBloom); !ok || len(e.Passes) != 4 {
+		t.Fatal("expected to find the bloom effect with its 4 passes")
+	}
+}
+
+func TestPostProcessApply(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	postDef := ShaderDef{
+		CullMode: "None",
+		Fields: []ShaderDefField{{"model", "mat4"}, {"params0", "vec4"},
+			{"params1", "vec4"}, {"params2", "vec4"}, {"params3", "vec4"}},
+	}
+	// The software renderer finds the definition of a shader by its stages,
+	// so each definition needs its own
+	vignetteDef, aberrationDef := postDef, postDef
+	vignetteDef.Vulkan.Frag = "post_vignette.frag"
+	aberrationDef.Vulkan.Frag = "post_chromatic_aberration.frag"
+	caches.shaders.shaderDefinitions[assets.ShaderDefinitionPostVignette] = vignetteDef
+	caches.shaders.shaderDefinitions[assets.ShaderDefinitionPostChromaticAberration] = aberrationDef
+	d := NewDrawings()
+	softwareTestQuad(r, &d, shader, NewMeshQuad(&caches.meshes), matrix.Vec3{}, matrix.ColorRed())
+	camera := cameras.NewStandardCamera(64, 64, matrix.Vec3{0, 0, 2})
+	caches.meshes.CreatePending()
+	d.PreparePending()
+	r.ReadyFrame(FrameData{Camera: camera, UICamera: camera})
+	stack := NewPostProcessStack(caches)
+	aberration := NewChromaticAberrationEffect(0.5)
+	aberration.Enabled = false
+	stack.Add(aberration)
+	if out, err := stack.Apply(r, r.DefaultTarget()); err != nil || out != r.DefaultTarget() {
+		t.Fatal("expected a stack without enabled effects to return the source")
+	}
+	stack.Add(NewVignetteEffect(1, 0.5, 0.1, matrix.ColorBlue()))
+	d.Render(r, camera, stack)
+	softwareTestColor(t, r, 0, 0, matrix.ColorBlue())
+	softwareTestColor(t, r, 32, 32, matrix.ColorRed())
+	stack.Destroy(r)
+}
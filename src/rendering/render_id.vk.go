@@ -107,6 +107,53 @@ type ShaderId struct {
 	teseModule                   vk.ShaderModule
 	skinningUniformBuffers       [maxFramesInFlight]vk.Buffer
 	skinningUniformBuffersMemory [maxFramesInFlight]vk.DeviceMemory
+	// shadowPipeline draws the depth of the shader into the shadow maps, it
+	// is made the first time the shader casts a shadow
+	shadowPipeline       vk.Pipeline
+	shadowPipelineLayout vk.PipelineLayout
+	// variants are the pipelines made for the render passes of targets with
+	// another color format or sample count than the screen
+	variants []shaderVariant
+}
+
+type shaderVariant struct {
+	pass           *oitPass
+	pipeline       vk.Pipeline
+	pipelineLayout vk.PipelineLayout
+}
+
+// stages are the stages of the created modules in the order the pipeline
+// runs them
+func (s *ShaderId) stages() []vk.PipelineShaderStageCreateInfo {
+	modules := []struct {
+		module vk.ShaderModule
+		stage  vk.ShaderStageFlagBits
+	}{
+		{s.vertModule, vk.ShaderStageVertexBit},
+		{s.tescModule, vk.ShaderStageTessellationControlBit},
+		{s.teseModule, vk.ShaderStageTessellationEvaluationBit},
+		{s.geomModule, vk.ShaderStageGeometryBit},
+		{s.fragModule, vk.ShaderStageFragmentBit},
+	}
+	stages := make([]vk.PipelineShaderStageCreateInfo, 0, len(modules))
+	for _, m := range modules {
+		if m.module == vk.ShaderModule(vk.NullHandle) {
+			continue
+		}
+		stages = append(stages, vk.PipelineShaderStageCreateInfo{
+			SType:  vk.StructureTypePipelineShaderStageCreateInfo,
+			Stage:  m.stage,
+			Module: m.module,
+			PName:  "main\x00",
+		})
+	}
+	return stages
+}
+
+// isValid reports if the shader has a pipeline to draw with, shaders that
+// failed to be created do not
+func (s *ShaderId) isValid() bool {
+	return s.graphicsPipeline != vk.Pipeline(vk.NullHandle)
 }
 
 type TextureId struct {
@@ -39,9 +39,134 @@
 
 package rendering
 
+import (
+	"errors"
+	"image"
+	"kaiju/gl"
+	"kaiju/matrix"
+	"log"
+	"slices"
+	"unsafe"
+)
+
 type GLRenderTarget struct {
+	buffers glOitBuffers
+	options RenderTargetOptions
+	width   int32
+	height  int32
+	// texture samples the color of the target, or the depth for depth
+	// targets, it is created the first time the target is drawn with
+	texture *Texture
+}
+
+func (r *GLRenderer) CreateRenderTarget(options RenderTargetOptions) (RenderTarget, error) {
+	if options.Samples > 1 && options.Format == RenderTargetFormatDepth {
+		return nil, errors.New("depth render targets can not be multisampled")
+	}
+	target := &GLRenderTarget{options: options}
+	if err := target.create(r.width, r.height); err != nil {
+		target.buffers.reset()
+		return nil, err
+	}
+	r.renderTargets = append(r.renderTargets, target)
+	return target, nil
+}
+
+func (r *GLRenderTarget) Width() int                   { return int(r.width) }
+func (r *GLRenderTarget) Height() int                  { return int(r.height) }
+func (r *GLRenderTarget) Options() RenderTargetOptions { return r.options }
+
+// create makes the frame buffers of the target at the size from the
+// options and the size of the screen
+func (r *GLRenderTarget) create(screenWidth, screenHeight int32) error {
+	w, h := r.options.size(int(screenWidth), int(screenHeight))
+	r.width, r.height = int32(w), int32(h)
+	colorFormat, colorType := gl.Handle(gl.RGBA8), gl.Handle(gl.UnsignedByte)
+	if r.options.Format == RenderTargetFormatRGBA16F {
+		colorFormat, colorType = gl.RGBA16F, gl.HalfFloat
+	}
+	return r.buffers.create(r.width, r.height, int32(r.options.Samples), colorFormat, colorType)
+}
+
+// remake creates the frame buffers of a target that follows the screen
+// again at the new size of the screen
+func (r *GLRenderTarget) remake(screenWidth, screenHeight int32) {
+	r.buffers.reset()
+	if err := r.create(screenWidth, screenHeight); err != nil {
+		log.Printf("failed to remake the render target: %v", err)
+	}
+	r.updateTexture()
+}
+
+// sampled is the texture that the drawings sampling the target read
+func (r *GLRenderTarget) sampled() gl.Texture {
+	if r.options.Format == RenderTargetFormatDepth {
+		return r.buffers.depthTexture
+	}
+	return r.buffers.opaqueTexture
+}
+
+func (r *GLRenderTarget) updateTexture() {
+	if r.texture == nil {
+		return
+	}
+	// The textures of targets that follow the screen are remade with it,
+	// the texture is pointed at the new ones when they are
+	r.texture.RenderId = TextureId(r.sampled())
+	r.texture.Width = int(r.width)
+	r.texture.Height = int(r.height)
+}
+
+func (r *GLRenderer) RenderTargetTexture(target RenderTarget) (*Texture, error) {
+	rt := target.(*GLRenderTarget)
+	if rt.texture == nil {
+		rt.texture = &Texture{Key: "render target", renderTarget: rt}
+	}
+	rt.updateTexture()
+	return rt.texture, nil
+}
+
+func (r *GLRenderer) DestroyRenderTarget(target RenderTarget) {
+	rt := target.(*GLRenderTarget)
+	r.renderTargets = slices.DeleteFunc(r.renderTargets, func(t *GLRenderTarget) bool { return t == rt })
+	rt.buffers.reset()
+	rt.texture = nil
 }
 
-func newRenderTarget(renderer Renderer) (GLRenderTarget, error) {
-	return GLRenderTarget{}, nil
+// ReadRenderTarget reads the color of the target right away, RGBA16F
+// targets are read as floats and clamped into the image, depth targets put
+// the depth in the red, green and blue
+func (r *GLRenderer) ReadRenderTarget(target RenderTarget, done func(*image.RGBA, error)) {
+	rt := target.(*GLRenderTarget)
+	w, h := int(rt.width), int(rt.height)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	gl.BindFrameBuffer(gl.ReadFrameBuffer, rt.buffers.opaqueFBO)
+	gl.PixelStorei(gl.PackAlignment, 1)
+	if rt.options.Format == RenderTargetFormatDepth {
+		depths := make([]float32, w*h)
+		gl.ReadPixels(0, 0, rt.width, rt.height, gl.DepthComponent, gl.Float, unsafe.Pointer(&depths[0]))
+		for i, d := range depths {
+			g := uint8(matrix.Clamp(matrix.Float(d), 0, 1)*255 + 0.5)
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = g, g, g, 255
+		}
+	} else if rt.options.Format == RenderTargetFormatRGBA16F {
+		floats := make([]float32, w*h*4)
+		gl.ReadPixels(0, 0, rt.width, rt.height, gl.RGBA, gl.Float, unsafe.Pointer(&floats[0]))
+		for i := range floats {
+			img.Pix[i] = uint8(matrix.Clamp(matrix.Float(floats[i]), 0, 1)*255 + 0.5)
+		}
+	} else {
+		gl.ReadPixels(0, 0, rt.width, rt.height, gl.RGBA, gl.UnsignedByte, unsafe.Pointer(&img.Pix[0]))
+	}
+	gl.UnBindFrameBuffer(gl.ReadFrameBuffer)
+	// GL reads the rows from the bottom up, the image goes from the top down
+	row := make([]byte, img.Stride)
+	for y := 0; y < h/2; y++ {
+		top := img.Pix[y*img.Stride : (y+1)*img.Stride]
+		bottom := img.Pix[(h-1-y)*img.Stride : (h-y)*img.Stride]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+	done(img, nil)
 }
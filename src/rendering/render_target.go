@@ -37,15 +37,145 @@
 
 package rendering
 
-import "kaiju/matrix"
+import (
+	"errors"
+	"image"
+	"kaiju/matrix"
+)
 
-type RenderTarget interface{}
+// RenderTargetFormat is what a render target stores for each pixel
+type RenderTargetFormat int
+
+const (
+	// RenderTargetFormatRGBA8 stores 8 bits per channel, colors are clamped
+	// between 0 and 1
+	RenderTargetFormatRGBA8 RenderTargetFormat = iota
+	// RenderTargetFormatRGBA16F stores floating point colors that can go
+	// above 1, for HDR effects like bloom and tonemapping
+	RenderTargetFormatRGBA16F
+	// RenderTargetFormatDepth keeps the depth of what was drawn, sampling or
+	// reading the target gives the depth in the red, green and blue, from 0
+	// at the near plane to 1 at the far one
+	RenderTargetFormatDepth
+)
+
+// RenderTargetResize is how a render target follows the size of the screen
+type RenderTargetResize int
+
+const (
+	// RenderTargetResizeScreen keeps the target at the size of the screen
+	// times the scale of the options
+	RenderTargetResizeScreen RenderTargetResize = iota
+	// RenderTargetResizeFixed keeps the target at the width and height of
+	// the options
+	RenderTargetResizeFixed
+)
+
+// RenderTargetOptions describe a render target, the zero value is an RGBA8
+// target that is always the size of the screen
+type RenderTargetOptions struct {
+	// Width and Height are the size of targets with a fixed size
+	Width  int
+	Height int
+	// Scale is multiplied with the screen size for targets that follow the
+	// screen, 0 is the same as 1
+	Scale  matrix.Float
+	Format RenderTargetFormat
+	// Samples is the MSAA sample count, 0 and 1 turn it off
+	Samples int
+	Resize  RenderTargetResize
+}
+
+// size is the size of the target for the size of the screen
+func (o RenderTargetOptions) size(screenWidth, screenHeight int) (int, int) {
+	if o.Resize == RenderTargetResizeFixed {
+		return o.Width, o.Height
+	}
+	scale := o.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	return max(1, int(matrix.Float(screenWidth)*scale)), max(1, int(matrix.Float(screenHeight)*scale))
+}
+
+func (o RenderTargetOptions) validate() error {
+	if o.Resize == RenderTargetResizeFixed && (o.Width <= 0 || o.Height <= 0) {
+		return errors.New("a render target with a fixed size needs a width and height")
+	}
+	if o.Samples < 0 {
+		return errors.New("the sample count of a render target can not be negative")
+	}
+	return nil
+}
+
+// RenderTarget is an image drawings can be drawn into with
+// Drawings.RenderToTarget, it can be sampled by other drawings through
+// RenderTargetTexture and read back with ReadRenderTargetAsync
+type RenderTarget interface {
+	Width() int
+	Height() int
+	Options() RenderTargetOptions
+}
 
 type RenderTargetDraw struct {
 	Target RenderTarget
 	Rect   matrix.Vec4
 }
 
+// NewRenderTarget creates an RGBA8 target that is the size of the screen
 func NewRenderTarget(renderer Renderer) (RenderTarget, error) {
-	return newRenderTarget(renderer)
+	return NewRenderTargetWithOptions(renderer, RenderTargetOptions{})
+}
+
+// NewRenderTargetWithOptions creates a target of the size, format and
+// sample count of the options. Targets that follow the screen are resized
+// by the renderer when the screen is
+func NewRenderTargetWithOptions(renderer Renderer, options RenderTargetOptions) (RenderTarget, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	return renderer.CreateRenderTarget(options)
+}
+
+// DestroyRenderTarget releases a target made with NewRenderTarget or
+// NewRenderTargetWithOptions, the target must not be drawn with after
+func DestroyRenderTarget(renderer Renderer, target RenderTarget) {
+	renderer.DestroyRenderTarget(target)
+}
+
+// RenderTargetTexture is a texture that samples the target, so what was
+// drawn into the target can be drawn on other drawings like a minimap or a
+// security camera screen. The target should be drawn to before the
+// drawings that use the texture
+func RenderTargetTexture(renderer Renderer, target RenderTarget) (*Texture, error) {
+	return renderer.RenderTargetTexture(target)
+}
+
+// ReadRenderTarget copies the pixels of the target back into an image, the
+// target should have been drawn to with Drawings.RenderToTarget first. The
+// software and OpenGL renderers read back right away, Vulkan needs
+// ReadRenderTargetAsync
+func ReadRenderTarget(renderer Renderer, target RenderTarget) (*image.RGBA, error) {
+	if _, ok := renderer.(laterTargetReader); ok {
+		return nil, errors.New("reading back render targets right away is not supported by this renderer")
+	}
+	var img *image.RGBA
+	var err error
+	renderer.ReadRenderTarget(target, func(read *image.RGBA, readErr error) {
+		img, err = read, readErr
+	})
+	return img, err
+}
+
+// laterTargetReader is a renderer that only has the pixels of a target
+// during a later frame, so it can't be read back right away
+type laterTargetReader interface {
+	readsTargetsLater()
+}
+
+// ReadRenderTargetAsync copies the pixels of the target into an image once
+// what was drawn into it this frame is done. The software and OpenGL
+// renderers call done right away, Vulkan calls it during a later frame
+func ReadRenderTargetAsync(renderer Renderer, target RenderTarget, done func(*image.RGBA, error)) {
+	renderer.ReadRenderTarget(target, done)
 }
@@ -39,24 +39,403 @@
 
 package rendering
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"image"
+	"kaiju/matrix"
+	"math"
+	"slices"
+	"unsafe"
+
+	vk "github.com/KaijuEngine/go-vulkan"
+)
 
 type VKRenderTarget struct {
-	oit oitFrameBuffers
+	oit     oitFrameBuffers
+	options RenderTargetOptions
+	// pass is the render pass for the color format and sample count of the
+	// target, the pass of the screen for the targets that match it
+	pass *oitPass
+	// texture samples the color of the target, or the depth for depth
+	// targets, it is created the first time the target is drawn with
+	texture *Texture
 }
 
-func newRenderTarget(renderer Renderer) (VKRenderTarget, error) {
-	vr := renderer.(*Vulkan)
-	target := VKRenderTarget{}
+func (vr *Vulkan) CreateRenderTarget(options RenderTargetOptions) (RenderTarget, error) {
+	target := &VKRenderTarget{options: options}
+	target.oit.sampleDepth = options.Format == RenderTargetFormatDepth
+	if options.Format == RenderTargetFormatRGBA16F {
+		target.oit.colorFormat = vk.FormatR16g16b16a16Sfloat
+	}
+	if options.Samples > 1 {
+		if options.Format == RenderTargetFormatDepth {
+			return nil, errors.New("depth render targets can not be multisampled")
+		}
+		samples := vk.SampleCountFlagBits(options.Samples)
+		if samples&(samples-1) != 0 || samples > vr.msaaSamples {
+			return nil, fmt.Errorf("the device can not draw with %d samples, it supports up to %d",
+				options.Samples, vr.msaaSamples)
+		}
+		target.oit.samples = samples
+	}
+	target.resize(vr)
 	if !target.oit.createImages(vr) {
-		return target, errors.New("failed to create render target images")
+		target.oit.reset(vr)
+		return nil, errors.New("failed to create render target images")
 	}
-	if !target.oit.createBuffers(vr, &vr.oitPass) {
-		return target, errors.New("failed to create render target buffers")
+	target.pass = vr.targetPass(&target.oit)
+	if target.pass == nil {
+		target.oit.reset(vr)
+		return nil, errors.New("failed to create the render target render passes")
 	}
+	if !target.oit.createBuffers(vr, target.pass) {
+		target.oit.reset(vr)
+		return nil, errors.New("failed to create render target buffers")
+	}
+	target.oit.createSetsAndSamplers(vr, target.pass)
+	vr.renderTargets = append(vr.renderTargets, target)
 	return target, nil
 }
 
+// targetPass finds the render pass for the color format and sample count of
+// the buffers, it is created the first time a target needs it
+func (vr *Vulkan) targetPass(buffers *oitFrameBuffers) *oitPass {
+	format, samples := buffers.format(), buffers.sampleCount()
+	if format == vr.oitPass.colorFormat && samples == vr.oitPass.samples {
+		return &vr.oitPass
+	}
+	for _, p := range vr.oitPasses {
+		if p.colorFormat == format && p.samples == samples {
+			return p
+		}
+	}
+	pass := &oitPass{
+		compositeShader: vr.oitPass.compositeShader,
+		compositeQuad:   vr.oitPass.compositeQuad,
+	}
+	if buffers.multisampled() {
+		pass.compositeShader = vr.compositeShaderMS
+	}
+	if !pass.createOitResources(vr, buffers) {
+		pass.reset(vr)
+		return nil
+	}
+	vr.oitPasses = append(vr.oitPasses, pass)
+	return pass
+}
+
+func (r *VKRenderTarget) Width() int                   { return int(r.oit.width) }
+func (r *VKRenderTarget) Height() int                  { return int(r.oit.height) }
+func (r *VKRenderTarget) Options() RenderTargetOptions { return r.options }
+
 func (r *VKRenderTarget) reset(vr *Vulkan) {
 	r.oit.reset(vr)
 }
+
+// resize sets the size the images of the target are created at from the
+// options and the size of the swap chain
+func (r *VKRenderTarget) resize(vr *Vulkan) {
+	w, h := r.options.size(int(vr.swapChainExtent.Width), int(vr.swapChainExtent.Height))
+	r.oit.width, r.oit.height = uint32(w), uint32(h)
+}
+
+// remake creates the images of a target that follows the screen again at
+// the new size of the swap chain
+func (r *VKRenderTarget) remake(vr *Vulkan) {
+	r.oit.reset(vr)
+	r.resize(vr)
+	r.oit.createImages(vr)
+	r.oit.createBuffers(vr, r.pass)
+	r.oit.createSamplers(vr)
+	if r.texture != nil {
+		vr.RenderTargetTexture(r)
+	}
+}
+
+// sampled is the image that the texture of the target reads
+func (r *VKRenderTarget) sampled() *TextureId {
+	if r.options.Format == RenderTargetFormatDepth {
+		return &r.oit.depth
+	}
+	return r.oit.output()
+}
+
+func depthAspect(id *TextureId) vk.ImageAspectFlags {
+	aspect := vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+	if id.Format == vk.FormatD32SfloatS8Uint || id.Format == vk.FormatD24UnormS8Uint {
+		aspect |= vk.ImageAspectFlags(vk.ImageAspectStencilBit)
+	}
+	return aspect
+}
+
+// beginSampling moves the sampled image of the target into the layout
+// shaders read it in, endSampling moves it back so it can be drawn to
+func (r *VKRenderTarget) beginSampling(vr *Vulkan, cmd vk.CommandBuffer) {
+	aspect := vk.ImageAspectFlags(vk.ImageAspectColorBit)
+	if r.options.Format == RenderTargetFormatDepth {
+		aspect = depthAspect(&r.oit.depth)
+	}
+	vr.transitionImageLayout(r.sampled(), vk.ImageLayoutShaderReadOnlyOptimal,
+		aspect, vk.AccessFlags(vk.AccessShaderReadBit), cmd)
+}
+
+func (r *VKRenderTarget) endSampling(vr *Vulkan, cmd vk.CommandBuffer) {
+	if r.options.Format == RenderTargetFormatDepth {
+		vr.transitionImageLayout(&r.oit.depth, vk.ImageLayoutDepthStencilAttachmentOptimal,
+			depthAspect(&r.oit.depth), vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit), cmd)
+		return
+	}
+	vr.transitionImageLayout(r.oit.output(), vk.ImageLayoutColorAttachmentOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectColorBit),
+		vk.AccessFlags(vk.AccessColorAttachmentReadBit|vk.AccessColorAttachmentWriteBit), cmd)
+}
+
+func (vr *Vulkan) RenderTargetTexture(target RenderTarget) (*Texture, error) {
+	rt := target.(*VKRenderTarget)
+	sampled := rt.sampled()
+	if sampled.Sampler == vk.Sampler(vk.NullHandle) {
+		// Not every device can filter depth formats linearly
+		filter := vk.FilterLinear
+		if rt.options.Format == RenderTargetFormatDepth {
+			filter = vk.FilterNearest
+		}
+		if !vr.createTextureSampler(&sampled.Sampler, sampled.MipLevels, filter) {
+			return nil, errors.New("failed to create the render target sampler")
+		}
+	}
+	if rt.texture == nil {
+		rt.texture = &Texture{Key: "render target", renderTarget: rt}
+	}
+	// The images of targets that follow the screen are remade with the swap
+	// chain, the texture is pointed at the new images when they are
+	rt.texture.RenderId = *sampled
+	rt.texture.Width = int(sampled.Width)
+	rt.texture.Height = int(sampled.Height)
+	return rt.texture, nil
+}
+
+func (vr *Vulkan) DestroyRenderTarget(target RenderTarget) {
+	rt := target.(*VKRenderTarget)
+	vk.DeviceWaitIdle(vr.device)
+	vr.finishRenderTargetReads()
+	vr.pendingReads = slices.DeleteFunc(vr.pendingReads, func(read renderTargetRead) bool {
+		if read.target == rt {
+			read.done(nil, errors.New("the render target was destroyed before it was read"))
+			return true
+		}
+		return false
+	})
+	vr.renderTargets = slices.DeleteFunc(vr.renderTargets, func(t *VKRenderTarget) bool { return t == rt })
+	rt.reset(vr)
+	// The sets are copied out of the target, cgo refuses a pointer into
+	// memory that also holds Go pointers
+	sets := rt.oit.descriptorSets
+	vk.FreeDescriptorSets(vr.device, rt.oit.descriptorPool, uint32(len(sets)), &sets[0])
+	rt.texture = nil
+}
+
+// renderTargetRead is a copy of the color of a target into memory the CPU
+// can read, it is recorded after the frame is submitted and is finished
+// once its fence is signaled
+type renderTargetRead struct {
+	target  *VKRenderTarget
+	done    func(*image.RGBA, error)
+	width   uint32
+	height  uint32
+	format  vk.Format
+	buffer  vk.Buffer
+	memory  vk.DeviceMemory
+	command vk.CommandBuffer
+	fence   vk.Fence
+}
+
+func (vr *Vulkan) ReadRenderTarget(target RenderTarget, done func(*image.RGBA, error)) {
+	rt := target.(*VKRenderTarget)
+	vr.pendingReads = append(vr.pendingReads, renderTargetRead{target: rt, done: done})
+}
+
+func (vr *Vulkan) readsTargetsLater() {}
+
+// submitRenderTargetReads copies the targets that were asked to be read
+// this frame, it is called after the commands of the frame are submitted
+func (vr *Vulkan) submitRenderTargetReads() {
+	for i := range vr.pendingReads {
+		read := vr.pendingReads[i]
+		if err := vr.submitRenderTargetRead(&read); err != nil {
+			vr.freeRenderTargetRead(&read)
+			read.done(nil, err)
+			continue
+		}
+		vr.runningReads = append(vr.runningReads, read)
+	}
+	vr.pendingReads = vr.pendingReads[:0]
+}
+
+func (vr *Vulkan) submitRenderTargetRead(read *renderTargetRead) error {
+	img := read.target.sampled()
+	read.width, read.height = uint32(img.Width), uint32(img.Height)
+	read.format = img.Format
+	pixelSize := uint32(4)
+	switch read.format {
+	case vk.FormatR16g16b16a16Sfloat:
+		pixelSize = 8
+	case vk.FormatD16Unorm, vk.FormatD16UnormS8Uint:
+		pixelSize = 2
+	}
+	// Only the depth of a depth and stencil image is copied, the layout
+	// changes still have to name both
+	aspect := vk.ImageAspectFlags(vk.ImageAspectColorBit)
+	copyAspect := aspect
+	layout := vk.ImageLayoutColorAttachmentOptimal
+	access := vk.AccessFlags(vk.AccessColorAttachmentReadBit | vk.AccessColorAttachmentWriteBit)
+	if read.target.options.Format == RenderTargetFormatDepth {
+		aspect = depthAspect(img)
+		copyAspect = vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+		layout = vk.ImageLayoutDepthStencilAttachmentOptimal
+		access = vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit)
+	}
+	size := vk.DeviceSize(read.width * read.height * pixelSize)
+	if !vr.CreateBuffer(size, vk.BufferUsageFlags(vk.BufferUsageTransferDstBit),
+		vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit),
+		&read.buffer, &read.memory) {
+		return errors.New("failed to create the render target read back buffer")
+	}
+	fInfo := vk.FenceCreateInfo{SType: vk.StructureTypeFenceCreateInfo}
+	var fence vk.Fence
+	if vk.CreateFence(vr.device, &fInfo, nil, &fence) != vk.Success {
+		return errors.New("failed to create the render target read back fence")
+	}
+	vr.dbg.add(uintptr(unsafe.Pointer(fence)))
+	read.fence = fence
+	allocInfo := vk.CommandBufferAllocateInfo{}
+	allocInfo.SType = vk.StructureTypeCommandBufferAllocateInfo
+	allocInfo.Level = vk.CommandBufferLevelPrimary
+	allocInfo.CommandPool = vr.commandPool
+	allocInfo.CommandBufferCount = 1
+	commandBuffer := make([]vk.CommandBuffer, allocInfo.CommandBufferCount)
+	if vk.AllocateCommandBuffers(vr.device, &allocInfo, commandBuffer) != vk.Success {
+		return errors.New("failed to allocate the render target read back commands")
+	}
+	read.command = commandBuffer[0]
+	beginInfo := vk.CommandBufferBeginInfo{}
+	beginInfo.SType = vk.StructureTypeCommandBufferBeginInfo
+	beginInfo.Flags = vk.CommandBufferUsageFlags(vk.CommandBufferUsageOneTimeSubmitBit)
+	vk.BeginCommandBuffer(read.command, &beginInfo)
+	vr.transitionImageLayout(img, vk.ImageLayoutTransferSrcOptimal,
+		aspect, vk.AccessFlags(vk.AccessTransferReadBit), read.command)
+	region := vk.BufferImageCopy{}
+	region.ImageSubresource.AspectMask = copyAspect
+	region.ImageSubresource.LayerCount = 1
+	region.ImageExtent = vk.Extent3D{Width: read.width, Height: read.height, Depth: 1}
+	vk.CmdCopyImageToBuffer(read.command, img.Image, vk.ImageLayoutTransferSrcOptimal,
+		read.buffer, 1, []vk.BufferImageCopy{region})
+	vr.transitionImageLayout(img, layout, aspect, access, read.command)
+	vk.EndCommandBuffer(read.command)
+	submitInfo := vk.SubmitInfo{}
+	submitInfo.SType = vk.StructureTypeSubmitInfo
+	submitInfo.CommandBufferCount = 1
+	submitInfo.PCommandBuffers = commandBuffer
+	if vk.QueueSubmit(vr.graphicsQueue, 1, []vk.SubmitInfo{submitInfo}, read.fence) != vk.Success {
+		return errors.New("failed to submit the render target read back commands")
+	}
+	return nil
+}
+
+// finishRenderTargetReads hands the images of the copies that are done to
+// their callbacks, the 8 bit color images are BGRA so they are swizzled to
+// RGBA, the half float ones are clamped into 8 bits and the depth is put
+// in the red, green and blue
+func (vr *Vulkan) finishRenderTargetReads() {
+	for i := 0; i < len(vr.runningReads); {
+		read := vr.runningReads[i]
+		if vk.GetFenceStatus(vr.device, read.fence) != vk.Success {
+			i++
+			continue
+		}
+		img := image.NewRGBA(image.Rect(0, 0, int(read.width), int(read.height)))
+		var data unsafe.Pointer
+		if read.target.options.Format == RenderTargetFormatDepth {
+			vr.readDepth(&read, img)
+		} else if read.format == vk.FormatR16g16b16a16Sfloat {
+			vk.MapMemory(vr.device, read.memory, 0, vk.DeviceSize(len(img.Pix)*2), 0, &data)
+			halves := unsafe.Slice((*uint16)(data), len(img.Pix))
+			for p := range img.Pix {
+				img.Pix[p] = uint8(matrix.Clamp(halfToFloat(halves[p]), 0, 1)*255 + 0.5)
+			}
+		} else {
+			vk.MapMemory(vr.device, read.memory, 0, vk.DeviceSize(len(img.Pix)), 0, &data)
+			copy(img.Pix, unsafe.Slice((*byte)(data), len(img.Pix)))
+			for p := 0; p < len(img.Pix); p += 4 {
+				img.Pix[p], img.Pix[p+2] = img.Pix[p+2], img.Pix[p]
+			}
+		}
+		vk.UnmapMemory(vr.device, read.memory)
+		vr.freeRenderTargetRead(&read)
+		vr.runningReads = slices.Delete(vr.runningReads, i, i+1)
+		read.done(img, nil)
+	}
+}
+
+// readDepth fills the image with the copied depth, 16 bit depth is packed
+// in 2 bytes and the 24 bit depth in the low bits of 4
+func (vr *Vulkan) readDepth(read *renderTargetRead, img *image.RGBA) {
+	count := int(read.width * read.height)
+	var data unsafe.Pointer
+	var depth func(i int) matrix.Float
+	switch read.format {
+	case vk.FormatD16Unorm, vk.FormatD16UnormS8Uint:
+		vk.MapMemory(vr.device, read.memory, 0, vk.DeviceSize(count*2), 0, &data)
+		values := unsafe.Slice((*uint16)(data), count)
+		depth = func(i int) matrix.Float { return matrix.Float(values[i]) / 0xFFFF }
+	case vk.FormatD32Sfloat, vk.FormatD32SfloatS8Uint:
+		vk.MapMemory(vr.device, read.memory, 0, vk.DeviceSize(count*4), 0, &data)
+		values := unsafe.Slice((*float32)(data), count)
+		depth = func(i int) matrix.Float { return matrix.Float(values[i]) }
+	default:
+		vk.MapMemory(vr.device, read.memory, 0, vk.DeviceSize(count*4), 0, &data)
+		values := unsafe.Slice((*uint32)(data), count)
+		depth = func(i int) matrix.Float { return matrix.Float(values[i]&0xFFFFFF) / 0xFFFFFF }
+	}
+	for i := range count {
+		d := uint8(matrix.Clamp(depth(i), 0, 1)*255 + 0.5)
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = d, d, d, 255
+	}
+}
+
+func (vr *Vulkan) freeRenderTargetRead(read *renderTargetRead) {
+	if read.command != vk.CommandBuffer(vk.NullHandle) {
+		vk.FreeCommandBuffers(vr.device, vr.commandPool, 1, []vk.CommandBuffer{read.command})
+	}
+	if read.fence != vk.Fence(vk.NullHandle) {
+		vk.DestroyFence(vr.device, read.fence, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(read.fence)))
+	}
+	if read.buffer != vk.Buffer(vk.NullHandle) {
+		vk.DestroyBuffer(vr.device, read.buffer, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(read.buffer)))
+		vk.FreeMemory(vr.device, read.memory, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(read.memory)))
+	}
+}
+
+// halfToFloat converts an IEEE 754 half precision float
+func halfToFloat(h uint16) matrix.Float {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h) & 0x3FF
+	switch {
+	case exp == 0 && mant == 0:
+		return matrix.Float(math.Float32frombits(sign))
+	case exp == 0:
+		// Subnormal halves have no implicit leading bit
+		f := matrix.Float(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case exp == 0x1F:
+		return matrix.Float(math.Float32frombits(sign | 0x7F800000 | mant<<13))
+	}
+	return matrix.Float(math.Float32frombits(sign | (exp+112)<<23 | mant<<13))
+}
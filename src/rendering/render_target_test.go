@@ -0,0 +1,127 @@
+/*****************************************************************************/
+/* render_target_test.go                                                     */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"image"
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestRenderTargetResize(t *testing.T) {
+	r := NewSoftwareRenderer(64, 64)
+	half, err := NewRenderTargetWithOptions(r, RenderTargetOptions{Scale: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := NewRenderTargetWithOptions(r, RenderTargetOptions{
+		Width: 10, Height: 20, Resize: RenderTargetResizeFixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Resize(32, 16)
+	if half.Width() != 16 || half.Height() != 8 {
+		t.Fatalf("expected the target to follow the screen at 16x8, got %dx%d", half.Width(), half.Height())
+	}
+	if fixed.Width() != 10 || fixed.Height() != 20 {
+		t.Fatalf("expected the fixed target to stay 10x20, got %dx%d", fixed.Width(), fixed.Height())
+	}
+	if _, err := NewRenderTargetWithOptions(r, RenderTargetOptions{Resize: RenderTargetResizeFixed}); err == nil {
+		t.Fatal("expected a fixed target without a size to fail")
+	}
+	DestroyRenderTarget(r, half)
+	if len(r.targets) != 0 {
+		t.Fatal("expected the destroyed target to no longer follow the screen")
+	}
+}
+
+func TestRenderTargetFormats(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	d := NewDrawings()
+	softwareTestQuad(r, &d, shader, NewMeshQuad(&caches.meshes), matrix.Vec3{}, matrix.ColorRed())
+	softwareTestRender(r, &d, caches)
+	r.ClearColor = matrix.Color{2, 0, 0, 1}
+	hdr, _ := NewRenderTargetWithOptions(r, RenderTargetOptions{Format: RenderTargetFormatRGBA16F})
+	ldr, _ := NewRenderTarget(r)
+	d.RenderToTarget(r, hdr)
+	d.RenderToTarget(r, ldr)
+	if c := hdr.(*SoftwareRenderTarget).Pixel(0, 0); !matrix.Approx(c.R(), 2) {
+		t.Fatalf("expected RGBA16F to keep colors above 1, got %v", c)
+	}
+	if c := ldr.(*SoftwareRenderTarget).Pixel(0, 0); !matrix.Approx(c.R(), 1) {
+		t.Fatalf("expected RGBA8 to clamp colors to 1, got %v", c)
+	}
+	texture, err := RenderTargetTexture(r, hdr)
+	if err != nil || texture.Width != hdr.Width() || texture.Height != hdr.Height() {
+		t.Fatal("expected a texture the size of the target")
+	}
+}
+
+func TestRenderTargetMSAA(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	r.ClearColor = matrix.ColorBlack()
+	d := NewDrawings()
+	softwareTestQuad(r, &d, shader, NewMeshQuad(&caches.meshes), matrix.Vec3{}, matrix.ColorRed())
+	softwareTestRender(r, &d, caches)
+	target, _ := NewRenderTargetWithOptions(r, RenderTargetOptions{
+		Width: 15, Height: 15, Samples: 4, Resize: RenderTargetResizeFixed})
+	d.RenderToTarget(r, target)
+	var img *image.RGBA
+	ReadRenderTargetAsync(r, target, func(i *image.RGBA, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		img = i
+	})
+	if img == nil || img.Rect.Dx() != 15 || img.Rect.Dy() != 15 {
+		t.Fatal("expected the read back image to be the size of the target")
+	}
+	if c := img.RGBAAt(7, 7); c.R != 255 || c.G != 0 {
+		t.Fatalf("expected the center to be red, got %v", c)
+	}
+	// The samples along the edge of the quad are averaged
+	edge := false
+	for x := range 15 {
+		if c := img.RGBAAt(x, 7); c.R > 0 && c.R < 255 {
+			edge = true
+		}
+	}
+	if !edge {
+		t.Fatal("expected the edge of the quad to be blended with MSAA")
+	}
+}
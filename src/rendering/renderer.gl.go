@@ -40,8 +40,8 @@
 package rendering
 
 import (
+	"fmt"
 	"kaiju/assets"
-	"kaiju/cameras"
 	"kaiju/gl"
 	"kaiju/matrix"
 	"log"
@@ -61,23 +61,23 @@ func (m MeshIdGL) IsValid() bool {
 }
 
 type GLRenderer struct {
-	globalShaderData     GlobalShaderData
-	opaqueFBO            gl.Handle
-	transparentAccumFBO  gl.Handle
-	transparentRevealFBO gl.Handle
-	opaqueTexture        gl.Texture
-	depthTexture         gl.Texture
-	accumTexture         gl.Texture
-	revealTexture        gl.Texture
-	revealAccumTexture   gl.Texture
-	revealRevealTexture  gl.Texture
-	colorBuffer          gl.Handle
-	compositeShader      *Shader
-	hdrShader            *Shader
-	composeQuad          *Mesh
-	hdr                  int
-	exposure             float32
-	preRuns              []func()
+	globalShaderData GlobalShaderData
+	defaultTarget    GLRenderTarget
+	renderTargets    []*GLRenderTarget
+	width            int32
+	height           int32
+	compositeShader  *Shader
+	hdrShader        *Shader
+	composeQuad      *Mesh
+	hdr              int
+	exposure         float32
+	jointTexture     gl.Handle
+	jointPalette     [MaxJointPalette]matrix.Mat4
+	morphTexture     gl.Handle
+	morphDeltas      []matrix.Vec4
+	morphVersion     uint64
+	shadowMaps       glShadowMaps
+	preRuns          []func()
 }
 
 func NewGLRenderer() *GLRenderer {
@@ -99,7 +99,16 @@ func NewGLRenderer() *GLRenderer {
 }
 
 func (r *GLRenderer) Initialize(caches RenderCaches, width, height int32) error {
-	r.setupOITFrameBuffer(width, height)
+	r.width, r.height = width, height
+	// The default target is HDR so the post effects have the colors above 1
+	// to work with before the frame is tonemapped
+	r.defaultTarget.options = RenderTargetOptions{Format: RenderTargetFormatRGBA16F}
+	if err := r.defaultTarget.create(width, height); err != nil {
+		return err
+	}
+	if err := r.shadowMaps.create(); err != nil {
+		return err
+	}
 	r.composeQuad = NewMeshUnitQuad(caches.MeshCache())
 	r.compositeShader = caches.ShaderCache().Shader(
 		assets.ShaderOitCompositeVert, assets.ShaderOitCompositeFrag, "", "", "")
@@ -108,10 +117,10 @@ func (r *GLRenderer) Initialize(caches RenderCaches, width, height int32) error
 	return nil
 }
 
-func createShaderObject(assetDatabase *assets.Database, shaderKey string, shaderType gl.Handle, defines []string) (gl.Handle, string) {
+func createShaderObject(assetDatabase *assets.Database, shaderKey string, shaderType gl.Handle, defines []string) (gl.Handle, string, error) {
 	src, err := assetDatabase.ReadText(shaderKey)
 	if err != nil {
-		panic(err)
+		return 0, "", fmt.Errorf("failed to load shader %s: %w", shaderKey, err)
 	}
 	// TODO:  Setup this so it supports other versions
 	const vulkanVersion = "#version 460"
@@ -150,7 +159,7 @@ func createShaderObject(assetDatabase *assets.Database, shaderKey string, shader
 			log.Fatalf("Error compiling shader %s: There was an error compiling the shader and could not retrieve the error log for unknown reasons\n", sType)
 		}
 	}
-	return shaderObj, src
+	return shaderObj, src, nil
 }
 
 func linkShader(vert, frag, geom, tesc, tese gl.Handle) gl.Handle {
@@ -176,19 +185,42 @@ func linkShader(vert, frag, geom, tesc, tese gl.Handle) gl.Handle {
 	return shader
 }
 
-func (r *GLRenderer) CreateShader(shader *Shader, assetDatabase *assets.Database) {
+func (r *GLRenderer) CreateShader(shader *Shader, assetDatabase *assets.Database) error {
 	noDef := []string{}
-	vert, _ := createShaderObject(assetDatabase, shader.VertPath, gl.VertexShader, noDef)
-	frag, fragSrc := createShaderObject(assetDatabase, shader.FragPath, gl.FragmentShader, shader.DriverData.Defines)
-	var geom, tesc, tese gl.Handle
+	var vert, frag, geom, tesc, tese gl.Handle
+	deleteStages := func() {
+		for _, s := range []gl.Handle{vert, frag, geom, tesc, tese} {
+			if s.IsValid() {
+				gl.DeleteShader(s)
+			}
+		}
+	}
+	var fragSrc string
+	var err error
+	if vert, _, err = createShaderObject(assetDatabase, shader.VertPath, gl.VertexShader, noDef); err != nil {
+		return err
+	}
+	if frag, fragSrc, err = createShaderObject(assetDatabase, shader.FragPath, gl.FragmentShader, shader.DriverData.Defines); err != nil {
+		deleteStages()
+		return err
+	}
 	if len(shader.GeomPath) > 0 {
-		geom, _ = createShaderObject(assetDatabase, shader.GeomPath, gl.GeometryShader, noDef)
+		if geom, _, err = createShaderObject(assetDatabase, shader.GeomPath, gl.GeometryShader, noDef); err != nil {
+			deleteStages()
+			return err
+		}
 	}
 	if len(shader.CtrlPath) > 0 {
-		tesc, _ = createShaderObject(assetDatabase, shader.CtrlPath, gl.TessControlShader, noDef)
+		if tesc, _, err = createShaderObject(assetDatabase, shader.CtrlPath, gl.TessControlShader, noDef); err != nil {
+			deleteStages()
+			return err
+		}
 	}
 	if len(shader.EvalPath) > 0 {
-		tese, _ = createShaderObject(assetDatabase, shader.EvalPath, gl.TessEvaluationShader, noDef)
+		if tese, _, err = createShaderObject(assetDatabase, shader.EvalPath, gl.TessEvaluationShader, noDef); err != nil {
+			deleteStages()
+			return err
+		}
 	}
 	shader.RenderId = linkShader(vert, frag, geom, tesc, tese)
 	gl.DeleteShader(vert)
@@ -210,6 +242,38 @@ func (r *GLRenderer) CreateShader(shader *Shader, assetDatabase *assets.Database
 		shader.SubShader.DriverData.Defines = append(
 			shader.SubShader.DriverData.Defines, "OIT")
 	}
+	return nil
+}
+
+// ReloadShader links the program of the shader again from its stages, the
+// old programs are kept when the new ones fail to build
+func (r *GLRenderer) ReloadShader(shader *Shader, assetDatabase *assets.Database) error {
+	old, sub := shader.RenderId, shader.SubShader
+	shader.SubShader = nil
+	err := r.CreateShader(shader, assetDatabase)
+	if err == nil && shader.SubShader != nil {
+		err = r.CreateShader(shader.SubShader, assetDatabase)
+	}
+	if err != nil {
+		if shader.RenderId != old {
+			gl.DeleteProgram(gl.Handle(shader.RenderId))
+		}
+		shader.RenderId, shader.SubShader = old, sub
+		return err
+	}
+	for _, program := range []gl.Handle{gl.Handle(old), subShaderProgram(sub)} {
+		if program.IsValid() {
+			gl.DeleteProgram(program)
+		}
+	}
+	return nil
+}
+
+func subShaderProgram(sub *Shader) gl.Handle {
+	if sub == nil {
+		return gl.Handle(0)
+	}
+	return gl.Handle(sub.RenderId)
 }
 
 func (r GLRenderer) FreeShader(shader *Shader) {
@@ -253,7 +317,7 @@ func (r GLRenderer) CreateMesh(mesh *Mesh, verts []Vertex, indices []uint32) {
 	gl.EnableVertexAttribArray(4)
 	pOffset += int32(unsafe.Sizeof(verts[0].Color))
 	// Vertex joint ids
-	gl.VertexAttribPointer(5, 4, gl.Int, false, stride, pOffset)
+	gl.VertexAttribIPointer(5, 4, gl.Int, stride, pOffset)
 	gl.EnableVertexAttribArray(5)
 	pOffset += int32(unsafe.Sizeof(verts[0].JointIds))
 	// Vertex joint weights
@@ -313,22 +377,43 @@ func (w *GLRenderer) TextureWritePixels(texture *Texture, x, y, width, height in
 	panic("TextureWritePixels not implemented")
 }
 
-func (r *GLRenderer) ReadyFrame(camera *cameras.StandardCamera, uiCamera *cameras.StandardCamera, runtime float32) bool {
-	r.globalShaderData.View = camera.View()
-	r.globalShaderData.Projection = camera.Projection()
-	r.globalShaderData.UIView = uiCamera.View()
-	r.globalShaderData.UIProjection = uiCamera.Projection()
-	r.globalShaderData.CameraPosition = camera.Position()
-	r.globalShaderData.UICameraPosition = uiCamera.Position()
-	r.globalShaderData.Time = runtime
-	for _, r := range vr.preRuns {
-		r()
-	}
-	vr.preRuns = vr.preRuns[:0]
+func (r *GLRenderer) ReadyFrame(frame FrameData) bool {
+	r.globalShaderData = NewGlobalShaderData(frame.Camera, frame.UICamera, frame.Lights, frame.Runtime)
+	r.readyShadowMaps(frame.Lights)
+	r.updateJointPalette(frame.Skins)
+	r.updateMorphDeltas(frame.Morphs)
+	for _, p := range r.preRuns {
+		p()
+	}
+	r.preRuns = r.preRuns[:0]
 	return true
 }
 
-func (r GLRenderer) setGlobalUniforms(shader *Shader) {
+func (r *GLRenderer) updateJointPalette(skins *Skins) {
+	if skins.fillPalette(r.jointPalette[:]) == 0 && r.jointTexture.IsValid() {
+		return
+	}
+	// Every joint matrix is 4 columns of the data texture
+	uploadDataTexture(&r.jointTexture,
+		unsafe.Pointer(&r.jointPalette[0]), len(r.jointPalette)*4)
+}
+
+func (r *GLRenderer) updateMorphDeltas(morphs *Morphs) {
+	if morphs == nil {
+		return
+	}
+	if r.morphDeltas == nil {
+		r.morphDeltas = make([]matrix.Vec4, MaxMorphDeltas)
+	}
+	version := r.morphVersion
+	_, r.morphVersion = morphs.fillDeltas(r.morphDeltas, r.morphVersion)
+	if version != r.morphVersion || !r.morphTexture.IsValid() {
+		uploadDataTexture(&r.morphTexture,
+			unsafe.Pointer(&r.morphDeltas[0]), len(r.morphDeltas))
+	}
+}
+
+func (r *GLRenderer) setGlobalUniforms(shader *Shader) {
 	sid := shader.RenderId.(gl.Handle)
 	viewLoc := gl.GetUniformLocation(sid, "globalData.view")
 	projectionLoc := gl.GetUniformLocation(sid, "globalData.projection")
@@ -344,13 +429,20 @@ func (r GLRenderer) setGlobalUniforms(shader *Shader) {
 	gl.Uniform3fv(cameraPositionLoc, &r.globalShaderData.CameraPosition)
 	gl.Uniform3fv(uiCameraPositionLoc, &r.globalShaderData.UICameraPosition)
 	gl.Uniform1f(timeLoc, r.globalShaderData.Time)
+	r.setLightUniforms(sid)
 }
 
 func (r *GLRenderer) draw(drawings []ShaderDraw) {
 	for _, sd := range drawings {
-		shaderId := sd.shader.RenderId.(gl.Handle)
+		// Shaders that failed to be created have no program to draw with
+		shaderId, ok := sd.shader.RenderId.(gl.Handle)
+		if !ok || !shaderId.IsValid() {
+			continue
+		}
 		gl.UseProgram(shaderId)
 		r.setGlobalUniforms(sd.shader)
+		bindDataTexture(shaderId, "jointSampler", glJointPaletteUnit, r.jointTexture)
+		bindDataTexture(shaderId, "morphSampler", glMorphDeltasUnit, r.morphTexture)
 		for _, draw := range sd.instanceGroups {
 			if draw.IsEmpty() || !draw.Mesh.IsReady() {
 				continue
@@ -361,6 +453,7 @@ func (r *GLRenderer) draw(drawings []ShaderDraw) {
 			gl.ActivateTexture(gl.Texture0)
 			gl.BindTexture(gl.Texture2D, draw.InstanceDriverData)
 			gl.Uniform1i(gl.GetUniformLocation(shaderId, "instanceSampler"), 0)
+			bindShadowMaps(shaderId, r.shadowMapsOf(&draw))
 			for i, texture := range draw.Textures {
 				gl.ActivateTexture(gl.Handle(int(gl.Texture1) + i))
 				gl.BindTexture(gl.Texture2D, texture.RenderId.(gl.Handle))
@@ -369,7 +462,7 @@ func (r *GLRenderer) draw(drawings []ShaderDraw) {
 			}
 			gl.BindBuffer(gl.ElementArrayBuffer, meshId.EBO)
 			gl.DrawElementsInstanced(gl.Triangles, meshId.indexCount,
-				gl.UnsignedInt, 0, int32(len(draw.Instances)))
+				gl.UnsignedInt, 0, int32(draw.VisibleCount()))
 			gl.UnBindBuffer(gl.ElementArrayBuffer)
 			gl.UnBindTexture(gl.Texture2D)
 			gl.UnBindVertexArray()
@@ -377,7 +470,12 @@ func (r *GLRenderer) draw(drawings []ShaderDraw) {
 	}
 }
 
-func (r GLRenderer) Draw(drawings []ShaderDraw) {
+func (r *GLRenderer) Draw(drawings []ShaderDraw) {
+	r.DrawToTarget(drawings, &r.defaultTarget)
+}
+
+func (r *GLRenderer) DrawToTarget(drawings []ShaderDraw, target RenderTarget) {
+	rt := target.(*GLRenderTarget)
 	solids := make([]ShaderDraw, 0)
 	transparents := make([]ShaderDraw, 0)
 	for _, sd := range drawings {
@@ -402,17 +500,21 @@ func (r GLRenderer) Draw(drawings []ShaderDraw) {
 			transparents = append(transparents, st)
 		}
 	}
-	r.solidPass(solids, matrix.ColorDarkBG())
-	r.transparentPass(transparents)
+	r.drawShadowMaps(solids)
+	gl.Viewport(0, 0, rt.width, rt.height)
+	r.solidPass(solids, matrix.ColorDarkBG(), &rt.buffers)
+	r.transparentPass(transparents, &rt.buffers)
+	r.composePass(&rt.buffers)
 }
 
-func (r *GLRenderer) SwapFrame(width, height int32) bool {
-	r.composePass()
+// BlitTargets draws the color of the targets into their rects of the
+// screen, the rects go from 0 to 1 starting at the top left
+func (r *GLRenderer) BlitTargets(targets ...RenderTargetDraw) {
 	gl.Disable(gl.DepthTest)
 	gl.DepthMask(true)
 	gl.Disable(gl.Blend)
 	gl.UnBindFrameBuffer(gl.FrameBuffer)
-	gl.Viewport(0, 0, width, height)
+	gl.Viewport(0, 0, r.width, r.height)
 	gl.ClearColor(1, 0, 1, 1)
 	gl.Clear(gl.ColorBufferBit | gl.DepthBufferBit | gl.StencilTest)
 	id := r.hdrShader.RenderId.(gl.Handle)
@@ -421,20 +523,42 @@ func (r *GLRenderer) SwapFrame(width, height int32) bool {
 	gl.BindVertexArray(meshId.VAO)
 	gl.Uniform1i(gl.GetUniformLocation(id, "hdr"), int32(r.hdr))
 	gl.Uniform1f(gl.GetUniformLocation(id, "exposure"), r.exposure)
-	gl.ActivateTexture(gl.Texture0)
-	gl.BindTexture(gl.Texture2D, r.opaqueTexture)
 	gl.BindBuffer(gl.ElementArrayBuffer, meshId.EBO)
-	gl.DrawElementsInstanced(gl.Triangles, 6, gl.UnsignedInt, 0, 1)
+	for i := range targets {
+		rt := targets[i].Target.(*GLRenderTarget)
+		area := targets[i].Rect
+		w, h := float32(r.width), float32(r.height)
+		// The viewport of OpenGL starts at the bottom left of the screen
+		x0, x1 := int32(w*area[0]), int32(w*area[2])
+		y0, y1 := int32(h*(1-area[3])), int32(h*(1-area[1]))
+		gl.Viewport(x0, y0, x1-x0, y1-y0)
+		gl.ActivateTexture(gl.Texture0)
+		gl.BindTexture(gl.Texture2D, rt.sampled())
+		gl.DrawElementsInstanced(gl.Triangles, 6, gl.UnsignedInt, 0, 1)
+	}
 	gl.UnBindBuffer(gl.ElementArrayBuffer)
 	gl.UnBindTexture(gl.Texture2D)
 	gl.UnBindVertexArray()
+	gl.Viewport(0, 0, r.width, r.height)
+}
+
+func (r *GLRenderer) SwapFrame(width, height int32) bool {
 	return true
 }
 
 func (r *GLRenderer) Resize(width, height int) {
-	gl.Viewport(0, 0, width, height)
+	r.width, r.height = int32(width), int32(height)
+	gl.Viewport(0, 0, r.width, r.height)
+	r.defaultTarget.remake(r.width, r.height)
+	for _, t := range r.renderTargets {
+		if t.options.Resize == RenderTargetResizeScreen {
+			t.remake(r.width, r.height)
+		}
+	}
 }
 
+func (r *GLRenderer) DefaultTarget() RenderTarget { return &r.defaultTarget }
+
 func (r *GLRenderer) AddPreRun(preRun func()) {
 	r.preRuns = append(r.preRuns, preRun)
 }
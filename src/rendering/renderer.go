@@ -38,15 +38,28 @@
 package rendering
 
 import (
+	"image"
 	"kaiju/assets"
 	"kaiju/cameras"
 	"kaiju/matrix"
 )
 
+// FrameData is everything the host hands the renderer to get a frame ready.
+// The lights, skins and morphs can be nil when the frame has none of them
+type FrameData struct {
+	Camera   cameras.Camera
+	UICamera cameras.Camera
+	Lights   *Lights
+	Skins    *Skins
+	Morphs   *Morphs
+	Runtime  float32
+}
+
 type Renderer interface {
 	Initialize(caches RenderCaches, width, height int32) error
-	ReadyFrame(camera cameras.Camera, uiCamera cameras.Camera, runtime float32) bool
-	CreateShader(shader *Shader, assetDatabase *assets.Database)
+	ReadyFrame(frame FrameData) bool
+	CreateShader(shader *Shader, assetDatabase *assets.Database) error
+	ReloadShader(shader *Shader, assetDatabase *assets.Database) error
 	CreateMesh(mesh *Mesh, verts []Vertex, indices []uint32)
 	CreateTexture(texture *Texture, textureData *TextureData)
 	TextureReadPixel(texture *Texture, x, y int) matrix.Color
@@ -63,4 +76,8 @@ type Renderer interface {
 	DestroyMesh(mesh *Mesh)
 	Destroy()
 	DefaultTarget() RenderTarget
+	CreateRenderTarget(options RenderTargetOptions) (RenderTarget, error)
+	DestroyRenderTarget(target RenderTarget)
+	RenderTargetTexture(target RenderTarget) (*Texture, error)
+	ReadRenderTarget(target RenderTarget, done func(*image.RGBA, error))
 }
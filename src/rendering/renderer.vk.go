@@ -41,14 +41,17 @@ package rendering
 
 import (
 	"errors"
+	"fmt"
 	"kaiju/assets"
 	"kaiju/cameras"
 	"kaiju/klib"
 	"kaiju/matrix"
 	"log"
 	"math"
+	"runtime"
 	"slices"
 	"strings"
+	"sync"
 	"unsafe"
 
 	vk "github.com/KaijuEngine/go-vulkan"
@@ -57,7 +60,7 @@ import (
 const (
 	useValidationLayers = vkUseValidationLayers
 	BytesInPixel        = 4
-	MaxCommandBuffers   = 15
+	MaxCommandBuffers   = 48
 	maxFramesInFlight   = 2
 	oitSuffix           = ".oit.spv"
 )
@@ -115,6 +118,13 @@ type Vulkan struct {
 	descriptorPools            []vk.DescriptorPool
 	globalUniformBuffers       [maxFramesInFlight]vk.Buffer
 	globalUniformBuffersMemory [maxFramesInFlight]vk.DeviceMemory
+	jointPaletteBuffers        [maxFramesInFlight]vk.Buffer
+	jointPaletteBuffersMemory  [maxFramesInFlight]vk.DeviceMemory
+	jointPalette               [MaxJointPalette]matrix.Mat4
+	morphDeltaBuffers          [maxFramesInFlight]vk.Buffer
+	morphDeltaBuffersMemory    [maxFramesInFlight]vk.DeviceMemory
+	morphDeltas                []matrix.Vec4
+	morphVersions              [maxFramesInFlight]uint64
 	pendingDeletes             []pendingDelete
 	depth                      TextureId
 	color                      TextureId
@@ -132,16 +142,44 @@ type Vulkan struct {
 	commandBuffersCount        int
 	msaaSamples                vk.SampleCountFlagBits
 	defaultTarget              VKRenderTarget
+	renderTargets              []*VKRenderTarget
+	pendingReads               []renderTargetRead
+	runningReads               []renderTargetRead
 	oitPass                    oitPass
 	preRuns                    []func()
 	dbg                        debugVulkan
-}
-
-func init() {
-	// TODO:  Fix this, to the correct loader
-	klib.Must(vk.SetDefaultGetInstanceProcAddr())
-	//vk.SetGetInstanceProcAddr(vk.GetInstanceProcAddr())
-	klib.Must(vk.Init())
+	shadowMaps                 vkShadowMaps
+	// oitPasses are the passes of render targets that have another color
+	// format or sample count than the screen
+	oitPasses         []*oitPass
+	compositeShaderMS *Shader
+	// headless renderers have no surface or swap chain, see
+	// NewVKRendererHeadless
+	headless bool
+	// validation is if the validation layers are used, headless renderers
+	// go without them on machines that do not have them
+	validation bool
+}
+
+var vkLoad struct {
+	once sync.Once
+	err  error
+}
+
+// loadVulkan binds the Vulkan functions the first time a renderer is made
+// rather than at start up so that programs and tests that never create a
+// Vulkan renderer (like those using the SoftwareRenderer) can run on
+// machines without a Vulkan driver
+func loadVulkan() error {
+	vkLoad.once.Do(func() {
+		// TODO:  Fix this, to the correct loader
+		if vkLoad.err = vk.SetDefaultGetInstanceProcAddr(); vkLoad.err != nil {
+			return
+		}
+		//vk.SetGetInstanceProcAddr(vk.GetInstanceProcAddr())
+		vkLoad.err = vk.Init()
+	})
+	return vkLoad.err
 }
 
 func (vr *Vulkan) DefaultTarget() RenderTarget { return &vr.defaultTarget }
@@ -149,9 +187,9 @@ func (vr *Vulkan) DefaultTarget() RenderTarget { return &vr.defaultTarget }
 /******************************************************************************/
 /* Helpers                                                                    */
 /******************************************************************************/
-func validationLayers() []string {
+func (vr *Vulkan) validationLayers() []string {
 	var validationLayers []string
-	if useValidationLayers {
+	if vr.validation {
 		validationLayers = append(validationLayers, "VK_LAYER_KHRONOS_validation\x00")
 	} else {
 		validationLayers = []string{}
@@ -159,7 +197,10 @@ func validationLayers() []string {
 	return validationLayers
 }
 
-func requiredDeviceExtensions() []string {
+func (vr *Vulkan) requiredDeviceExtensions() []string {
+	if vr.headless {
+		return vkDeviceExtensions()
+	}
 	return append([]string{vk.KhrSwapchainExtensionName + "\x00"}, vkDeviceExtensions()...)
 }
 
@@ -368,12 +409,28 @@ func (vr *Vulkan) createIndexBuffer(indices []uint32, indexBuffer *vk.Buffer, in
 func (vr *Vulkan) createGlobalUniformBuffers() {
 	bufferSize := vk.DeviceSize(unsafe.Sizeof(*(*GlobalShaderData)(nil)))
 	for i := uint64(0); i < maxFramesInFlight; i++ {
-		vr.CreateBuffer(bufferSize, vk.BufferUsageFlags(vk.BufferUsageUniformBufferBit), vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit), &vr.globalUniformBuffers[i], &vr.globalUniformBuffersMemory[i])
+		// The shadow passes write their view into the buffer while drawing
+		vr.CreateBuffer(bufferSize, vk.BufferUsageFlags(vk.BufferUsageUniformBufferBit|vk.BufferUsageTransferDstBit), vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit), &vr.globalUniformBuffers[i], &vr.globalUniformBuffersMemory[i])
+	}
+}
+
+func (vr *Vulkan) createJointPaletteBuffers() {
+	bufferSize := vk.DeviceSize(unsafe.Sizeof(vr.jointPalette))
+	for i := uint64(0); i < maxFramesInFlight; i++ {
+		vr.CreateBuffer(bufferSize, vk.BufferUsageFlags(vk.BufferUsageStorageBufferBit), vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit), &vr.jointPaletteBuffers[i], &vr.jointPaletteBuffersMemory[i])
+	}
+}
+
+func (vr *Vulkan) createMorphDeltaBuffers() {
+	vr.morphDeltas = make([]matrix.Vec4, MaxMorphDeltas)
+	bufferSize := vk.DeviceSize(MaxMorphDeltas) * vk.DeviceSize(unsafe.Sizeof(matrix.Vec4{}))
+	for i := uint64(0); i < maxFramesInFlight; i++ {
+		vr.CreateBuffer(bufferSize, vk.BufferUsageFlags(vk.BufferUsageStorageBufferBit), vk.MemoryPropertyFlags(vk.MemoryPropertyHostVisibleBit|vk.MemoryPropertyHostCoherentBit), &vr.morphDeltaBuffers[i], &vr.morphDeltaBuffersMemory[i])
 	}
 }
 
 func (vr *Vulkan) createDescriptorPool(counts uint32) bool {
-	poolSizes := make([]vk.DescriptorPoolSize, 4)
+	poolSizes := make([]vk.DescriptorPoolSize, 5)
 	poolSizes[0].Type = vk.DescriptorTypeUniformBuffer
 	poolSizes[0].DescriptorCount = counts * maxFramesInFlight
 	poolSizes[1].Type = vk.DescriptorTypeCombinedImageSampler
@@ -382,6 +439,8 @@ func (vr *Vulkan) createDescriptorPool(counts uint32) bool {
 	poolSizes[2].DescriptorCount = counts * maxFramesInFlight
 	poolSizes[3].Type = vk.DescriptorTypeInputAttachment
 	poolSizes[3].DescriptorCount = counts * maxFramesInFlight
+	poolSizes[4].Type = vk.DescriptorTypeStorageBuffer
+	poolSizes[4].DescriptorCount = counts * maxFramesInFlight
 
 	poolInfo := vk.DescriptorPoolCreateInfo{}
 	poolInfo.SType = vk.StructureTypeDescriptorPoolCreateInfo
@@ -423,20 +482,73 @@ func (vr *Vulkan) createDescriptorSet(layout vk.DescriptorSetLayout, poolIdx int
 	return sets, vr.descriptorPools[poolIdx], nil
 }
 
-func (vr *Vulkan) updateGlobalUniformBuffer(camera cameras.Camera, uiCamera cameras.Camera, runtime float32) {
-	ubo := GlobalShaderData{
-		View:             camera.View(),
-		UIView:           uiCamera.View(),
-		Projection:       camera.Projection(),
-		UIProjection:     uiCamera.Projection(),
-		CameraPosition:   camera.Position(),
-		UICameraPosition: uiCamera.Position(),
-		Time:             runtime,
-	}
+func (vr *Vulkan) updateGlobalUniformBuffer(camera cameras.Camera, uiCamera cameras.Camera, lights *Lights, runtime float32) {
+	ubo := NewGlobalShaderData(camera, uiCamera, lights, runtime)
 	var data unsafe.Pointer
 	vk.MapMemory(vr.device, vr.globalUniformBuffersMemory[vr.currentFrame], 0, vk.DeviceSize(unsafe.Sizeof(ubo)), 0, &data)
 	vk.Memcopy(data, klib.StructToByteArray(ubo))
 	vk.UnmapMemory(vr.device, vr.globalUniformBuffersMemory[vr.currentFrame])
+	vr.readyShadowMaps(&ubo, lights)
+}
+
+func (vr *Vulkan) updateJointPalette(skins *Skins) {
+	used := skins.fillPalette(vr.jointPalette[:])
+	if used == 0 {
+		return
+	}
+	size := vk.DeviceSize(used) * vk.DeviceSize(unsafe.Sizeof(matrix.Mat4{}))
+	var data unsafe.Pointer
+	vk.MapMemory(vr.device, vr.jointPaletteBuffersMemory[vr.currentFrame], 0, size, 0, &data)
+	vk.Memcopy(data, unsafe.Slice((*byte)(unsafe.Pointer(&vr.jointPalette[0])), size))
+	vk.UnmapMemory(vr.device, vr.jointPaletteBuffersMemory[vr.currentFrame])
+}
+
+// updateMorphDeltas only uploads the deltas when the morph targets of the
+// host changed since the buffer of the frame was last written
+func (vr *Vulkan) updateMorphDeltas(morphs *Morphs) {
+	used, version := morphs.fillDeltas(vr.morphDeltas, vr.morphVersions[vr.currentFrame])
+	if version == vr.morphVersions[vr.currentFrame] {
+		return
+	}
+	vr.morphVersions[vr.currentFrame] = version
+	if used == 0 {
+		return
+	}
+	size := vk.DeviceSize(used) * vk.DeviceSize(unsafe.Sizeof(matrix.Vec4{}))
+	var data unsafe.Pointer
+	vk.MapMemory(vr.device, vr.morphDeltaBuffersMemory[vr.currentFrame], 0, size, 0, &data)
+	vk.Memcopy(data, unsafe.Slice((*byte)(unsafe.Pointer(&vr.morphDeltas[0])), size))
+	vk.UnmapMemory(vr.device, vr.morphDeltaBuffersMemory[vr.currentFrame])
+}
+
+// usesStorageBuffer is true for shaders with a storage buffer at the
+// binding in their layout
+func usesStorageBuffer(shader *Shader, binding uint32) bool {
+	for _, t := range shader.DriverData.Types {
+		if t.Binding == binding && t.Type == vk.DescriptorTypeStorageBuffer {
+			return true
+		}
+	}
+	return false
+}
+
+// storageBufferWrites are the writes of the joint palette and morph delta
+// storage buffers for the shaders that use them
+func (vr *Vulkan) storageBufferWrites(shader *Shader, set vk.DescriptorSet) []vk.WriteDescriptorSet {
+	writes := []vk.WriteDescriptorSet{}
+	if usesStorageBuffer(shader, jointPaletteBinding) {
+		info := bufferInfo(vr.jointPaletteBuffers[vr.currentFrame],
+			vk.DeviceSize(unsafe.Sizeof(vr.jointPalette)))
+		writes = append(writes, prepareSetWriteBuffer(set,
+			[]vk.DescriptorBufferInfo{info}, jointPaletteBinding, vk.DescriptorTypeStorageBuffer))
+	}
+	if usesStorageBuffer(shader, morphDeltasBinding) {
+		info := bufferInfo(vr.morphDeltaBuffers[vr.currentFrame],
+			vk.DeviceSize(MaxMorphDeltas)*vk.DeviceSize(unsafe.Sizeof(matrix.Vec4{})))
+		writes = append(writes, prepareSetWriteBuffer(set,
+			[]vk.DescriptorBufferInfo{info}, morphDeltasBinding, vk.DescriptorTypeStorageBuffer))
+	}
+	return writes
 }
 
 var mampsfDefault = uint32(vk.PipelineStageVertexShaderBit | vk.PipelineStageTessellationControlShaderBit | vk.PipelineStageTessellationEvaluationShaderBit | vk.PipelineStageGeometryShaderBit | vk.PipelineStageFragmentShaderBit | vk.PipelineStageComputeShaderBit)
@@ -612,7 +724,12 @@ func findQueueFamilies(device vk.PhysicalDevice, surface vk.Surface) vkQueueFami
 			indices.graphicsFamily = i
 		}
 		presentSupport := vk.Bool32(0)
-		vk.GetPhysicalDeviceSurfaceSupport(device, uint32(i), surface, &presentSupport)
+		if surface == vk.Surface(vk.NullHandle) {
+			// Headless renderers never present, the graphics queue is used
+			presentSupport = vk.Bool32(uint32(queueFamilies[i].QueueFlags) & uint32(vk.QueueGraphicsBit))
+		} else {
+			vk.GetPhysicalDeviceSurfaceSupport(device, uint32(i), surface, &presentSupport)
+		}
 		if presentSupport != 0 {
 			indices.presentFamily = i
 		}
@@ -700,6 +817,9 @@ func (vr *Vulkan) querySwapChainSupport(device vk.PhysicalDevice) vkSwapChainSup
 }
 
 func (vr *Vulkan) createSwapChain() bool {
+	if vr.headless {
+		return vr.createHeadlessImages()
+	}
 	scs := vr.querySwapChainSupport(vr.physicalDevice)
 	surfaceFormat := chooseSwapSurfaceFormat(scs.formats, scs.formatCount)
 	presentMode := chooseSwapPresentMode(scs.presentModes, scs.presentModeCount)
@@ -781,6 +901,10 @@ func (vr *Vulkan) swapChainCleanup() {
 		vk.DestroyFramebuffer(vr.device, vr.swapChainFramebuffers[i], nil)
 		vr.dbg.remove(uintptr(unsafe.Pointer(vr.swapChainFramebuffers[i])))
 	}
+	if vr.headless {
+		vr.headlessImagesCleanup()
+		return
+	}
 	for i := uint32(0); i < vr.swapChainImageViewCount; i++ {
 		vk.DestroyImageView(vr.device, vr.swapImages[i].View, nil)
 		vr.dbg.remove(uintptr(unsafe.Pointer(vr.swapImages[i].View)))
@@ -812,12 +936,17 @@ func (vr *Vulkan) createLogicalDevice() bool {
 		queueCreateInfos[i].PQueuePriorities = []float32{1.0}
 	}
 
+	// Geometry and tessellation shaders are only asked for when the device
+	// has them, software drivers like SwiftShader do not
+	var supported vk.PhysicalDeviceFeatures
+	vk.GetPhysicalDeviceFeatures(vr.physicalDevice, &supported)
+	supported.Deref()
 	deviceFeatures := vk.PhysicalDeviceFeatures{}
 	deviceFeatures.SamplerAnisotropy = vk.True
 	deviceFeatures.SampleRateShading = vk.True
 	deviceFeatures.ShaderClipDistance = vk.True
-	deviceFeatures.GeometryShader = vkGeometryShaderValid
-	deviceFeatures.TessellationShader = vk.True
+	deviceFeatures.GeometryShader = vkGeometryShaderValid & supported.GeometryShader
+	deviceFeatures.TessellationShader = supported.TessellationShader
 	deviceFeatures.IndependentBlend = vk.True
 	//deviceFeatures.TextureCompressionASTC_LDR = vk.True;
 
@@ -825,8 +954,8 @@ func (vr *Vulkan) createLogicalDevice() bool {
 	drawFeatures.SType = vk.StructureTypePhysicalDeviceShaderDrawParameterFeatures
 	drawFeatures.ShaderDrawParameters = vk.True
 
-	extensions := requiredDeviceExtensions()
-	validationLayers := validationLayers()
+	extensions := vr.requiredDeviceExtensions()
+	validationLayers := vr.validationLayers()
 	createInfo := &vk.DeviceCreateInfo{}
 	createInfo.SType = vk.StructureTypeDeviceCreateInfo
 	createInfo.PQueueCreateInfos = queueCreateInfos[:qFamCount]
@@ -836,7 +965,11 @@ func (vr *Vulkan) createLogicalDevice() bool {
 	createInfo.PpEnabledExtensionNames = extensions
 	createInfo.EnabledLayerCount = uint32(len(validationLayers))
 	createInfo.PpEnabledLayerNames = validationLayers
-	createInfo.PNext = unsafe.Pointer(&drawFeatures)
+	// None of the engine shaders use the draw parameters, headless renderers
+	// leave them off as software drivers like SwiftShader do not have them
+	if !vr.headless {
+		createInfo.PNext = unsafe.Pointer(&drawFeatures)
+	}
 
 	var device vk.Device
 	if vk.CreateDevice(vr.physicalDevice, createInfo, nil, &device) != vk.Success {
@@ -862,13 +995,13 @@ func (vr *Vulkan) isPhysicalDeviceSuitable(device vk.PhysicalDevice) bool {
 	vk.GetPhysicalDeviceFeatures(device, &supportedFeatures)
 	supportedFeatures.Deref()
 	indices := findQueueFamilies(device, vr.surface)
-	exts := requiredDeviceExtensions()
+	exts := vr.requiredDeviceExtensions()
 	hasExtensions := true
 	for i := 0; i < len(exts) && hasExtensions; i++ {
 		hasExtensions = isExtensionSupported(device, exts[i])
 	}
-	swapChainAdequate := false
-	if hasExtensions {
+	swapChainAdequate := vr.headless
+	if hasExtensions && !vr.headless {
 		swapChainSupport := vr.querySwapChainSupport(device)
 		swapChainAdequate = swapChainSupport.formatCount > 0 && swapChainSupport.presentModeCount > 0
 		//free_swap_chain_support_details(swapChainSupport)
@@ -1321,7 +1454,7 @@ func (vr *Vulkan) createDefaultFrameBuffer() bool {
 func (vr *Vulkan) createVulkanInstance(appInfo vk.ApplicationInfo) bool {
 	windowExtensions := vr.window.GetInstanceExtensions()
 	added := make([]string, 0, 3)
-	if useValidationLayers {
+	if vr.validation {
 		added = append(added, vk.ExtDebugReportExtensionName+"\x00")
 	}
 	//	const char* added[] = {
@@ -1346,7 +1479,7 @@ func (vr *Vulkan) createVulkanInstance(appInfo vk.ApplicationInfo) bool {
 		Flags:                   vkInstanceFlags,
 	}
 
-	validationLayers := validationLayers()
+	validationLayers := vr.validationLayers()
 	if len(validationLayers) > 0 {
 		if !checkValidationLayerSupport(validationLayers) {
 			log.Fatalf("%s", "Expected to have validation layers for debugging, but didn't find them")
@@ -1373,7 +1506,15 @@ func (vr *Vulkan) createVulkanInstance(appInfo vk.ApplicationInfo) bool {
 /******************************************************************************/
 
 func NewVKRenderer(window RenderingContainer, applicationName string) (*Vulkan, error) {
+	return newVKRenderer(window, applicationName, false)
+}
+
+func newVKRenderer(window RenderingContainer, applicationName string, headless bool) (*Vulkan, error) {
+	if err := loadVulkan(); err != nil {
+		return nil, err
+	}
 	vr := &Vulkan{
+		headless:       headless,
 		window:         window,
 		instance:       vk.Instance(vk.NullHandle),
 		physicalDevice: vk.PhysicalDevice(vk.NullHandle),
@@ -1381,6 +1522,10 @@ func NewVKRenderer(window RenderingContainer, applicationName string) (*Vulkan,
 		msaaSamples:    vk.SampleCountFlagBits(vk.SampleCount1Bit),
 		dbg:            debugVulkanNew(),
 	}
+	vr.validation = useValidationLayers
+	if headless && useValidationLayers {
+		vr.validation = checkValidationLayerSupport(vr.validationLayers())
+	}
 
 	appInfo := vk.ApplicationInfo{}
 	appInfo.SType = vk.StructureTypeApplicationInfo
@@ -1392,7 +1537,7 @@ func NewVKRenderer(window RenderingContainer, applicationName string) (*Vulkan,
 	if !vr.createVulkanInstance(appInfo) {
 		return nil, errors.New("failed to create Vulkan instance")
 	}
-	if !vr.createSurface(window) {
+	if !headless && !vr.createSurface(window) {
 		return nil, errors.New("failed to create window surface")
 	}
 	//vr.surface = vk.SurfaceFromPointer(uintptr(surface))
@@ -1427,6 +1572,8 @@ func NewVKRenderer(window RenderingContainer, applicationName string) (*Vulkan,
 		return nil, errors.New("failed to create default frame buffer")
 	}
 	vr.createGlobalUniformBuffers()
+	vr.createJointPaletteBuffers()
+	vr.createMorphDeltaBuffers()
 	if !vr.createDescriptorPool(1000) {
 		return nil, errors.New("failed to create descriptor pool")
 	}
@@ -1436,15 +1583,20 @@ func NewVKRenderer(window RenderingContainer, applicationName string) (*Vulkan,
 	if !vr.createSyncObjects() {
 		return nil, errors.New("failed to create sync objects")
 	}
+	vr.defaultTarget.resize(vr)
 	if !vr.defaultTarget.oit.createImages(vr) {
 		return nil, errors.New("failed to create OIT images")
 	}
 	if !vr.oitPass.createOitResources(vr, &vr.defaultTarget.oit) {
 		return nil, errors.New("failed to create OIT render pass")
 	}
+	vr.defaultTarget.pass = &vr.oitPass
 	if !vr.defaultTarget.oit.createBuffers(vr, &vr.oitPass) {
 		return nil, errors.New("failed to create OIT buffers")
 	}
+	if !vr.shadowMaps.create(vr) {
+		return nil, errors.New("failed to create the shadow maps")
+	}
 	return vr, nil
 }
 
@@ -1458,7 +1610,7 @@ func (vr *Vulkan) Initialize(caches RenderCaches, width, height int32) error {
 	}
 	caches.TextureCache().CreatePending()
 	vr.oitPass.createCompositeResources(vr, float32(width), float32(height), caches.ShaderCache(), caches.MeshCache())
-	vr.defaultTarget.oit.createSetsAndSamplers(vr)
+	vr.defaultTarget.oit.createSetsAndSamplers(vr, &vr.oitPass)
 	return nil
 }
 
@@ -1473,9 +1625,18 @@ func (vr *Vulkan) remakeSwapChain() {
 	vr.createDefaultFrameBuffer()
 	vr.defaultTarget.oit.reset(vr)
 	vr.oitPass.reset(vr)
+	vr.defaultTarget.resize(vr)
 	vr.defaultTarget.oit.createImages(vr)
 	vr.oitPass.createOitResources(vr, &vr.defaultTarget.oit)
 	vr.defaultTarget.oit.createBuffers(vr, &vr.oitPass)
+	vr.defaultTarget.oit.createSamplers(vr)
+	// Targets with a fixed size keep their images, their frame buffers
+	// still work with the new render passes as the formats are the same
+	for _, t := range vr.renderTargets {
+		if t.options.Resize == RenderTargetResizeScreen {
+			t.remake(vr)
+		}
+	}
 }
 
 func (vr *Vulkan) createSyncObjects() bool {
@@ -1524,7 +1685,6 @@ func (vr *Vulkan) createSpvModule(mem []byte) (vk.ShaderModule, bool) {
 	info.PCode = *(*[]uint32)(unsafe.Pointer(&mem))
 	var outModule vk.ShaderModule
 	if vk.CreateShaderModule(vr.device, &info, nil, &outModule) != vk.Success {
-		log.Fatalf("Failed to create shader module for %s", "TODO")
 		return outModule, false
 	} else {
 		vr.dbg.add(uintptr(unsafe.Pointer(outModule)))
@@ -1668,7 +1828,7 @@ func (vr *Vulkan) createRenderPass() bool {
 func (vr *Vulkan) createPipeline(shader *Shader, shaderStages []vk.PipelineShaderStageCreateInfo,
 	shaderStageCount int, descriptorSetLayout vk.DescriptorSetLayout,
 	pipelineLayout *vk.PipelineLayout, graphicsPipeline *vk.Pipeline,
-	renderPass vk.RenderPass, isTransparentPipeline bool) bool {
+	renderPass vk.RenderPass, isTransparentPipeline bool, samples vk.SampleCountFlagBits) bool {
 	bDesc := vertexGetBindingDescription(shader)
 	bDescCount := uint32(len(bDesc))
 	if shader.IsComposite() {
@@ -1744,12 +1904,11 @@ func (vr *Vulkan) createPipeline(shader *Shader, shaderStages []vk.PipelineShade
 	multisampling := vk.PipelineMultisampleStateCreateInfo{}
 	multisampling.SType = vk.StructureTypePipelineMultisampleStateCreateInfo
 	multisampling.SampleShadingEnable = vk.True // Optional
-	// TODO:  This is a temp hack for testing
-	multisampling.RasterizationSamples = vk.SampleCount1Bit //shader.uniformType == SHADER_UNIFORM_TYPE_DEPTH ? 1 : vr.msaaSamples;
-	multisampling.MinSampleShading = 0.2                    // Optional
-	multisampling.PSampleMask = nil                         // Optional
-	multisampling.AlphaToCoverageEnable = vk.False          // Optional
-	multisampling.AlphaToOneEnable = vk.False               // Optional
+	multisampling.RasterizationSamples = samples
+	multisampling.MinSampleShading = 0.2           // Optional
+	multisampling.PSampleMask = nil                // Optional
+	multisampling.AlphaToCoverageEnable = vk.False // Optional
+	multisampling.AlphaToOneEnable = vk.False      // Optional
 
 	allChannels := vk.ColorComponentFlags(vk.ColorComponentRBit | vk.ColorComponentGBit | vk.ColorComponentBBit | vk.ColorComponentABit)
 	var colorBlendAttachment [2]vk.PipelineColorBlendAttachmentState
@@ -1776,8 +1935,10 @@ func (vr *Vulkan) createPipeline(shader *Shader, shaderStages []vk.PipelineShade
 		if shader.IsComposite() {
 			colorBlendAttachment[0].SrcColorBlendFactor = vk.BlendFactorOneMinusSrcAlpha
 			colorBlendAttachment[0].DstColorBlendFactor = vk.BlendFactorSrcAlpha
-			colorBlendAttachment[0].SrcAlphaBlendFactor = vk.BlendFactorOneMinusSrcAlpha
-			colorBlendAttachment[0].DstAlphaBlendFactor = vk.BlendFactorSrcAlpha
+			// The transparent drawings are composed over the solid ones, the
+			// target stays as opaque as the solids left it
+			colorBlendAttachment[0].SrcAlphaBlendFactor = vk.BlendFactorZero
+			colorBlendAttachment[0].DstAlphaBlendFactor = vk.BlendFactorOne
 		} else {
 			colorBlendAttachment[0].SrcColorBlendFactor = vk.BlendFactorSrcAlpha
 			colorBlendAttachment[0].DstColorBlendFactor = vk.BlendFactorOneMinusSrcAlpha
@@ -1786,6 +1947,14 @@ func (vr *Vulkan) createPipeline(shader *Shader, shaderStages []vk.PipelineShade
 		}
 		colorBlendAttachmentCount = 1
 	}
+	// Pipelines without a fragment stage only write depth, like the ones
+	// that draw the shadow maps
+	if !slices.ContainsFunc(shaderStages[:shaderStageCount], func(s vk.PipelineShaderStageCreateInfo) bool {
+		return s.Stage == vk.ShaderStageFragmentBit
+	}) {
+		colorBlendAttachmentCount = 0
+		multisampling.SampleShadingEnable = vk.False
+	}
 
 	colorBlending := vk.PipelineColorBlendStateCreateInfo{}
 	colorBlending.SType = vk.StructureTypePipelineColorBlendStateCreateInfo
@@ -1867,21 +2036,40 @@ func (vr *Vulkan) createPipeline(shader *Shader, shaderStages []vk.PipelineShade
 
 	success := true
 	pipelines := [1]vk.Pipeline{}
+	// The bindings free the C copies of the slices they convert from a
+	// finalizer, which can run while the driver is still reading them.
+	// Converting the create info here keeps its C memory with pipelineInfo
+	// until it is freed below
+	pipelineInfo.PassRef()
 	if vk.CreateGraphicsPipelines(vr.device, vk.PipelineCache(vk.NullHandle), 1, []vk.GraphicsPipelineCreateInfo{pipelineInfo}, nil, pipelines[:]) != vk.Success {
 		success = false
 		log.Fatal("Failed to create graphics pipeline")
 	} else {
 		vr.dbg.add(uintptr(unsafe.Pointer(pipelines[0])))
 	}
+	runtime.KeepAlive(&pipelineInfo)
+	runtime.KeepAlive(shaderStages)
+	runtime.KeepAlive(bDesc)
+	runtime.KeepAlive(aDesc)
+	runtime.KeepAlive(dynamicStates)
+	runtime.KeepAlive(&colorBlendAttachment)
+	pipelineInfo.Free()
 	*graphicsPipeline = pipelines[0]
 	return success
 }
 
-func (vr *Vulkan) ReadyFrame(camera cameras.Camera, uiCamera cameras.Camera, runtime float32) bool {
+func (vr *Vulkan) ReadyFrame(frame FrameData) bool {
 	fences := []vk.Fence{vr.renderFences[vr.currentFrame]}
 	vk.WaitForFences(vr.device, 1, fences, vk.True, math.MaxUint64)
-	vr.acquireImageResult = vk.AcquireNextImage(vr.device, vr.swapChain, math.MaxUint64,
-		vr.imageSemaphores[vr.currentFrame], vk.Fence(vk.NullHandle), &vr.imageIndex[vr.currentFrame])
+	vr.finishRenderTargetReads()
+	if vr.headless {
+		// Every frame in flight has its own image
+		vr.imageIndex[vr.currentFrame] = uint32(vr.currentFrame)
+		vr.acquireImageResult = vk.Success
+	} else {
+		vr.acquireImageResult = vk.AcquireNextImage(vr.device, vr.swapChain, math.MaxUint64,
+			vr.imageSemaphores[vr.currentFrame], vk.Fence(vk.NullHandle), &vr.imageIndex[vr.currentFrame])
+	}
 	if vr.acquireImageResult == vk.ErrorOutOfDate {
 		vr.remakeSwapChain()
 		return false
@@ -1892,7 +2080,9 @@ func (vr *Vulkan) ReadyFrame(camera cameras.Camera, uiCamera cameras.Camera, run
 	vk.ResetFences(vr.device, 1, fences)
 	vk.ResetCommandBuffer(vr.commandBuffers[vr.currentFrame*MaxCommandBuffers], 0)
 	vr.doPendingDeletes()
-	vr.updateGlobalUniformBuffer(camera, uiCamera, runtime)
+	vr.updateGlobalUniformBuffer(frame.Camera, frame.UICamera, frame.Lights, frame.Runtime)
+	vr.updateJointPalette(frame.Skins)
+	vr.updateMorphDeltas(frame.Morphs)
 	for _, r := range vr.preRuns {
 		r()
 	}
@@ -1905,24 +2095,33 @@ func (vr *Vulkan) SwapFrame(width, height int32) bool {
 	submitInfo := vk.SubmitInfo{}
 	submitInfo.SType = vk.StructureTypeSubmitInfo
 
-	waitSemaphores := []vk.Semaphore{vr.imageSemaphores[vr.currentFrame]}
-	waitStages := []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)}
-	submitInfo.WaitSemaphoreCount = 1
-	submitInfo.PWaitSemaphores = waitSemaphores
-	submitInfo.PWaitDstStageMask = waitStages
 	submitInfo.CommandBufferCount = uint32(vr.commandBuffersCount)
 	startIdx := vr.currentFrame * MaxCommandBuffers
 	submitInfo.PCommandBuffers = vr.commandBuffers[startIdx : startIdx+vr.commandBuffersCount]
 
+	// Headless frames are not waiting on an image from a swap chain and
+	// are not presented, so they skip the semaphores
 	signalSemaphores := []vk.Semaphore{vr.renderSemaphores[vr.currentFrame]}
-	submitInfo.SignalSemaphoreCount = 1
-	submitInfo.PSignalSemaphores = signalSemaphores
+	if !vr.headless {
+		waitSemaphores := []vk.Semaphore{vr.imageSemaphores[vr.currentFrame]}
+		waitStages := []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)}
+		submitInfo.WaitSemaphoreCount = 1
+		submitInfo.PWaitSemaphores = waitSemaphores
+		submitInfo.PWaitDstStageMask = waitStages
+		submitInfo.SignalSemaphoreCount = 1
+		submitInfo.PSignalSemaphores = signalSemaphores
+	}
 
 	eCode := vk.QueueSubmit(vr.graphicsQueue, 1, []vk.SubmitInfo{submitInfo}, vr.renderFences[vr.currentFrame])
 	if eCode != vk.Success {
 		log.Fatalf("Failed to submit draw command buffer, error code %d", eCode)
 		return false
 	}
+	vr.submitRenderTargetReads()
+	if vr.headless {
+		vr.currentFrame = (vr.currentFrame + 1) % maxFramesInFlight
+		return true
+	}
 
 	dependency := vk.SubpassDependency{}
 	dependency.SrcSubpass = vk.SubpassExternal
@@ -2080,7 +2279,7 @@ func (vr *Vulkan) prepShader(key *Shader, groups []DrawInstanceGroup) {
 			continue
 		}
 		group.UpdateData(vr)
-		if group.VisibleCount() == 0 {
+		if group.VisibleCount()+group.CulledCount() == 0 {
 			continue
 		}
 		vr.resizeUniformBuffer(key, group)
@@ -2104,6 +2303,8 @@ func (vr *Vulkan) prepShader(key *Shader, groups []DrawInstanceGroup) {
 				prepareSetWriteBuffer(set, []vk.DescriptorBufferInfo{globalInfo}, 0, vk.DescriptorTypeUniformBuffer),
 				prepareSetWriteImage(set, imageInfos, 1, false),
 			}
+			descriptorWrites = append(descriptorWrites, vr.storageBufferWrites(key, set)...)
+			descriptorWrites = append(descriptorWrites, vr.shadowMapWrites(key, group, set)...)
 			count := uint32(len(descriptorWrites))
 			vk.UpdateDescriptorSets(vr.device, count, descriptorWrites, 0, nil)
 		} else {
@@ -2111,6 +2312,8 @@ func (vr *Vulkan) prepShader(key *Shader, groups []DrawInstanceGroup) {
 				prepareSetWriteBuffer(set, []vk.DescriptorBufferInfo{globalInfo},
 					0, vk.DescriptorTypeUniformBuffer),
 			}
+			descriptorWrites = append(descriptorWrites, vr.storageBufferWrites(key, set)...)
+			descriptorWrites = append(descriptorWrites, vr.shadowMapWrites(key, group, set)...)
 			count := uint32(len(descriptorWrites))
 			vk.UpdateDescriptorSets(vr.device, count, descriptorWrites, 0, nil)
 		}
@@ -2119,20 +2322,34 @@ func (vr *Vulkan) prepShader(key *Shader, groups []DrawInstanceGroup) {
 
 func (vr *Vulkan) prepEntityBuffers(drawings []ShaderDraw) {
 	for i := range drawings {
+		if !drawings[i].shader.RenderId.isValid() {
+			continue
+		}
 		vr.prepShader(drawings[i].shader, drawings[i].instanceGroups)
 	}
 }
 
 func beginRender(renderPass vk.RenderPass, frameBuffer vk.Framebuffer,
 	extent vk.Extent2D, commandBuffer vk.CommandBuffer, clearColors []vk.ClearValue) {
+	if beginCommands(commandBuffer) {
+		beginRenderPass(renderPass, frameBuffer, extent, commandBuffer, clearColors)
+	}
+}
+
+func beginCommands(commandBuffer vk.CommandBuffer) bool {
 	beginInfo := vk.CommandBufferBeginInfo{}
 	beginInfo.SType = vk.StructureTypeCommandBufferBeginInfo
 	beginInfo.Flags = 0              // Optional
 	beginInfo.PInheritanceInfo = nil // Optional
 	if vk.BeginCommandBuffer(commandBuffer, &beginInfo) != vk.Success {
 		log.Fatal("Failed to begin recording command buffer")
-		return
+		return false
 	}
+	return true
+}
+
+func beginRenderPass(renderPass vk.RenderPass, frameBuffer vk.Framebuffer,
+	extent vk.Extent2D, commandBuffer vk.CommandBuffer, clearColors []vk.ClearValue) {
 	renderPassInfo := vk.RenderPassBeginInfo{}
 	renderPassInfo.SType = vk.StructureTypeRenderPassBeginInfo
 	renderPassInfo.RenderPass = renderPass
@@ -2161,12 +2378,15 @@ func endRender(commandBuffer vk.CommandBuffer) {
 	vk.EndCommandBuffer(commandBuffer)
 }
 
-func (vr *Vulkan) renderEach(commandBuffer vk.CommandBuffer, shader *Shader, groups []DrawInstanceGroup) {
-	if shader.IsComposite() {
+func (vr *Vulkan) renderEach(commandBuffer vk.CommandBuffer, pass *oitPass, shader *Shader, groups []DrawInstanceGroup) {
+	if shader.IsComposite() || !shader.RenderId.isValid() {
 		return
 	}
-	vk.CmdBindPipeline(commandBuffer, vk.PipelineBindPointGraphics,
-		shader.RenderId.graphicsPipeline)
+	pipeline := vr.shaderPipeline(shader, pass)
+	if pipeline == vk.Pipeline(vk.NullHandle) {
+		return
+	}
+	vk.CmdBindPipeline(commandBuffer, vk.PipelineBindPointGraphics, pipeline)
 	for i := range groups {
 		group := &groups[i]
 		if !group.IsReady() || group.VisibleCount() == 0 {
@@ -2192,7 +2412,7 @@ func (vr *Vulkan) renderEach(commandBuffer vk.CommandBuffer, shader *Shader, gro
 	}
 }
 
-func (vr *Vulkan) renderEachAlpha(commandBuffer vk.CommandBuffer, shader *Shader, groups []*DrawInstanceGroup) {
+func (vr *Vulkan) renderEachAlpha(commandBuffer vk.CommandBuffer, pass *oitPass, shader *Shader, groups []*DrawInstanceGroup) {
 	lastShader := (*Shader)(nil)
 	currentShader := (*Shader)(nil)
 	for i := range groups {
@@ -2201,12 +2421,15 @@ func (vr *Vulkan) renderEachAlpha(commandBuffer vk.CommandBuffer, shader *Shader
 			continue
 		}
 		if lastShader != shader {
-			if shader == nil {
+			if shader == nil || !shader.RenderId.isValid() {
+				continue
+			}
+			pipeline := vr.shaderPipeline(shader, pass)
+			if pipeline == vk.Pipeline(vk.NullHandle) {
 				continue
 			}
 			vk.CmdBindPipeline(commandBuffer,
-				vk.PipelineBindPointGraphics,
-				shader.RenderId.graphicsPipeline)
+				vk.PipelineBindPointGraphics, pipeline)
 			lastShader = shader
 			currentShader = shader
 		}
@@ -2235,6 +2458,31 @@ func (vr *Vulkan) DrawToTarget(drawings []ShaderDraw, target RenderTarget) {
 	vr.DrawMeshes(matrix.ColorDarkBG(), drawings, target)
 }
 
+// shaderPipeline is the pipeline of the shader for the pass, the pipelines
+// for the passes of targets that don't match the screen are created the
+// first time the shader is drawn into them
+func (vr *Vulkan) shaderPipeline(shader *Shader, pass *oitPass) vk.Pipeline {
+	id := &shader.RenderId
+	if pass == &vr.oitPass || shader.DriverData.OverrideRenderPass != nil {
+		return id.graphicsPipeline
+	}
+	for i := range id.variants {
+		if id.variants[i].pass == pass {
+			return id.variants[i].pipeline
+		}
+	}
+	v := shaderVariant{pass: pass}
+	renderPass, isTransparentPipeline := pass.renderPassFor(shader)
+	stages := id.stages()
+	if !vr.createPipeline(shader, stages, len(stages), id.descriptorSetLayout,
+		&v.pipelineLayout, &v.pipeline, renderPass, isTransparentPipeline, pass.samples) {
+		log.Printf("failed to create the render target pipeline for %s", shader.KeyName)
+	}
+	// Failed pipelines are kept as a null handle so they aren't tried again
+	id.variants = append(id.variants, v)
+	return v.pipeline
+}
+
 func (vr *Vulkan) doPendingDeletes() {
 	if len(vr.pendingDeletes) == 0 {
 		return
@@ -2257,6 +2505,27 @@ func (vr *Vulkan) doPendingDeletes() {
 	}
 }
 
+// sampledTargets are the targets whose color is read by the drawings, they
+// are only read by the opaque pass and go back to being attachments for
+// the transparent pass
+func sampledTargets(drawings []ShaderDraw) []*VKRenderTarget {
+	var targets []*VKRenderTarget
+	for i := range drawings {
+		for j := range drawings[i].instanceGroups {
+			for _, t := range drawings[i].instanceGroups[j].Textures {
+				if t == nil || t.renderTarget == nil {
+					continue
+				}
+				rt := t.renderTarget.(*VKRenderTarget)
+				if !slices.Contains(targets, rt) {
+					targets = append(targets, rt)
+				}
+			}
+		}
+	}
+	return targets
+}
+
 func (vr *Vulkan) DrawMeshes(clearColor matrix.Color, drawings []ShaderDraw, target RenderTarget) {
 	rt := target.(*VKRenderTarget)
 	frame := vr.currentFrame
@@ -2264,7 +2533,8 @@ func (vr *Vulkan) DrawMeshes(clearColor matrix.Color, drawings []ShaderDraw, tar
 	vr.prepEntityBuffers(drawings)
 
 	// TODO:  The material will render entities not yet added to the host...
-	oRenderPass := vr.oitPass.opaqueRenderPass
+	pass := rt.pass
+	oRenderPass := pass.opaqueRenderPass
 	oFrameBuffer := rt.oit.opaqueFrameBuffer
 	cmd1 := vr.commandBuffers[cmdBuffIdx+vr.commandBuffersCount]
 	vr.commandBuffersCount++
@@ -2272,26 +2542,36 @@ func (vr *Vulkan) DrawMeshes(clearColor matrix.Color, drawings []ShaderDraw, tar
 	cc := clearColor
 	opaqueClear[0].SetColor(cc[:])
 	opaqueClear[1].SetDepthStencil(1.0, 0.0)
-	beginRender(oRenderPass, oFrameBuffer, vr.swapChainExtent, cmd1, opaqueClear[:])
+	sampled := sampledTargets(drawings)
+	beginCommands(cmd1)
+	vr.drawShadowMaps(cmd1, drawings)
+	for _, t := range sampled {
+		t.beginSampling(vr, cmd1)
+	}
+	beginRenderPass(oRenderPass, oFrameBuffer, rt.oit.extent(), cmd1, opaqueClear[:])
 	for i := range drawings {
-		vr.renderEach(cmd1, drawings[i].shader, drawings[i].instanceGroups)
+		vr.renderEach(cmd1, pass, drawings[i].shader, drawings[i].instanceGroups)
 	}
 	endRender(cmd1)
 
-	tRenderPass := vr.oitPass.transparentRenderPass
+	tRenderPass := pass.transparentRenderPass
 	tFrameBuffer := rt.oit.transparentFrameBuffer
 	cmd2 := vr.commandBuffers[cmdBuffIdx+vr.commandBuffersCount]
 	vr.commandBuffersCount++
 	var transparentClear [2]vk.ClearValue
 	transparentClear[0].SetColor([]float32{0.0, 0.0, 0.0, 0.0})
 	transparentClear[1].SetColor([]float32{1.0, 0.0, 0.0, 0.0})
-	beginRender(tRenderPass, tFrameBuffer, vr.swapChainExtent, cmd2, transparentClear[:])
+	beginCommands(cmd2)
+	for _, t := range sampled {
+		t.endSampling(vr, cmd2)
+	}
+	beginRenderPass(tRenderPass, tFrameBuffer, rt.oit.extent(), cmd2, transparentClear[:])
 	for i := range drawings {
-		vr.renderEachAlpha(cmd2, drawings[i].shader.SubShader, drawings[i].TransparentGroups())
+		vr.renderEachAlpha(cmd2, pass, drawings[i].shader.SubShader, drawings[i].TransparentGroups())
 	}
 	offsets := vk.DeviceSize(0)
 	vk.CmdNextSubpass(cmd2, vk.SubpassContentsInline)
-	vk.CmdBindPipeline(cmd2, vk.PipelineBindPointGraphics, vr.oitPass.compositeShader.RenderId.graphicsPipeline)
+	vk.CmdBindPipeline(cmd2, vk.PipelineBindPointGraphics, vr.shaderPipeline(pass.compositeShader, pass))
 	imageInfos := [2]vk.DescriptorImageInfo{
 		imageInfo(rt.oit.weightedColor.View, rt.oit.weightedColor.Sampler),
 		imageInfo(rt.oit.weightedReveal.View, rt.oit.weightedReveal.Sampler),
@@ -2302,10 +2582,10 @@ func (vr *Vulkan) DrawMeshes(clearColor matrix.Color, drawings []ShaderDraw, tar
 		prepareSetWriteImage(set, imageInfos[1:2], 1, true),
 	}
 	vk.UpdateDescriptorSets(vr.device, uint32(len(descriptorWrites)), descriptorWrites, 0, nil)
-	csid := &vr.oitPass.compositeShader.RenderId
+	csid := &pass.compositeShader.RenderId
 	vk.CmdBindDescriptorSets(cmd2, vk.PipelineBindPointGraphics, csid.pipelineLayout,
 		0, 1, []vk.DescriptorSet{rt.oit.descriptorSets[vr.currentFrame]}, 0, []uint32{0})
-	mid := &vr.oitPass.compositeQuad.MeshId
+	mid := &pass.compositeQuad.MeshId
 	vk.CmdBindVertexBuffers(cmd2, 0, 1, []vk.Buffer{mid.vertexBuffer}, []vk.DeviceSize{offsets})
 	vk.CmdBindIndexBuffer(cmd2, mid.indexBuffer, 0, vk.IndexTypeUint32)
 	vk.CmdDrawIndexed(cmd2, mid.indexCount, 1, 0, 0, 0)
@@ -2330,8 +2610,8 @@ func (vr *Vulkan) BlitTargets(targets ...RenderTargetDraw) {
 		rt := targets[i].Target.(*VKRenderTarget)
 		area := targets[i].Rect
 		region := vk.ImageBlit{}
-		region.SrcOffsets[1].X = int32(vr.swapChainExtent.Width)
-		region.SrcOffsets[1].Y = int32(vr.swapChainExtent.Height)
+		region.SrcOffsets[1].X = int32(rt.oit.width)
+		region.SrcOffsets[1].Y = int32(rt.oit.height)
 		region.SrcOffsets[1].Z = 1
 		region.DstOffsets[0].X = int32(float32(vr.swapChainExtent.Width) * area[0])
 		region.DstOffsets[0].Y = int32(float32(vr.swapChainExtent.Height) * area[1])
@@ -2342,16 +2622,21 @@ func (vr *Vulkan) BlitTargets(targets ...RenderTargetDraw) {
 		region.DstSubresource.LayerCount = 1
 		region.SrcSubresource.AspectMask = vk.ImageAspectFlags(vk.ImageAspectColorBit)
 		region.SrcSubresource.LayerCount = 1
-		vr.transitionImageLayout(&rt.oit.color, vk.ImageLayoutTransferSrcOptimal,
+		color := rt.oit.output()
+		vr.transitionImageLayout(color, vk.ImageLayoutTransferSrcOptimal,
 			vk.ImageAspectFlags(vk.ImageAspectColorBit), vk.AccessFlags(vk.AccessTransferReadBit), cmd3)
-		vk.CmdBlitImage(cmd3, rt.oit.color.Image, rt.oit.color.Layout,
+		vk.CmdBlitImage(cmd3, color.Image, color.Layout,
 			vr.swapImages[idxSF].Image, vk.ImageLayoutTransferDstOptimal,
 			1, []vk.ImageBlit{region}, vk.FilterNearest)
-		vr.transitionImageLayout(&rt.oit.color, vk.ImageLayoutColorAttachmentOptimal,
+		vr.transitionImageLayout(color, vk.ImageLayoutColorAttachmentOptimal,
 			vk.ImageAspectFlags(vk.ImageAspectColorBit),
 			vk.AccessFlags(vk.AccessColorAttachmentReadBit|vk.AccessColorAttachmentWriteBit), cmd3)
 	}
-	vr.transitionImageLayout(&vr.swapImages[idxSF], vk.ImageLayoutPresentSrc,
+	finalLayout := vk.ImageLayoutPresentSrc
+	if vr.headless {
+		finalLayout = vk.ImageLayoutTransferSrcOptimal
+	}
+	vr.transitionImageLayout(&vr.swapImages[idxSF], finalLayout,
 		vk.ImageAspectFlags(vk.ImageAspectColorBit), vk.AccessFlags(vk.AccessTransferWriteBit), cmd3)
 	vk.EndCommandBuffer(cmd3)
 }
@@ -2537,143 +2822,106 @@ func (vr *Vulkan) TextureWritePixels(texture *Texture, x, y, width, height int,
 /* Friendly shader API                                                        */
 /******************************************************************************/
 
-func (vr *Vulkan) CreateShader(shader *Shader, assetDB *assets.Database) {
-	var vert, frag, geom, tesc, tese vk.ShaderModule
-	var vMem, fMem, gMem, cMem, eMem []byte
-	overrideRenderPass := shader.DriverData.OverrideRenderPass
-	vertStage := vk.PipelineShaderStageCreateInfo{}
-	vMem, err := assetDB.Read(shader.VertPath)
-	if err != nil || !(len(vMem) > 0 && (len(vMem)%4) == 0) {
-		panic("Failed to load vertex shader")
-	}
-	vert, ok := vr.createSpvModule(vMem)
-	if !ok {
-		panic("Failed to create vertex shader module")
-	}
-	vertStage.SType = vk.StructureTypePipelineShaderStageCreateInfo
-	vertStage.Stage = vk.ShaderStageVertexBit
-	vertStage.Module = vert
-	vertStage.PName = "main\x00"
-	shader.RenderId.vertModule = vert
+// spirvMagic is the first word of every SPIR-V module
+const spirvMagic = 0x07230203
 
-	fragStage := vk.PipelineShaderStageCreateInfo{}
-	fMem, err = assetDB.Read(shader.FragPath)
-	if err != nil || !(fMem != nil && len(fMem) > 0 && (len(fMem)%4) == 0) {
-		panic("Failed to load fragment shader")
+// loadShaderStage reads the SPIR-V at the given asset key and creates the
+// module for one stage of a shader
+func (vr *Vulkan) loadShaderStage(assetDB *assets.Database, key string, stage vk.ShaderStageFlagBits) (vk.PipelineShaderStageCreateInfo, error) {
+	info := vk.PipelineShaderStageCreateInfo{}
+	mem, err := assetDB.Read(key)
+	if err != nil {
+		return info, err
+	}
+	if len(mem) < 4 || len(mem)%4 != 0 ||
+		*(*uint32)(unsafe.Pointer(&mem[0])) != spirvMagic {
+		return info, fmt.Errorf("%s is not a SPIR-V module", key)
 	}
-	frag, ok = vr.createSpvModule(fMem)
+	module, ok := vr.createSpvModule(mem)
 	if !ok {
-		panic("Failed to create fragment shader module")
-	}
-	fragStage.SType = vk.StructureTypePipelineShaderStageCreateInfo
-	fragStage.Stage = vk.ShaderStageFragmentBit
-	fragStage.Module = frag
-	fragStage.PName = "main\x00"
-	shader.RenderId.fragModule = frag
-
-	geomStage := vk.PipelineShaderStageCreateInfo{}
-	if len(shader.GeomPath) > 0 {
-		gMem, err = assetDB.Read(shader.GeomPath)
-		if err != nil || !(gMem != nil && len(gMem) > 0 && (len(gMem)%4) == 0) {
-			panic("Failed to load geometry shader")
-		}
-		geom, ok = vr.createSpvModule(gMem)
-		if !ok {
-			panic("Failed to create geometry shader module")
-		}
-		geomStage.SType = vk.StructureTypePipelineShaderStageCreateInfo
-		geomStage.Stage = vk.ShaderStageGeometryBit
-		geomStage.Module = geom
-		geomStage.PName = "main\x00"
-		shader.RenderId.geomModule = geom
-	}
-
-	tescStage := vk.PipelineShaderStageCreateInfo{}
-	if len(shader.CtrlPath) > 0 {
-		cMem, err = assetDB.Read(shader.CtrlPath)
-		if err != nil || !(cMem != nil && len(cMem) > 0 && (len(cMem)%4) == 0) {
-			panic("Failed to load tessellation control shader")
-		}
-		tesc, ok = vr.createSpvModule(cMem)
-		if !ok {
-			panic("Failed to create tessellation control shader module")
-		}
-		tescStage.SType = vk.StructureTypePipelineShaderStageCreateInfo
-		tescStage.Stage = vk.ShaderStageTessellationControlBit
-		tescStage.Module = tesc
-		tescStage.PName = "main\x00"
-		shader.RenderId.tescModule = tesc
-	}
-
-	teseStage := vk.PipelineShaderStageCreateInfo{}
-	if len(shader.EvalPath) > 0 {
-		eMem, err = assetDB.Read(shader.EvalPath)
-		if err != nil || !(eMem != nil && len(eMem) > 0 && (len(eMem)%4) == 0) {
-			panic("Failed to load tessellation evaluation shader")
-		}
-		tese, ok = vr.createSpvModule(eMem)
-		if !ok {
-			panic("Failed to create tessellation evaluation shader module")
-		}
-		teseStage.SType = vk.StructureTypePipelineShaderStageCreateInfo
-		teseStage.Stage = vk.ShaderStageTessellationEvaluationBit
-		teseStage.Module = tese
-		teseStage.PName = "main\x00"
-		shader.RenderId.teseModule = tese
+		return info, fmt.Errorf("failed to create the shader module for %s", key)
 	}
+	info.SType = vk.StructureTypePipelineShaderStageCreateInfo
+	info.Stage = stage
+	info.Module = module
+	info.PName = "main\x00"
+	return info, nil
+}
 
+func (vr *Vulkan) CreateShader(shader *Shader, assetDB *assets.Database) error {
+	// Vulkan only promises 16 vertex inputs, drivers fail to make the
+	// pipeline or crash on shaders with more inputs than their limit
+	inputs := uint32(len(vertexGetAttributeDescription(shader)))
+	if limit := vr.physicalDeviceProperties.Limits.MaxVertexInputAttributes; inputs > limit {
+		log.Printf("%s needs %d vertex inputs but %s only supports %d, nothing drawn with it will show up",
+			shader.KeyName, inputs, vk.ToString(vr.physicalDeviceProperties.DeviceName[:]), limit)
+		return fmt.Errorf("%w: %s has %d, the device supports %d",
+			ErrVertexInputLimit, shader.KeyName, inputs, limit)
+	}
 	id := &shader.RenderId
-
+	var err error
 	id.descriptorSetLayout, err = vr.createDescriptorSetLayout(vr.device,
 		shader.DriverData.DescriptorSetLayoutStructure)
 	if err != nil {
-		// TODO:  Handle this error properly
-		log.Printf("Error: %s", err.Error())
+		return err
 	}
+	return vr.createShaderPipeline(shader, assetDB)
+}
 
-	stages := []vk.PipelineShaderStageCreateInfo{vertStage, tescStage, teseStage, geomStage, fragStage}
-	moduleCount := 0
-	if vertStage.SType != 0 {
-		stages[moduleCount] = vertStage
-		moduleCount++
-	}
-	if tescStage.SType != 0 {
-		stages[moduleCount] = tescStage
-		moduleCount++
-	}
-	if teseStage.SType != 0 {
-		stages[moduleCount] = teseStage
-		moduleCount++
-	}
-	if geomStage.SType != 0 {
-		stages[moduleCount] = geomStage
-		moduleCount++
-	}
-	if fragStage.SType != 0 {
-		stages[moduleCount] = fragStage
-		moduleCount++
+// createShaderPipeline loads the stages of the shader and creates its
+// pipeline on the descriptor set layout the shader already has
+func (vr *Vulkan) createShaderPipeline(shader *Shader, assetDB *assets.Database) error {
+	overrideRenderPass := shader.DriverData.OverrideRenderPass
+	id := &shader.RenderId
+	// The stages are in the order the pipeline runs them, the vertex and
+	// fragment stages are required, the others are only used when set
+	stageFiles := []struct {
+		key      string
+		stage    vk.ShaderStageFlagBits
+		name     string
+		required bool
+		module   *vk.ShaderModule
+	}{
+		{shader.VertPath, vk.ShaderStageVertexBit, "vertex", true, &id.vertModule},
+		{shader.CtrlPath, vk.ShaderStageTessellationControlBit, "tessellation control", false, &id.tescModule},
+		{shader.EvalPath, vk.ShaderStageTessellationEvaluationBit, "tessellation evaluation", false, &id.teseModule},
+		{shader.GeomPath, vk.ShaderStageGeometryBit, "geometry", false, &id.geomModule},
+		{shader.FragPath, vk.ShaderStageFragmentBit, "fragment", true, &id.fragModule},
+	}
+	stages := make([]vk.PipelineShaderStageCreateInfo, 0, len(stageFiles))
+	for _, f := range stageFiles {
+		if len(f.key) == 0 && !f.required {
+			continue
+		}
+		stage, err := vr.loadShaderStage(assetDB, f.key, f.stage)
+		if err != nil {
+			vr.destroyShaderModules(id)
+			return fmt.Errorf("failed to load the %s shader for %s: %w",
+				f.name, shader.KeyName, err)
+		}
+		*f.module = stage.Module
+		stages = append(stages, stage)
 	}
-	renderPass := vr.oitPass.opaqueRenderPass
-	if strings.HasSuffix(shader.FragPath, oitSuffix) || shader.IsComposite() {
-		renderPass = vr.oitPass.transparentRenderPass
-	} else if overrideRenderPass != nil {
+	renderPass, isTransparentPipeline := vr.oitPass.renderPassFor(shader)
+	if renderPass == vr.oitPass.opaqueRenderPass && overrideRenderPass != nil {
 		renderPass = *overrideRenderPass
 	}
-
-	isTransparentPipeline := renderPass == vr.oitPass.transparentRenderPass &&
-		!shader.IsComposite()
-	vr.createPipeline(shader, stages, moduleCount,
+	if !vr.createPipeline(shader, stages, len(stages),
 		id.descriptorSetLayout, &id.pipelineLayout,
-		&id.graphicsPipeline, renderPass, isTransparentPipeline)
+		&id.graphicsPipeline, renderPass, isTransparentPipeline, vk.SampleCount1Bit) {
+		vr.destroyShaderModules(id)
+		return fmt.Errorf("failed to create the pipeline for %s", shader.KeyName)
+	}
 	// TODO:  Setup subshader in the shader definition?
 	var subShaderCheck string
 	subShaderCheck = strings.TrimSuffix(shader.FragPath, ".spv") + oitSuffix
-	if assetDB.Exists(subShaderCheck) {
+	if shader.SubShader == nil && assetDB.Exists(subShaderCheck) {
 		subShader := NewShader(shader.VertPath, subShaderCheck,
 			shader.GeomPath, shader.CtrlPath, shader.EvalPath, vr)
 		subShader.DriverData = shader.DriverData
 		shader.SubShader = subShader
 	}
+	return nil
 }
 
 /******************************************************************************/
@@ -2764,6 +3012,9 @@ func (vr *Vulkan) TextureReadPixel(texture *Texture, x, y int) matrix.Color {
 }
 
 func (vr *Vulkan) Resize(width, height int) {
+	if vr.headless {
+		vr.window = headlessContainer{int32(width), int32(height)}
+	}
 	vr.remakeSwapChain()
 }
 
@@ -2789,28 +3040,72 @@ func (vr *Vulkan) DestroyTexture(texture *Texture) {
 	texture.RenderId = TextureId{}
 }
 
-func (vr *Vulkan) DestroyShader(shader *Shader) {
+// destroyShaderModules destroys the modules of the stages that were created
+// for the shader
+func (vr *Vulkan) destroyShaderModules(id *ShaderId) {
+	for _, m := range []*vk.ShaderModule{&id.vertModule, &id.fragModule,
+		&id.geomModule, &id.tescModule, &id.teseModule} {
+		if *m != vk.ShaderModule(vk.NullHandle) {
+			vk.DestroyShaderModule(vr.device, *m, nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(*m)))
+			*m = vk.ShaderModule(vk.NullHandle)
+		}
+	}
+}
+
+// destroyShaderPipelines destroys the pipelines and modules of the shader,
+// the descriptor set layout the sets of its drawings are made from is kept
+func (vr *Vulkan) destroyShaderPipelines(id *ShaderId) {
+	vk.DestroyPipeline(vr.device, id.graphicsPipeline, nil)
+	vr.dbg.remove(uintptr(unsafe.Pointer(id.graphicsPipeline)))
+	vk.DestroyPipelineLayout(vr.device, id.pipelineLayout, nil)
+	vr.dbg.remove(uintptr(unsafe.Pointer(id.pipelineLayout)))
+	id.graphicsPipeline = vk.Pipeline(vk.NullHandle)
+	id.pipelineLayout = vk.PipelineLayout(vk.NullHandle)
+	if id.shadowPipelineLayout != vk.PipelineLayout(vk.NullHandle) {
+		vk.DestroyPipeline(vr.device, id.shadowPipeline, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(id.shadowPipeline)))
+		vk.DestroyPipelineLayout(vr.device, id.shadowPipelineLayout, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(id.shadowPipelineLayout)))
+		id.shadowPipeline = vk.Pipeline(vk.NullHandle)
+		id.shadowPipelineLayout = vk.PipelineLayout(vk.NullHandle)
+	}
+	for _, v := range id.variants {
+		vk.DestroyPipeline(vr.device, v.pipeline, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(v.pipeline)))
+		vk.DestroyPipelineLayout(vr.device, v.pipelineLayout, nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(v.pipelineLayout)))
+	}
+	id.variants = nil
+	vr.destroyShaderModules(id)
+}
+
+// ReloadShader creates the modules and pipelines of the shader again from
+// its stages. The descriptor set layout is kept, the descriptor sets of the
+// drawings using the shader were allocated from it
+func (vr *Vulkan) ReloadShader(shader *Shader, assetDB *assets.Database) error {
+	if shader.RenderId.descriptorSetLayout == vk.DescriptorSetLayout(vk.NullHandle) {
+		// The shader was never created, nothing has sets from it yet
+		return shader.DelayedCreate(vr, assetDB)
+	}
 	vk.DeviceWaitIdle(vr.device)
-	vk.DestroyPipeline(vr.device, shader.RenderId.graphicsPipeline, nil)
-	vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.graphicsPipeline)))
-	vk.DestroyPipelineLayout(vr.device, shader.RenderId.pipelineLayout, nil)
-	vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.pipelineLayout)))
-	vk.DestroyShaderModule(vr.device, shader.RenderId.vertModule, nil)
-	vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.vertModule)))
-	vk.DestroyShaderModule(vr.device, shader.RenderId.fragModule, nil)
-	vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.fragModule)))
-	if shader.RenderId.geomModule != vk.ShaderModule(vk.NullHandle) {
-		vk.DestroyShaderModule(vr.device, shader.RenderId.geomModule, nil)
-		vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.geomModule)))
-	}
-	if shader.RenderId.tescModule != vk.ShaderModule(vk.NullHandle) {
-		vk.DestroyShaderModule(vr.device, shader.RenderId.tescModule, nil)
-		vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.tescModule)))
-	}
-	if shader.RenderId.teseModule != vk.ShaderModule(vk.NullHandle) {
-		vk.DestroyShaderModule(vr.device, shader.RenderId.teseModule, nil)
-		vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.teseModule)))
+	vr.destroyShaderPipelines(&shader.RenderId)
+	sub := shader.SubShader
+	if err := vr.createShaderPipeline(shader, assetDB); err != nil {
+		return err
+	}
+	if sub != nil {
+		return vr.ReloadShader(sub, assetDB)
 	}
+	if shader.SubShader != nil {
+		return vr.CreateShader(shader.SubShader, assetDB)
+	}
+	return nil
+}
+
+func (vr *Vulkan) DestroyShader(shader *Shader) {
+	vk.DeviceWaitIdle(vr.device)
+	vr.destroyShaderPipelines(&shader.RenderId)
 	vk.DestroyDescriptorSetLayout(vr.device, shader.RenderId.descriptorSetLayout, nil)
 	vr.dbg.remove(uintptr(unsafe.Pointer(shader.RenderId.descriptorSetLayout)))
 	if shader.SubShader != nil {
@@ -2838,8 +3133,24 @@ func (vr *Vulkan) Destroy() {
 		vr.doPendingDeletes()
 	}
 	if vr.device != vk.Device(vk.NullHandle) {
+		for len(vr.runningReads) > 0 {
+			vr.finishRenderTargetReads()
+		}
+		for i := range vr.pendingReads {
+			vr.pendingReads[i].done(nil, errors.New("the renderer was destroyed before the render target was read"))
+		}
+		vr.pendingReads = vr.pendingReads[:0]
+		for _, t := range vr.renderTargets {
+			t.reset(vr)
+		}
+		vr.renderTargets = vr.renderTargets[:0]
 		vr.defaultTarget.reset(vr)
 		vr.oitPass.reset(vr)
+		vr.shadowMaps.reset(vr)
+		for _, p := range vr.oitPasses {
+			p.reset(vr)
+		}
+		vr.oitPasses = vr.oitPasses[:0]
 		vr.defaultTexture = nil
 		for i := 0; i < maxFramesInFlight; i++ {
 			vk.DestroySemaphore(vr.device, vr.imageSemaphores[i], nil)
@@ -2856,6 +3167,14 @@ func (vr *Vulkan) Destroy() {
 			vr.dbg.remove(uintptr(unsafe.Pointer(vr.globalUniformBuffers[i])))
 			vk.FreeMemory(vr.device, vr.globalUniformBuffersMemory[i], nil)
 			vr.dbg.remove(uintptr(unsafe.Pointer(vr.globalUniformBuffersMemory[i])))
+			vk.DestroyBuffer(vr.device, vr.jointPaletteBuffers[i], nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(vr.jointPaletteBuffers[i])))
+			vk.FreeMemory(vr.device, vr.jointPaletteBuffersMemory[i], nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(vr.jointPaletteBuffersMemory[i])))
+			vk.DestroyBuffer(vr.device, vr.morphDeltaBuffers[i], nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(vr.morphDeltaBuffers[i])))
+			vk.FreeMemory(vr.device, vr.morphDeltaBuffersMemory[i], nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(vr.morphDeltaBuffersMemory[i])))
 		}
 		for i := range vr.descriptorPools {
 			vk.DestroyDescriptorPool(vr.device, vr.descriptorPools[i], nil)
@@ -2868,8 +3187,10 @@ func (vr *Vulkan) Destroy() {
 		vr.dbg.remove(uintptr(unsafe.Pointer(vr.device)))
 	}
 	if vr.instance != vk.Instance(vk.NullHandle) {
-		vk.DestroySurface(vr.instance, vr.surface, nil)
-		vr.dbg.remove(uintptr(unsafe.Pointer(vr.surface)))
+		if vr.surface != vk.Surface(vk.NullHandle) {
+			vk.DestroySurface(vr.instance, vr.surface, nil)
+			vr.dbg.remove(uintptr(unsafe.Pointer(vr.surface)))
+		}
 		vk.DestroyInstance(vr.instance, nil)
 		vr.dbg.remove(uintptr(unsafe.Pointer(vr.instance)))
 	}
@@ -0,0 +1,97 @@
+//go:build !js && !OPENGL
+
+/*****************************************************************************/
+/* renderer_headless.vk.go                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"errors"
+	"unsafe"
+
+	vk "github.com/KaijuEngine/go-vulkan"
+)
+
+// headlessContainer stands in for the window of a headless renderer, it
+// only knows the size of the images the renderer draws into
+type headlessContainer struct {
+	width  int32
+	height int32
+}
+
+func (c headlessContainer) GetDrawableSize() (int32, int32)  { return c.width, c.height }
+func (c headlessContainer) GetInstanceExtensions() []string  { return []string{} }
+func (c headlessContainer) PlatformWindow() unsafe.Pointer   { return nil }
+func (c headlessContainer) PlatformInstance() unsafe.Pointer { return nil }
+
+// NewVKRendererHeadless creates a Vulkan renderer without a window or a
+// surface, frames are drawn into images of the size rather than a swap
+// chain and nothing is presented. Render targets are read back like they
+// are with a window, this is what tests and offline rendering use
+func NewVKRendererHeadless(width, height int, applicationName string) (*Vulkan, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("a headless renderer needs a width and height")
+	}
+	return newVKRenderer(headlessContainer{int32(width), int32(height)},
+		applicationName, true)
+}
+
+// createHeadlessImages makes an image for every frame in flight in place of
+// the images of a swap chain, they are left in the transfer source layout
+// at the end of a frame so they can be copied from
+func (vr *Vulkan) createHeadlessImages() bool {
+	w, h := vr.window.GetDrawableSize()
+	extent := vk.Extent2D{Width: uint32(w), Height: uint32(h)}
+	vr.swapImageCount = maxFramesInFlight
+	vr.swapImages = make([]TextureId, vr.swapImageCount)
+	for i := range vr.swapImages {
+		if !vr.CreateImage(extent.Width, extent.Height, 1, vk.SampleCount1Bit,
+			vk.FormatB8g8r8a8Unorm, vk.ImageTilingOptimal,
+			vk.ImageUsageFlags(vk.ImageUsageColorAttachmentBit|vk.ImageUsageTransferDstBit|vk.ImageUsageTransferSrcBit),
+			vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), &vr.swapImages[i], 1) {
+			return false
+		}
+	}
+	vr.swapChainExtent = extent
+	return true
+}
+
+func (vr *Vulkan) headlessImagesCleanup() {
+	for i := range vr.swapImages {
+		vr.textureIdFree(&vr.swapImages[i])
+	}
+}
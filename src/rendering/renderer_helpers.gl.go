@@ -39,7 +39,54 @@
 
 package rendering
 
-import "kaiju/gl"
+import (
+	"kaiju/gl"
+	"unsafe"
+)
+
+// glDataTextureWidth is how wide the float textures are that hold the data
+// which the other renderers put in storage buffers, GLES 3.0 does not have
+// them. A shader finds vec4 i at ivec2(i % width, i / width)
+const glDataTextureWidth = 1024
+
+// Texture units the data textures and shadow maps are bound to, the instance
+// data and the material textures start at unit 0
+const (
+	glShadowMapUnit    = 13
+	glJointPaletteUnit = 14
+	glMorphDeltasUnit  = 15
+)
+
+// uploadDataTexture puts the vec4s into the data texture, making the texture
+// when it is used the first time. The count of vec4s must be a multiple of
+// glDataTextureWidth
+func uploadDataTexture(texture *gl.Handle, data unsafe.Pointer, count int) {
+	if !texture.IsValid() {
+		gl.GenTextures(1, texture)
+		gl.BindTexture(gl.Texture2D, *texture)
+		gl.TexParameteri(gl.Texture2D, gl.TextureWrapS, gl.ClampToEdge)
+		gl.TexParameteri(gl.Texture2D, gl.TextureWrapT, gl.ClampToEdge)
+		gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Nearest)
+		gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Nearest)
+	} else {
+		gl.BindTexture(gl.Texture2D, *texture)
+	}
+	gl.TexImage2D(gl.Texture2D, 0, gl.RGBA32F, glDataTextureWidth,
+		int32(count/glDataTextureWidth), 0, gl.RGBA, gl.Float, data)
+	gl.UnBindTexture(gl.Texture2D)
+}
+
+// bindDataTexture binds the data texture to the sampler of the program when
+// the program reads it
+func bindDataTexture(program gl.Handle, sampler string, unit int, texture gl.Handle) {
+	loc := gl.GetUniformLocation(program, sampler)
+	if loc.Equal(-1) || !texture.IsValid() {
+		return
+	}
+	gl.ActivateTexture(gl.Handle(int(gl.Texture0) + unit))
+	gl.BindTexture(gl.Texture2D, texture)
+	gl.Uniform1i(loc, int32(unit))
+}
 
 func padBin(wb []byte) []byte {
 	pad := len(wb) % 16
@@ -40,145 +40,200 @@
 package rendering
 
 import (
+	"errors"
 	"kaiju/gl"
 	"kaiju/matrix"
-	"log"
 )
 
-func (r *GLRenderer) setupOITFrameBuffer(width, height int32) {
-	gl.DeleteFrameBuffers(1, &r.opaqueFBO)
-	gl.DeleteFrameBuffers(1, &r.transparentAccumFBO)
-	gl.DeleteFrameBuffers(1, &r.transparentRevealFBO)
-	gl.DeleteTextures(1, &r.opaqueTexture)
-	gl.DeleteTextures(1, &r.accumTexture)
-	gl.DeleteTextures(1, &r.revealAccumTexture)
-	gl.DeleteTextures(1, &r.revealRevealTexture)
-	gl.DeleteTextures(1, &r.revealTexture)
-	gl.DeleteTextures(1, &r.depthTexture)
-
-	gl.GenTextures(1, &r.opaqueTexture)
-	gl.BindTexture(gl.Texture2D, r.opaqueTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.RGBA16F, width, height, 0, gl.RGBA, gl.HalfFloat, nil)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Linear)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Linear)
-	gl.UnBindTexture(gl.Texture2D)
+// glOitBuffers are the frame buffers a target is drawn with. The solid
+// drawings go into the opaque buffer, the transparent ones are drawn twice,
+// once to accumulate their colors and once for how much they reveal, as
+// GLES 3.0 can't blend its color attachments differently. They are then
+// composed onto the opaque buffer. Multisampled buffers draw the solids
+// into renderbuffers that are resolved into the opaque buffer before the
+// transparent drawings
+type glOitBuffers struct {
+	opaqueFBO            gl.Handle
+	multisampleFBO       gl.Handle
+	multisampleColor     gl.Handle
+	multisampleDepth     gl.Handle
+	transparentAccumFBO  gl.Handle
+	transparentRevealFBO gl.Handle
+	opaqueTexture        gl.Texture
+	depthTexture         gl.Texture
+	accumTexture         gl.Texture
+	revealTexture        gl.Texture
+	revealAccumTexture   gl.Texture
+	revealRevealTexture  gl.Texture
+	width                int32
+	height               int32
+}
 
-	gl.GenTextures(1, &r.depthTexture)
-	gl.BindTexture(gl.Texture2D, r.depthTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.DepthComponent32F,
-		width, height, 0, gl.DepthComponent, gl.Float, nil)
+func createOITTexture(texture *gl.Texture, internalFormat, format, typ gl.Handle, filter gl.Handle, width, height int32) {
+	gl.GenTextures(1, texture)
+	gl.BindTexture(gl.Texture2D, *texture)
+	gl.TexImage2D(gl.Texture2D, 0, internalFormat, width, height, 0, format, typ, nil)
+	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, filter)
+	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, filter)
+	gl.TexParameteri(gl.Texture2D, gl.TextureWrapS, gl.ClampToEdge)
+	gl.TexParameteri(gl.Texture2D, gl.TextureWrapT, gl.ClampToEdge)
 	gl.UnBindTexture(gl.Texture2D)
+}
 
-	gl.GenFrameBuffers(1, &r.opaqueFBO)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.opaqueFBO)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, r.opaqueTexture, 0)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, r.depthTexture, 0)
-
+// create makes the frame buffers at the size, the opaque color is stored in
+// the internal format and pixel type that are given. A sample count above
+// one also makes the multisampled buffer the solids are drawn into
+func (b *glOitBuffers) create(width, height, samples int32, colorFormat, colorType gl.Handle) error {
+	b.width, b.height = width, height
+	createOITTexture(&b.opaqueTexture, colorFormat, gl.RGBA, colorType, gl.Linear, width, height)
+	createOITTexture(&b.depthTexture, gl.DepthComponent32F, gl.DepthComponent, gl.Float, gl.Nearest, width, height)
+
+	gl.GenFrameBuffers(1, &b.opaqueFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, b.opaqueFBO)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, b.opaqueTexture, 0)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, b.depthTexture, 0)
 	if !gl.CheckFrameBufferStatus(gl.FrameBuffer).Equal(gl.FrameBufferComplete) {
-		log.Fatalf("%s\n", "FrameBuffer opaque FBO not complete")
+		gl.UnBindFrameBuffer(gl.FrameBuffer)
+		return errors.New("the opaque frame buffer is not complete")
 	}
-
 	gl.UnBindFrameBuffer(gl.FrameBuffer)
 
-	gl.GenTextures(1, &r.accumTexture)
-	gl.BindTexture(gl.Texture2D, r.accumTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.RGBA16F, width, height, 0, gl.RGBA, gl.HalfFloat, nil)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Linear)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Linear)
-	gl.UnBindTexture(gl.Texture2D)
-
-	gl.GenTextures(1, &r.revealRevealTexture)
-	gl.BindTexture(gl.Texture2D, r.revealRevealTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.R32F, width, height, 0, gl.Red, gl.Float, nil)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Linear)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Linear)
-	gl.UnBindTexture(gl.Texture2D)
-
-	gl.GenFrameBuffers(1, &r.transparentAccumFBO)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.transparentAccumFBO)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, r.accumTexture, 0)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment1, gl.Texture2D, r.revealRevealTexture, 0)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, r.depthTexture, 0)
+	createOITTexture(&b.accumTexture, gl.RGBA16F, gl.RGBA, gl.HalfFloat, gl.Linear, width, height)
+	createOITTexture(&b.revealRevealTexture, gl.R32F, gl.Red, gl.Float, gl.Linear, width, height)
 
+	gl.GenFrameBuffers(1, &b.transparentAccumFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, b.transparentAccumFBO)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, b.accumTexture, 0)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment1, gl.Texture2D, b.revealRevealTexture, 0)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, b.depthTexture, 0)
 	accumDrawBuffers := []gl.Handle{gl.ColorAttachment0, gl.ColorAttachment1}
 	gl.DrawBuffers(accumDrawBuffers)
-
 	if !gl.CheckFrameBufferStatus(gl.FrameBuffer).Equal(gl.FrameBufferComplete) {
-		log.Fatalf("%s\n", "FrameBuffer transparent FBO not complete")
+		gl.UnBindFrameBuffer(gl.FrameBuffer)
+		return errors.New("the transparent frame buffer is not complete")
 	}
-
 	gl.UnBindFrameBuffer(gl.FrameBuffer)
 
-	gl.GenTextures(1, &r.revealAccumTexture)
-	gl.BindTexture(gl.Texture2D, r.revealAccumTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.RGBA16F, width, height, 0, gl.RGBA, gl.HalfFloat, nil)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Linear)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Linear)
-	gl.UnBindTexture(gl.Texture2D)
-
-	gl.GenTextures(1, &r.revealTexture)
-	gl.BindTexture(gl.Texture2D, r.revealTexture)
-	gl.TexImage2D(gl.Texture2D, 0, gl.R32F, width, height, 0, gl.Red, gl.Float, nil)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMinFilter, gl.Linear)
-	gl.TexParameteri(gl.Texture2D, gl.TextureMagFilter, gl.Linear)
-	gl.UnBindTexture(gl.Texture2D)
-
-	gl.GenFrameBuffers(1, &r.transparentRevealFBO)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.transparentRevealFBO)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, r.revealAccumTexture, 0)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment1, gl.Texture2D, r.revealTexture, 0)
-	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, r.depthTexture, 0)
+	createOITTexture(&b.revealAccumTexture, gl.RGBA16F, gl.RGBA, gl.HalfFloat, gl.Linear, width, height)
+	createOITTexture(&b.revealTexture, gl.R32F, gl.Red, gl.Float, gl.Linear, width, height)
 
+	gl.GenFrameBuffers(1, &b.transparentRevealFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, b.transparentRevealFBO)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment0, gl.Texture2D, b.revealAccumTexture, 0)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.ColorAttachment1, gl.Texture2D, b.revealTexture, 0)
+	gl.FrameBufferTexture2D(gl.FrameBuffer, gl.DepthAttachment, gl.Texture2D, b.depthTexture, 0)
 	revealDrawBuffers := []gl.Handle{gl.ColorAttachment0, gl.ColorAttachment1}
 	gl.DrawBuffers(revealDrawBuffers)
 	if !gl.CheckFrameBufferStatus(gl.FrameBuffer).Equal(gl.FrameBufferComplete) {
-		log.Fatalf("%s\n", "FrameBuffer transparent reveal FBO not complete")
+		gl.UnBindFrameBuffer(gl.FrameBuffer)
+		return errors.New("the transparent reveal frame buffer is not complete")
+	}
+	gl.UnBindFrameBuffer(gl.FrameBuffer)
+	if samples > 1 {
+		return b.createMultisample(samples, colorFormat)
+	}
+	return nil
+}
+
+func (b *glOitBuffers) createMultisample(samples int32, colorFormat gl.Handle) error {
+	gl.GenRenderBuffers(1, &b.multisampleColor)
+	gl.BindRenderBuffer(gl.RenderBuffer, b.multisampleColor)
+	gl.RenderBufferStorageMultisample(gl.RenderBuffer, samples, colorFormat, b.width, b.height)
+	gl.GenRenderBuffers(1, &b.multisampleDepth)
+	gl.BindRenderBuffer(gl.RenderBuffer, b.multisampleDepth)
+	gl.RenderBufferStorageMultisample(gl.RenderBuffer, samples, gl.DepthComponent32F, b.width, b.height)
+	gl.BindRenderBuffer(gl.RenderBuffer, 0)
+
+	gl.GenFrameBuffers(1, &b.multisampleFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, b.multisampleFBO)
+	gl.FrameBufferRenderBuffer(gl.FrameBuffer, gl.ColorAttachment0, gl.RenderBuffer, b.multisampleColor)
+	gl.FrameBufferRenderBuffer(gl.FrameBuffer, gl.DepthAttachment, gl.RenderBuffer, b.multisampleDepth)
+	if !gl.CheckFrameBufferStatus(gl.FrameBuffer).Equal(gl.FrameBufferComplete) {
+		gl.UnBindFrameBuffer(gl.FrameBuffer)
+		return errors.New("the multisampled frame buffer is not complete")
 	}
 	gl.UnBindFrameBuffer(gl.FrameBuffer)
+	return nil
 }
 
-func (r *GLRenderer) solidPass(drawings []ShaderDraw, clearColor matrix.Color) {
+func (b *glOitBuffers) multisampled() bool { return b.multisampleFBO.IsValid() }
+
+// resolve copies the multisampled solids into the opaque buffer, the depth
+// is resolved as well so the transparent drawings are still depth tested
+func (b *glOitBuffers) resolve() {
+	gl.BindFrameBuffer(gl.ReadFrameBuffer, b.multisampleFBO)
+	gl.BindFrameBuffer(gl.DrawFrameBuffer, b.opaqueFBO)
+	gl.BlitFrameBuffer(0, 0, b.width, b.height, 0, 0, b.width, b.height,
+		gl.ColorBufferBit|gl.DepthBufferBit, gl.Nearest)
+	gl.UnBindFrameBuffer(gl.ReadFrameBuffer)
+	gl.UnBindFrameBuffer(gl.DrawFrameBuffer)
+}
+
+func (b *glOitBuffers) reset() {
+	gl.DeleteFrameBuffers(1, &b.opaqueFBO)
+	gl.DeleteFrameBuffers(1, &b.transparentAccumFBO)
+	gl.DeleteFrameBuffers(1, &b.transparentRevealFBO)
+	gl.DeleteFrameBuffers(1, &b.multisampleFBO)
+	gl.DeleteRenderBuffers(1, &b.multisampleColor)
+	gl.DeleteRenderBuffers(1, &b.multisampleDepth)
+	gl.DeleteTextures(1, &b.opaqueTexture)
+	gl.DeleteTextures(1, &b.accumTexture)
+	gl.DeleteTextures(1, &b.revealAccumTexture)
+	gl.DeleteTextures(1, &b.revealRevealTexture)
+	gl.DeleteTextures(1, &b.revealTexture)
+	gl.DeleteTextures(1, &b.depthTexture)
+	*b = glOitBuffers{}
+}
+
+func (r *GLRenderer) solidPass(drawings []ShaderDraw, clearColor matrix.Color, buffers *glOitBuffers) {
 	gl.Enable(gl.DepthTest)
 	gl.DepthFunc(gl.Less)
 	gl.DepthMask(true)
 	gl.Disable(gl.Blend)
 	gl.ClearColor(clearColor.R(), clearColor.G(), clearColor.B(), clearColor.A())
-	gl.BindFrameBuffer(gl.FrameBuffer, r.opaqueFBO)
+	if buffers.multisampled() {
+		gl.BindFrameBuffer(gl.FrameBuffer, buffers.multisampleFBO)
+	} else {
+		gl.BindFrameBuffer(gl.FrameBuffer, buffers.opaqueFBO)
+	}
 	gl.Clear(gl.ColorBufferBit | gl.DepthBufferBit)
 	r.draw(drawings)
+	if buffers.multisampled() {
+		buffers.resolve()
+	}
 }
 
-func (r *GLRenderer) transparentPass(drawings []ShaderDraw) {
+func (r *GLRenderer) transparentPass(drawings []ShaderDraw, buffers *glOitBuffers) {
 	gl.DepthMask(false)
 	gl.Enable(gl.Blend)
 	// TODO:  Figure this out, blend func doesn't take in an arg num to first arg
 	gl.BlendFunc(gl.One, gl.One)
 	gl.BlendEquation(gl.FuncAdd)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.transparentAccumFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, buffers.transparentAccumFBO)
 	gl.ClearBufferfv(gl.Color, 0, matrix.Vec4Zero())
 	gl.ClearBufferfv(gl.Color, 1, matrix.Vec4One())
 	r.draw(drawings)
 
 	gl.BlendFunc(gl.Zero, gl.OneMinusSrcColor)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.transparentRevealFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, buffers.transparentRevealFBO)
 	gl.ClearBufferfv(gl.Color, 0, matrix.Vec4Zero())
 	gl.ClearBufferfv(gl.Color, 1, matrix.Vec4One())
 	r.draw(drawings)
 }
 
-func (r *GLRenderer) composePass() {
+func (r *GLRenderer) composePass(buffers *glOitBuffers) {
 	id := r.compositeShader.RenderId.(gl.Handle)
 	meshId := r.composeQuad.MeshId.(MeshIdGL)
 	gl.DepthFunc(gl.Always)
 	gl.Enable(gl.Blend)
 	gl.BlendFunc(gl.SrcAlpha, gl.OneMinusSrcAlpha)
-	gl.BindFrameBuffer(gl.FrameBuffer, r.opaqueFBO)
+	gl.BindFrameBuffer(gl.FrameBuffer, buffers.opaqueFBO)
 	gl.UseProgram(id)
 	gl.ActivateTexture(gl.Texture0)
-	gl.BindTexture(gl.Texture2D, r.accumTexture)
+	gl.BindTexture(gl.Texture2D, buffers.accumTexture)
 	gl.Uniform1i(gl.GetUniformLocation(id, "accum"), 0)
 	gl.ActivateTexture(gl.Texture1)
-	gl.BindTexture(gl.Texture2D, r.revealTexture)
+	gl.BindTexture(gl.Texture2D, buffers.revealTexture)
 	gl.Uniform1i(gl.GetUniformLocation(id, "reveal"), 1)
 	gl.BindVertexArray(meshId.VAO)
 	gl.BindBuffer(gl.ElementArrayBuffer, meshId.EBO)
@@ -186,4 +241,5 @@ func (r *GLRenderer) composePass() {
 	gl.UnBindBuffer(gl.ElementArrayBuffer)
 	gl.UnBindTexture(gl.Texture2D)
 	gl.UnBindVertexArray()
+	gl.UnBindFrameBuffer(gl.FrameBuffer)
 }
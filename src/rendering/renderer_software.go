@@ -0,0 +1,620 @@
+/*****************************************************************************/
+/* renderer_software.go                                                      */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"fmt"
+	"image"
+	"kaiju/assets"
+	"kaiju/matrix"
+	"log"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// SoftwareVaryings are the values a software vertex shader hands to the
+// fragment shader, every one of them is interpolated across the primitive.
+// ClipDistance works like gl_ClipDistance, a fragment is discarded when any
+// of the interpolated distances is below zero.
+type SoftwareVaryings struct {
+	Color        matrix.Color
+	UV0          matrix.Vec2
+	Normal       matrix.Vec3
+	Position     matrix.Vec3
+	Custom       [6]matrix.Vec4
+	ClipDistance [4]matrix.Float
+}
+
+const softwareVaryingCount = int(unsafe.Sizeof(SoftwareVaryings{}) / unsafe.Sizeof(matrix.Float(0)))
+
+func (v *SoftwareVaryings) floats() *[softwareVaryingCount]matrix.Float {
+	return (*[softwareVaryingCount]matrix.Float)(unsafe.Pointer(v))
+}
+
+// SoftwareInstance reads the fields of a single instance by the names given
+// to them in the shader definition, missing fields read as zero
+type SoftwareInstance struct {
+	data   []byte
+	layout softwareLayout
+}
+
+type softwareLayout map[string]int
+
+func newSoftwareLayout(def ShaderDef) softwareLayout {
+	layout := softwareLayout{}
+	offset := 0
+	for _, f := range def.Fields {
+		layout[f.Name] = offset
+		t := defTypes[f.Type]
+		offset += int(t.size) * t.repeat
+	}
+	return layout
+}
+
+func (s SoftwareInstance) field(name string, size uintptr) unsafe.Pointer {
+	offset, ok := s.layout[name]
+	if !ok || offset+int(size) > len(s.data) {
+		return nil
+	}
+	return unsafe.Pointer(&s.data[offset])
+}
+
+func (s SoftwareInstance) Float(name string) matrix.Float {
+	if p := s.field(name, unsafe.Sizeof(matrix.Float(0))); p != nil {
+		return *(*matrix.Float)(p)
+	}
+	return 0
+}
+
+func (s SoftwareInstance) Vec2(name string) matrix.Vec2 {
+	if p := s.field(name, unsafe.Sizeof(matrix.Vec2{})); p != nil {
+		return *(*matrix.Vec2)(p)
+	}
+	return matrix.Vec2{}
+}
+
+func (s SoftwareInstance) Vec4(name string) matrix.Vec4 {
+	if p := s.field(name, unsafe.Sizeof(matrix.Vec4{})); p != nil {
+		return *(*matrix.Vec4)(p)
+	}
+	return matrix.Vec4{}
+}
+
+func (s SoftwareInstance) Mat4(name string) matrix.Mat4 {
+	if p := s.field(name, unsafe.Sizeof(matrix.Mat4{})); p != nil {
+		return *(*matrix.Mat4)(p)
+	}
+	return matrix.Mat4{}
+}
+
+type SoftwareVertexInput struct {
+	Globals  *GlobalShaderData
+	Instance SoftwareInstance
+	Vertex   *Vertex
+	// VertexIndex is the index of the vertex in the mesh like gl_VertexIndex
+	VertexIndex int
+	joints      []matrix.Mat4
+	morphDeltas []matrix.Vec4
+}
+
+// Joint reads the joint palette of the frame, joints outside of the
+// palette do not move the vertex
+func (in *SoftwareVertexInput) Joint(index int) matrix.Mat4 {
+	if index < 0 || index >= len(in.joints) {
+		return matrix.Mat4Identity()
+	}
+	return in.joints[index]
+}
+
+// MorphDelta reads the morph delta buffer of the frame, deltas outside of
+// the buffer do not change the vertex
+func (in *SoftwareVertexInput) MorphDelta(index int) matrix.Vec4 {
+	if index < 0 || index >= len(in.morphDeltas) {
+		return matrix.Vec4{}
+	}
+	return in.morphDeltas[index]
+}
+
+type SoftwareFragmentInput struct {
+	Globals *GlobalShaderData
+	// FragCoord is the pixel center, the depth and 1/w like gl_FragCoord
+	FragCoord matrix.Vec4
+	// UV0Dx and UV0Dy are how much UV0 changes to the next pixel to the right
+	// and below, they take the place of dFdx and dFdy
+	UV0Dx    matrix.Vec2
+	UV0Dy    matrix.Vec2
+	Varyings SoftwareVaryings
+	// Blending is set while drawing groups that use blending, shaders
+	// should not discard translucent fragments then
+	Blending bool
+	// ReceiveShadows is cleared for groups that do not receive shadows
+	ReceiveShadows bool
+	textures       []*softwareTexture
+	shadowMaps     []*SoftwareRenderTarget
+}
+
+// Sample reads the group texture at the index, groups without a texture
+// at the index sample as white
+func (in *SoftwareFragmentInput) Sample(index int, uv matrix.Vec2) matrix.Color {
+	if index < 0 || index >= len(in.textures) || in.textures[index] == nil {
+		return matrix.ColorWhite()
+	}
+	return in.textures[index].sample(uv)
+}
+
+// ShadowDepth reads the depth of the shadow map at the index, outside of
+// the map, or when there is no map, nothing is in the way of the light
+func (in *SoftwareFragmentInput) ShadowDepth(index, x, y int) matrix.Float {
+	if index < 0 || index >= len(in.shadowMaps) || in.shadowMaps[index] == nil {
+		return 1
+	}
+	m := in.shadowMaps[index]
+	if x < 0 || y < 0 || x >= m.width || y >= m.height {
+		return 1
+	}
+	return m.Depth(x, y)
+}
+
+func (in *SoftwareFragmentInput) TextureSize(index int) matrix.Vec2 {
+	if index < 0 || index >= len(in.textures) || in.textures[index] == nil {
+		return matrix.Vec2{1, 1}
+	}
+	t := in.textures[index]
+	if t.target != nil {
+		return matrix.Vec2{matrix.Float(t.target.Width()), matrix.Float(t.target.Height())}
+	}
+	return matrix.Vec2{matrix.Float(t.width), matrix.Float(t.height)}
+}
+
+// SoftwareVertexShader fills out the varyings for the vertex and returns
+// its clip space position
+type SoftwareVertexShader func(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4
+
+// SoftwareFragmentShader returns the color of the fragment, or false to
+// discard it
+type SoftwareFragmentShader func(in *SoftwareFragmentInput) (matrix.Color, bool)
+
+// SoftwareProgram is the Go stand in for the compiled shaders of a shader
+// definition when drawing with the SoftwareRenderer
+type SoftwareProgram struct {
+	Vertex   SoftwareVertexShader
+	Fragment SoftwareFragmentShader
+}
+
+type softwareShader struct {
+	program  SoftwareProgram
+	layout   softwareLayout
+	drawMode MeshDrawMode
+	cullMode MeshCullMode
+}
+
+type softwareMesh struct {
+	verts   []Vertex
+	indices []uint32
+}
+
+// SoftwareRenderer draws on the CPU into memory, it needs no GPU or window
+// which makes it useful for tests and for taking pictures of scenes on
+// machines without a graphics driver. Shaders are replaced by a
+// SoftwareProgram registered for their shader definition, the programs for
+// the definitions that ship with the engine are registered by default.
+// Output follows the Vulkan conventions, the first row of the image is the
+// top of the screen and depth runs from 0 to 1.
+type SoftwareRenderer struct {
+	// ClearColor fills a target before anything is drawn to it
+	ClearColor    matrix.Color
+	caches        RenderCaches
+	programs      map[string]SoftwareProgram
+	shaders       map[*Shader]*softwareShader
+	meshes        map[*Mesh]*softwareMesh
+	textures      map[*Texture]*softwareTexture
+	globals       GlobalShaderData
+	defaultTarget SoftwareRenderTarget
+	frame         *image.RGBA
+	preRuns       []func()
+	raster        softwareRaster
+	shadowPasses  []ShadowPass
+	shadowMaps    [MaxShadowMaps]*SoftwareRenderTarget
+	jointPalette  [MaxJointPalette]matrix.Mat4
+	morphDeltas   []matrix.Vec4
+	morphVersion  uint64
+	// targetTextures sample the colors of the targets that were drawn with
+	targetTextures map[*SoftwareRenderTarget]*Texture
+	// targets follow the size of the screen when it is resized
+	targets []*SoftwareRenderTarget
+}
+
+func NewSoftwareRenderer(width, height int) *SoftwareRenderer {
+	r := &SoftwareRenderer{
+		ClearColor:     matrix.ColorDarkBG(),
+		programs:       make(map[string]SoftwareProgram),
+		shaders:        make(map[*Shader]*softwareShader),
+		meshes:         make(map[*Mesh]*softwareMesh),
+		textures:       make(map[*Texture]*softwareTexture),
+		targetTextures: make(map[*SoftwareRenderTarget]*Texture),
+	}
+	for key, program := range softwarePrograms() {
+		r.programs[key] = program
+	}
+	r.Resize(width, height)
+	return r
+}
+
+// SetProgram sets the program used for shaders created from the shader
+// definition with the given asset key, it must be set before the shader is
+// created
+func (r *SoftwareRenderer) SetProgram(definitionKey string, program SoftwareProgram) {
+	r.programs[definitionKey] = program
+}
+
+// Image is the frame that was last blitted to, see BlitTargets
+func (r *SoftwareRenderer) Image() *image.RGBA { return r.frame }
+
+func (r *SoftwareRenderer) CreateRenderTarget(options RenderTargetOptions) (RenderTarget, error) {
+	t := &SoftwareRenderTarget{options: options}
+	t.resize(options.size(r.defaultTarget.Width(), r.defaultTarget.Height()))
+	if options.Resize == RenderTargetResizeScreen {
+		r.targets = append(r.targets, t)
+	}
+	return t, nil
+}
+
+// RenderTargetTexture reads the colors of the target as they are when the
+// texture is sampled, without rounding them to 8 bits
+func (r *SoftwareRenderer) RenderTargetTexture(renderTarget RenderTarget) (*Texture, error) {
+	target := renderTarget.(*SoftwareRenderTarget)
+	texture, ok := r.targetTextures[target]
+	if !ok {
+		texture = &Texture{Key: "render target", Filter: TextureFilterLinear, renderTarget: target}
+		r.targetTextures[target] = texture
+		r.textures[texture] = &softwareTexture{filter: TextureFilterLinear, target: target}
+	}
+	texture.Width, texture.Height = target.Width(), target.Height()
+	t := r.textures[texture]
+	t.width, t.height = target.Width(), target.Height()
+	return texture, nil
+}
+
+func (r *SoftwareRenderer) DestroyRenderTarget(renderTarget RenderTarget) {
+	target := renderTarget.(*SoftwareRenderTarget)
+	if texture, ok := r.targetTextures[target]; ok {
+		delete(r.textures, texture)
+		delete(r.targetTextures, target)
+	}
+	r.targets = slices.DeleteFunc(r.targets, func(t *SoftwareRenderTarget) bool { return t == target })
+}
+
+func (r *SoftwareRenderer) ReadRenderTarget(target RenderTarget, done func(*image.RGBA, error)) {
+	done(target.(*SoftwareRenderTarget).Image(), nil)
+}
+
+func (r *SoftwareRenderer) Initialize(caches RenderCaches, width, height int32) error {
+	r.caches = caches
+	r.Resize(int(width), int(height))
+	return nil
+}
+
+func (r *SoftwareRenderer) ReadyFrame(frame FrameData) bool {
+	r.globals = NewGlobalShaderData(frame.Camera, frame.UICamera, frame.Lights, frame.Runtime)
+	r.shadowPasses = r.shadowPasses[:0]
+	if frame.Lights != nil {
+		r.shadowPasses = frame.Lights.ShadowPasses()
+	}
+	r.raster.vertex.joints = r.jointPalette[:frame.Skins.fillPalette(r.jointPalette[:])]
+	if frame.Morphs != nil && r.morphDeltas == nil {
+		r.morphDeltas = make([]matrix.Vec4, MaxMorphDeltas)
+	}
+	used, version := frame.Morphs.fillDeltas(r.morphDeltas, r.morphVersion)
+	r.raster.vertex.morphDeltas, r.morphVersion = r.morphDeltas[:used], version
+	for _, p := range r.preRuns {
+		p()
+	}
+	r.preRuns = r.preRuns[:0]
+	return true
+}
+
+// findDefinition looks through the definitions loaded by the shader cache
+// for the one the shader was created from
+func (r *SoftwareRenderer) findDefinition(shader *Shader) (string, ShaderDef, bool) {
+	if r.caches == nil {
+		return "", ShaderDef{}, false
+	}
+	return r.caches.ShaderCache().definitionOf(shader)
+}
+
+func (r *SoftwareRenderer) CreateShader(shader *Shader, _ *assets.Database) error {
+	key, def, ok := r.findDefinition(shader)
+	if !ok {
+		return fmt.Errorf("no shader definition found for %s", shader.KeyName)
+	}
+	program, ok := r.programs[key]
+	if !ok || program.Vertex == nil || program.Fragment == nil {
+		return fmt.Errorf("no software program set for %s", key)
+	}
+	s := &softwareShader{
+		program:  program,
+		layout:   newSoftwareLayout(def),
+		drawMode: MeshDrawModeTriangles,
+		cullMode: MeshCullModeFront,
+	}
+	switch strings.ToLower(def.DrawMode) {
+	case "lines":
+		s.drawMode = MeshDrawModeLines
+	case "points":
+		s.drawMode = MeshDrawModePoints
+	}
+	switch strings.ToLower(def.CullMode) {
+	case "none":
+		s.cullMode = MeshCullModeNone
+	case "back":
+		s.cullMode = MeshCullModeBack
+	}
+	r.shaders[shader] = s
+	return nil
+}
+
+// ReloadShader creates the shader again, the program and layout are looked
+// up from the definition it was created from
+func (r *SoftwareRenderer) ReloadShader(shader *Shader, assetDatabase *assets.Database) error {
+	return r.CreateShader(shader, assetDatabase)
+}
+
+func (r *SoftwareRenderer) CreateMesh(mesh *Mesh, verts []Vertex, indices []uint32) {
+	r.meshes[mesh] = &softwareMesh{
+		verts:   append([]Vertex{}, verts...),
+		indices: append([]uint32{}, indices...),
+	}
+}
+
+func (r *SoftwareRenderer) CreateTexture(texture *Texture, textureData *TextureData) {
+	t := &softwareTexture{filter: texture.Filter}
+	if textureData != nil {
+		t.width, t.height = textureData.Width, textureData.Height
+	}
+	if t.width == 0 || t.height == 0 {
+		t.width, t.height = texture.Width, texture.Height
+	}
+	t.width, t.height = max(t.width, 1), max(t.height, 1)
+	t.pix = make([]byte, t.width*t.height*bytesInPixel)
+	if textureData == nil || !t.load(textureData) {
+		if textureData != nil {
+			log.Printf("texture %s is in a format the software renderer can not read", texture.Key)
+		}
+		for i := range t.pix {
+			t.pix[i] = 255
+		}
+	}
+	r.textures[texture] = t
+}
+
+func (r *SoftwareRenderer) TextureReadPixel(texture *Texture, x, y int) matrix.Color {
+	if t, ok := r.textures[texture]; ok {
+		return t.texel(x, y)
+	}
+	return matrix.Color{}
+}
+
+func (r *SoftwareRenderer) TextureWritePixels(texture *Texture, x, y, width, height int, pixels []byte) {
+	t, ok := r.textures[texture]
+	if !ok {
+		return
+	}
+	for row := 0; row < height; row++ {
+		ty := y + row
+		if ty < 0 || ty >= t.height {
+			continue
+		}
+		for col := 0; col < width; col++ {
+			tx := x + col
+			from := (row*width + col) * bytesInPixel
+			if tx < 0 || tx >= t.width || from+bytesInPixel > len(pixels) {
+				continue
+			}
+			to := (ty*t.width + tx) * bytesInPixel
+			copy(t.pix[to:to+bytesInPixel], pixels[from:from+bytesInPixel])
+		}
+	}
+}
+
+func (r *SoftwareRenderer) Draw(drawings []ShaderDraw) {
+	r.DrawToTarget(drawings, &r.defaultTarget)
+}
+
+// DrawToTarget draws the shadow maps of the frame, then the opaque groups,
+// then the groups that use blending are combined with weighted blended
+// order independent transparency the same way the Vulkan renderer does it
+func (r *SoftwareRenderer) DrawToTarget(drawings []ShaderDraw, target RenderTarget) {
+	for i := range drawings {
+		for j := range drawings[i].instanceGroups {
+			group := &drawings[i].instanceGroups[j]
+			if _, ok := r.meshes[group.Mesh]; ok && !group.IsEmpty() {
+				group.UpdateData(r)
+			}
+		}
+	}
+	r.drawShadowMaps(drawings)
+	rt := target.(*SoftwareRenderTarget)
+	rt.clear(r.ClearColor)
+	r.raster.target = rt
+	r.raster.fragment.Globals = &r.globals
+	for i := range drawings {
+		r.drawGroups(&drawings[i], drawings[i].SolidGroups(), false)
+	}
+	for i := range drawings {
+		r.drawGroups(&drawings[i], drawings[i].TransparentGroups(), true)
+	}
+	rt.composite()
+	rt.resolve()
+}
+
+func (r *SoftwareRenderer) drawGroups(draw *ShaderDraw, groups []*DrawInstanceGroup, blending bool) {
+	shader, ok := r.shaders[draw.shader]
+	if !ok {
+		return
+	}
+	r.raster.shader = shader
+	r.raster.fragment.Blending = blending
+	for _, group := range groups {
+		mesh, ok := r.meshes[group.Mesh]
+		if !ok || group.IsEmpty() || group.VisibleCount() == 0 {
+			continue
+		}
+		r.raster.fragment.ReceiveShadows = !group.noShadowReceiving
+		r.raster.fragment.textures = r.raster.fragment.textures[:0]
+		for _, t := range group.Textures {
+			r.raster.fragment.textures = append(r.raster.fragment.textures, r.textures[t])
+		}
+		stride := group.instanceSize + group.padding
+		for k := 0; k < group.VisibleCount(); k++ {
+			instance := SoftwareInstance{
+				data:   group.instanceData[k*stride : k*stride+group.instanceSize],
+				layout: shader.layout,
+			}
+			r.raster.drawMesh(mesh, instance)
+		}
+	}
+}
+
+// drawShadowMaps draws the depth of the opaque groups that cast shadows
+// into a shadow map for each of the shadow passes. Instances are not
+// culled by the camera, things out of view still cast shadows into it
+func (r *SoftwareRenderer) drawShadowMaps(drawings []ShaderDraw) {
+	r.raster.fragment.shadowMaps = r.raster.fragment.shadowMaps[:0]
+	if len(r.shadowPasses) == 0 {
+		return
+	}
+	globals := r.globals
+	r.raster.fragment.Globals = &globals
+	r.raster.depthOnly = true
+	defer func() { r.raster.depthOnly = false }()
+	for _, pass := range r.shadowPasses {
+		m := r.shadowMaps[pass.Index]
+		if m == nil || m.width != pass.Resolution || m.height != pass.Resolution {
+			m = newSoftwareRenderTarget(pass.Resolution, pass.Resolution)
+			r.shadowMaps[pass.Index] = m
+		}
+		m.clearDepth()
+		r.raster.target = m
+		globals.View = pass.View
+		globals.Projection = pass.Projection
+		for i := range drawings {
+			shader, ok := r.shaders[drawings[i].shader]
+			if !ok || !drawings[i].shader.CastShadows {
+				continue
+			}
+			r.raster.shader = shader
+			for _, group := range drawings[i].SolidGroups() {
+				mesh, ok := r.meshes[group.Mesh]
+				if !ok || group.noShadowCasting {
+					continue
+				}
+				for _, instance := range group.Instances {
+					if instance.IsDestroyed() || !instance.IsActive() {
+						continue
+					}
+					data := unsafe.Slice((*byte)(instance.DataPointer()), group.instanceSize)
+					r.raster.drawMesh(mesh, SoftwareInstance{data: data, layout: shader.layout})
+				}
+			}
+		}
+	}
+	r.raster.fragment.shadowMaps = r.shadowMaps[:]
+}
+
+// BlitTargets copies the targets into the frame returned by Image, the
+// rect of each target is the left, top, right and bottom of the area it
+// covers as a fraction of the frame size
+func (r *SoftwareRenderer) BlitTargets(targets ...RenderTargetDraw) {
+	w, h := r.frame.Rect.Dx(), r.frame.Rect.Dy()
+	for i := range targets {
+		src := targets[i].Target.(*SoftwareRenderTarget)
+		area := targets[i].Rect
+		x0, y0 := int(matrix.Float(w)*area[0]), int(matrix.Float(h)*area[1])
+		x1, y1 := int(matrix.Float(w)*area[2]), int(matrix.Float(h)*area[3])
+		if x1 <= x0 || y1 <= y0 {
+			continue
+		}
+		for y := max(y0, 0); y < min(y1, h); y++ {
+			sy := (y - y0) * src.Height() / (y1 - y0)
+			for x := max(x0, 0); x < min(x1, w); x++ {
+				sx := (x - x0) * src.Width() / (x1 - x0)
+				r.frame.SetRGBA(x, y, softwareRGBA(src.Pixel(sx, sy)))
+			}
+		}
+	}
+}
+
+func (r *SoftwareRenderer) SwapFrame(width, height int32) bool { return true }
+
+func (r *SoftwareRenderer) Resize(width, height int) {
+	width, height = max(width, 1), max(height, 1)
+	r.defaultTarget.resize(width, height)
+	for _, t := range r.targets {
+		t.resize(t.options.size(width, height))
+	}
+	r.frame = image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+func (r *SoftwareRenderer) AddPreRun(preRun func()) {
+	r.preRuns = append(r.preRuns, preRun)
+}
+
+func (r *SoftwareRenderer) DestroyGroup(group *DrawInstanceGroup) {}
+
+func (r *SoftwareRenderer) DestroyTexture(texture *Texture) {
+	delete(r.textures, texture)
+}
+
+func (r *SoftwareRenderer) DestroyShader(shader *Shader) {
+	delete(r.shaders, shader)
+}
+
+func (r *SoftwareRenderer) DestroyMesh(mesh *Mesh) {
+	delete(r.meshes, mesh)
+}
+
+func (r *SoftwareRenderer) Destroy() {
+	clear(r.shaders)
+	clear(r.meshes)
+	clear(r.textures)
+	clear(r.targetTextures)
+	r.preRuns = r.preRuns[:0]
+}
+
+func (r *SoftwareRenderer) DefaultTarget() RenderTarget { return &r.defaultTarget }
@@ -0,0 +1,218 @@
+/*****************************************************************************/
+/* renderer_software_pbr.go                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import "kaiju/matrix"
+
+// Slots of SoftwareVaryings.Custom used by the PBR program
+const (
+	softwarePBRTangent = iota
+	softwarePBREmissive
+	softwarePBRFactors
+	softwarePBRSurface
+)
+
+func softwarePBRVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	inst := in.Instance
+	out.Color = matrix.Color(matrix.Vec4(in.Vertex.Color).Multiply(inst.Vec4(PBRParamBaseColor)))
+	out.UV0 = in.Vertex.UV0
+	model := inst.Mat4("model")
+	out.Normal = softwareNormal(model, in.Vertex.Normal)
+	t := in.Vertex.Tangent
+	tangent := model.MultiplyVec4(matrix.Vec4{t.X(), t.Y(), t.Z(), 0})
+	tangent[matrix.Vw] = t.W()
+	out.Custom[softwarePBRTangent] = tangent
+	out.Custom[softwarePBREmissive] = inst.Vec4(PBRParamEmissive)
+	out.Custom[softwarePBRFactors] = inst.Vec4(PBRParamFactors)
+	out.Custom[softwarePBRSurface] = inst.Vec4(PBRParamSurface)
+	return softwareTransform(in.Globals.View, in.Globals.Projection, model, in.Vertex, out)
+}
+
+// softwareEnvironment looks up the environment strip in the direction, it
+// stays half a texel inside of the face like environment() in pbr.frag
+func softwareEnvironment(in *SoftwareFragmentInput, dir matrix.Vec3) matrix.Vec3 {
+	a := dir.Abs()
+	var face, sc, tc, ma matrix.Float
+	if a.X() >= a.Y() && a.X() >= a.Z() {
+		face, sc, tc, ma = 0, -dir.Z(), -dir.Y(), a.X()
+		if dir.X() <= 0 {
+			face, sc = 1, dir.Z()
+		}
+	} else if a.Y() >= a.Z() {
+		face, sc, tc, ma = 2, dir.X(), dir.Z(), a.Y()
+		if dir.Y() <= 0 {
+			face, tc = 3, -dir.Z()
+		}
+	} else {
+		face, sc, tc, ma = 4, dir.X(), -dir.Y(), a.Z()
+		if dir.Z() <= 0 {
+			face, sc = 5, -dir.X()
+		}
+	}
+	inset := 0.5 / in.TextureSize(PBRTextureEnvironment).Y()
+	u := matrix.Clamp((sc/ma+1)*0.5, inset, 1-inset)
+	v := matrix.Clamp((tc/ma+1)*0.5, inset, 1-inset)
+	return matrix.Vec4(in.Sample(PBRTextureEnvironment, matrix.Vec2{(face + u) / 6, v})).AsVec3()
+}
+
+// softwareIrradiance is the ambient cube of the environment around n
+func softwareIrradiance(in *SoftwareFragmentInput, n matrix.Vec3) matrix.Vec3 {
+	sign := func(v matrix.Float) matrix.Float {
+		if v >= 0 {
+			return 1
+		}
+		return -1
+	}
+	sq := n.Multiply(n)
+	return softwareEnvironment(in, matrix.Vec3{sign(n.X()), 0, 0}).Scale(sq.X()).
+		Add(softwareEnvironment(in, matrix.Vec3{0, sign(n.Y()), 0}).Scale(sq.Y())).
+		Add(softwareEnvironment(in, matrix.Vec3{0, 0, sign(n.Z())}).Scale(sq.Z()))
+}
+
+// softwareEnvBRDFApprox is the analytic fit of the split sum BRDF lookup
+func softwareEnvBRDFApprox(f0 matrix.Vec3, roughness, nDotV matrix.Float) matrix.Vec3 {
+	rx := -roughness + 1
+	ry := -0.0275*roughness + 0.0425
+	rz := -0.572*roughness + 1.04
+	rw := 0.022*roughness - 0.04
+	a004 := min(rx*rx, matrix.Pow(2, -9.28*nDotV))*rx + ry
+	scale := -1.04*a004 + rz
+	bias := 1.04*a004 + rw
+	return matrix.Vec3{f0.X()*scale + bias, f0.Y()*scale + bias, f0.Z()*scale + bias}
+}
+
+func softwareDistributionGGX(nDotH, roughness matrix.Float) matrix.Float {
+	a := roughness * roughness
+	a2 := a * a
+	d := nDotH*nDotH*(a2-1) + 1
+	return a2 / max(matrix.Float(3.14159265359)*d*d, 0.0001)
+}
+
+func softwareGeometrySmith(nDotV, nDotL, roughness matrix.Float) matrix.Float {
+	k := (roughness + 1) * (roughness + 1) / 8
+	return (nDotV / (nDotV*(1-k) + k)) * (nDotL / (nDotL*(1-k) + k))
+}
+
+func softwareFresnelSchlick(cosTheta matrix.Float, f0 matrix.Vec3) matrix.Vec3 {
+	f := matrix.Pow(matrix.Clamp(1-cosTheta, 0, 1), 5)
+	return f0.Add(matrix.Vec3One().Subtract(f0).Scale(f))
+}
+
+func softwarePBRNormal(in *SoftwareFragmentInput) matrix.Vec3 {
+	n := in.Varyings.Normal.Normal()
+	tangent := in.Varyings.Custom[softwarePBRTangent]
+	t := tangent.AsVec3()
+	if matrix.Vec3Dot(t, t) < 0.0001 {
+		return n
+	}
+	t = t.Subtract(n.Scale(matrix.Vec3Dot(n, t))).Normal()
+	b := matrix.Vec3Cross(n, t).Scale(tangent.W())
+	m := matrix.Vec4(in.Sample(PBRTextureNormal, in.Varyings.UV0)).AsVec3().Scale(2).Subtract(matrix.Vec3One())
+	scale := in.Varyings.Custom[softwarePBRFactors].Z()
+	return t.Scale(m.X() * scale).Add(b.Scale(m.Y() * scale)).Add(n.Scale(m.Z())).Normal()
+}
+
+// softwarePBRDirect is the Cook-Torrance lighting of the lights in the
+// tile of the fragment, shadows are applied when the group receives them
+func softwarePBRDirect(in *SoftwareFragmentInput, n, v, albedo, f0 matrix.Vec3, metallic, roughness matrix.Float) matrix.Vec3 {
+	g := in.Globals
+	mask := softwareTileLights(in)
+	position := in.Varyings.Position
+	nDotV := max(matrix.Vec3Dot(n, v), 0.0001)
+	result := matrix.Vec3Zero()
+	for i := 0; mask != 0; i++ {
+		if mask&1 != 0 {
+			l := &g.Lights[i]
+			toLight, attenuation := softwareLightVector(l, position)
+			nDotL := max(matrix.Vec3Dot(n, toLight), 0)
+			if nDotL > 0 && attenuation > 0 {
+				if in.ReceiveShadows && l.Shadow.X() >= 0 {
+					attenuation *= softwareShadow(in, l, position, in.Varyings.Normal.Normal())
+				}
+				h := v.Add(toLight).Normal()
+				f := softwareFresnelSchlick(max(matrix.Vec3Dot(h, v), 0), f0)
+				d := softwareDistributionGGX(max(matrix.Vec3Dot(n, h), 0), roughness)
+				gs := softwareGeometrySmith(nDotV, nDotL, roughness)
+				specular := f.Scale(d * gs / (4*nDotV*nDotL + 0.0001))
+				kd := matrix.Vec3One().Subtract(f).Scale(1 - metallic)
+				radiance := l.Color.AsVec3().Scale(l.Color.W() * attenuation)
+				brdf := kd.Multiply(albedo).Scale(1 / matrix.Float(3.14159265359)).Add(specular)
+				result.AddAssign(brdf.Multiply(radiance).Scale(nDotL))
+			}
+		}
+		mask >>= 1
+	}
+	return result
+}
+
+func softwarePBRFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	vary := &in.Varyings
+	factors := vary.Custom[softwarePBRFactors]
+	surface := vary.Custom[softwarePBRSurface]
+	base := matrix.Vec4(in.Sample(PBRTextureBaseColor, vary.UV0)).Multiply(matrix.Vec4(vary.Color))
+	switch int(surface.Y()) {
+	case PBRAlphaMask:
+		if base.W() < surface.Z() {
+			return matrix.Color{}, false
+		}
+		base[matrix.Vw] = 1
+	case PBRAlphaBlend:
+	default:
+		base[matrix.Vw] = 1
+	}
+	mr := in.Sample(PBRTextureMetallicRoughness, vary.UV0)
+	metallic := matrix.Clamp(mr.B()*factors.X(), 0, 1)
+	roughness := matrix.Clamp(mr.G()*factors.Y(), 0.04, 1)
+	ao := 1 + (in.Sample(PBRTextureOcclusion, vary.UV0).R()-1)*factors.W()
+	n := softwarePBRNormal(in)
+	v := in.Globals.CameraPosition.Subtract(vary.Position).Normal()
+	albedo := base.AsVec3()
+	f0 := matrix.Vec3Lerp(matrix.Vec3{0.04, 0.04, 0.04}, albedo, metallic)
+	nDotV := max(matrix.Vec3Dot(n, v), 0.0001)
+	color := softwarePBRDirect(in, n, v, albedo, f0, metallic, roughness)
+	diffuseIBL := softwareIrradiance(in, n).Multiply(albedo).Scale(1 - metallic)
+	r := v.Negative().Subtract(n.Scale(2 * matrix.Vec3Dot(v.Negative(), n)))
+	prefiltered := matrix.Vec3Lerp(softwareEnvironment(in, r), softwareIrradiance(in, r), roughness)
+	specularIBL := prefiltered.Multiply(softwareEnvBRDFApprox(f0, roughness, nDotV))
+	color.AddAssign(diffuseIBL.Add(specularIBL).Scale(surface.X() * ao))
+	color.AddAssign(in.Globals.AmbientLight.AsVec3().Multiply(albedo).Scale(ao))
+	emissive := vary.Custom[softwarePBREmissive]
+	color.AddAssign(matrix.Vec4(in.Sample(PBRTextureEmissive, vary.UV0)).AsVec3().
+		Multiply(emissive.AsVec3()).Scale(emissive.W()))
+	return softwareOpaque(in, matrix.Color{color.X(), color.Y(), color.Z(), base.W()})
+}
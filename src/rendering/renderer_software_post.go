@@ -0,0 +1,175 @@
+/*****************************************************************************/
+/* renderer_software_post.go                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/matrix"
+)
+
+var softwareLumaWeights = matrix.Vec3{0.299, 0.587, 0.114}
+
+// softwarePostVertex follows post.vert, the pass parameters are handed to
+// the fragment in the first slots of SoftwareVaryings.Custom
+func softwarePostVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	p := in.Vertex.Position
+	for i := range PostParamCount {
+		out.Custom[i] = in.Instance.Vec4(postParamNames[i])
+	}
+	out.UV0 = matrix.Vec2{p.X()*0.5 + 0.5, p.Y()*0.5 + 0.5}
+	return matrix.Vec4{p.X(), p.Y(), 0, 1}
+}
+
+func softwarePostSource(in *SoftwareFragmentInput, uv matrix.Vec2) matrix.Vec3 {
+	return matrix.Vec4(in.Sample(PostTextureSource, uv)).AsVec3()
+}
+
+func softwarePostColor(c matrix.Vec3) matrix.Color {
+	return matrix.Color{c.X(), c.Y(), c.Z(), 1}
+}
+
+func softwareTonemapFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	c := softwarePostSource(in, in.Varyings.UV0)
+	for i := range c {
+		c[i] = matrix.Pow(1-matrix.Exp(-c[i]*params.X()), 1/params.Y())
+	}
+	return softwarePostColor(c), true
+}
+
+func softwareBloomBrightFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	c := softwarePostSource(in, in.Varyings.UV0)
+	brightness := matrix.Max(c.X(), matrix.Max(c.Y(), c.Z()))
+	knee := matrix.Max(params.X()*params.Y(), 0.0001)
+	soft := matrix.Clamp(brightness-params.X()+knee, 0, 2*knee)
+	soft = soft * soft / (4 * knee)
+	contribution := matrix.Max(soft, brightness-params.X()) / matrix.Max(brightness, 0.0001)
+	return softwarePostColor(c.Scale(contribution)), true
+}
+
+var softwareBlurWeights = [5]matrix.Float{0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216}
+
+func softwareBlurFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	uv := in.Varyings.UV0
+	size := in.TextureSize(PostTextureSource)
+	offset := matrix.Vec2{params.X() / size.X() * params.Z(), params.Y() / size.Y() * params.Z()}
+	sum := softwarePostSource(in, uv).Scale(softwareBlurWeights[0])
+	for i := 1; i < len(softwareBlurWeights); i++ {
+		step := offset.Scale(matrix.Float(i))
+		sum.AddAssign(softwarePostSource(in, uv.Add(step)).Scale(softwareBlurWeights[i]))
+		sum.AddAssign(softwarePostSource(in, uv.Subtract(step)).Scale(softwareBlurWeights[i]))
+	}
+	return softwarePostColor(sum), true
+}
+
+func softwareBloomCombineFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	bloom := softwarePostSource(in, in.Varyings.UV0)
+	c := matrix.Vec4(in.Sample(PostTextureInput, in.Varyings.UV0)).AsVec3()
+	return softwarePostColor(c.Add(bloom.Scale(params.X()))), true
+}
+
+func softwareFXAAFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	uv := in.Varyings.UV0
+	size := in.TextureSize(PostTextureSource)
+	texel := matrix.Vec2{1 / size.X(), 1 / size.Y()}
+	luma := func(x, y matrix.Float) matrix.Float {
+		return matrix.Vec3Dot(softwarePostSource(in, uv.Add(matrix.Vec2{x * texel.X(), y * texel.Y()})), softwareLumaWeights)
+	}
+	lumaNW, lumaNE := luma(-1, -1), luma(1, -1)
+	lumaSW, lumaSE := luma(-1, 1), luma(1, 1)
+	lumaM := luma(0, 0)
+	lumaMin := matrix.Min(lumaM, matrix.Min(matrix.Min(lumaNW, lumaNE), matrix.Min(lumaSW, lumaSE)))
+	lumaMax := matrix.Max(lumaM, matrix.Max(matrix.Max(lumaNW, lumaNE), matrix.Max(lumaSW, lumaSE)))
+	dir := matrix.Vec2{-((lumaNW + lumaNE) - (lumaSW + lumaSE)), (lumaNW + lumaSW) - (lumaNE + lumaSE)}
+	reduce := matrix.Max((lumaNW+lumaNE+lumaSW+lumaSE)*0.25*params.Y(), params.Z())
+	scale := 1 / (matrix.Min(matrix.Abs(dir.X()), matrix.Abs(dir.Y())) + reduce)
+	dir = matrix.Vec2{
+		matrix.Clamp(dir.X()*scale, -params.X(), params.X()) * texel.X(),
+		matrix.Clamp(dir.Y()*scale, -params.X(), params.X()) * texel.Y(),
+	}
+	at := func(t matrix.Float) matrix.Vec3 { return softwarePostSource(in, uv.Add(dir.Scale(t))) }
+	rgbA := at(1.0/3.0 - 0.5).Add(at(2.0/3.0 - 0.5)).Scale(0.5)
+	rgbB := rgbA.Scale(0.5).Add(at(-0.5).Add(at(0.5)).Scale(0.25))
+	lumaB := matrix.Vec3Dot(rgbB, softwareLumaWeights)
+	if lumaB < lumaMin || lumaB > lumaMax {
+		return softwarePostColor(rgbA), true
+	}
+	return softwarePostColor(rgbB), true
+}
+
+func softwareColorGradingFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	color := softwarePostSource(in, in.Varyings.UV0)
+	c := matrix.Vec3{matrix.Clamp(color.X(), 0, 1), matrix.Clamp(color.Y(), 0, 1), matrix.Clamp(color.Z(), 0, 1)}
+	size := params.Y()
+	lookUp := func(slice matrix.Float) matrix.Vec3 {
+		cell := matrix.Vec2{
+			(c.X()*(size-1) + 0.5) / (size * size),
+			(c.Y()*(size-1) + 0.5) / size,
+		}
+		return matrix.Vec4(in.Sample(PostTextureExtra, cell.Add(matrix.Vec2{slice / size, 0}))).AsVec3()
+	}
+	blue := c.Z() * (size - 1)
+	slice := matrix.Floor(blue)
+	graded := matrix.Vec3Lerp(lookUp(slice), lookUp(matrix.Min(slice+1, size-1)), blue-slice)
+	return softwarePostColor(matrix.Vec3Lerp(color, graded, params.X())), true
+}
+
+func softwareVignetteFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	uv := in.Varyings.UV0
+	color := softwarePostSource(in, uv)
+	dist := uv.Subtract(matrix.Vec2{0.5, 0.5}).Length() * matrix.Sqrt(2)
+	amount := softwareSmoothstep(params.Y()-params.Z(), params.Y(), dist) * params.X()
+	return softwarePostColor(matrix.Vec3Lerp(color, in.Varyings.Custom[1].AsVec3(), amount)), true
+}
+
+func softwareChromaticAberrationFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	params := in.Varyings.Custom[0]
+	uv := in.Varyings.UV0
+	offset := uv.Subtract(matrix.Vec2{0.5, 0.5}).Scale(params.X())
+	return matrix.Color{
+		softwarePostSource(in, uv.Add(offset)).X(),
+		softwarePostSource(in, uv).Y(),
+		softwarePostSource(in, uv.Subtract(offset)).Z(),
+		1,
+	}, true
+}
@@ -0,0 +1,570 @@
+/*****************************************************************************/
+/* renderer_software_raster.go                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"image"
+	"image/color"
+	"kaiju/matrix"
+)
+
+// SoftwareRenderTarget is the memory a SoftwareRenderer draws into. MSAA is
+// done by drawing at a larger size and averaging the samples of each pixel
+// when the target is resolved after drawing
+type SoftwareRenderTarget struct {
+	options RenderTargetOptions
+	// width and height are the size that is drawn at, samples times the
+	// size of the target on each axis
+	width    int
+	height   int
+	samples  int
+	color    []matrix.Color
+	depth    []matrix.Float
+	accum    []matrix.Vec4
+	reveal   []matrix.Float
+	resolved []matrix.Color
+}
+
+func newSoftwareRenderTarget(width, height int) *SoftwareRenderTarget {
+	t := &SoftwareRenderTarget{options: RenderTargetOptions{Format: RenderTargetFormatRGBA16F}}
+	t.resize(width, height)
+	return t
+}
+
+func (t *SoftwareRenderTarget) Width() int                   { return t.width / t.samples }
+func (t *SoftwareRenderTarget) Height() int                  { return t.height / t.samples }
+func (t *SoftwareRenderTarget) Options() RenderTargetOptions { return t.options }
+
+// Pixel is the color at the pixel as it was resolved, the top left pixel
+// is 0, 0
+func (t *SoftwareRenderTarget) Pixel(x, y int) matrix.Color {
+	return t.resolved[y*t.Width()+x]
+}
+
+// Depth is the depth at the pixel, from 0 at the near plane to 1 at the far
+// plane
+func (t *SoftwareRenderTarget) Depth(x, y int) matrix.Float {
+	return t.depth[y*t.samples*t.width+x*t.samples]
+}
+
+// Image copies the target into a new image
+func (t *SoftwareRenderTarget) Image() *image.RGBA {
+	w, h := t.Width(), t.Height()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, softwareRGBA(t.resolved[y*w+x]))
+		}
+	}
+	return img
+}
+
+// samplesPerAxis is how many times larger than the target it is drawn on
+// each axis for the MSAA sample count
+func samplesPerAxis(samples int) int {
+	f := 1
+	for f*f < samples {
+		f++
+	}
+	return f
+}
+
+func (t *SoftwareRenderTarget) resize(width, height int) {
+	t.samples = samplesPerAxis(t.options.Samples)
+	t.width, t.height = width*t.samples, height*t.samples
+	count := t.width * t.height
+	t.color = make([]matrix.Color, count)
+	t.depth = make([]matrix.Float, count)
+	t.accum = make([]matrix.Vec4, count)
+	t.reveal = make([]matrix.Float, count)
+	if t.samples == 1 && t.options.Format == RenderTargetFormatRGBA16F {
+		t.resolved = t.color
+	} else {
+		t.resolved = make([]matrix.Color, width*height)
+	}
+}
+
+// resolve averages the samples of each pixel and stores them the way the
+// format of the target would
+func (t *SoftwareRenderTarget) resolve() {
+	if t.samples == 1 && t.options.Format == RenderTargetFormatRGBA16F {
+		return
+	}
+	w, h, f := t.Width(), t.Height(), t.samples
+	weight := 1 / matrix.Float(f*f)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := matrix.Vec4{}
+			for sy := 0; sy < f; sy++ {
+				for sx := 0; sx < f; sx++ {
+					i := (y*f+sy)*t.width + x*f + sx
+					if t.options.Format == RenderTargetFormatDepth {
+						d := t.depth[i]
+						sum.AddAssign(matrix.Vec4{d, d, d, 1})
+					} else {
+						sum.AddAssign(matrix.Vec4(t.color[i]))
+					}
+				}
+			}
+			c := matrix.Color(sum.Scale(weight))
+			if t.options.Format != RenderTargetFormatRGBA16F {
+				for i := range c {
+					c[i] = matrix.Float(int(matrix.Clamp(c[i], 0, 1)*255+0.5)) / 255
+				}
+			}
+			t.resolved[y*w+x] = c
+		}
+	}
+}
+
+func (t *SoftwareRenderTarget) clear(c matrix.Color) {
+	for i := range t.color {
+		t.color[i] = c
+		t.depth[i] = 1
+		t.accum[i] = matrix.Vec4{}
+		t.reveal[i] = 1
+	}
+}
+
+func (t *SoftwareRenderTarget) clearDepth() {
+	for i := range t.depth {
+		t.depth[i] = 1
+	}
+}
+
+// composite lays the weighted transparent colors over the opaque colors
+func (t *SoftwareRenderTarget) composite() {
+	for i := range t.color {
+		reveal := t.reveal[i]
+		if matrix.Approx(reveal, 1) {
+			continue
+		}
+		accum := t.accum[i]
+		if matrix.IsInf(matrix.Max(matrix.Abs(accum[0]), matrix.Max(matrix.Abs(accum[1]), matrix.Abs(accum[2]))), 0) {
+			accum[0], accum[1], accum[2] = accum[3], accum[3], accum[3]
+		}
+		scale := 1 / matrix.Max(accum[3], 0.00001)
+		dst := &t.color[i]
+		for c := 0; c < 3; c++ {
+			dst[c] = accum[c]*scale*(1-reveal) + dst[c]*reveal
+		}
+	}
+}
+
+func softwareRGBA(c matrix.Color) color.RGBA {
+	to8 := func(v matrix.Float) uint8 {
+		return uint8(matrix.Clamp(v, 0, 1)*255 + 0.5)
+	}
+	return color.RGBA{to8(c.R()), to8(c.G()), to8(c.B()), to8(c.A())}
+}
+
+type softwareTexture struct {
+	width  int
+	height int
+	pix    []byte
+	filter TextureFilter
+	// target is read instead of the pixels for textures that sample a
+	// render target, it is read with clamp addressing
+	target *SoftwareRenderTarget
+}
+
+// load copies the texture data in as RGBA, compressed formats are not
+// supported
+func (t *softwareTexture) load(data *TextureData) bool {
+	count := t.width * t.height
+	switch data.InternalFormat {
+	case TextureInputTypeRgba8:
+		if len(data.Mem) < count*bytesInPixel {
+			return false
+		}
+		copy(t.pix, data.Mem)
+	case TextureInputTypeRgb8:
+		if len(data.Mem) < count*3 {
+			return false
+		}
+		for i := 0; i < count; i++ {
+			copy(t.pix[i*bytesInPixel:], data.Mem[i*3:i*3+3])
+			t.pix[i*bytesInPixel+3] = 255
+		}
+	case TextureInputTypeLuminance:
+		if len(data.Mem) < count {
+			return false
+		}
+		for i := 0; i < count; i++ {
+			l := data.Mem[i]
+			t.pix[i*bytesInPixel+0] = l
+			t.pix[i*bytesInPixel+1] = l
+			t.pix[i*bytesInPixel+2] = l
+			t.pix[i*bytesInPixel+3] = 255
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// texel reads the pixel with repeat addressing
+func (t *softwareTexture) texel(x, y int) matrix.Color {
+	if t.target != nil {
+		x = max(0, min(x, t.target.Width()-1))
+		y = max(0, min(y, t.target.Height()-1))
+		return t.target.Pixel(x, y)
+	}
+	x = ((x % t.width) + t.width) % t.width
+	y = ((y % t.height) + t.height) % t.height
+	i := (y*t.width + x) * bytesInPixel
+	return matrix.Color{
+		matrix.Float(t.pix[i+0]) / 255,
+		matrix.Float(t.pix[i+1]) / 255,
+		matrix.Float(t.pix[i+2]) / 255,
+		matrix.Float(t.pix[i+3]) / 255,
+	}
+}
+
+func (t *softwareTexture) sample(uv matrix.Vec2) matrix.Color {
+	u := uv.X() * matrix.Float(t.width)
+	v := uv.Y() * matrix.Float(t.height)
+	if t.filter == TextureFilterNearest {
+		return t.texel(int(matrix.Floor(u)), int(matrix.Floor(v)))
+	}
+	u, v = u-0.5, v-0.5
+	fx, fy := matrix.Floor(u), matrix.Floor(v)
+	x, y := int(fx), int(fy)
+	tx, ty := u-fx, v-fy
+	top := matrix.Vec4Lerp(matrix.Vec4(t.texel(x, y)), matrix.Vec4(t.texel(x+1, y)), tx)
+	bottom := matrix.Vec4Lerp(matrix.Vec4(t.texel(x, y+1)), matrix.Vec4(t.texel(x+1, y+1)), tx)
+	return matrix.Color(matrix.Vec4Lerp(top, bottom, ty))
+}
+
+// softwareVertex is a vertex in clip space as it comes out of the vertex
+// shader
+type softwareVertex struct {
+	clip matrix.Vec4
+	vary SoftwareVaryings
+}
+
+// softwareScreenVertex is a vertex in pixels, the varyings are already
+// divided by w so they can be interpolated with perspective correction
+type softwareScreenVertex struct {
+	x, y, z, invW matrix.Float
+	vary          SoftwareVaryings
+}
+
+type softwareRaster struct {
+	target   *SoftwareRenderTarget
+	shader   *softwareShader
+	fragment SoftwareFragmentInput
+	vertex   SoftwareVertexInput
+	verts    []softwareVertex
+	clipA    []softwareVertex
+	clipB    []softwareVertex
+	screen   []softwareScreenVertex
+	// depthOnly skips the fragment shader and only writes depth, it is
+	// used to draw shadow maps
+	depthOnly bool
+}
+
+func (r *softwareRaster) drawMesh(mesh *softwareMesh, instance SoftwareInstance) {
+	r.vertex.Globals = r.fragment.Globals
+	r.vertex.Instance = instance
+	r.verts = r.verts[:0]
+	for i := range mesh.verts {
+		r.vertex.Vertex = &mesh.verts[i]
+		r.vertex.VertexIndex = i
+		v := softwareVertex{}
+		v.clip = r.shader.program.Vertex(&r.vertex, &v.vary)
+		r.verts = append(r.verts, v)
+	}
+	idx := mesh.indices
+	switch r.shader.drawMode {
+	case MeshDrawModePoints:
+		for i := range idx {
+			r.primitive(idx[i : i+1])
+		}
+	case MeshDrawModeLines:
+		for i := 0; i+1 < len(idx); i += 2 {
+			r.primitive(idx[i : i+2])
+		}
+	default:
+		for i := 0; i+2 < len(idx); i += 3 {
+			r.primitive(idx[i : i+3])
+		}
+	}
+}
+
+// primitive clips the point, line or triangle against the near plane and
+// draws what is left of it
+func (r *softwareRaster) primitive(indices []uint32) {
+	r.clipA = r.clipA[:0]
+	for _, i := range indices {
+		if int(i) >= len(r.verts) {
+			return
+		}
+		r.clipA = append(r.clipA, r.verts[i])
+	}
+	closed := len(indices) > 2
+	r.clipB = softwareClip(r.clipA, r.clipB[:0], closed, func(v matrix.Vec4) matrix.Float { return v.Z() })
+	r.clipA = softwareClip(r.clipB, r.clipA[:0], closed, func(v matrix.Vec4) matrix.Float { return v.W() - 0.00001 })
+	if len(r.clipA) < len(indices) {
+		return
+	}
+	r.screen = r.screen[:0]
+	for i := range r.clipA {
+		r.screen = append(r.screen, r.project(&r.clipA[i]))
+	}
+	switch len(indices) {
+	case 1:
+		r.point(&r.screen[0])
+	case 2:
+		r.line(&r.screen[0], &r.screen[1])
+	default:
+		for i := 2; i < len(r.screen); i++ {
+			r.triangle(&r.screen[0], &r.screen[i-1], &r.screen[i])
+		}
+	}
+}
+
+// softwareClip keeps the part of the polygon, or line when it is not
+// closed, where the distance is not negative
+func softwareClip(in, out []softwareVertex, closed bool, distance func(matrix.Vec4) matrix.Float) []softwareVertex {
+	count := len(in)
+	if count == 1 {
+		if distance(in[0].clip) >= 0 {
+			out = append(out, in[0])
+		}
+		return out
+	}
+	edges := count
+	if !closed {
+		edges = count - 1
+	}
+	for i := 0; i < edges; i++ {
+		a, b := &in[i], &in[(i+1)%count]
+		da, db := distance(a.clip), distance(b.clip)
+		if da >= 0 && (closed || i == 0) {
+			out = append(out, *a)
+		}
+		if (da >= 0) != (db >= 0) {
+			out = append(out, softwareLerpVertex(a, b, da/(da-db)))
+		}
+		if !closed && db >= 0 {
+			out = append(out, *b)
+		}
+	}
+	return out
+}
+
+func softwareLerpVertex(a, b *softwareVertex, t matrix.Float) softwareVertex {
+	v := softwareVertex{clip: matrix.Vec4Lerp(a.clip, b.clip, t)}
+	fa, fb, fv := a.vary.floats(), b.vary.floats(), v.vary.floats()
+	for i := range fv {
+		fv[i] = fa[i] + (fb[i]-fa[i])*t
+	}
+	return v
+}
+
+func (r *softwareRaster) project(v *softwareVertex) softwareScreenVertex {
+	invW := 1 / v.clip.W()
+	s := softwareScreenVertex{
+		x:    (v.clip.X()*invW*0.5 + 0.5) * matrix.Float(r.target.width),
+		y:    (v.clip.Y()*invW*0.5 + 0.5) * matrix.Float(r.target.height),
+		z:    v.clip.Z() * invW,
+		invW: invW,
+		vary: v.vary,
+	}
+	f := s.vary.floats()
+	for i := range f {
+		f[i] *= invW
+	}
+	return s
+}
+
+func (r *softwareRaster) point(a *softwareScreenVertex) {
+	r.fragment.UV0Dx, r.fragment.UV0Dy = matrix.Vec2{}, matrix.Vec2{}
+	r.shade(int(matrix.Floor(a.x)), int(matrix.Floor(a.y)), [3]matrix.Float{1, 0, 0}, a, a, a)
+}
+
+func (r *softwareRaster) line(a, b *softwareScreenVertex) {
+	r.fragment.UV0Dx, r.fragment.UV0Dy = matrix.Vec2{}, matrix.Vec2{}
+	dx, dy := b.x-a.x, b.y-a.y
+	steps := int(matrix.Ceil(matrix.Max(matrix.Abs(dx), matrix.Abs(dy))))
+	if steps == 0 {
+		r.point(a)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		t := matrix.Float(i) / matrix.Float(steps)
+		x := int(matrix.Floor(a.x + dx*t))
+		y := int(matrix.Floor(a.y + dy*t))
+		r.shade(x, y, [3]matrix.Float{1 - t, t, 0}, a, b, b)
+	}
+}
+
+func (r *softwareRaster) triangle(a, b, c *softwareScreenVertex) {
+	area := (b.x-a.x)*(c.y-a.y) - (c.x-a.x)*(b.y-a.y)
+	if area == 0 {
+		return
+	}
+	// Vulkan is set up with clockwise front faces, with y pointing down
+	// that is a positive area here
+	switch r.shader.cullMode {
+	case MeshCullModeFront:
+		if area > 0 {
+			return
+		}
+	case MeshCullModeBack:
+		if area < 0 {
+			return
+		}
+	}
+	minX := max(int(matrix.Floor(min(a.x, b.x, c.x))), 0)
+	maxX := min(int(matrix.Ceil(max(a.x, b.x, c.x))), r.target.width-1)
+	minY := max(int(matrix.Floor(min(a.y, b.y, c.y))), 0)
+	maxY := min(int(matrix.Ceil(max(a.y, b.y, c.y))), r.target.height-1)
+	invArea := 1 / area
+	// The weights change by the same amount for every pixel step which
+	// gives the screen space derivatives of the varyings
+	stepX := [3]matrix.Float{(b.y - c.y) * invArea, (c.y - a.y) * invArea, (a.y - b.y) * invArea}
+	stepY := [3]matrix.Float{(c.x - b.x) * invArea, (a.x - c.x) * invArea, (b.x - a.x) * invArea}
+	edges := [3][2]*softwareScreenVertex{{b, c}, {c, a}, {a, b}}
+	topLeft := [3]bool{}
+	for i := range edges {
+		topLeft[i] = softwareTopLeft(edges[i][0], edges[i][1], area)
+	}
+	for py := minY; py <= maxY; py++ {
+		cy := matrix.Float(py) + 0.5
+	pixels:
+		for px := minX; px <= maxX; px++ {
+			cx := matrix.Float(px) + 0.5
+			var w [3]matrix.Float
+			for i := range edges {
+				w[i] = softwareEdge(edges[i][0], edges[i][1], cx, cy) * invArea
+				// Pixels exactly on an edge shared by two triangles are
+				// only drawn by one of them, the one where it is a top or
+				// left edge, so blended meshes don't show their seams
+				if w[i] < 0 || (w[i] == 0 && !topLeft[i]) {
+					continue pixels
+				}
+			}
+			uv := softwareUV(w, a, b, c)
+			r.fragment.UV0Dx = softwareUV([3]matrix.Float{w[0] + stepX[0], w[1] + stepX[1], w[2] + stepX[2]}, a, b, c).Subtract(uv)
+			r.fragment.UV0Dy = softwareUV([3]matrix.Float{w[0] + stepY[0], w[1] + stepY[1], w[2] + stepY[2]}, a, b, c).Subtract(uv)
+			r.shade(px, py, w, a, b, c)
+		}
+	}
+}
+
+// softwareEdge is the edge function of the point for the edge from p to q,
+// the edge is always evaluated in the same direction so that two triangles
+// sharing it get exactly opposite values
+func softwareEdge(p, q *softwareScreenVertex, x, y matrix.Float) matrix.Float {
+	if q.y < p.y || (q.y == p.y && q.x < p.x) {
+		return -((p.x-q.x)*(y-q.y) - (p.y-q.y)*(x-q.x))
+	}
+	return (q.x-p.x)*(y-p.y) - (q.y-p.y)*(x-p.x)
+}
+
+// softwareTopLeft reports if the edge from p to q is a top or left edge of
+// a triangle with the given winding area, with y pointing down
+func softwareTopLeft(p, q *softwareScreenVertex, area matrix.Float) bool {
+	dx, dy := q.x-p.x, q.y-p.y
+	if area < 0 {
+		dx, dy = -dx, -dy
+	}
+	return (dy == 0 && dx > 0) || dy < 0
+}
+
+func softwareUV(w [3]matrix.Float, a, b, c *softwareScreenVertex) matrix.Vec2 {
+	invW := w[0]*a.invW + w[1]*b.invW + w[2]*c.invW
+	uv := a.vary.UV0.Scale(w[0]).Add(b.vary.UV0.Scale(w[1])).Add(c.vary.UV0.Scale(w[2]))
+	return uv.Scale(1 / invW)
+}
+
+// shade runs the fragment shader for the pixel at the weights of the
+// three vertices and writes the result to the target
+func (r *softwareRaster) shade(px, py int, w [3]matrix.Float, a, b, c *softwareScreenVertex) {
+	t := r.target
+	if px < 0 || py < 0 || px >= t.width || py >= t.height {
+		return
+	}
+	z := w[0]*a.z + w[1]*b.z + w[2]*c.z
+	idx := py*t.width + px
+	if z < 0 || z > 1 || z >= t.depth[idx] {
+		return
+	}
+	if r.depthOnly {
+		t.depth[idx] = z
+		return
+	}
+	invW := w[0]*a.invW + w[1]*b.invW + w[2]*c.invW
+	fa, fb, fc := a.vary.floats(), b.vary.floats(), c.vary.floats()
+	f := r.fragment.Varyings.floats()
+	scale := 1 / invW
+	for i := range f {
+		f[i] = (w[0]*fa[i] + w[1]*fb[i] + w[2]*fc[i]) * scale
+	}
+	for _, d := range r.fragment.Varyings.ClipDistance {
+		if d < 0 {
+			return
+		}
+	}
+	// With MSAA the target is drawn larger, the fragment still sees the
+	// coordinates of the pixel the sample belongs to
+	samples := matrix.Float(t.samples)
+	r.fragment.FragCoord = matrix.Vec4{(matrix.Float(px) + 0.5) / samples, (matrix.Float(py) + 0.5) / samples, z, invW}
+	col, keep := r.shader.program.Fragment(&r.fragment)
+	if !keep {
+		return
+	}
+	if !r.fragment.Blending {
+		t.color[idx] = col
+		t.depth[idx] = z
+		return
+	}
+	// Same weighting as the OIT variants of the shaders
+	distWeight := matrix.Clamp(0.03/(0.00001+matrix.Pow(z/200, 4)), 0.01, 3000)
+	alphaWeight := min(1, max(col.R(), col.G(), col.B(), col.A())*40+0.01)
+	weight := alphaWeight * alphaWeight * distWeight
+	alpha := col.A()
+	accum := &t.accum[idx]
+	accum[0] += col.R() * alpha * weight
+	accum[1] += col.G() * alpha * weight
+	accum[2] += col.B() * alpha * weight
+	accum[3] += alpha * weight
+	t.reveal[idx] *= 1 - alpha
+}
@@ -0,0 +1,402 @@
+/*****************************************************************************/
+/* renderer_software_shaders.go                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"kaiju/matrix"
+)
+
+// Slots of SoftwareVaryings.Custom used by the UI programs
+const (
+	softwareUIBGColor = iota
+	softwareUISize2D
+	softwareUIBorderRadius
+	softwareUIBorderSize
+	softwareUIBorderColor
+	softwareUIBorderLen
+)
+
+// softwarePrograms are the Go versions of the shaders that ship with the
+// engine, they follow the GLSL sources in content/shaders
+func softwarePrograms() map[string]SoftwareProgram {
+	return map[string]SoftwareProgram{
+		assets.ShaderDefinitionBasic:   {softwareBasicVertex, softwareBasicFragment},
+		assets.ShaderDefinitionGrid:    {softwareBasicVertex, softwareGridFragment},
+		assets.ShaderDefinitionUI:      {softwareUIVertex, softwareUINineFragment},
+		assets.ShaderDefinitionSprite:  {softwareUIVertex, softwareSpriteFragment},
+		assets.ShaderDefinitionText:    {softwareUIVertex, softwareTextFragment},
+		assets.ShaderDefinitionText3D:  {softwareText3DVertex, softwareTextFragment},
+		assets.ShaderDefinitionPBR:     {softwarePBRVertex, softwarePBRFragment},
+		assets.ShaderDefinitionSkinned: {softwareSkinnedVertex, softwareBasicFragment},
+		assets.ShaderDefinitionMorph:   {softwareMorphVertex, softwareBasicFragment},
+
+		assets.ShaderDefinitionPostTonemap:             {softwarePostVertex, softwareTonemapFragment},
+		assets.ShaderDefinitionPostBloomBright:         {softwarePostVertex, softwareBloomBrightFragment},
+		assets.ShaderDefinitionPostBlur:                {softwarePostVertex, softwareBlurFragment},
+		assets.ShaderDefinitionPostBloomCombine:        {softwarePostVertex, softwareBloomCombineFragment},
+		assets.ShaderDefinitionPostFXAA:                {softwarePostVertex, softwareFXAAFragment},
+		assets.ShaderDefinitionPostColorGrading:        {softwarePostVertex, softwareColorGradingFragment},
+		assets.ShaderDefinitionPostVignette:            {softwarePostVertex, softwareVignetteFragment},
+		assets.ShaderDefinitionPostChromaticAberration: {softwarePostVertex, softwareChromaticAberrationFragment},
+	}
+}
+
+func softwareTransform(view, projection, model matrix.Mat4, v *Vertex, out *SoftwareVaryings) matrix.Vec4 {
+	world := model.MultiplyVec4(matrix.Vec4{v.Position.X(), v.Position.Y(), v.Position.Z(), 1})
+	out.Position = world.AsVec3()
+	return projection.MultiplyVec4(view.MultiplyVec4(world))
+}
+
+// softwareNormal moves the normal into world space by the inverse transpose
+// of the model like the vertex shaders do, Invert only undoes rigid
+// transforms so the full inverse is used for scaled and sheared models
+func softwareNormal(model matrix.Mat4, n matrix.Vec3) matrix.Vec3 {
+	model.Inverse()
+	return model.Transpose().MultiplyVec4(matrix.Vec4{n.X(), n.Y(), n.Z(), 0}).AsVec3().Normal()
+}
+
+// softwareOpaque discards the translucent fragments of the opaque pass
+// like the non OIT variants of the shaders do
+func softwareOpaque(in *SoftwareFragmentInput, c matrix.Color) (matrix.Color, bool) {
+	return c, in.Blending || c.A() >= 1-0.0001
+}
+
+func softwareBasicVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	out.Color = matrix.Color(matrix.Vec4(in.Vertex.Color).Multiply(in.Instance.Vec4("color")))
+	out.UV0 = in.Vertex.UV0
+	model := in.Instance.Mat4("model")
+	out.Normal = softwareNormal(model, in.Vertex.Normal)
+	return softwareTransform(in.Globals.View, in.Globals.Projection, model, in.Vertex, out)
+}
+
+// softwareSkin blends the joints of the vertex by their weights, vertices
+// without weights are not skinned
+func softwareSkin(in *SoftwareVertexInput) matrix.Mat4 {
+	v := in.Vertex
+	total := v.JointWeights.X() + v.JointWeights.Y() + v.JointWeights.Z() + v.JointWeights.W()
+	if total <= 0 {
+		return matrix.Mat4Identity()
+	}
+	offset := int(in.Instance.Float("jointOffset"))
+	skin := matrix.Mat4{}
+	for i := range 4 {
+		joint := in.Joint(offset + int(v.JointIds[i]))
+		w := v.JointWeights[i] / total
+		for j := range skin {
+			skin[j] += joint[j] * w
+		}
+	}
+	return skin
+}
+
+func softwareSkinnedVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	skin := softwareSkin(in)
+	v := *in.Vertex
+	v.Position = skin.MultiplyVec4(matrix.Vec4{v.Position.X(), v.Position.Y(), v.Position.Z(), 1}).AsVec3()
+	v.Normal = skin.MultiplyVec4(matrix.Vec4{v.Normal.X(), v.Normal.Y(), v.Normal.Z(), 0}).AsVec3().Normal()
+	skinned := *in
+	skinned.Vertex = &v
+	return softwareBasicVertex(&skinned, out)
+}
+
+// softwareMorph blends the deltas of the morph targets into the vertex by
+// the weights of the instance
+func softwareMorph(in *SoftwareVertexInput) (matrix.Vec3, matrix.Vec3) {
+	position, normal := in.Vertex.Position, in.Vertex.Normal
+	morph := in.Instance.Vec4(MorphParamInfo)
+	w0, w1 := in.Instance.Vec4(MorphParamWeights0), in.Instance.Vec4(MorphParamWeights1)
+	vertexCount := int(morph.Y())
+	for i := range min(int(morph.Z()), MaxMorphTargets) {
+		weight := w0[min(i, 3)]
+		if i >= 4 {
+			weight = w1[i-4]
+		}
+		delta := int(morph.X()) + (i*vertexCount+in.VertexIndex)*2
+		position.AddAssign(in.MorphDelta(delta).AsVec3().Scale(weight))
+		normal.AddAssign(in.MorphDelta(delta + 1).AsVec3().Scale(weight))
+	}
+	return position, normal
+}
+
+func softwareMorphVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	v := *in.Vertex
+	v.Position, v.Normal = softwareMorph(in)
+	morphed := *in
+	morphed.Vertex = &v
+	return softwareBasicVertex(&morphed, out)
+}
+
+func softwareBasicFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	c := matrix.Vec4(in.Sample(0, in.Varyings.UV0)).Multiply(matrix.Vec4(in.Varyings.Color))
+	light := softwareLighting(in)
+	c[matrix.Vx] *= light.X()
+	c[matrix.Vy] *= light.Y()
+	c[matrix.Vz] *= light.Z()
+	return softwareOpaque(in, matrix.Color(c))
+}
+
+// softwareLightVector is the direction from the position to the light and
+// how much of the light reaches it over distance and through the cone
+func softwareLightVector(light *LightShaderData, position matrix.Vec3) (matrix.Vec3, matrix.Float) {
+	dir := light.Direction.AsVec3()
+	toLight := dir.Negative()
+	attenuation := matrix.Float(1)
+	if lightType := LightType(light.Position.W()); lightType != LightTypeDirectional {
+		toLight = light.Position.AsVec3().Subtract(position)
+		dist := toLight.Length()
+		toLight = toLight.Scale(1 / max(dist, 0.0001))
+		falloff := matrix.Clamp(1-(dist/light.Direction.W())*(dist/light.Direction.W()), 0, 1)
+		attenuation = falloff * falloff
+		if lightType == LightTypeSpot {
+			theta := matrix.Vec3Dot(toLight.Negative(), dir)
+			attenuation *= softwareSmoothstep(light.Cone.Y(), light.Cone.X(), theta)
+		}
+	}
+	return toLight, attenuation
+}
+
+func softwareLightContribution(light *LightShaderData, position, normal matrix.Vec3) matrix.Vec3 {
+	toLight, attenuation := softwareLightVector(light, position)
+	diffuse := max(matrix.Vec3Dot(normal, toLight), 0)
+	return light.Color.AsVec3().Scale(light.Color.W() * diffuse * attenuation)
+}
+
+// softwareShadow is how much of the light reaches the position, from 0 in
+// full shadow to 1. Directional lights pick their cascade by the view depth
+// of the position, the shadow map is compared over the PCF radius
+func softwareShadow(in *SoftwareFragmentInput, light *LightShaderData, position, normal matrix.Vec3) matrix.Float {
+	g := in.Globals
+	index, count := int(light.Shadow.X()), int(light.Shadow.Y())
+	if LightType(light.Position.W()) == LightTypeDirectional {
+		depth := -g.View.TransformPoint(position).Z()
+		cascade := 0
+		for cascade < count && depth > g.ShadowCascadeSplits[cascade] {
+			cascade++
+		}
+		if cascade == count {
+			return 1
+		}
+		index += cascade
+	}
+	p := position.Add(normal.Scale(light.Shadow.W()))
+	clip := g.ShadowMatrices[index].MultiplyVec4(matrix.Vec4{p.X(), p.Y(), p.Z(), 1})
+	if clip.W() <= 0 {
+		return 1
+	}
+	z := clip.Z()/clip.W() - light.Shadow.Z()
+	if z > 1 {
+		return 1
+	}
+	size := 1 / light.ShadowFilter.Y()
+	x := int(matrix.Floor((clip.X()/clip.W()*0.5 + 0.5) * size))
+	y := int(matrix.Floor((clip.Y()/clip.W()*0.5 + 0.5) * size))
+	radius := int(light.ShadowFilter.X())
+	lit, total := 0, 0
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if z <= in.ShadowDepth(index, x+dx, y+dy) {
+				lit++
+			}
+			total++
+		}
+	}
+	return matrix.Float(lit) / matrix.Float(total)
+}
+
+// softwareTileLights is the mask of the lights in the screen tile of the
+// fragment
+func softwareTileLights(in *SoftwareFragmentInput) uint32 {
+	g := in.Globals
+	tx := min(max(int(in.FragCoord.X()/g.ScreenSize.X()*LightTilesX), 0), LightTilesX-1)
+	ty := min(max(int(in.FragCoord.Y()/g.ScreenSize.Y()*LightTilesY), 0), LightTilesY-1)
+	tile := ty*LightTilesX + tx
+	return g.LightTiles[tile/4][tile%4]
+}
+
+// softwareLighting adds up the lights in the screen tile of the fragment
+// like basic.frag, without any lights the fragment is unlit
+func softwareLighting(in *SoftwareFragmentInput) matrix.Vec3 {
+	g := in.Globals
+	if g.LightCount == 0 {
+		return matrix.Vec3One()
+	}
+	normal := in.Varyings.Normal.Normal()
+	mask := softwareTileLights(in)
+	light := g.AmbientLight.AsVec3()
+	for i := 0; mask != 0; i++ {
+		if mask&1 != 0 {
+			l := &g.Lights[i]
+			contribution := softwareLightContribution(l, in.Varyings.Position, normal)
+			if in.ReceiveShadows && l.Shadow.X() >= 0 {
+				contribution = contribution.Scale(softwareShadow(in, l, in.Varyings.Position, normal))
+			}
+			light.AddAssign(contribution)
+		}
+		mask >>= 1
+	}
+	return light
+}
+
+func softwareGridFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	return in.Varyings.Color, true
+}
+
+// softwareAtlasUV moves the UV into the area of the texture given by uvs
+func softwareAtlasUV(uv matrix.Vec2, uvs matrix.Vec4) matrix.Vec2 {
+	uv = uv.Multiply(matrix.Vec2{uvs.Z(), uvs.W()})
+	uv[matrix.Vy] += (1 - uvs.W()) - uvs.Y()
+	uv[matrix.Vx] += uvs.X()
+	return uv
+}
+
+func softwareUIVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	inst := in.Instance
+	clip := softwareTransform(in.Globals.UIView, in.Globals.UIProjection,
+		inst.Mat4("model"), in.Vertex, out)
+	out.UV0 = softwareAtlasUV(in.Vertex.UV0, inst.Vec4("uvs"))
+	out.Color = matrix.Color(matrix.Vec4(in.Vertex.Color).Multiply(inst.Vec4("fgColor")))
+	out.Custom[softwareUIBGColor] = inst.Vec4("bgColor")
+	out.Custom[softwareUISize2D] = inst.Vec4("size2D")
+	out.Custom[softwareUIBorderRadius] = inst.Vec4("borderRadius")
+	out.Custom[softwareUIBorderSize] = inst.Vec4("borderSize")
+	bc := inst.Mat4("borderColor")
+	out.Custom[softwareUIBorderColor] = matrix.Vec4{bc[0], bc[1], bc[2], bc[3]}
+	bl := inst.Vec2("borderLen")
+	out.Custom[softwareUIBorderLen] = matrix.Vec4{bl.X(), bl.Y(), 0, 0}
+	scissor := inst.Vec4("scissor")
+	pos := out.Position
+	out.ClipDistance = [4]matrix.Float{
+		pos.X() - scissor.X(),
+		pos.Y() - scissor.Y(),
+		scissor.Z() - pos.X(),
+		scissor.W() - pos.Y(),
+	}
+	return clip
+}
+
+func softwareText3DVertex(in *SoftwareVertexInput, out *SoftwareVaryings) matrix.Vec4 {
+	inst := in.Instance
+	out.UV0 = softwareAtlasUV(in.Vertex.UV0, inst.Vec4("uvs"))
+	out.Color = matrix.Color(matrix.Vec4(in.Vertex.Color).Multiply(inst.Vec4("fgColor")))
+	out.Custom[softwareUIBGColor] = inst.Vec4("bgColor")
+	return softwareTransform(in.Globals.View, in.Globals.Projection,
+		inst.Mat4("model"), in.Vertex, out)
+}
+
+func softwareSpriteFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	c := in.Sample(0, in.Varyings.UV0)
+	return softwareOpaque(in, matrix.Color(matrix.Vec4(c).Multiply(matrix.Vec4(in.Varyings.Color))))
+}
+
+func softwareSmoothstep(edge0, edge1, x matrix.Float) matrix.Float {
+	t := matrix.Clamp((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
+
+func softwareProcessAxis(coord, border, ratio matrix.Float) matrix.Float {
+	l := border * ratio
+	lScale := 1 - l*2
+	bScale := 1 - border*2
+	if coord < l {
+		return coord / ratio
+	} else if coord > 1-l {
+		return 1 - ((1 - coord) / ratio)
+	}
+	return (coord-l)*(bScale/lScale) + border
+}
+
+func softwareRoundedBoxSDF(center, size matrix.Vec2, radius matrix.Vec4) matrix.Float {
+	rx, ry := radius.Y(), radius.Z()
+	if center.X() > 0 {
+		rx, ry = radius.X(), radius.W()
+	}
+	r := ry
+	if center.Y() > 0 {
+		r = rx
+	}
+	qx := matrix.Abs(center.X()) - size.X() + r
+	qy := matrix.Abs(center.Y()) - size.Y() + r
+	outside := matrix.Vec2{matrix.Max(qx, 0), matrix.Max(qy, 0)}
+	return matrix.Min(matrix.Max(qx, qy), 0) + outside.Length() - r
+}
+
+func softwareUINineFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	vary := &in.Varyings
+	size2D := vary.Custom[softwareUISize2D]
+	borderLen := vary.Custom[softwareUIBorderLen]
+	radius := vary.Custom[softwareUIBorderRadius]
+	borderSize := vary.Custom[softwareUIBorderSize]
+	uv := vary.UV0
+	nineUV := matrix.Vec2{
+		softwareProcessAxis(uv.X(), borderLen.X()/size2D.Z(), size2D.Z()/size2D.X()),
+		softwareProcessAxis(uv.Y(), borderLen.Y()/size2D.W(), size2D.W()/size2D.Y()),
+	}
+	c := matrix.Vec4(in.Sample(0, nineUV)).Multiply(matrix.Vec4(vary.Color))
+	const edgeSoftness = 2.0
+	size := matrix.Vec2{size2D.X() / 2, size2D.Y() / 2}
+	pixPos := size.Subtract(uv.Multiply(matrix.Vec2{size2D.X(), size2D.Y()}))
+	dist := softwareRoundedBoxSDF(pixPos, size, radius)
+	smoothedAlpha := 1 - softwareSmoothstep(0, edgeSoftness, dist)
+	pixPos[matrix.Vx] += borderSize.X()/2 - borderSize.Z()/2
+	pixPos[matrix.Vy] += borderSize.Y()/2 - borderSize.W()/2
+	size[matrix.Vx] -= (borderSize.X() + borderSize.Z()) / 2
+	size[matrix.Vy] -= (borderSize.Y() + borderSize.W()) / 2
+	borderDist := softwareRoundedBoxSDF(pixPos, size, radius)
+	borderAlpha := softwareSmoothstep(0, edgeSoftness, borderDist)
+	c = matrix.Vec4Lerp(c, vary.Custom[softwareUIBorderColor], borderAlpha)
+	c[matrix.Vw] *= smoothedAlpha
+	return softwareOpaque(in, matrix.Color(c))
+}
+
+func softwareTextFragment(in *SoftwareFragmentInput) (matrix.Color, bool) {
+	msdf := in.Sample(0, in.Varyings.UV0)
+	r, g, b := msdf.R(), msdf.G(), msdf.B()
+	median := max(min(r, g), min(max(r, g), b))
+	texSize := in.TextureSize(0)
+	dxdy := matrix.Vec2{
+		(matrix.Abs(in.UV0Dx.X()) + matrix.Abs(in.UV0Dy.X())) * texSize.X(),
+		(matrix.Abs(in.UV0Dx.Y()) + matrix.Abs(in.UV0Dy.Y())) * texSize.Y(),
+	}
+	opacity := matrix.Float(1)
+	if l := dxdy.Length(); l > 0 {
+		opacity = matrix.Clamp((median-0.5)*8/l+0.5, 0, 1)
+	}
+	c := matrix.Vec4Lerp(in.Varyings.Custom[softwareUIBGColor], matrix.Vec4(in.Varyings.Color), opacity)
+	return softwareOpaque(in, matrix.Color(c))
+}
@@ -0,0 +1,188 @@
+/*****************************************************************************/
+/* renderer_software_test.go                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"fmt"
+	"kaiju/assets"
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+type softwareTestCaches struct {
+	shaders  ShaderCache
+	textures TextureCache
+	meshes   MeshCache
+}
+
+func (c *softwareTestCaches) ShaderCache() *ShaderCache   { return &c.shaders }
+func (c *softwareTestCaches) TextureCache() *TextureCache { return &c.textures }
+func (c *softwareTestCaches) MeshCache() *MeshCache       { return &c.meshes }
+func (c *softwareTestCaches) FontCache() *FontCache       { return nil }
+
+type softwareTestShaderData struct {
+	ShaderDataBase
+	Color matrix.Color
+}
+
+func (s softwareTestShaderData) Size() int {
+	return int(unsafe.Sizeof(softwareTestShaderData{}) - ShaderBaseDataStart)
+}
+
+func softwareTestSetup(t *testing.T) (*SoftwareRenderer, *softwareTestCaches, *Shader) {
+	t.Helper()
+	r := NewSoftwareRenderer(64, 64)
+	caches := &softwareTestCaches{
+		shaders: NewShaderCache(r, nil),
+		meshes:  NewMeshCache(r, nil),
+	}
+	caches.shaders.shaderDefinitions[assets.ShaderDefinitionBasic] = ShaderDef{
+		FrustumCulling: true,
+		CastShadows:    true,
+		Vulkan:         ShaderDefDriver{Vert: "basic.vert", Frag: "basic.frag"},
+		Fields:         []ShaderDefField{{"model", "mat4"}, {"color", "vec4"}},
+	}
+	if err := r.Initialize(caches, 64, 64); err != nil {
+		t.Fatal(err)
+	}
+	shader := caches.shaders.ShaderFromDefinition(assets.ShaderDefinitionBasic)
+	caches.shaders.CreatePending()
+	return r, caches, shader
+}
+
+func softwareTestQuad(r *SoftwareRenderer, d *Drawings, shader *Shader, mesh *Mesh, pos matrix.Vec3, color matrix.Color) {
+	sd := &softwareTestShaderData{NewShaderDataBase(), color}
+	m := matrix.Mat4Identity()
+	m.Translate(pos)
+	sd.SetModel(m)
+	d.AddDrawing(Drawing{
+		Renderer:    r,
+		Shader:      shader,
+		Mesh:        mesh,
+		ShaderData:  sd,
+		UseBlending: color.A() < 1,
+	})
+}
+
+func softwareTestRender(r *SoftwareRenderer, d *Drawings, caches *softwareTestCaches) {
+	camera := cameras.NewStandardCamera(64, 64, matrix.Vec3{0, 0, 2})
+	uiCamera := cameras.NewStandardCameraOrthographic(64, 64, matrix.Vec3{0, 0, 250})
+	caches.meshes.CreatePending()
+	d.PreparePending()
+	r.ReadyFrame(FrameData{Camera: camera, UICamera: uiCamera})
+	d.Render(r, camera, nil)
+}
+
+func softwareTestColor(t *testing.T, r *SoftwareRenderer, x, y int, expected matrix.Color) {
+	t.Helper()
+	got := r.Image().RGBAAt(x, y)
+	want := softwareRGBA(expected)
+	diff := func(a, b uint8) int { return max(int(a), int(b)) - min(int(a), int(b)) }
+	if diff(got.R, want.R) > 2 || diff(got.G, want.G) > 2 || diff(got.B, want.B) > 2 {
+		t.Fatalf("expected %v at %d, %d but got %v", want, x, y, got)
+	}
+}
+
+func TestSoftwareRendererDepth(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	d := NewDrawings()
+	mesh := NewMeshQuad(&caches.meshes)
+	softwareTestQuad(r, &d, shader, mesh, matrix.Vec3{0, 0, 0}, matrix.ColorRed())
+	softwareTestQuad(r, &d, shader, mesh, matrix.Vec3{0.5, 0, 0.5}, matrix.ColorBlue())
+	softwareTestRender(r, &d, caches)
+	softwareTestColor(t, r, 0, 0, r.ClearColor)
+	softwareTestColor(t, r, 28, 32, matrix.ColorRed())
+	// The blue quad is closer to the camera and covers the red one
+	softwareTestColor(t, r, 36, 32, matrix.ColorBlue())
+	if stats := d.Stats(); stats.Drawn != 2 {
+		t.Fatalf("expected 2 drawn instances, got %d", stats.Drawn)
+	}
+}
+
+func TestSoftwareRendererBlending(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	d := NewDrawings()
+	mesh := NewMeshQuad(&caches.meshes)
+	softwareTestQuad(r, &d, shader, mesh, matrix.Vec3{0, 0, 0}, matrix.ColorRed())
+	softwareTestQuad(r, &d, shader, mesh, matrix.Vec3{0, 0, 0.5}, matrix.Color{0, 0, 1, 0.5})
+	softwareTestRender(r, &d, caches)
+	softwareTestColor(t, r, 32, 32, matrix.Color{0.5, 0, 0.5, 1})
+	bg := r.ClearColor
+	softwareTestColor(t, r, 32, 15, matrix.Color{bg.R() * 0.5, bg.G() * 0.5, bg.B()*0.5 + 0.5, 1})
+}
+
+// TestSoftwareRendererCreatesWhileDefinitionsLoad creates shaders, which
+// looks through the definitions, while other definitions are loaded. Run
+// with -race to see the two race without the definition lock
+func TestSoftwareRendererCreatesWhileDefinitionsLoad(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+	const count = 32
+	for i := range count {
+		file := filepath.Join("content", fmt.Sprintf("definition_%d.json", i))
+		os.MkdirAll(filepath.Dir(file), os.ModePerm)
+		def := fmt.Sprintf(`{"Vulkan": {"Vert": "%d.vert", "Frag": "%d.frag"}}`, i, i)
+		if err := os.WriteFile(file, []byte(def), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded := make(chan error)
+	go func() {
+		for i := range count {
+			if _, err := caches.shaders.ShaderDefinition(fmt.Sprintf("definition_%d.json", i)); err != nil {
+				loaded <- err
+				return
+			}
+		}
+		loaded <- nil
+	}()
+	for range count {
+		if err := r.CreateShader(shader, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+}
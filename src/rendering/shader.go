@@ -38,10 +38,15 @@
 package rendering
 
 import (
+	"errors"
 	"kaiju/assets"
 	"strings"
 )
 
+// ErrVertexInputLimit is returned when a shader has more vertex inputs than
+// the device can feed it, it can't be drawn on that device
+var ErrVertexInputLimit = errors.New("the shader has more vertex inputs than the device supports")
+
 type Shader struct {
 	RenderId   ShaderId
 	SubShader  *Shader
@@ -52,6 +57,13 @@ type Shader struct {
 	CtrlPath   string
 	EvalPath   string
 	DriverData ShaderDriverData
+	// FrustumCulling skips instances outside of the main camera view, it is
+	// only valid for shaders that place the mesh using the model matrix
+	FrustumCulling bool
+	// CastShadows draws the instances into shadow maps, like FrustumCulling
+	// it is only valid for shaders that place the mesh using the model
+	// matrix
+	CastShadows bool
 }
 
 func createShaderKey(vertPath string, fragPath string, geomPath string, ctrlPath string, evalPath string) string {
@@ -72,11 +84,14 @@ func NewShader(vertPath string, fragPath string, geomPath string, ctrlPath strin
 	return s
 }
 
-func (s *Shader) DelayedCreate(renderer Renderer, assetDatabase *assets.Database) {
-	renderer.CreateShader(s, assetDatabase)
+func (s *Shader) DelayedCreate(renderer Renderer, assetDatabase *assets.Database) error {
+	if err := renderer.CreateShader(s, assetDatabase); err != nil {
+		return err
+	}
 	if s.SubShader != nil {
-		renderer.CreateShader(s.SubShader, assetDatabase)
+		return renderer.CreateShader(s.SubShader, assetDatabase)
 	}
+	return nil
 }
 
 func (s *Shader) IsComposite() bool {
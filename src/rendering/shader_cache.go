@@ -39,6 +39,8 @@ package rendering
 
 import (
 	"kaiju/assets"
+	"log"
+	"maps"
 	"sync"
 )
 
@@ -47,8 +49,15 @@ type ShaderCache struct {
 	assetDatabase     *assets.Database
 	shaders           map[string]*Shader
 	pendingShaders    []*Shader
+	failedShaders     map[string]error
 	shaderDefinitions map[string]ShaderDef
 	mutex             sync.Mutex
+	// definitionMutex guards the definitions, they are read by renderers
+	// creating shaders while the cache mutex is held by CreatePending
+	definitionMutex sync.RWMutex
+	// watch is set while the sources of the shaders are watched for
+	// changes, see WatchForChanges
+	watch *shaderWatch
 }
 
 func NewShaderCache(renderer Renderer, assetDatabase *assets.Database) ShaderCache {
@@ -57,6 +66,7 @@ func NewShaderCache(renderer Renderer, assetDatabase *assets.Database) ShaderCac
 		assetDatabase:     assetDatabase,
 		shaders:           make(map[string]*Shader),
 		pendingShaders:    make([]*Shader, 0),
+		failedShaders:     make(map[string]error),
 		shaderDefinitions: make(map[string]ShaderDef),
 		mutex:             sync.Mutex{},
 	}
@@ -78,24 +88,54 @@ func (s *ShaderCache) Shader(vertPath string, fragPath string, geomPath string,
 	}
 }
 
-func (s *ShaderCache) ShaderFromDefinition(definitionKey string) *Shader {
+// ShaderDefinition loads the shader definition with the given asset key, or
+// returns it if it was already loaded
+func (s *ShaderCache) ShaderDefinition(definitionKey string) (ShaderDef, error) {
+	s.definitionMutex.RLock()
 	def, ok := s.shaderDefinitions[definitionKey]
-	if !ok {
-		if str, err := s.assetDatabase.ReadText(definitionKey); err != nil {
-			// TODO:  Return error and fallback shader
-			panic(err)
-		} else {
-			if def, err = ShaderDefFromJson(str); err != nil {
-				// TODO:  Return error and fallback shader
-				panic(err)
-			} else {
-				s.shaderDefinitions[definitionKey] = def
-			}
+	s.definitionMutex.RUnlock()
+	if ok {
+		return def, nil
+	}
+	str, err := s.assetDatabase.ReadText(definitionKey)
+	if err != nil {
+		return ShaderDef{}, err
+	}
+	def, err = ShaderDefFromJson(str)
+	if err != nil {
+		return ShaderDef{}, err
+	}
+	s.definitionMutex.Lock()
+	s.shaderDefinitions[definitionKey] = def
+	s.definitionMutex.Unlock()
+	return def, nil
+}
+
+// definitionOf finds the loaded definition the shader was created from
+// along with its asset key
+func (s *ShaderCache) definitionOf(shader *Shader) (string, ShaderDef, bool) {
+	s.definitionMutex.RLock()
+	defer s.definitionMutex.RUnlock()
+	for key, def := range s.shaderDefinitions {
+		v := def.Vulkan
+		if createShaderKey(v.Vert, v.Frag, v.Geom, v.Tesc, v.Tese) == shader.KeyName {
+			return key, def, true
 		}
 	}
+	return "", ShaderDef{}, false
+}
+
+func (s *ShaderCache) ShaderFromDefinition(definitionKey string) *Shader {
+	def, err := s.ShaderDefinition(definitionKey)
+	if err != nil {
+		// TODO:  Return error and fallback shader
+		panic(err)
+	}
 	shader := s.Shader(def.Vulkan.Vert, def.Vulkan.Frag,
 		def.Vulkan.Geom, def.Vulkan.Tesc, def.Vulkan.Tese)
 	shader.DriverData.setup(def, baseVertexAttributeCount)
+	shader.FrustumCulling = def.FrustumCulling
+	shader.CastShadows = def.CastShadows
 	return shader
 }
 
@@ -103,9 +143,24 @@ func (s *ShaderCache) CreatePending() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	for _, shader := range s.pendingShaders {
-		shader.DelayedCreate(s.renderer, s.assetDatabase)
+		if err := shader.DelayedCreate(s.renderer, s.assetDatabase); err != nil {
+			log.Printf("failed to create shader %s, it will not be drawn: %v",
+				shader.KeyName, err)
+			s.failedShaders[shader.KeyName] = err
+		}
 	}
 	s.pendingShaders = s.pendingShaders[:0]
+	if s.watch != nil {
+		s.checkForChanges()
+	}
+}
+
+// FailedShaders returns the shaders the renderer could not create, keyed by
+// their name, drawings using them are skipped
+func (s *ShaderCache) FailedShaders() map[string]error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return maps.Clone(s.failedShaders)
 }
 
 func (s *ShaderCache) Destroy() {
@@ -151,12 +151,14 @@ func (l ShaderDefLayout) DescriptorFlags() vk.ShaderStageFlagBits {
 }
 
 type ShaderDef struct {
-	CullMode string
-	DrawMode string
-	OpenGL   ShaderDefDriver
-	Vulkan   ShaderDefDriver
-	Fields   []ShaderDefField
-	Layouts  []ShaderDefLayout
+	CullMode       string
+	DrawMode       string
+	FrustumCulling bool
+	CastShadows    bool
+	OpenGL         ShaderDefDriver
+	Vulkan         ShaderDefDriver
+	Fields         []ShaderDefField
+	Layouts        []ShaderDefLayout
 }
 
 const floatSize = int(unsafe.Sizeof(matrix.Float(0.0)))
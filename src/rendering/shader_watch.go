@@ -0,0 +1,331 @@
+/*****************************************************************************/
+/* shader_watch.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ShaderCompiler is the program that changed GLSL sources are compiled to
+// SPIR-V with, the same one content/shaders/build_spv.sh uses
+var ShaderCompiler = "glslc"
+
+const shaderWatchInterval = time.Second
+
+type shaderWatch struct {
+	report    func(message string)
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+	compiling map[string]bool
+	compiled  chan shaderCompile
+}
+
+// shaderCompile is the result of compiling a GLSL source on another
+// goroutine, source is the asset key of the GLSL file
+type shaderCompile struct {
+	source string
+	output string
+	err    error
+}
+
+// shaderSource is the GLSL source that the SPIR-V asset key is compiled
+// from, OIT variants of fragment shaders share the source of the shader
+func shaderSource(spvKey string) (string, bool) {
+	dir, file := path.Split(spvKey)
+	if path.Base(dir) != "spv" || !strings.HasSuffix(file, ".spv") {
+		return "", false
+	}
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".spv"), ".oit")
+	return path.Join(path.Dir(path.Clean(dir)), file), true
+}
+
+func shaderSpv(source, suffix string) string {
+	dir, file := path.Split(source)
+	return path.Join(dir, "spv", file+suffix)
+}
+
+func (s *Shader) stagePaths() []string {
+	paths := make([]string, 0, 5)
+	for _, p := range []string{s.VertPath, s.FragPath, s.GeomPath, s.CtrlPath, s.EvalPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func (s *Shader) usesSource(source string) bool {
+	for _, p := range s.stagePaths() {
+		if src, ok := shaderSource(p); ok && src == source {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchForChanges makes CreatePending check the GLSL sources, the files
+// they include and the definitions of the cached shaders for changes about
+// once a second. Changed sources are compiled to SPIR-V on another
+// goroutine and the shaders that use them are created again in place, so
+// the drawings that use them keep drawing with the new pipelines. Reloads
+// and compile errors are given to report
+func (s *ShaderCache) WatchForChanges(report func(message string)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.watch != nil {
+		s.watch.report = report
+		return
+	}
+	s.watch = &shaderWatch{
+		report:    report,
+		modTimes:  make(map[string]time.Time),
+		compiling: make(map[string]bool),
+		compiled:  make(chan shaderCompile, 16),
+	}
+}
+
+func (s *ShaderCache) StopWatching() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.watch = nil
+}
+
+func (s *ShaderCache) IsWatching() bool { return s.watch != nil }
+
+// changed is true when the file was modified after the last time it was
+// checked, files seen for the first time are not changed
+func (w *shaderWatch) changed(file string) bool {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	last, ok := w.modTimes[file]
+	w.modTimes[file] = stat.ModTime()
+	return ok && stat.ModTime().After(last)
+}
+
+func (w *shaderWatch) write(format string, args ...any) {
+	if w.report != nil {
+		w.report(fmt.Sprintf(format, args...))
+	}
+}
+
+// checkForChanges is called from CreatePending while the cache is locked
+func (s *ShaderCache) checkForChanges() {
+	w := s.watch
+	s.finishCompiles()
+	if time.Since(w.lastCheck) < shaderWatchInterval {
+		return
+	}
+	w.lastCheck = time.Now()
+	s.definitionMutex.RLock()
+	keys := make([]string, 0, len(s.shaderDefinitions))
+	for key := range s.shaderDefinitions {
+		keys = append(keys, key)
+	}
+	s.definitionMutex.RUnlock()
+	for _, key := range keys {
+		if w.changed(s.assetDatabase.Path(key)) {
+			s.reloadDefinition(key)
+		}
+	}
+	sources := map[string]bool{}
+	for _, shader := range s.shaders {
+		for _, p := range shader.stagePaths() {
+			if src, ok := shaderSource(p); ok {
+				sources[src] = true
+			}
+		}
+	}
+	for src := range sources {
+		if w.compiling[src] {
+			continue
+		}
+		// Every file is checked so the time of each is kept up to date
+		changed := w.changed(s.assetDatabase.Path(src))
+		for _, inc := range s.shaderIncludes(src) {
+			changed = w.changed(s.assetDatabase.Path(inc)) || changed
+		}
+		if changed {
+			w.compiling[src] = true
+			go s.compile(src, w.compiled)
+		}
+	}
+}
+
+// shaderIncludes are the files the GLSL source includes, along with the
+// files they include. The paths are relative to the including file like
+// glslc looks for them
+func (s *ShaderCache) shaderIncludes(source string) []string {
+	includes := []string{}
+	seen := map[string]bool{source: true}
+	for pending := []string{source}; len(pending) > 0; {
+		file := pending[0]
+		pending = pending[1:]
+		src, err := s.assetDatabase.ReadText(file)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(src, "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "#include") {
+				continue
+			}
+			name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "#include")), `"<>`)
+			inc := path.Join(path.Dir(file), name)
+			if name != "" && !seen[inc] {
+				seen[inc] = true
+				includes = append(includes, inc)
+				pending = append(pending, inc)
+			}
+		}
+	}
+	return includes
+}
+
+// compile follows build_spv.sh, fragment shaders with an OIT block are
+// also compiled a second time with OIT defined
+func (s *ShaderCache) compile(source string, done chan<- shaderCompile) {
+	file := s.assetDatabase.Path(source)
+	run := func(spv string, args ...string) (string, error) {
+		args = append([]string{file, "-o", s.assetDatabase.Path(spv)}, args...)
+		out, err := exec.Command(ShaderCompiler, args...).CombinedOutput()
+		return string(out), err
+	}
+	out, err := run(shaderSpv(source, ".spv"))
+	if err == nil && strings.HasSuffix(source, ".frag") {
+		if src, readErr := os.ReadFile(file); readErr == nil &&
+			(strings.HasPrefix(string(src), "#ifdef OIT") || strings.Contains(string(src), "\n#ifdef OIT")) {
+			out, err = run(shaderSpv(source, ".oit.spv"), "-DOIT")
+		}
+	}
+	done <- shaderCompile{source: source, output: out, err: err}
+}
+
+func (s *ShaderCache) finishCompiles() {
+	w := s.watch
+	for {
+		select {
+		case c := <-w.compiled:
+			delete(w.compiling, c.source)
+			if c.err != nil {
+				w.write("Failed to compile %s: %v\n%s", c.source, c.err, strings.TrimSpace(c.output))
+				continue
+			}
+			for _, shader := range s.shaders {
+				if shader.usesSource(c.source) {
+					s.reloadShader(shader)
+				}
+			}
+		default:
+			return
+		}
+	}
+}
+
+// reloadDefinition reads the definition again and creates the shader made
+// from it with the new settings. The fields and layouts can not change as
+// the instance data and descriptor sets of the drawings are built on them
+func (s *ShaderCache) reloadDefinition(key string) {
+	w := s.watch
+	str, err := s.assetDatabase.ReadText(key)
+	if err != nil {
+		w.write("Failed to read %s: %v", key, err)
+		return
+	}
+	def, err := ShaderDefFromJson(str)
+	if err != nil {
+		w.write("Failed to parse %s: %v", key, err)
+		return
+	}
+	s.definitionMutex.RLock()
+	old := s.shaderDefinitions[key]
+	s.definitionMutex.RUnlock()
+	if !reflect.DeepEqual(old.Fields, def.Fields) || !reflect.DeepEqual(old.Layouts, def.Layouts) {
+		w.write("The fields or layouts of %s changed, restart to use them", key)
+		return
+	}
+	ov, nv := old.Vulkan, def.Vulkan
+	oldKey := createShaderKey(ov.Vert, ov.Frag, ov.Geom, ov.Tesc, ov.Tese)
+	newKey := createShaderKey(nv.Vert, nv.Frag, nv.Geom, nv.Tesc, nv.Tese)
+	shader, ok := s.shaders[oldKey]
+	if _, taken := s.shaders[newKey]; newKey != oldKey && taken {
+		w.write("The shaders of %s are already used by another definition", key)
+		return
+	}
+	s.definitionMutex.Lock()
+	s.shaderDefinitions[key] = def
+	s.definitionMutex.Unlock()
+	if !ok {
+		return
+	}
+	delete(s.shaders, oldKey)
+	delete(s.failedShaders, oldKey)
+	s.shaders[newKey] = shader
+	shader.KeyName = newKey
+	shader.VertPath, shader.FragPath = nv.Vert, nv.Frag
+	shader.GeomPath, shader.CtrlPath, shader.EvalPath = nv.Geom, nv.Tesc, nv.Tese
+	shader.DriverData.setup(def, baseVertexAttributeCount)
+	shader.FrustumCulling = def.FrustumCulling
+	shader.CastShadows = def.CastShadows
+	s.reloadShader(shader)
+}
+
+// reloadShader has the renderer create the pipelines of the shader again
+// on the same Shader, so the ShaderDraws holding it pick them up
+func (s *ShaderCache) reloadShader(shader *Shader) {
+	for _, p := range shader.stagePaths() {
+		if !s.assetDatabase.Exists(p) {
+			s.watch.write("Failed to reload %s, %s does not exist", shader.KeyName, p)
+			return
+		}
+	}
+	if err := s.renderer.ReloadShader(shader, s.assetDatabase); err != nil {
+		s.failedShaders[shader.KeyName] = err
+		s.watch.write("Failed to reload %s, %v", shader.KeyName, err)
+		return
+	}
+	delete(s.failedShaders, shader.KeyName)
+	s.watch.write("Reloaded %s", shader.KeyName)
+}
@@ -0,0 +1,118 @@
+//go:build !js && !OPENGL
+
+/*****************************************************************************/
+/* shader_watch.vk_test.go                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/assets"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	vk "github.com/KaijuEngine/go-vulkan"
+)
+
+// vulkanWatchTestSetup creates a headless Vulkan renderer with caches that
+// load from the content folder, it skips when there is no Vulkan driver
+func vulkanWatchTestSetup(t *testing.T) (*Vulkan, *ShaderCache) {
+	t.Helper()
+	wd, _ := os.Getwd()
+	for dir := wd; filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		if s, err := os.Stat(filepath.Join(dir, "content")); err == nil && s.IsDir() {
+			os.Chdir(dir)
+			break
+		}
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	vr, err := NewVKRendererHeadless(64, 64, "Shader watch test")
+	if err != nil {
+		t.Skipf("no Vulkan driver to render with: %v", err)
+	}
+	db := assets.NewDatabase()
+	caches := &softwareTestCaches{
+		shaders:  NewShaderCache(vr, &db),
+		textures: NewTextureCache(vr, &db),
+		meshes:   NewMeshCache(vr, &db),
+	}
+	if err := vr.Initialize(caches, 64, 64); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		caches.shaders.Destroy()
+		caches.textures.Destroy()
+		caches.meshes.Destroy()
+		vr.Destroy()
+	})
+	return vr, &caches.shaders
+}
+
+func TestShaderWatchReloadKeepsVulkanLayout(t *testing.T) {
+	_, s := vulkanWatchTestSetup(t)
+	shader := s.ShaderFromDefinition(assets.ShaderDefinitionBasic)
+	s.CreatePending()
+	if shader.RenderId.graphicsPipeline == vk.Pipeline(vk.NullHandle) || shader.SubShader == nil {
+		t.Fatal("expected the shader and its OIT sub shader to be created")
+	}
+	layout := shader.RenderId.descriptorSetLayout
+	subLayout := shader.SubShader.RenderId.descriptorSetLayout
+	messages := []string{}
+	s.WatchForChanges(func(message string) { messages = append(messages, message) })
+	s.failedShaders[shader.KeyName] = ErrVertexInputLimit
+	s.reloadShader(shader)
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "Reloaded") {
+		t.Fatalf("expected the shader to be reloaded, got %q", messages)
+	}
+	// The descriptor sets of the drawings were allocated from the layouts,
+	// they have to outlive the reload
+	if shader.RenderId.descriptorSetLayout != layout ||
+		shader.SubShader.RenderId.descriptorSetLayout != subLayout {
+		t.Fatal("expected the reload to keep the descriptor set layouts")
+	}
+	for _, id := range []*ShaderId{&shader.RenderId, &shader.SubShader.RenderId} {
+		if id.graphicsPipeline == vk.Pipeline(vk.NullHandle) ||
+			id.vertModule == vk.ShaderModule(vk.NullHandle) ||
+			id.fragModule == vk.ShaderModule(vk.NullHandle) {
+			t.Fatal("expected the reload to create the modules and pipelines again")
+		}
+	}
+	if _, failed := s.FailedShaders()[shader.KeyName]; failed {
+		t.Fatal("expected the reloaded shader to no longer be failed")
+	}
+}
@@ -0,0 +1,166 @@
+/*****************************************************************************/
+/* shader_watch_test.go                                                      */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"errors"
+	"fmt"
+	"kaiju/assets"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const shaderWatchTestDef = `{
+	"CullMode": "%s",
+	"Vulkan": {
+		"Vert": "shaders/spv/basic.vert.spv",
+		"Frag": "shaders/spv/basic.frag.spv"
+	},
+	"Fields": [{"Name": "model", "Type": "mat4"}, {"Name": "%s", "Type": "vec4"}]
+}`
+
+func TestShaderSource(t *testing.T) {
+	for spv, expected := range map[string]string{
+		"shaders/spv/basic.vert.spv":     "shaders/basic.vert",
+		"shaders/spv/basic.frag.oit.spv": "shaders/basic.frag",
+	} {
+		if src, ok := shaderSource(spv); !ok || src != expected {
+			t.Fatalf("expected %s to be compiled from %s, got %s", spv, expected, src)
+		}
+	}
+	if _, ok := shaderSource("shaders/basic.vert"); ok {
+		t.Fatal("expected a GLSL file to have no source")
+	}
+	if spv := shaderSpv("shaders/basic.frag", ".oit.spv"); spv != "shaders/spv/basic.frag.oit.spv" {
+		t.Fatalf("unexpected SPIR-V path %s", spv)
+	}
+}
+
+var shaderWatchTestWrites int
+
+func shaderWatchTestWrite(t *testing.T, file, content string) {
+	t.Helper()
+	file = filepath.Join("content", file)
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	// Move the time forward so the change is seen on file systems with a
+	// coarse modification time
+	shaderWatchTestWrites++
+	future := time.Now().Add(time.Duration(shaderWatchTestWrites) * time.Second)
+	os.Chtimes(file, future, future)
+}
+
+// shaderWatchTestCheck runs CreatePending until a message is reported
+func shaderWatchTestCheck(t *testing.T, s *ShaderCache, messages *[]string) string {
+	t.Helper()
+	*messages = (*messages)[:0]
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		s.watch.lastCheck = time.Time{}
+		s.CreatePending()
+		if len(*messages) > 0 {
+			return (*messages)[0]
+		}
+	}
+	t.Fatal("expected a message from watching the shaders")
+	return ""
+}
+
+func TestShaderWatch(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+	compiler := ShaderCompiler
+	ShaderCompiler = "kaiju-missing-shader-compiler"
+	defer func() { ShaderCompiler = compiler }()
+	shaderWatchTestWrite(t, assets.ShaderDefinitionBasic,
+		fmt.Sprintf(shaderWatchTestDef, "Back", "color"))
+	for _, f := range []string{"basic.vert", "basic.frag", "spv/basic.vert.spv", "spv/basic.frag.spv"} {
+		shaderWatchTestWrite(t, "shaders/"+f, "")
+	}
+	db := assets.NewDatabase()
+	r := NewSoftwareRenderer(8, 8)
+	caches := &softwareTestCaches{shaders: NewShaderCache(r, &db), meshes: NewMeshCache(r, &db)}
+	r.Initialize(caches, 8, 8)
+	s := &caches.shaders
+	shader := s.ShaderFromDefinition(assets.ShaderDefinitionBasic)
+	messages := []string{}
+	s.WatchForChanges(func(message string) { messages = append(messages, message) })
+	s.CreatePending()
+	if r.shaders[shader].cullMode != MeshCullModeBack {
+		t.Fatal("expected the shader to cull back faces")
+	}
+	// The compiler can not be found, which is reported without crashing
+	shaderWatchTestWrite(t, "shaders/basic.frag", "void main() {}")
+	if msg := shaderWatchTestCheck(t, s, &messages); !strings.HasPrefix(msg, "Failed to compile shaders/basic.frag") {
+		t.Fatalf("expected the compile error to be reported, got %q", msg)
+	}
+	// Changing a file the source includes compiles the source again
+	shaderWatchTestWrite(t, "shaders/common.glsl", "")
+	shaderWatchTestWrite(t, "shaders/basic.frag", "#include \"common.glsl\"\nvoid main() {}")
+	if msg := shaderWatchTestCheck(t, s, &messages); !strings.HasPrefix(msg, "Failed to compile shaders/basic.frag") {
+		t.Fatalf("expected the compile error to be reported, got %q", msg)
+	}
+	shaderWatchTestWrite(t, "shaders/common.glsl", "const float scale = 2.0;")
+	if msg := shaderWatchTestCheck(t, s, &messages); !strings.HasPrefix(msg, "Failed to compile shaders/basic.frag") {
+		t.Fatalf("expected the include to compile the source again, got %q", msg)
+	}
+	s.failedShaders[shader.KeyName] = errors.New("failed before the reload")
+	shaderWatchTestWrite(t, assets.ShaderDefinitionBasic,
+		fmt.Sprintf(shaderWatchTestDef, "None", "color"))
+	if msg := shaderWatchTestCheck(t, s, &messages); !strings.HasPrefix(msg, "Reloaded") {
+		t.Fatalf("expected the shader to be reloaded, got %q", msg)
+	}
+	if r.shaders[shader].cullMode != MeshCullModeNone {
+		t.Fatal("expected the reloaded shader to cull nothing")
+	}
+	if _, failed := s.FailedShaders()[shader.KeyName]; failed {
+		t.Fatal("expected the reloaded shader to no longer be failed")
+	}
+	shaderWatchTestWrite(t, assets.ShaderDefinitionBasic,
+		fmt.Sprintf(shaderWatchTestDef, "None", "tint"))
+	if msg := shaderWatchTestCheck(t, s, &messages); !strings.Contains(msg, "restart") {
+		t.Fatalf("expected a restart to be asked for, got %q", msg)
+	}
+}
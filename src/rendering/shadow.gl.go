@@ -0,0 +1,210 @@
+//go:build OPENGL
+
+/*****************************************************************************/
+/* shadow.gl.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+
+package rendering
+
+import (
+	"errors"
+	"kaiju/gl"
+	"log"
+	"unsafe"
+)
+
+// glShadowMaps are the depth textures the shadow passes of a frame are
+// drawn into, every pass draws into the layer of its index. Groups that do
+// not receive shadows sample the empty maps, where nothing blocks the light
+type glShadowMaps struct {
+	frameBuffer gl.Handle
+	depth       gl.Texture
+	empty       gl.Texture
+	resolution  int
+	passes      []ShadowPass
+}
+
+// createShadowMapTexture makes an array texture with a layer of depth for
+// every shadow map, the depth is read with texelFetch so it isn't filtered
+func createShadowMapTexture(texture *gl.Texture, size int32, pixels unsafe.Pointer) {
+	gl.GenTextures(1, texture)
+	gl.BindTexture(gl.Texture2DArray, *texture)
+	gl.TexImage3D(gl.Texture2DArray, 0, gl.DepthComponent32F, size, size, MaxShadowMaps,
+		0, gl.DepthComponent, gl.Float, pixels)
+	gl.TexParameteri(gl.Texture2DArray, gl.TextureMinFilter, gl.Nearest)
+	gl.TexParameteri(gl.Texture2DArray, gl.TextureMagFilter, gl.Nearest)
+	gl.TexParameteri(gl.Texture2DArray, gl.TextureWrapS, gl.ClampToEdge)
+	gl.TexParameteri(gl.Texture2DArray, gl.TextureWrapT, gl.ClampToEdge)
+	gl.UnBindTexture(gl.Texture2DArray)
+}
+
+// create makes the frame buffer, the empty maps and shadow maps of a
+// single texel that grow to the resolution of the lights once they are used
+func (s *glShadowMaps) create() error {
+	far := [MaxShadowMaps]float32{}
+	for i := range far {
+		far[i] = 1
+	}
+	createShadowMapTexture(&s.empty, 1, unsafe.Pointer(&far[0]))
+	gl.GenFrameBuffers(1, &s.frameBuffer)
+	return s.createMaps(1)
+}
+
+func (s *glShadowMaps) createMaps(resolution int) error {
+	createShadowMapTexture(&s.depth, int32(resolution), nil)
+	s.resolution = resolution
+	gl.BindFrameBuffer(gl.FrameBuffer, s.frameBuffer)
+	gl.FrameBufferTextureLayer(gl.FrameBuffer, gl.DepthAttachment, s.depth, 0, 0)
+	defer gl.UnBindFrameBuffer(gl.FrameBuffer)
+	if !gl.CheckFrameBufferStatus(gl.FrameBuffer).Equal(gl.FrameBufferComplete) {
+		return errors.New("the shadow map frame buffer is not complete")
+	}
+	return nil
+}
+
+// readyShadowMaps keeps the passes of the frame and grows the shadow maps
+// when a light wants a larger resolution than they have
+func (r *GLRenderer) readyShadowMaps(lights *Lights) {
+	s := &r.shadowMaps
+	s.passes = s.passes[:0]
+	if lights == nil {
+		return
+	}
+	s.passes = lights.ShadowPasses()
+	resolution := 0
+	for i := range s.passes {
+		resolution = max(resolution, s.passes[i].Resolution)
+	}
+	if resolution <= s.resolution {
+		return
+	}
+	gl.DeleteTextures(1, &s.depth)
+	if err := s.createMaps(resolution); err != nil {
+		log.Printf("failed to create the shadow maps at a resolution of %d: %v", resolution, err)
+	}
+}
+
+// drawShadowMaps draws the depth of the solid groups that cast shadows
+// into a layer of the shadow maps for each of the shadow passes. Culled
+// instances are drawn too, things out of view still cast shadows into it
+func (r *GLRenderer) drawShadowMaps(drawings []ShaderDraw) {
+	s := &r.shadowMaps
+	if len(s.passes) == 0 {
+		return
+	}
+	for i := range drawings {
+		for j := range drawings[i].instanceGroups {
+			if group := &drawings[i].instanceGroups[j]; castsShadows(drawings[i].shader, group) {
+				group.UpdateData(r)
+			}
+		}
+	}
+	gl.Enable(gl.DepthTest)
+	gl.DepthFunc(gl.Less)
+	gl.DepthMask(true)
+	gl.Disable(gl.Blend)
+	gl.BindFrameBuffer(gl.FrameBuffer, s.frameBuffer)
+	view, projection := r.globalShaderData.View, r.globalShaderData.Projection
+	for _, pass := range s.passes {
+		gl.FrameBufferTextureLayer(gl.FrameBuffer, gl.DepthAttachment, s.depth, 0, int32(pass.Index))
+		gl.Viewport(0, 0, int32(pass.Resolution), int32(pass.Resolution))
+		gl.Clear(gl.DepthBufferBit)
+		// The vertex stages of the shaders are drawn as they are, only what
+		// they look through is changed
+		r.globalShaderData.View, r.globalShaderData.Projection = pass.View, pass.Projection
+		for i := range drawings {
+			r.drawShadowCasters(drawings[i].shader, drawings[i].instanceGroups)
+		}
+	}
+	r.globalShaderData.View, r.globalShaderData.Projection = view, projection
+	gl.UnBindFrameBuffer(gl.FrameBuffer)
+}
+
+func castsShadows(shader *Shader, group *DrawInstanceGroup) bool {
+	return shader.CastShadows && !shader.IsComposite() && !group.IsEmpty() &&
+		group.Mesh.IsReady() && !group.useBlending && !group.noShadowCasting
+}
+
+func (r *GLRenderer) drawShadowCasters(shader *Shader, groups []DrawInstanceGroup) {
+	shaderId, ok := shader.RenderId.(gl.Handle)
+	if !ok || !shaderId.IsValid() {
+		return
+	}
+	gl.UseProgram(shaderId)
+	r.setGlobalUniforms(shader)
+	bindDataTexture(shaderId, "jointSampler", glJointPaletteUnit, r.jointTexture)
+	bindDataTexture(shaderId, "morphSampler", glMorphDeltasUnit, r.morphTexture)
+	// The maps being drawn can't be sampled at the same time
+	bindShadowMaps(shaderId, r.shadowMaps.empty)
+	for i := range groups {
+		group := &groups[i]
+		count := group.VisibleCount() + group.CulledCount()
+		if !castsShadows(shader, group) || count == 0 {
+			continue
+		}
+		meshId := group.Mesh.MeshId.(MeshIdGL)
+		gl.BindVertexArray(meshId.VAO)
+		gl.ActivateTexture(gl.Texture0)
+		gl.BindTexture(gl.Texture2D, group.InstanceDriverData)
+		gl.Uniform1i(gl.GetUniformLocation(shaderId, "instanceSampler"), 0)
+		gl.BindBuffer(gl.ElementArrayBuffer, meshId.EBO)
+		gl.DrawElementsInstanced(gl.Triangles, meshId.indexCount,
+			gl.UnsignedInt, 0, int32(count))
+		gl.UnBindBuffer(gl.ElementArrayBuffer)
+		gl.UnBindTexture(gl.Texture2D)
+		gl.UnBindVertexArray()
+	}
+}
+
+// shadowMapsOf are the maps the group samples, groups that don't receive
+// shadows are given the empty maps
+func (r *GLRenderer) shadowMapsOf(group *DrawInstanceGroup) gl.Texture {
+	if group.noShadowReceiving {
+		return r.shadowMaps.empty
+	}
+	return r.shadowMaps.depth
+}
+
+// bindShadowMaps binds the maps to the shadow map sampler of the program
+// when the program reads them
+func bindShadowMaps(program gl.Handle, maps gl.Texture) {
+	loc := gl.GetUniformLocation(program, "shadowMap")
+	if loc.Equal(-1) {
+		return
+	}
+	gl.ActivateTexture(gl.Handle(int(gl.Texture0) + glShadowMapUnit))
+	gl.BindTexture(gl.Texture2DArray, maps)
+	gl.Uniform1i(loc, glShadowMapUnit)
+}
@@ -0,0 +1,266 @@
+/*****************************************************************************/
+/* shadow.go                                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/cameras"
+	"kaiju/matrix"
+)
+
+const (
+	// MaxShadowCascades is the most cascades the main directional light can
+	// split the view into
+	MaxShadowCascades = 4
+	// MaxShadowMaps is the most shadow maps drawn in a frame, the cascades
+	// of the main directional light are first and every shadowed spot
+	// light takes one of the maps that are left
+	MaxShadowMaps = 8
+	// shadowMapBinding is the binding of the shadow maps in the shader
+	// definitions that receive shadows
+	shadowMapBinding = 4
+)
+
+// ShadowSettings are how a light draws and filters its shadows, they are
+// only used when the light has CastShadows set
+type ShadowSettings struct {
+	// Resolution is the width and height of each shadow map in texels
+	Resolution int
+	// DepthBias is subtracted from the depth of a fragment before it is
+	// compared to the shadow map, it stops surfaces from shadowing
+	// themselves (shadow acne)
+	DepthBias float32
+	// NormalBias moves the fragment along its normal, in world units,
+	// before it is looked up in the shadow map
+	NormalBias float32
+	// PCFRadius is how many texels around the fragment are compared to
+	// soften the edges of shadows, 0 gives hard shadows
+	PCFRadius int
+	// Cascades is how many shadow maps the view is split into for a
+	// directional light, it is ignored for spot lights
+	Cascades int
+	// CascadeSplitLambda blends between splitting the view evenly (0) and
+	// logarithmically (1)
+	CascadeSplitLambda float32
+	// MaxDistance is how far from the camera directional shadows are drawn
+	MaxDistance float32
+}
+
+// ShadowPass is a depth only pass drawn from the point of view of a light,
+// Index is where the matrix of the pass is in the global shader data
+type ShadowPass struct {
+	Light      *Light
+	Index      int
+	View       matrix.Mat4
+	Projection matrix.Mat4
+	Resolution int
+}
+
+func DefaultShadowSettings() ShadowSettings {
+	return ShadowSettings{
+		Resolution:         1024,
+		DepthBias:          0.002,
+		NormalBias:         0.02,
+		PCFRadius:          1,
+		Cascades:           MaxShadowCascades,
+		CascadeSplitLambda: 0.75,
+		MaxDistance:        100,
+	}
+}
+
+func (p ShadowPass) ViewProjection() matrix.Mat4 {
+	return p.View.Multiply(p.Projection)
+}
+
+func (s ShadowSettings) resolution() int {
+	if s.Resolution <= 0 {
+		return DefaultShadowSettings().Resolution
+	}
+	return s.Resolution
+}
+
+func (s ShadowSettings) cascades() int {
+	return min(max(s.Cascades, 1), MaxShadowCascades)
+}
+
+func (s ShadowSettings) shaderData(first, count int) (matrix.Vec4, matrix.Vec4) {
+	return matrix.Vec4{float32(first), float32(count), s.DepthBias, s.NormalBias},
+		matrix.Vec4{float32(max(s.PCFRadius, 0)), 1 / float32(s.resolution()), 0, 0}
+}
+
+// cascadeSplits splits the view between near and far, each split is the
+// view depth at which a cascade ends
+func cascadeSplits(near, far float32, count int, lambda float32) [MaxShadowCascades]float32 {
+	splits := [MaxShadowCascades]float32{}
+	near = max(near, 0.0001)
+	for i := 1; i <= count; i++ {
+		p := float32(i) / float32(count)
+		logSplit := near * matrix.Pow(far/near, p)
+		uniform := near + (far-near)*p
+		splits[i-1] = lambda*logSplit + (1-lambda)*uniform
+	}
+	return splits
+}
+
+// viewCorners finds the 8 world space corners of the part of the camera
+// view between the view depths near and far. The corners are solved from
+// the projection directly so it works for perspective and orthographic
+// cameras without losing precision to the inverse of the projection
+func viewCorners(view, projection matrix.Mat4, near, far float32) [8]matrix.Vec3 {
+	p := projection
+	inv := view
+	inv.Inverse()
+	corner := func(x, y, d float32) matrix.Vec3 {
+		w := -p[11]*d + p[15]
+		return inv.TransformPoint(matrix.Vec3{
+			(x*w + p[8]*d - p[12]) / p[0],
+			(y*w + p[9]*d - p[13]) / p[5],
+			-d,
+		})
+	}
+	corners := [8]matrix.Vec3{}
+	for i := 0; i < 4; i++ {
+		x, y := float32(i&1)*2-1, float32(i>>1)*2-1
+		corners[i] = corner(x, y, near)
+		corners[i+4] = corner(x, y, far)
+	}
+	return corners
+}
+
+func shadowLightRotation(direction matrix.Vec3) matrix.Mat4 {
+	up := matrix.Vec3Up()
+	if matrix.Abs(matrix.Vec3Dot(direction, up)) > 0.99 {
+		up = matrix.Vec3Right()
+	}
+	rotation := matrix.Mat4Identity()
+	rotation.LookAt(matrix.Vec3Zero(), direction, up)
+	return rotation
+}
+
+// cascadePass fits an orthographic projection around the bounding sphere
+// of the slice of the view. The sphere does not change size as the camera
+// turns and its center is snapped to whole texels of the shadow map, this
+// keeps the edges of shadows from crawling as the camera moves
+func cascadePass(rotation matrix.Mat4, corners [8]matrix.Vec3, resolution int, pullBack float32) (matrix.Mat4, matrix.Mat4) {
+	center := matrix.Vec3Zero()
+	for _, c := range corners {
+		center.AddAssign(c)
+	}
+	center = center.Scale(1.0 / 8.0)
+	radius := float32(0)
+	for _, c := range corners {
+		radius = max(radius, c.Subtract(center).Length())
+	}
+	radius = matrix.Ceil(radius*16) / 16
+	texel := radius * 2 / float32(resolution)
+	lc := rotation.TransformPoint(center)
+	lc[matrix.Vx] = matrix.Floor(lc.X()/texel) * texel
+	lc[matrix.Vy] = matrix.Floor(lc.Y()/texel) * texel
+	projection := matrix.Mat4Identity()
+	projection.Orthographic(lc.X()-radius, lc.X()+radius, lc.Y()-radius, lc.Y()+radius,
+		-lc.Z()-radius-pullBack, -lc.Z()+radius)
+	return rotation, projection
+}
+
+// spotPass looks down the cone of the spot light, the projection writes
+// depth from 0 to 1 like the Vulkan projections do
+func spotPass(light *Light) (matrix.Mat4, matrix.Mat4) {
+	pos := light.WorldPosition()
+	dir := light.WorldDirection()
+	up := matrix.Vec3Up()
+	if matrix.Abs(matrix.Vec3Dot(dir, up)) > 0.99 {
+		up = matrix.Vec3Right()
+	}
+	view := matrix.Mat4Identity()
+	view.LookAt(pos, pos.Add(dir), up)
+	near, far := max(light.Range*0.01, 0.01), max(light.Range, 0.02)
+	projection := matrix.Mat4Identity()
+	projection.Perspective(matrix.Deg2Rad(min(light.OuterAngle*2, 170)), 1, near, far)
+	projection[10] = far / (near - far)
+	projection[14] = near * far / (near - far)
+	return view, projection
+}
+
+// shadowPasses creates the passes of the lights that cast shadows, only the
+// first directional light with shadows is given cascades
+func shadowPasses(lights []*Light, data *GlobalShaderData, camera cameras.Camera, passes []ShadowPass) []ShadowPass {
+	passes = passes[:0]
+	data.ShadowCascadeSplits = matrix.Vec4{}
+	cascaded := false
+	for i, light := range lights {
+		idx := int32(i)
+		if !light.CastShadows || len(passes) >= MaxShadowMaps {
+			continue
+		}
+		first := len(passes)
+		settings := light.Shadows
+		res := settings.resolution()
+		switch light.Type {
+		case LightTypeDirectional:
+			if cascaded {
+				continue
+			}
+			cascaded = true
+			count := min(settings.cascades(), MaxShadowMaps-first)
+			near := camera.NearPlane()
+			far := camera.FarPlane()
+			if settings.MaxDistance > 0 {
+				far = min(far, settings.MaxDistance)
+			}
+			splits := cascadeSplits(near, far, count, settings.CascadeSplitLambda)
+			rotation := shadowLightRotation(light.WorldDirection())
+			for c := 0; c < count; c++ {
+				corners := viewCorners(data.View, data.Projection, near, splits[c])
+				view, projection := cascadePass(rotation, corners, res, far)
+				passes = append(passes, ShadowPass{light, len(passes), view, projection, res})
+				data.ShadowCascadeSplits[c] = splits[c]
+				near = splits[c]
+			}
+		case LightTypeSpot:
+			view, projection := spotPass(light)
+			passes = append(passes, ShadowPass{light, len(passes), view, projection, res})
+		default:
+			continue
+		}
+		for _, p := range passes[first:] {
+			data.ShadowMatrices[p.Index] = p.ViewProjection()
+		}
+		data.Lights[idx].Shadow, data.Lights[idx].ShadowFilter =
+			settings.shaderData(first, len(passes)-first)
+	}
+	return passes
+}
@@ -0,0 +1,346 @@
+//go:build !js && !OPENGL
+
+/*****************************************************************************/
+/* shadow.vk.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+
+package rendering
+
+import (
+	"kaiju/matrix"
+	"log"
+	"slices"
+	"unsafe"
+
+	vk "github.com/KaijuEngine/go-vulkan"
+)
+
+// vkShadowMaps are the depth images the shadow passes of a frame are drawn
+// into, every pass draws into the layer of its index. Groups that do not
+// receive shadows sample the empty maps, where nothing blocks the light
+type vkShadowMaps struct {
+	depth        TextureId
+	empty        TextureId
+	layerViews   [MaxShadowMaps]vk.ImageView
+	frameBuffers [MaxShadowMaps]vk.Framebuffer
+	renderPass   vk.RenderPass
+	passes       []ShadowPass
+	// view and projection are the ones of the camera, they are written
+	// back into the global uniform buffer after the shadow passes
+	view       matrix.Mat4
+	projection matrix.Mat4
+}
+
+func (vr *Vulkan) shadowMapFormat() vk.Format {
+	candidates := []vk.Format{vk.FormatD32Sfloat,
+		vk.FormatX8D24UnormPack32, vk.FormatD16Unorm}
+	return vr.findSupportedFormat(candidates, vk.ImageTilingOptimal,
+		vk.FormatFeatureFlags(vk.FormatFeatureDepthStencilAttachmentBit|vk.FormatFeatureSampledImageBit))
+}
+
+func (vr *Vulkan) createShadowView(id *TextureId, viewType vk.ImageViewType, layer, layerCount uint32, view *vk.ImageView) bool {
+	viewInfo := vk.ImageViewCreateInfo{}
+	viewInfo.SType = vk.StructureTypeImageViewCreateInfo
+	viewInfo.Image = id.Image
+	viewInfo.ViewType = viewType
+	viewInfo.Format = id.Format
+	viewInfo.SubresourceRange.AspectMask = vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+	viewInfo.SubresourceRange.LevelCount = 1
+	viewInfo.SubresourceRange.BaseArrayLayer = layer
+	viewInfo.SubresourceRange.LayerCount = layerCount
+	var idView vk.ImageView
+	if vk.CreateImageView(vr.device, &viewInfo, nil, &idView) != vk.Success {
+		log.Printf("%s", "Failed to create shadow map image view")
+		return false
+	}
+	vr.dbg.add(uintptr(unsafe.Pointer(idView)))
+	*view = idView
+	return true
+}
+
+// createShadowImage makes an array image with a layer for every shadow
+// map, the view of the whole array is the one that is sampled
+func (vr *Vulkan) createShadowImage(id *TextureId, size uint32, usage vk.ImageUsageFlagBits) bool {
+	if !vr.CreateImage(size, size, 1, vk.SampleCount1Bit, vr.shadowMapFormat(),
+		vk.ImageTilingOptimal, vk.ImageUsageFlags(usage|vk.ImageUsageSampledBit),
+		vk.MemoryPropertyFlags(vk.MemoryPropertyDeviceLocalBit), id, MaxShadowMaps) {
+		return false
+	}
+	id.LayerCount = MaxShadowMaps
+	return vr.createShadowView(id, vk.ImageViewType2dArray, 0, MaxShadowMaps, &id.View) &&
+		vr.createTextureSampler(&id.Sampler, 1, vk.FilterNearest)
+}
+
+func (s *vkShadowMaps) createRenderPass(vr *Vulkan) bool {
+	depthAttachment := vk.AttachmentDescription{}
+	depthAttachment.Format = vr.shadowMapFormat()
+	depthAttachment.Samples = vk.SampleCount1Bit
+	depthAttachment.LoadOp = vk.AttachmentLoadOpClear
+	depthAttachment.StoreOp = vk.AttachmentStoreOpStore
+	depthAttachment.StencilLoadOp = vk.AttachmentLoadOpDontCare
+	depthAttachment.StencilStoreOp = vk.AttachmentStoreOpDontCare
+	depthAttachment.InitialLayout = vk.ImageLayoutDepthStencilAttachmentOptimal
+	depthAttachment.FinalLayout = vk.ImageLayoutDepthStencilAttachmentOptimal
+
+	depthAttachmentRef := vk.AttachmentReference{}
+	depthAttachmentRef.Attachment = 0
+	depthAttachmentRef.Layout = vk.ImageLayoutDepthStencilAttachmentOptimal
+
+	subpass := vk.SubpassDescription{}
+	subpass.PipelineBindPoint = vk.PipelineBindPointGraphics
+	subpass.PDepthStencilAttachment = &depthAttachmentRef
+
+	renderPassInfo := vk.RenderPassCreateInfo{}
+	renderPassInfo.SType = vk.StructureTypeRenderPassCreateInfo
+	renderPassInfo.AttachmentCount = 1
+	renderPassInfo.PAttachments = []vk.AttachmentDescription{depthAttachment}
+	renderPassInfo.SubpassCount = 1
+	renderPassInfo.PSubpasses = []vk.SubpassDescription{subpass}
+	var renderPass vk.RenderPass
+	if vk.CreateRenderPass(vr.device, &renderPassInfo, nil, &renderPass) != vk.Success {
+		log.Printf("%s", "Failed to create the shadow map render pass")
+		return false
+	}
+	vr.dbg.add(uintptr(unsafe.Pointer(renderPass)))
+	s.renderPass = renderPass
+	return true
+}
+
+// create makes the render pass, the empty maps and shadow maps of a
+// single texel that grow to the resolution of the lights once they are used
+func (s *vkShadowMaps) create(vr *Vulkan) bool {
+	if !s.createRenderPass(vr) || !s.createMaps(vr, 1) ||
+		!vr.createShadowImage(&s.empty, 1, vk.ImageUsageTransferDstBit) {
+		return false
+	}
+	cmd := vr.beginSingleTimeCommands()
+	vr.transitionImageLayout(&s.empty, vk.ImageLayoutTransferDstOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectDepthBit), vk.AccessFlags(vk.AccessTransferWriteBit), cmd)
+	far := vk.ClearDepthStencilValue{Depth: 1}
+	vk.CmdClearDepthStencilImage(cmd, s.empty.Image, vk.ImageLayoutTransferDstOptimal, &far, 1,
+		[]vk.ImageSubresourceRange{{
+			AspectMask: vk.ImageAspectFlags(vk.ImageAspectDepthBit),
+			LevelCount: 1,
+			LayerCount: MaxShadowMaps,
+		}})
+	vr.transitionImageLayout(&s.empty, vk.ImageLayoutShaderReadOnlyOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectDepthBit), vk.AccessFlags(vk.AccessShaderReadBit), cmd)
+	vr.endSingleTimeCommands(cmd)
+	return true
+}
+
+func (s *vkShadowMaps) createMaps(vr *Vulkan, resolution int) bool {
+	if !vr.createShadowImage(&s.depth, uint32(resolution), vk.ImageUsageDepthStencilAttachmentBit) {
+		return false
+	}
+	for i := range s.layerViews {
+		if !vr.createShadowView(&s.depth, vk.ImageViewType2d, uint32(i), 1, &s.layerViews[i]) ||
+			!vr.CreateFrameBuffer(s.renderPass, []vk.ImageView{s.layerViews[i]},
+				uint32(resolution), uint32(resolution), &s.frameBuffers[i]) {
+			return false
+		}
+	}
+	vr.transitionImageLayout(&s.depth, vk.ImageLayoutShaderReadOnlyOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectDepthBit), vk.AccessFlags(vk.AccessShaderReadBit),
+		vk.CommandBuffer(vk.NullHandle))
+	return true
+}
+
+func (s *vkShadowMaps) freeMaps(vr *Vulkan) {
+	for i := range s.frameBuffers {
+		vk.DestroyFramebuffer(vr.device, s.frameBuffers[i], nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(s.frameBuffers[i])))
+		vk.DestroyImageView(vr.device, s.layerViews[i], nil)
+		vr.dbg.remove(uintptr(unsafe.Pointer(s.layerViews[i])))
+	}
+	s.frameBuffers = [MaxShadowMaps]vk.Framebuffer{}
+	s.layerViews = [MaxShadowMaps]vk.ImageView{}
+	vr.textureIdFree(&s.depth)
+	s.depth = TextureId{}
+}
+
+func (s *vkShadowMaps) reset(vr *Vulkan) {
+	s.freeMaps(vr)
+	vr.textureIdFree(&s.empty)
+	s.empty = TextureId{}
+	vk.DestroyRenderPass(vr.device, s.renderPass, nil)
+	vr.dbg.remove(uintptr(unsafe.Pointer(s.renderPass)))
+	s.renderPass = vk.RenderPass(vk.NullHandle)
+}
+
+// readyShadowMaps keeps the passes of the frame and grows the shadow maps
+// when a light wants a larger resolution than they have
+func (vr *Vulkan) readyShadowMaps(data *GlobalShaderData, lights *Lights) {
+	s := &vr.shadowMaps
+	s.view, s.projection = data.View, data.Projection
+	s.passes = s.passes[:0]
+	if lights == nil {
+		return
+	}
+	s.passes = lights.ShadowPasses()
+	resolution := 0
+	for i := range s.passes {
+		resolution = max(resolution, s.passes[i].Resolution)
+	}
+	if resolution <= s.depth.Width {
+		return
+	}
+	vk.DeviceWaitIdle(vr.device)
+	s.freeMaps(vr)
+	if !s.createMaps(vr, resolution) {
+		log.Printf("failed to create the shadow maps at a resolution of %d", resolution)
+	}
+}
+
+// writeGlobalViewProjection replaces the view and projection of the global
+// uniform buffer of the frame, the shadow passes draw with the vertex
+// stages of the shaders as they are and only change what they look through
+func (vr *Vulkan) writeGlobalViewProjection(cmd vk.CommandBuffer, view, projection matrix.Mat4) {
+	data := [2]matrix.Mat4{view, projection}
+	barrier := vk.BufferMemoryBarrier{}
+	barrier.SType = vk.StructureTypeBufferMemoryBarrier
+	barrier.SrcAccessMask = vk.AccessFlags(vk.AccessUniformReadBit)
+	barrier.DstAccessMask = vk.AccessFlags(vk.AccessTransferWriteBit)
+	barrier.SrcQueueFamilyIndex = vk.QueueFamilyIgnored
+	barrier.DstQueueFamilyIndex = vk.QueueFamilyIgnored
+	barrier.Buffer = vr.globalUniformBuffers[vr.currentFrame]
+	barrier.Size = vk.DeviceSize(unsafe.Sizeof(data))
+	uniformStages := vk.PipelineStageFlags(makeAccessMaskPipelineStageFlags(barrier.SrcAccessMask))
+	transferStage := vk.PipelineStageFlags(vk.PipelineStageTransferBit)
+	vk.CmdPipelineBarrier(cmd, uniformStages, transferStage, 0, 0, nil,
+		1, []vk.BufferMemoryBarrier{barrier}, 0, nil)
+	vk.CmdUpdateBuffer(cmd, barrier.Buffer, 0, barrier.Size, (*uint32)(unsafe.Pointer(&data[0])))
+	barrier.SrcAccessMask, barrier.DstAccessMask = barrier.DstAccessMask, barrier.SrcAccessMask
+	vk.CmdPipelineBarrier(cmd, transferStage, uniformStages, 0, 0, nil,
+		1, []vk.BufferMemoryBarrier{barrier}, 0, nil)
+}
+
+// drawShadowMaps draws the depth of the solid groups that cast shadows
+// into a layer of the shadow maps for each of the shadow passes. Culled
+// instances are drawn too, things out of view still cast shadows into it
+func (vr *Vulkan) drawShadowMaps(cmd vk.CommandBuffer, drawings []ShaderDraw) {
+	s := &vr.shadowMaps
+	if len(s.passes) == 0 {
+		return
+	}
+	vr.transitionImageLayout(&s.depth, vk.ImageLayoutDepthStencilAttachmentOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectDepthBit),
+		vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit), cmd)
+	var depthClear [1]vk.ClearValue
+	depthClear[0].SetDepthStencil(1.0, 0.0)
+	for _, pass := range s.passes {
+		vr.writeGlobalViewProjection(cmd, pass.View, pass.Projection)
+		extent := vk.Extent2D{Width: uint32(pass.Resolution), Height: uint32(pass.Resolution)}
+		beginRenderPass(s.renderPass, s.frameBuffers[pass.Index], extent, cmd, depthClear[:])
+		for i := range drawings {
+			vr.renderShadowCasters(cmd, drawings[i].shader, drawings[i].instanceGroups)
+		}
+		vk.CmdEndRenderPass(cmd)
+	}
+	vr.writeGlobalViewProjection(cmd, s.view, s.projection)
+	vr.transitionImageLayout(&s.depth, vk.ImageLayoutShaderReadOnlyOptimal,
+		vk.ImageAspectFlags(vk.ImageAspectDepthBit), vk.AccessFlags(vk.AccessShaderReadBit), cmd)
+}
+
+func (vr *Vulkan) renderShadowCasters(cmd vk.CommandBuffer, shader *Shader, groups []DrawInstanceGroup) {
+	if !shader.CastShadows || shader.IsComposite() || !shader.RenderId.isValid() {
+		return
+	}
+	pipeline := vr.shadowPipeline(shader)
+	if pipeline == vk.Pipeline(vk.NullHandle) {
+		return
+	}
+	vk.CmdBindPipeline(cmd, vk.PipelineBindPointGraphics, pipeline)
+	for i := range groups {
+		group := &groups[i]
+		count := group.VisibleCount() + group.CulledCount()
+		if !group.IsReady() || group.useBlending || group.noShadowCasting || count == 0 {
+			continue
+		}
+		vk.CmdBindDescriptorSets(cmd, vk.PipelineBindPointGraphics,
+			shader.RenderId.pipelineLayout, 0, 1,
+			[]vk.DescriptorSet{group.InstanceDriverData.descriptorSets[vr.currentFrame]}, 0, []uint32{0})
+		meshId := group.Mesh.MeshId
+		vk.CmdBindVertexBuffers(cmd, 0, 1, []vk.Buffer{meshId.vertexBuffer}, []vk.DeviceSize{0})
+		vk.CmdBindVertexBuffers(cmd, 1, 1,
+			[]vk.Buffer{group.instanceBuffers[vr.currentFrame]}, []vk.DeviceSize{0})
+		vk.CmdBindIndexBuffer(cmd, meshId.indexBuffer, 0, vk.IndexTypeUint32)
+		vk.CmdDrawIndexed(cmd, meshId.indexCount, uint32(count), 0, 0, 0)
+	}
+}
+
+// shadowPipeline is the depth only pipeline the shader draws into the
+// shadow maps with. A pipeline that failed keeps its layout, so it is not
+// tried again every frame
+func (vr *Vulkan) shadowPipeline(shader *Shader) vk.Pipeline {
+	id := &shader.RenderId
+	if id.shadowPipelineLayout != vk.PipelineLayout(vk.NullHandle) {
+		return id.shadowPipeline
+	}
+	stages := slices.DeleteFunc(id.stages(), func(s vk.PipelineShaderStageCreateInfo) bool {
+		return s.Stage == vk.ShaderStageFragmentBit
+	})
+	if !vr.createPipeline(shader, stages, len(stages), id.descriptorSetLayout,
+		&id.shadowPipelineLayout, &id.shadowPipeline, vr.shadowMaps.renderPass, false, vk.SampleCount1Bit) {
+		log.Printf("failed to create the shadow map pipeline for %s", shader.KeyName)
+	}
+	return id.shadowPipeline
+}
+
+// usesShadowMaps is true for shaders with the shadow maps in their layout
+func usesShadowMaps(shader *Shader) bool {
+	for _, t := range shader.DriverData.Types {
+		if t.Binding == shadowMapBinding && t.Type == vk.DescriptorTypeCombinedImageSampler {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowMapWrites is the write of the shadow maps for the shaders that
+// sample them, groups that don't receive shadows are given the empty maps
+func (vr *Vulkan) shadowMapWrites(shader *Shader, group *DrawInstanceGroup, set vk.DescriptorSet) []vk.WriteDescriptorSet {
+	if !usesShadowMaps(shader) {
+		return nil
+	}
+	maps := &vr.shadowMaps.depth
+	if group.noShadowReceiving {
+		maps = &vr.shadowMaps.empty
+	}
+	info := imageInfo(maps.View, maps.Sampler)
+	return []vk.WriteDescriptorSet{
+		prepareSetWriteImage(set, []vk.DescriptorImageInfo{info}, shadowMapBinding, false),
+	}
+}
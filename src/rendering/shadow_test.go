@@ -0,0 +1,125 @@
+/*****************************************************************************/
+/* shadow_test.go                                                            */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"kaiju/cameras"
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestCascadeSplits(t *testing.T) {
+	splits := cascadeSplits(0.1, 100, 4, 0.75)
+	last := float32(0.1)
+	for i := 0; i < 4; i++ {
+		if splits[i] <= last {
+			t.Fatalf("expected increasing splits, got %v", splits)
+		}
+		last = splits[i]
+	}
+	if !matrix.Approx(splits[3], 100) {
+		t.Fatalf("expected the last split at the far plane, got %f", splits[3])
+	}
+}
+
+func TestStableCascades(t *testing.T) {
+	sun := NewDirectionalLight(matrix.Vec3{1, -2, -1}, matrix.ColorWhite(), 1)
+	sun.CastShadows = true
+	lights := NewLights()
+	lights.Add(&sun)
+	camera := cameras.NewStandardCamera(160, 90, matrix.Vec3{0, 2, 5})
+	uiCamera := cameras.NewStandardCameraOrthographic(160, 90, matrix.Vec3{0, 0, 250})
+	texel := func() (matrix.Vec2, matrix.Float) {
+		NewGlobalShaderData(camera, uiCamera, &lights, 0)
+		passes := lights.ShadowPasses()
+		if len(passes) != MaxShadowCascades {
+			t.Fatalf("expected %d cascades, got %d", MaxShadowCascades, len(passes))
+		}
+		p := passes[0].ViewProjection().TransformPoint(matrix.Vec3{})
+		res := matrix.Float(passes[0].Resolution)
+		return matrix.Vec2{(p.X()*0.5 + 0.5) * res, (p.Y()*0.5 + 0.5) * res}, passes[0].Projection[0]
+	}
+	before, scale := texel()
+	// Moving the camera moves the map by whole texels
+	camera.SetPosition(matrix.Vec3{0.0137, 2.0041, 4.9})
+	after, _ := texel()
+	for i := 0; i < 2; i++ {
+		d := after[i] - before[i]
+		if matrix.Abs(d-matrix.Floor(d+0.5)) > 0.01 {
+			t.Fatalf("expected the map to move by whole texels, moved %f", d)
+		}
+	}
+	// Turning the camera does not change the size of the cascade
+	camera.SetYaw(37)
+	if _, turned := texel(); !matrix.Approx(turned, scale) {
+		t.Fatalf("expected the cascade size to stay %f, got %f", scale, turned)
+	}
+}
+
+func TestSoftwareShadows(t *testing.T) {
+	r, caches, shader := softwareTestSetup(t)
+	mesh := NewMeshQuad(&caches.meshes)
+	floor := &softwareTestShaderData{NewShaderDataBase(), matrix.ColorWhite()}
+	blocker := &softwareTestShaderData{NewShaderDataBase(), matrix.ColorWhite()}
+	m := matrix.Mat4Identity()
+	m.Translate(matrix.Vec3{-0.5, 0, 0.5})
+	blocker.SetModel(m)
+	lights := NewLights()
+	lights.Ambient = matrix.Color{0.2, 0.2, 0.2, 1}
+	sun := NewDirectionalLight(matrix.Vec3{1, 0, -1}, matrix.ColorWhite(), 1)
+	sun.CastShadows = true
+	lights.Add(&sun)
+	render := func(floorFlags, blockerFlags Drawing) {
+		d := NewDrawings()
+		floorFlags.Renderer, floorFlags.Shader, floorFlags.Mesh, floorFlags.ShaderData = r, shader, mesh, floor
+		blockerFlags.Renderer, blockerFlags.Shader, blockerFlags.Mesh, blockerFlags.ShaderData = r, shader, mesh, blocker
+		d.AddDrawings([]Drawing{floorFlags, blockerFlags})
+		softwareTestLitRender(r, &d, caches, &lights)
+	}
+	// The floor at x 0.25 is seen past the blocker but is in its shadow
+	camera := cameras.NewStandardCamera(64, 64, matrix.Vec3{0, 0, 2})
+	p := camera.Projection().MultiplyVec4(camera.View().MultiplyVec4(matrix.Vec4{0.25, 0, 0, 1}))
+	x, y := int((p.X()/p.W()*0.5+0.5)*64), int((p.Y()/p.W()*0.5+0.5)*64)
+	lit := matrix.Color{0.2 + matrix.Sqrt(0.5), 0.2 + matrix.Sqrt(0.5), 0.2 + matrix.Sqrt(0.5), 1}
+	render(Drawing{}, Drawing{})
+	softwareTestColor(t, r, x, y, lights.Ambient)
+	render(Drawing{NoShadowReceiving: true}, Drawing{})
+	softwareTestColor(t, r, x, y, lit)
+	render(Drawing{}, Drawing{NoShadowCasting: true})
+	softwareTestColor(t, r, x, y, lit)
+}
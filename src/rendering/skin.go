@@ -0,0 +1,137 @@
+/*****************************************************************************/
+/* skin.go                                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package rendering
+
+import (
+	"errors"
+	"kaiju/matrix"
+	"slices"
+	"sync"
+)
+
+const (
+	// MaxSkinJoints is the most joints a single skin can have
+	MaxSkinJoints = 128
+	// MaxJointPalette is the most joint matrices of all skins in a frame,
+	// every skin takes a slice of the palette for as long as it is added
+	MaxJointPalette = 2048
+	// jointPaletteBinding is the binding of the joint palette storage
+	// buffer in shader definitions that skin their vertices
+	jointPaletteBinding = 2
+)
+
+// Skin is the joint palette of a skinned mesh. Joints are the matrices that
+// move the vertices from their bind pose to where the joints are now, in
+// the model space of the mesh. Shaders find the joints of a vertex at
+// Offset plus the joint index of the vertex
+type Skin struct {
+	Joints []matrix.Mat4
+	offset int
+}
+
+// Skins are the skins of a host, like Lights they are gathered into the
+// joint palette of the frame that is given to the renderer
+type Skins struct {
+	skins []*Skin
+	mutex sync.Mutex
+}
+
+func NewSkin(jointCount int) *Skin {
+	s := &Skin{Joints: make([]matrix.Mat4, min(jointCount, MaxSkinJoints))}
+	for i := range s.Joints {
+		s.Joints[i] = matrix.Mat4Identity()
+	}
+	return s
+}
+
+// Offset is where the joints of the skin start in the joint palette, it
+// does not change while the skin is added
+func (s *Skin) Offset() int { return s.offset }
+
+func NewSkins() Skins {
+	return Skins{skins: make([]*Skin, 0)}
+}
+
+// Add finds room for the joints of the skin in the joint palette, it fails
+// when the palette is full
+func (s *Skins) Add(skin *Skin) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if slices.Contains(s.skins, skin) {
+		return nil
+	}
+	offset := 0
+	at := len(s.skins)
+	for i, other := range s.skins {
+		if other.offset-offset >= len(skin.Joints) {
+			at = i
+			break
+		}
+		offset = other.offset + len(other.Joints)
+	}
+	if at == len(s.skins) && offset+len(skin.Joints) > MaxJointPalette {
+		return errors.New("the joint palette is full")
+	}
+	skin.offset = offset
+	s.skins = slices.Insert(s.skins, at, skin)
+	return nil
+}
+
+func (s *Skins) Remove(skin *Skin) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if i := slices.Index(s.skins, skin); i >= 0 {
+		s.skins = slices.Delete(s.skins, i, i+1)
+	}
+}
+
+// fillPalette copies the joints of every skin into the palette, it returns
+// how many matrices of the palette are used
+func (s *Skins) fillPalette(palette []matrix.Mat4) int {
+	if s == nil {
+		return 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	used := 0
+	for _, skin := range s.skins {
+		copy(palette[skin.offset:], skin.Joints)
+		used = skin.offset + len(skin.Joints)
+	}
+	return used
+}
@@ -141,6 +141,8 @@ type Texture struct {
 	Height            int
 	CacheInvalid      bool
 	pendingData       *TextureData
+	// renderTarget is set for textures that sample the color of a target
+	renderTarget RenderTarget
 }
 
 func ReadRawTextureData(mem []byte, inputType TextureFileFormat) TextureData {
@@ -0,0 +1,493 @@
+/*****************************************************************************/
+/* animator.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package animation
+
+import (
+	"errors"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/rendering/loaders"
+	"kaiju/systems/events"
+	"slices"
+)
+
+// Event is raised by the animator when the playback of a clip passes the
+// time of an event added to the clip
+type Event struct {
+	Clip string
+	Name string
+	Time matrix.Float
+}
+
+type clipEvent struct {
+	time matrix.Float
+	name string
+}
+
+// morph is a drawing whose morph target weights are animated
+type morph struct {
+	node     int
+	instance *rendering.MaterialInstance
+	defaults []matrix.Float
+	weights  []matrix.Float
+	sample   []matrix.Float
+}
+
+type track struct {
+	clip     *Clip
+	time     matrix.Float
+	weight   matrix.Float
+	fadeTo   matrix.Float
+	fadeRate matrix.Float
+	loop     bool
+	started  bool
+}
+
+// Animator plays the clips of a skeleton and writes the joint palette of
+// the pose into Skin every update. Clips are played on tracks, the pose is
+// the weighted blend of all the tracks, or the rest pose when no track has
+// any weight
+type Animator struct {
+	// Skin is the joint palette of the skeleton, draw the skinned mesh
+	// with the joint offset of the skin
+	Skin    *rendering.Skin
+	OnEvent events.EventWithArg[Event]
+	// Speed scales the time of every track, 1 is the speed of the clips
+	Speed    matrix.Float
+	skeleton *loaders.ResultSkeleton
+	clips    map[string]*Clip
+	events   map[*Clip][]clipEvent
+	tracks   []*track
+	morphs   []*morph
+	order    []int
+	pose     []JointPose
+	sample   []JointPose
+	world    []matrix.Mat4
+}
+
+// New creates an animator for the skeleton that is not attached to a host,
+// the caller is expected to call Update. Use Attach to have the host update
+// it and upload its joint palette
+func New(skeleton *loaders.ResultSkeleton, animations []loaders.ResultAnimation) *Animator {
+	jointCount := len(skeleton.Joints)
+	a := &Animator{
+		Skin:     rendering.NewSkin(jointCount),
+		OnEvent:  events.NewWithArg[Event](),
+		Speed:    1,
+		skeleton: skeleton,
+		clips:    make(map[string]*Clip, len(animations)),
+		events:   make(map[*Clip][]clipEvent),
+		pose:     make([]JointPose, jointCount),
+		sample:   make([]JointPose, jointCount),
+		world:    make([]matrix.Mat4, jointCount),
+	}
+	for i := range animations {
+		c := newClip(&animations[i], skeleton)
+		a.clips[c.Name] = c
+	}
+	// Parents are posed before their children no matter the order of the
+	// joints in the file
+	posed := make([]bool, jointCount)
+	for len(a.order) < jointCount {
+		added := false
+		for i := range skeleton.Joints {
+			p := skeleton.Joints[i].Parent
+			if !posed[i] && (p < 0 || p >= jointCount || posed[p]) {
+				posed[i] = true
+				a.order = append(a.order, i)
+				added = true
+			}
+		}
+		if !added {
+			// A cycle in the hierarchy, pose the rest as roots
+			for i := range posed {
+				if !posed[i] {
+					posed[i] = true
+					a.order = append(a.order, i)
+				}
+			}
+		}
+	}
+	a.Update(0)
+	return a
+}
+
+// Attach creates an animator that is updated by the host while the entity
+// is active. Its skin is added to the host so the joint palette is given to
+// the renderer, both are removed when the entity is destroyed
+func Attach(host *engine.Host, entity *engine.Entity, skeleton *loaders.ResultSkeleton, animations []loaders.ResultAnimation) (*Animator, error) {
+	a := New(skeleton, animations)
+	// Animators that only drive morph weights have no joints to upload
+	if len(a.Skin.Joints) > 0 {
+		if err := host.Skins.Add(a.Skin); err != nil {
+			return nil, err
+		}
+	}
+	id := host.Updater.AddUpdate(func(deltaTime float64) {
+		if entity.IsActive() {
+			a.Update(deltaTime)
+		}
+	})
+	entity.OnDestroy.Add(func() {
+		host.Updater.RemoveUpdate(id)
+		host.Skins.Remove(a.Skin)
+	})
+	return a, nil
+}
+
+// AddMorph has the weight channels of the clips that target the node drive
+// the morph target weights of the instance, defaults are the weights used
+// while no clip animates them. The instance can be nil to only read the
+// weights with MorphWeights
+func (a *Animator) AddMorph(node int, instance *rendering.MaterialInstance, defaults []matrix.Float) {
+	m := &morph{
+		node:     node,
+		instance: instance,
+		defaults: slices.Clone(defaults),
+		weights:  slices.Clone(defaults),
+		sample:   make([]matrix.Float, len(defaults)),
+	}
+	a.morphs = append(a.morphs, m)
+	a.blendMorph(m, a.totalWeight())
+}
+
+// MorphWeights are the blended morph target weights of the node from the
+// last update, nil when the node was not added with AddMorph
+func (a *Animator) MorphWeights(node int) []matrix.Float {
+	for _, m := range a.morphs {
+		if m.node == node {
+			return m.weights
+		}
+	}
+	return nil
+}
+
+// Clip finds the clip by name, nil when the skeleton has no such clip
+func (a *Animator) Clip(name string) *Clip { return a.clips[name] }
+
+func (a *Animator) findClip(name string) (*Clip, error) {
+	c, ok := a.clips[name]
+	if !ok {
+		return nil, errors.New("the animator has no clip named " + name)
+	}
+	return c, nil
+}
+
+func (a *Animator) findTrack(c *Clip) *track {
+	for _, t := range a.tracks {
+		if t.clip == c {
+			return t
+		}
+	}
+	return nil
+}
+
+// Play stops every other clip and plays the clip from the start at full
+// weight
+func (a *Animator) Play(clip string, loop bool) error {
+	c, err := a.findClip(clip)
+	if err != nil {
+		return err
+	}
+	a.tracks = append(a.tracks[:0], &track{clip: c, weight: 1, fadeTo: 1, loop: loop})
+	return nil
+}
+
+// CrossFade fades the clip in from the start while every other clip fades
+// out over the duration in seconds
+func (a *Animator) CrossFade(clip string, duration matrix.Float, loop bool) error {
+	c, err := a.findClip(clip)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return a.Play(clip, loop)
+	}
+	rate := 1 / duration
+	for _, t := range a.tracks {
+		t.fadeTo, t.fadeRate = 0, rate
+	}
+	t := a.findTrack(c)
+	if t == nil {
+		t = &track{clip: c}
+		a.tracks = append(a.tracks, t)
+	}
+	t.time, t.started, t.loop = 0, false, loop
+	t.fadeTo, t.fadeRate = 1, rate
+	return nil
+}
+
+// Blend plays the clip alongside the other clips at the weight, a clip that
+// is already playing keeps its time and only changes its weight
+func (a *Animator) Blend(clip string, weight matrix.Float, loop bool) error {
+	c, err := a.findClip(clip)
+	if err != nil {
+		return err
+	}
+	t := a.findTrack(c)
+	if t == nil {
+		t = &track{clip: c}
+		a.tracks = append(a.tracks, t)
+	}
+	t.weight, t.fadeTo, t.fadeRate, t.loop = weight, weight, 0, loop
+	return nil
+}
+
+// Stop removes the clip from the blend
+func (a *Animator) Stop(clip string) {
+	a.tracks = slices.DeleteFunc(a.tracks, func(t *track) bool { return t.clip.Name == clip })
+}
+
+// Time is the playback time of the clip, 0 when it is not playing
+func (a *Animator) Time(clip string) matrix.Float {
+	for _, t := range a.tracks {
+		if t.clip.Name == clip {
+			return t.time
+		}
+	}
+	return 0
+}
+
+// Weight is the blend weight of the clip, 0 when it is not playing
+func (a *Animator) Weight(clip string) matrix.Float {
+	for _, t := range a.tracks {
+		if t.clip.Name == clip {
+			return t.weight
+		}
+	}
+	return 0
+}
+
+// IsPlaying is true while the clip is in the blend, clips that do not loop
+// stay in the blend holding their last frame until they are stopped
+func (a *Animator) IsPlaying(clip string) bool {
+	return slices.ContainsFunc(a.tracks, func(t *track) bool { return t.clip.Name == clip })
+}
+
+// AddEvent raises OnEvent with the name whenever the playback of the clip
+// passes the time in seconds
+func (a *Animator) AddEvent(clip string, time matrix.Float, name string) error {
+	c, err := a.findClip(clip)
+	if err != nil {
+		return err
+	}
+	a.events[c] = append(a.events[c], clipEvent{time, name})
+	slices.SortStableFunc(a.events[c], func(x, y clipEvent) int {
+		if x.time < y.time {
+			return -1
+		} else if x.time > y.time {
+			return 1
+		}
+		return 0
+	})
+	return nil
+}
+
+// AddKeyframeEvent raises OnEvent with the name whenever the playback of the
+// clip reaches the keyframe, keyframes are the distinct key times of all of
+// the channels of the clip in order
+func (a *Animator) AddKeyframeEvent(clip string, keyframe int, name string) error {
+	c, err := a.findClip(clip)
+	if err != nil {
+		return err
+	}
+	if keyframe < 0 || keyframe >= len(c.keyTimes) {
+		return errors.New("the keyframe is outside of the clip")
+	}
+	return a.AddEvent(clip, c.keyTimes[keyframe], name)
+}
+
+// fireEvents raises the events of the clip that are after from (or at it
+// when inclusive) and at or before to
+func (a *Animator) fireEvents(c *Clip, from, to matrix.Float, inclusive bool) {
+	for _, e := range a.events[c] {
+		if (e.time > from || inclusive && e.time == from) && e.time <= to {
+			a.OnEvent.Execute(Event{Clip: c.Name, Name: e.name, Time: e.time})
+		}
+	}
+}
+
+func (a *Animator) advance(t *track, dt matrix.Float) {
+	from, inclusive := t.time, !t.started
+	t.started = true
+	t.time += dt
+	d := t.clip.Duration
+	if t.time < d || d <= 0 {
+		a.fireEvents(t.clip, from, t.time, inclusive)
+		return
+	}
+	if !t.loop {
+		t.time = d
+		if from < d || inclusive {
+			a.fireEvents(t.clip, from, d, inclusive)
+		}
+		return
+	}
+	// Every wrap around the end of the clip passes all of its events
+	a.fireEvents(t.clip, from, d, inclusive)
+	for t.time -= d; t.time >= d; t.time -= d {
+		a.fireEvents(t.clip, 0, d, true)
+	}
+	a.fireEvents(t.clip, 0, t.time, true)
+}
+
+// Update advances every clip by the delta time in seconds, raises the
+// events that were passed and poses the skeleton into Skin
+func (a *Animator) Update(deltaTime float64) {
+	dt := matrix.Float(deltaTime) * a.Speed
+	for i := 0; i < len(a.tracks); i++ {
+		t := a.tracks[i]
+		a.advance(t, dt)
+		if t.weight < t.fadeTo {
+			t.weight = min(t.weight+t.fadeRate*dt, t.fadeTo)
+		} else if t.weight > t.fadeTo {
+			t.weight = max(t.weight-t.fadeRate*dt, t.fadeTo)
+		}
+		if t.fadeTo <= 0 && t.weight <= 0 {
+			a.tracks = slices.Delete(a.tracks, i, i+1)
+			i--
+		}
+	}
+	a.blend()
+	a.updateSkin()
+}
+
+func (a *Animator) restPose(pose []JointPose) {
+	for i := range a.skeleton.Joints {
+		j := &a.skeleton.Joints[i]
+		pose[i] = JointPose{j.Position, j.Rotation, j.Scale}
+	}
+}
+
+func (a *Animator) totalWeight() matrix.Float {
+	total := matrix.Float(0)
+	for _, t := range a.tracks {
+		total += max(t.weight, 0)
+	}
+	return total
+}
+
+// blendMorph blends the weights of the morph like the joints are blended,
+// clips that do not animate the node use the default weights
+func (a *Animator) blendMorph(m *morph, total matrix.Float) {
+	copy(m.weights, m.defaults)
+	if total > 0 {
+		clear(m.weights)
+		for _, t := range a.tracks {
+			if t.weight <= 0 {
+				continue
+			}
+			if !t.clip.SampleWeights(t.time, m.node, m.sample) {
+				copy(m.sample, m.defaults)
+			}
+			w := t.weight / total
+			for i := range m.weights {
+				m.weights[i] += m.sample[i] * w
+			}
+		}
+	}
+	if m.instance != nil {
+		m.instance.SetMorphWeights(m.weights)
+	}
+}
+
+func (a *Animator) blend() {
+	a.restPose(a.pose)
+	total := a.totalWeight()
+	for _, m := range a.morphs {
+		a.blendMorph(m, total)
+	}
+	if total <= 0 {
+		return
+	}
+	first := true
+	for _, t := range a.tracks {
+		if t.weight <= 0 {
+			continue
+		}
+		w := t.weight / total
+		a.restPose(a.sample)
+		t.clip.Sample(t.time, a.sample)
+		for i := range a.pose {
+			s, p := &a.sample[i], &a.pose[i]
+			if first {
+				p.Position = s.Position.Scale(w)
+				p.Scale = s.Scale.Scale(w)
+				p.Rotation = matrix.Quaternion{s.Rotation[0] * w,
+					s.Rotation[1] * w, s.Rotation[2] * w, s.Rotation[3] * w}
+				continue
+			}
+			p.Position.AddAssign(s.Position.Scale(w))
+			p.Scale.AddAssign(s.Scale.Scale(w))
+			// Rotations are blended in the same hemisphere so they do not
+			// cancel each other out
+			dot := s.Rotation[0]*p.Rotation[0] + s.Rotation[1]*p.Rotation[1] +
+				s.Rotation[2]*p.Rotation[2] + s.Rotation[3]*p.Rotation[3]
+			rw := w
+			if dot < 0 {
+				rw = -w
+			}
+			for k := range p.Rotation {
+				p.Rotation[k] += s.Rotation[k] * rw
+			}
+		}
+		first = false
+	}
+	for i := range a.pose {
+		a.pose[i].Rotation.Normalize()
+	}
+}
+
+// Pose is the local pose of every joint from the last update
+func (a *Animator) Pose() []JointPose { return a.pose }
+
+func (a *Animator) updateSkin() {
+	for _, i := range a.order {
+		p := &a.pose[i]
+		local := matrix.Mat4FromTRS(p.Position, p.Rotation, p.Scale)
+		if parent := a.skeleton.Joints[i].Parent; parent >= 0 && parent < len(a.world) {
+			a.world[i] = local.Multiply(a.world[parent])
+		} else {
+			a.world[i] = local.Multiply(a.skeleton.Root)
+		}
+	}
+	for i := range a.Skin.Joints {
+		a.Skin.Joints[i] = a.skeleton.Joints[i].InverseBind.Multiply(a.world[i])
+	}
+}
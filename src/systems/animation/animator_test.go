@@ -0,0 +1,221 @@
+/*****************************************************************************/
+/* animator_test.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package animation
+
+import (
+	"kaiju/matrix"
+	"kaiju/rendering/loaders"
+	"kaiju/rendering/loaders/gltf"
+	"testing"
+)
+
+// testSkeleton is a root joint with a child joint one unit above it
+func testSkeleton() loaders.ResultSkeleton {
+	joint := func(name string, parent int, y matrix.Float) loaders.ResultJoint {
+		ib := matrix.Mat4Identity()
+		ib.SetTranslation(matrix.Vec3{0, -y, 0})
+		return loaders.ResultJoint{
+			Name:        name,
+			Parent:      parent,
+			Node:        parent + 1,
+			Position:    matrix.Vec3{0, min(y, 1), 0},
+			Rotation:    matrix.QuaternionIdentity(),
+			Scale:       matrix.Vec3One(),
+			InverseBind: ib,
+		}
+	}
+	return loaders.ResultSkeleton{
+		Joints: []loaders.ResultJoint{joint("root", -1, 0), joint("tip", 0, 1)},
+		Root:   matrix.Mat4Identity(),
+	}
+}
+
+func testAnimations() []loaders.ResultAnimation {
+	return []loaders.ResultAnimation{
+		{Name: "slide", Duration: 2, Channels: []loaders.ResultAnimationChannel{{
+			Node: 0, Path: gltf.PATH_TRANSLATION, Interpolation: gltf.INTERPOLATION_LINEAR,
+			Times: []matrix.Float{0, 2}, Values: []matrix.Float{0, 0, 0, 4, 0, 0}, Components: 3,
+		}}},
+		{Name: "step", Duration: 1, Channels: []loaders.ResultAnimationChannel{{
+			Node: 0, Path: gltf.PATH_TRANSLATION, Interpolation: gltf.INTERPOLATION_STEP,
+			Times: []matrix.Float{0, 0.5, 1}, Values: []matrix.Float{1, 0, 0, 2, 0, 0, 3, 0, 0}, Components: 3,
+		}}},
+		{Name: "ease", Duration: 1, Channels: []loaders.ResultAnimationChannel{{
+			// Flat tangents make the spline ease in and out
+			Node: 0, Path: gltf.PATH_TRANSLATION, Interpolation: gltf.INTERPOLATION_CUBICSPLINE,
+			Times:  []matrix.Float{0, 1},
+			Values: []matrix.Float{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 0}, Components: 3,
+		}}},
+		{Name: "turn", Duration: 1, Channels: []loaders.ResultAnimationChannel{{
+			Node: 1, Path: gltf.PATH_ROTATION, Interpolation: gltf.INTERPOLATION_LINEAR,
+			Times: []matrix.Float{0, 1},
+			Values: []matrix.Float{0, 0, 0, 1,
+				0, 0, matrix.Sin(matrix.Deg2Rad(45)), matrix.Cos(matrix.Deg2Rad(45))},
+			Components: 4,
+		}}},
+	}
+}
+
+func newTestAnimator() *Animator {
+	skeleton := testSkeleton()
+	return New(&skeleton, testAnimations())
+}
+
+func expectNear(t *testing.T, what string, got, want matrix.Float) {
+	t.Helper()
+	if matrix.Abs(got-want) > 0.001 {
+		t.Errorf("expected %s to be %f, got %f", what, want, got)
+	}
+}
+
+func TestAnimatorInterpolation(t *testing.T) {
+	tests := []struct {
+		clip string
+		time float64
+		x    matrix.Float
+	}{
+		{"slide", 0.5, 1},
+		{"slide", 3, 4},
+		{"step", 0.25, 1},
+		{"step", 0.75, 2},
+		{"ease", 0.25, 4 * 0.15625},
+		{"ease", 0.5, 2},
+	}
+	for _, test := range tests {
+		a := newTestAnimator()
+		if err := a.Play(test.clip, false); err != nil {
+			t.Fatal(err)
+		}
+		a.Update(test.time)
+		expectNear(t, test.clip, a.Pose()[0].Position.X(), test.x)
+	}
+}
+
+func TestAnimatorJointPalette(t *testing.T) {
+	a := newTestAnimator()
+	a.Play("turn", false)
+	a.Update(1)
+	// The tip is turned 90 degrees around its own joint, a vertex one unit
+	// above it ends up one unit to its left
+	p := a.Skin.Joints[1].TransformPoint(matrix.Vec3{0, 2, 0})
+	expectNear(t, "x", p.X(), -1)
+	expectNear(t, "y", p.Y(), 1)
+	// The root is not animated so it stays in its bind pose
+	p = a.Skin.Joints[0].TransformPoint(matrix.Vec3{0, 0.5, 0})
+	expectNear(t, "root y", p.Y(), 0.5)
+}
+
+func TestAnimatorBlend(t *testing.T) {
+	a := newTestAnimator()
+	a.Blend("slide", 1, true)
+	a.Blend("step", 3, true)
+	a.Update(0.25)
+	// slide is at 0.5 and step at 1, weighted 1 to 3
+	expectNear(t, "blend", a.Pose()[0].Position.X(), (0.5+3)/4)
+	// Without any weight the skeleton goes back to its rest pose
+	a.Blend("slide", 0, true)
+	a.Blend("step", 0, true)
+	a.Update(0)
+	expectNear(t, "rest", a.Pose()[0].Position.X(), 0)
+}
+
+func TestAnimatorCrossFade(t *testing.T) {
+	a := newTestAnimator()
+	a.Play("slide", true)
+	a.Update(0.5)
+	if err := a.CrossFade("step", 1, true); err != nil {
+		t.Fatal(err)
+	}
+	a.Update(0.5)
+	expectNear(t, "slide weight", a.Weight("slide"), 0.5)
+	expectNear(t, "step weight", a.Weight("step"), 0.5)
+	a.Update(0.5)
+	if a.IsPlaying("slide") {
+		t.Fatal("expected slide to stop once it faded out")
+	}
+	expectNear(t, "step weight", a.Weight("step"), 1)
+}
+
+func TestAnimatorEvents(t *testing.T) {
+	a := newTestAnimator()
+	fired := []string{}
+	a.OnEvent.Add(func(e Event) { fired = append(fired, e.Name) })
+	a.AddEvent("step", 0, "start")
+	a.AddKeyframeEvent("step", 1, "middle")
+	if err := a.AddKeyframeEvent("step", 3, "missing"); err == nil {
+		t.Fatal("expected keyframes outside of the clip to fail")
+	}
+	a.Play("step", true)
+	a.Update(0.4)
+	a.Update(0.2)
+	// Wraps around the end of the clip twice
+	a.Update(1.5)
+	expect := []string{"start", "middle", "start", "middle", "start"}
+	if len(fired) != len(expect) {
+		t.Fatalf("expected the events %v, got %v", expect, fired)
+	}
+	for i := range expect {
+		if fired[i] != expect[i] {
+			t.Fatalf("expected the events %v, got %v", expect, fired)
+		}
+	}
+}
+
+func TestAnimatorMorphWeights(t *testing.T) {
+	a := New(&loaders.ResultSkeleton{}, []loaders.ResultAnimation{
+		{Name: "pulse", Duration: 1, Channels: []loaders.ResultAnimationChannel{{
+			Node: 3, Path: gltf.PATH_WEIGHTS, Interpolation: gltf.INTERPOLATION_LINEAR,
+			Times: []matrix.Float{0, 1}, Values: []matrix.Float{0, 1, 1, 0}, Components: 2,
+		}}},
+		{Name: "idle", Duration: 1},
+	})
+	a.AddMorph(3, nil, []matrix.Float{0.5, 0.5})
+	if w := a.MorphWeights(3); w[0] != 0.5 || w[1] != 0.5 {
+		t.Fatalf("expected the default weights while nothing plays, got %v", w)
+	}
+	a.Play("pulse", false)
+	a.Update(0.25)
+	w := a.MorphWeights(3)
+	expectNear(t, "first weight", w[0], 0.25)
+	expectNear(t, "second weight", w[1], 0.75)
+	// Clips that do not animate the weights blend in the defaults
+	a.Blend("idle", 1, true)
+	a.Update(0)
+	w = a.MorphWeights(3)
+	expectNear(t, "blended weight", w[0], (0.25+0.5)/2)
+}
@@ -0,0 +1,198 @@
+/*****************************************************************************/
+/* clip.go                                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package animation
+
+import (
+	"kaiju/matrix"
+	"kaiju/rendering/loaders"
+	"kaiju/rendering/loaders/gltf"
+	"slices"
+	"sort"
+)
+
+// JointPose is the local transform of a joint relative to its parent
+type JointPose struct {
+	Position matrix.Vec3
+	Rotation matrix.Quaternion
+	Scale    matrix.Vec3
+}
+
+type channel struct {
+	joint         int
+	path          gltf.AnimationPath
+	interpolation gltf.Interpolation
+	times         []matrix.Float
+	values        []matrix.Float
+	components    int
+}
+
+// Clip is an animation of a skeleton, channels that target nodes that are
+// not joints of the skeleton are left out
+type Clip struct {
+	Name     string
+	Duration matrix.Float
+	channels []channel
+	// weights are the morph target weight channels, their joint is the node
+	// they target
+	weights []channel
+	// keyTimes are the times of all the keyframes of the clip, in order
+	keyTimes []matrix.Float
+}
+
+func newClip(anim *loaders.ResultAnimation, skeleton *loaders.ResultSkeleton) *Clip {
+	joints := make(map[int]int, len(skeleton.Joints))
+	for i := range skeleton.Joints {
+		joints[skeleton.Joints[i].Node] = i
+	}
+	c := &Clip{Name: anim.Name, Duration: anim.Duration}
+	for i := range anim.Channels {
+		ch := &anim.Channels[i]
+		out := channel{
+			joint:         ch.Node,
+			path:          ch.Path,
+			interpolation: ch.Interpolation,
+			times:         ch.Times,
+			values:        ch.Values,
+			components:    ch.Components,
+		}
+		if ch.Path == gltf.PATH_WEIGHTS {
+			c.weights = append(c.weights, out)
+		} else if joint, ok := joints[ch.Node]; ok {
+			out.joint = joint
+			c.channels = append(c.channels, out)
+		} else {
+			continue
+		}
+		c.keyTimes = append(c.keyTimes, ch.Times...)
+	}
+	slices.Sort(c.keyTimes)
+	c.keyTimes = slices.Compact(c.keyTimes)
+	return c
+}
+
+// Sample writes the pose of the animated joints at the time into the pose,
+// joints the clip does not animate are left as they are
+func (c *Clip) Sample(time matrix.Float, pose []JointPose) {
+	var v [4]matrix.Float
+	for i := range c.channels {
+		ch := &c.channels[i]
+		if ch.joint >= len(pose) || ch.components > len(v) {
+			continue
+		}
+		ch.sample(time, v[:ch.components])
+		p := &pose[ch.joint]
+		switch ch.path {
+		case gltf.PATH_TRANSLATION:
+			p.Position = matrix.Vec3{v[0], v[1], v[2]}
+		case gltf.PATH_ROTATION:
+			p.Rotation = matrix.NewQuaternion(v[3], v[0], v[1], v[2]).Normal()
+		case gltf.PATH_SCALE:
+			p.Scale = matrix.Vec3{v[0], v[1], v[2]}
+		}
+	}
+}
+
+// SampleWeights writes the morph target weights of the node at the time
+// into weights, it is false when the clip does not animate the weights of
+// the node
+func (c *Clip) SampleWeights(time matrix.Float, node int, weights []matrix.Float) bool {
+	for i := range c.weights {
+		ch := &c.weights[i]
+		if ch.joint != node {
+			continue
+		}
+		if ch.components > len(weights) {
+			return false
+		}
+		ch.sample(time, weights[:ch.components])
+		return true
+	}
+	return false
+}
+
+// value is the keyframe value, for cubic splines part selects the in
+// tangent (0), the value (1) or the out tangent (2)
+func (ch *channel) value(key, part int) []matrix.Float {
+	if ch.interpolation == gltf.INTERPOLATION_CUBICSPLINE {
+		start := (key*3 + part) * ch.components
+		return ch.values[start : start+ch.components]
+	}
+	start := key * ch.components
+	return ch.values[start : start+ch.components]
+}
+
+func (ch *channel) sample(time matrix.Float, out []matrix.Float) {
+	last := len(ch.times) - 1
+	if time <= ch.times[0] {
+		copy(out, ch.value(0, 1))
+		return
+	} else if time >= ch.times[last] {
+		copy(out, ch.value(last, 1))
+		return
+	}
+	key := sort.Search(len(ch.times), func(i int) bool { return ch.times[i] > time }) - 1
+	dt := ch.times[key+1] - ch.times[key]
+	t := (time - ch.times[key]) / dt
+	switch ch.interpolation {
+	case gltf.INTERPOLATION_STEP:
+		copy(out, ch.value(key, 1))
+	case gltf.INTERPOLATION_CUBICSPLINE:
+		p0, m0 := ch.value(key, 1), ch.value(key, 2)
+		p1, m1 := ch.value(key+1, 1), ch.value(key+1, 0)
+		t2 := t * t
+		t3 := t2 * t
+		h00 := 2*t3 - 3*t2 + 1
+		h10 := t3 - 2*t2 + t
+		h01 := -2*t3 + 3*t2
+		h11 := t3 - t2
+		for i := range out {
+			out[i] = h00*p0[i] + h10*dt*m0[i] + h01*p1[i] + h11*dt*m1[i]
+		}
+	default:
+		a, b := ch.value(key, 1), ch.value(key+1, 1)
+		if ch.path == gltf.PATH_ROTATION {
+			q := matrix.QuaternionSlerp(matrix.NewQuaternion(a[3], a[0], a[1], a[2]),
+				matrix.NewQuaternion(b[3], b[0], b[1], b[2]), t)
+			out[0], out[1], out[2], out[3] = q.X(), q.Y(), q.Z(), q.W()
+			return
+		}
+		for i := range out {
+			out[i] = a[i] + (b[i]-a[i])*t
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*****************************************************************************/
+/* camera_rig.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package character
+
+import (
+	"kaiju/cameras"
+	"kaiju/collision"
+	"kaiju/engine"
+	"kaiju/matrix"
+)
+
+const maxCameraPitch = 89.0
+
+// MoveDirection converts a 2D input (x = strafe, y = forward) into a world
+// space direction on the ground plane relative to where the camera looks
+func MoveDirection(camera cameras.Camera, input matrix.Vec2) matrix.Vec3 {
+	fwd := camera.Forward()
+	fwd.SetY(0)
+	right := camera.Right()
+	right.SetY(0)
+	if fwd.Length() > minMoveDistance {
+		fwd.Normalize()
+	}
+	if right.Length() > minMoveDistance {
+		right.Normalize()
+	}
+	dir := fwd.Scale(input.Y()).Add(right.Scale(input.X()))
+	if dir.Length() > 1 {
+		dir.Normalize()
+	}
+	return dir
+}
+
+func lookDirection(yaw, pitch float32) matrix.Vec3 {
+	return matrix.Vec3{
+		matrix.Cos(matrix.Deg2Rad(yaw)) * matrix.Cos(matrix.Deg2Rad(pitch)),
+		matrix.Sin(matrix.Deg2Rad(pitch)),
+		matrix.Sin(matrix.Deg2Rad(yaw)) * matrix.Cos(matrix.Deg2Rad(pitch)),
+	}.Normal()
+}
+
+type cameraRig struct {
+	host       *engine.Host
+	Camera     cameras.Camera
+	Controller *Controller
+	yaw, pitch float32
+	updateId   int
+}
+
+func (r *cameraRig) init(host *engine.Host, controller *Controller, update func(float64)) {
+	r.host = host
+	r.Camera = host.Camera
+	r.Controller = controller
+	r.updateId = host.LateUpdater.AddUpdate(update)
+	controller.Entity.OnDestroy.Add(func() {
+		host.LateUpdater.RemoveUpdate(r.updateId)
+	})
+}
+
+func (r *cameraRig) Yaw() float32   { return r.yaw }
+func (r *cameraRig) Pitch() float32 { return r.pitch }
+
+// Look rotates the rig by the given yaw and pitch deltas in degrees
+func (r *cameraRig) Look(yawDelta, pitchDelta float32) {
+	r.yaw += yawDelta
+	if r.yaw > 360 {
+		r.yaw -= 360
+	} else if r.yaw < -360 {
+		r.yaw += 360
+	}
+	r.pitch = matrix.Clamp(r.pitch+pitchDelta, -maxCameraPitch, maxCameraPitch)
+}
+
+func (r *cameraRig) Destroy() {
+	r.host.LateUpdater.RemoveUpdate(r.updateId)
+}
+
+// FirstPersonCamera places the camera at the eyes of the character
+type FirstPersonCamera struct {
+	cameraRig
+	EyeHeight float32
+}
+
+func NewFirstPersonCamera(host *engine.Host, controller *Controller) *FirstPersonCamera {
+	fp := &FirstPersonCamera{EyeHeight: controller.Height * 0.9}
+	fp.init(host, controller, fp.update)
+	return fp
+}
+
+func (fp *FirstPersonCamera) update(float64) {
+	feet := fp.Controller.Entity.Transform.WorldPosition()
+	fp.Camera.SetPosition(feet.Add(matrix.Vec3Up().Scale(fp.EyeHeight)))
+	fp.Camera.SetYawAndPitch(fp.yaw, fp.pitch)
+}
+
+// ThirdPersonCamera orbits the camera around the character, the camera is
+// pulled in towards the character when level geometry is in the way
+type ThirdPersonCamera struct {
+	cameraRig
+	Distance        float32
+	TargetHeight    float32
+	CollisionRadius float32
+}
+
+func NewThirdPersonCamera(host *engine.Host, controller *Controller, distance float32) *ThirdPersonCamera {
+	tp := &ThirdPersonCamera{
+		Distance:        distance,
+		TargetHeight:    controller.Height * 0.9,
+		CollisionRadius: 0.2,
+	}
+	tp.pitch = -20
+	tp.init(host, controller, tp.update)
+	return tp
+}
+
+func (tp *ThirdPersonCamera) update(float64) {
+	feet := tp.Controller.Entity.Transform.WorldPosition()
+	target := feet.Add(matrix.Vec3Up().Scale(tp.TargetHeight))
+	offset := lookDirection(tp.yaw, tp.pitch).Scale(-tp.Distance)
+	if level := tp.Controller.Level(); level != nil && tp.CollisionRadius > 0 {
+		sphere := collision.Capsule{A: target, B: target, Radius: tp.CollisionRadius}
+		if hit, ok := level.Sweep(sphere, offset); ok {
+			offset.ScaleAssign(hit.Time)
+		}
+	}
+	tp.Camera.SetPositionAndLookAt(target.Add(offset), target)
+}
@@ -0,0 +1,315 @@
+/*****************************************************************************/
+/* controller.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package character
+
+import (
+	"kaiju/collision"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/systems/events"
+)
+
+const (
+	maxSlideIterations = 4
+	maxDepenetrations  = 4
+	minMoveDistance    = 0.0001
+)
+
+type CollisionFlags uint8
+
+const (
+	CollisionSides CollisionFlags = 1 << iota
+	CollisionAbove
+	CollisionBelow
+)
+
+type Hit struct {
+	Point     matrix.Vec3
+	Normal    matrix.Vec3
+	Direction matrix.Vec3
+	Length    float32
+}
+
+// Controller is a kinematic capsule that is moved with Move rather than
+// simulated. The position of the entity is at the feet of the capsule and
+// the capsule always stands upright along the world up axis.
+type Controller struct {
+	Entity        *engine.Entity
+	host          *engine.Host
+	level         *Level
+	OnCollision   events.EventWithArg[Hit]
+	Velocity      matrix.Vec3
+	groundNormal  matrix.Vec3
+	Radius        float32
+	Height        float32
+	SkinWidth     float32
+	StepHeight    float32
+	SnapDistance  float32
+	SlopeLimit    float32
+	Gravity       float32
+	verticalSpeed float32
+	updateId      int
+	grounded      bool
+}
+
+func NewController(host *engine.Host, entity *engine.Entity, level *Level, radius, height float32) *Controller {
+	c := &Controller{
+		Entity:       entity,
+		host:         host,
+		level:        level,
+		OnCollision:  events.NewWithArg[Hit](),
+		groundNormal: matrix.Vec3Up(),
+		Radius:       radius,
+		Height:       max(height, radius*2),
+		SkinWidth:    0.01,
+		StepHeight:   0.3,
+		SnapDistance: 0.3,
+		SlopeLimit:   45,
+		Gravity:      9.81,
+	}
+	c.updateId = host.Updater.AddUpdate(c.update)
+	entity.OnDestroy.Add(func() {
+		host.Updater.RemoveUpdate(c.updateId)
+	})
+	return c
+}
+
+func (c *Controller) Level() *Level                  { return c.level }
+func (c *Controller) SetLevel(level *Level)          { c.level = level }
+func (c *Controller) IsGrounded() bool               { return c.grounded }
+func (c *Controller) GroundNormal() matrix.Vec3      { return c.groundNormal }
+func (c *Controller) VerticalSpeed() float32         { return c.verticalSpeed }
+func (c *Controller) SetVerticalSpeed(speed float32) { c.verticalSpeed = speed }
+
+// Jump launches the controller upwards, it is ignored while in the air
+func (c *Controller) Jump(speed float32) {
+	if c.grounded {
+		c.verticalSpeed = speed
+		c.grounded = false
+	}
+}
+
+// Teleport moves the feet of the controller without testing for collisions
+func (c *Controller) Teleport(position matrix.Vec3) {
+	c.Entity.Transform.SetWorldPosition(position)
+	c.verticalSpeed = 0
+	c.grounded = false
+}
+
+func (c *Controller) Capsule() collision.Capsule {
+	return c.capsuleAt(c.Entity.Transform.WorldPosition())
+}
+
+func (c *Controller) capsuleAt(feet matrix.Vec3) collision.Capsule {
+	up := matrix.Vec3Up()
+	return collision.Capsule{
+		A:      feet.Add(up.Scale(c.Radius)),
+		B:      feet.Add(up.Scale(c.Height - c.Radius)),
+		Radius: c.Radius,
+	}
+}
+
+func (c *Controller) minGroundDot() float32 {
+	return matrix.Cos(matrix.Deg2Rad(c.SlopeLimit))
+}
+
+func (c *Controller) isWalkable(normal matrix.Vec3) bool {
+	return matrix.Vec3Dot(normal, matrix.Vec3Up()) >= c.minGroundDot()
+}
+
+func (c *Controller) classify(normal matrix.Vec3) CollisionFlags {
+	d := matrix.Vec3Dot(normal, matrix.Vec3Up())
+	if d >= c.minGroundDot() {
+		return CollisionBelow
+	} else if d <= -c.minGroundDot() {
+		return CollisionAbove
+	}
+	return CollisionSides
+}
+
+func (c *Controller) update(deltaTime float64) {
+	if !c.Entity.CanUpdate() || c.level == nil {
+		return
+	}
+	dt := float32(deltaTime)
+	if c.grounded && c.verticalSpeed <= 0 {
+		c.verticalSpeed = 0
+	} else {
+		c.verticalSpeed -= c.Gravity * dt
+	}
+	motion := c.Velocity.Scale(dt).Add(matrix.Vec3Up().Scale(c.verticalSpeed * dt))
+	flags := c.Move(motion)
+	if flags&CollisionAbove != 0 && c.verticalSpeed > 0 {
+		c.verticalSpeed = 0
+	}
+}
+
+// Move slides the controller along the level geometry by the given motion,
+// stepping up over small ledges and staying snapped to the ground when
+// walking down slopes or stairs. The returned flags describe which sides of
+// the capsule touched something during the move.
+func (c *Controller) Move(motion matrix.Vec3) CollisionFlags {
+	if c.level == nil {
+		return 0
+	}
+	up := matrix.Vec3Up()
+	pos := c.depenetrate(c.Entity.Transform.WorldPosition())
+	wasGrounded := c.grounded
+	vertical := up.Scale(matrix.Vec3Dot(motion, up))
+	horizontal := motion.Subtract(vertical)
+	pos, flags := c.moveHorizontal(pos, horizontal, wasGrounded)
+	pos, vFlags := c.slide(pos, vertical, false)
+	flags |= vFlags
+	c.grounded, c.groundNormal = c.checkGround(pos)
+	if !c.grounded && wasGrounded && vertical.Y() <= 0 && c.verticalSpeed <= 0 {
+		pos = c.snapToGround(pos)
+	}
+	if c.grounded {
+		flags |= CollisionBelow
+	} else {
+		c.groundNormal = up
+	}
+	c.Entity.Transform.SetWorldPosition(pos)
+	return flags
+}
+
+func (c *Controller) depenetrate(pos matrix.Vec3) matrix.Vec3 {
+	for i := 0; i < maxDepenetrations; i++ {
+		offset, moved := c.level.Depenetrate(c.capsuleAt(pos))
+		if !moved {
+			break
+		}
+		pos.AddAssign(offset)
+	}
+	return pos
+}
+
+func (c *Controller) slide(pos, motion matrix.Vec3, horizontal bool) (matrix.Vec3, CollisionFlags) {
+	up := matrix.Vec3Up()
+	flags := CollisionFlags(0)
+	remaining := motion
+	for i := 0; i < maxSlideIterations; i++ {
+		length := remaining.Length()
+		if length < minMoveDistance {
+			break
+		}
+		hit, ok := c.level.Sweep(c.capsuleAt(pos), remaining)
+		if !ok {
+			pos.AddAssign(remaining)
+			break
+		}
+		dir := remaining.Shrink(length)
+		travel := max(0, length*hit.Time-c.SkinWidth)
+		pos.AddAssign(dir.Scale(travel))
+		flags |= c.classify(hit.SurfaceNormal)
+		c.OnCollision.Execute(Hit{
+			Point:     hit.Point,
+			Normal:    hit.Normal,
+			Direction: dir,
+			Length:    length,
+		})
+		normal := hit.Normal
+		if horizontal && !c.isWalkable(hit.SurfaceNormal) {
+			// Walls and steep slopes are treated as vertical so that the
+			// horizontal motion can't be used to climb them
+			flat := normal.Subtract(up.Scale(matrix.Vec3Dot(normal, up)))
+			if flat.Length() > minMoveDistance {
+				normal = flat.Normal()
+			}
+		}
+		left := dir.Scale(length - travel)
+		remaining = left.Subtract(normal.Scale(matrix.Vec3Dot(left, normal)))
+		if matrix.Vec3Dot(remaining, motion) <= 0 {
+			break
+		}
+	}
+	return pos, flags
+}
+
+func (c *Controller) moveHorizontal(pos, motion matrix.Vec3, grounded bool) (matrix.Vec3, CollisionFlags) {
+	end, flags := c.slide(pos, motion, true)
+	if !grounded || c.StepHeight <= 0 || flags&CollisionSides == 0 {
+		return end, flags
+	}
+	up := matrix.Vec3Up()
+	raised, _ := c.slide(pos, up.Scale(c.StepHeight), false)
+	climbed := raised.Y() - pos.Y()
+	if climbed <= minMoveDistance {
+		return end, flags
+	}
+	stepped, stepFlags := c.slide(raised, motion, true)
+	drop := climbed + c.SkinWidth
+	hit, ok := c.level.Sweep(c.capsuleAt(stepped), up.Scale(-drop))
+	if !ok || !c.isWalkable(hit.SurfaceNormal) {
+		return end, flags
+	}
+	landed := stepped.Subtract(up.Scale(max(0, drop*hit.Time-c.SkinWidth)))
+	if horizontalDistanceSq(pos, landed) <= horizontalDistanceSq(pos, end)+minMoveDistance {
+		return end, flags
+	}
+	return landed, stepFlags
+}
+
+func (c *Controller) checkGround(pos matrix.Vec3) (bool, matrix.Vec3) {
+	probe := matrix.Vec3Down().Scale(c.SkinWidth * 2)
+	if hit, ok := c.level.Sweep(c.capsuleAt(pos), probe); ok && c.isWalkable(hit.SurfaceNormal) {
+		return true, hit.SurfaceNormal
+	}
+	return false, matrix.Vec3Up()
+}
+
+func (c *Controller) snapToGround(pos matrix.Vec3) matrix.Vec3 {
+	if c.SnapDistance <= 0 {
+		return pos
+	}
+	down := matrix.Vec3Down()
+	hit, ok := c.level.Sweep(c.capsuleAt(pos), down.Scale(c.SnapDistance))
+	if !ok || !c.isWalkable(hit.SurfaceNormal) {
+		return pos
+	}
+	pos.AddAssign(down.Scale(max(0, c.SnapDistance*hit.Time-c.SkinWidth)))
+	c.grounded = true
+	c.groundNormal = hit.SurfaceNormal
+	return pos
+}
+
+func horizontalDistanceSq(a, b matrix.Vec3) float32 {
+	dx := b.X() - a.X()
+	dz := b.Z() - a.Z()
+	return dx*dx + dz*dz
+}
@@ -0,0 +1,228 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package character
+
+import (
+	"kaiju/collision"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"testing"
+)
+
+const (
+	testRadius = 0.5
+	testHeight = 2
+)
+
+func addQuad(level *Level, a, b, c, d matrix.Vec3) {
+	level.AddTriangle(collision.NewTriangle(a, b, c))
+	level.AddTriangle(collision.NewTriangle(a, c, d))
+}
+
+func addFloor(level *Level, y float32) {
+	addQuad(level, matrix.Vec3{-20, y, -20}, matrix.Vec3{-20, y, 20},
+		matrix.Vec3{20, y, 20}, matrix.Vec3{20, y, -20})
+}
+
+// addBox adds the top and sides of an axis aligned box sitting on the floor
+func addBox(level *Level, min, max matrix.Vec3) {
+	addQuad(level, matrix.Vec3{min.X(), max.Y(), min.Z()}, matrix.Vec3{min.X(), max.Y(), max.Z()},
+		matrix.Vec3{max.X(), max.Y(), max.Z()}, matrix.Vec3{max.X(), max.Y(), min.Z()})
+	addQuad(level, matrix.Vec3{min.X(), min.Y(), min.Z()}, matrix.Vec3{min.X(), min.Y(), max.Z()},
+		matrix.Vec3{min.X(), max.Y(), max.Z()}, matrix.Vec3{min.X(), max.Y(), min.Z()})
+	addQuad(level, matrix.Vec3{max.X(), min.Y(), min.Z()}, matrix.Vec3{max.X(), max.Y(), min.Z()},
+		matrix.Vec3{max.X(), max.Y(), max.Z()}, matrix.Vec3{max.X(), min.Y(), max.Z()})
+	addQuad(level, matrix.Vec3{min.X(), min.Y(), min.Z()}, matrix.Vec3{min.X(), max.Y(), min.Z()},
+		matrix.Vec3{max.X(), max.Y(), min.Z()}, matrix.Vec3{max.X(), min.Y(), min.Z()})
+	addQuad(level, matrix.Vec3{min.X(), min.Y(), max.Z()}, matrix.Vec3{max.X(), min.Y(), max.Z()},
+		matrix.Vec3{max.X(), max.Y(), max.Z()}, matrix.Vec3{min.X(), max.Y(), max.Z()})
+}
+
+// addRamp adds a ramp that rises along +X from x0 at the given angle
+func addRamp(level *Level, x0, length, degrees float32) {
+	rise := length * matrix.Tan(matrix.Deg2Rad(degrees))
+	addQuad(level, matrix.Vec3{x0, 0, -5}, matrix.Vec3{x0, 0, 5},
+		matrix.Vec3{x0 + length, rise, 5}, matrix.Vec3{x0 + length, rise, -5})
+}
+
+func testController(t *testing.T, level *Level, feet matrix.Vec3) *Controller {
+	t.Helper()
+	host := engine.NewHost("Character test")
+	entity := engine.NewEntity()
+	entity.Transform.SetWorldPosition(feet)
+	return NewController(host, entity, level, testRadius, testHeight)
+}
+
+func (c *Controller) testFeet() matrix.Vec3 {
+	return c.Entity.Transform.WorldPosition()
+}
+
+// walk moves the controller in small steps like it would over several frames
+func walk(c *Controller, motion matrix.Vec3, steps int) CollisionFlags {
+	flags := CollisionFlags(0)
+	step := motion.Scale(1 / float32(steps))
+	for i := 0; i < steps; i++ {
+		flags |= c.Move(step)
+	}
+	return flags
+}
+
+func TestControllerGrounded(t *testing.T) {
+	level := NewLevel()
+	addFloor(level, 0)
+	c := testController(t, level, matrix.Vec3{0, 3, 0})
+	if flags := c.Move(matrix.Vec3{0, -0.5, 0}); flags&CollisionBelow != 0 || c.IsGrounded() {
+		t.Fatal("the controller should still be in the air")
+	}
+	flags := c.Move(matrix.Vec3{0, -5, 0})
+	if flags&CollisionBelow == 0 || !c.IsGrounded() {
+		t.Fatal("the controller should have landed on the floor")
+	}
+	if y := c.testFeet().Y(); y < 0 || y > c.SkinWidth*2 {
+		t.Errorf("expected the feet to rest on the floor, got a height of %f", y)
+	}
+	if !matrix.Vec3ApproxTo(c.GroundNormal(), matrix.Vec3Up(), 0.001) {
+		t.Errorf("expected an up facing ground normal, got %v", c.GroundNormal())
+	}
+	c.Jump(5)
+	if c.IsGrounded() || c.VerticalSpeed() != 5 {
+		t.Error("jumping should leave the ground")
+	}
+	c.Jump(10)
+	if c.VerticalSpeed() != 5 {
+		t.Error("jumping in the air should be ignored")
+	}
+}
+
+func TestControllerMoveAndSlide(t *testing.T) {
+	level := NewLevel()
+	addFloor(level, 0)
+	// A wall facing -X at x = 2
+	addQuad(level, matrix.Vec3{2, 0, -20}, matrix.Vec3{2, 3, -20},
+		matrix.Vec3{2, 3, 20}, matrix.Vec3{2, 0, 20})
+	c := testController(t, level, matrix.Vec3{0, 0.005, 0})
+	hits := 0
+	c.OnCollision.Add(func(Hit) { hits++ })
+	flags := c.Move(matrix.Vec3{4, 0, 2})
+	if flags&CollisionSides == 0 {
+		t.Error("expected the move to hit the wall")
+	}
+	if hits == 0 {
+		t.Error("expected the collision event to fire")
+	}
+	feet := c.testFeet()
+	if feet.X() > 2-testRadius || feet.X() < 2-testRadius-c.SkinWidth*2 {
+		t.Errorf("expected to stop against the wall, got x = %f", feet.X())
+	}
+	if !matrix.ApproxTo(feet.Z(), 2, 0.01) {
+		t.Errorf("expected to slide along the wall to z = 2, got %f", feet.Z())
+	}
+	if !c.IsGrounded() {
+		t.Error("sliding along the wall should keep the controller grounded")
+	}
+}
+
+func TestControllerStepUp(t *testing.T) {
+	level := NewLevel()
+	addFloor(level, 0)
+	addBox(level, matrix.Vec3{1, 0, -5}, matrix.Vec3{4, 0.2, 5})
+	addBox(level, matrix.Vec3{1, 0, 6}, matrix.Vec3{4, 0.5, 16})
+	c := testController(t, level, matrix.Vec3{0, 0.005, 0})
+	walk(c, matrix.Vec3{2.5, 0, 0}, 10)
+	if feet := c.testFeet(); !matrix.ApproxTo(feet.Y(), 0.2, c.SkinWidth*2) || feet.X() < 2 {
+		t.Errorf("expected to step up onto the low box, got %v", feet)
+	}
+	if !c.IsGrounded() {
+		t.Error("expected to be grounded on top of the step")
+	}
+	tall := testController(t, level, matrix.Vec3{0, 0.005, 11})
+	walk(tall, matrix.Vec3{2.5, 0, 0}, 10)
+	if feet := tall.testFeet(); feet.Y() > 0.1 || feet.X() > 1-testRadius {
+		t.Errorf("a ledge taller than the step height should block, got %v", feet)
+	}
+}
+
+func TestControllerSlopeLimit(t *testing.T) {
+	level := NewLevel()
+	addFloor(level, 0)
+	addRamp(level, 1, 4, 20)
+	gentle := testController(t, level, matrix.Vec3{0, 0.005, 0})
+	walk(gentle, matrix.Vec3{3, 0, 0}, 10)
+	if feet := gentle.testFeet(); feet.Y() < 0.3 || feet.X() < 2.5 {
+		t.Errorf("expected to walk up the gentle slope, got %v", feet)
+	}
+	if !gentle.IsGrounded() {
+		t.Error("a slope under the limit should be walkable")
+	}
+	steepLevel := NewLevel()
+	addFloor(steepLevel, 0)
+	addRamp(steepLevel, 1, 4, 60)
+	steep := testController(t, steepLevel, matrix.Vec3{0, 0.005, 0})
+	flags := walk(steep, matrix.Vec3{3, 0, 0}, 10)
+	if flags&CollisionSides == 0 {
+		t.Error("a slope over the limit should be treated like a wall")
+	}
+	if feet := steep.testFeet(); feet.Y() > 0.1 || feet.X() > 1.5 {
+		t.Errorf("expected the steep slope to block, got %v", feet)
+	}
+}
+
+func TestControllerGroundSnap(t *testing.T) {
+	level := NewLevel()
+	addFloor(level, -0.2)
+	addBox(level, matrix.Vec3{-5, -0.2, -5}, matrix.Vec3{1, 0, 5})
+	c := testController(t, level, matrix.Vec3{0, 0.005, 0})
+	c.Move(matrix.Vec3{0, -0.01, 0})
+	walk(c, matrix.Vec3{2, 0, 0}, 10)
+	if feet := c.testFeet(); !matrix.ApproxTo(feet.Y(), -0.2, c.SkinWidth*2) {
+		t.Errorf("expected to snap down the small ledge, got %v", feet)
+	}
+	if !c.IsGrounded() {
+		t.Error("expected to stay grounded after snapping")
+	}
+	cliff := NewLevel()
+	addFloor(cliff, -2)
+	addBox(cliff, matrix.Vec3{-5, -2, -5}, matrix.Vec3{1, 0, 5})
+	c = testController(t, cliff, matrix.Vec3{0, 0.005, 0})
+	c.Move(matrix.Vec3{0, -0.01, 0})
+	walk(c, matrix.Vec3{2, 0, 0}, 10)
+	// The capsule rolls a little way over the edge before it loses contact
+	if c.IsGrounded() || c.testFeet().Y() < -0.5 {
+		t.Errorf("a drop larger than the snap distance should leave the ground, got %v", c.testFeet())
+	}
+}
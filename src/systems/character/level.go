@@ -0,0 +1,138 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package character
+
+import (
+	"kaiju/collision"
+	"kaiju/matrix"
+	"kaiju/rendering"
+)
+
+// Level is the static triangle geometry that character controllers collide
+// against, it is shared between all controllers in a scene. The triangles
+// are indexed by a BVH which is rebuilt on the first query after a change.
+type Level struct {
+	triangles []collision.Triangle
+	bvh       *collision.BVH
+}
+
+func NewLevel() *Level {
+	return &Level{
+		triangles: make([]collision.Triangle, 0),
+	}
+}
+
+func (l *Level) TriangleCount() int { return len(l.triangles) }
+
+func (l *Level) AddTriangle(tri collision.Triangle) {
+	l.triangles = append(l.triangles, tri)
+	l.bvh = nil
+}
+
+// AddMesh adds the triangles of a mesh to the level, the vertices are
+// transformed into world space by the supplied matrix
+func (l *Level) AddMesh(verts []rendering.Vertex, indexes []uint32, transform matrix.Mat4) {
+	for i := 0; i+2 < len(indexes); i += 3 {
+		tri := collision.NewTriangle(
+			transform.TransformPoint(verts[indexes[i]].Position),
+			transform.TransformPoint(verts[indexes[i+1]].Position),
+			transform.TransformPoint(verts[indexes[i+2]].Position))
+		if !tri.IsDegenerate() {
+			l.AddTriangle(tri)
+		}
+	}
+}
+
+func (l *Level) Clear() {
+	l.triangles = l.triangles[:0]
+	l.bvh = nil
+}
+
+func (l *Level) eachOverlapping(bounds collision.AABB, fn func(tri *collision.Triangle)) {
+	if l.bvh == nil {
+		l.bvh = collision.NewBVH(l.triangles)
+	}
+	l.bvh.Overlapping(bounds, func(tri int) {
+		fn(&l.triangles[tri])
+	})
+}
+
+const sweepTieTolerance = 0.0001
+
+// prefersHit decides between two contacts at the same time, favoring the
+// deepest penetration and then the most upward facing surface so that a
+// capsule resting on an edge treats the top face as the ground
+func prefersHit(hit, best collision.SweepHit) bool {
+	if hit.Depth != best.Depth {
+		return hit.Depth > best.Depth
+	}
+	return hit.SurfaceNormal.Y() > best.SurfaceNormal.Y()
+}
+
+// Sweep moves the capsule along the motion and returns the earliest hit
+// against the level geometry
+func (l *Level) Sweep(capsule collision.Capsule, motion matrix.Vec3) (collision.SweepHit, bool) {
+	best := collision.SweepHit{}
+	found := false
+	l.eachOverlapping(capsule.SweptBounds(motion), func(tri *collision.Triangle) {
+		if hit, ok := capsule.SweepTriangle(*tri, motion); ok {
+			if !found || hit.Time < best.Time-sweepTieTolerance {
+				best = hit
+				found = true
+			} else if hit.Time <= best.Time+sweepTieTolerance && prefersHit(hit, best) {
+				best = hit
+			}
+		}
+	})
+	return best, found
+}
+
+// Depenetrate returns the offset needed to push the capsule out of any of
+// the level geometry it is currently overlapping
+func (l *Level) Depenetrate(capsule collision.Capsule) (matrix.Vec3, bool) {
+	offset := matrix.Vec3Zero()
+	moved := false
+	l.eachOverlapping(capsule.Bounds(), func(tri *collision.Triangle) {
+		c := capsule.Translate(offset)
+		if depth, normal, ok := c.TrianglePenetration(*tri); ok {
+			offset.AddAssign(normal.Scale(depth))
+			moved = true
+		}
+	})
+	return offset, moved
+}
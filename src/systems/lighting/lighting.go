@@ -0,0 +1,73 @@
+/*****************************************************************************/
+/* lighting.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package lighting
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+)
+
+// Attach adds the light to the host and makes it follow the entity, use
+// Entity.LookAt to point it. The light is turned off while the entity is
+// inactive and removed when it is destroyed
+func Attach(host *engine.Host, entity *engine.Entity, light rendering.Light) *rendering.Light {
+	l := &light
+	l.Transform = &entity.Transform
+	l.Enabled = entity.IsActive()
+	host.Lights.Add(l)
+	entity.OnActivate.Add(func() { l.Enabled = true })
+	entity.OnDeactivate.Add(func() { l.Enabled = false })
+	entity.OnDestroy.Add(func() { host.Lights.Remove(l) })
+	return l
+}
+
+func NewDirectional(host *engine.Host, entity *engine.Entity, color matrix.Color, intensity float32) *rendering.Light {
+	return Attach(host, entity, rendering.NewDirectionalLight(
+		matrix.Vec3Forward(), color, intensity))
+}
+
+func NewPoint(host *engine.Host, entity *engine.Entity, color matrix.Color, intensity, lightRange float32) *rendering.Light {
+	return Attach(host, entity, rendering.NewPointLight(
+		matrix.Vec3Zero(), color, intensity, lightRange))
+}
+
+func NewSpot(host *engine.Host, entity *engine.Entity, color matrix.Color, intensity, lightRange, innerAngle, outerAngle float32) *rendering.Light {
+	return Attach(host, entity, rendering.NewSpotLight(matrix.Vec3Zero(),
+		matrix.Vec3Forward(), color, intensity, lightRange, innerAngle, outerAngle))
+}
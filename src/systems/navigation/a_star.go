@@ -38,74 +38,43 @@
 package navigation
 
 import (
-	"container/heap"
 	"kaiju/matrix"
+	"sync"
 )
 
+// AStar finds a path between two cells of the grid using the default
+// options, see AStarWithOptions
 func AStar(grid Grid, start, end matrix.Vec3i) []*Node {
-	if grid.IsBlocked(end) {
-		end = findNearestUnblockedNode(grid, end)
-		if end[matrix.Vx] == -1 && end[matrix.Vy] == -1 && end[matrix.Vz] == -1 {
-			return nil
-		}
-	}
-	openSet := make(PriorityQueue, 0)
-	closedSet := make(map[[3]int32]bool)
-	startNode := &Node{x: start[0], y: start[1], z: start[2]}
-	endNode := &Node{x: end[0], y: end[1], z: end[2]}
-	openSet = append(openSet, startNode)
-	heap.Init(&openSet)
-	for len(openSet) > 0 {
-		current := heap.Pop(&openSet).(*Node)
-		if current.x == endNode.x && current.y == endNode.y && current.z == endNode.z {
-			path := make([]*Node, 0)
-			for current != nil {
-				path = append(path, current)
-				current = current.parent
-			}
-			reversePath(path)
-			return path
-		}
-		closedSet[[3]int32{current.x, current.y, current.z}] = true
-		neighbors := getNeighbors(current, grid)
-		for _, neighbor := range neighbors {
-			if closedSet[[3]int32{neighbor.x, neighbor.y, neighbor.z}] {
-				continue
-			}
-			tentativeG := current.g + 1.0 // Assuming uniform cost
-			if !contains(openSet, neighbor) || tentativeG < neighbor.g {
-				neighbor.g = tentativeG
-				neighbor.h = heuristic(neighbor, endNode)
-				neighbor.f = neighbor.g + neighbor.h
-				neighbor.parent = current
-				if !contains(openSet, neighbor) {
-					heap.Push(&openSet, neighbor)
-				}
-			}
-		}
-	}
-	return nil
+	return AStarWithOptions(grid, start, end, DefaultAStarOptions())
 }
 
-func getNeighbors(node *Node, grid Grid) []*Node {
-	neighbors := make([]*Node, 0)
-	directions := [][3]int8{
-		{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1},
-		{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0}, {1, 0, 1}, {-1, 0, 1},
-		{1, 0, -1}, {-1, 0, -1}, {0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
-		{1, 1, 1}, {-1, 1, 1}, {1, -1, 1}, {-1, -1, 1}, {1, 1, -1}, {-1, 1, -1},
-		{1, -1, -1}, {-1, -1, -1},
+var searcherPool = sync.Pool{New: func() any { return NewSearcher() }}
+
+// AStarWithOptions finds the cheapest path between two cells of the grid.
+// When the end cell can not be entered the path leads to the nearest cell
+// that can be. The 2D connectivity options keep the path on the layer of the
+// start cell. Searches borrow a Searcher from a shared pool, code that runs
+// many searches can hold on to its own Searcher instead.
+func AStarWithOptions(grid Grid, start, end matrix.Vec3i, options AStarOptions) []*Node {
+	s := searcherPool.Get().(*Searcher)
+	defer searcherPool.Put(s)
+	cells := s.FindPath(grid, start, end, &options)
+	if cells == nil {
+		return nil
 	}
-	for _, dir := range directions {
-		x, y, z := node.x+int32(dir[0]), node.y+int32(dir[1]), node.z+int32(dir[2])
-		if isValid(x, y, z, grid) {
-			neighbors = append(neighbors, &Node{x: x, y: y, z: z})
+	path := make([]*Node, len(cells))
+	for i, c := range cells {
+		path[i] = &Node{x: c.X(), y: c.Y(), z: c.Z()}
+		if i > 0 {
+			path[i].parent = path[i-1]
+			path[i].g = path[i-1].g + stepCost(grid, cells[i-1], c, &options.Costs)
+			path[i].f = path[i].g
 		}
 	}
-	return neighbors
+	return path
 }
 
-func findNearestUnblockedNode(grid Grid, blockedEnd [3]int32) matrix.Vec3i {
+func findNearestUnblockedNode(grid Grid, blockedEnd [3]int32, costs *CostTable) matrix.Vec3i {
 	visited := make(map[[3]int32]bool)
 	queue := make([][3]int32, 0)
 	queue = append(queue, blockedEnd)
@@ -117,8 +86,8 @@ func findNearestUnblockedNode(grid Grid, blockedEnd [3]int32) matrix.Vec3i {
 		for _, dir := range directions {
 			x, y, z := currentNode[0]+dir[0], currentNode[1]+dir[1], currentNode[2]+dir[2]
 			neighbor := [3]int32{x, y, z}
-			if isValid(x, y, z, grid) && !visited[neighbor] {
-				if grid[x][y][z] == 0 { // Found an unblocked node
+			if grid.IsValid(neighbor) && !visited[neighbor] {
+				if grid.passable(x, y, z, costs) {
 					return neighbor
 				}
 				queue = append(queue, neighbor)
@@ -129,33 +98,3 @@ func findNearestUnblockedNode(grid Grid, blockedEnd [3]int32) matrix.Vec3i {
 	// Return a default value (e.g., (-1, -1, -1)) if no unblocked node is found
 	return [3]int32{-1, -1, -1}
 }
-
-func isValid(x, y, z int32, grid Grid) bool {
-	if x < 0 || x >= int32(len(grid)) || y < 0 || y >= int32(len(grid[0])) || z < 0 || z >= int32(len(grid[0][0])) {
-		return false
-	}
-	return grid[x][y][z] == 0 // Assuming 0 represents an open cell
-}
-
-func heuristic(a, b *Node) float64 {
-	// Euclidean distance as the heuristic
-	dx := a.x - b.x
-	dy := a.y - b.y
-	dz := a.z - b.z
-	return float64(dx*dx + dy*dy + dz*dz)
-}
-
-func reversePath(path []*Node) {
-	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-		path[i], path[j] = path[j], path[i]
-	}
-}
-
-func contains(nodes PriorityQueue, node *Node) bool {
-	for _, n := range nodes {
-		if n.x == node.x && n.y == node.y && n.z == node.z {
-			return true
-		}
-	}
-	return false
-}
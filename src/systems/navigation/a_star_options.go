@@ -0,0 +1,254 @@
+/*****************************************************************************/
+/* a_star_options.go                                                         */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math"
+)
+
+// Connectivity selects which neighbouring cells a path can step to. The 2D
+// options search the X/Z plane at the height of the start cell.
+type Connectivity uint8
+
+const (
+	// Connect26 steps through faces, edges and corners of the cell
+	Connect26 Connectivity = iota
+	// Connect6 steps through the faces of the cell only
+	Connect6
+	// Connect4 steps along the X and Z axes of a single layer
+	Connect4
+	// Connect8 steps along the X and Z axes and their diagonals
+	Connect8
+)
+
+// CornerRule decides if a diagonal step can pass by blocked cells
+type CornerRule uint8
+
+const (
+	// CornerCutAllow lets diagonal steps brush past any blocked cell
+	CornerCutAllow CornerRule = iota
+	// CornerCutNever requires every cell a diagonal step brushes past to be
+	// passable, so paths never clip the corner of a wall
+	CornerCutNever
+	// CornerCutIfOneOpen allows a diagonal step as long as one of the
+	// straight steps that make it up is passable, so paths can not squeeze
+	// between two blocked cells that touch at a corner
+	CornerCutIfOneOpen
+)
+
+// Impassable is the cost of a cell type that can not be walked through
+var Impassable = math.Inf(1)
+
+// CostTable holds the cost of entering a cell for each block type of the
+// grid. The cost of a step is its length multiplied by the cost of the cell
+// being entered.
+type CostTable [256]float64
+
+// DefaultCostTable matches the grid blocking rules, open cells cost 1 and
+// every other block type is impassable
+func DefaultCostTable() CostTable {
+	var t CostTable
+	for i := range t {
+		t[i] = Impassable
+	}
+	t.SetCost(0, 1)
+	return t
+}
+
+func (t *CostTable) SetCost(blockType int8, cost float64) { t[uint8(blockType)] = cost }
+func (t *CostTable) Cost(blockType int8) float64          { return t[uint8(blockType)] }
+
+func (t *CostTable) IsPassable(blockType int8) bool {
+	c := t[uint8(blockType)]
+	return c > 0 && !math.IsInf(c, 1)
+}
+
+// minCost is the cheapest passable cost, heuristics are scaled by it so
+// they never overestimate the cost of the remaining path
+func (t *CostTable) minCost() float64 {
+	low := math.Inf(1)
+	for _, c := range t {
+		if c > 0 && c < low {
+			low = c
+		}
+	}
+	if math.IsInf(low, 1) {
+		return 1
+	}
+	return low
+}
+
+// Heuristic estimates the length of the path between two cells assuming
+// every cell costs 1, it must not overestimate for the search to find the
+// cheapest path
+type Heuristic func(a, b matrix.Vec3i) float64
+
+func cellDelta(a, b matrix.Vec3i) (float64, float64, float64) {
+	return math.Abs(float64(a.X() - b.X())),
+		math.Abs(float64(a.Y() - b.Y())),
+		math.Abs(float64(a.Z() - b.Z()))
+}
+
+// EuclideanHeuristic is the straight line distance between the cells
+func EuclideanHeuristic(a, b matrix.Vec3i) float64 {
+	dx, dy, dz := cellDelta(a, b)
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+// ManhattanHeuristic is exact for 4 and 6 connectivity on open ground
+func ManhattanHeuristic(a, b matrix.Vec3i) float64 {
+	dx, dy, dz := cellDelta(a, b)
+	return dx + dy + dz
+}
+
+// OctileHeuristic is exact for 8 and 26 connectivity on open ground
+func OctileHeuristic(a, b matrix.Vec3i) float64 {
+	dx, dy, dz := cellDelta(a, b)
+	hi := max(dx, dy, dz)
+	lo := min(dx, dy, dz)
+	mid := dx + dy + dz - hi - lo
+	return hi + (math.Sqrt2-1)*mid + (math.Sqrt(3)-math.Sqrt2)*lo
+}
+
+type AStarOptions struct {
+	Connectivity Connectivity
+	Corners      CornerRule
+	Costs        CostTable
+	// Heuristic is picked from the connectivity when left nil
+	Heuristic Heuristic
+}
+
+// DefaultAStarOptions searches all 26 neighbours with uniform costs
+func DefaultAStarOptions() AStarOptions {
+	return AStarOptions{
+		Connectivity: Connect26,
+		Corners:      CornerCutAllow,
+		Costs:        DefaultCostTable(),
+	}
+}
+
+func (o *AStarOptions) heuristic() Heuristic {
+	if o.Heuristic != nil {
+		return o.Heuristic
+	}
+	switch o.Connectivity {
+	case Connect4, Connect6:
+		return ManhattanHeuristic
+	default:
+		return OctileHeuristic
+	}
+}
+
+type stepDirection struct {
+	offset [3]int32
+	length float64
+}
+
+var connectivityDirections = func() [4][]stepDirection {
+	var out [4][]stepDirection
+	for x := int32(-1); x <= 1; x++ {
+		for y := int32(-1); y <= 1; y++ {
+			for z := int32(-1); z <= 1; z++ {
+				axes := x*x + y*y + z*z
+				if axes == 0 {
+					continue
+				}
+				d := stepDirection{[3]int32{x, y, z}, math.Sqrt(float64(axes))}
+				out[Connect26] = append(out[Connect26], d)
+				if axes == 1 {
+					out[Connect6] = append(out[Connect6], d)
+				}
+				if y == 0 {
+					out[Connect8] = append(out[Connect8], d)
+					if axes == 1 {
+						out[Connect4] = append(out[Connect4], d)
+					}
+				}
+			}
+		}
+	}
+	return out
+}()
+
+func (g Grid) passable(x, y, z int32, costs *CostTable) bool {
+	if x < 0 || x >= int32(len(g)) || y < 0 || y >= int32(len(g[0])) || z < 0 || z >= int32(len(g[0][0])) {
+		return false
+	}
+	return costs.IsPassable(g[x][y][z])
+}
+
+// canStep applies the corner rule to a step from the cell at x, y, z
+func (g Grid) canStep(x, y, z int32, offset [3]int32, rule CornerRule, costs *CostTable) bool {
+	if rule == CornerCutAllow {
+		return true
+	}
+	// Every cell the step brushes past is the start moved by a non-empty,
+	// proper subset of the step offset axes
+	axes := 0
+	for _, o := range offset {
+		if o != 0 {
+			axes++
+		}
+	}
+	if axes < 2 {
+		return true
+	}
+	anyOpen := false
+	for mask := 1; mask < 7; mask++ {
+		var sub [3]int32
+		count := 0
+		for i := 0; i < 3; i++ {
+			if mask&(1<<i) != 0 && offset[i] != 0 {
+				sub[i] = offset[i]
+				count++
+			}
+		}
+		if count == 0 || count == axes {
+			continue
+		}
+		open := g.passable(x+sub[0], y+sub[1], z+sub[2], costs)
+		if rule == CornerCutNever && !open {
+			return false
+		}
+		if count == 1 && open {
+			anyOpen = true
+		}
+	}
+	return rule == CornerCutNever || anyOpen
+}
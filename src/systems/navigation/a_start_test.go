@@ -38,6 +38,7 @@
 package navigation
 
 import (
+	"container/heap"
 	"kaiju/matrix"
 	"testing"
 )
@@ -67,3 +68,71 @@ func TestAStar(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestAStarWeightedCosts(t *testing.T) {
+	const mud = 2
+	grid := NewGrid(5, 1, 3)
+	for x := int32(1); x < 4; x++ {
+		grid.BlockCell(matrix.Vec3i{x, 0, 1}, mud)
+	}
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect4
+	options.Costs.SetCost(mud, 5)
+	path := AStarWithOptions(grid, matrix.Vec3i{0, 0, 1}, matrix.Vec3i{4, 0, 1}, options)
+	if len(path) != 7 {
+		t.Fatalf("expected the path to go around the mud, got %d steps", len(path))
+	}
+	for _, n := range path {
+		if grid.BlockedType(n.XYZ()) == mud {
+			t.Fatalf("path entered mud at %v", n.XYZ())
+		}
+	}
+	options.Costs.SetCost(mud, 1.5)
+	path = AStarWithOptions(grid, matrix.Vec3i{0, 0, 1}, matrix.Vec3i{4, 0, 1}, options)
+	if len(path) != 5 || path[len(path)-1].Cost() != 5.5 {
+		t.Fatalf("expected the path to cross the cheap mud, got %d steps", len(path))
+	}
+}
+
+func TestAStarCornerCutting(t *testing.T) {
+	grid := NewGrid(2, 1, 2)
+	grid.BlockCell(matrix.Vec3i{1, 0, 0}, 1)
+	start, end := matrix.Vec3i{0, 0, 0}, matrix.Vec3i{1, 0, 1}
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect8
+	if path := AStarWithOptions(grid, start, end, options); len(path) != 2 {
+		t.Fatalf("expected a diagonal step, got %d steps", len(path))
+	}
+	options.Corners = CornerCutIfOneOpen
+	if path := AStarWithOptions(grid, start, end, options); len(path) != 2 {
+		t.Fatalf("expected a diagonal step past one open cell, got %d steps", len(path))
+	}
+	options.Corners = CornerCutNever
+	if path := AStarWithOptions(grid, start, end, options); len(path) != 3 {
+		t.Fatalf("expected the path to step around the corner, got %d steps", len(path))
+	}
+	grid.BlockCell(matrix.Vec3i{0, 0, 1}, 1)
+	options.Corners = CornerCutIfOneOpen
+	if path := AStarWithOptions(grid, start, end, options); path != nil {
+		t.Fatal("expected no path between the touching corners")
+	}
+}
+
+// TestPriorityQueueOrdersPathNodes keeps the deprecated queue working with
+// the nodes of the paths AStar returns
+func TestPriorityQueueOrdersPathNodes(t *testing.T) {
+	grid := Grid{{{0, 0, 0, 0}}}
+	path := AStar(grid, matrix.Vec3i{0, 0, 0}, matrix.Vec3i{0, 0, 3})
+	if len(path) != 4 {
+		t.Fatalf("expected a path of 4 nodes, got %d", len(path))
+	}
+	pq := PriorityQueue{}
+	for i := len(path) - 1; i >= 0; i-- {
+		heap.Push(&pq, path[i])
+	}
+	for i := range path {
+		if n := heap.Pop(&pq).(*Node); n != path[i] {
+			t.Fatalf("expected node %d of the path, got %v", i, n.XYZ())
+		}
+	}
+}
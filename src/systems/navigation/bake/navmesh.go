@@ -0,0 +1,77 @@
+/*****************************************************************************/
+/* navmesh.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package bake
+
+import (
+	"kaiju/matrix"
+	"kaiju/rendering/loaders"
+	"kaiju/systems/navigation"
+)
+
+// Part is a loaded model placed into the level
+type Part struct {
+	Result    loaders.Result
+	Transform matrix.Mat4
+}
+
+// AddResult appends the triangles of every mesh in the loaded model to the
+// navmesh input after moving them into world space
+func AddResult(input *navigation.NavMeshInput, result loaders.Result, transform matrix.Mat4) {
+	for i := range result.Meshes {
+		m := &result.Meshes[i]
+		positions := make([]matrix.Vec3, len(m.Verts))
+		for j := range m.Verts {
+			positions[j] = m.Verts[j].Position
+		}
+		input.AddMesh(positions, m.Indexes, transform)
+	}
+}
+
+// Input collects the triangles of every level part in world space
+func Input(parts ...Part) *navigation.NavMeshInput {
+	input := &navigation.NavMeshInput{}
+	for i := range parts {
+		AddResult(input, parts[i].Result, parts[i].Transform)
+	}
+	return input
+}
+
+// NavMesh builds a navmesh for the given level parts
+func NavMesh(cfg navigation.NavMeshConfig, parts ...Part) (*navigation.NavMesh, error) {
+	return navigation.BuildNavMesh(Input(parts...), cfg)
+}
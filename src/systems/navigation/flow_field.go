@@ -0,0 +1,334 @@
+/*****************************************************************************/
+/* flow_field.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"container/heap"
+	"kaiju/matrix"
+	"math"
+)
+
+const noFlow = -1
+
+type flowEntry struct {
+	cost  float32
+	index int32
+}
+
+type flowQueue []flowEntry
+
+func (q flowQueue) Len() int           { return len(q) }
+func (q flowQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q flowQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x any)        { *q = append(*q, x.(flowEntry)) }
+
+func (q *flowQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// FlowField stores, for every cell of a grid, the cost of the cheapest path
+// to the nearest goal and the direction to step in to follow it. Any number
+// of agents can read the same field to move towards the goals, so it pays
+// off when many agents share a destination.
+type FlowField struct {
+	grid        Grid
+	options     AStarOptions
+	directions  []stepDirection
+	integration []float32
+	flow        []int8
+	goals       []matrix.Vec3i
+	queue       flowQueue
+	width       int32
+	height      int32
+	depth       int32
+}
+
+// NewFlowField creates an empty field over the grid, the connectivity,
+// corner rule and costs of the options decide how agents can move. The
+// heuristic is not used.
+func NewFlowField(grid Grid, options AStarOptions) *FlowField {
+	f := &FlowField{
+		grid:       grid,
+		options:    options,
+		directions: connectivityDirections[options.Connectivity],
+		width:      int32(grid.Width()),
+		height:     int32(grid.Height()),
+		depth:      int32(grid.Depth()),
+	}
+	count := f.width * f.height * f.depth
+	f.integration = make([]float32, count)
+	f.flow = make([]int8, count)
+	f.reset()
+	return f
+}
+
+func (f *FlowField) Grid() Grid            { return f.grid }
+func (f *FlowField) Goals() []matrix.Vec3i { return f.goals }
+func (f *FlowField) Options() AStarOptions { return f.options }
+
+func (f *FlowField) reset() {
+	for i := range f.integration {
+		f.integration[i] = float32(math.Inf(1))
+		f.flow[i] = noFlow
+	}
+}
+
+func (f *FlowField) index(p matrix.Vec3i) int32 {
+	return (p[0]*f.height+p[1])*f.depth + p[2]
+}
+
+func (f *FlowField) coords(index int32) matrix.Vec3i {
+	layer := f.height * f.depth
+	r := index % layer
+	return matrix.Vec3i{index / layer, r / f.depth, r % f.depth}
+}
+
+// SetGoals replaces the goals and rebuilds the whole field, goals that can
+// not be entered are ignored
+func (f *FlowField) SetGoals(goals ...matrix.Vec3i) {
+	f.goals = append(f.goals[:0], goals...)
+	f.Rebuild()
+}
+
+// Rebuild integrates the field from scratch with a Dijkstra search that
+// starts from every goal at once
+func (f *FlowField) Rebuild() {
+	f.reset()
+	f.queue = f.queue[:0]
+	for _, g := range f.goals {
+		if f.grid.passable(g[0], g[1], g[2], &f.options.Costs) {
+			i := f.index(g)
+			f.integration[i] = 0
+			f.queue = append(f.queue, flowEntry{0, i})
+		}
+	}
+	heap.Init(&f.queue)
+	f.integrate(nil)
+	for i := range f.flow {
+		f.updateFlow(int32(i))
+	}
+}
+
+// stepCost is the cost of stepping from the cell along the direction, it
+// returns false when the step is not allowed
+func (f *FlowField) stepCost(from matrix.Vec3i, dir *stepDirection) (float32, bool) {
+	to := matrix.Vec3i{from[0] + dir.offset[0], from[1] + dir.offset[1], from[2] + dir.offset[2]}
+	costs := &f.options.Costs
+	if !f.grid.passable(to[0], to[1], to[2], costs) ||
+		!f.grid.canStep(from[0], from[1], from[2], dir.offset, f.options.Corners, costs) {
+		return 0, false
+	}
+	return float32(dir.length * costs.Cost(f.grid[to[0]][to[1]][to[2]])), true
+}
+
+// integrate runs the Dijkstra search backwards from the queued cells, each
+// cell is reached from the neighbours that can step onto it
+func (f *FlowField) integrate(changed map[int32]bool) {
+	costs := &f.options.Costs
+	for f.queue.Len() > 0 {
+		e := heap.Pop(&f.queue).(flowEntry)
+		if e.cost > f.integration[e.index] {
+			continue
+		}
+		if changed != nil {
+			changed[e.index] = true
+		}
+		to := f.coords(e.index)
+		for i := range f.directions {
+			dir := &f.directions[i]
+			from := matrix.Vec3i{to[0] - dir.offset[0], to[1] - dir.offset[1], to[2] - dir.offset[2]}
+			if !f.grid.passable(from[0], from[1], from[2], costs) {
+				continue
+			}
+			step, ok := f.stepCost(from, dir)
+			if !ok {
+				continue
+			}
+			fi := f.index(from)
+			if cost := e.cost + step; cost < f.integration[fi] {
+				f.integration[fi] = cost
+				heap.Push(&f.queue, flowEntry{cost, fi})
+			}
+		}
+	}
+}
+
+// updateFlow points the cell at the neighbour that leads to the cheapest
+// path, goals and unreachable cells have no direction
+func (f *FlowField) updateFlow(index int32) {
+	f.flow[index] = noFlow
+	cost := f.integration[index]
+	if cost == 0 || math.IsInf(float64(cost), 1) {
+		return
+	}
+	from := f.coords(index)
+	best := cost
+	for i := range f.directions {
+		dir := &f.directions[i]
+		step, ok := f.stepCost(from, dir)
+		if !ok {
+			continue
+		}
+		to := matrix.Vec3i{from[0] + dir.offset[0], from[1] + dir.offset[1], from[2] + dir.offset[2]}
+		if total := f.integration[f.index(to)] + step; total <= best {
+			best = total
+			f.flow[index] = int8(i)
+		}
+	}
+}
+
+// Cost is the cost of the cheapest path from the cell to a goal, it is
+// infinite for cells that can not reach any goal
+func (f *FlowField) Cost(cell matrix.Vec3i) float64 {
+	if !f.grid.IsValid(cell) {
+		return math.Inf(1)
+	}
+	return float64(f.integration[f.index(cell)])
+}
+
+// Direction is the step to take from the cell to get closer to a goal, it
+// returns false for goals and cells that can not reach a goal
+func (f *FlowField) Direction(cell matrix.Vec3i) (matrix.Vec3i, bool) {
+	if !f.grid.IsValid(cell) {
+		return matrix.Vec3i{}, false
+	}
+	d := f.flow[f.index(cell)]
+	if d == noFlow {
+		return matrix.Vec3i{}, false
+	}
+	return matrix.Vec3i(f.directions[d].offset), true
+}
+
+// IsGoal reports if the cell is one of the goals that can be reached
+func (f *FlowField) IsGoal(cell matrix.Vec3i) bool {
+	return f.grid.IsValid(cell) && f.integration[f.index(cell)] == 0
+}
+
+// BlockCell changes a cell of the grid and repairs the part of the field
+// that depended on it instead of rebuilding everything. The cells whose
+// path ran through or past the changed cell are cleared and filled back in
+// from their untouched neighbours.
+func (f *FlowField) BlockCell(pos matrix.Vec3i, blockType int8) {
+	f.grid.BlockCell(pos, blockType)
+	cleared := map[int32]bool{}
+	stack := []int32{}
+	mark := func(i int32) {
+		if !cleared[i] {
+			cleared[i] = true
+			stack = append(stack, i)
+		}
+	}
+	mark(f.index(pos))
+	for i := range connectivityDirections[Connect26] {
+		n := pos
+		for a, o := range connectivityDirections[Connect26][i].offset {
+			n[a] += o
+		}
+		if f.grid.IsValid(n) {
+			mark(f.index(n))
+		}
+	}
+	// Everything that flows into a cleared cell depends on it
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		to := f.coords(i)
+		for d := range f.directions {
+			o := f.directions[d].offset
+			from := matrix.Vec3i{to[0] - o[0], to[1] - o[1], to[2] - o[2]}
+			if f.grid.IsValid(from) && f.flow[f.index(from)] == int8(d) {
+				mark(f.index(from))
+			}
+		}
+	}
+	isGoal := map[int32]bool{}
+	for _, g := range f.goals {
+		if f.grid.passable(g[0], g[1], g[2], &f.options.Costs) {
+			isGoal[f.index(g)] = true
+		}
+	}
+	f.queue = f.queue[:0]
+	for i := range cleared {
+		f.integration[i] = float32(math.Inf(1))
+		f.flow[i] = noFlow
+		if isGoal[i] {
+			f.integration[i] = 0
+		}
+	}
+	// Seed every cleared cell from the best of its untouched neighbours
+	for i := range cleared {
+		from := f.coords(i)
+		if !f.grid.passable(from[0], from[1], from[2], &f.options.Costs) {
+			continue
+		}
+		for d := range f.directions {
+			dir := &f.directions[d]
+			step, ok := f.stepCost(from, dir)
+			if !ok {
+				continue
+			}
+			to := matrix.Vec3i{from[0] + dir.offset[0], from[1] + dir.offset[1], from[2] + dir.offset[2]}
+			if ti := f.index(to); !cleared[ti] {
+				f.integration[i] = min(f.integration[i], f.integration[ti]+step)
+			}
+		}
+		if !math.IsInf(float64(f.integration[i]), 1) {
+			f.queue = append(f.queue, flowEntry{f.integration[i], i})
+		}
+	}
+	heap.Init(&f.queue)
+	changed := map[int32]bool{}
+	f.integrate(changed)
+	for i := range cleared {
+		changed[i] = true
+	}
+	for i := range changed {
+		f.updateFlow(i)
+		to := f.coords(i)
+		for d := range f.directions {
+			o := f.directions[d].offset
+			n := matrix.Vec3i{to[0] - o[0], to[1] - o[1], to[2] - o[2]}
+			if f.grid.IsValid(n) {
+				f.updateFlow(f.index(n))
+			}
+		}
+	}
+}
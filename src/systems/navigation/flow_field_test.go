@@ -0,0 +1,131 @@
+/*****************************************************************************/
+/* flow_field_test.go                                                        */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func checkFlowField(t *testing.T, f *FlowField, goal matrix.Vec3i) {
+	t.Helper()
+	options := f.Options()
+	s := NewSearcher()
+	s.DisableJumpPoints = true
+	grid := f.Grid()
+	for x := int32(0); x < int32(grid.Width()); x++ {
+		for z := int32(0); z < int32(grid.Depth()); z++ {
+			cell := matrix.Vec3i{x, 0, z}
+			if !grid.passable(x, 0, z, &options.Costs) {
+				continue
+			}
+			path := s.FindPath(grid, cell, goal, &options)
+			cost := f.Cost(cell)
+			if path == nil {
+				if !math.IsInf(cost, 1) {
+					t.Fatalf("cell %v has cost %f but can not reach the goal", cell, cost)
+				}
+				continue
+			}
+			if math.Abs(cost-s.PathCost()) > 1e-3 {
+				t.Fatalf("cell %v has cost %f, expected %f", cell, cost, s.PathCost())
+			}
+			// Following the directions must reach the goal
+			for steps := 0; cell != goal; steps++ {
+				dir, ok := f.Direction(cell)
+				if !ok || steps > grid.Width()*grid.Depth() {
+					t.Fatalf("lost the flow at %v on the way to %v", cell, goal)
+				}
+				cell = matrix.Vec3i{cell[0] + dir[0], cell[1] + dir[1], cell[2] + dir[2]}
+			}
+		}
+	}
+}
+
+func TestFlowFieldMatchesAStar(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	for _, conn := range []Connectivity{Connect4, Connect8} {
+		options := DefaultAStarOptions()
+		options.Connectivity = conn
+		options.Corners = CornerCutNever
+		options.Costs.SetCost(2, 3)
+		grid := randomGrid(rng, 24, 1, 24, 0.25)
+		for i := 0; i < 60; i++ {
+			grid[rng.Intn(24)][0][rng.Intn(24)] = 2
+		}
+		goal := matrix.Vec3i{12, 0, 12}
+		grid.BlockCell(goal, 0)
+		f := NewFlowField(grid, options)
+		f.SetGoals(goal)
+		checkFlowField(t, f, goal)
+		// The repaired field must match one built from scratch
+		for i := 0; i < 20; i++ {
+			cell := matrix.Vec3i{int32(rng.Intn(24)), 0, int32(rng.Intn(24))}
+			if cell != goal {
+				f.BlockCell(cell, int8(rng.Intn(3)))
+			}
+			fresh := NewFlowField(grid, options)
+			fresh.SetGoals(goal)
+			for j := range fresh.integration {
+				if math.Abs(float64(fresh.integration[j]-f.integration[j])) > 1e-3 &&
+					!(math.IsInf(float64(fresh.integration[j]), 1) && math.IsInf(float64(f.integration[j]), 1)) {
+					t.Fatalf("cell %v repaired to %f, rebuilt to %f",
+						f.coords(int32(j)), f.integration[j], fresh.integration[j])
+				}
+			}
+		}
+		checkFlowField(t, f, goal)
+	}
+}
+
+func TestFlowFieldMultipleGoals(t *testing.T) {
+	grid := NewGrid(20, 1, 5)
+	f := NewFlowField(grid, DefaultAStarOptions())
+	f.SetGoals(matrix.Vec3i{0, 0, 2}, matrix.Vec3i{19, 0, 2})
+	if dir, ok := f.Direction(matrix.Vec3i{3, 0, 2}); !ok || dir.X() != -1 {
+		t.Fatalf("expected to flow towards the left goal, got %v", dir)
+	}
+	if dir, ok := f.Direction(matrix.Vec3i{16, 0, 2}); !ok || dir.X() != 1 {
+		t.Fatalf("expected to flow towards the right goal, got %v", dir)
+	}
+	if !f.IsGoal(matrix.Vec3i{19, 0, 2}) {
+		t.Fatal("expected the right goal to be a goal")
+	}
+}
@@ -0,0 +1,145 @@
+/*****************************************************************************/
+/* grid_bake.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+)
+
+// GridBlocked is the block type used by BuildGrid for cells an agent can
+// not stand in
+const GridBlocked int8 = 1
+
+// BakedGrid is a navigation grid placed in the world, every cell is a cube
+// CellSize wide and the first cell starts at Origin
+type BakedGrid struct {
+	Grid     Grid
+	Origin   matrix.Vec3
+	CellSize float32
+}
+
+// CellCenter is the world position of the middle of the cell
+func (b *BakedGrid) CellCenter(cell matrix.Vec3i) matrix.Vec3 {
+	return CellCenter(cell, b.Origin, b.CellSize)
+}
+
+// WorldToCell finds the cell containing the point, it returns false when
+// the point is outside of the grid
+func (b *BakedGrid) WorldToCell(point matrix.Vec3) (matrix.Vec3i, bool) {
+	cell := matrix.Vec3i{}
+	for a := range cell {
+		cell[a] = int32(matrix.Floor((point[a] - b.Origin[a]) / b.CellSize))
+	}
+	return cell, b.Grid.IsValid(cell)
+}
+
+// GridBakeConfig describes the agent that will walk the baked grid along
+// with the resolution used to voxelize the level, see NavMeshConfig for the
+// meaning of each of the fields
+type GridBakeConfig struct {
+	CellSize    float32
+	CellHeight  float32
+	AgentRadius float32
+	AgentHeight float32
+	MaxClimb    float32
+	MaxSlope    float32
+}
+
+func DefaultGridBakeConfig() GridBakeConfig {
+	nav := DefaultNavMeshConfig()
+	return GridBakeConfig{
+		CellSize:    nav.CellSize,
+		CellHeight:  nav.CellHeight,
+		AgentRadius: nav.AgentRadius,
+		AgentHeight: nav.AgentHeight,
+		MaxClimb:    nav.MaxClimb,
+		MaxSlope:    nav.MaxSlope,
+	}
+}
+
+func (c GridBakeConfig) navMeshConfig() NavMeshConfig {
+	return NavMeshConfig{
+		CellSize:    c.CellSize,
+		CellHeight:  c.CellHeight,
+		AgentRadius: c.AgentRadius,
+		AgentHeight: c.AgentHeight,
+		MaxClimb:    c.MaxClimb,
+		MaxSlope:    c.MaxSlope,
+	}
+}
+
+// BuildGrid voxelizes the input triangles into a grid of cubes CellSize
+// wide. A cell is left open when it holds a surface that is flat enough for
+// the configured agent and has enough head room above it, every other cell
+// is set to GridBlocked. CellHeight is only used for the precision of the
+// voxelization.
+func BuildGrid(input *NavMeshInput, bakeConfig GridBakeConfig) (*BakedGrid, error) {
+	cfg := bakeConfig.navMeshConfig()
+	hf, cf, err := voxelize(input, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := input.bounds()
+	height := int32(matrix.Ceil((hi.Y()-lo.Y())/cfg.CellSize)) + 1
+	grid := NewGrid(int(hf.width), int(height), int(hf.depth))
+	for x := range grid {
+		for y := range grid[x] {
+			for z := range grid[x][y] {
+				grid[x][y][z] = GridBlocked
+			}
+		}
+	}
+	open := 0
+	for i := range cf.cells {
+		c := &cf.cells[i]
+		if c.poly == removedCell {
+			continue
+		}
+		y := int32(matrix.Floor(float32(c.floor) * cfg.CellHeight / cfg.CellSize))
+		grid[c.x][min(y, height-1)][c.z] = 0
+		open++
+	}
+	if open == 0 {
+		return nil, ErrNavMeshNoSurface
+	}
+	return &BakedGrid{
+		Grid:     grid,
+		Origin:   hf.origin,
+		CellSize: cfg.CellSize,
+	}, nil
+}
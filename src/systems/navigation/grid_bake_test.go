@@ -0,0 +1,148 @@
+/*****************************************************************************/
+/* grid_bake_test.go                                                         */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"kaiju/matrix"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestBuildGrid(t *testing.T) {
+	input := &NavMeshInput{}
+	addBox(input, matrix.Vec3{0, -0.2, 0}, matrix.Vec3{10, 0, 10})
+	addBox(input, matrix.Vec3{4, 0, 4}, matrix.Vec3{6, 3, 6})
+	cfg := DefaultGridBakeConfig()
+	cfg.CellSize = 0.5
+	cfg.AgentRadius = 0.25
+	baked, err := BuildGrid(input, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	floor, ok := baked.WorldToCell(matrix.Vec3{1, 0, 1})
+	if !ok {
+		t.Fatal("expected the floor to be inside of the grid")
+	}
+	if baked.Grid.IsBlocked(floor) {
+		t.Fatalf("expected the floor cell %v to be open", floor)
+	}
+	wall, _ := baked.WorldToCell(matrix.Vec3{4.1, 0, 5})
+	wall[matrix.Vy] = floor.Y()
+	if !baked.Grid.IsBlocked(wall) {
+		t.Fatalf("expected the cell %v at the side of the pillar to be blocked", wall)
+	}
+	top, _ := baked.WorldToCell(matrix.Vec3{5, 3.1, 5})
+	if baked.Grid.IsBlocked(top) {
+		t.Fatalf("expected the top of the pillar %v to be open", top)
+	}
+	end := matrix.Vec3i{floor.X() + 16, floor.Y(), floor.Z() + 16}
+	path := AStar(baked.Grid, floor, end)
+	if len(path) == 0 {
+		t.Fatal("expected a path around the pillar")
+	}
+	for _, n := range path {
+		p := baked.CellCenter(n.XYZ())
+		if p.X() > 4 && p.X() < 6 && p.Z() > 4 && p.Z() < 6 {
+			t.Fatalf("path went through the pillar at %v", n.XYZ())
+		}
+	}
+}
+
+func TestBakedGridFileRoundTrip(t *testing.T) {
+	baked := &BakedGrid{
+		Grid:     randomGrid(rand.New(rand.NewSource(3)), 17, 3, 11, 0.3),
+		Origin:   matrix.Vec3{-4, 1.5, 2},
+		CellSize: 0.25,
+	}
+	var buf bytes.Buffer
+	if err := baked.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBakedGrid(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Origin != baked.Origin || read.CellSize != baked.CellSize {
+		t.Fatalf("placement changed, got %v %f", read.Origin, read.CellSize)
+	}
+	for x := range baked.Grid {
+		for y := range baked.Grid[x] {
+			if !slices.Equal(baked.Grid[x][y], read.Grid[x][y]) {
+				t.Fatalf("cells changed at %d, %d", x, y)
+			}
+		}
+	}
+	if _, err := ReadBakedGrid(bytes.NewReader([]byte("not a grid at all"))); !errors.Is(err, ErrGridFileInvalid) {
+		t.Fatalf("expected an invalid file error, got %v", err)
+	}
+}
+
+func TestReadBakedGridRejectsBadSizes(t *testing.T) {
+	write := func(width, height, depth uint32, payload []byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		header := gridFileHeader{
+			Magic:   gridFileMagic,
+			Version: gridFileVersion,
+			Width:   width,
+			Height:  height,
+			Depth:   depth,
+		}
+		binary.Write(&buf, binary.LittleEndian, &header)
+		buf.Write(payload)
+		return &buf
+	}
+	// A single run claiming every cell of a grid far too large to allocate
+	huge := binary.AppendUvarint(nil, math.MaxUint32*uint64(math.MaxUint32))
+	if _, err := ReadBakedGrid(write(math.MaxUint32, math.MaxUint32, 2, append(huge, 0))); !errors.Is(err, ErrGridFileInvalid) {
+		t.Fatalf("expected an oversized grid to be rejected, got %v", err)
+	}
+	// A reasonable size with runs that stop short of covering it
+	short := append(binary.AppendUvarint(nil, 10), 1)
+	if _, err := ReadBakedGrid(write(64, 64, 64, short)); !errors.Is(err, ErrGridFileInvalid) {
+		t.Fatalf("expected a truncated grid to be rejected, got %v", err)
+	}
+	full := append(binary.AppendUvarint(nil, 8), 1)
+	if grid, err := ReadBakedGrid(write(2, 2, 2, full)); err != nil || !grid.Grid.IsBlocked(matrix.Vec3i{1, 1, 1}) {
+		t.Fatalf("expected the small grid to be read, got %v", err)
+	}
+}
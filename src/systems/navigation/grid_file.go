@@ -0,0 +1,205 @@
+/*****************************************************************************/
+/* grid_file.go                                                              */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"kaiju/matrix"
+	"os"
+)
+
+// GridFileExtension is the extension of baked grid files in a project
+const GridFileExtension = ".navgrid"
+
+const gridFileVersion = 1
+
+// maxGridFileCells keeps a damaged header from asking for more memory than
+// any real level would need
+const maxGridFileCells = 1 << 30
+
+var gridFileMagic = [4]byte{'K', 'N', 'V', 'G'}
+
+var (
+	ErrGridFileInvalid = errors.New("file is not a navigation grid")
+	ErrGridFileVersion = errors.New("navigation grid file version is not supported")
+)
+
+type gridFileHeader struct {
+	Magic    [4]byte
+	Version  uint32
+	Width    uint32
+	Height   uint32
+	Depth    uint32
+	CellSize float32
+	Origin   [3]float32
+}
+
+type gridFileRun struct {
+	count uint64
+	value int8
+}
+
+// Write stores the grid in a compact binary form. The cells are written in
+// x, y, z order as runs of a count followed by the block type, which keeps
+// the large open and blocked areas of a baked level small.
+func (b *BakedGrid) Write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	header := gridFileHeader{
+		Magic:    gridFileMagic,
+		Version:  gridFileVersion,
+		Width:    uint32(b.Grid.Width()),
+		Height:   uint32(b.Grid.Height()),
+		Depth:    uint32(b.Grid.Depth()),
+		CellSize: b.CellSize,
+		Origin:   [3]float32{float32(b.Origin.X()), float32(b.Origin.Y()), float32(b.Origin.Z())},
+	}
+	if err := binary.Write(out, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	var buf [binary.MaxVarintLen64 + 1]byte
+	flush := func(count uint64, value int8) error {
+		n := binary.PutUvarint(buf[:], count)
+		buf[n] = byte(value)
+		_, err := out.Write(buf[:n+1])
+		return err
+	}
+	count, value := uint64(0), int8(0)
+	for x := range b.Grid {
+		for y := range b.Grid[x] {
+			for _, cell := range b.Grid[x][y] {
+				if count > 0 && cell != value {
+					if err := flush(count, value); err != nil {
+						return err
+					}
+					count = 0
+				}
+				value = cell
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		if err := flush(count, value); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Save writes the grid to a file, see Write for the format
+func (b *BakedGrid) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadBakedGrid reads a grid stored with BakedGrid.Write
+func ReadBakedGrid(r io.Reader) (*BakedGrid, error) {
+	in := bufio.NewReader(r)
+	var header gridFileHeader
+	if err := binary.Read(in, binary.LittleEndian, &header); err != nil {
+		return nil, ErrGridFileInvalid
+	}
+	if header.Magic != gridFileMagic {
+		return nil, ErrGridFileInvalid
+	}
+	if header.Version != gridFileVersion {
+		return nil, ErrGridFileVersion
+	}
+	if header.Width == 0 || header.Height == 0 || header.Depth == 0 {
+		return nil, ErrGridFileInvalid
+	}
+	area := uint64(header.Width) * uint64(header.Height)
+	if area > maxGridFileCells || area*uint64(header.Depth) > maxGridFileCells {
+		return nil, ErrGridFileInvalid
+	}
+	// The runs are read before the grid is allocated so that the size in the
+	// header has to be backed by the data that follows it
+	runs := make([]gridFileRun, 0)
+	remaining := area * uint64(header.Depth)
+	for remaining > 0 {
+		count, err := binary.ReadUvarint(in)
+		if err != nil || count == 0 || count > remaining {
+			return nil, ErrGridFileInvalid
+		}
+		value, err := in.ReadByte()
+		if err != nil {
+			return nil, ErrGridFileInvalid
+		}
+		remaining -= count
+		runs = append(runs, gridFileRun{count, int8(value)})
+	}
+	grid := NewGrid(int(header.Width), int(header.Height), int(header.Depth))
+	x, y, z := 0, 0, 0
+	for _, run := range runs {
+		for count := run.count; count > 0; count-- {
+			grid[x][y][z] = run.value
+			if z++; z == len(grid[x][y]) {
+				z = 0
+				if y++; y == len(grid[x]) {
+					y = 0
+					x++
+				}
+			}
+		}
+	}
+	return &BakedGrid{
+		Grid:     grid,
+		Origin:   matrix.Vec3{matrix.Float(header.Origin[0]), matrix.Float(header.Origin[1]), matrix.Float(header.Origin[2])},
+		CellSize: header.CellSize,
+	}, nil
+}
+
+// LoadBakedGrid reads a grid file saved with BakedGrid.Save
+func LoadBakedGrid(path string) (*BakedGrid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBakedGrid(f)
+}
@@ -0,0 +1,540 @@
+/*****************************************************************************/
+/* hpa.go                                                                    */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"container/heap"
+	"kaiju/matrix"
+)
+
+// HierarchicalGrid speeds up long searches over a Grid by splitting it into
+// clusters. Entrances are placed where neighbouring clusters touch and the
+// cost between every pair of entrances in a cluster is precomputed, so a
+// search only has to cross the small graph of entrances. The cells between
+// entrances are found when the path is refined.
+type HierarchicalGrid struct {
+	grid        Grid
+	options     AStarOptions
+	searcher    *Searcher
+	clusters    []hpaCluster
+	nodes       []hpaNode
+	freeNodes   []int32
+	nodeAt      map[matrix.Vec3i]int32
+	faces       map[int32][][2]int32
+	dirtyFaces  map[int32]bool
+	dirtyIntra  map[int32]bool
+	counts      [3]int32
+	clusterSize int32
+	version     uint64
+}
+
+type hpaCluster struct {
+	min, max matrix.Vec3i
+	nodes    []int32
+}
+
+type hpaNode struct {
+	pos     matrix.Vec3i
+	edges   []hpaEdge
+	cluster int32
+	refs    int32
+}
+
+type hpaEdge struct {
+	to    int32
+	cost  float64
+	inter bool
+}
+
+// NewHierarchicalGrid clusters the grid into cubes of clusterSize cells and
+// builds the abstract graph. The grid is shared, change it through BlockCell
+// so the affected clusters are rebuilt.
+func NewHierarchicalGrid(grid Grid, clusterSize int, options AStarOptions) *HierarchicalGrid {
+	h := &HierarchicalGrid{
+		grid:        grid,
+		options:     options,
+		searcher:    NewSearcher(),
+		nodeAt:      make(map[matrix.Vec3i]int32),
+		faces:       make(map[int32][][2]int32),
+		dirtyFaces:  make(map[int32]bool),
+		dirtyIntra:  make(map[int32]bool),
+		clusterSize: int32(max(clusterSize, 2)),
+	}
+	// Jump point search does not pay off inside of small clusters
+	h.searcher.DisableJumpPoints = true
+	dims := [3]int32{int32(grid.Width()), int32(grid.Height()), int32(grid.Depth())}
+	for a := range dims {
+		h.counts[a] = (dims[a] + h.clusterSize - 1) / h.clusterSize
+	}
+	for x := int32(0); x < h.counts[0]; x++ {
+		for y := int32(0); y < h.counts[1]; y++ {
+			for z := int32(0); z < h.counts[2]; z++ {
+				lo := matrix.Vec3i{x * h.clusterSize, y * h.clusterSize, z * h.clusterSize}
+				hi := matrix.Vec3i{}
+				for a := range hi {
+					hi[a] = min(lo[a]+h.clusterSize, dims[a]) - 1
+				}
+				h.clusters = append(h.clusters, hpaCluster{min: lo, max: hi})
+			}
+		}
+	}
+	for c := range h.clusters {
+		for a := int32(0); a < 3; a++ {
+			if h.hasFace(int32(c), a) {
+				h.dirtyFaces[int32(c)*3+a] = true
+			}
+		}
+		h.dirtyIntra[int32(c)] = true
+	}
+	h.Refresh()
+	return h
+}
+
+func (h *HierarchicalGrid) Grid() Grid            { return h.grid }
+func (h *HierarchicalGrid) EntranceCount() int    { return len(h.nodeAt) }
+func (h *HierarchicalGrid) Options() AStarOptions { return h.options }
+
+func (h *HierarchicalGrid) isLayered() bool {
+	return h.options.Connectivity == Connect4 || h.options.Connectivity == Connect8
+}
+
+func (h *HierarchicalGrid) clusterIndex(c [3]int32) int32 {
+	return (c[0]*h.counts[1]+c[1])*h.counts[2] + c[2]
+}
+
+func (h *HierarchicalGrid) clusterCoords(index int32) [3]int32 {
+	layer := h.counts[1] * h.counts[2]
+	r := index % layer
+	return [3]int32{index / layer, r / h.counts[2], r % h.counts[2]}
+}
+
+func (h *HierarchicalGrid) clusterOf(pos matrix.Vec3i) int32 {
+	return h.clusterIndex([3]int32{
+		pos[0] / h.clusterSize, pos[1] / h.clusterSize, pos[2] / h.clusterSize})
+}
+
+// hasFace reports if the cluster has a neighbour above it on the axis,
+// faces are stored on the lower of the two clusters
+func (h *HierarchicalGrid) hasFace(cluster, axis int32) bool {
+	if axis == 1 && h.isLayered() {
+		return false
+	}
+	return h.clusterCoords(cluster)[axis]+1 < h.counts[axis]
+}
+
+func (h *HierarchicalGrid) neighbor(cluster, axis, dir int32) (int32, bool) {
+	c := h.clusterCoords(cluster)
+	c[axis] += dir
+	if c[axis] < 0 || c[axis] >= h.counts[axis] {
+		return 0, false
+	}
+	return h.clusterIndex(c), true
+}
+
+// BlockCell changes a cell of the grid and marks the clusters it affects to
+// be rebuilt on the next search or call to Refresh
+func (h *HierarchicalGrid) BlockCell(pos matrix.Vec3i, blockType int8) {
+	h.grid.BlockCell(pos, blockType)
+	h.version++
+	cluster := h.clusterOf(pos)
+	h.dirtyIntra[cluster] = true
+	c := &h.clusters[cluster]
+	for a := int32(0); a < 3; a++ {
+		if pos[a] == c.min[a] {
+			if n, ok := h.neighbor(cluster, a, -1); ok && h.hasFace(n, a) {
+				h.dirtyFaces[n*3+a] = true
+				h.dirtyIntra[n] = true
+			}
+		}
+		if pos[a] == c.max[a] && h.hasFace(cluster, a) {
+			n, _ := h.neighbor(cluster, a, 1)
+			h.dirtyFaces[cluster*3+a] = true
+			h.dirtyIntra[n] = true
+		}
+	}
+}
+
+// Refresh rebuilds the entrances and cluster paths that BlockCell changed
+func (h *HierarchicalGrid) Refresh() {
+	for face := range h.dirtyFaces {
+		h.buildFace(face/3, face%3)
+	}
+	clear(h.dirtyFaces)
+	for cluster := range h.dirtyIntra {
+		h.buildIntraEdges(cluster)
+	}
+	clear(h.dirtyIntra)
+}
+
+func (h *HierarchicalGrid) passable(p matrix.Vec3i) bool {
+	return h.grid.passable(p[0], p[1], p[2], &h.options.Costs)
+}
+
+func (h *HierarchicalGrid) cellCost(p matrix.Vec3i) float64 {
+	return h.options.Costs.Cost(h.grid[p[0]][p[1]][p[2]])
+}
+
+func (h *HierarchicalGrid) addNode(pos matrix.Vec3i) int32 {
+	if id, ok := h.nodeAt[pos]; ok {
+		h.nodes[id].refs++
+		return id
+	}
+	node := hpaNode{pos: pos, cluster: h.clusterOf(pos), refs: 1}
+	var id int32
+	if len(h.freeNodes) > 0 {
+		id = h.freeNodes[len(h.freeNodes)-1]
+		h.freeNodes = h.freeNodes[:len(h.freeNodes)-1]
+		h.nodes[id] = node
+	} else {
+		id = int32(len(h.nodes))
+		h.nodes = append(h.nodes, node)
+	}
+	h.nodeAt[pos] = id
+	c := &h.clusters[node.cluster]
+	c.nodes = append(c.nodes, id)
+	return id
+}
+
+func (h *HierarchicalGrid) releaseNode(id int32) {
+	n := &h.nodes[id]
+	if n.refs--; n.refs > 0 {
+		return
+	}
+	c := &h.clusters[n.cluster]
+	for i, other := range c.nodes {
+		if other == id {
+			c.nodes = append(c.nodes[:i], c.nodes[i+1:]...)
+			break
+		}
+	}
+	for _, other := range c.nodes {
+		h.removeEdge(other, id)
+	}
+	delete(h.nodeAt, n.pos)
+	n.edges = nil
+	h.freeNodes = append(h.freeNodes, id)
+}
+
+func (h *HierarchicalGrid) removeEdge(from, to int32) {
+	edges := h.nodes[from].edges
+	for i := range edges {
+		if edges[i].to == to {
+			h.nodes[from].edges = append(edges[:i], edges[i+1:]...)
+			return
+		}
+	}
+}
+
+// buildFace replaces the entrances between the cluster and its neighbour
+// along the axis. Each connected group of open cells on the shared face
+// gets a single entrance at the cell closest to its middle.
+func (h *HierarchicalGrid) buildFace(cluster, axis int32) {
+	key := cluster*3 + axis
+	for _, pair := range h.faces[key] {
+		h.removeEdge(pair[0], pair[1])
+		h.removeEdge(pair[1], pair[0])
+		h.releaseNode(pair[0])
+		h.releaseNode(pair[1])
+	}
+	delete(h.faces, key)
+	c := &h.clusters[cluster]
+	u, v := (axis+1)%3, (axis+2)%3
+	width := c.max[u] - c.min[u] + 1
+	height := c.max[v] - c.min[v] + 1
+	cellAt := func(i, j int32) matrix.Vec3i {
+		var p matrix.Vec3i
+		p[axis] = c.max[axis]
+		p[u] = c.min[u] + i
+		p[v] = c.min[v] + j
+		return p
+	}
+	open := make([]bool, width*height)
+	for i := int32(0); i < width; i++ {
+		for j := int32(0); j < height; j++ {
+			p := cellAt(i, j)
+			q := p
+			q[axis]++
+			open[i*height+j] = h.passable(p) && h.passable(q)
+		}
+	}
+	pairs := [][2]int32{}
+	stack := []int32{}
+	group := []int32{}
+	for start := range open {
+		if !open[start] {
+			continue
+		}
+		open[start] = false
+		stack = append(stack[:0], int32(start))
+		group = group[:0]
+		var sumI, sumJ int32
+		for len(stack) > 0 {
+			cell := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			group = append(group, cell)
+			i, j := cell/height, cell%height
+			sumI += i
+			sumJ += j
+			for _, o := range [4][2]int32{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				ni, nj := i+o[0], j+o[1]
+				if ni >= 0 && nj >= 0 && ni < width && nj < height && open[ni*height+nj] {
+					open[ni*height+nj] = false
+					stack = append(stack, ni*height+nj)
+				}
+			}
+		}
+		count := int32(len(group))
+		best, bestDist := group[0], int32(-1)
+		for _, cell := range group {
+			di := (cell/height)*count - sumI
+			dj := (cell%height)*count - sumJ
+			if d := di*di + dj*dj; bestDist < 0 || d < bestDist {
+				best, bestDist = cell, d
+			}
+		}
+		p := cellAt(best/height, best%height)
+		q := p
+		q[axis]++
+		a, b := h.addNode(p), h.addNode(q)
+		h.nodes[a].edges = append(h.nodes[a].edges, hpaEdge{to: b, cost: h.cellCost(q), inter: true})
+		h.nodes[b].edges = append(h.nodes[b].edges, hpaEdge{to: a, cost: h.cellCost(p), inter: true})
+		pairs = append(pairs, [2]int32{a, b})
+	}
+	if len(pairs) > 0 {
+		h.faces[key] = pairs
+	}
+}
+
+// localCost searches between two cells without leaving the cluster
+func (h *HierarchicalGrid) localCost(cluster int32, from, to matrix.Vec3i) (float64, bool) {
+	c := &h.clusters[cluster]
+	h.searcher.SetBounds(c.min, c.max)
+	path := h.searcher.FindPath(h.grid, from, to, &h.options)
+	h.searcher.ClearBounds()
+	return h.searcher.PathCost(), path != nil
+}
+
+func (h *HierarchicalGrid) buildIntraEdges(cluster int32) {
+	c := &h.clusters[cluster]
+	for _, id := range c.nodes {
+		n := &h.nodes[id]
+		kept := n.edges[:0]
+		for _, e := range n.edges {
+			if e.inter {
+				kept = append(kept, e)
+			}
+		}
+		n.edges = kept
+	}
+	for i, a := range c.nodes {
+		for _, b := range c.nodes[i+1:] {
+			h.connectLocal(cluster, a, b)
+		}
+	}
+}
+
+// connectLocal adds the edges both ways between two nodes of a cluster.
+// Each direction is searched separately since entering a costly cell on a
+// diagonal costs more than entering it straight on.
+func (h *HierarchicalGrid) connectLocal(cluster, a, b int32) {
+	pa, pb := h.nodes[a].pos, h.nodes[b].pos
+	if cost, ok := h.localCost(cluster, pa, pb); ok {
+		h.nodes[a].edges = append(h.nodes[a].edges, hpaEdge{to: b, cost: cost})
+	}
+	if cost, ok := h.localCost(cluster, pb, pa); ok {
+		h.nodes[b].edges = append(h.nodes[b].edges, hpaEdge{to: a, cost: cost})
+	}
+}
+
+// insertEndpoint temporarily adds a search endpoint to the abstract graph
+func (h *HierarchicalGrid) insertEndpoint(pos matrix.Vec3i) int32 {
+	if id, ok := h.nodeAt[pos]; ok {
+		h.nodes[id].refs++
+		return id
+	}
+	id := h.addNode(pos)
+	cluster := h.nodes[id].cluster
+	for _, other := range h.clusters[cluster].nodes {
+		if other != id {
+			h.connectLocal(cluster, id, other)
+		}
+	}
+	return id
+}
+
+type hpaOpen struct {
+	node int32
+	f    float64
+}
+
+type hpaQueue []hpaOpen
+
+func (q hpaQueue) Len() int           { return len(q) }
+func (q hpaQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+func (q hpaQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *hpaQueue) Push(x any)        { *q = append(*q, x.(hpaOpen)) }
+
+func (q *hpaQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// HierarchicalPath is the list of entrances a path passes through, the cells
+// between them are only searched for when a segment is asked for
+type HierarchicalPath struct {
+	owner     *HierarchicalGrid
+	Waypoints []matrix.Vec3i
+	Cost      float64
+	version   uint64
+}
+
+// FindPath searches the abstract graph between two open cells and returns
+// nil when no path exists
+func (h *HierarchicalGrid) FindPath(start, end matrix.Vec3i) *HierarchicalPath {
+	if h.isLayered() {
+		end[matrix.Vy] = start[matrix.Vy]
+	}
+	if !h.grid.IsValid(start) || !h.grid.IsValid(end) || !h.passable(start) || !h.passable(end) {
+		return nil
+	}
+	h.Refresh()
+	path := &HierarchicalPath{owner: h, version: h.version}
+	if start == end {
+		path.Waypoints = []matrix.Vec3i{start}
+		return path
+	}
+	s := h.insertEndpoint(start)
+	e := h.insertEndpoint(end)
+	defer h.releaseNode(s)
+	defer h.releaseNode(e)
+	if h.nodes[s].cluster == h.nodes[e].cluster {
+		if _, seen := h.nodeEdge(s, e); !seen {
+			h.connectLocal(h.nodes[s].cluster, s, e)
+		}
+	}
+	heuristic := h.options.heuristic()
+	hScale := h.options.Costs.minCost()
+	g := map[int32]float64{s: 0}
+	parent := map[int32]int32{s: -1}
+	closed := map[int32]bool{}
+	open := hpaQueue{{s, heuristic(start, end) * hScale}}
+	for open.Len() > 0 {
+		current := heap.Pop(&open).(hpaOpen).node
+		if closed[current] {
+			continue
+		}
+		if current == e {
+			for n := e; n >= 0; n = parent[n] {
+				path.Waypoints = append(path.Waypoints, h.nodes[n].pos)
+			}
+			reverseCells(path.Waypoints)
+			path.Cost = g[e]
+			return path
+		}
+		closed[current] = true
+		for _, edge := range h.nodes[current].edges {
+			cost := g[current] + edge.cost
+			if old, ok := g[edge.to]; closed[edge.to] || (ok && cost >= old) {
+				continue
+			}
+			g[edge.to] = cost
+			parent[edge.to] = current
+			heap.Push(&open, hpaOpen{edge.to, cost + heuristic(h.nodes[edge.to].pos, end)*hScale})
+		}
+	}
+	return nil
+}
+
+func (h *HierarchicalGrid) nodeEdge(from, to int32) (hpaEdge, bool) {
+	for _, e := range h.nodes[from].edges {
+		if e.to == to {
+			return e, true
+		}
+	}
+	return hpaEdge{}, false
+}
+
+// IsStale reports if the grid changed after the path was found, refining a
+// stale path can fail where cells have been blocked
+func (p *HierarchicalPath) IsStale() bool { return p.version != p.owner.version }
+
+// SegmentCount is the number of segments between the waypoints
+func (p *HierarchicalPath) SegmentCount() int { return max(len(p.Waypoints)-1, 0) }
+
+// Segment refines the path between two waypoints into cells, the first cell
+// is the waypoint at the index. Nil is returned when the segment is blocked.
+func (p *HierarchicalPath) Segment(index int) []matrix.Vec3i {
+	from, to := p.Waypoints[index], p.Waypoints[index+1]
+	h := p.owner
+	cluster := h.clusterOf(from)
+	if cluster != h.clusterOf(to) {
+		if !h.passable(to) {
+			return nil
+		}
+		return []matrix.Vec3i{from, to}
+	}
+	c := &h.clusters[cluster]
+	h.searcher.SetBounds(c.min, c.max)
+	cells := h.searcher.FindPath(h.grid, from, to, &h.options)
+	h.searcher.ClearBounds()
+	if cells == nil || cells[len(cells)-1] != to {
+		return nil
+	}
+	return append([]matrix.Vec3i(nil), cells...)
+}
+
+// Cells refines every segment of the path into a single list of cells
+func (p *HierarchicalPath) Cells() []matrix.Vec3i {
+	if len(p.Waypoints) == 1 {
+		return []matrix.Vec3i{p.Waypoints[0]}
+	}
+	out := []matrix.Vec3i{}
+	for i := 0; i < p.SegmentCount(); i++ {
+		segment := p.Segment(i)
+		if segment == nil {
+			return nil
+		}
+		if len(out) > 0 {
+			segment = segment[1:]
+		}
+		out = append(out, segment...)
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+/*****************************************************************************/
+/* hpa_test.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math/rand"
+	"testing"
+)
+
+func checkHierarchicalPath(t *testing.T, h *HierarchicalGrid, start, end matrix.Vec3i) {
+	t.Helper()
+	options := h.Options()
+	s := NewSearcher()
+	s.DisableJumpPoints = true
+	exact := s.FindPath(h.Grid(), start, end, &options)
+	path := h.FindPath(start, end)
+	if (exact == nil) != (path == nil) {
+		t.Fatalf("A* found path: %t, hierarchical found path: %t", exact != nil, path != nil)
+	}
+	if path == nil {
+		return
+	}
+	cells := path.Cells()
+	if cells == nil || cells[0] != start || cells[len(cells)-1] != end {
+		t.Fatalf("refined path does not join %v and %v", start, end)
+	}
+	checkPath(t, h.Grid(), cells, &options)
+	cost := 0.0
+	for i := 1; i < len(cells); i++ {
+		cost += stepCost(h.Grid(), cells[i-1], cells[i], &options.Costs)
+	}
+	if cost < s.PathCost()-0.001 || cost-path.Cost > 0.001 || path.Cost-cost > 0.001 {
+		t.Fatalf("path cost %f, abstract cost %f, optimal cost %f", cost, path.Cost, s.PathCost())
+	}
+}
+
+func TestHierarchicalGrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for _, conn := range []Connectivity{Connect4, Connect8} {
+		options := DefaultAStarOptions()
+		options.Connectivity = conn
+		options.Corners = CornerCutNever
+		options.Costs.SetCost(2, 4)
+		grid := randomGrid(rng, 40, 1, 40, 0.25)
+		for i := 0; i < 100; i++ {
+			grid[rng.Intn(40)][0][rng.Intn(40)] = 2
+		}
+		h := NewHierarchicalGrid(grid, 8, options)
+		for i := 0; i < 50; i++ {
+			start := matrix.Vec3i{int32(rng.Intn(40)), 0, int32(rng.Intn(40))}
+			end := matrix.Vec3i{int32(rng.Intn(40)), 0, int32(rng.Intn(40))}
+			h.BlockCell(start, 0)
+			h.BlockCell(end, 0)
+			checkHierarchicalPath(t, h, start, end)
+			// Flip a few cells to exercise the incremental rebuild
+			for j := 0; j < 5; j++ {
+				h.BlockCell(matrix.Vec3i{int32(rng.Intn(40)), 0, int32(rng.Intn(40))}, int8(rng.Intn(2)))
+			}
+		}
+	}
+}
+
+func TestHierarchicalGridWall(t *testing.T) {
+	grid := NewGrid(32, 1, 32)
+	h := NewHierarchicalGrid(grid, 8, DefaultAStarOptions())
+	start, end := matrix.Vec3i{2, 0, 2}, matrix.Vec3i{29, 0, 2}
+	if h.FindPath(start, end) == nil {
+		t.Fatal("expected a path across the open grid")
+	}
+	for z := int32(0); z < 32; z++ {
+		h.BlockCell(matrix.Vec3i{16, 0, z}, 1)
+	}
+	if h.FindPath(start, end) != nil {
+		t.Fatal("expected the wall to block every path")
+	}
+	h.BlockCell(matrix.Vec3i{16, 0, 31}, 0)
+	path := h.FindPath(start, end)
+	if path == nil {
+		t.Fatal("expected a path through the gap in the wall")
+	}
+	if cells := path.Cells(); len(cells) < 30 {
+		t.Fatalf("expected the path to detour through the gap, got %d cells", len(cells))
+	}
+}
@@ -0,0 +1,204 @@
+/*****************************************************************************/
+/* jump_point.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math"
+)
+
+// jumpContext searches a single layer of the grid with jump point search.
+// Diagonal moves are only allowed when both of the straight cells next to
+// them are open, which matches the CornerCutNever rule.
+type jumpContext struct {
+	search *Searcher
+	grid   Grid
+	costs  *CostTable
+	y      int32
+	endX   int32
+	endZ   int32
+	buffer [8][2]int32
+}
+
+func (j *jumpContext) walkable(x, z int32) bool {
+	return j.search.inBounds(x, j.y, z) && j.grid.passable(x, j.y, z, j.costs)
+}
+
+// jump moves from the parent through x, z until it reaches the end or a
+// cell with a forced neighbour, it returns false when it hits a wall
+func (j *jumpContext) jump(x, z, px, pz int32) (int32, int32, bool) {
+	dx, dz := x-px, z-pz
+	for {
+		if !j.walkable(x, z) {
+			return 0, 0, false
+		}
+		if x == j.endX && z == j.endZ {
+			return x, z, true
+		}
+		if dx != 0 && dz != 0 {
+			if _, _, ok := j.jump(x+dx, z, x, z); ok {
+				return x, z, true
+			}
+			if _, _, ok := j.jump(x, z+dz, x, z); ok {
+				return x, z, true
+			}
+			if !j.walkable(x+dx, z) || !j.walkable(x, z+dz) {
+				return 0, 0, false
+			}
+		} else if dx != 0 {
+			if (j.walkable(x, z-1) && !j.walkable(x-dx, z-1)) ||
+				(j.walkable(x, z+1) && !j.walkable(x-dx, z+1)) {
+				return x, z, true
+			}
+		} else if (j.walkable(x-1, z) && !j.walkable(x-1, z-dz)) ||
+			(j.walkable(x+1, z) && !j.walkable(x+1, z-dz)) {
+			return x, z, true
+		}
+		x, z = x+dx, z+dz
+	}
+}
+
+// neighbors prunes the cells around x, z down to the ones that can not be
+// reached more cheaply without passing through x, z
+func (j *jumpContext) neighbors(x, z, px, pz int32, hasParent bool) [][2]int32 {
+	out := j.buffer[:0]
+	if !hasParent {
+		for _, d := range connectivityDirections[Connect8] {
+			dx, dz := d.offset[0], d.offset[2]
+			if !j.walkable(x+dx, z+dz) {
+				continue
+			}
+			if dx != 0 && dz != 0 && (!j.walkable(x+dx, z) || !j.walkable(x, z+dz)) {
+				continue
+			}
+			out = append(out, [2]int32{x + dx, z + dz})
+		}
+		return out
+	}
+	dx, dz := sign32(x-px), sign32(z-pz)
+	if dx != 0 && dz != 0 {
+		openZ, openX := j.walkable(x, z+dz), j.walkable(x+dx, z)
+		if openZ {
+			out = append(out, [2]int32{x, z + dz})
+		}
+		if openX {
+			out = append(out, [2]int32{x + dx, z})
+		}
+		if openZ && openX {
+			out = append(out, [2]int32{x + dx, z + dz})
+		}
+	} else if dx != 0 {
+		next, up, down := j.walkable(x+dx, z), j.walkable(x, z+1), j.walkable(x, z-1)
+		if next {
+			out = append(out, [2]int32{x + dx, z})
+			if up {
+				out = append(out, [2]int32{x + dx, z + 1})
+			}
+			if down {
+				out = append(out, [2]int32{x + dx, z - 1})
+			}
+		}
+		if up {
+			out = append(out, [2]int32{x, z + 1})
+		}
+		if down {
+			out = append(out, [2]int32{x, z - 1})
+		}
+	} else {
+		next, right, left := j.walkable(x, z+dz), j.walkable(x+1, z), j.walkable(x-1, z)
+		if next {
+			out = append(out, [2]int32{x, z + dz})
+			if right {
+				out = append(out, [2]int32{x + 1, z + dz})
+			}
+			if left {
+				out = append(out, [2]int32{x - 1, z + dz})
+			}
+		}
+		if right {
+			out = append(out, [2]int32{x + 1, z})
+		}
+		if left {
+			out = append(out, [2]int32{x - 1, z})
+		}
+	}
+	return out
+}
+
+func (s *Searcher) jumpSearch(grid Grid, start, end matrix.Vec3i, costs *CostTable, cost float32) bool {
+	j := jumpContext{search: s, grid: grid, costs: costs, y: start.Y(), endX: end.X(), endZ: end.Z()}
+	octile := func(ax, az, bx, bz int32) float32 {
+		dx := float32(max(ax-bx, bx-ax))
+		dz := float32(max(az-bz, bz-az))
+		return (max(dx, dz) + (math.Sqrt2-1)*min(dx, dz)) * cost
+	}
+	endIndex := s.index(end[0], end[1], end[2])
+	startIndex := s.index(start[0], start[1], start[2])
+	s.push(startIndex, 0, octile(start.X(), start.Z(), end.X(), end.Z()), -1)
+	for len(s.open) > 0 {
+		current := s.pop()
+		if current == endIndex {
+			return true
+		}
+		x, _, z := s.coords(current)
+		parent := s.parent[current]
+		var px, pz int32
+		if parent >= 0 {
+			px, _, pz = s.coords(parent)
+		}
+		for _, n := range j.neighbors(x, z, px, pz, parent >= 0) {
+			jx, jz, ok := j.jump(n[0], n[1], x, z)
+			if !ok {
+				continue
+			}
+			next := s.index(jx, j.y, jz)
+			if s.isClosed(next) {
+				continue
+			}
+			g := s.g[current] + octile(x, z, jx, jz)
+			if s.isSeen(next) {
+				if g < s.g[next] {
+					s.update(next, g, current)
+				}
+				continue
+			}
+			s.push(next, g, g+octile(jx, jz, end.X(), end.Z()), current)
+		}
+	}
+	return false
+}
@@ -37,7 +37,10 @@
 
 package navigation
 
-import "kaiju/matrix"
+import (
+	"kaiju/matrix"
+	"slices"
+)
 
 type Grid [][][]int8
 
@@ -85,3 +88,16 @@ func (g Grid) IsValid(pos matrix.Vec3i) bool {
 		pos.Y() >= 0 && pos.Y() < int32(len(g[0])) &&
 		pos.Z() >= 0 && pos.Z() < int32(len(g[0][0]))
 }
+
+// Clone makes a deep copy of the grid, the copy can be read from other
+// goroutines while the original keeps changing
+func (g Grid) Clone() Grid {
+	out := make([][][]int8, len(g))
+	for x := range g {
+		out[x] = make([][]int8, len(g[x]))
+		for y := range g[x] {
+			out[x][y] = slices.Clone(g[x][y])
+		}
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+/*****************************************************************************/
+/* flow_field.go                                                             */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navdebug
+
+import (
+	"kaiju/assets"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/systems/navigation"
+	"math"
+	"unsafe"
+)
+
+const flowArrowMeshKey = "flow_field_arrow"
+
+type flowArrow struct {
+	data *lineShaderData
+	// live is set when the cell had a direction at the last refresh
+	live bool
+}
+
+type lineShaderData struct {
+	rendering.ShaderDataBase
+	Color matrix.Color
+}
+
+func (t lineShaderData) Size() int {
+	const size = int(unsafe.Sizeof(lineShaderData{}) - rendering.ShaderBaseDataStart)
+	return size
+}
+
+// FlowFieldDrawer draws a short line in every cell of a flow field pointing
+// in the direction agents in that cell will move. Lines are colored from
+// Near to Far by how expensive it is to reach a goal from the cell. Every
+// line is an instance of the same mesh so the whole field is drawn in a
+// single instanced draw, cells are only added to the drawings the first
+// time they have a direction and are deactivated rather than removed.
+type FlowFieldDrawer struct {
+	host     *engine.Host
+	field    *navigation.FlowField
+	arrows   []flowArrow
+	Origin   matrix.Vec3
+	CellSize float32
+	Near     matrix.Color
+	Far      matrix.Color
+	hidden   bool
+}
+
+// NewFlowFieldDrawer draws the field with its first cell starting at origin
+func NewFlowFieldDrawer(host *engine.Host, field *navigation.FlowField, origin matrix.Vec3, cellSize float32) *FlowFieldDrawer {
+	grid := field.Grid()
+	d := &FlowFieldDrawer{
+		host:     host,
+		field:    field,
+		arrows:   make([]flowArrow, grid.Width()*grid.Height()*grid.Depth()),
+		Origin:   origin,
+		CellSize: cellSize,
+		Near:     matrix.Color{0, 1, 0, 1},
+		Far:      matrix.Color{1, 0, 0, 1},
+	}
+	d.Refresh()
+	return d
+}
+
+// CellCenter is the world position of the middle of the cell
+func (d *FlowFieldDrawer) CellCenter(cell matrix.Vec3i) matrix.Vec3 {
+	return navigation.CellCenter(cell, d.Origin, d.CellSize)
+}
+
+// arrowModel maps the unit line along +X onto the cell, the line has no
+// width so only the X axis of the matrix needs to follow the direction
+func (d *FlowFieldDrawer) arrowModel(cell, dir matrix.Vec3i) matrix.Mat4 {
+	end := matrix.Vec3{float32(dir.X()), float32(dir.Y()), float32(dir.Z())}
+	end = end.Normal().Scale(d.CellSize * 0.45)
+	model := matrix.Mat4Identity()
+	model.Scale(matrix.Vec3{d.CellSize, d.CellSize, d.CellSize})
+	model[matrix.Mat4x0y0] = end.X()
+	model[matrix.Mat4x1y0] = end.Y()
+	model[matrix.Mat4x2y0] = end.Z()
+	model.SetTranslation(d.CellCenter(cell))
+	return model
+}
+
+// Refresh updates the lines to match the field, it should be called after
+// the goals change or cells are blocked. Lines stay hidden if Hide was
+// called before the refresh.
+func (d *FlowFieldDrawer) Refresh() {
+	maxCost := 0.0
+	d.eachCell(func(cell matrix.Vec3i, _ int) {
+		if cost := d.field.Cost(cell); !math.IsInf(cost, 1) {
+			maxCost = max(maxCost, cost)
+		}
+	})
+	var shader *rendering.Shader
+	var mesh *rendering.Mesh
+	added := make([]rendering.Drawing, 0)
+	d.eachCell(func(cell matrix.Vec3i, index int) {
+		arrow := &d.arrows[index]
+		dir, ok := d.field.Direction(cell)
+		arrow.live = ok
+		if !ok {
+			if arrow.data != nil {
+				arrow.data.Deactivate()
+			}
+			return
+		}
+		if arrow.data == nil {
+			if shader == nil {
+				shader = d.host.ShaderCache().ShaderFromDefinition(assets.ShaderDefinitionGrid)
+				mesh = rendering.NewMeshLine(d.host.MeshCache(), flowArrowMeshKey,
+					matrix.Vec3{}, matrix.Vec3Right(), matrix.ColorWhite())
+			}
+			arrow.data = &lineShaderData{ShaderDataBase: rendering.NewShaderDataBase()}
+			added = append(added, rendering.Drawing{
+				Renderer:   d.host.Window.Renderer,
+				Shader:     shader,
+				Mesh:       mesh,
+				ShaderData: arrow.data,
+			})
+		}
+		arrow.data.SetModel(d.arrowModel(cell, dir))
+		t := float32(0)
+		if maxCost > 0 {
+			t = float32(d.field.Cost(cell) / maxCost)
+		}
+		for i := range arrow.data.Color {
+			arrow.data.Color[i] = d.Near[i] + (d.Far[i]-d.Near[i])*t
+		}
+		if d.hidden {
+			arrow.data.Deactivate()
+		} else {
+			arrow.data.Activate()
+		}
+	})
+	if len(added) > 0 {
+		d.host.Drawings.AddDrawings(added)
+	}
+}
+
+func (d *FlowFieldDrawer) eachCell(fn func(cell matrix.Vec3i, index int)) {
+	grid := d.field.Grid()
+	index := 0
+	for x := int32(0); x < int32(grid.Width()); x++ {
+		for y := int32(0); y < int32(grid.Height()); y++ {
+			for z := int32(0); z < int32(grid.Depth()); z++ {
+				fn(matrix.Vec3i{x, y, z}, index)
+				index++
+			}
+		}
+	}
+}
+
+// Show and Hide toggle the lines without removing them, the choice is kept
+// when the lines are refreshed
+func (d *FlowFieldDrawer) Show()          { d.setHidden(false) }
+func (d *FlowFieldDrawer) Hide()          { d.setHidden(true) }
+func (d *FlowFieldDrawer) IsHidden() bool { return d.hidden }
+
+func (d *FlowFieldDrawer) setHidden(hidden bool) {
+	d.hidden = hidden
+	for i := range d.arrows {
+		if data := d.arrows[i].data; data != nil {
+			if d.arrows[i].live && !hidden {
+				data.Activate()
+			} else {
+				data.Deactivate()
+			}
+		}
+	}
+}
+
+// Destroy removes every line from the renderer
+func (d *FlowFieldDrawer) Destroy() {
+	for i := range d.arrows {
+		if d.arrows[i].data != nil {
+			d.arrows[i].data.Destroy()
+			d.arrows[i] = flowArrow{}
+		}
+	}
+}
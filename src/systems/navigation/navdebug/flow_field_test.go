@@ -0,0 +1,99 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navdebug
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/systems/navigation"
+	"kaiju/tests/testhost"
+	"testing"
+)
+
+func TestMain(m *testing.M) { testhost.Main(m) }
+
+func testHost(t *testing.T) *engine.Host {
+	return testhost.New(t, "Navigation debug test", 32, 24)
+}
+
+func activeArrows(d *FlowFieldDrawer) int {
+	count := 0
+	for i := range d.arrows {
+		if d.arrows[i].data != nil && d.arrows[i].data.IsActive() {
+			count++
+		}
+	}
+	return count
+}
+
+func TestFlowFieldDrawer(t *testing.T) {
+	host := testHost(t)
+	field := navigation.NewFlowField(navigation.NewGrid(4, 1, 4), navigation.DefaultAStarOptions())
+	field.SetGoals(matrix.Vec3i{0, 0, 0})
+	d := NewFlowFieldDrawer(host, field, matrix.Vec3Zero(), 1)
+	// Every cell other than the goal points somewhere
+	if active := activeArrows(d); active != 15 {
+		t.Fatalf("expected 15 arrows, got %d", active)
+	}
+	host.Update(1.0 / 60.0)
+	host.Render()
+	blocked := matrix.Vec3i{3, 0, 3}
+	index := 3*4 + 3
+	before := d.arrows[index].data
+	field.BlockCell(blocked, 1)
+	d.Refresh()
+	if d.arrows[index].data != before || before.IsActive() {
+		t.Error("the arrow of a blocked cell should be deactivated rather than removed")
+	}
+	if active := activeArrows(d); active != 14 {
+		t.Errorf("expected 14 arrows after blocking a cell, got %d", active)
+	}
+	d.Hide()
+	d.Refresh()
+	if active := activeArrows(d); active != 0 || !d.IsHidden() {
+		t.Errorf("refreshing should keep the arrows hidden, %d are active", active)
+	}
+	d.Show()
+	if active := activeArrows(d); active != 14 {
+		t.Errorf("showing should only bring back the live arrows, got %d", active)
+	}
+	d.Destroy()
+	if active := activeArrows(d); active != 0 {
+		t.Errorf("expected no arrows after destroy, got %d", active)
+	}
+}
@@ -0,0 +1,332 @@
+/*****************************************************************************/
+/* navmesh.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+	"kaiju/matrix"
+)
+
+var (
+	ErrNavMeshPointOff = errors.New("point is not on the navmesh")
+	ErrNavMeshNoPath   = errors.New("no path exists between the points")
+)
+
+const navMeshBucketCells = 16
+
+// OffMeshLink connects two points of the navmesh that can not be walked
+// between, like jumping down a ledge or climbing a ladder. Cost is added to
+// the distance between the points when searching for a path.
+type OffMeshLink struct {
+	Start         matrix.Vec3
+	End           matrix.Vec3
+	Cost          float32
+	Bidirectional bool
+}
+
+// NavPolygon is a convex walkable area of the navmesh, the vertices are in
+// world space and wind counter-clockwise when viewed from above
+type NavPolygon struct {
+	Vertices []matrix.Vec3
+	Center   matrix.Vec3
+	links    []navLink
+	min      matrix.Vec2
+	max      matrix.Vec2
+}
+
+// navLink is an edge of the polygon graph, it is either a portal shared with
+// a neighbouring polygon or one direction of an off-mesh link
+type navLink struct {
+	a, b    matrix.Vec3
+	poly    int32
+	offMesh int32
+	reverse bool
+}
+
+type NavMesh struct {
+	config   NavMeshConfig
+	origin   matrix.Vec3
+	polygons []NavPolygon
+	links    []OffMeshLink
+	buckets  map[[2]int32][]int32
+}
+
+func newNavMesh(cfg NavMeshConfig, origin matrix.Vec3) *NavMesh {
+	return &NavMesh{
+		config:  cfg,
+		origin:  origin,
+		buckets: make(map[[2]int32][]int32),
+	}
+}
+
+func (m *NavMesh) Config() NavMeshConfig              { return m.config }
+func (m *NavMesh) PolygonCount() int                  { return len(m.polygons) }
+func (m *NavMesh) Polygon(index int) *NavPolygon      { return &m.polygons[index] }
+func (m *NavMesh) OffMeshLinks() []OffMeshLink        { return m.links }
+func (p *NavPolygon) Contains(point matrix.Vec3) bool { return p.containsXZ(point, 0) }
+
+// Neighbors returns the indexes of the polygons that can be reached from
+// this polygon, including the ones reached through off-mesh links
+func (p *NavPolygon) Neighbors() []int {
+	out := make([]int, 0, len(p.links))
+	for _, l := range p.links {
+		out = append(out, int(l.poly))
+	}
+	return out
+}
+
+func (p *NavPolygon) containsXZ(point matrix.Vec3, pad float32) bool {
+	return point.X() >= p.min.X()-pad && point.X() <= p.max.X()+pad &&
+		point.Z() >= p.min.Y()-pad && point.Z() <= p.max.Y()+pad
+}
+
+// HeightAt interpolates the height of the polygon surface at the XZ
+// position of the point
+func (p *NavPolygon) HeightAt(point matrix.Vec3) float32 {
+	size := p.max.Subtract(p.min)
+	u := matrix.Clamp((point.X()-p.min.X())/size.X(), 0, 1)
+	v := matrix.Clamp((point.Z()-p.min.Y())/size.Y(), 0, 1)
+	v0, v1, v2, v3 := p.Vertices[0].Y(), p.Vertices[1].Y(), p.Vertices[2].Y(), p.Vertices[3].Y()
+	near := v0 + (v3-v0)*u
+	far := v1 + (v2-v1)*u
+	return near + (far-near)*v
+}
+
+// closestPoint clamps the point to the polygon and places it on the surface
+func (p *NavPolygon) closestPoint(point matrix.Vec3) matrix.Vec3 {
+	c := matrix.Vec3{
+		matrix.Clamp(point.X(), p.min.X(), p.max.X()),
+		0,
+		matrix.Clamp(point.Z(), p.min.Y(), p.max.Y()),
+	}
+	c.SetY(p.HeightAt(c))
+	return c
+}
+
+func (m *NavMesh) cellCoords(point matrix.Vec3) (int32, int32) {
+	return int32(matrix.Floor((point.X() - m.origin.X()) / m.config.CellSize)),
+		int32(matrix.Floor((point.Z() - m.origin.Z()) / m.config.CellSize))
+}
+
+func (m *NavMesh) addRect(cf *cellField, rows [][]int32) {
+	index := int32(len(m.polygons))
+	for _, row := range rows {
+		for _, c := range row {
+			cf.cells[c].poly = index
+		}
+	}
+	cs, ch := m.config.CellSize, m.config.CellHeight
+	corner := func(c int32, dx, dz int32) matrix.Vec3 {
+		cell := &cf.cells[c]
+		return matrix.Vec3{
+			m.origin.X() + float32(cell.x+dx)*cs,
+			m.origin.Y() + float32(cell.floor)*ch,
+			m.origin.Z() + float32(cell.z+dz)*cs,
+		}
+	}
+	last := len(rows) - 1
+	end := len(rows[0]) - 1
+	p := NavPolygon{
+		Vertices: []matrix.Vec3{
+			corner(rows[0][0], 0, 0),
+			corner(rows[last][0], 0, 1),
+			corner(rows[last][end], 1, 1),
+			corner(rows[0][end], 1, 0),
+		},
+	}
+	p.min = matrix.Vec2{p.Vertices[0].X(), p.Vertices[0].Z()}
+	p.max = matrix.Vec2{p.Vertices[2].X(), p.Vertices[2].Z()}
+	for _, v := range p.Vertices {
+		p.Center.AddAssign(v)
+	}
+	p.Center = p.Center.Shrink(float32(len(p.Vertices)))
+	m.polygons = append(m.polygons, p)
+}
+
+// connectRect walks the cells along each side of the rectangle and creates
+// a portal for every run of cells that lead into the same neighbour
+func (m *NavMesh) connectRect(cf *cellField, index int32, rows [][]int32) {
+	last := len(rows) - 1
+	end := len(rows[0]) - 1
+	sides := [4][]int32{}
+	for _, row := range rows {
+		sides[dirNegX] = append(sides[dirNegX], row[0])
+		sides[dirPosX] = append(sides[dirPosX], row[end])
+	}
+	sides[dirNegZ] = rows[0]
+	sides[dirPosZ] = rows[last]
+	for dir, cells := range sides {
+		runPoly, runStart := int32(-1), 0
+		for k := 0; k <= len(cells); k++ {
+			next := int32(-1)
+			if k < len(cells) {
+				if n := cf.cells[cells[k]].conn[dir]; n >= 0 {
+					next = cf.cells[n].poly
+				}
+			}
+			if k < len(cells) && next == runPoly {
+				continue
+			}
+			if runPoly >= 0 {
+				m.polygons[index].links = append(m.polygons[index].links, navLink{
+					a:       m.edgePoint(&cf.cells[cells[runStart]], dir, false),
+					b:       m.edgePoint(&cf.cells[cells[k-1]], dir, true),
+					poly:    runPoly,
+					offMesh: -1,
+				})
+			}
+			runPoly, runStart = next, k
+		}
+	}
+}
+
+// edgePoint is the corner of the cell on the given side, far selects the
+// corner further along the side
+func (m *NavMesh) edgePoint(c *navCell, dir int, far bool) matrix.Vec3 {
+	x, z := float32(c.x), float32(c.z)
+	along := float32(0)
+	if far {
+		along = 1
+	}
+	switch dir {
+	case dirNegX:
+		z += along
+	case dirPosX:
+		x += 1
+		z += along
+	case dirNegZ:
+		x += along
+	case dirPosZ:
+		z += 1
+		x += along
+	}
+	return matrix.Vec3{
+		m.origin.X() + x*m.config.CellSize,
+		m.origin.Y() + float32(c.floor)*m.config.CellHeight,
+		m.origin.Z() + z*m.config.CellSize,
+	}
+}
+
+func (m *NavMesh) buildBuckets() {
+	for i := range m.polygons {
+		p := &m.polygons[i]
+		x0, z0 := m.cellCoords(matrix.Vec3{p.min.X(), 0, p.min.Y()})
+		x1, z1 := m.cellCoords(matrix.Vec3{p.max.X(), 0, p.max.Y()})
+		for bz := z0 / navMeshBucketCells; bz <= z1/navMeshBucketCells; bz++ {
+			for bx := x0 / navMeshBucketCells; bx <= x1/navMeshBucketCells; bx++ {
+				key := [2]int32{bx, bz}
+				m.buckets[key] = append(m.buckets[key], int32(i))
+			}
+		}
+	}
+}
+
+func (m *NavMesh) bucket(point matrix.Vec3) []int32 {
+	x, z := m.cellCoords(point)
+	if x < 0 || z < 0 {
+		return nil
+	}
+	return m.buckets[[2]int32{x / navMeshBucketCells, z / navMeshBucketCells}]
+}
+
+// FindPolygon returns the index of the polygon under the point, when
+// polygons are stacked the one closest in height is picked
+func (m *NavMesh) FindPolygon(point matrix.Vec3) (int, bool) {
+	best, bestDist := -1, m.config.AgentHeight
+	for _, i := range m.bucket(point) {
+		p := &m.polygons[i]
+		if !p.containsXZ(point, 0) {
+			continue
+		}
+		if d := matrix.Abs(p.HeightAt(point) - point.Y()); d <= bestDist {
+			best, bestDist = int(i), d
+		}
+	}
+	return best, best >= 0
+}
+
+// ClosestPoint finds the nearest point on the navmesh that is within the
+// search radius of the given point
+func (m *NavMesh) ClosestPoint(point matrix.Vec3, radius float32) (matrix.Vec3, int, bool) {
+	if i, ok := m.FindPolygon(point); ok {
+		return m.polygons[i].closestPoint(point), i, true
+	}
+	best, bestDist := -1, radius
+	closest := point
+	for i := range m.polygons {
+		p := &m.polygons[i]
+		if !p.containsXZ(point, radius) {
+			continue
+		}
+		c := p.closestPoint(point)
+		if d := c.Distance(point); d <= bestDist {
+			best, bestDist, closest = i, d, c
+		}
+	}
+	return closest, best, best >= 0
+}
+
+func (m *NavMesh) locate(point matrix.Vec3) (int32, matrix.Vec3, bool) {
+	p, i, ok := m.ClosestPoint(point, m.config.AgentRadius+m.config.CellSize*2)
+	return int32(i), p, ok
+}
+
+// AddOffMeshLink connects the polygons under the start and end points of
+// the link, both points are moved onto the surface of the navmesh
+func (m *NavMesh) AddOffMeshLink(link OffMeshLink) error {
+	startPoly, start, ok := m.locate(link.Start)
+	if !ok {
+		return ErrNavMeshPointOff
+	}
+	endPoly, end, ok := m.locate(link.End)
+	if !ok {
+		return ErrNavMeshPointOff
+	}
+	link.Start, link.End = start, end
+	index := int32(len(m.links))
+	m.links = append(m.links, link)
+	m.polygons[startPoly].links = append(m.polygons[startPoly].links, navLink{
+		a: start, b: end, poly: endPoly, offMesh: index,
+	})
+	if link.Bidirectional {
+		m.polygons[endPoly].links = append(m.polygons[endPoly].links, navLink{
+			a: end, b: start, poly: startPoly, offMesh: index, reverse: true,
+		})
+	}
+	return nil
+}
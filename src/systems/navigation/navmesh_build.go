@@ -0,0 +1,473 @@
+/*****************************************************************************/
+/* navmesh_build.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+	"kaiju/matrix"
+	"math"
+)
+
+var (
+	ErrNavMeshNoInput   = errors.New("navmesh input has no triangles")
+	ErrNavMeshBadConfig = errors.New("navmesh cell size and height must be positive")
+	ErrNavMeshNoSurface = errors.New("navmesh input has no walkable surface")
+)
+
+const (
+	dirNegX = iota
+	dirPosZ
+	dirPosX
+	dirNegZ
+)
+
+var cellDirOffsets = [4][2]int32{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+
+// NavMeshConfig describes the agent that will walk the navmesh along with
+// the resolution used to voxelize the level. Distances are in world units
+// and MaxSlope is in degrees.
+type NavMeshConfig struct {
+	CellSize    float32
+	CellHeight  float32
+	AgentRadius float32
+	AgentHeight float32
+	MaxClimb    float32
+	MaxSlope    float32
+	// MaxPolygonCells limits how many cells along each side a polygon can
+	// span, smaller values follow uneven terrain more closely
+	MaxPolygonCells int32
+}
+
+func DefaultNavMeshConfig() NavMeshConfig {
+	return NavMeshConfig{
+		CellSize:        0.3,
+		CellHeight:      0.2,
+		AgentRadius:     0.5,
+		AgentHeight:     2,
+		MaxClimb:        0.4,
+		MaxSlope:        45,
+		MaxPolygonCells: 32,
+	}
+}
+
+// NavMeshInput collects the level triangles in world space
+type NavMeshInput struct {
+	Vertices []matrix.Vec3
+	Indexes  []uint32
+}
+
+// AddMesh appends the triangles of a mesh after moving them by transform
+func (in *NavMeshInput) AddMesh(positions []matrix.Vec3, indexes []uint32, transform matrix.Mat4) {
+	base := uint32(len(in.Vertices))
+	for _, p := range positions {
+		in.Vertices = append(in.Vertices, transform.TransformPoint(p))
+	}
+	for _, i := range indexes {
+		in.Indexes = append(in.Indexes, base+i)
+	}
+}
+
+func (in *NavMeshInput) bounds() (matrix.Vec3, matrix.Vec3) {
+	lo, hi := in.Vertices[0], in.Vertices[0]
+	for _, v := range in.Vertices[1:] {
+		lo = matrix.Vec3Min(lo, v)
+		hi = matrix.Vec3Max(hi, v)
+	}
+	return lo, hi
+}
+
+type heightSpan struct {
+	min, max int32
+	walkable bool
+}
+
+// heightfield is a set of solid spans for each column of cells on the XZ
+// plane, spans in a column are sorted from the bottom up
+type heightfield struct {
+	origin  matrix.Vec3
+	width   int32
+	depth   int32
+	columns [][]heightSpan
+}
+
+func (hf *heightfield) addSpan(x, z, smin, smax int32, walkable bool, mergeDist int32) {
+	col := hf.columns[x+z*hf.width]
+	ns := heightSpan{smin, smax, walkable}
+	out := make([]heightSpan, 0, len(col)+1)
+	inserted := false
+	for _, s := range col {
+		if s.max < ns.min {
+			out = append(out, s)
+			continue
+		}
+		if s.min > ns.max {
+			if !inserted {
+				out = append(out, ns)
+				inserted = true
+			}
+			out = append(out, s)
+			continue
+		}
+		// Overlapping spans are merged, the walkable flag follows the top
+		ns.min = min(ns.min, s.min)
+		if s.max > ns.max {
+			if s.max-ns.max <= mergeDist {
+				ns.walkable = ns.walkable || s.walkable
+			} else {
+				ns.walkable = s.walkable
+			}
+			ns.max = s.max
+		} else if ns.max-s.max <= mergeDist {
+			ns.walkable = ns.walkable || s.walkable
+		}
+	}
+	if !inserted {
+		out = append(out, ns)
+	}
+	hf.columns[x+z*hf.width] = out
+}
+
+// clipPolygon keeps the part of the polygon on the positive side of the
+// plane where the axis equals value, or the negative side when flip is set
+func clipPolygon(in []matrix.Vec3, out []matrix.Vec3, axis int, value float32, flip bool) []matrix.Vec3 {
+	out = out[:0]
+	dist := func(p matrix.Vec3) float32 {
+		if flip {
+			return value - p[axis]
+		}
+		return p[axis] - value
+	}
+	for i := range in {
+		a := in[i]
+		b := in[(i+1)%len(in)]
+		da, db := dist(a), dist(b)
+		if da >= 0 {
+			out = append(out, a)
+		}
+		if (da >= 0) != (db >= 0) {
+			t := da / (da - db)
+			out = append(out, a.Add(b.Subtract(a).Scale(t)))
+		}
+	}
+	return out
+}
+
+func (hf *heightfield) rasterizeTriangle(a, b, c matrix.Vec3, cfg *NavMeshConfig, walkable bool) {
+	lo := matrix.Vec3Min(a, matrix.Vec3Min(b, c))
+	hi := matrix.Vec3Max(a, matrix.Vec3Max(b, c))
+	x0 := max(0, int32((lo.X()-hf.origin.X())/cfg.CellSize))
+	x1 := min(hf.width-1, int32((hi.X()-hf.origin.X())/cfg.CellSize))
+	z0 := max(0, int32((lo.Z()-hf.origin.Z())/cfg.CellSize))
+	z1 := min(hf.depth-1, int32((hi.Z()-hf.origin.Z())/cfg.CellSize))
+	mergeDist := int32(cfg.MaxClimb / cfg.CellHeight)
+	var bufA, bufB, bufC, bufD [12]matrix.Vec3
+	tri := []matrix.Vec3{a, b, c}
+	for z := z0; z <= z1; z++ {
+		cz := hf.origin.Z() + float32(z)*cfg.CellSize
+		row := clipPolygon(tri, bufA[:0], 2, cz, false)
+		row = clipPolygon(row, bufB[:0], 2, cz+cfg.CellSize, true)
+		if len(row) < 3 {
+			continue
+		}
+		for x := x0; x <= x1; x++ {
+			cx := hf.origin.X() + float32(x)*cfg.CellSize
+			cell := clipPolygon(row, bufC[:0], 0, cx, false)
+			cell = clipPolygon(cell, bufD[:0], 0, cx+cfg.CellSize, true)
+			if len(cell) < 3 {
+				continue
+			}
+			ymin, ymax := cell[0].Y(), cell[0].Y()
+			for _, p := range cell[1:] {
+				ymin = min(ymin, p.Y())
+				ymax = max(ymax, p.Y())
+			}
+			smin := max(0, int32(matrix.Floor((ymin-hf.origin.Y())/cfg.CellHeight)))
+			smax := max(smin+1, int32(matrix.Ceil((ymax-hf.origin.Y())/cfg.CellHeight)))
+			hf.addSpan(x, z, smin, smax, walkable, mergeDist)
+		}
+	}
+}
+
+// filter lets agents step up onto low obstacles like curbs and stairs then
+// removes the walkable flag from spans without enough head room
+func (hf *heightfield) filter(climb, height int32) {
+	for i := range hf.columns {
+		col := hf.columns[i]
+		prevWalkable := false
+		prevMax := int32(0)
+		for j := range col {
+			walkable := col[j].walkable
+			if !walkable && prevWalkable && col[j].max-prevMax <= climb {
+				col[j].walkable = true
+			}
+			prevWalkable = walkable
+			prevMax = col[j].max
+		}
+		for j := range col {
+			ceiling := int32(math.MaxInt32)
+			if j+1 < len(col) {
+				ceiling = col[j+1].min
+			}
+			if ceiling-col[j].max < height {
+				col[j].walkable = false
+			}
+		}
+	}
+}
+
+// navCell is the open space above a walkable span
+type navCell struct {
+	x, z    int32
+	floor   int32
+	ceiling int32
+	conn    [4]int32
+	dist    int32
+	poly    int32
+}
+
+type cellField struct {
+	cells   []navCell
+	columns [][]int32
+	width   int32
+	depth   int32
+}
+
+func (hf *heightfield) openCells(climb, height int32) cellField {
+	cf := cellField{
+		columns: make([][]int32, len(hf.columns)),
+		width:   hf.width,
+		depth:   hf.depth,
+	}
+	for i, col := range hf.columns {
+		for j := range col {
+			if !col[j].walkable {
+				continue
+			}
+			ceiling := int32(math.MaxInt32)
+			if j+1 < len(col) {
+				ceiling = col[j+1].min
+			}
+			cf.columns[i] = append(cf.columns[i], int32(len(cf.cells)))
+			cf.cells = append(cf.cells, navCell{
+				x:       int32(i) % hf.width,
+				z:       int32(i) / hf.width,
+				floor:   col[j].max,
+				ceiling: ceiling,
+				conn:    [4]int32{-1, -1, -1, -1},
+				poly:    -1,
+			})
+		}
+	}
+	for i := range cf.cells {
+		c := &cf.cells[i]
+		for d, off := range cellDirOffsets {
+			nx, nz := c.x+off[0], c.z+off[1]
+			if nx < 0 || nz < 0 || nx >= cf.width || nz >= cf.depth {
+				continue
+			}
+			for _, ni := range cf.columns[nx+nz*cf.width] {
+				n := &cf.cells[ni]
+				gap := min(c.ceiling, n.ceiling) - max(c.floor, n.floor)
+				if absInt32(n.floor-c.floor) <= climb && gap >= height {
+					c.conn[d] = ni
+					break
+				}
+			}
+		}
+	}
+	return cf
+}
+
+// erode removes the cells closer to the edge of the walkable area than the
+// agent radius so that paths keep the agent clear of walls
+func (cf *cellField) erode(radius int32) {
+	if radius <= 0 {
+		return
+	}
+	queue := make([]int32, 0, len(cf.cells))
+	for i := range cf.cells {
+		c := &cf.cells[i]
+		c.dist = -1
+		for _, n := range c.conn {
+			if n < 0 {
+				c.dist = 0
+				queue = append(queue, int32(i))
+				break
+			}
+		}
+	}
+	for len(queue) > 0 {
+		c := &cf.cells[queue[0]]
+		queue = queue[1:]
+		for _, n := range c.conn {
+			if n >= 0 && cf.cells[n].dist < 0 {
+				cf.cells[n].dist = c.dist + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	for i := range cf.cells {
+		if cf.cells[i].dist >= 0 && cf.cells[i].dist < radius {
+			cf.cells[i].poly = removedCell
+		}
+	}
+	for i := range cf.cells {
+		c := &cf.cells[i]
+		for d, n := range c.conn {
+			if n >= 0 && cf.cells[n].poly == removedCell {
+				c.conn[d] = -1
+			}
+		}
+	}
+}
+
+const removedCell = -2
+
+func (cf *cellField) isFree(i int32) bool {
+	return i >= 0 && cf.cells[i].poly == -1
+}
+
+// growRect greedily grows a rectangle of free connected cells from the
+// start cell along +X and then +Z, the returned rows are the cell indexes
+func (cf *cellField) growRect(start int32, maxCells int32) [][]int32 {
+	row := []int32{start}
+	for cur := start; int32(len(row)) < maxCells; {
+		n := cf.cells[cur].conn[dirPosX]
+		if !cf.isFree(n) {
+			break
+		}
+		row = append(row, n)
+		cur = n
+	}
+	rows := [][]int32{row}
+	for int32(len(rows)) < maxCells {
+		prev := rows[len(rows)-1]
+		next := make([]int32, 0, len(prev))
+		for i, p := range prev {
+			n := cf.cells[p].conn[dirPosZ]
+			if !cf.isFree(n) {
+				break
+			}
+			if i > 0 && cf.cells[next[i-1]].conn[dirPosX] != n {
+				break
+			}
+			next = append(next, n)
+		}
+		if len(next) != len(prev) {
+			break
+		}
+		rows = append(rows, next)
+	}
+	return rows
+}
+
+// voxelize rasterizes the input triangles and finds the open cells above
+// them that the configured agent can stand in, cells closer to a wall than
+// the agent radius are already removed
+func voxelize(input *NavMeshInput, cfg *NavMeshConfig) (heightfield, cellField, error) {
+	if len(input.Indexes) < 3 {
+		return heightfield{}, cellField{}, ErrNavMeshNoInput
+	}
+	if cfg.CellSize <= 0 || cfg.CellHeight <= 0 {
+		return heightfield{}, cellField{}, ErrNavMeshBadConfig
+	}
+	lo, hi := input.bounds()
+	hf := heightfield{
+		origin: lo,
+		width:  int32(matrix.Ceil((hi.X()-lo.X())/cfg.CellSize)) + 1,
+		depth:  int32(matrix.Ceil((hi.Z()-lo.Z())/cfg.CellSize)) + 1,
+	}
+	hf.columns = make([][]heightSpan, hf.width*hf.depth)
+	minNormalY := matrix.Cos(matrix.Deg2Rad(cfg.MaxSlope))
+	for i := 0; i+2 < len(input.Indexes); i += 3 {
+		a := input.Vertices[input.Indexes[i]]
+		b := input.Vertices[input.Indexes[i+1]]
+		c := input.Vertices[input.Indexes[i+2]]
+		normal := matrix.Vec3Cross(b.Subtract(a), c.Subtract(a))
+		length := normal.Length()
+		if length <= 0 {
+			continue
+		}
+		// Triangles are treated as two sided so winding does not matter
+		walkable := matrix.Abs(normal.Y()/length) >= minNormalY
+		hf.rasterizeTriangle(a, b, c, cfg, walkable)
+	}
+	climb := int32(cfg.MaxClimb / cfg.CellHeight)
+	height := int32(matrix.Ceil(cfg.AgentHeight / cfg.CellHeight))
+	hf.filter(climb, height)
+	cf := hf.openCells(climb, height)
+	cf.erode(int32(matrix.Ceil(cfg.AgentRadius / cfg.CellSize)))
+	return hf, cf, nil
+}
+
+// BuildNavMesh voxelizes the input triangles and creates a navmesh made
+// of convex polygons over the surfaces the configured agent can walk on
+func BuildNavMesh(input *NavMeshInput, cfg NavMeshConfig) (*NavMesh, error) {
+	if cfg.MaxPolygonCells <= 0 {
+		cfg.MaxPolygonCells = DefaultNavMeshConfig().MaxPolygonCells
+	}
+	hf, cf, err := voxelize(input, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	mesh := newNavMesh(cfg, hf.origin)
+	rects := [][][]int32{}
+	for i := range cf.cells {
+		if cf.cells[i].poly != -1 {
+			continue
+		}
+		rows := cf.growRect(int32(i), cfg.MaxPolygonCells)
+		mesh.addRect(&cf, rows)
+		rects = append(rects, rows)
+	}
+	if len(mesh.polygons) == 0 {
+		return nil, ErrNavMeshNoSurface
+	}
+	for i, rows := range rects {
+		mesh.connectRect(&cf, int32(i), rows)
+	}
+	mesh.buildBuckets()
+	return mesh, nil
+}
+
+func absInt32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
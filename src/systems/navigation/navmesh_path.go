@@ -0,0 +1,266 @@
+/*****************************************************************************/
+/* navmesh_path.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"container/heap"
+	"kaiju/matrix"
+)
+
+type polyNode struct {
+	parent *polyNode
+	link   *navLink
+	pos    matrix.Vec3
+	poly   int32
+	index  int
+	g, f   float32
+	open   bool
+}
+
+type polyQueue []*polyNode
+
+func (q polyQueue) Len() int           { return len(q) }
+func (q polyQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+
+func (q polyQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *polyQueue) Push(x any) {
+	n := x.(*polyNode)
+	n.index = len(*q)
+	n.open = true
+	*q = append(*q, n)
+}
+
+func (q *polyQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	n.open = false
+	*q = old[:len(old)-1]
+	return n
+}
+
+// FindPath searches the polygons between the start and end points and then
+// pulls the path tight around the corners of the polygons it passes through
+func (m *NavMesh) FindPath(start, end matrix.Vec3) ([]matrix.Vec3, error) {
+	startPoly, start, ok := m.locate(start)
+	if !ok {
+		return nil, ErrNavMeshPointOff
+	}
+	endPoly, end, ok := m.locate(end)
+	if !ok {
+		return nil, ErrNavMeshPointOff
+	}
+	corridor := m.findCorridor(startPoly, start, endPoly, end)
+	if len(corridor) == 0 {
+		return nil, ErrNavMeshNoPath
+	}
+	return m.stringPull(corridor, start, end), nil
+}
+
+// FindCorridor returns the indexes of the polygons that the path between
+// the two points passes through
+func (m *NavMesh) FindCorridor(start, end matrix.Vec3) ([]int, error) {
+	startPoly, start, ok := m.locate(start)
+	if !ok {
+		return nil, ErrNavMeshPointOff
+	}
+	endPoly, end, ok := m.locate(end)
+	if !ok {
+		return nil, ErrNavMeshPointOff
+	}
+	corridor := m.findCorridor(startPoly, start, endPoly, end)
+	if len(corridor) == 0 {
+		return nil, ErrNavMeshNoPath
+	}
+	out := make([]int, len(corridor))
+	for i, n := range corridor {
+		out[i] = int(n.poly)
+	}
+	return out, nil
+}
+
+// findCorridor runs A* over the polygon graph. Each node is positioned at
+// the middle of the portal it was entered through which keeps the costs
+// close to the length of the final path.
+func (m *NavMesh) findCorridor(startPoly int32, start matrix.Vec3, endPoly int32, end matrix.Vec3) []*polyNode {
+	nodes := map[int32]*polyNode{}
+	first := &polyNode{poly: startPoly, pos: start, f: start.Distance(end)}
+	nodes[startPoly] = first
+	open := polyQueue{}
+	heap.Push(&open, first)
+	for open.Len() > 0 {
+		current := heap.Pop(&open).(*polyNode)
+		if current.poly == endPoly {
+			corridor := []*polyNode{}
+			for n := current; n != nil; n = n.parent {
+				corridor = append(corridor, n)
+			}
+			for i, j := 0, len(corridor)-1; i < j; i, j = i+1, j-1 {
+				corridor[i], corridor[j] = corridor[j], corridor[i]
+			}
+			return corridor
+		}
+		links := m.polygons[current.poly].links
+		for i := range links {
+			link := &links[i]
+			var pos matrix.Vec3
+			var cost float32
+			if link.offMesh >= 0 {
+				pos = link.b
+				cost = current.pos.Distance(link.a) + link.a.Distance(link.b) + m.links[link.offMesh].Cost
+			} else {
+				pos = link.a.Add(link.b).Scale(0.5)
+				cost = current.pos.Distance(pos)
+			}
+			g := current.g + cost
+			n, seen := nodes[link.poly]
+			if seen && g >= n.g {
+				continue
+			}
+			if !seen {
+				n = &polyNode{poly: link.poly}
+				nodes[link.poly] = n
+			}
+			n.parent, n.link, n.pos, n.g = current, link, pos, g
+			n.f = g + pos.Distance(end)
+			if n.open {
+				heap.Fix(&open, n.index)
+			} else {
+				heap.Push(&open, n)
+			}
+		}
+	}
+	return nil
+}
+
+// stringPull turns the corridor into a list of points using the funnel
+// algorithm, off-mesh links split the corridor into separate funnels
+func (m *NavMesh) stringPull(corridor []*polyNode, start, end matrix.Vec3) []matrix.Vec3 {
+	points := []matrix.Vec3{start}
+	portals := [][2]matrix.Vec3{{start, start}}
+	flush := func(target matrix.Vec3) {
+		portals = append(portals, [2]matrix.Vec3{target, target})
+		for _, p := range funnel(portals)[1:] {
+			points = appendPathPoint(points, p)
+		}
+	}
+	for i := 1; i < len(corridor); i++ {
+		link := corridor[i].link
+		if link.offMesh >= 0 {
+			flush(link.a)
+			points = appendPathPoint(points, link.b)
+			portals = [][2]matrix.Vec3{{link.b, link.b}}
+			continue
+		}
+		from := m.polygons[corridor[i-1].poly].Center
+		to := m.polygons[corridor[i].poly].Center
+		left, right := link.a, link.b
+		if triArea2(from, to, left) > triArea2(from, to, right) {
+			left, right = right, left
+		}
+		portals = append(portals, [2]matrix.Vec3{left, right})
+	}
+	flush(end)
+	return points
+}
+
+func appendPathPoint(points []matrix.Vec3, p matrix.Vec3) []matrix.Vec3 {
+	if len(points) > 0 && sameXZ(points[len(points)-1], p) {
+		return points
+	}
+	return append(points, p)
+}
+
+// triArea2 is twice the signed area of the triangle on the XZ plane, it is
+// positive when c is on the right of the line from a to b
+func triArea2(a, b, c matrix.Vec3) float32 {
+	abx, abz := b.X()-a.X(), b.Z()-a.Z()
+	acx, acz := c.X()-a.X(), c.Z()-a.Z()
+	return acx*abz - abx*acz
+}
+
+func sameXZ(a, b matrix.Vec3) bool {
+	dx, dz := a.X()-b.X(), a.Z()-b.Z()
+	return dx*dx+dz*dz < 0.000001
+}
+
+// funnel is the simple stupid funnel algorithm, each portal is a pair of
+// left and right points and the first and last portals are the start and
+// end of the path collapsed to a single point
+func funnel(portals [][2]matrix.Vec3) []matrix.Vec3 {
+	apex, left, right := portals[0][0], portals[0][0], portals[0][1]
+	apexIndex, leftIndex, rightIndex := 0, 0, 0
+	points := []matrix.Vec3{apex}
+	for i := 1; i < len(portals); i++ {
+		l, r := portals[i][0], portals[i][1]
+		if triArea2(apex, right, r) <= 0 {
+			if sameXZ(apex, right) || triArea2(apex, left, r) > 0 {
+				right, rightIndex = r, i
+			} else {
+				apex, apexIndex = left, leftIndex
+				points = append(points, apex)
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+		if triArea2(apex, left, l) >= 0 {
+			if sameXZ(apex, left) || triArea2(apex, right, l) < 0 {
+				left, leftIndex = l, i
+			} else {
+				apex, apexIndex = right, rightIndex
+				points = append(points, apex)
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+	}
+	last := portals[len(portals)-1][0]
+	if !sameXZ(points[len(points)-1], last) {
+		points = append(points, last)
+	}
+	return points
+}
@@ -0,0 +1,134 @@
+/*****************************************************************************/
+/* navmesh_test.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+	"kaiju/matrix"
+	"testing"
+)
+
+func addBox(in *NavMeshInput, lo, hi matrix.Vec3) {
+	base := uint32(len(in.Vertices))
+	for i := 0; i < 8; i++ {
+		v := lo
+		if i&1 != 0 {
+			v.SetX(hi.X())
+		}
+		if i&2 != 0 {
+			v.SetY(hi.Y())
+		}
+		if i&4 != 0 {
+			v.SetZ(hi.Z())
+		}
+		in.Vertices = append(in.Vertices, v)
+	}
+	faces := [6][4]uint32{
+		{0, 1, 5, 4}, {2, 6, 7, 3}, {0, 4, 6, 2},
+		{1, 3, 7, 5}, {0, 2, 3, 1}, {4, 5, 7, 6},
+	}
+	for _, f := range faces {
+		in.Indexes = append(in.Indexes,
+			base+f[0], base+f[1], base+f[2], base+f[0], base+f[2], base+f[3])
+	}
+}
+
+func pathLength(path []matrix.Vec3) float32 {
+	length := float32(0)
+	for i := 1; i < len(path); i++ {
+		length += path[i].Distance(path[i-1])
+	}
+	return length
+}
+
+func TestNavMeshPathAroundWall(t *testing.T) {
+	in := &NavMeshInput{}
+	addBox(in, matrix.Vec3{-10, -1, -10}, matrix.Vec3{10, 0, 10})
+	addBox(in, matrix.Vec3{-1, 0, -6}, matrix.Vec3{1, 3, 6})
+	mesh, err := BuildNavMesh(in, DefaultNavMeshConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := matrix.Vec3{-5, 0, 0}, matrix.Vec3{5, 0, 0}
+	path, err := mesh.FindPath(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameXZ(path[0], start) || !sameXZ(path[len(path)-1], end) {
+		t.Fatalf("path should run from start to end, got %v", path)
+	}
+	if len(path) < 4 {
+		t.Fatalf("path should turn around both wall corners, got %v", path)
+	}
+	if l := pathLength(path); l < 14 || l > 20 {
+		t.Fatalf("unexpected path length %f for %v", l, path)
+	}
+	for _, p := range path {
+		if p.X() > -1 && p.X() < 1 && p.Z() > -6 && p.Z() < 6 {
+			t.Fatalf("path point %v is inside of the wall", p)
+		}
+	}
+}
+
+func TestNavMeshOffMeshLink(t *testing.T) {
+	in := &NavMeshInput{}
+	addBox(in, matrix.Vec3{-10, -1, -3}, matrix.Vec3{-2, 0, 3})
+	addBox(in, matrix.Vec3{2, -3, -3}, matrix.Vec3{10, -2, 3})
+	mesh, err := BuildNavMesh(in, DefaultNavMeshConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := matrix.Vec3{-6, 0, 0}, matrix.Vec3{6, -2, 0}
+	if _, err := mesh.FindPath(start, end); !errors.Is(err, ErrNavMeshNoPath) {
+		t.Fatalf("expected no path between the platforms, got %v", err)
+	}
+	link := OffMeshLink{Start: matrix.Vec3{-3, 0, 0}, End: matrix.Vec3{3, -2, 0}}
+	if err := mesh.AddOffMeshLink(link); err != nil {
+		t.Fatal(err)
+	}
+	path, err := mesh.FindPath(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 4 {
+		t.Fatalf("expected the path to jump across the link, got %v", path)
+	}
+	if _, err := mesh.FindPath(end, start); !errors.Is(err, ErrNavMeshNoPath) {
+		t.Fatalf("the link should only work in one direction, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+/*****************************************************************************/
+/* path_service.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// PathOwner is whatever is waiting on a path, usually an *engine.Entity.
+// Requests are dropped without calling back once their owner is destroyed.
+type PathOwner interface {
+	IsDestroyed() bool
+}
+
+type PathResult struct {
+	Path  []matrix.Vec3i
+	Cost  float64
+	Found bool
+}
+
+type PathRequestId uint64
+
+type pathJob struct {
+	owner    PathOwner
+	grid     Grid
+	done     func(PathResult)
+	options  AStarOptions
+	result   PathResult
+	start    matrix.Vec3i
+	end      matrix.Vec3i
+	id       PathRequestId
+	canceled atomic.Bool
+}
+
+func (j *pathJob) isDropped() bool {
+	return j.canceled.Load() || (j.owner != nil && j.owner.IsDestroyed())
+}
+
+// PathService runs grid searches on worker goroutines. Requests are queued
+// from the engine thread and handed to the workers by Update, at most
+// MaxSearchesPerFrame at a time, and their callbacks are run by a later
+// Update on the engine thread. Workers search a copy of the grid taken by
+// SetGrid so that the live grid can change while searches are running.
+type PathService struct {
+	grid                Grid
+	queue               []*pathJob
+	jobs                chan *pathJob
+	finished            []*pathJob
+	delivering          []*pathJob
+	active              map[PathRequestId]*pathJob
+	mutex               sync.Mutex
+	workers             sync.WaitGroup
+	MaxSearchesPerFrame int
+	inFlight            int
+	nextId              PathRequestId
+	closed              bool
+}
+
+// NewPathService starts the worker goroutines, a worker count of 0 or less
+// uses one worker per CPU
+func NewPathService(grid Grid, workers int) *PathService {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	s := &PathService{
+		jobs:                make(chan *pathJob, workers*4),
+		active:              make(map[PathRequestId]*pathJob),
+		MaxSearchesPerFrame: 32,
+	}
+	s.SetGrid(grid)
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// SetGrid snapshots the grid, searches started after this call see the
+// new snapshot and searches already running keep using the old one
+func (s *PathService) SetGrid(grid Grid) {
+	s.grid = grid.Clone()
+}
+
+// Pending is the number of requests that have not been delivered yet
+func (s *PathService) Pending() int { return len(s.active) }
+
+// Request queues a search between two cells, done is called on the engine
+// thread from Update unless the request is canceled or the owner is
+// destroyed first. Nil options use DefaultAStarOptions.
+func (s *PathService) Request(owner PathOwner, start, end matrix.Vec3i, options *AStarOptions, done func(PathResult)) PathRequestId {
+	if s.closed {
+		return 0
+	}
+	s.nextId++
+	job := &pathJob{
+		id:    s.nextId,
+		owner: owner,
+		start: start,
+		end:   end,
+		done:  done,
+	}
+	if options != nil {
+		job.options = *options
+	} else {
+		job.options = DefaultAStarOptions()
+	}
+	s.active[job.id] = job
+	s.queue = append(s.queue, job)
+	return job.id
+}
+
+// Cancel stops a request from being searched or delivered
+func (s *PathService) Cancel(id PathRequestId) {
+	if job, ok := s.active[id]; ok {
+		job.canceled.Store(true)
+	}
+}
+
+// Update delivers the finished searches and hands queued requests to the
+// workers, it must be called from the engine thread and can be added
+// directly to a host updater
+func (s *PathService) Update(float64) {
+	s.deliver()
+	s.dispatch()
+}
+
+func (s *PathService) deliver() {
+	s.mutex.Lock()
+	s.delivering, s.finished = s.finished, s.delivering[:0]
+	s.mutex.Unlock()
+	for i, job := range s.delivering {
+		s.inFlight--
+		delete(s.active, job.id)
+		if !job.isDropped() && job.done != nil {
+			job.done(job.result)
+		}
+		s.delivering[i] = nil
+	}
+}
+
+func (s *PathService) dispatch() {
+	sent := 0
+	for len(s.queue) > 0 && sent < s.MaxSearchesPerFrame && s.inFlight < cap(s.jobs) {
+		job := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		if job.isDropped() {
+			delete(s.active, job.id)
+			continue
+		}
+		job.grid = s.grid
+		s.jobs <- job
+		s.inFlight++
+		sent++
+	}
+}
+
+func (s *PathService) work() {
+	defer s.workers.Done()
+	searcher := NewSearcher()
+	for job := range s.jobs {
+		if !job.canceled.Load() {
+			path := searcher.FindPath(job.grid, job.start, job.end, &job.options)
+			job.result = PathResult{
+				Path:  slices.Clone(path),
+				Cost:  searcher.PathCost(),
+				Found: path != nil,
+			}
+		}
+		job.grid = nil
+		s.mutex.Lock()
+		s.finished = append(s.finished, job)
+		s.mutex.Unlock()
+	}
+}
+
+// Close stops the workers after they finish their current searches, any
+// request that has not been delivered is dropped
+func (s *PathService) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.jobs)
+	s.workers.Wait()
+	s.queue = nil
+	s.finished = nil
+	clear(s.active)
+}
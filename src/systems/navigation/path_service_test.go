@@ -0,0 +1,106 @@
+/*****************************************************************************/
+/* path_service_test.go                                                      */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"testing"
+	"time"
+)
+
+type testOwner struct{ destroyed bool }
+
+func (o *testOwner) IsDestroyed() bool { return o.destroyed }
+
+func waitForPaths(t *testing.T, s *PathService) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d path requests never finished", s.Pending())
+		}
+		s.Update(0)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPathServiceDeliversResults(t *testing.T) {
+	grid := NewGrid(16, 1, 16)
+	s := NewPathService(grid, 2)
+	defer s.Close()
+	s.MaxSearchesPerFrame = 3
+	// Changes after the snapshot must not be seen by the workers
+	grid.BlockCell(matrix.Vec3i{15, 0, 15}, 1)
+	delivered := 0
+	for i := 0; i < 10; i++ {
+		s.Request(nil, matrix.Vec3i{0, 0, 0}, matrix.Vec3i{15, 0, 15}, nil, func(r PathResult) {
+			delivered++
+			if !r.Found || r.Path[len(r.Path)-1] != (matrix.Vec3i{15, 0, 15}) {
+				t.Errorf("unexpected path %v", r.Path)
+			}
+		})
+	}
+	s.Update(0)
+	if s.inFlight > 3 {
+		t.Fatalf("expected at most 3 searches to start in a frame, got %d", s.inFlight)
+	}
+	if delivered != 0 {
+		t.Fatal("results should only be delivered by a later update")
+	}
+	waitForPaths(t, s)
+	if delivered != 10 {
+		t.Fatalf("expected 10 results, got %d", delivered)
+	}
+}
+
+func TestPathServiceCancel(t *testing.T) {
+	s := NewPathService(NewGrid(8, 1, 8), 1)
+	defer s.Close()
+	owner := &testOwner{}
+	delivered := 0
+	done := func(PathResult) { delivered++ }
+	canceled := s.Request(nil, matrix.Vec3i{}, matrix.Vec3i{7, 0, 7}, nil, done)
+	s.Request(owner, matrix.Vec3i{}, matrix.Vec3i{7, 0, 7}, nil, done)
+	s.Request(nil, matrix.Vec3i{}, matrix.Vec3i{7, 0, 7}, nil, done)
+	s.Cancel(canceled)
+	owner.destroyed = true
+	waitForPaths(t, s)
+	if delivered != 1 {
+		t.Fatalf("expected only the live request to be delivered, got %d", delivered)
+	}
+}
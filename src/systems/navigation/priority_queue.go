@@ -39,16 +39,29 @@ package navigation
 
 import "kaiju/matrix"
 
+// Node is a cell along a path returned by AStar, each node links back to
+// the one before it
 type Node struct {
 	x, y, z int32
 	g, h, f float64
 	parent  *Node
+	index   int
 }
 
 func (n Node) XYZ() matrix.Vec3i {
 	return matrix.Vec3i{n.x, n.y, n.z}
 }
 
+// Cost is the total cost of the path from the start up to this node
+func (n Node) Cost() float64 {
+	return n.g
+}
+
+// PriorityQueue is a container/heap of nodes ordered by their estimated
+// total cost.
+//
+// Deprecated: AStar searches with a Searcher, which keeps its own queue.
+// Use Searcher.FindPath for searches that need more control.
 type PriorityQueue []*Node
 
 func (pq PriorityQueue) Len() int { return len(pq) }
@@ -58,10 +71,13 @@ func (pq PriorityQueue) Less(i, j int) bool {
 
 func (pq PriorityQueue) Swap(i, j int) {
 	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
 }
 
 func (pq *PriorityQueue) Push(x interface{}) {
 	item := x.(*Node)
+	item.index = len(*pq)
 	*pq = append(*pq, item)
 }
 
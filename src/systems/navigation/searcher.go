@@ -0,0 +1,337 @@
+/*****************************************************************************/
+/* searcher.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math"
+)
+
+type searchEntry struct {
+	f     float32
+	index int32
+}
+
+// Searcher holds the state of a grid path search so that it can be reused
+// between searches without allocating. All per-cell state is stored in flat
+// arrays indexed by cell and is invalidated by bumping the generation rather
+// than clearing it. A Searcher is not safe for concurrent use, create one
+// for each goroutine that searches.
+type Searcher struct {
+	g       []float32
+	parent  []int32
+	state   []uint32
+	heapPos []int32
+	open    []searchEntry
+	path    []matrix.Vec3i
+	cost    float64
+	// DisableJumpPoints forces a regular A* search on grids where jump
+	// point search would otherwise be used
+	DisableJumpPoints bool
+	generation        uint32
+	height, depth     int32
+	width             int32
+	boundsMin         matrix.Vec3i
+	boundsMax         matrix.Vec3i
+	bounded           bool
+}
+
+func NewSearcher() *Searcher {
+	return &Searcher{}
+}
+
+// SetBounds keeps the following searches inside of the box between the two
+// cells, both cells are included in the box
+func (s *Searcher) SetBounds(min, max matrix.Vec3i) {
+	s.boundsMin, s.boundsMax, s.bounded = min, max, true
+}
+
+func (s *Searcher) ClearBounds() { s.bounded = false }
+
+func (s *Searcher) inBounds(x, y, z int32) bool {
+	if x < 0 || y < 0 || z < 0 || x >= s.width || y >= s.height || z >= s.depth {
+		return false
+	}
+	return !s.bounded || (x >= s.boundsMin.X() && y >= s.boundsMin.Y() && z >= s.boundsMin.Z() &&
+		x <= s.boundsMax.X() && y <= s.boundsMax.Y() && z <= s.boundsMax.Z())
+}
+
+// PathCost is the cost of the path found by the last call to FindPath
+func (s *Searcher) PathCost() float64 { return s.cost }
+
+func (s *Searcher) reset(grid Grid) {
+	s.width, s.height, s.depth = int32(grid.Width()), int32(grid.Height()), int32(grid.Depth())
+	count := int(s.width * s.height * s.depth)
+	if len(s.state) < count {
+		s.g = make([]float32, count)
+		s.parent = make([]int32, count)
+		s.state = make([]uint32, count)
+		s.heapPos = make([]int32, count)
+		s.generation = 0
+	}
+	s.generation++
+	// The lowest bit of the state marks closed cells
+	if s.generation >= math.MaxUint32>>1 {
+		clear(s.state)
+		s.generation = 1
+	}
+	s.open = s.open[:0]
+	s.path = s.path[:0]
+	s.cost = 0
+}
+
+func (s *Searcher) index(x, y, z int32) int32 { return (x*s.height+y)*s.depth + z }
+
+func (s *Searcher) coords(index int32) (int32, int32, int32) {
+	layer := s.height * s.depth
+	r := index % layer
+	return index / layer, r / s.depth, r % s.depth
+}
+
+func (s *Searcher) isSeen(index int32) bool   { return s.state[index]>>1 == s.generation }
+func (s *Searcher) isClosed(index int32) bool { return s.state[index] == s.generation<<1|1 }
+
+func (s *Searcher) push(index int32, g, f float32, parent int32) {
+	s.g[index] = g
+	s.parent[index] = parent
+	s.state[index] = s.generation << 1
+	s.open = append(s.open, searchEntry{f, index})
+	s.heapPos[index] = int32(len(s.open) - 1)
+	s.siftUp(len(s.open) - 1)
+}
+
+// update lowers the cost of a cell that is already in the open list
+func (s *Searcher) update(index int32, g float32, parent int32) {
+	pos := s.heapPos[index]
+	s.open[pos].f += g - s.g[index]
+	s.g[index] = g
+	s.parent[index] = parent
+	s.siftUp(int(pos))
+}
+
+func (s *Searcher) pop() int32 {
+	top := s.open[0].index
+	last := len(s.open) - 1
+	s.open[0] = s.open[last]
+	s.heapPos[s.open[0].index] = 0
+	s.open = s.open[:last]
+	if last > 0 {
+		s.siftDown(0)
+	}
+	s.state[top] |= 1
+	return top
+}
+
+func (s *Searcher) siftUp(i int) {
+	e := s.open[i]
+	for i > 0 {
+		p := (i - 1) / 2
+		if s.open[p].f <= e.f {
+			break
+		}
+		s.open[i] = s.open[p]
+		s.heapPos[s.open[i].index] = int32(i)
+		i = p
+	}
+	s.open[i] = e
+	s.heapPos[e.index] = int32(i)
+}
+
+func (s *Searcher) siftDown(i int) {
+	e := s.open[i]
+	n := len(s.open)
+	for {
+		c := 2*i + 1
+		if c >= n {
+			break
+		}
+		if c+1 < n && s.open[c+1].f < s.open[c].f {
+			c++
+		}
+		if e.f <= s.open[c].f {
+			break
+		}
+		s.open[i] = s.open[c]
+		s.heapPos[s.open[i].index] = int32(i)
+		i = c
+	}
+	s.open[i] = e
+	s.heapPos[e.index] = int32(i)
+}
+
+// FindPath finds the cheapest path between two cells of the grid following
+// the same rules as AStarWithOptions. Jump point search is used for 8-way
+// searches on grids where every passable cell costs the same and corners
+// can not be cut. The returned slice is reused by the next search.
+func (s *Searcher) FindPath(grid Grid, start, end matrix.Vec3i, options *AStarOptions) []matrix.Vec3i {
+	costs := &options.Costs
+	if options.Connectivity == Connect4 || options.Connectivity == Connect8 {
+		end[matrix.Vy] = start[matrix.Vy]
+	}
+	if !grid.IsValid(start) {
+		return nil
+	}
+	if !grid.passable(end[0], end[1], end[2], costs) {
+		end = findNearestUnblockedNode(grid, end, costs)
+		if end[matrix.Vx] == -1 && end[matrix.Vy] == -1 && end[matrix.Vz] == -1 {
+			return nil
+		}
+	}
+	s.reset(grid)
+	var found bool
+	if cost, uniform := costs.uniformCost(); uniform && !s.DisableJumpPoints &&
+		options.Connectivity == Connect8 && options.Corners == CornerCutNever && start.Y() == end.Y() {
+		found = s.jumpSearch(grid, start, end, costs, float32(cost))
+	} else {
+		found = s.search(grid, start, end, options)
+	}
+	if !found {
+		return nil
+	}
+	return s.buildPath(grid, end, costs)
+}
+
+func (s *Searcher) search(grid Grid, start, end matrix.Vec3i, options *AStarOptions) bool {
+	costs := &options.Costs
+	heuristic := options.heuristic()
+	hScale := costs.minCost()
+	directions := connectivityDirections[options.Connectivity]
+	endIndex := s.index(end[0], end[1], end[2])
+	startIndex := s.index(start[0], start[1], start[2])
+	s.push(startIndex, 0, float32(heuristic(start, end)*hScale), -1)
+	for len(s.open) > 0 {
+		current := s.pop()
+		if current == endIndex {
+			return true
+		}
+		x, y, z := s.coords(current)
+		for i := range directions {
+			dir := &directions[i]
+			nx, ny, nz := x+dir.offset[0], y+dir.offset[1], z+dir.offset[2]
+			if !s.inBounds(nx, ny, nz) {
+				continue
+			}
+			next := s.index(nx, ny, nz)
+			if s.isClosed(next) {
+				continue
+			}
+			cellType := grid[nx][ny][nz]
+			if !costs.IsPassable(cellType) || !grid.canStep(x, y, z, dir.offset, options.Corners, costs) {
+				continue
+			}
+			g := s.g[current] + float32(dir.length*costs.Cost(cellType))
+			if s.isSeen(next) {
+				if g < s.g[next] {
+					s.update(next, g, current)
+				}
+				continue
+			}
+			h := float32(heuristic(matrix.Vec3i{nx, ny, nz}, end) * hScale)
+			s.push(next, g, g+h, current)
+		}
+	}
+	return false
+}
+
+// buildPath walks the parents back from the end cell, cells between jump
+// points are filled in so the path always moves one cell at a time
+func (s *Searcher) buildPath(grid Grid, end matrix.Vec3i, costs *CostTable) []matrix.Vec3i {
+	for i := s.index(end[0], end[1], end[2]); i >= 0; i = s.parent[i] {
+		x, y, z := s.coords(i)
+		s.path = append(s.path, matrix.Vec3i{x, y, z})
+	}
+	reverseCells(s.path)
+	count := len(s.path)
+	for i := 1; i < count; i++ {
+		from, to := s.path[i-1], s.path[i]
+		for from != to {
+			for a := range from {
+				from[a] += sign32(to[a] - from[a])
+			}
+			s.path = append(s.path, from)
+		}
+	}
+	// The filled path was appended after the jump points, move it to the
+	// front and measure its cost
+	s.path = append(s.path[:1], s.path[count:]...)
+	s.cost = 0
+	for i := 1; i < len(s.path); i++ {
+		s.cost += stepCost(grid, s.path[i-1], s.path[i], costs)
+	}
+	return s.path
+}
+
+func stepCost(grid Grid, from, to matrix.Vec3i, costs *CostTable) float64 {
+	axes := 0
+	for a := range from {
+		if from[a] != to[a] {
+			axes++
+		}
+	}
+	return math.Sqrt(float64(axes)) * costs.Cost(grid[to.X()][to.Y()][to.Z()])
+}
+
+func reverseCells(cells []matrix.Vec3i) {
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+}
+
+func sign32(v int32) int32 {
+	if v > 0 {
+		return 1
+	} else if v < 0 {
+		return -1
+	}
+	return 0
+}
+
+// uniformCost reports if every passable block type costs the same
+func (t *CostTable) uniformCost() (float64, bool) {
+	cost := -1.0
+	for i := range t {
+		if !t.IsPassable(int8(i)) {
+			continue
+		}
+		if cost >= 0 && t[i] != cost {
+			return 0, false
+		}
+		cost = t[i]
+	}
+	return cost, cost > 0
+}
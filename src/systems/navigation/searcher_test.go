@@ -0,0 +1,314 @@
+/*****************************************************************************/
+/* searcher_test.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"container/heap"
+	"kaiju/matrix"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type referenceNode struct {
+	cell  [3]int32
+	g, f  float64
+	index int
+}
+
+type referenceQueue []*referenceNode
+
+func (q referenceQueue) Len() int           { return len(q) }
+func (q referenceQueue) Less(i, j int) bool { return q[i].f < q[j].f }
+
+func (q referenceQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *referenceQueue) Push(x any) {
+	n := x.(*referenceNode)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+
+func (q *referenceQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// referenceAStar is the straightforward map based search that Searcher
+// replaced, it is kept to check results and measure the speedup. It
+// returns the cost of the cheapest path and if a path was found.
+func referenceAStar(grid Grid, start, end matrix.Vec3i, options AStarOptions) (float64, bool) {
+	costs := &options.Costs
+	if options.Connectivity == Connect4 || options.Connectivity == Connect8 {
+		end[matrix.Vy] = start[matrix.Vy]
+	}
+	if !grid.passable(end[0], end[1], end[2], costs) {
+		end = findNearestUnblockedNode(grid, end, costs)
+		if end[matrix.Vx] == -1 && end[matrix.Vy] == -1 && end[matrix.Vz] == -1 {
+			return 0, false
+		}
+	}
+	heuristic := options.heuristic()
+	hScale := costs.minCost()
+	directions := connectivityDirections[options.Connectivity]
+	openSet := make(referenceQueue, 0)
+	nodes := make(map[[3]int32]*referenceNode)
+	closedSet := make(map[[3]int32]bool)
+	startNode := &referenceNode{cell: start, f: heuristic(start, end) * hScale}
+	nodes[startNode.cell] = startNode
+	heap.Push(&openSet, startNode)
+	for len(openSet) > 0 {
+		current := heap.Pop(&openSet).(*referenceNode)
+		if current.cell == [3]int32(end) {
+			return current.g, true
+		}
+		closedSet[current.cell] = true
+		c := current.cell
+		for _, dir := range directions {
+			key := [3]int32{c[0] + dir.offset[0], c[1] + dir.offset[1], c[2] + dir.offset[2]}
+			if closedSet[key] || !grid.passable(key[0], key[1], key[2], costs) ||
+				!grid.canStep(c[0], c[1], c[2], dir.offset, options.Corners, costs) {
+				continue
+			}
+			tentativeG := current.g + dir.length*costs.Cost(grid[key[0]][key[1]][key[2]])
+			neighbor, open := nodes[key]
+			if open && tentativeG >= neighbor.g {
+				continue
+			}
+			if !open {
+				neighbor = &referenceNode{cell: key}
+				nodes[key] = neighbor
+			}
+			neighbor.f = tentativeG + heuristic(matrix.Vec3i(key), end)*hScale
+			neighbor.g = tentativeG
+			if open {
+				heap.Fix(&openSet, neighbor.index)
+			} else {
+				heap.Push(&openSet, neighbor)
+			}
+		}
+	}
+	return 0, false
+}
+
+func randomGrid(rng *rand.Rand, width, height, depth int, fill float64) Grid {
+	grid := NewGrid(width, height, depth)
+	for x := range grid {
+		for y := range grid[x] {
+			for z := range grid[x][y] {
+				if rng.Float64() < fill {
+					grid[x][y][z] = 1
+				}
+			}
+		}
+	}
+	return grid
+}
+
+func checkPath(t *testing.T, grid Grid, path []matrix.Vec3i, options *AStarOptions) {
+	t.Helper()
+	for i, c := range path {
+		if !grid.passable(c.X(), c.Y(), c.Z(), &options.Costs) {
+			t.Fatalf("path enters blocked cell %v", c)
+		}
+		if i == 0 {
+			continue
+		}
+		offset := [3]int32{}
+		for a := range offset {
+			offset[a] = c[a] - path[i-1][a]
+			if offset[a] < -1 || offset[a] > 1 {
+				t.Fatalf("path jumps from %v to %v", path[i-1], c)
+			}
+		}
+		if !grid.canStep(path[i-1].X(), path[i-1].Y(), path[i-1].Z(), offset, options.Corners, &options.Costs) {
+			t.Fatalf("path cuts a corner from %v to %v", path[i-1], c)
+		}
+	}
+}
+
+func TestSearcherMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := NewSearcher()
+	for _, conn := range []Connectivity{Connect4, Connect8, Connect6, Connect26} {
+		for _, corners := range []CornerRule{CornerCutAllow, CornerCutNever, CornerCutIfOneOpen} {
+			options := DefaultAStarOptions()
+			options.Connectivity = conn
+			options.Corners = corners
+			options.Costs.SetCost(2, 3)
+			for i := 0; i < 20; i++ {
+				grid := randomGrid(rng, 24, 4, 24, 0.3)
+				for j := 0; j < 40; j++ {
+					grid[rng.Intn(24)][rng.Intn(4)][rng.Intn(24)] = 2
+				}
+				start := matrix.Vec3i{0, 0, 0}
+				end := matrix.Vec3i{23, 3, 23}
+				grid[0][0][0] = 0
+				want, found := referenceAStar(grid, start, end, options)
+				path := s.FindPath(grid, start, end, &options)
+				if found != (path != nil) {
+					t.Fatalf("reference found path: %t, searcher found path: %t", found, path != nil)
+				}
+				if path == nil {
+					continue
+				}
+				checkPath(t, grid, path, &options)
+				if math.Abs(s.PathCost()-want) > 0.001 {
+					t.Fatalf("expected path cost %f, got %f", want, s.PathCost())
+				}
+			}
+		}
+	}
+}
+
+func TestJumpPointSearchMatchesAStar(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	jps := NewSearcher()
+	plain := NewSearcher()
+	plain.DisableJumpPoints = true
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect8
+	options.Corners = CornerCutNever
+	for i := 0; i < 200; i++ {
+		grid := randomGrid(rng, 48, 1, 48, 0.35)
+		start := matrix.Vec3i{int32(rng.Intn(48)), 0, int32(rng.Intn(48))}
+		end := matrix.Vec3i{int32(rng.Intn(48)), 0, int32(rng.Intn(48))}
+		grid[start.X()][0][start.Z()] = 0
+		grid[end.X()][0][end.Z()] = 0
+		want := plain.FindPath(grid, start, end, &options) != nil
+		path := jps.FindPath(grid, start, end, &options)
+		if want != (path != nil) {
+			t.Fatalf("jump point search found path: %t, expected %t", path != nil, want)
+		}
+		if path == nil {
+			continue
+		}
+		checkPath(t, grid, path, &options)
+		if path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("path runs from %v to %v", path[0], path[len(path)-1])
+		}
+		if math.Abs(jps.PathCost()-plain.PathCost()) > 0.001 {
+			t.Fatalf("expected path cost %f, got %f", plain.PathCost(), jps.PathCost())
+		}
+	}
+}
+
+// benchmarkGrid3D has a wall through the middle of the grid with a hole in
+// the far corner so that the search has to explore most of the grid
+func benchmarkGrid3D() (Grid, matrix.Vec3i, matrix.Vec3i) {
+	grid := randomGrid(rand.New(rand.NewSource(3)), 128, 128, 128, 0.1)
+	for y := range grid[64] {
+		for z := range grid[64][y] {
+			grid[64][y][z] = 1
+		}
+	}
+	grid[64][127][0] = 0
+	start, end := matrix.Vec3i{0, 0, 0}, matrix.Vec3i{127, 127, 127}
+	grid[0][0][0] = 0
+	grid[127][127][127] = 0
+	return grid, start, end
+}
+
+// benchmarkGrid2D is an open room split by walls with gaps at alternating
+// ends, the kind of uniform grid that jump point search is made for
+func benchmarkGrid2D() (Grid, matrix.Vec3i, matrix.Vec3i) {
+	grid := NewGrid(512, 1, 512)
+	for x := 64; x < 512; x += 128 {
+		for z := 0; z < 480; z++ {
+			if (x/128)%2 == 0 {
+				grid[x][0][z] = 1
+			} else {
+				grid[x][0][511-z] = 1
+			}
+		}
+	}
+	start, end := matrix.Vec3i{0, 0, 0}, matrix.Vec3i{511, 0, 511}
+	grid[0][0][0] = 0
+	grid[511][0][511] = 0
+	return grid, start, end
+}
+
+func BenchmarkReferenceAStar3D(b *testing.B) {
+	grid, start, end := benchmarkGrid3D()
+	options := DefaultAStarOptions()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		referenceAStar(grid, start, end, options)
+	}
+}
+
+func BenchmarkSearcher3D(b *testing.B) {
+	grid, start, end := benchmarkGrid3D()
+	options := DefaultAStarOptions()
+	s := NewSearcher()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.FindPath(grid, start, end, &options)
+	}
+}
+
+func BenchmarkSearcher2D(b *testing.B) {
+	grid, start, end := benchmarkGrid2D()
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect8
+	options.Corners = CornerCutNever
+	s := NewSearcher()
+	s.DisableJumpPoints = true
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.FindPath(grid, start, end, &options)
+	}
+}
+
+func BenchmarkJumpPointSearch2D(b *testing.B) {
+	grid, start, end := benchmarkGrid2D()
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect8
+	options.Corners = CornerCutNever
+	s := NewSearcher()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.FindPath(grid, start, end, &options)
+	}
+}
@@ -0,0 +1,302 @@
+/*****************************************************************************/
+/* steering.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"math"
+)
+
+const steeringEpsilon = 0.0001
+
+// AvoidanceNeighbor is another moving body that an agent should keep away
+// from, only the X and Z axes are used for avoidance
+type AvoidanceNeighbor struct {
+	Position matrix.Vec3
+	Velocity matrix.Vec3
+	Radius   float32
+}
+
+// CellCenter is the world position of the middle of a grid cell, where the
+// first cell of the grid starts at origin
+func CellCenter(cell matrix.Vec3i, origin matrix.Vec3, cellSize float32) matrix.Vec3 {
+	return matrix.Vec3{
+		origin.X() + (float32(cell.X())+0.5)*cellSize,
+		origin.Y() + (float32(cell.Y())+0.5)*cellSize,
+		origin.Z() + (float32(cell.Z())+0.5)*cellSize,
+	}
+}
+
+// GridPathToWorld converts a path of cells into the world positions of the
+// cell centers
+func GridPathToWorld(path []matrix.Vec3i, origin matrix.Vec3, cellSize float32) []matrix.Vec3 {
+	out := make([]matrix.Vec3, len(path))
+	for i := range path {
+		out[i] = CellCenter(path[i], origin, cellSize)
+	}
+	return out
+}
+
+// Seek is the velocity that moves from the position straight towards the
+// target at full speed
+func Seek(position, target matrix.Vec3, maxSpeed float32) matrix.Vec3 {
+	offset := target.Subtract(position)
+	length := offset.Length()
+	if length < steeringEpsilon {
+		return matrix.Vec3Zero()
+	}
+	return offset.Scale(maxSpeed / length)
+}
+
+// Arrive is like Seek but slows down linearly once the target is closer
+// than slowRadius so that the target is reached without overshooting
+func Arrive(position, target matrix.Vec3, maxSpeed, slowRadius float32) matrix.Vec3 {
+	offset := target.Subtract(position)
+	length := offset.Length()
+	if length < steeringEpsilon {
+		return matrix.Vec3Zero()
+	}
+	speed := maxSpeed
+	if slowRadius > 0 && length < slowRadius {
+		speed *= length / slowRadius
+	}
+	return offset.Scale(speed / length)
+}
+
+// Accelerate moves the velocity towards the desired velocity without
+// changing it by more than maxAcceleration * deltaTime, the result is also
+// limited to maxSpeed. A maxAcceleration of 0 or less changes the velocity
+// instantly.
+func Accelerate(velocity, desired matrix.Vec3, maxSpeed, maxAcceleration, deltaTime float32) matrix.Vec3 {
+	change := desired.Subtract(velocity)
+	if limit := maxAcceleration * deltaTime; maxAcceleration > 0 {
+		if length := change.Length(); length > limit {
+			change = change.Scale(limit / length)
+		}
+	}
+	return limitLength(velocity.Add(change), maxSpeed)
+}
+
+func limitLength(v matrix.Vec3, maxLength float32) matrix.Vec3 {
+	if length := v.Length(); length > maxLength && length > steeringEpsilon {
+		return v.Scale(maxLength / length)
+	}
+	return v
+}
+
+// Separation pushes away from every neighbor that is closer than the sum of
+// both radii plus the padding, the push grows as the neighbor gets closer
+// and is at most 1 for each neighbor
+func Separation(position matrix.Vec3, radius, padding float32, neighbors []AvoidanceNeighbor) matrix.Vec3 {
+	push := matrix.Vec3Zero()
+	for i := range neighbors {
+		n := &neighbors[i]
+		away := matrix.Vec3{position.X() - n.Position.X(), 0, position.Z() - n.Position.Z()}
+		reach := radius + n.Radius + padding
+		dist := away.Length()
+		if dist >= reach || reach <= 0 {
+			continue
+		}
+		if dist < steeringEpsilon {
+			// Exactly on top of each other, pick a stable direction
+			away, dist = matrix.Vec3Right(), 1
+		}
+		push.AddAssign(away.Scale((1 - dist/reach) / dist))
+	}
+	return push
+}
+
+// timeToCollision is the time until two circles touch when the one at the
+// origin moves at the relative velocity, it is infinite when they never do
+func timeToCollision(offset, relative matrix.Vec3, reach float32) float32 {
+	ox, oz := offset.X(), offset.Z()
+	vx, vz := relative.X(), relative.Z()
+	c := ox*ox + oz*oz - reach*reach
+	b := vx*ox + vz*oz
+	if c < 0 {
+		// Already overlapping, only moving further in counts as a collision
+		if b > 0 {
+			return 0
+		}
+		return float32(math.Inf(1))
+	}
+	a := vx*vx + vz*vz
+	disc := b*b - a*c
+	if a < steeringEpsilon || disc < 0 || b <= 0 {
+		return float32(math.Inf(1))
+	}
+	return (b - matrix.Sqrt(disc)) / a
+}
+
+// The number of directions and speeds tried by AvoidVelocity
+const (
+	avoidanceDirections = 16
+	avoidanceSpeeds     = 3
+)
+
+// AvoidVelocity picks the velocity closest to the preferred one that does
+// not run into any of the neighbors within timeHorizon seconds. Each agent
+// is assumed to do half of the avoiding (reciprocal velocity obstacles), so
+// two agents running the same logic will not both dodge the same way.
+// Candidate velocities are sampled around the agent and scored by how far
+// they are from the preferred velocity and how soon they would collide.
+// Only the X and Z axes are changed.
+func AvoidVelocity(position, velocity, preferred matrix.Vec3, radius, maxSpeed, timeHorizon float32, neighbors []AvoidanceNeighbor) matrix.Vec3 {
+	if len(neighbors) == 0 || timeHorizon <= 0 {
+		return preferred
+	}
+	score := func(candidate matrix.Vec3) float32 {
+		penalty := candidate.Subtract(preferred).Length()
+		for i := range neighbors {
+			n := &neighbors[i]
+			// The reciprocal obstacle is centered on the average of both
+			// velocities rather than the neighbor velocity alone
+			relative := candidate.Scale(2).Subtract(velocity).Subtract(n.Velocity)
+			t := timeToCollision(n.Position.Subtract(position), relative, radius+n.Radius)
+			if t < timeHorizon {
+				penalty += maxSpeed * timeHorizon / max(t, steeringEpsilon)
+			}
+		}
+		return penalty
+	}
+	best := limitLength(preferred, maxSpeed)
+	bestScore := score(best)
+	if bestScore <= best.Subtract(preferred).Length() {
+		return best
+	}
+	if stop := (matrix.Vec3{0, preferred.Y(), 0}); score(stop) < bestScore {
+		best, bestScore = stop, score(stop)
+	}
+	for i := 0; i < avoidanceDirections; i++ {
+		angle := float32(i) * 2 * math.Pi / avoidanceDirections
+		dx, dz := matrix.Cos(angle), matrix.Sin(angle)
+		for j := 1; j <= avoidanceSpeeds; j++ {
+			speed := maxSpeed * float32(j) / avoidanceSpeeds
+			candidate := matrix.Vec3{dx * speed, preferred.Y(), dz * speed}
+			if s := score(candidate); s < bestScore {
+				best, bestScore = candidate, s
+			}
+		}
+	}
+	return best
+}
+
+// SmoothPath removes the points of a path that can be skipped by walking in
+// a straight line, visible reports if the straight line between two points
+// is clear. The first and last points are always kept.
+func SmoothPath(path []matrix.Vec3, visible func(from, to matrix.Vec3) bool) []matrix.Vec3 {
+	return smoothPath(path, visible)
+}
+
+// SmoothGridPath removes the cells of a grid path that can be skipped by
+// walking in a straight line through passable cells. The remaining cells
+// are the corners of the path, so consecutive cells may no longer be next
+// to each other.
+func SmoothGridPath(grid Grid, path []matrix.Vec3i, options *AStarOptions) []matrix.Vec3i {
+	return smoothPath(path, func(from, to matrix.Vec3i) bool {
+		return GridLineOfSight(grid, from, to, options)
+	})
+}
+
+// smoothPath greedily jumps from each kept point to the furthest point that
+// is visible from it
+func smoothPath[T any](path []T, visible func(from, to T) bool) []T {
+	if len(path) < 3 {
+		return path
+	}
+	out := []T{path[0]}
+	from := 0
+	for from < len(path)-1 {
+		next := from + 1
+		for i := len(path) - 1; i > next; i-- {
+			if visible(path[from], path[i]) {
+				next = i
+				break
+			}
+		}
+		out = append(out, path[next])
+		from = next
+	}
+	return out
+}
+
+// GridLineOfSight reports if every cell touched by the straight line
+// between the centers of the two cells can be entered. Lines that pass
+// exactly through the corner between cells also test the cells on either
+// side of the corner unless the corner rule allows cutting them.
+func GridLineOfSight(grid Grid, from, to matrix.Vec3i, options *AStarOptions) bool {
+	costs := &options.Costs
+	if !grid.passable(from[0], from[1], from[2], costs) {
+		return false
+	}
+	var step [3]int32
+	var tMax, tDelta [3]float64
+	for a := 0; a < 3; a++ {
+		d := float64(to[a] - from[a])
+		step[a] = sign32(to[a] - from[a])
+		if d == 0 {
+			tMax[a], tDelta[a] = math.Inf(1), math.Inf(1)
+		} else {
+			// Starting from the cell center the first boundary is half a
+			// cell away
+			tDelta[a] = 1 / math.Abs(d)
+			tMax[a] = tDelta[a] / 2
+		}
+	}
+	cell := from
+	for cell != to {
+		t := min(tMax[0], tMax[1], tMax[2])
+		var offset [3]int32
+		for a := 0; a < 3; a++ {
+			if tMax[a]-t < 1e-9 {
+				offset[a] = step[a]
+				tMax[a] += tDelta[a]
+			}
+		}
+		if !grid.canStep(cell[0], cell[1], cell[2], offset, options.Corners, costs) {
+			return false
+		}
+		for a := 0; a < 3; a++ {
+			cell[a] += offset[a]
+		}
+		if !grid.passable(cell[0], cell[1], cell[2], costs) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+/*****************************************************************************/
+/* agent.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package steering
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/systems/events"
+	"kaiju/systems/navigation"
+)
+
+const minSpeed = 0.001
+
+type mode uint8
+
+const (
+	modeIdle mode = iota
+	modeSeek
+	modeArrive
+	modePath
+)
+
+// Agent moves an entity towards a target or along a path every update. The
+// agent accelerates and turns within its limits, keeps away from the other
+// agents of its crowd and calls OnArrived when it reaches its destination.
+type Agent struct {
+	Entity    *engine.Entity
+	host      *engine.Host
+	crowd     *Crowd
+	OnArrived events.Event
+	Velocity  matrix.Vec3
+	target    matrix.Vec3
+	path      []matrix.Vec3
+	neighbors []navigation.AvoidanceNeighbor
+	Radius    float32
+	// MaxSpeed is in units per second and MaxAcceleration in units per
+	// second squared, an acceleration of 0 changes speed instantly
+	MaxSpeed        float32
+	MaxAcceleration float32
+	// SlowRadius is the distance from the destination at which the agent
+	// starts to slow down when arriving
+	SlowRadius float32
+	// ArriveDistance is how close to the destination counts as arrived
+	ArriveDistance float32
+	// WaypointDistance is how close to a path point the agent has to get
+	// before it moves on to the next one
+	WaypointDistance float32
+	// SeparationWeight scales the push away from nearby agents
+	SeparationWeight float32
+	// AvoidanceTime is how many seconds ahead collisions with other agents
+	// are predicted, 0 turns off avoidance
+	AvoidanceTime float32
+	pathIndex     int
+	updateId      int
+	mode          mode
+	// Planar agents only steer on the X and Z axes and keep their height
+	Planar       bool
+	FaceMovement bool
+}
+
+// NewAgent starts updating the agent on the host, a nil crowd means the
+// agent does not avoid anything
+func NewAgent(host *engine.Host, entity *engine.Entity, crowd *Crowd, radius float32) *Agent {
+	a := &Agent{
+		Entity:           entity,
+		host:             host,
+		crowd:            crowd,
+		OnArrived:        events.New(),
+		Radius:           radius,
+		MaxSpeed:         3.5,
+		MaxAcceleration:  10,
+		SlowRadius:       1.5,
+		ArriveDistance:   0.1,
+		WaypointDistance: max(radius, 0.25),
+		SeparationWeight: 1,
+		AvoidanceTime:    2,
+		Planar:           true,
+		FaceMovement:     true,
+	}
+	if crowd != nil {
+		crowd.add(a)
+	}
+	a.updateId = host.Updater.AddUpdate(a.update)
+	entity.OnDestroy.Add(func() {
+		host.Updater.RemoveUpdate(a.updateId)
+		if a.crowd != nil {
+			a.crowd.remove(a)
+		}
+	})
+	return a
+}
+
+func (a *Agent) Crowd() *Crowd { return a.crowd }
+
+// SetCrowd moves the agent into another crowd, nil removes it from its
+// current crowd
+func (a *Agent) SetCrowd(crowd *Crowd) {
+	if a.crowd != nil {
+		a.crowd.remove(a)
+	}
+	a.crowd = crowd
+	if crowd != nil {
+		crowd.add(a)
+	}
+}
+
+// IsMoving reports if the agent still has somewhere to go
+func (a *Agent) IsMoving() bool { return a.mode != modeIdle }
+
+// Destination is the point the agent is heading to, for paths it is the
+// last point of the path
+func (a *Agent) Destination() matrix.Vec3 { return a.target }
+
+// Path is what is left of the path being followed
+func (a *Agent) Path() []matrix.Vec3 {
+	if a.mode != modePath {
+		return nil
+	}
+	return a.path[a.pathIndex:]
+}
+
+// Seek heads towards the target at full speed and stops once it is reached
+func (a *Agent) Seek(target matrix.Vec3) {
+	a.target = target
+	a.mode = modeSeek
+}
+
+// Arrive heads towards the target and slows down before reaching it
+func (a *Agent) Arrive(target matrix.Vec3) {
+	a.target = target
+	a.mode = modeArrive
+}
+
+// FollowPath moves through each point of the path in order and arrives at
+// the last one. Paths from the grid searches can be converted with
+// navigation.GridPathToWorld and shortened with navigation.SmoothPath.
+func (a *Agent) FollowPath(path []matrix.Vec3) {
+	if len(path) == 0 {
+		a.Stop()
+		return
+	}
+	a.path = append(a.path[:0], path...)
+	a.pathIndex = 0
+	a.target = path[len(path)-1]
+	a.mode = modePath
+}
+
+// Stop drops the destination, the agent slows down to a stop within its
+// acceleration limit
+func (a *Agent) Stop() {
+	a.mode = modeIdle
+	a.path = a.path[:0]
+}
+
+func (a *Agent) offset(from, to matrix.Vec3) matrix.Vec3 {
+	if a.Planar {
+		to.SetY(from.Y())
+	}
+	return to.Subtract(from)
+}
+
+func (a *Agent) desiredVelocity(pos matrix.Vec3) matrix.Vec3 {
+	switch a.mode {
+	case modeSeek:
+		return navigation.Seek(pos, pos.Add(a.offset(pos, a.target)), a.MaxSpeed)
+	case modeArrive:
+		return navigation.Arrive(pos, pos.Add(a.offset(pos, a.target)), a.MaxSpeed, a.SlowRadius)
+	case modePath:
+		last := len(a.path) - 1
+		for a.pathIndex < last && a.offset(pos, a.path[a.pathIndex]).Length() <= a.WaypointDistance {
+			a.pathIndex++
+		}
+		next := pos.Add(a.offset(pos, a.path[a.pathIndex]))
+		if a.pathIndex == last {
+			return navigation.Arrive(pos, next, a.MaxSpeed, a.SlowRadius)
+		}
+		return navigation.Seek(pos, next, a.MaxSpeed)
+	}
+	return matrix.Vec3Zero()
+}
+
+func (a *Agent) checkArrived(pos matrix.Vec3) {
+	if a.mode == modeIdle || a.offset(pos, a.target).Length() > a.ArriveDistance {
+		return
+	}
+	if a.mode == modePath && a.pathIndex < len(a.path)-1 {
+		return
+	}
+	a.Stop()
+	a.OnArrived.Execute()
+}
+
+func (a *Agent) update(deltaTime float64) {
+	if !a.Entity.CanUpdate() {
+		return
+	}
+	dt := float32(deltaTime)
+	pos := a.Entity.Transform.WorldPosition()
+	desired := a.desiredVelocity(pos)
+	if a.crowd != nil {
+		a.neighbors = a.crowd.neighbors(a, a.neighbors[:0])
+		if len(a.neighbors) > 0 {
+			push := navigation.Separation(pos, a.Radius, a.Radius, a.neighbors)
+			desired.AddAssign(push.Scale(a.SeparationWeight * a.MaxSpeed))
+			desired = navigation.AvoidVelocity(pos, a.Velocity, desired,
+				a.Radius, a.MaxSpeed, a.AvoidanceTime, a.neighbors)
+		}
+		clear(a.neighbors)
+	}
+	if a.Planar {
+		desired.SetY(0)
+	}
+	a.Velocity = navigation.Accelerate(a.Velocity, desired, a.MaxSpeed, a.MaxAcceleration, dt)
+	if a.Velocity.Length() < minSpeed {
+		a.Velocity = matrix.Vec3Zero()
+	} else {
+		pos.AddAssign(a.Velocity.Scale(dt))
+		a.Entity.Transform.SetWorldPosition(pos)
+		if a.FaceMovement {
+			a.face(pos)
+		}
+	}
+	a.checkArrived(pos)
+}
+
+func (a *Agent) face(pos matrix.Vec3) {
+	look := matrix.Vec3{a.Velocity.X(), 0, a.Velocity.Z()}
+	if look.Length() < minSpeed {
+		return
+	}
+	a.Entity.LookAt(pos.Add(look))
+}
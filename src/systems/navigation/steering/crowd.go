@@ -0,0 +1,95 @@
+/*****************************************************************************/
+/* crowd.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package steering
+
+import (
+	"kaiju/systems/navigation"
+	"slices"
+)
+
+// Crowd is a group of agents that keep away from each other. Agents only
+// avoid the other agents of their own crowd.
+type Crowd struct {
+	agents []*Agent
+	// NeighborDistance is how far apart agents can be and still react to
+	// each other, measured between their edges
+	NeighborDistance float32
+}
+
+func NewCrowd() *Crowd {
+	return &Crowd{NeighborDistance: 3}
+}
+
+func (c *Crowd) Agents() []*Agent { return c.agents }
+
+func (c *Crowd) add(agent *Agent) {
+	if !slices.Contains(c.agents, agent) {
+		c.agents = append(c.agents, agent)
+	}
+}
+
+func (c *Crowd) remove(agent *Agent) {
+	if i := slices.Index(c.agents, agent); i >= 0 {
+		last := len(c.agents) - 1
+		c.agents[i] = c.agents[last]
+		c.agents[last] = nil
+		c.agents = c.agents[:last]
+	}
+}
+
+// neighbors appends every other active agent close enough to the agent
+func (c *Crowd) neighbors(agent *Agent, out []navigation.AvoidanceNeighbor) []navigation.AvoidanceNeighbor {
+	pos := agent.Entity.Transform.WorldPosition()
+	for _, other := range c.agents {
+		if other == agent || !other.Entity.IsActive() || other.Entity.IsDestroyed() {
+			continue
+		}
+		otherPos := other.Entity.Transform.WorldPosition()
+		dx, dz := otherPos.X()-pos.X(), otherPos.Z()-pos.Z()
+		reach := c.NeighborDistance + agent.Radius + other.Radius
+		if dx*dx+dz*dz > reach*reach {
+			continue
+		}
+		out = append(out, navigation.AvoidanceNeighbor{
+			Position: otherPos,
+			Velocity: other.Velocity,
+			Radius:   other.Radius,
+		})
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+/*****************************************************************************/
+/* steering_test.go                                                          */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package navigation
+
+import (
+	"kaiju/matrix"
+	"testing"
+)
+
+func TestArriveSlowsDown(t *testing.T) {
+	far := Arrive(matrix.Vec3{}, matrix.Vec3{10, 0, 0}, 4, 2)
+	near := Arrive(matrix.Vec3{}, matrix.Vec3{1, 0, 0}, 4, 2)
+	if !matrix.Approx(far.Length(), 4) {
+		t.Fatalf("expected full speed far from the target, got %f", far.Length())
+	}
+	if !matrix.Approx(near.Length(), 2) {
+		t.Fatalf("expected half speed halfway into the slow radius, got %f", near.Length())
+	}
+}
+
+func TestAccelerateLimits(t *testing.T) {
+	v := Accelerate(matrix.Vec3{}, matrix.Vec3{10, 0, 0}, 5, 2, 0.5)
+	if !matrix.Approx(v.X(), 1) {
+		t.Fatalf("expected the change to be limited by the acceleration, got %v", v)
+	}
+	v = Accelerate(matrix.Vec3{}, matrix.Vec3{10, 0, 0}, 5, 0, 0.5)
+	if !matrix.Approx(v.X(), 5) {
+		t.Fatalf("expected the velocity to be limited by the max speed, got %v", v)
+	}
+}
+
+func TestAvoidVelocityHeadOn(t *testing.T) {
+	// Two agents walking straight at each other on the same line
+	posA, posB := matrix.Vec3{0, 0, 0}, matrix.Vec3{4, 0, 0}
+	velA, velB := matrix.Vec3{1, 0, 0}, matrix.Vec3{-1, 0, 0}
+	for step := 0; step < 100; step++ {
+		newA := AvoidVelocity(posA, velA, Seek(posA, matrix.Vec3{8, 0, 0}, 1), 0.5, 1, 2,
+			[]AvoidanceNeighbor{{posB, velB, 0.5}})
+		newB := AvoidVelocity(posB, velB, Seek(posB, matrix.Vec3{-4, 0, 0}, 1), 0.5, 1, 2,
+			[]AvoidanceNeighbor{{posA, velA, 0.5}})
+		velA, velB = newA, newB
+		posA.AddAssign(velA.Scale(0.1))
+		posB.AddAssign(velB.Scale(0.1))
+		dx, dz := posA.X()-posB.X(), posA.Z()-posB.Z()
+		if dx*dx+dz*dz < 0.9*0.9 {
+			t.Fatalf("agents overlapped at step %d: %v %v", step, posA, posB)
+		}
+	}
+	if posA.X() < posB.X() {
+		t.Fatalf("expected the agents to pass each other, got %v %v", posA, posB)
+	}
+}
+
+func TestSeparationPushesApart(t *testing.T) {
+	push := Separation(matrix.Vec3{}, 0.5, 0.5, []AvoidanceNeighbor{{Position: matrix.Vec3{1, 0, 0}, Radius: 0.5}})
+	if push.X() >= 0 || push.Z() != 0 {
+		t.Fatalf("expected a push away from the neighbor, got %v", push)
+	}
+	push = Separation(matrix.Vec3{}, 0.5, 0.5, []AvoidanceNeighbor{{Position: matrix.Vec3{3, 0, 0}, Radius: 0.5}})
+	if push.Length() != 0 {
+		t.Fatalf("expected no push from a far neighbor, got %v", push)
+	}
+}
+
+func TestSmoothGridPath(t *testing.T) {
+	grid := NewGrid(10, 1, 10)
+	for z := int32(0); z < 8; z++ {
+		grid.BlockCell(matrix.Vec3i{5, 0, z}, 1)
+	}
+	options := DefaultAStarOptions()
+	options.Connectivity = Connect8
+	options.Corners = CornerCutNever
+	start, end := matrix.Vec3i{1, 0, 1}, matrix.Vec3i{8, 0, 1}
+	path := NewSearcher().FindPath(grid, start, end, &options)
+	smooth := SmoothGridPath(grid, path, &options)
+	if len(smooth) >= len(path) || smooth[0] != start || smooth[len(smooth)-1] != end {
+		t.Fatalf("expected a shorter path with the same ends, got %v", smooth)
+	}
+	for i := 1; i < len(smooth); i++ {
+		if !GridLineOfSight(grid, smooth[i-1], smooth[i], &options) {
+			t.Fatalf("no line of sight between %v and %v", smooth[i-1], smooth[i])
+		}
+	}
+	if GridLineOfSight(grid, start, end, &options) {
+		t.Fatal("expected the wall to block the line of sight")
+	}
+}
@@ -0,0 +1,188 @@
+/*****************************************************************************/
+/* body.go                                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/systems/events"
+)
+
+type BodyType uint8
+
+const (
+	// BodyStatic never moves and is only pushed against
+	BodyStatic BodyType = iota
+	// BodyDynamic is moved by gravity, forces and collisions
+	BodyDynamic
+	// BodyKinematic is moved by its velocity but ignores collisions
+	BodyKinematic
+)
+
+const AllLayers = ^uint32(0)
+
+// Contact is sent to a body when it touches another, the normal points away
+// from the other body so that it is the direction this body is pushed
+type Contact struct {
+	Other  *Body
+	Point  matrix.Vec2
+	Normal matrix.Vec2
+	Depth  float32
+}
+
+// Body attaches a shape to an entity. The position is read from the entity
+// transform before each step and written back after it, so sprites can be
+// moved directly and still collide. Rotation is taken from the entity and
+// is not simulated.
+type Body struct {
+	Entity          *engine.Entity
+	world           *World
+	Shape           Shape
+	Velocity        matrix.Vec2
+	OneWayDirection matrix.Vec2
+	OnCollision     events.EventWithArg[Contact]
+	OnTriggerEnter  events.EventWithArg[*Body]
+	OnTriggerStay   events.EventWithArg[*Body]
+	OnTriggerExit   events.EventWithArg[*Body]
+	force           matrix.Vec2
+	position        matrix.Vec2
+	groundNormal    matrix.Vec2
+	shape           worldShape
+	Restitution     float32
+	Friction        float32
+	GravityScale    float32
+	mass            float32
+	invMass         float32
+	angle           float32
+	Layer           uint32
+	Mask            uint32
+	id              uint32
+	Type            BodyType
+	IsTrigger       bool
+	OneWay          bool
+	grounded        bool
+	removed         bool
+}
+
+func newBody(world *World, entity *engine.Entity, shape Shape, bodyType BodyType) *Body {
+	b := &Body{
+		Entity:          entity,
+		world:           world,
+		Shape:           shape,
+		Type:            bodyType,
+		OneWayDirection: matrix.Vec2Up(),
+		OnCollision:     events.NewWithArg[Contact](),
+		OnTriggerEnter:  events.NewWithArg[*Body](),
+		OnTriggerStay:   events.NewWithArg[*Body](),
+		OnTriggerExit:   events.NewWithArg[*Body](),
+		Friction:        0.3,
+		GravityScale:    1,
+		Layer:           1,
+		Mask:            AllLayers,
+	}
+	b.SetMass(1)
+	b.readTransform()
+	return b
+}
+
+func (b *Body) World() *World             { return b.world }
+func (b *Body) Mass() float32             { return b.mass }
+func (b *Body) Position() matrix.Vec2     { return b.position }
+func (b *Body) IsGrounded() bool          { return b.grounded }
+func (b *Body) GroundNormal() matrix.Vec2 { return b.groundNormal }
+
+// SetMass changes the mass of a dynamic body, static and kinematic bodies
+// always behave as if their mass is infinite
+func (b *Body) SetMass(mass float32) {
+	b.mass = max(mass, 0)
+	if b.mass > 0 {
+		b.invMass = 1 / b.mass
+	} else {
+		b.invMass = 0
+	}
+}
+
+// ApplyForce adds a force that is applied over the next physics step
+func (b *Body) ApplyForce(force matrix.Vec2) {
+	b.force.AddAssign(force)
+}
+
+// ApplyImpulse instantly changes the velocity of a dynamic body
+func (b *Body) ApplyImpulse(impulse matrix.Vec2) {
+	if b.Type == BodyDynamic {
+		b.Velocity.AddAssign(impulse.Scale(b.invMass))
+	}
+}
+
+// Teleport moves the body and its entity without testing for collisions
+func (b *Body) Teleport(position matrix.Vec2) {
+	b.position = position
+	b.writeTransform()
+	b.refreshShape()
+}
+
+// CanCollide reports if the layers and masks of both bodies allow them to
+// interact with each other
+func (b *Body) CanCollide(other *Body) bool {
+	return b.Layer&other.Mask != 0 && other.Layer&b.Mask != 0
+}
+
+func (b *Body) inverseMass() float32 {
+	if b.Type != BodyDynamic || b.IsTrigger {
+		return 0
+	}
+	return b.invMass
+}
+
+func (b *Body) isActive() bool {
+	return !b.removed && b.Entity.IsActive() && !b.Entity.IsDestroyed()
+}
+
+func (b *Body) readTransform() {
+	b.position = b.Entity.Transform.WorldPosition().AsVec2()
+	b.angle = b.Entity.Transform.WorldRotation().Z()
+}
+
+func (b *Body) writeTransform() {
+	p := b.Entity.Transform.WorldPosition()
+	b.Entity.Transform.SetWorldPosition(matrix.Vec3{b.position.X(), b.position.Y(), p.Z()})
+}
+
+func (b *Body) refreshShape() {
+	b.Shape.toWorld(b.position, b.angle, &b.shape)
+}
@@ -0,0 +1,246 @@
+/*****************************************************************************/
+/* sat.go                                                                    */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import "kaiju/matrix"
+
+const epsilon = 0.00001
+
+// manifold describes the overlap between two shapes, the normal points from
+// the first shape towards the second and depth is the distance the second
+// must move along the normal to separate them
+type manifold struct {
+	normal matrix.Vec2
+	point  matrix.Vec2
+	depth  float32
+}
+
+func collide(a, b *worldShape) (manifold, bool) {
+	var m manifold
+	var ok bool
+	switch {
+	case a.isCircle() && b.isCircle():
+		m, ok = circleCircle(a, b)
+	case a.isCircle():
+		m, ok = circlePolygon(a, b)
+	case b.isCircle():
+		m, ok = circlePolygon(b, a)
+		m.normal = m.normal.Negative()
+	default:
+		m, ok = polygonPolygon(a, b)
+	}
+	if ok {
+		m.point = b.support(m.normal.Negative())
+	}
+	return m, ok
+}
+
+func circleCircle(a, b *worldShape) (manifold, bool) {
+	delta := b.center.Subtract(a.center)
+	radii := a.radius + b.radius
+	dist := delta.Length()
+	if dist >= radii {
+		return manifold{}, false
+	}
+	normal := matrix.Vec2{0, 1}
+	if dist > epsilon {
+		normal = delta.Shrink(dist)
+	}
+	return manifold{normal: normal, depth: radii - dist}, true
+}
+
+func circlePolygon(circle, poly *worldShape) (manifold, bool) {
+	m := manifold{depth: matrix.FloatMax}
+	count := len(poly.points)
+	closest := poly.points[0]
+	closestDist := float32(matrix.FloatMax)
+	for i := 0; i < count; i++ {
+		axis := edgeNormal(poly.points, i)
+		if !overlapAxis(circle, poly, axis, &m) {
+			return m, false
+		}
+		if d := poly.points[i].Subtract(circle.center); matrix.Vec2Dot(d, d) < closestDist {
+			closestDist = matrix.Vec2Dot(d, d)
+			closest = poly.points[i]
+		}
+	}
+	if axis := closest.Subtract(circle.center); axis.Length() > epsilon {
+		if !overlapAxis(circle, poly, axis.Normal(), &m) {
+			return m, false
+		}
+	}
+	if matrix.Vec2Dot(poly.centroid().Subtract(circle.center), m.normal) < 0 {
+		m.normal = m.normal.Negative()
+	}
+	return m, true
+}
+
+func polygonPolygon(a, b *worldShape) (manifold, bool) {
+	m := manifold{depth: matrix.FloatMax}
+	for _, s := range [2]*worldShape{a, b} {
+		for i := range s.points {
+			if !overlapAxis(a, b, edgeNormal(s.points, i), &m) {
+				return m, false
+			}
+		}
+	}
+	if matrix.Vec2Dot(b.centroid().Subtract(a.centroid()), m.normal) < 0 {
+		m.normal = m.normal.Negative()
+	}
+	return m, true
+}
+
+// overlapAxis projects both shapes onto the axis and keeps the axis in the
+// manifold when it has the smallest overlap found so far
+func overlapAxis(a, b *worldShape, axis matrix.Vec2, m *manifold) bool {
+	minA, maxA := a.project(axis)
+	minB, maxB := b.project(axis)
+	overlap := min(maxA, maxB) - max(minA, minB)
+	if overlap <= 0 {
+		return false
+	}
+	if overlap < m.depth {
+		m.depth = overlap
+		m.normal = axis
+	}
+	return true
+}
+
+// edgeNormal is the outward facing normal of the edge starting at index i,
+// the points are expected to be wound counter-clockwise
+func edgeNormal(points []matrix.Vec2, i int) matrix.Vec2 {
+	edge := points[(i+1)%len(points)].Subtract(points[i])
+	return perpendicular(edge).Normal()
+}
+
+func (w *worldShape) project(axis matrix.Vec2) (float32, float32) {
+	if w.isCircle() {
+		c := matrix.Vec2Dot(w.center, axis)
+		return c - w.radius, c + w.radius
+	}
+	lo := matrix.Vec2Dot(w.points[0], axis)
+	hi := lo
+	for _, p := range w.points[1:] {
+		d := matrix.Vec2Dot(p, axis)
+		lo = min(lo, d)
+		hi = max(hi, d)
+	}
+	return lo, hi
+}
+
+// support returns the point on the shape that is furthest along dir
+func (w *worldShape) support(dir matrix.Vec2) matrix.Vec2 {
+	if w.isCircle() {
+		return w.center.Add(dir.Scale(w.radius))
+	}
+	best := w.points[0]
+	bestDot := matrix.Vec2Dot(best, dir)
+	for _, p := range w.points[1:] {
+		if d := matrix.Vec2Dot(p, dir); d > bestDot {
+			best, bestDot = p, d
+		}
+	}
+	return best
+}
+
+func (w *worldShape) centroid() matrix.Vec2 {
+	if w.isCircle() {
+		return w.center
+	}
+	c := matrix.Vec2Zero()
+	for _, p := range w.points {
+		c.AddAssign(p)
+	}
+	return c.Shrink(float32(len(w.points)))
+}
+
+// rayHit finds the distance along the normalized direction where the ray
+// enters the shape, rays starting inside of the shape hit at distance 0
+func (w *worldShape) rayHit(origin, dir matrix.Vec2, maxDistance float32) (float32, matrix.Vec2, bool) {
+	if w.isCircle() {
+		return rayCircle(w.center, w.radius, origin, dir, maxDistance)
+	}
+	enter, exit := float32(0), maxDistance
+	normal := dir.Negative()
+	for i, p := range w.points {
+		n := edgeNormal(w.points, i)
+		denom := matrix.Vec2Dot(n, dir)
+		dist := matrix.Vec2Dot(n, p.Subtract(origin))
+		if matrix.Abs(denom) < epsilon {
+			if dist < 0 {
+				return 0, normal, false
+			}
+			continue
+		}
+		t := dist / denom
+		if denom < 0 {
+			if t > enter {
+				enter = t
+				normal = n
+			}
+		} else if t < exit {
+			exit = t
+		}
+		if enter > exit {
+			return 0, normal, false
+		}
+	}
+	return enter, normal, true
+}
+
+func rayCircle(center matrix.Vec2, radius float32, origin, dir matrix.Vec2, maxDistance float32) (float32, matrix.Vec2, bool) {
+	toOrigin := origin.Subtract(center)
+	c := matrix.Vec2Dot(toOrigin, toOrigin) - radius*radius
+	if c <= 0 {
+		return 0, dir.Negative(), true
+	}
+	b := matrix.Vec2Dot(toOrigin, dir)
+	if b > 0 {
+		return 0, matrix.Vec2{}, false
+	}
+	disc := b*b - c
+	if disc < 0 {
+		return 0, matrix.Vec2{}, false
+	}
+	t := -b - matrix.Sqrt(disc)
+	if t > maxDistance {
+		return 0, matrix.Vec2{}, false
+	}
+	point := origin.Add(dir.Scale(t))
+	return t, point.Subtract(center).Shrink(radius), true
+}
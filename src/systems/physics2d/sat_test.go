@@ -0,0 +1,124 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import (
+	"kaiju/matrix"
+	"testing"
+)
+
+func testWorldShape(shape Shape, position matrix.Vec2, angle float32) *worldShape {
+	out := &worldShape{}
+	shape.toWorld(position, angle, out)
+	return out
+}
+
+func TestCollideCircles(t *testing.T) {
+	a := testWorldShape(NewCircleShape(1), matrix.Vec2{0, 0}, 0)
+	b := testWorldShape(NewCircleShape(1), matrix.Vec2{1.5, 0}, 0)
+	m, ok := collide(a, b)
+	if !ok {
+		t.Fatal("expected the circles to overlap")
+	}
+	if !matrix.Vec2ApproxTo(m.normal, matrix.Vec2{1, 0}, 0.001) {
+		t.Errorf("expected the normal to point from a to b, got %v", m.normal)
+	}
+	if !matrix.ApproxTo(m.depth, 0.5, 0.001) {
+		t.Errorf("expected a depth of 0.5, got %f", m.depth)
+	}
+	b = testWorldShape(NewCircleShape(1), matrix.Vec2{2.5, 0}, 0)
+	if _, ok = collide(a, b); ok {
+		t.Error("separated circles should not collide")
+	}
+}
+
+func TestCollideBoxes(t *testing.T) {
+	floor := testWorldShape(NewBoxShape(10, 1), matrix.Vec2{0, 0}, 0)
+	box := testWorldShape(NewBoxShape(1, 1), matrix.Vec2{2, 0.8}, 0)
+	m, ok := collide(floor, box)
+	if !ok {
+		t.Fatal("expected the box to overlap the floor")
+	}
+	if !matrix.Vec2ApproxTo(m.normal, matrix.Vec2{0, 1}, 0.001) {
+		t.Errorf("expected the box to be pushed up, got %v", m.normal)
+	}
+	if !matrix.ApproxTo(m.depth, 0.2, 0.001) {
+		t.Errorf("expected a depth of 0.2, got %f", m.depth)
+	}
+	if m.point.Y() > 0.5+0.001 || m.point.Y() < 0.3-0.001 {
+		t.Errorf("expected the contact point to be inside of the overlap, got %v", m.point)
+	}
+	// Rotated 45 degrees the corner of the box reaches further down
+	box = testWorldShape(NewBoxShape(1, 1), matrix.Vec2{2, 1.1}, 45)
+	if m, ok = collide(floor, box); !ok {
+		t.Fatal("expected the rotated box corner to overlap the floor")
+	}
+	if !matrix.ApproxTo(m.depth, 0.5+0.7071-1.1, 0.001) {
+		t.Errorf("unexpected depth for the rotated box %f", m.depth)
+	}
+	box = testWorldShape(NewBoxShape(1, 1), matrix.Vec2{2, 1.2}, 0)
+	if _, ok = collide(floor, box); ok {
+		t.Error("the box above the floor should not collide")
+	}
+}
+
+func TestCollideCirclePolygon(t *testing.T) {
+	tri := testWorldShape(NewPolygonShape(matrix.Vec2{-1, 0}, matrix.Vec2{0, 1}, matrix.Vec2{1, 0}), matrix.Vec2{}, 0)
+	circle := testWorldShape(NewCircleShape(0.5), matrix.Vec2{0, -0.3}, 0)
+	m, ok := collide(tri, circle)
+	if !ok {
+		t.Fatal("expected the circle to overlap the bottom of the triangle")
+	}
+	if !matrix.Vec2ApproxTo(m.normal, matrix.Vec2{0, -1}, 0.001) {
+		t.Errorf("expected the circle to be pushed down, got %v", m.normal)
+	}
+	// Swapping the order flips the normal
+	if m, ok = collide(circle, tri); !ok || !matrix.Vec2ApproxTo(m.normal, matrix.Vec2{0, 1}, 0.001) {
+		t.Errorf("expected the triangle to be pushed up, got %v", m.normal)
+	}
+	if !matrix.ApproxTo(m.depth, 0.2, 0.001) {
+		t.Errorf("expected a depth of 0.2, got %f", m.depth)
+	}
+}
+
+func TestNewPolygonShapeWinding(t *testing.T) {
+	s := NewPolygonShape(matrix.Vec2{0, 0}, matrix.Vec2{0, 1}, matrix.Vec2{1, 0})
+	if polygonArea(s.Points) <= 0 {
+		t.Error("clockwise points should be reordered counter-clockwise")
+	}
+}
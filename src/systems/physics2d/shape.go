@@ -0,0 +1,151 @@
+/*****************************************************************************/
+/* shape.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import "kaiju/matrix"
+
+type ShapeType uint8
+
+const (
+	ShapeCircle ShapeType = iota
+	ShapeBox
+	ShapePolygon
+)
+
+// Shape is the collision outline of a body in world units, it is positioned
+// and rotated by the entity but is not affected by the entity scale since a
+// sprite uses its scale for its size
+type Shape struct {
+	Type     ShapeType
+	Offset   matrix.Vec2
+	Radius   float32
+	HalfSize matrix.Vec2
+	Points   []matrix.Vec2
+}
+
+func NewCircleShape(radius float32) Shape {
+	return Shape{Type: ShapeCircle, Radius: radius}
+}
+
+func NewBoxShape(width, height float32) Shape {
+	return Shape{Type: ShapeBox, HalfSize: matrix.Vec2{width * 0.5, height * 0.5}}
+}
+
+// NewPolygonShape creates a convex polygon from points in counter-clockwise
+// order, concave shapes should be split into multiple bodies
+func NewPolygonShape(points ...matrix.Vec2) Shape {
+	pts := make([]matrix.Vec2, len(points))
+	copy(pts, points)
+	if polygonArea(pts) < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return Shape{Type: ShapePolygon, Points: pts}
+}
+
+func polygonArea(points []matrix.Vec2) float32 {
+	area := float32(0)
+	for i := range points {
+		a := points[i]
+		b := points[(i+1)%len(points)]
+		area += a.X()*b.Y() - b.X()*a.Y()
+	}
+	return area * 0.5
+}
+
+func rotate2D(v matrix.Vec2, sin, cos float32) matrix.Vec2 {
+	return matrix.Vec2{v.X()*cos - v.Y()*sin, v.X()*sin + v.Y()*cos}
+}
+
+func cross2D(a, b matrix.Vec2) float32 {
+	return a.X()*b.Y() - a.Y()*b.X()
+}
+
+func perpendicular(v matrix.Vec2) matrix.Vec2 {
+	return matrix.Vec2{v.Y(), -v.X()}
+}
+
+// worldShape is a shape that has been moved into world space for the
+// current step, circles only use center and radius
+type worldShape struct {
+	center matrix.Vec2
+	radius float32
+	points []matrix.Vec2
+	min    matrix.Vec2
+	max    matrix.Vec2
+}
+
+func (s Shape) toWorld(position matrix.Vec2, angle float32, out *worldShape) {
+	sin := matrix.Sin(matrix.Deg2Rad(angle))
+	cos := matrix.Cos(matrix.Deg2Rad(angle))
+	out.center = position.Add(rotate2D(s.Offset, sin, cos))
+	out.points = out.points[:0]
+	switch s.Type {
+	case ShapeCircle:
+		out.radius = s.Radius
+		r := matrix.Vec2{s.Radius, s.Radius}
+		out.min = out.center.Subtract(r)
+		out.max = out.center.Add(r)
+		return
+	case ShapeBox:
+		hx, hy := s.HalfSize.X(), s.HalfSize.Y()
+		corners := [4]matrix.Vec2{{-hx, -hy}, {hx, -hy}, {hx, hy}, {-hx, hy}}
+		for i := range corners {
+			out.points = append(out.points, out.center.Add(rotate2D(corners[i], sin, cos)))
+		}
+	case ShapePolygon:
+		for i := range s.Points {
+			out.points = append(out.points, out.center.Add(rotate2D(s.Points[i], sin, cos)))
+		}
+	}
+	out.radius = 0
+	out.min = matrix.Vec2Largest()
+	out.max = matrix.Vec2Largest().Negative()
+	for _, p := range out.points {
+		out.min = matrix.Vec2Min(out.min, p)
+		out.max = matrix.Vec2Max(out.max, p)
+	}
+}
+
+func (w *worldShape) isCircle() bool { return len(w.points) == 0 }
+
+func (w *worldShape) overlapsBounds(other *worldShape) bool {
+	return w.min.X() <= other.max.X() && w.max.X() >= other.min.X() &&
+		w.min.Y() <= other.max.Y() && w.max.Y() >= other.min.Y()
+}
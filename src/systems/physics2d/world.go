@@ -0,0 +1,478 @@
+/*****************************************************************************/
+/* world.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/systems/visual2d/sprite"
+	"slices"
+)
+
+const (
+	maxStepsPerFrame   = 5
+	positionPercent    = 0.8
+	positionSlop       = 0.01
+	oneWayMinDot       = 0.5
+	groundMinDot       = 0.7
+	defaultTimeStep    = 1.0 / 60.0
+	defaultIterations  = 4
+	restingSpeedFactor = 2
+)
+
+type pairKey struct{ a, b uint32 }
+
+// pairState tracks bodies that overlapped in the previous step so that
+// trigger enter/exit can be detected and so that a body that started
+// passing through a one-way platform is allowed to finish doing so
+type pairState struct {
+	a, b    *Body
+	step    uint64
+	trigger bool
+	passing bool
+}
+
+type contact struct {
+	a, b *Body
+	m    manifold
+}
+
+type RaycastHit struct {
+	Body     *Body
+	Point    matrix.Vec2
+	Normal   matrix.Vec2
+	Distance float32
+}
+
+// World steps all of the bodies added to it at a fixed time step as part of
+// the host update
+type World struct {
+	host        *engine.Host
+	bodies      []*Body
+	order       []*Body
+	contacts    []contact
+	pairs       map[pairKey]*pairState
+	Gravity     matrix.Vec2
+	accumulator float64
+	step        uint64
+	TimeStep    float64
+	Iterations  int
+	updateId    int
+	nextId      uint32
+	// stepping is set while Step runs, bodies removed from its callbacks
+	// are only marked and taken out of bodies once the step is done
+	stepping bool
+}
+
+func NewWorld(host *engine.Host) *World {
+	w := &World{
+		host:       host,
+		pairs:      make(map[pairKey]*pairState),
+		Gravity:    matrix.Vec2{0, -9.81},
+		TimeStep:   defaultTimeStep,
+		Iterations: defaultIterations,
+	}
+	w.updateId = host.Updater.AddUpdate(w.update)
+	return w
+}
+
+func (w *World) Destroy() {
+	w.host.Updater.RemoveUpdate(w.updateId)
+	w.bodies = w.bodies[:0]
+	clear(w.pairs)
+}
+
+func (w *World) Bodies() []*Body { return w.bodies }
+
+// AddBody creates a body for the entity, the body is removed from the world
+// when the entity is destroyed
+func (w *World) AddBody(entity *engine.Entity, shape Shape, bodyType BodyType) *Body {
+	w.nextId++
+	b := newBody(w, entity, shape, bodyType)
+	b.id = w.nextId
+	w.bodies = append(w.bodies, b)
+	entity.OnDestroy.Add(func() { w.RemoveBody(b) })
+	return b
+}
+
+// AddSprite creates a body that moves the entity of the sprite
+func (w *World) AddSprite(s *sprite.Sprite, shape Shape, bodyType BodyType) *Body {
+	return w.AddBody(s.Entity, shape, bodyType)
+}
+
+// RemoveBody takes the body out of the world. Bodies removed by the
+// callbacks of a step stop colliding right away and leave Bodies once the
+// step is done
+func (w *World) RemoveBody(body *Body) {
+	if body.removed {
+		return
+	}
+	body.removed = true
+	if !w.stepping {
+		w.bodies = slices.DeleteFunc(w.bodies, func(b *Body) bool { return b == body })
+	}
+	// Triggers still overlapping the body would otherwise never hear that
+	// it left, so exit them the same way as exitPairs does
+	for k, p := range w.pairs {
+		if p.a != body && p.b != body {
+			continue
+		}
+		delete(w.pairs, k)
+		if p.trigger {
+			p.a.OnTriggerExit.Execute(p.b)
+			p.b.OnTriggerExit.Execute(p.a)
+		}
+	}
+}
+
+func (w *World) update(deltaTime float64) {
+	if w.TimeStep <= 0 {
+		w.Step(float32(deltaTime))
+		return
+	}
+	w.accumulator += deltaTime
+	for i := 0; w.accumulator >= w.TimeStep; i++ {
+		if i == maxStepsPerFrame {
+			// Drop the time that can't be caught up on rather than spiral
+			w.accumulator = 0
+			break
+		}
+		w.Step(float32(w.TimeStep))
+		w.accumulator -= w.TimeStep
+	}
+}
+
+// Step advances the simulation by the given time, this is called from the
+// host update but can be called directly when TimeStep is driven manually
+func (w *World) Step(deltaTime float32) {
+	w.step++
+	w.stepping = true
+	defer w.endStep()
+	for _, b := range w.bodies {
+		if !b.isActive() {
+			continue
+		}
+		b.readTransform()
+		b.grounded = false
+		if b.Type == BodyDynamic && !b.IsTrigger {
+			accel := w.Gravity.Scale(b.GravityScale).Add(b.force.Scale(b.invMass))
+			b.Velocity.AddAssign(accel.Scale(deltaTime))
+		}
+		b.force = matrix.Vec2Zero()
+		if b.Type != BodyStatic {
+			b.position.AddAssign(b.Velocity.Scale(deltaTime))
+		}
+		b.refreshShape()
+	}
+	w.findContacts()
+	w.resolveContacts(deltaTime)
+	w.notifyContacts()
+	w.exitPairs()
+	for _, b := range w.bodies {
+		if b.Type != BodyStatic && b.isActive() {
+			b.refreshShape()
+			b.writeTransform()
+		}
+	}
+}
+
+// endStep takes the bodies that were removed during the step out of the
+// world
+func (w *World) endStep() {
+	w.stepping = false
+	w.bodies = slices.DeleteFunc(w.bodies, func(b *Body) bool { return b.removed })
+}
+
+// findContacts uses a sort and sweep along the x axis to find the pairs of
+// bodies that overlap
+func (w *World) findContacts() {
+	w.contacts = w.contacts[:0]
+	w.order = w.order[:0]
+	for _, b := range w.bodies {
+		if b.isActive() {
+			w.order = append(w.order, b)
+		}
+	}
+	slices.SortFunc(w.order, func(a, b *Body) int {
+		if a.shape.min.X() < b.shape.min.X() {
+			return -1
+		} else if a.shape.min.X() > b.shape.min.X() {
+			return 1
+		}
+		return 0
+	})
+	for i, a := range w.order {
+		for _, b := range w.order[i+1:] {
+			if b.shape.min.X() > a.shape.max.X() {
+				break
+			}
+			// Trigger callbacks can remove bodies while they are sorted
+			if a.removed || b.removed || !w.shouldTest(a, b) || !a.shape.overlapsBounds(&b.shape) {
+				continue
+			}
+			if m, ok := collide(&a.shape, &b.shape); ok {
+				w.addContact(a, b, m)
+			}
+		}
+	}
+}
+
+func (w *World) shouldTest(a, b *Body) bool {
+	if a.Type == BodyStatic && b.Type == BodyStatic {
+		return false
+	}
+	return a.CanCollide(b)
+}
+
+// pair returns the tracked state of the two bodies, pairs that did not
+// overlap in the last step are removed by exitPairs so a missing pair is
+// always a new overlap
+func (w *World) pair(a, b *Body) (*pairState, bool) {
+	key := pairKey{a.id, b.id}
+	if a.id > b.id {
+		key = pairKey{b.id, a.id}
+	}
+	p, ok := w.pairs[key]
+	if !ok {
+		p = &pairState{a: a, b: b}
+		w.pairs[key] = p
+	}
+	return p, !ok
+}
+
+func (w *World) addContact(a, b *Body, m manifold) {
+	p, isNew := w.pair(a, b)
+	p.step = w.step
+	if a.IsTrigger || b.IsTrigger {
+		p.trigger = true
+		if isNew {
+			a.OnTriggerEnter.Execute(b)
+			b.OnTriggerEnter.Execute(a)
+		} else {
+			a.OnTriggerStay.Execute(b)
+			b.OnTriggerStay.Execute(a)
+		}
+		return
+	}
+	if p.passing || !w.oneWayAllows(a, b, m, isNew, p) {
+		return
+	}
+	w.contacts = append(w.contacts, contact{a: a, b: b, m: m})
+}
+
+// oneWayAllows filters contacts with one-way platforms, a body is only
+// blocked when it is pushed out along the platform direction while moving
+// into it. Bodies that begin overlapping from any other side are marked as
+// passing until they no longer overlap.
+func (w *World) oneWayAllows(a, b *Body, m manifold, isNew bool, p *pairState) bool {
+	check := func(platform, other *Body, normal matrix.Vec2) bool {
+		if !platform.OneWay {
+			return true
+		}
+		dir := platform.OneWayDirection
+		rel := other.Velocity.Subtract(platform.Velocity)
+		if matrix.Vec2Dot(normal, dir) >= oneWayMinDot && matrix.Vec2Dot(rel, dir) <= epsilon {
+			return true
+		}
+		if isNew || matrix.Vec2Dot(normal, dir) < oneWayMinDot {
+			p.passing = true
+		}
+		return false
+	}
+	return check(a, b, m.normal) && check(b, a, m.normal.Negative())
+}
+
+func (w *World) resolveContacts(deltaTime float32) {
+	restingSpeed := w.Gravity.Length() * deltaTime * restingSpeedFactor
+	for i := 0; i < max(1, w.Iterations); i++ {
+		for c := range w.contacts {
+			w.resolveVelocity(&w.contacts[c], i == 0, restingSpeed)
+		}
+	}
+	for c := range w.contacts {
+		w.correctPosition(&w.contacts[c])
+	}
+}
+
+func (w *World) resolveVelocity(c *contact, bounce bool, restingSpeed float32) {
+	invA, invB := c.a.inverseMass(), c.b.inverseMass()
+	invSum := invA + invB
+	if invSum == 0 {
+		return
+	}
+	n := c.m.normal
+	rel := c.b.Velocity.Subtract(c.a.Velocity)
+	along := matrix.Vec2Dot(rel, n)
+	if along > 0 {
+		return
+	}
+	e := float32(0)
+	if bounce && -along > restingSpeed {
+		e = min(c.a.Restitution, c.b.Restitution)
+	}
+	j := -(1 + e) * along / invSum
+	impulse := n.Scale(j)
+	c.a.Velocity.SubtractAssign(impulse.Scale(invA))
+	c.b.Velocity.AddAssign(impulse.Scale(invB))
+	// Coulomb friction along the contact tangent
+	rel = c.b.Velocity.Subtract(c.a.Velocity)
+	tangent := rel.Subtract(n.Scale(matrix.Vec2Dot(rel, n)))
+	if tangent.Length() <= epsilon {
+		return
+	}
+	tangent.Normalize()
+	jt := -matrix.Vec2Dot(rel, tangent) / invSum
+	mu := matrix.Sqrt(c.a.Friction * c.b.Friction)
+	jt = matrix.Clamp(jt, -j*mu, j*mu)
+	friction := tangent.Scale(jt)
+	c.a.Velocity.SubtractAssign(friction.Scale(invA))
+	c.b.Velocity.AddAssign(friction.Scale(invB))
+}
+
+func (w *World) correctPosition(c *contact) {
+	invA, invB := c.a.inverseMass(), c.b.inverseMass()
+	invSum := invA + invB
+	if invSum == 0 {
+		return
+	}
+	amount := max(c.m.depth-positionSlop, 0) / invSum * positionPercent
+	correction := c.m.normal.Scale(amount)
+	c.a.position.SubtractAssign(correction.Scale(invA))
+	c.b.position.AddAssign(correction.Scale(invB))
+}
+
+func (w *World) notifyContacts() {
+	up := w.Gravity.Negative()
+	if up.Length() > epsilon {
+		up.Normalize()
+	} else {
+		up = matrix.Vec2Up()
+	}
+	for _, c := range w.contacts {
+		if c.a.removed || c.b.removed {
+			continue
+		}
+		n := c.m.normal
+		if matrix.Vec2Dot(n, up) >= groundMinDot {
+			c.b.grounded = true
+			c.b.groundNormal = n
+		} else if matrix.Vec2Dot(n.Negative(), up) >= groundMinDot {
+			c.a.grounded = true
+			c.a.groundNormal = n.Negative()
+		}
+		c.a.OnCollision.Execute(Contact{Other: c.b, Point: c.m.point, Normal: n.Negative(), Depth: c.m.depth})
+		c.b.OnCollision.Execute(Contact{Other: c.a, Point: c.m.point, Normal: n, Depth: c.m.depth})
+	}
+}
+
+func (w *World) exitPairs() {
+	for k, p := range w.pairs {
+		if p.step == w.step {
+			continue
+		}
+		delete(w.pairs, k)
+		if p.trigger && !p.a.removed && !p.b.removed {
+			p.a.OnTriggerExit.Execute(p.b)
+			p.b.OnTriggerExit.Execute(p.a)
+		}
+	}
+}
+
+// Raycast finds the closest body along the ray that is on one of the layers
+// in the mask, triggers are ignored. The direction does not need to be
+// normalized.
+func (w *World) Raycast(origin, direction matrix.Vec2, maxDistance float32, mask uint32) (RaycastHit, bool) {
+	hit := RaycastHit{Distance: maxDistance}
+	found := false
+	if direction.Length() <= epsilon {
+		return hit, false
+	}
+	dir := direction.Normal()
+	for _, b := range w.bodies {
+		if b.IsTrigger || b.Layer&mask == 0 || !b.isActive() {
+			continue
+		}
+		b.readTransform()
+		b.refreshShape()
+		if dist, normal, ok := b.shape.rayHit(origin, dir, hit.Distance); ok && (!found || dist < hit.Distance) {
+			found = true
+			hit = RaycastHit{
+				Body:     b,
+				Point:    origin.Add(dir.Scale(dist)),
+				Normal:   normal,
+				Distance: dist,
+			}
+		}
+	}
+	return hit, found
+}
+
+// OverlapPoint returns all of the bodies on the layers in the mask that
+// contain the point
+func (w *World) OverlapPoint(point matrix.Vec2, mask uint32) []*Body {
+	var out []*Body
+	probe := worldShape{center: point, min: point, max: point}
+	for _, b := range w.bodies {
+		if b.Layer&mask == 0 || !b.isActive() {
+			continue
+		}
+		b.readTransform()
+		b.refreshShape()
+		if !b.shape.overlapsBounds(&probe) {
+			continue
+		}
+		if b.shape.isCircle() {
+			if point.Distance(b.shape.center) <= b.shape.radius {
+				out = append(out, b)
+			}
+		} else if pointInPolygon(point, b.shape.points) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func pointInPolygon(point matrix.Vec2, points []matrix.Vec2) bool {
+	for i := range points {
+		edge := points[(i+1)%len(points)].Subtract(points[i])
+		if cross2D(edge, point.Subtract(points[i])) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package physics2d
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"testing"
+)
+
+const testStep = 1.0 / 60.0
+
+func testWorld() *World {
+	w := NewWorld(engine.NewHost("Physics test"))
+	w.TimeStep = 0
+	return w
+}
+
+func testBody(w *World, shape Shape, bodyType BodyType, position matrix.Vec2) *Body {
+	e := engine.NewEntity()
+	e.Transform.SetWorldPosition(matrix.Vec3{position.X(), position.Y(), 0})
+	return w.AddBody(e, shape, bodyType)
+}
+
+func testSteps(w *World, count int) {
+	for i := 0; i < count; i++ {
+		w.Step(testStep)
+	}
+}
+
+func TestWorldBodyLands(t *testing.T) {
+	w := testWorld()
+	testBody(w, NewBoxShape(20, 1), BodyStatic, matrix.Vec2{0, 0})
+	box := testBody(w, NewBoxShape(1, 1), BodyDynamic, matrix.Vec2{0, 3})
+	contacts := 0
+	box.OnCollision.Add(func(c Contact) {
+		contacts++
+		if c.Normal.Y() <= 0 {
+			t.Errorf("expected the floor to push the box up, got %v", c.Normal)
+		}
+	})
+	testSteps(w, 120)
+	if contacts == 0 {
+		t.Fatal("expected the box to touch the floor")
+	}
+	if !box.IsGrounded() {
+		t.Error("expected the box to be grounded on the floor")
+	}
+	if y := box.Entity.Transform.WorldPosition().Y(); !matrix.ApproxTo(y, 1, positionSlop*2) {
+		t.Errorf("expected the box to rest on the floor at y = 1, got %f", y)
+	}
+	if !matrix.ApproxTo(box.Velocity.Y(), 0, 0.2) {
+		t.Errorf("expected the box to come to rest, got a velocity of %v", box.Velocity)
+	}
+}
+
+func TestWorldLayerMask(t *testing.T) {
+	w := testWorld()
+	floor := testBody(w, NewBoxShape(20, 1), BodyStatic, matrix.Vec2{0, 0})
+	floor.Layer = 1 << 1
+	ghost := testBody(w, NewCircleShape(0.5), BodyDynamic, matrix.Vec2{-2, 2})
+	ghost.Mask = 1
+	solid := testBody(w, NewCircleShape(0.5), BodyDynamic, matrix.Vec2{2, 2})
+	if ghost.CanCollide(floor) || !solid.CanCollide(floor) {
+		t.Fatal("unexpected CanCollide result for the layer masks")
+	}
+	testSteps(w, 90)
+	if ghost.Position().Y() > -1 {
+		t.Errorf("the masked out body should fall through the floor, got %v", ghost.Position())
+	}
+	if solid.Position().Y() < 0.9 {
+		t.Errorf("the body on a matching layer should land on the floor, got %v", solid.Position())
+	}
+}
+
+func TestWorldOneWayPlatform(t *testing.T) {
+	w := testWorld()
+	platform := testBody(w, NewBoxShape(10, 0.5), BodyStatic, matrix.Vec2{0, 0})
+	platform.OneWay = true
+	// Jumping up from underneath passes through the platform
+	jumper := testBody(w, NewBoxShape(1, 1), BodyDynamic, matrix.Vec2{0, -1.5})
+	jumper.Velocity = matrix.Vec2{0, 8}
+	// Falling from above lands on it
+	faller := testBody(w, NewBoxShape(1, 1), BodyDynamic, matrix.Vec2{3, 2})
+	passedAbove := false
+	for i := 0; i < 120; i++ {
+		w.Step(testStep)
+		if jumper.Position().Y() > 1 {
+			passedAbove = true
+		}
+	}
+	if !passedAbove {
+		t.Error("the jumping body should pass up through the platform")
+	}
+	if y := jumper.Position().Y(); !matrix.ApproxTo(y, 0.75, positionSlop*2) {
+		t.Errorf("the jumping body should land back on top of the platform, got %f", y)
+	}
+	if y := faller.Position().Y(); !matrix.ApproxTo(y, 0.75, positionSlop*2) {
+		t.Errorf("the falling body should land on the platform, got %f", y)
+	}
+}
+
+func TestWorldTriggerEvents(t *testing.T) {
+	w := testWorld()
+	w.Gravity = matrix.Vec2Zero()
+	zone := testBody(w, NewBoxShape(2, 2), BodyStatic, matrix.Vec2{0, 0})
+	zone.IsTrigger = true
+	mover := testBody(w, NewCircleShape(0.25), BodyKinematic, matrix.Vec2{-3, 0})
+	mover.Velocity = matrix.Vec2{60, 0}
+	events := make([]string, 0)
+	zone.OnTriggerEnter.Add(func(other *Body) {
+		if other != mover {
+			t.Error("expected the mover to enter the zone")
+		}
+		events = append(events, "enter")
+	})
+	zone.OnTriggerStay.Add(func(*Body) { events = append(events, "stay") })
+	zone.OnTriggerExit.Add(func(*Body) { events = append(events, "exit") })
+	moverEnters := 0
+	mover.OnTriggerEnter.Add(func(*Body) { moverEnters++ })
+	mover.OnCollision.Add(func(Contact) { t.Error("triggers should not produce collisions") })
+	testSteps(w, 6)
+	expected := []string{"enter", "stay", "stay", "exit"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected the events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected the events %v, got %v", expected, events)
+		}
+	}
+	if moverEnters != 1 {
+		t.Errorf("expected the mover to be told it entered once, got %d", moverEnters)
+	}
+	if mover.Velocity.X() != 60 {
+		t.Error("the trigger should not slow the mover down")
+	}
+}
+
+func TestWorldRemoveBodyExitsTriggers(t *testing.T) {
+	w := testWorld()
+	w.Gravity = matrix.Vec2Zero()
+	zone := testBody(w, NewBoxShape(2, 2), BodyStatic, matrix.Vec2{0, 0})
+	zone.IsTrigger = true
+	inside := testBody(w, NewCircleShape(0.25), BodyKinematic, matrix.Vec2{0, 0})
+	exits := 0
+	zone.OnTriggerExit.Add(func(other *Body) {
+		if other != inside {
+			t.Error("expected the removed body to exit")
+		}
+		exits++
+	})
+	w.Step(testStep)
+	w.RemoveBody(inside)
+	if exits != 1 {
+		t.Fatalf("expected the trigger to exit when the body was removed, got %d exits", exits)
+	}
+	w.Step(testStep)
+	if exits != 1 {
+		t.Errorf("the exit should only fire once, got %d", exits)
+	}
+	if len(w.Bodies()) != 1 {
+		t.Errorf("expected only the zone to remain, got %d bodies", len(w.Bodies()))
+	}
+}
+
+func TestWorldRemoveBodyDuringStep(t *testing.T) {
+	w := testWorld()
+	floor := testBody(w, NewBoxShape(20, 1), BodyStatic, matrix.Vec2{0, 0})
+	box := testBody(w, NewBoxShape(1, 1), BodyDynamic, matrix.Vec2{0, 1})
+	other := testBody(w, NewBoxShape(1, 1), BodyDynamic, matrix.Vec2{0.5, 1})
+	collisions := 0
+	box.OnCollision.Add(func(Contact) {
+		collisions++
+		w.RemoveBody(box)
+		w.RemoveBody(other)
+	})
+	other.OnCollision.Add(func(Contact) { t.Error("the removed body should not collide") })
+	testSteps(w, 3)
+	if collisions != 1 {
+		t.Errorf("expected the box to collide once before it was removed, got %d", collisions)
+	}
+	if len(w.Bodies()) != 1 || w.Bodies()[0] != floor {
+		t.Errorf("expected only the floor to remain, got %d bodies", len(w.Bodies()))
+	}
+}
+
+func TestWorldRaycast(t *testing.T) {
+	w := testWorld()
+	near := testBody(w, NewBoxShape(1, 1), BodyStatic, matrix.Vec2{3, 0})
+	far := testBody(w, NewCircleShape(0.5), BodyStatic, matrix.Vec2{6, 0})
+	far.Layer = 1 << 2
+	trigger := testBody(w, NewBoxShape(1, 1), BodyStatic, matrix.Vec2{1, 0})
+	trigger.IsTrigger = true
+	hit, ok := w.Raycast(matrix.Vec2{0, 0}, matrix.Vec2{2, 0}, 10, AllLayers)
+	if !ok || hit.Body != near {
+		t.Fatalf("expected the ray to hit the near box, got %v", hit.Body)
+	}
+	if !matrix.ApproxTo(hit.Distance, 2.5, 0.001) {
+		t.Errorf("expected a distance of 2.5, got %f", hit.Distance)
+	}
+	if !matrix.Vec2ApproxTo(hit.Normal, matrix.Vec2{-1, 0}, 0.001) {
+		t.Errorf("expected the normal to face the ray, got %v", hit.Normal)
+	}
+	if hit, ok = w.Raycast(matrix.Vec2{0, 0}, matrix.Vec2{1, 0}, 10, far.Layer); !ok || hit.Body != far {
+		t.Fatalf("expected the mask to skip to the far circle, got %v", hit.Body)
+	}
+	if !matrix.ApproxTo(hit.Distance, 5.5, 0.001) {
+		t.Errorf("expected a distance of 5.5, got %f", hit.Distance)
+	}
+	if _, ok = w.Raycast(matrix.Vec2{0, 0}, matrix.Vec2{1, 0}, 2, AllLayers); ok {
+		t.Error("the box is further than the max distance")
+	}
+	if _, ok = w.Raycast(matrix.Vec2{0, 0}, matrix.Vec2{0, 1}, 10, AllLayers); ok {
+		t.Error("nothing is above the origin")
+	}
+}
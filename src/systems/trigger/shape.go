@@ -0,0 +1,142 @@
+/*****************************************************************************/
+/* shape.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package trigger
+
+import (
+	"kaiju/collision"
+	"kaiju/matrix"
+)
+
+type ShapeType uint8
+
+const (
+	ShapeBox ShapeType = iota
+	ShapeSphere
+	ShapeCapsule
+)
+
+// Shape is described in the local space of the entity and is moved, rotated
+// and scaled along with it. A capsule stands along the local up axis with
+// the height covering the rounded ends.
+type Shape struct {
+	Type   ShapeType
+	Center matrix.Vec3
+	Extent matrix.Vec3
+	Radius float32
+	Height float32
+}
+
+func NewBoxShape(size matrix.Vec3) Shape {
+	return Shape{Type: ShapeBox, Extent: size.Scale(0.5)}
+}
+
+func NewSphereShape(radius float32) Shape {
+	return Shape{Type: ShapeSphere, Radius: radius}
+}
+
+func NewCapsuleShape(radius, height float32) Shape {
+	return Shape{Type: ShapeCapsule, Radius: radius, Height: max(height, radius*2)}
+}
+
+type worldShape struct {
+	box     collision.OBB
+	sphere  collision.Sphere
+	capsule collision.Capsule
+	bounds  collision.AABB
+	kind    ShapeType
+}
+
+func (s Shape) toWorld(m matrix.Mat4, out *worldShape) {
+	out.kind = s.Type
+	switch s.Type {
+	case ShapeBox:
+		local := collision.AABB{Min: s.Center.Subtract(s.Extent), Max: s.Center.Add(s.Extent)}
+		out.box = collision.OBBFromAABB(local, m)
+		out.bounds = out.box.Bounds()
+	case ShapeSphere:
+		out.sphere = collision.Sphere{
+			Center: m.TransformPoint(s.Center),
+			Radius: s.Radius * largestScale(m),
+		}
+		out.bounds = out.sphere.Bounds()
+	case ShapeCapsule:
+		half := matrix.Vec3Up().Scale(max(0, s.Height*0.5-s.Radius))
+		out.capsule = collision.Capsule{
+			A:      m.TransformPoint(s.Center.Subtract(half)),
+			B:      m.TransformPoint(s.Center.Add(half)),
+			Radius: s.Radius * largestScale(m),
+		}
+		out.bounds = out.capsule.Bounds()
+	}
+}
+
+func largestScale(m matrix.Mat4) float32 {
+	origin := m.TransformPoint(matrix.Vec3Zero())
+	return max(m.TransformPoint(matrix.Vec3Right()).Subtract(origin).Length(),
+		m.TransformPoint(matrix.Vec3Up()).Subtract(origin).Length(),
+		m.TransformPoint(matrix.Vec3Backward()).Subtract(origin).Length())
+}
+
+func (w *worldShape) asCapsule() collision.Capsule {
+	if w.kind == ShapeSphere {
+		return collision.Capsule{A: w.sphere.Center, B: w.sphere.Center, Radius: w.sphere.Radius}
+	}
+	return w.capsule
+}
+
+// overlaps tests the two shapes, spheres are treated as capsules with no
+// length when paired with a capsule to keep the number of cases down
+func (w *worldShape) overlaps(other *worldShape) bool {
+	if !w.bounds.Overlaps(other.bounds) {
+		return false
+	}
+	switch {
+	case w.kind == ShapeBox && other.kind == ShapeBox:
+		return w.box.Overlaps(other.box)
+	case w.kind == ShapeBox && other.kind == ShapeSphere:
+		return w.box.OverlapsSphere(other.sphere)
+	case w.kind == ShapeBox:
+		return w.box.OverlapsCapsule(other.capsule)
+	case other.kind == ShapeBox:
+		return other.overlaps(w)
+	case w.kind == ShapeSphere && other.kind == ShapeSphere:
+		return w.sphere.Overlaps(other.sphere)
+	default:
+		return w.asCapsule().Overlaps(other.asCapsule())
+	}
+}
@@ -0,0 +1,166 @@
+/*****************************************************************************/
+/* system.go                                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package trigger
+
+import (
+	"kaiju/engine"
+	"kaiju/systems/events"
+	"slices"
+)
+
+// System tests all triggers against all colliders after the entities have
+// been moved for the frame, it is meant for the small number of triggers a
+// level script needs rather than for large crowds
+type System struct {
+	host      *engine.Host
+	triggers  []*Trigger
+	colliders []*Collider
+	// Snapshots of the triggers and colliders taken at the start of the
+	// update, event handlers are free to add and remove either while the
+	// update is walking through them
+	triggerSnapshot  []*Trigger
+	colliderSnapshot []*Collider
+	frame            uint64
+	updateId         int
+}
+
+func NewSystem(host *engine.Host) *System {
+	s := &System{host: host}
+	s.updateId = host.LateUpdater.AddUpdate(s.update)
+	return s
+}
+
+func (s *System) Destroy() {
+	s.host.LateUpdater.RemoveUpdate(s.updateId)
+	s.triggers = s.triggers[:0]
+	s.colliders = s.colliders[:0]
+}
+
+// AddTrigger creates a trigger that detects colliders on any of the layers
+// in the mask, it is removed when the entity is destroyed
+func (s *System) AddTrigger(entity *engine.Entity, shape Shape, mask uint32) *Trigger {
+	t := &Trigger{
+		Entity:   entity,
+		Shape:    shape,
+		Mask:     mask,
+		OnEnter:  events.NewWithArg[*Collider](),
+		OnStay:   events.NewWithArg[*Collider](),
+		OnExit:   events.NewWithArg[*Collider](),
+		overlaps: make(map[*Collider]uint64),
+	}
+	s.triggers = append(s.triggers, t)
+	entity.OnDestroy.Add(func() { s.RemoveTrigger(t) })
+	return t
+}
+
+// AddCollider makes the entity detectable by triggers, it is removed when
+// the entity is destroyed
+func (s *System) AddCollider(entity *engine.Entity, shape Shape, layer uint32) *Collider {
+	c := &Collider{Entity: entity, Shape: shape, Layer: layer}
+	s.colliders = append(s.colliders, c)
+	entity.OnDestroy.Add(func() { s.RemoveCollider(c) })
+	return c
+}
+
+func (s *System) RemoveTrigger(trigger *Trigger) {
+	if trigger.removed {
+		return
+	}
+	trigger.removed = true
+	s.triggers = slices.DeleteFunc(s.triggers, func(t *Trigger) bool { return t == trigger })
+	clear(trigger.overlaps)
+}
+
+// RemoveCollider stops the collider from being detected, any triggers that
+// it is inside of will raise their exit event
+func (s *System) RemoveCollider(collider *Collider) {
+	if collider.removed {
+		return
+	}
+	collider.removed = true
+	s.colliders = slices.DeleteFunc(s.colliders, func(c *Collider) bool { return c == collider })
+	for _, t := range s.triggers {
+		if t.Contains(collider) {
+			t.exit(collider)
+		}
+	}
+}
+
+func (s *System) update(float64) {
+	s.frame++
+	s.triggerSnapshot = append(s.triggerSnapshot[:0], s.triggers...)
+	s.colliderSnapshot = append(s.colliderSnapshot[:0], s.colliders...)
+	for _, c := range s.colliderSnapshot {
+		if isLive(c.Entity) {
+			c.Shape.toWorld(c.Entity.Transform.WorldMatrix(), &c.shape)
+		}
+	}
+	for _, t := range s.triggerSnapshot {
+		if t.removed {
+			continue
+		}
+		if !isLive(t.Entity) {
+			t.exitAll()
+			continue
+		}
+		t.Shape.toWorld(t.Entity.Transform.WorldMatrix(), &t.shape)
+		for _, c := range s.colliderSnapshot {
+			if t.removed {
+				break
+			}
+			if c.removed || c.Layer&t.Mask == 0 || c.Entity == t.Entity || !isLive(c.Entity) {
+				continue
+			}
+			if !t.shape.overlaps(&c.shape) {
+				continue
+			}
+			_, inside := t.overlaps[c]
+			t.overlaps[c] = s.frame
+			if inside {
+				t.OnStay.Execute(c)
+			} else {
+				t.OnEnter.Execute(c)
+			}
+		}
+		for c, frame := range t.overlaps {
+			if frame != s.frame {
+				t.exit(c)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*****************************************************************************/
+/* level.go                                                                  */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package trigger
+
+import (
+	"kaiju/engine"
+	"kaiju/matrix"
+	"testing"
+)
+
+func testEntity(position matrix.Vec3) *engine.Entity {
+	e := engine.NewEntity()
+	e.Transform.SetPosition(position)
+	return e
+}
+
+func testSystem() *System {
+	return NewSystem(engine.NewHost("Trigger test"))
+}
+
+func TestSystemEventSequence(t *testing.T) {
+	s := testSystem()
+	zone := s.AddTrigger(testEntity(matrix.Vec3Zero()), NewBoxShape(matrix.Vec3One().Scale(2)), AllLayers)
+	mover := testEntity(matrix.Vec3{-3, 0, 0})
+	collider := s.AddCollider(mover, NewSphereShape(0.5), 1)
+	events := make([]string, 0)
+	zone.OnEnter.Add(func(c *Collider) {
+		if c != collider {
+			t.Error("unexpected collider entered")
+		}
+		events = append(events, "enter")
+	})
+	zone.OnStay.Add(func(*Collider) { events = append(events, "stay") })
+	zone.OnExit.Add(func(*Collider) { events = append(events, "exit") })
+	for _, x := range []float32{-3, -1.2, 0, 1.2, 3, 3} {
+		mover.Transform.SetPosition(matrix.Vec3{x, 0, 0})
+		s.update(0)
+	}
+	expected := []string{"enter", "stay", "stay", "exit"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected the events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected the events %v, got %v", expected, events)
+		}
+	}
+	if zone.Contains(collider) {
+		t.Error("the collider should no longer be inside of the trigger")
+	}
+}
+
+func TestSystemLayerMask(t *testing.T) {
+	s := testSystem()
+	zone := s.AddTrigger(testEntity(matrix.Vec3Zero()), NewSphereShape(1), 1<<1)
+	ignored := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+	detected := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1<<1)
+	s.update(0)
+	if zone.Contains(ignored) || !zone.Contains(detected) {
+		t.Error("only colliders on a layer in the mask should be detected")
+	}
+}
+
+func TestSystemRemoveColliderExits(t *testing.T) {
+	s := testSystem()
+	zone := s.AddTrigger(testEntity(matrix.Vec3Zero()), NewSphereShape(1), AllLayers)
+	collider := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+	exits := 0
+	zone.OnExit.Add(func(*Collider) { exits++ })
+	s.update(0)
+	s.RemoveCollider(collider)
+	s.RemoveCollider(collider)
+	s.update(0)
+	if exits != 1 {
+		t.Errorf("expected a single exit for the removed collider, got %d", exits)
+	}
+}
+
+func TestSystemMutateDuringEvents(t *testing.T) {
+	s := testSystem()
+	first := s.AddTrigger(testEntity(matrix.Vec3Zero()), NewSphereShape(1), AllLayers)
+	second := s.AddTrigger(testEntity(matrix.Vec3Zero()), NewSphereShape(1), AllLayers)
+	a := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+	b := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+	c := s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+	var spawned *Collider
+	firstEntered := make([]*Collider, 0)
+	first.OnEnter.Add(func(entered *Collider) {
+		firstEntered = append(firstEntered, entered)
+		if entered == a {
+			// Removing a later collider and adding a new one while the
+			// update is walking the colliders must not skip or crash
+			s.RemoveCollider(b)
+			spawned = s.AddCollider(testEntity(matrix.Vec3Zero()), NewSphereShape(0.5), 1)
+		}
+	})
+	secondEntered := 0
+	second.OnEnter.Add(func(*Collider) {
+		secondEntered++
+		s.RemoveTrigger(second)
+	})
+	s.update(0)
+	if len(firstEntered) != 2 || firstEntered[0] != a || firstEntered[1] != c {
+		t.Errorf("expected a and c to enter the first trigger, got %v", firstEntered)
+	}
+	if secondEntered != 1 {
+		t.Errorf("the removed trigger should stop raising events, got %d", secondEntered)
+	}
+	if second.Contains(a) || len(second.Overlapping()) != 0 {
+		t.Error("the removed trigger should not track overlaps")
+	}
+	s.update(0)
+	if !first.Contains(spawned) || first.Contains(b) {
+		t.Error("the spawned collider should be detected on the next update")
+	}
+	if len(s.colliders) != 3 || len(s.triggers) != 1 {
+		t.Errorf("expected 3 colliders and 1 trigger, got %d and %d", len(s.colliders), len(s.triggers))
+	}
+}
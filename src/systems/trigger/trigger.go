@@ -0,0 +1,108 @@
+/*****************************************************************************/
+/* trigger.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package trigger
+
+import (
+	"kaiju/engine"
+	"kaiju/systems/events"
+)
+
+const AllLayers = ^uint32(0)
+
+// Collider marks an entity as something that triggers can detect, it does
+// not take part in any physics simulation
+type Collider struct {
+	Entity  *engine.Entity
+	Shape   Shape
+	Layer   uint32
+	shape   worldShape
+	removed bool
+}
+
+// Trigger raises events as colliders on the layers in its mask begin to
+// overlap, continue to overlap, and stop overlapping its shape
+type Trigger struct {
+	Entity   *engine.Entity
+	Shape    Shape
+	Mask     uint32
+	OnEnter  events.EventWithArg[*Collider]
+	OnStay   events.EventWithArg[*Collider]
+	OnExit   events.EventWithArg[*Collider]
+	overlaps map[*Collider]uint64
+	shape    worldShape
+	removed  bool
+}
+
+func (t *Trigger) Contains(collider *Collider) bool {
+	_, ok := t.overlaps[collider]
+	return ok
+}
+
+func (t *Trigger) Overlapping() []*Collider {
+	out := make([]*Collider, 0, len(t.overlaps))
+	for c := range t.overlaps {
+		out = append(out, c)
+	}
+	return out
+}
+
+// ContainsEntity reports if any collider on the given entity is currently
+// inside of the trigger
+func (t *Trigger) ContainsEntity(entity *engine.Entity) bool {
+	for c := range t.overlaps {
+		if c.Entity == entity {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Trigger) exit(collider *Collider) {
+	delete(t.overlaps, collider)
+	t.OnExit.Execute(collider)
+}
+
+func (t *Trigger) exitAll() {
+	for c := range t.overlaps {
+		t.exit(c)
+	}
+}
+
+func isLive(entity *engine.Entity) bool {
+	return entity.IsActive() && !entity.IsDestroyed()
+}
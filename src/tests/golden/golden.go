@@ -0,0 +1,225 @@
+/*****************************************************************************/
+/* golden.go                                                                 */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package golden
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The largest possible YIQ difference between two colors, black and white
+const maxYIQDelta = 35215
+
+// FailureFolder is the folder, next to the golden images, where the actual
+// image and the diff image are written when a comparison fails
+const FailureFolder = "failures"
+
+var update = flag.Bool("update", false, "write the rendered images as the new golden images")
+
+type Options struct {
+	// Threshold is how different two pixels can be, from 0 to 1, before
+	// they are counted as different. The difference is perceptual, it is
+	// measured in YIQ space so changes in brightness count more than
+	// changes in hue
+	Threshold float64
+	// MaxDiffRatio is the fraction of the pixels that can be different
+	// before the images are considered to not match
+	MaxDiffRatio float64
+}
+
+type Result struct {
+	DiffPixels  int
+	TotalPixels int
+	// Diff is a faded copy of the expected image with the pixels that are
+	// different painted red
+	Diff *image.RGBA
+}
+
+func DefaultOptions() Options {
+	return Options{Threshold: 0.1, MaxDiffRatio: 0.001}
+}
+
+func (r Result) DiffRatio() float64 {
+	if r.TotalPixels == 0 {
+		return 0
+	}
+	return float64(r.DiffPixels) / float64(r.TotalPixels)
+}
+
+func (r Result) Passed(opts Options) bool {
+	return r.DiffRatio() <= opts.MaxDiffRatio
+}
+
+func rgb2y(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgb2i(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgb2q(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+// blendWhite blends the (premultiplied) color over white so that
+// transparent pixels are compared by how they look rather than by their
+// raw values
+func blendWhite(c color.RGBA) (float64, float64, float64) {
+	white := 255 - float64(c.A)
+	return float64(c.R) + white, float64(c.G) + white, float64(c.B) + white
+}
+
+func colorDelta(a, b color.RGBA) float64 {
+	if a == b {
+		return 0
+	}
+	r1, g1, b1 := blendWhite(a)
+	r2, g2, b2 := blendWhite(b)
+	y := rgb2y(r1, g1, b1) - rgb2y(r2, g2, b2)
+	i := rgb2i(r1, g1, b1) - rgb2i(r2, g2, b2)
+	q := rgb2q(r1, g1, b1) - rgb2q(r2, g2, b2)
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			rgba.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return rgba
+}
+
+// Compare counts the pixels of actual that are perceptually different from
+// expected, the images must be the same size
+func Compare(expected, actual image.Image, opts Options) (Result, error) {
+	eb, ab := expected.Bounds(), actual.Bounds()
+	if eb.Dx() != ab.Dx() || eb.Dy() != ab.Dy() {
+		return Result{}, fmt.Errorf("image sizes differ, expected %dx%d but got %dx%d",
+			eb.Dx(), eb.Dy(), ab.Dx(), ab.Dy())
+	}
+	e, a := toRGBA(expected), toRGBA(actual)
+	w, h := eb.Dx(), eb.Dy()
+	res := Result{
+		TotalPixels: w * h,
+		Diff:        image.NewRGBA(image.Rect(0, 0, w, h)),
+	}
+	maxDelta := maxYIQDelta * opts.Threshold * opts.Threshold
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ec, ac := e.RGBAAt(x, y), a.RGBAAt(x, y)
+			if colorDelta(ec, ac) > maxDelta {
+				res.DiffPixels++
+				res.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				r, g, b := blendWhite(ec)
+				v := uint8(255 + (rgb2y(r, g, b)-255)*0.1)
+				res.Diff.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+			}
+		}
+	}
+	return res, nil
+}
+
+func Read(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func Write(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Check compares the image to the golden image <dir>/<name>.png and fails
+// the test if they don't match. On failure the image and the diff image are
+// written to the FailureFolder inside of dir. Running the tests with the
+// -update flag writes the image as the new golden image instead
+func Check(t *testing.T, dir, name string, img image.Image, opts Options) {
+	t.Helper()
+	path := filepath.Join(dir, name+".png")
+	if *update {
+		if err := Write(path, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing golden image %s, run the tests with -update to create it", path)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Compare(expected, img, opts)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if res.Passed(opts) {
+		return
+	}
+	failures := filepath.Join(dir, FailureFolder)
+	actualPath := filepath.Join(failures, name+".actual.png")
+	diffPath := filepath.Join(failures, name+".diff.png")
+	if err := Write(actualPath, img); err != nil {
+		t.Error(err)
+	}
+	if err := Write(diffPath, res.Diff); err != nil {
+		t.Error(err)
+	}
+	t.Errorf("%s: %d of %d pixels (%.2f%%) differ from the golden image, see %s and %s",
+		name, res.DiffPixels, res.TotalPixels, res.DiffRatio()*100, actualPath, diffPath)
+}
@@ -48,7 +48,9 @@ import (
 	"kaiju/matrix"
 	"kaiju/rendering"
 	"kaiju/rendering/loaders"
+	"kaiju/systems/animation"
 	"kaiju/systems/console"
+	"kaiju/systems/lighting"
 	"kaiju/ui"
 	"strings"
 	"unsafe"
@@ -292,40 +294,48 @@ func testMonkeyGLB(host *engine.Host) {
 	drawBasicMesh(host, res)
 }
 
+// scenarios are the rendering tests by the name used to run them from the
+// console, they are also drawn and compared to golden images by go test
+var scenarios = map[string]func(*engine.Host){
+	"drawing":       testDrawing,
+	"two drawings":  testTwoDrawings,
+	"font":          testFont,
+	"oit":           testOIT,
+	"panel":         testPanel,
+	"label":         testLabel,
+	"button":        testButton,
+	"html":          testHTML,
+	"layout simple": testLayoutSimple,
+	"layout":        testLayout,
+	"html binding":  testHTMLBinding,
+	"obj":           testMonkeyOBJ,
+	"gltf":          testMonkeyGLTF,
+	"glb":           testMonkeyGLB,
+	"lights":        testLights,
+	"shadows":       testShadows,
+	"pbr":           testPBR,
+	"skinning":      testSkinning,
+	"morph":         testMorph,
+	"post process":  testPostProcess,
+}
+
+func testLights(host *engine.Host) {
+	testMonkeyOBJ(host)
+	sun := host.NewEntity()
+	sun.Transform.SetRotation(matrix.Vec3{-45, 30, 0})
+	lighting.NewDirectional(host, sun, matrix.ColorWhite(), 0.6)
+	bulb := host.NewEntity()
+	bulb.Transform.SetPosition(matrix.Vec3{-1, 0.5, 1})
+	lighting.NewPoint(host, bulb, matrix.ColorRed(), 1.5, 2.5)
+	spot := host.NewEntity()
+	spot.Transform.SetPosition(matrix.Vec3{1, 0, 1.5})
+	spot.LookAt(matrix.Vec3{0.5, 0, 0})
+	lighting.NewSpot(host, spot, matrix.ColorBlue(), 2, 4, 15, 25)
+}
+
 func SetupConsole(host *engine.Host) {
 	console.For(host).AddCommand("test", func(_ *engine.Host, t string) string {
-		var testFunc func(*engine.Host) = nil
-		switch strings.ToLower(t) {
-		case "drawing":
-			testFunc = testDrawing
-		case "two drawings":
-			testFunc = testTwoDrawings
-		case "font":
-			testFunc = testFont
-		case "oit":
-			testFunc = testOIT
-		case "panel":
-			testFunc = testPanel
-		case "label":
-			testFunc = testLabel
-		case "button":
-			testFunc = testButton
-		case "html":
-			testFunc = testHTML
-		case "layout simple":
-			testFunc = testLayoutSimple
-		case "layout":
-			testFunc = testLayout
-		case "html binding":
-			testFunc = testHTMLBinding
-		case "obj":
-			testFunc = testMonkeyOBJ
-		case "gltf":
-			testFunc = testMonkeyGLTF
-		case "glb":
-			testFunc = testMonkeyGLB
-		}
-		if testFunc != nil {
+		if testFunc, ok := scenarios[strings.ToLower(t)]; ok {
 			c := host_container.New("Test " + t)
 			go c.Run(engine.DefaultWindowWidth, engine.DefaultWindowHeight)
 			<-c.PrepLock
@@ -335,3 +345,151 @@ func SetupConsole(host *engine.Host) {
 		return "Running test"
 	})
 }
+
+func testShadows(host *engine.Host) {
+	shader := host.ShaderCache().ShaderFromDefinition(assets.ShaderDefinitionBasic)
+	tex, _ := host.TextureCache().Texture("textures/square.png", rendering.TextureFilterNearest)
+	models := []matrix.Mat4{matrix.Mat4Identity(), matrix.Mat4Identity()}
+	models[0].Scale(matrix.Vec3{4, 4, 1})
+	models[0].Translate(matrix.Vec3{0, 0, -0.75})
+	models[1].Scale(matrix.Vec3{0.4, 0.4, 0.4})
+	models[1].Rotate(matrix.Vec3{20, 35, 0})
+	meshes := []*rendering.Mesh{
+		rendering.NewMeshQuad(host.MeshCache()),
+		rendering.NewMeshTexturableCube(host.MeshCache()),
+	}
+	for i := range models {
+		tsd := &TestBasicShaderData{rendering.NewShaderDataBase(), matrix.ColorWhite()}
+		tsd.SetModel(models[i])
+		host.Drawings.AddDrawing(rendering.Drawing{
+			Renderer:   host.Window.Renderer,
+			Shader:     shader,
+			Mesh:       meshes[i],
+			Textures:   []*rendering.Texture{tex},
+			ShaderData: tsd,
+		})
+	}
+	sun := rendering.NewDirectionalLight(matrix.Vec3{0.4, -0.5, -1}, matrix.ColorWhite(), 0.7)
+	sun.CastShadows = true
+	spot := rendering.NewSpotLight(matrix.Vec3{-1.5, 0.5, 1.5}, matrix.Vec3{1.5, -0.5, -1.5},
+		matrix.ColorRed(), 1.5, 5, 15, 22)
+	spot.CastShadows = true
+	host.Lights.Add(&sun)
+	host.Lights.Add(&spot)
+}
+
+func testPBR(host *engine.Host) {
+	const monkeyGLTF = "meshes/monkey.gltf"
+	host.Camera.SetPosition(matrix.Vec3{0, 0, 4})
+	res := klib.MustReturn(loaders.GLTF(host.Window.Renderer, monkeyGLTF, host.AssetDatabase()))
+	m := res.Meshes[0]
+	material := klib.MustReturn(rendering.NewMaterial(monkeyGLTF, *m.Material,
+		host.ShaderCache(), host.TextureCache()))
+	host.MaterialCache().AddMaterial(material)
+	mesh := rendering.NewMesh(m.Name, m.Verts, m.Indexes)
+	host.MeshCache().AddMesh(mesh)
+	// From left to right: rough plastic, glossy plastic, rough metal and
+	// polished gold
+	surfaces := []struct {
+		color               matrix.Color
+		metallic, roughness float32
+	}{
+		{matrix.Color{0.8, 0.1, 0.1, 1}, 0, 0.9},
+		{matrix.Color{0.1, 0.3, 0.8, 1}, 0, 0.2},
+		{matrix.Color{0.9, 0.9, 0.9, 1}, 1, 0.6},
+		{matrix.Color{1, 0.77, 0.34, 1}, 1, 0.15},
+	}
+	for i, s := range surfaces {
+		mi := material.NewInstance()
+		mi.SetColor(rendering.PBRParamBaseColor, s.color)
+		mi.SetVec4(rendering.PBRParamFactors, matrix.Vec4{s.metallic, s.roughness, 1, 1})
+		model := matrix.Mat4Identity()
+		model.Scale(matrix.Vec3{0.7, 0.7, 0.7})
+		model.Translate(matrix.Vec3{float32(i)*1.9 - 2.85, 0, 0})
+		mi.SetModel(model)
+		host.Drawings.AddDrawing(mi.Drawing(host.Window.Renderer, mesh, nil))
+	}
+	sun := rendering.NewDirectionalLight(matrix.Vec3{-0.5, -0.6, -1}, matrix.ColorWhite(), 1.5)
+	host.Lights.Add(&sun)
+}
+
+func testSkinning(host *engine.Host) {
+	const columnGLTF = "meshes/skinned_column.gltf"
+	host.Camera.SetPosition(matrix.Vec3{0, 0, 4})
+	res := klib.MustReturn(loaders.GLTF(host.Window.Renderer, columnGLTF, host.AssetDatabase()))
+	m := res.Meshes[0]
+	mesh := rendering.NewMesh(m.Name, m.Verts, m.Indexes)
+	host.MeshCache().AddMesh(mesh)
+	material := klib.MustReturn(host.MaterialCache().Material(assets.MaterialSkinned))
+	// The left column bends, the right one blends the bend with a sway of
+	// its root. Both are advanced to the peak of the bend once the harness
+	// has run its frames
+	blends := []map[string]float32{
+		{"bend": 1},
+		{"bend": 0.5, "sway": 0.5},
+	}
+	for i, blend := range blends {
+		entity := host.NewEntity()
+		animator := klib.MustReturn(animation.Attach(host, entity, &res.Skeletons[m.Skin], res.Animations))
+		for clip, weight := range blend {
+			klib.Must(animator.Blend(clip, weight, true))
+		}
+		animator.Update(1 - 3.0/60.0)
+		mi := material.NewInstance()
+		mi.SetColor("color", matrix.Color{0.9, 0.6, 0.3, 1})
+		mi.SetFloat("jointOffset", float32(animator.Skin.Offset()))
+		model := matrix.Mat4Identity()
+		model.Translate(matrix.Vec3{float32(i)*1.6 - 0.8, 0, 0})
+		mi.SetModel(model)
+		host.Drawings.AddDrawing(mi.Drawing(host.Window.Renderer, mesh, nil))
+	}
+	sun := rendering.NewDirectionalLight(matrix.Vec3{-0.5, -0.6, -1}, matrix.ColorWhite(), 1.2)
+	host.Lights.Add(&sun)
+}
+
+func testMorph(host *engine.Host) {
+	const sheetGLTF = "meshes/morph_sheet.gltf"
+	host.Camera.SetPosition(matrix.Vec3{0, 0, 3})
+	res := klib.MustReturn(loaders.GLTF(host.Window.Renderer, sheetGLTF, host.AssetDatabase()))
+	m := res.Meshes[0]
+	mesh := rendering.NewMesh(m.Name, m.Verts, m.Indexes)
+	host.MeshCache().AddMesh(mesh)
+	targets := m.NewMorphTargets()
+	klib.Must(host.Morphs.Add(targets))
+	material := klib.MustReturn(host.MaterialCache().Material(assets.MaterialMorph))
+	// From left to right: the default weights, a fully tall instance and an
+	// instance whose weights are animated to the peak of the pulse once the
+	// harness has run its frames
+	for i := range 3 {
+		mi := material.NewInstance()
+		mi.SetColor("color", matrix.Color{0.4, 0.6, 0.9, 1})
+		mi.SetMorphTargets(targets)
+		mi.SetMorphWeights(m.MorphWeights)
+		switch i {
+		case 1:
+			mi.SetMorphWeights([]float32{1, 0})
+		case 2:
+			animator := klib.MustReturn(animation.Attach(host, host.NewEntity(),
+				&loaders.ResultSkeleton{}, res.Animations))
+			animator.AddMorph(m.Node, mi, m.MorphWeights)
+			klib.Must(animator.Play("pulse", true))
+			animator.Update(1 - 3.0/60.0)
+		}
+		model := matrix.Mat4Identity()
+		model.Translate(matrix.Vec3{float32(i)*1.3 - 1.5, -0.2, 0})
+		mi.SetModel(model)
+		host.Drawings.AddDrawing(mi.Drawing(host.Window.Renderer, mesh, nil))
+	}
+	sun := rendering.NewDirectionalLight(matrix.Vec3{-0.6, -0.3, -1}, matrix.ColorWhite(), 1.2)
+	host.Lights.Add(&sun)
+}
+
+func testPostProcess(host *engine.Host) {
+	testLights(host)
+	stack := host.PostProcessing.Stack(host.Camera)
+	stack.Add(rendering.NewBloomEffect(0.6, 1, 2))
+	stack.Add(rendering.NewChromaticAberrationEffect(0.01))
+	stack.Add(rendering.NewVignetteEffect(0.8, 1, 0.5, matrix.ColorBlack()))
+	stack.Add(rendering.NewTonemapEffect(1.5, 1))
+	stack.Add(rendering.NewFXAAEffect())
+}
@@ -0,0 +1,277 @@
+/*****************************************************************************/
+/* rendering_tests_test.go                                                   */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package tests
+
+import (
+	"errors"
+	"image"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/tests/golden"
+	"kaiju/tests/testhost"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const (
+	goldenWidth  = 640
+	goldenHeight = 360
+	goldenFrames = 3
+	// goldenReadFrames is how many more frames a Vulkan read back has to
+	// finish in
+	goldenReadFrames = 8
+)
+
+var (
+	goldenFolder string
+	// vulkanGoldenFolder holds the goldens drawn by the Vulkan renderer,
+	// they are kept apart from the software ones since the two rasterize
+	// and filter a little differently
+	vulkanGoldenFolder string
+)
+
+// TestMain moves into the folder holding the content folder so the
+// scenarios can load their assets like the engine does when it runs
+func TestMain(m *testing.M) {
+	wd, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	goldenFolder = filepath.Join(wd, "testdata", "golden")
+	vulkanGoldenFolder = filepath.Join(goldenFolder, "vulkan")
+	if err := testhost.ChdirToContent(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func scenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func goldenName(name string) string { return strings.ReplaceAll(name, " ", "_") }
+
+func setupScenario(host *engine.Host, scenario func(*engine.Host)) {
+	host.FontCache().Init(host.Window.Renderer, host.AssetDatabase(), host)
+	host.Camera.SetPosition(matrix.Vec3{0, 0, 2})
+	scenario(host)
+}
+
+// drawScenarioTarget draws the frame of the host into a new render target
+// and runs the post processing of the camera over it
+func drawScenarioTarget(host *engine.Host, options rendering.RenderTargetOptions) (rendering.RenderTarget, error) {
+	renderer := host.Window.Renderer
+	target, err := rendering.NewRenderTargetWithOptions(renderer, options)
+	if err != nil {
+		return nil, err
+	}
+	host.Drawings.RenderToTarget(renderer, target)
+	return host.PostProcessing.Find(host.Camera).Apply(renderer, target)
+}
+
+// checkScenarioShaders fails the test when a shader of the scenario could
+// not be created. Only the vertex input limit of the driver is a skip,
+// SwiftShader has 16 and the UI and text shaders need more
+func checkScenarioShaders(t *testing.T, host *engine.Host) {
+	t.Helper()
+	failed := host.ShaderCache().FailedShaders()
+	for _, shader := range host.Drawings.Shaders() {
+		err, ok := failed[shader.KeyName]
+		if !ok {
+			continue
+		}
+		if errors.Is(err, rendering.ErrVertexInputLimit) {
+			t.Skipf("the driver can't draw %s: %v", shader.KeyName, err)
+		}
+		t.Fatalf("failed to create %s: %v", shader.KeyName, err)
+	}
+}
+
+// renderScenario draws the scenario with the software renderer, which
+// runs everywhere so the goldens are checked on every machine
+func renderScenario(t *testing.T, name string, scenario func(*engine.Host), options rendering.RenderTargetOptions) *image.RGBA {
+	t.Helper()
+	host := testhost.New(t, "Test "+name, goldenWidth, goldenHeight)
+	setupScenario(host, scenario)
+	// Some scenarios (like the UI layouts) take a few frames to settle
+	for i := 0; i < goldenFrames; i++ {
+		host.Update(1.0 / 60.0)
+		host.Render()
+	}
+	checkScenarioShaders(t, host)
+	target, err := drawScenarioTarget(host, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := rendering.ReadRenderTarget(host.Window.Renderer, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// renderScenarioVulkan draws the scenario through a headless Vulkan
+// renderer (lavapipe or SwiftShader on machines without a GPU) and reads
+// the frame back from an offscreen render target once the GPU is done
+// with it. It skips when there is no Vulkan driver
+func renderScenarioVulkan(t *testing.T, name string, scenario func(*engine.Host), options rendering.RenderTargetOptions) *image.RGBA {
+	t.Helper()
+	renderer, err := rendering.NewVKRendererHeadless(goldenWidth, goldenHeight, "Test "+name)
+	if err != nil {
+		t.Skipf("no Vulkan driver to render with: %v", err)
+	}
+	host := engine.NewHost("Test " + name)
+	host.InitializeHeadless(goldenWidth, goldenHeight, renderer)
+	if err := renderer.Initialize(host, goldenWidth, goldenHeight); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { testhost.Teardown(host) })
+	setupScenario(host, scenario)
+	for i := 0; i < goldenFrames; i++ {
+		host.Update(1.0 / 60.0)
+		host.Render()
+	}
+	checkScenarioShaders(t, host)
+	var img *image.RGBA
+	var readErr error
+	host.OnFrameDrawn.Add(func() {
+		if img != nil || readErr != nil {
+			return
+		}
+		target, err := drawScenarioTarget(host, options)
+		if err != nil {
+			readErr = err
+			return
+		}
+		rendering.ReadRenderTargetAsync(renderer, target, func(read *image.RGBA, err error) {
+			img, readErr = read, err
+		})
+	})
+	for i := 0; i < goldenReadFrames && img == nil && readErr == nil; i++ {
+		host.Update(1.0 / 60.0)
+		host.Render()
+	}
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if img == nil {
+		t.Fatalf("the render target was not read back within %d frames", goldenReadFrames)
+	}
+	return img
+}
+
+func TestScenariosMatchGolden(t *testing.T) {
+	for _, name := range scenarioNames() {
+		t.Run(name, func(t *testing.T) {
+			img := renderScenario(t, name, scenarios[name], rendering.RenderTargetOptions{})
+			golden.Check(t, goldenFolder, goldenName(name), img, golden.DefaultOptions())
+		})
+	}
+}
+
+func TestScenariosMatchGoldenVulkan(t *testing.T) {
+	for _, name := range scenarioNames() {
+		t.Run(name, func(t *testing.T) {
+			img := renderScenarioVulkan(t, name, scenarios[name], rendering.RenderTargetOptions{})
+			golden.Check(t, vulkanGoldenFolder, goldenName(name), img, golden.DefaultOptions())
+		})
+	}
+}
+
+var scenarioRenderers = []struct {
+	name   string
+	render func(*testing.T, string, func(*engine.Host), rendering.RenderTargetOptions) *image.RGBA
+}{
+	{"software", renderScenario},
+	{"vulkan", renderScenarioVulkan},
+}
+
+// TestTransparentDrawingsKeepTargetOpaque draws transparent drawings over
+// solid ones and the opaque clear color, the target that is read back has
+// to stay as opaque as they left it
+func TestTransparentDrawingsKeepTargetOpaque(t *testing.T) {
+	for _, r := range scenarioRenderers {
+		t.Run(r.name, func(t *testing.T) {
+			img := r.render(t, "oit", scenarios["oit"], rendering.RenderTargetOptions{})
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if a := img.RGBAAt(x, y).A; a != 255 {
+						t.Fatalf("expected an opaque target, the pixel at %d, %d has an alpha of %d", x, y, a)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestReadDepthTarget reads back the depth of a drawing, it is grey from
+// the near plane to the far one where nothing was drawn
+func TestReadDepthTarget(t *testing.T) {
+	options := rendering.RenderTargetOptions{Format: rendering.RenderTargetFormatDepth}
+	for _, r := range scenarioRenderers {
+		t.Run(r.name, func(t *testing.T) {
+			img := r.render(t, "drawing", scenarios["drawing"], options)
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if c := img.RGBAAt(x, y); c.R != c.G || c.R != c.B || c.A != 255 {
+						t.Fatalf("expected an opaque grey depth, the pixel at %d, %d is %v", x, y, c)
+					}
+				}
+			}
+			center := img.RGBAAt(b.Dx()/2, b.Dy()/2).R
+			corner := img.RGBAAt(0, 0).R
+			if corner != 255 {
+				t.Errorf("expected the far plane where nothing was drawn, the corner is %d", corner)
+			}
+			if center >= corner {
+				t.Errorf("expected the drawing in front of the far plane, the center is %d", center)
+			}
+		})
+	}
+}
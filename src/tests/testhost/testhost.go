@@ -0,0 +1,95 @@
+/*****************************************************************************/
+/* testhost.go                                                               */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package testhost
+
+import (
+	"errors"
+	"kaiju/engine"
+	"kaiju/rendering"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ChdirToContent moves into the closest folder above the working directory
+// that holds the content folder, so tests can load their assets like the
+// engine does when it runs
+func ChdirToContent() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		if s, err := os.Stat(filepath.Join(dir, "content")); err == nil && s.IsDir() {
+			return os.Chdir(dir)
+		}
+		if filepath.Dir(dir) == dir {
+			return errors.New("could not find the content folder")
+		}
+	}
+}
+
+// Main is a TestMain for packages whose tests load content, it moves into
+// the folder holding the content folder before running them
+func Main(m *testing.M) {
+	if err := ChdirToContent(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// New creates a host without a window that draws with the software
+// renderer, the host is torn down when the test ends
+func New(t testing.TB, name string, width, height int) *engine.Host {
+	t.Helper()
+	renderer := rendering.NewSoftwareRenderer(width, height)
+	host := engine.NewHost(name)
+	host.InitializeHeadless(width, height, renderer)
+	if err := renderer.Initialize(host, int32(width), int32(height)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Teardown(host) })
+	return host
+}
+
+// Teardown closes the host, the close signal is drained since nothing
+// else listens to it in a test
+func Teardown(host *engine.Host) {
+	go func() { <-host.CloseSignal }()
+	host.Teardown()
+}
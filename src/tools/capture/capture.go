@@ -0,0 +1,277 @@
+/*****************************************************************************/
+/* capture.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"kaiju/engine"
+	"kaiju/rendering"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	screenshotFolder = "screenshots"
+	captureFolder    = "captures"
+	timestampFormat  = "2006-01-02_15-04-05.000"
+	DefaultFPS       = 60
+	MaxScale         = 8
+)
+
+// Recorder writes the frames the host draws into PNG files. The frames are
+// read back once they are drawn and the files are written on other
+// goroutines, so the results are reported through Report on later frames
+type Recorder struct {
+	host   *engine.Host
+	folder string
+	shots  []int
+	// Report is given a message when a file is written or fails to be
+	// written, it is called on the main thread during the host update
+	Report  func(message string)
+	capture struct {
+		folder   string
+		frame    int
+		frames   int
+		timestep float64
+		writes   *sync.WaitGroup
+	}
+	messages chan string
+	writes   sync.WaitGroup
+	// captureWrites is set from the start of a capture until all of its
+	// frames are written, a new capture waits for it to clear
+	captureWrites atomic.Bool
+	// lastShot and sameShots number screenshots taken in the same
+	// millisecond so they do not write over each other
+	lastShot  string
+	sameShots int
+}
+
+// New creates a recorder that writes into the screenshots and captures
+// folders inside of the given folder
+func New(host *engine.Host, folder string) *Recorder {
+	r := &Recorder{
+		host:     host,
+		folder:   folder,
+		messages: make(chan string, 64),
+	}
+	host.OnFrameDrawn.Add(r.frameDrawn)
+	host.Updater.AddUpdate(r.update)
+	return r
+}
+
+// Screenshot writes the next frame into a PNG named after the time it was
+// taken. A scale above 1 draws the scene again into a target that many
+// times the size of the window, the post process stack is not applied to
+// these as its targets are the size of the window
+func (r *Recorder) Screenshot(scale int) error {
+	if scale < 1 || scale > MaxScale {
+		return fmt.Errorf("the screenshot scale must be between 1 and %d", MaxScale)
+	}
+	r.shots = append(r.shots, scale)
+	return nil
+}
+
+// Capture writes the next frames into a numbered PNG series. The host is
+// stepped at a fixed timestep for the frame rate while capturing, so the
+// series plays back at that rate no matter how long the frames took
+func (r *Recorder) Capture(frames, fps int) error {
+	if r.Capturing() {
+		return errors.New("a capture is already running")
+	}
+	if r.captureWrites.Load() {
+		return errors.New("the frames of the last capture are still being written")
+	}
+	if frames < 1 || fps < 1 {
+		return errors.New("the frame count and frame rate must be above 0")
+	}
+	c := &r.capture
+	c.folder = filepath.Join(r.folder, captureFolder,
+		"capture_"+time.Now().Format(timestampFormat))
+	c.frame = 0
+	c.frames = frames
+	c.timestep = r.host.FixedTimestep()
+	c.writes = &sync.WaitGroup{}
+	r.captureWrites.Store(true)
+	r.host.SetFixedTimestep(1 / float64(fps))
+	return nil
+}
+
+// StopCapture ends the capture early, the frames that were drawn are
+// still written
+func (r *Recorder) StopCapture() {
+	if r.Capturing() {
+		r.capture.frames = r.capture.frame
+		r.finishCapture()
+	}
+}
+
+func (r *Recorder) finishCapture() {
+	c := &r.capture
+	r.host.SetFixedTimestep(c.timestep)
+	writes, folder, count := c.writes, c.folder, c.frames
+	r.writes.Add(1)
+	go func() {
+		defer r.writes.Done()
+		writes.Wait()
+		r.captureWrites.Store(false)
+		r.send(fmt.Sprintf("Captured %d frames to %s", count, folder))
+	}()
+}
+
+func (r *Recorder) Capturing() bool { return r.capture.frame < r.capture.frames }
+
+// Wait blocks until the files that were read back are written
+func (r *Recorder) Wait() { r.writes.Wait() }
+
+func (r *Recorder) frameDrawn() {
+	for _, scale := range r.shots {
+		r.screenshot(scale)
+	}
+	r.shots = r.shots[:0]
+	if r.Capturing() {
+		c := &r.capture
+		path := filepath.Join(c.folder, fmt.Sprintf("frame_%05d.png", c.frame))
+		c.writes.Add(1)
+		r.read(r.host.FrameTarget(), nil, path, c.writes)
+		c.frame++
+		if c.frame == c.frames {
+			r.finishCapture()
+		}
+	}
+}
+
+func (r *Recorder) screenshot(scale int) {
+	name := "screenshot_" + time.Now().Format(timestampFormat)
+	if name == r.lastShot {
+		r.sameShots++
+	} else {
+		r.lastShot, r.sameShots = name, 0
+	}
+	if r.sameShots > 0 {
+		name += fmt.Sprintf("_%d", r.sameShots)
+	}
+	path := filepath.Join(r.folder, screenshotFolder, name+".png")
+	if scale == 1 {
+		r.read(r.host.FrameTarget(), nil, path, nil)
+		return
+	}
+	renderer := r.host.Window.Renderer
+	target, err := rendering.NewRenderTargetWithOptions(renderer, rendering.RenderTargetOptions{
+		Width:  r.host.Window.Width() * scale,
+		Height: r.host.Window.Height() * scale,
+		Resize: rendering.RenderTargetResizeFixed,
+	})
+	if err != nil {
+		r.send("Failed to take the screenshot: " + err.Error())
+		return
+	}
+	r.host.Drawings.RenderToTarget(renderer, target)
+	r.read(target, target, path, nil)
+}
+
+// read reads back the target and writes it to the path, owned is a target
+// that was made for the read and is destroyed once it is done
+func (r *Recorder) read(target, owned rendering.RenderTarget, path string, group *sync.WaitGroup) {
+	renderer := r.host.Window.Renderer
+	r.writes.Add(1)
+	rendering.ReadRenderTargetAsync(renderer, target, func(img *image.RGBA, err error) {
+		if owned != nil {
+			rendering.DestroyRenderTarget(renderer, owned)
+		}
+		go func() {
+			defer r.writes.Done()
+			if group != nil {
+				defer group.Done()
+			}
+			if err == nil {
+				err = writeImage(img, path)
+			}
+			// Every frame of a capture is not reported, only the whole
+			if err != nil {
+				r.send(fmt.Sprintf("Failed to write %s: %v", path, err))
+			} else if group == nil {
+				r.send("Screenshot written to " + path)
+			}
+		}()
+	})
+}
+
+// send queues the message for Report without blocking the caller, the
+// messages that don't fit while the host is not updating go to the log
+func (r *Recorder) send(message string) {
+	select {
+	case r.messages <- message:
+	default:
+		log.Println(message)
+	}
+}
+
+func (r *Recorder) update(float64) {
+	for {
+		select {
+		case msg := <-r.messages:
+			if r.Report != nil {
+				r.Report(msg)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeImage writes the frames, tests replace it to hold the writes back
+var writeImage = writePNG
+
+func writePNG(img *image.RGBA, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
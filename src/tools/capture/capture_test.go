@@ -0,0 +1,173 @@
+/*****************************************************************************/
+/* capture_test.go                                                           */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"kaiju/engine"
+	"kaiju/tests/testhost"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testHost(t *testing.T) *engine.Host {
+	return testhost.New(t, "Capture test", 32, 24)
+}
+
+func testFrame(host *engine.Host) {
+	host.Update(1.0 / 30.0)
+	host.Render()
+}
+
+func testPNGSize(t *testing.T, path string, width, height int) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != width || cfg.Height != height {
+		t.Fatalf("expected %s to be %dx%d, got %dx%d", path, width, height, cfg.Width, cfg.Height)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	host := testHost(t)
+	r := New(host, t.TempDir())
+	if r.Screenshot(0) == nil {
+		t.Fatal("expected a scale of 0 to fail")
+	}
+	r.Screenshot(1)
+	testFrame(host)
+	r.Screenshot(2)
+	testFrame(host)
+	r.Wait()
+	files, _ := filepath.Glob(filepath.Join(r.folder, screenshotFolder, "*.png"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 screenshots, got %d", len(files))
+	}
+	testPNGSize(t, files[0], 32, 24)
+	testPNGSize(t, files[1], 64, 48)
+}
+
+func TestCapture(t *testing.T) {
+	host := testHost(t)
+	r := New(host, t.TempDir())
+	if err := r.Capture(3, 10); err != nil {
+		t.Fatal(err)
+	}
+	if r.Capture(3, 10) == nil {
+		t.Fatal("expected a second capture to fail while capturing")
+	}
+	if host.FixedTimestep() != 0.1 {
+		t.Fatalf("expected a fixed timestep of 0.1, got %f", host.FixedTimestep())
+	}
+	for range 5 {
+		testFrame(host)
+	}
+	r.Wait()
+	if r.Capturing() || host.FixedTimestep() != 0 {
+		t.Fatal("expected the capture to end and restore the timestep")
+	}
+	files, _ := filepath.Glob(filepath.Join(r.capture.folder, "frame_*.png"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(files))
+	}
+	testPNGSize(t, files[2], 32, 24)
+}
+
+func TestCaptureWaitsForLastWrites(t *testing.T) {
+	release := make(chan struct{})
+	writeImage = func(img *image.RGBA, path string) error {
+		<-release
+		return writePNG(img, path)
+	}
+	defer func() { writeImage = writePNG }()
+	host := testHost(t)
+	r := New(host, t.TempDir())
+	if err := r.Capture(2, 10); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		testFrame(host)
+	}
+	if r.Capturing() {
+		t.Fatal("expected the capture to have drawn its frames")
+	}
+	if r.Capture(2, 10) == nil {
+		t.Fatal("expected a capture to fail while the last one is being written")
+	}
+	close(release)
+	r.Wait()
+	if err := r.Capture(2, 10); err != nil {
+		t.Fatalf("expected a capture once the last one was written, got %v", err)
+	}
+	r.StopCapture()
+	r.Wait()
+}
+
+func TestSendDoesNotBlock(t *testing.T) {
+	host := testHost(t)
+	r := New(host, t.TempDir())
+	for i := range cap(r.messages) + 1 {
+		r.send(fmt.Sprintf("message %d", i))
+	}
+	reported := 0
+	r.Report = func(string) { reported++ }
+	testFrame(host)
+	if reported != cap(r.messages) {
+		t.Fatalf("expected the %d queued messages to be reported, got %d", cap(r.messages), reported)
+	}
+}
+
+func TestConsoleCreatesRecorderOnUse(t *testing.T) {
+	c := &consoleRecorder{host: testHost(t)}
+	if msg := captureCommand(c, "stop"); msg != "Not capturing" || c.recorder != nil {
+		t.Fatalf("expected stopping to leave the recorder uncreated, got %q", msg)
+	}
+	if msg := screenshotCommand(c, "0"); c.recorder == nil {
+		t.Fatalf("expected the screenshot command to create the recorder, got %q", msg)
+	}
+}
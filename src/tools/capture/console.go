@@ -0,0 +1,111 @@
+/*****************************************************************************/
+/* console.go                                                                */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package capture
+
+import (
+	"kaiju/engine"
+	"kaiju/klib"
+	"kaiju/systems/console"
+	"strconv"
+	"strings"
+)
+
+// consoleRecorder creates the recorder the first time a command needs it,
+// hosts that never take a screenshot don't pay for its frame callbacks
+type consoleRecorder struct {
+	host     *engine.Host
+	report   func(message string)
+	recorder *Recorder
+}
+
+func (c *consoleRecorder) get() *Recorder {
+	if c.recorder == nil {
+		c.recorder = New(c.host, ".")
+		c.recorder.Report = c.report
+	}
+	return c.recorder
+}
+
+func screenshotCommand(c *consoleRecorder, arg string) string {
+	scale := 1
+	if arg != "" {
+		var err error
+		if scale, err = strconv.Atoi(arg); err != nil {
+			return `Expected "screenshot" or "screenshot <scale>"`
+		}
+	}
+	if err := c.get().Screenshot(scale); err != nil {
+		return err.Error()
+	}
+	return "Taking a screenshot"
+}
+
+func captureCommand(c *consoleRecorder, arg string) string {
+	arg = klib.ReplaceStringRecursive(arg, "  ", " ")
+	args := strings.Split(arg, " ")
+	if args[0] == "stop" {
+		if c.recorder == nil || !c.recorder.Capturing() {
+			return "Not capturing"
+		}
+		c.recorder.StopCapture()
+		return "Capture stopped"
+	}
+	frames, err := strconv.Atoi(args[0])
+	fps := DefaultFPS
+	if err == nil && len(args) > 1 {
+		fps, err = strconv.Atoi(args[1])
+	}
+	if err != nil || len(args) > 2 {
+		return `Expected "capture <frames>", "capture <frames> <fps>" or "capture stop"`
+	}
+	if err := c.get().Capture(frames, fps); err != nil {
+		return err.Error()
+	}
+	return "Capturing " + strconv.Itoa(frames) + " frames at " + strconv.Itoa(fps) + " fps"
+}
+
+func SetupConsole(host *engine.Host) {
+	c := console.For(host)
+	r := &consoleRecorder{host: host, report: c.Write}
+	c.AddCommand("screenshot", func(_ *engine.Host, arg string) string {
+		return screenshotCommand(r, arg)
+	})
+	c.AddCommand("capture", func(_ *engine.Host, arg string) string {
+		return captureCommand(r, arg)
+	})
+}
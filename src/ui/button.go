@@ -65,7 +65,7 @@ func (b *Button) Label() *Label {
 		}
 	}
 	if pui == nil {
-		return b.createLabel()
+		return b.createLabel("")
 	} else {
 		return pui.(*Label)
 	}
@@ -75,12 +75,12 @@ func NewButton(host *engine.Host, texture *rendering.Texture, text string, ancho
 	panel := NewPanel(host, texture, anchor)
 	btn := (*Button)(panel)
 	btn.setup(text)
-	btn.createLabel()
+	btn.createLabel(text)
 	return btn
 }
 
-func (b *Button) createLabel() *Label {
-	lbl := NewLabel(b.host, "", AnchorStretchCenter)
+func (b *Button) createLabel(text string) *Label {
+	lbl := NewLabel(b.host, text, AnchorStretchCenter)
 	lbl.layout.SetStretch(0, 0, 0, 0)
 	lbl.SetColor(matrix.ColorBlack())
 	lbl.SetBGColor(b.shaderData.FgColor)
@@ -94,6 +94,9 @@ func (b *Button) setup(text string) {
 	p := (*Panel)(b)
 	p.localData = &buttonData{matrix.ColorWhite()}
 	p.SetColor(matrix.ColorWhite())
+	// The label stretches to the button, fitting the button to the label
+	// would grow both of them forever
+	p.DontFitContent()
 	btn := (*Button)(p)
 	btn.setupEvents()
 	ps := p.layout.PixelSize()
@@ -142,6 +142,10 @@ func (label *Label) clearDrawings() {
 }
 
 func (label *Label) postLayoutUpdate() {
+	// A stretched label takes its height from the parent
+	if label.layout.Anchor().IsStretch() {
+		return
+	}
 	maxWidth := float32(999999.0)
 	if label.wordWrap {
 		maxWidth = label.layout.PixelSize().Width()
@@ -164,6 +164,10 @@ func (a Anchor) IsBottom() bool {
 	return a == AnchorBottomLeft || a == AnchorBottomCenter || a == AnchorBottomRight || a == AnchorStretchBottom
 }
 
+func (a Anchor) IsStretch() bool {
+	return a == AnchorStretchLeft || a == AnchorStretchTop || a == AnchorStretchRight || a == AnchorStretchBottom || a == AnchorStretchCenter
+}
+
 type Layout struct {
 	offset           matrix.Vec2
 	rowLayoutOffset  matrix.Vec2
@@ -179,7 +183,6 @@ type Layout struct {
 	screenAnchor     Anchor
 	layoutFunction   func(layout *Layout)
 	anchorFunction   func(self *Layout, w, h float32, size matrix.Vec2) matrix.Vec4
-	worldScalar      matrix.Vec2
 	border           matrix.Vec4
 	padding          matrix.Vec4
 	margin           matrix.Vec4
@@ -342,14 +345,19 @@ func layoutStretch(self *Layout) {
 	y := res.Y() + self.CalcOffset().Y()
 	xSize := res.Z()
 	ySize := res.W()
-	scale := matrix.Vec3{xSize * self.worldScalar.X(), ySize * self.worldScalar.Y(), 1}
-	scale[matrix.Vx] -= (self.inset.X() + self.inset.Z()) / bounds.X()
-	scale[matrix.Vy] -= (self.inset.Y() + self.inset.W()) / bounds.Y()
+	scale := matrix.Vec3{
+		xSize - (self.inset.X() + self.inset.Z()),
+		ySize - (self.inset.Y() + self.inset.W()),
+		1,
+	}
+	if self.ui.Entity().Parent != nil {
+		scale.DivideAssign(self.ui.Entity().Parent.Transform.WorldScale())
+	}
 	self.ui.Entity().ScaleWithoutChildren(scale)
 	pos := matrix.Vec3{
-		x + bounds.X() + (self.inset.X()-self.inset.Z())*0.5,
-		y + bounds.Y() + (self.inset.W()-self.inset.Y())*0.5,
-		t.Position().Z(),
+		x - bounds.X()*0.5 + (self.inset.X()-self.inset.Z())*0.5,
+		y - bounds.Y()*0.5 + (self.inset.W()-self.inset.Y())*0.5,
+		self.z + 0.01,
 	}
 	t.SetPosition(pos)
 }
@@ -0,0 +1,162 @@
+/*****************************************************************************/
+/* layout_test.go                                                            */
+/*****************************************************************************/
+/*                           This file is part of:                           */
+/*                                KAIJU ENGINE                               */
+/*                          https://kaijuengine.org                          */
+/*****************************************************************************/
+/* MIT License                                                               */
+/*                                                                           */
+/* Copyright (c) 2023-present Kaiju Engine contributors (CONTRIBUTORS.md).   */
+/* Copyright (c) 2015-2023 Brent Farris.                                     */
+/*                                                                           */
+/* May all those that this source may reach be blessed by the LORD and find  */
+/* peace and joy in life.                                                    */
+/* Everyone who drinks of this water will be thirsty again; but whoever      */
+/* drinks of the water that I will give him shall never thirst; John 4:13-14 */
+/*                                                                           */
+/* Permission is hereby granted, free of charge, to any person obtaining a   */
+/* copy of this software and associated documentation files (the "Software"),*/
+/* to deal in the Software without restriction, including without limitation */
+/* the rights to use, copy, modify, merge, publish, distribute, sublicense,  */
+/* and/or sell copies of the Software, and to permit persons to whom the     */
+/* Software is furnished to do so, subject to the following conditions:      */
+/*                                                                           */
+/* The above copyright, blessing, biblical verse, notice and                 */
+/* this permission notice shall be included in all copies or                 */
+/* substantial portions of the Software.                                     */
+/*                                                                           */
+/* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS   */
+/* OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF                */
+/* MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.    */
+/* IN NO EVENT SHALL THE /* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY   */
+/* CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT */
+/* OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE     */
+/* OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.                             */
+/*****************************************************************************/
+
+package ui
+
+import (
+	"kaiju/assets"
+	"kaiju/engine"
+	"kaiju/matrix"
+	"kaiju/rendering"
+	"kaiju/tests/testhost"
+	"testing"
+)
+
+func TestMain(m *testing.M) { testhost.Main(m) }
+
+func testUIHost(t *testing.T) (*engine.Host, *rendering.Texture) {
+	t.Helper()
+	host := testhost.New(t, "UI layout test", 640, 360)
+	host.FontCache().Init(host.Window.Renderer, host.AssetDatabase(), host)
+	tex, err := host.TextureCache().Texture(assets.TextureSquare, rendering.TextureFilterLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, tex
+}
+
+func testUIFrames(host *engine.Host, frames int) {
+	for range frames {
+		host.Update(1.0 / 60.0)
+		host.Render()
+	}
+}
+
+// testUIEdges are the left, top, right and bottom edges of the UI in pixels
+func testUIEdges(ui UI) matrix.Vec4 {
+	p := ui.Entity().Transform.WorldPosition()
+	s := ui.Layout().PixelSize()
+	return matrix.Vec4{
+		p.X() - s.X()*0.5,
+		p.Y() + s.Y()*0.5,
+		p.X() + s.X()*0.5,
+		p.Y() - s.Y()*0.5,
+	}
+}
+
+func testExpectEdges(t *testing.T, name string, ui UI, expected matrix.Vec4) {
+	t.Helper()
+	if edges := testUIEdges(ui); !matrix.Vec4ApproxTo(edges, expected, 0.01) {
+		t.Errorf("expected the edges of %s to be %v, got %v", name, expected, edges)
+	}
+}
+
+func TestLayoutStretchInsetsFromParent(t *testing.T) {
+	host, tex := testUIHost(t)
+	parent := NewPanel(host, tex, AnchorTopLeft)
+	parent.DontFitContent()
+	parent.layout.Scale(200, 100)
+	child := NewPanel(host, tex, AnchorStretchCenter)
+	child.DontFitContent()
+	parent.AddChild(child)
+	child.layout.SetStretch(10, 20, 30, 40)
+	// Stretched panels inside stretched panels, like the panels docked
+	// into the editor windows
+	inner := NewPanel(host, tex, AnchorStretchCenter)
+	inner.DontFitContent()
+	child.AddChild(inner)
+	inner.layout.SetStretch(5, 5, 5, 5)
+	testUIFrames(host, 3)
+	p := testUIEdges(parent)
+	testExpectEdges(t, "the child", child, matrix.Vec4{
+		p.Left() + 10, p.Top() - 20, p.Right() - 30, p.Bottom() + 40})
+	c := testUIEdges(child)
+	testExpectEdges(t, "the inner panel", inner, matrix.Vec4{
+		c.Left() + 5, c.Top() - 5, c.Right() - 5, c.Bottom() + 5})
+	if pz, cz := parent.entity.Transform.WorldPosition().Z(),
+		child.entity.Transform.WorldPosition().Z(); cz <= pz {
+		t.Errorf("expected the child in front of the parent, got z %f behind %f", cz, pz)
+	}
+}
+
+func TestLayoutStretchFollowsParentResize(t *testing.T) {
+	host, tex := testUIHost(t)
+	parent := NewPanel(host, tex, AnchorCenter)
+	parent.DontFitContent()
+	parent.layout.Scale(200, 100)
+	child := NewPanel(host, tex, AnchorStretchCenter)
+	child.DontFitContent()
+	parent.AddChild(child)
+	child.layout.SetStretch(10, 10, 10, 10)
+	testUIFrames(host, 3)
+	parent.layout.Scale(300, 150)
+	testUIFrames(host, 3)
+	if s := child.layout.PixelSize(); !matrix.Vec2ApproxTo(s, matrix.Vec2{280, 130}, 0.01) {
+		t.Errorf("expected the child to follow the parent to 280x130, got %v", s)
+	}
+}
+
+func TestButtonLabelStretchesToButton(t *testing.T) {
+	host, tex := testUIHost(t)
+	btn := NewButton(host, tex, "Click me!", AnchorCenter)
+	btn.Layout().Scale(100, 50)
+	testUIFrames(host, 3)
+	label := btn.Label()
+	if label.Text() != "Click me!" {
+		t.Errorf("expected the label to show the button text, got %q", label.Text())
+	}
+	testExpectEdges(t, "the label", label, testUIEdges(btn))
+	// The button used to fit itself to the label it stretches, growing
+	// every frame
+	testUIFrames(host, 3)
+	if s := btn.Layout().PixelSize(); !matrix.Vec2ApproxTo(s, matrix.Vec2{100, 50}, 0.01) {
+		t.Errorf("expected the button to stay 100x50, got %v", s)
+	}
+}
+
+func TestProgressBarStretchesToValue(t *testing.T) {
+	host, tex := testUIHost(t)
+	bar := NewProgressBar(host, tex, tex, AnchorTopRight)
+	(*Panel)(bar).DontFitContent()
+	bar.Layout().Scale(100, 20)
+	bar.SetValue(0.25)
+	testUIFrames(host, 3)
+	b := testUIEdges(bar)
+	// The foreground is inset by a pixel on every side
+	testExpectEdges(t, "the foreground", bar.data().fgPanel, matrix.Vec4{
+		b.Left() + 1, b.Top() - 1, b.Left() + 24, b.Bottom() + 1})
+}
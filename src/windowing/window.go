@@ -85,6 +85,8 @@ type Window struct {
 	width, height int
 	isClosed      bool
 	isCrashed     bool
+	headless      bool
+	clipboard     string
 	OnResize      events.Event
 }
 
@@ -130,8 +132,42 @@ func New(windowName string, width, height int) (*Window, error) {
 	return w, err
 }
 
-func (w *Window) PlatformWindow() unsafe.Pointer   { return w.cHandle() }
-func (w *Window) PlatformInstance() unsafe.Pointer { return w.cInstance() }
+// NewHeadless creates a window that has no platform window behind it and
+// is never shown, everything is drawn by the given renderer. It is used to
+// draw scenes where there is no display, like in tests.
+func NewHeadless(width, height int, renderer rendering.Renderer) *Window {
+	w := &Window{
+		Keyboard:     hid.NewKeyboard(),
+		Mouse:        hid.NewMouse(),
+		Touch:        hid.NewTouch(),
+		Stylus:       hid.NewStylus(),
+		Controller:   hid.NewController(),
+		Renderer:     renderer,
+		width:        width,
+		height:       height,
+		evtSharedMem: new(evtMem),
+		headless:     true,
+		OnResize:     events.New(),
+	}
+	w.Cursor = hid.NewCursor(&w.Mouse, &w.Touch, &w.Stylus)
+	return w
+}
+
+func (w *Window) PlatformWindow() unsafe.Pointer {
+	if w.headless {
+		return nil
+	}
+	return w.cHandle()
+}
+
+func (w *Window) PlatformInstance() unsafe.Pointer {
+	if w.headless {
+		return nil
+	}
+	return w.cInstance()
+}
+
+func (w *Window) IsHeadless() bool { return w.headless }
 
 func (w *Window) IsClosed() bool  { return w.isClosed }
 func (w *Window) IsCrashed() bool { return w.isCrashed }
@@ -241,7 +277,9 @@ func (w *Window) processControllerEvent(evtType eventType) {
 }
 
 func (w *Window) Poll() {
-	w.poll()
+	if !w.headless {
+		w.poll()
+	}
 	w.isClosed = w.isClosed || w.evtSharedMem.IsQuit()
 	w.isCrashed = w.isCrashed || w.evtSharedMem.IsFatal()
 	w.Cursor.Poll()
@@ -257,10 +295,15 @@ func (w *Window) EndUpdate() {
 
 func (w *Window) SwapBuffers() {
 	w.Renderer.SwapFrame(int32(w.Width()), int32(w.Height()))
-	swapBuffers(w.handle)
+	if !w.headless {
+		swapBuffers(w.handle)
+	}
 }
 
 func (w *Window) GetDPI() (int, int, error) {
+	if w.headless {
+		return 96, 96, nil
+	}
 	return w.getDPI()
 }
 
@@ -282,14 +325,37 @@ func DPI2PX(pixels, mm, targetMM int) int {
 	return targetMM * (pixels / mm)
 }
 
-func (w *Window) CursorStandard() { w.cursorStandard() }
-func (w *Window) CursorIbeam()    { w.cursorIbeam() }
+func (w *Window) CursorStandard() {
+	if !w.headless {
+		w.cursorStandard()
+	}
+}
 
-func (w *Window) CopyToClipboard(text string) { w.copyToClipboard(text) }
-func (w *Window) ClipboardContents() string   { return w.clipboardContents() }
+func (w *Window) CursorIbeam() {
+	if !w.headless {
+		w.cursorIbeam()
+	}
+}
+
+func (w *Window) CopyToClipboard(text string) {
+	if w.headless {
+		w.clipboard = text
+	} else {
+		w.copyToClipboard(text)
+	}
+}
+
+func (w *Window) ClipboardContents() string {
+	if w.headless {
+		return w.clipboard
+	}
+	return w.clipboardContents()
+}
 
 func (w *Window) Destroy() {
 	w.isClosed = true
 	w.Renderer.Destroy()
-	w.destroy()
+	if !w.headless {
+		w.destroy()
+	}
 }